@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+// Exit codes of check-visor, as expected by Nagios compatible monitoring systems.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// nolint:gochecknoglobals
+var checkStates = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// nolint:gochecknoglobals
+var (
+	checkPK          string
+	checkSocket      string
+	checkTimeout     time.Duration
+	checkWarnLatency time.Duration
+)
+
+// nolint:gochecknoinits
+func init() {
+	checkVisorCmd.Flags().StringVar(&checkPK, "pk", "", "public key, unique public key prefix or name of the visor")
+	checkVisorCmd.Flags().StringVar(&checkSocket, "socket", pathutil.HypervisorAdminSocket(), "hypervisor admin socket path")
+	checkVisorCmd.Flags().DurationVar(&checkTimeout, "timeout", 10*time.Second, "timeout of each request to the hypervisor")
+	checkVisorCmd.Flags().DurationVar(&checkWarnLatency, "warn-latency", 0, "warn when the dmsg latency of the visor exceeds this (0 to disable)")
+
+	rootCmd.AddCommand(checkVisorCmd)
+}
+
+// nolint:gochecknoglobals
+var checkVisorCmd = &cobra.Command{
+	Use:   "check-visor",
+	Short: "Checks the health of a visor through the hypervisor admin socket, for monitoring systems",
+	Long: `Checks the health of a visor through the hypervisor admin socket, printing a single status line.
+The exit code follows the Nagios plugin conventions:
+
+  0  OK        the visor is connected and all of its components are healthy
+  1  WARNING   components of the visor are failing, it is unreachable over dmsg or its latency is high
+  2  CRITICAL  the visor is not connected or does not respond
+  3  UNKNOWN   the hypervisor could not be queried`,
+	Args: cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		code, status := checkVisor(checkPK)
+		fmt.Printf("VISOR %s - %s\n", checkStates[code], status)
+		os.Exit(code)
+	},
+}
+
+// checkVisor returns the exit code and status line of the visor identified by id.
+func checkVisor(id string) (int, string) {
+	if id == "" {
+		return checkUnknown, "no visor given, use --pk"
+	}
+
+	c := &http.Client{
+		Timeout: checkTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", checkSocket)
+			},
+		},
+	}
+
+	path := "/visors/" + url.PathEscape(id)
+
+	var health hypervisor.VisorHealth
+
+	switch status, err := getAPI(c, path+"/health", &health); {
+	case status == http.StatusNotFound:
+		return checkCritical, fmt.Sprintf("%s is not connected", id)
+	case status == http.StatusRequestTimeout:
+		return checkCritical, fmt.Sprintf("%s did not respond to the health check", id)
+	case err != nil:
+		return checkUnknown, err.Error()
+	case health.Status != http.StatusOK || health.HealthInfo == nil:
+		return checkCritical, fmt.Sprintf("%s failed the health check with status %d", id, health.Status)
+	}
+
+	var summary struct {
+		Name  string                  `json:"name"`
+		Probe *hypervisor.ProbeResult `json:"probe"`
+	}
+
+	if _, err := getAPI(c, path, &summary); err != nil {
+		return checkUnknown, err.Error()
+	}
+
+	if summary.Name != "" && summary.Name != id {
+		id = fmt.Sprintf("%s (%s)", id, summary.Name)
+	}
+
+	var failing []string
+
+	for _, comp := range []struct {
+		name   string
+		status int
+	}{
+		{"transport_discovery", health.TransportDiscovery},
+		{"route_finder", health.RouteFinder},
+		{"setup_node", health.SetupNode},
+	} {
+		if comp.status != http.StatusOK {
+			failing = append(failing, comp.name)
+		}
+	}
+
+	if len(failing) > 0 {
+		return checkWarning, fmt.Sprintf("%s has failing components: %s", id, strings.Join(failing, ", "))
+	}
+
+	p := summary.Probe
+
+	switch {
+	case p == nil:
+		return checkOK, fmt.Sprintf("%s is healthy", id)
+	case !p.Reachable:
+		return checkWarning, fmt.Sprintf("%s is unreachable over dmsg: %s", id, p.Error)
+	}
+
+	latency := time.Duration(p.AvgLatencyMs * float64(time.Millisecond))
+	perf := fmt.Sprintf("| latency=%.1fms", p.AvgLatencyMs)
+
+	if checkWarnLatency > 0 && latency > checkWarnLatency {
+		return checkWarning, fmt.Sprintf("%s has a dmsg latency of %s %s", id, latency.Round(time.Millisecond), perf)
+	}
+
+	return checkOK, fmt.Sprintf("%s is healthy %s", id, perf)
+}
+
+// getAPI decodes the response of the hypervisor API at path into v, and returns the status code.
+func getAPI(c *http.Client, path string, v interface{}) (int, error) {
+	resp, err := c.Get("http://hypervisor/api/v1" + path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query hypervisor: %w", err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close response body.")
+		}
+	}()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read hypervisor response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("hypervisor responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	return resp.StatusCode, json.Unmarshal(raw, v)
+}
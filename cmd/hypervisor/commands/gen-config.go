@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
@@ -16,6 +18,9 @@ var (
 	replace       bool
 	configLocType = pathutil.WorkingDirLoc
 	testEnv       bool
+	interactive   bool
+	dryRun        bool
+	genOpts       hypervisor.GenConfigOptions
 )
 
 // nolint:gochecknoinits
@@ -30,6 +35,16 @@ func init() {
 	genConfigCmd.Flags().BoolVarP(&replace, "replace", "r", false, replaceUsage)
 	genConfigCmd.Flags().VarP(&configLocType, "type", "m", configLocTypeUsage)
 	genConfigCmd.Flags().BoolVarP(&testEnv, "testing-environment", "t", false, testEnvUsage)
+	genConfigCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "whether to ask for the settings below interactively")
+	genConfigCmd.Flags().BoolVar(&dryRun, "dry-run", false, "whether to only print the config, without writing any files")
+	genConfigCmd.Flags().StringVar(&genOpts.DBPath, "db-path", "", "path of the database. Uses default of 'type' flag if unspecified.")
+	genConfigCmd.Flags().StringVar(&genOpts.HTTPAddr, "http-addr", "", "address to serve the API and web UI on (default \":8000\")")
+	genConfigCmd.Flags().BoolVar(&genOpts.EnableAuth, "enable-auth", false, "whether to enable user management")
+	genConfigCmd.Flags().StringVar(&genOpts.TLSMode, "tls", hypervisor.TLSModeNone, "TLS mode. Valid values: none, files, self-signed, acme")
+	genConfigCmd.Flags().StringVar(&genOpts.TLSCertFile, "tls-cert", "", "TLS cert file (generated in 'self-signed' mode)")
+	genConfigCmd.Flags().StringVar(&genOpts.TLSKeyFile, "tls-key", "", "TLS key file (generated in 'self-signed' mode)")
+	genConfigCmd.Flags().StringSliceVar(&genOpts.TLSHosts, "tls-hosts", nil, "hosts of the self-signed cert, or domains in 'acme' mode")
+	genConfigCmd.Flags().StringVar(&genOpts.ACMEEmail, "acme-email", "", "contact email of the ACME account")
 }
 
 // nolint:gochecknoglobals
@@ -47,17 +62,42 @@ var genConfigCmd = &cobra.Command{
 		}
 	},
 	Run: func(_ *cobra.Command, _ []string) {
-		var conf hypervisor.Config
-		switch configLocType {
-		case pathutil.WorkingDirLoc:
-			conf = hypervisor.GenerateWorkDirConfig(testEnv)
-		case pathutil.HomeLoc:
-			conf = hypervisor.GenerateHomeConfig(testEnv)
-		case pathutil.LocalLoc:
-			conf = hypervisor.GenerateLocalConfig(testEnv)
-		default:
-			log.Fatalln("invalid config type:", configLocType)
+		genOpts.Location, genOpts.TestEnv = configLocType, testEnv
+
+		if interactive {
+			if err := hypervisor.PromptGenConfigOptions(os.Stdin, os.Stdout, &genOpts); err != nil {
+				log.WithError(err).Fatalln("failed to read settings")
+			}
+		}
+
+		conf, err := hypervisor.GenerateConfig(genOpts)
+		if err != nil {
+			log.WithError(err).Fatalln("invalid settings")
+		}
+
+		if dryRun {
+			raw, err := json.MarshalIndent(conf, "", "\t")
+			if err != nil {
+				log.WithError(err).Fatal("unexpected error, report to dev")
+			}
+
+			fmt.Println(string(raw))
+
+			return
 		}
+
+		if !replace && pathutil.Exists(output) {
+			log.Fatalf("file %s already exists, stopping as 'replace,r' flag is not set", output)
+		}
+
+		if genOpts.TLSMode == hypervisor.TLSModeSelfSigned {
+			if err := hypervisor.WriteSelfSignedCert(conf.TLSCertFile, conf.TLSKeyFile, genOpts.TLSHosts); err != nil {
+				log.WithError(err).Fatalln("failed to write self-signed cert")
+			}
+
+			log.Infof("Wrote self-signed cert to %s and its key to %s", conf.TLSCertFile, conf.TLSKeyFile)
+		}
+
 		pathutil.WriteJSONConfig(conf, output, replace)
 	},
 }
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+)
+
+// nolint:gochecknoinits
+func init() {
+	rootCmd.AddCommand(genUserCmd)
+}
+
+// nolint:gochecknoglobals
+var genUserCmd = &cobra.Command{
+	Use:   "gen-user <name>",
+	Short: "Generates an entry of a users file, for the 'file' user store",
+	Long: `Generates an entry of a users file, for the 'file' user store.
+The password is read from the terminal, or from the first line of stdin if it is not a terminal.
+The entry is printed as JSON, to be added to the array of the users file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		password, err := readPassword()
+		if err != nil {
+			log.WithError(err).Fatalln("Failed to read password")
+		}
+
+		u, err := hypervisor.NewFileUser(args[0], password)
+		if err != nil {
+			log.WithError(err).Fatalln("Invalid user")
+		}
+
+		raw, err := json.MarshalIndent(u, "", "\t")
+		if err != nil {
+			log.WithError(err).Fatalln("Unexpected error")
+		}
+
+		fmt.Println(string(raw))
+	},
+}
+
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !terminal.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	raw, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+
+	return string(raw), err
+}
@@ -0,0 +1,88 @@
+//go:build loadtest
+// +build loadtest
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+)
+
+// nolint:gochecknoglobals
+var (
+	loadUsers    int
+	loadDuration time.Duration
+	loadThink    time.Duration
+)
+
+// nolint:gochecknoinits
+func init() {
+	loadTestCmd.Flags().IntVar(&loadUsers, "users", 50, "number of concurrent dashboard users to simulate")
+	loadTestCmd.Flags().DurationVar(&loadDuration, "duration", 30*time.Second, "how long to generate load for")
+	loadTestCmd.Flags().DurationVar(&loadThink, "think", 0, "pause between requests of a single user")
+	loadTestCmd.Flags().BoolVar(&mockEnableAuth, "mock-enable-auth", false, "whether to enable user management")
+	loadTestCmd.Flags().IntVar(&mockVisors, "mock-visors", 5, "number of mock visors")
+	loadTestCmd.Flags().IntVar(&mockMaxTps, "mock-max-tps", 10, "max number of transports per mock visor")
+	loadTestCmd.Flags().IntVar(&mockMaxRoutes, "mock-max-routes", 30, "max number of routes per mock visor")
+
+	rootCmd.AddCommand(loadTestCmd)
+}
+
+// nolint:gochecknoglobals
+var loadTestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Simulates concurrent dashboard users against mock visors and reports endpoint latencies",
+	Run: func(_ *cobra.Command, _ []string) {
+		dir, err := ioutil.TempDir(os.TempDir(), "hypervisor-loadtest")
+		if err != nil {
+			log.Fatalf("Failed to create temporary directory: %v", err)
+		}
+
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				log.WithError(err).Warn("Failed to remove temporary directory.")
+			}
+		}()
+
+		conf := hypervisor.GenerateWorkDirConfig(true)
+		conf.DBPath = filepath.Join(dir, "users.db")
+
+		// Simulated users share one client IP, so rate limits would reject most of the load.
+		conf.RateLimits.PerIP = -1
+		conf.RateLimits.PerUser = -1
+
+		hv, err := hypervisor.New(nil, conf)
+		if err != nil {
+			log.Fatalln("Failed to start hypervisor:", err)
+		}
+
+		defer func() {
+			if err := hv.Close(); err != nil {
+				log.WithError(err).Warn("Failed to close hypervisor.")
+			}
+		}()
+
+		prepareMockData(hv)
+
+		log.WithField("users", loadUsers).WithField("duration", loadDuration).Info("Generating load...")
+
+		report, err := hypervisor.RunLoadTest(hv, hypervisor.LoadTestConfig{
+			Users:    loadUsers,
+			Duration: loadDuration,
+			Think:    loadThink,
+		})
+		if err != nil {
+			log.Fatalln("Load test failed:", err)
+		}
+
+		if _, err := report.WriteTo(os.Stdout); err != nil {
+			log.Fatalln("Failed to write report:", err)
+		}
+	},
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+// nolint:gochecknoglobals
+var (
+	migrateOutput string
+	migrateDryRun bool
+)
+
+// nolint:gochecknoinits
+func init() {
+	rootCmd.AddCommand(migrateConfigCmd)
+	migrateConfigCmd.Flags().StringVarP(&migrateOutput, "output", "o", "", "path of the migrated config file. Replaces the input file if unspecified.")
+	migrateConfigCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "whether to only validate and print the migrated config, without writing any files")
+}
+
+// nolint:gochecknoglobals
+var migrateConfigCmd = &cobra.Command{
+	Use:   "migrate-config <config-path>",
+	Short: "validates a configuration file and migrates it to the latest version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		raw, err := ioutil.ReadFile(filepath.Clean(args[0]))
+		if err != nil {
+			log.WithError(err).Fatalln("failed to read config file")
+		}
+
+		conf, version, err := hypervisor.ParseAndMigrate(raw, false)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if migrateDryRun {
+			out, err := json.MarshalIndent(conf, "", "\t")
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			fmt.Println(string(out))
+
+			return
+		}
+
+		if version == hypervisor.ConfigVersion && migrateOutput == "" {
+			fmt.Printf("Config is valid and at the latest version %q\n", version)
+			return
+		}
+
+		if migrateOutput == "" {
+			migrateOutput = args[0]
+		}
+
+		pathutil.WriteJSONConfig(conf, migrateOutput, true)
+		fmt.Printf("Migrated config from version %q to %q\n", version, hypervisor.ConfigVersion)
+	},
+}
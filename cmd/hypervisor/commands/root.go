@@ -2,8 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/rakyll/statik/fs"
 	"github.com/skycoin/dmsg"
@@ -68,14 +70,21 @@ var rootCmd = &cobra.Command{
 			prepareDmsg(hv, conf)
 		}
 
+		if conf.AdminSocket != "" {
+			go serveAdmin(hv, conf.AdminSocket)
+		}
+
 		// Serve HTTP(s).
 		log := log.
 			WithField("addr", conf.HTTPAddr).
 			WithField("tls", conf.EnableTLS)
 		log.Info("Serving hypervisor...")
-		if conf.EnableTLS {
+		switch {
+		case conf.EnableTLS && conf.ACME.Enable:
+			err = hypervisor.ListenAndServeACME(conf.HTTPAddr, conf.ACME, hv)
+		case conf.EnableTLS:
 			err = http.ListenAndServeTLS(conf.HTTPAddr, conf.TLSCertFile, conf.TLSKeyFile, hv)
-		} else {
+		default:
 			err = http.ListenAndServe(conf.HTTPAddr, hv)
 		}
 		if err != nil {
@@ -89,10 +98,18 @@ func prepareConfig(args []string) (conf hypervisor.Config) {
 	if configPath == "" {
 		configPath = pathutil.FindConfigPath(args, -1, configEnv, pathutil.HypervisorDefaults())
 	}
-	conf.FillDefaults(mock)
-	if err := conf.Parse(configPath); err != nil {
+	raw, err := ioutil.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		log.WithError(err).Fatalln("failed to read config file")
+	}
+	conf, version, err := hypervisor.ParseAndMigrate(raw, mock)
+	if err != nil {
 		log.WithError(err).Fatalln("failed to parse config file")
 	}
+	if version != hypervisor.ConfigVersion {
+		log.Warnf("Config is at version %q and was migrated to %q; run 'hypervisor migrate-config' to update the file.",
+			version, hypervisor.ConfigVersion)
+	}
 	log.WithField("config", conf).Info()
 	return conf
 }
@@ -127,6 +144,29 @@ func prepareDmsg(hv *hypervisor.Hypervisor, conf hypervisor.Config) {
 	}()
 	log.WithField("addr", fmt.Sprintf("dmsg://%s:%d", conf.PK, conf.DmsgPort)).
 		Info("Serving RPC client over dmsg.")
+
+	standbyL, err := dmsgC.Listen(conf.StandbyPort)
+	if err != nil {
+		log.WithField("addr", fmt.Sprintf("dmsg://%s:%d", conf.PK, conf.StandbyPort)).
+			Fatal("Failed to listen over dmsg.")
+	}
+	go func() {
+		if err := hv.ServeStandby(standbyL); err != nil {
+			log.WithError(err).
+				Fatal("Failed to serve standby visors over dmsg.")
+		}
+	}()
+	log.WithField("addr", fmt.Sprintf("dmsg://%s:%d", conf.PK, conf.StandbyPort)).
+		Info("Serving standby visors over dmsg.")
+}
+
+func serveAdmin(hv *hypervisor.Hypervisor, path string) {
+	log := log.WithField("admin_socket", path)
+	log.Info("Serving admin API over unix socket...")
+
+	if err := hv.ServeAdmin(path); err != nil {
+		log.WithError(err).Error("Stopped serving admin API.")
+	}
 }
 
 // Execute executes root CLI command.
@@ -0,0 +1,145 @@
+package hypervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+var logger = logging.MustGetLogger("skywire-cli")
+
+var socketPath string
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&socketPath, "socket", pathutil.HypervisorAdminSocket(), "hypervisor admin socket path")
+
+	RootCmd.AddCommand(
+		lsVisorsCmd,
+		callCmd,
+	)
+}
+
+// RootCmd contains commands that interact with the local hypervisor over its admin socket.
+var RootCmd = &cobra.Command{
+	Use:   "hypervisor",
+	Short: "Contains sub-commands that interact with the local Hypervisor",
+}
+
+var lsVisorsCmd = &cobra.Command{
+	Use:   "ls-visors",
+	Short: "Lists the visors connected to the hypervisor",
+	Run: func(_ *cobra.Command, _ []string) {
+		var visors []struct {
+			PubKey  cipher.PubKey `json:"local_pk"`
+			Name    string        `json:"name"`
+			TCPAddr string        `json:"tcp_addr"`
+			Online  bool          `json:"online"`
+		}
+
+		raw, err := call(http.MethodGet, "/visors", nil)
+		internal.Catch(err)
+		internal.Catch(json.Unmarshal(raw, &visors), "failed to decode visors:")
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		_, err = fmt.Fprintln(w, "pk\tname\taddr\tonline")
+		internal.Catch(err)
+
+		for _, v := range visors {
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", v.PubKey, v.Name, v.TCPAddr, v.Online)
+			internal.Catch(err)
+		}
+
+		internal.Catch(w.Flush())
+	},
+}
+
+var callCmd = &cobra.Command{
+	Use:   "call <method> <path> [<json-body>]",
+	Short: "Calls an endpoint of the hypervisor API and prints the response",
+	Long: `Calls an endpoint of the hypervisor API and prints the response.
+The path is relative to '/api/v1', for example:
+
+  skywire-cli hypervisor call GET /visors/<pk>/apps
+  skywire-cli hypervisor call POST /visors/<pk>/exec '{"command":"uptime"}'`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(_ *cobra.Command, args []string) {
+		var body io.Reader
+		if len(args) == 3 {
+			body = strings.NewReader(args[2])
+		}
+
+		raw, err := call(strings.ToUpper(args[0]), args[1], body)
+		internal.Catch(err)
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, raw, "", "  "); err != nil {
+			out.Reset()
+			out.Write(raw)
+		}
+
+		fmt.Println(out.String())
+	},
+}
+
+const requestTimeout = time.Second * 60
+
+func httpClient() *http.Client {
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// call performs a request against the hypervisor API and returns the response body.
+func call(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, "http://hypervisor/api/v1"+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to hypervisor admin socket: %w", err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.WithError(err).Warn("Failed to close response body.")
+		}
+	}()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("hypervisor responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+
+	return raw, nil
+}
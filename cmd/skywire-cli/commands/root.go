@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/skycoin/skywire/cmd/skywire-cli/commands/hypervisor"
 	"github.com/skycoin/skywire/cmd/skywire-cli/commands/mdisc"
 	"github.com/skycoin/skywire/cmd/skywire-cli/commands/rtfind"
 	"github.com/skycoin/skywire/cmd/skywire-cli/commands/visor"
@@ -18,6 +19,7 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(
 		visor.RootCmd,
+		hypervisor.RootCmd,
 		mdisc.RootCmd,
 		rtfind.RootCmd,
 	)
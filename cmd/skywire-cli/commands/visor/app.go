@@ -3,6 +3,7 @@ package visor
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -11,16 +12,29 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+	"github.com/skycoin/skywire/pkg/app"
 	"github.com/skycoin/skywire/pkg/visor"
 )
 
+var (
+	logsLevel string
+	logsLimit int
+)
+
 func init() {
+	appLogsSinceCmd.Flags().StringVar(&logsLevel, "level", "", "minimum level of the logs, such as \"warn\". Logs of all levels are fetched if unspecified.")
+	appLogsSinceCmd.Flags().IntVar(&logsLimit, "limit", 0, "maximum number of logs, counted from the oldest. 0 fetches all the logs.")
+
 	RootCmd.AddCommand(
 		lsAppsCmd,
 		startAppCmd,
 		stopAppCmd,
 		setAppAutostartCmd,
+		appUsageCmd,
+		appSettingsCmd,
+		setAppSettingCmd,
 		appLogsSinceCmd,
+		appLogsAfterCmd,
 		execCmd,
 	)
 }
@@ -33,15 +47,19 @@ var lsAppsCmd = &cobra.Command{
 		internal.Catch(err)
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
-		_, err = fmt.Fprintln(w, "app\tports\tauto_start\tstatus")
+		_, err = fmt.Fprintln(w, "app\tbinary\tports\tauto_start\tstatus\tcrashes")
 		internal.Catch(err)
 
 		for _, state := range states {
 			status := "stopped"
-			if state.Status == visor.AppStatusRunning {
+			if state.Status == visor.AppStatusRunning && state.Health == visor.AppHealthUnhealthy {
+				status = "degraded"
+			} else if state.Status == visor.AppStatusRunning {
 				status = "running"
+			} else if state.RestartPending {
+				status = "restarting"
 			}
-			_, err = fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", state.Name, strconv.Itoa(int(state.Port)), state.AutoStart, status)
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%d\n", state.Name, state.Binary, strconv.Itoa(int(state.Port)), state.AutoStart, status, state.Crashes)
 			internal.Catch(err)
 		}
 		internal.Catch(w.Flush())
@@ -87,6 +105,76 @@ var setAppAutostartCmd = &cobra.Command{
 	},
 }
 
+var appUsageCmd = &cobra.Command{
+	Use:   "app-usage <name>",
+	Short: "Shows the resource usage and limits of a running app",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		usage, err := rpcClient().AppUsage(args[0])
+		internal.Catch(err)
+
+		limit := func(v uint64) string {
+			if v == 0 {
+				return "unlimited"
+			}
+			return strconv.FormatUint(v, 10)
+		}
+
+		cpuLimit := "unlimited"
+		if usage.Limits.CPU > 0 {
+			cpuLimit = strconv.FormatFloat(usage.Limits.CPU, 'f', -1, 64)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		_, err = fmt.Fprintln(w, "resource\tusage\tlimit")
+		internal.Catch(err)
+		_, err = fmt.Fprintf(w, "cpu_time\t%s\t%s\n", usage.CPUTime, cpuLimit)
+		internal.Catch(err)
+		_, err = fmt.Fprintf(w, "memory\t%d\t%s\n", usage.Memory, limit(usage.Limits.Memory))
+		internal.Catch(err)
+		_, err = fmt.Fprintf(w, "files\t%d\t%s\n", usage.Files, limit(usage.Limits.Files))
+		internal.Catch(err)
+		internal.Catch(w.Flush())
+	},
+}
+
+var appSettingsCmd = &cobra.Command{
+	Use:   "app-settings <name>",
+	Short: "Lists the settings of an app",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		settings, err := rpcClient().GetAppSettings(args[0])
+		internal.Catch(err)
+
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		_, err = fmt.Fprintln(w, "key\tvalue")
+		internal.Catch(err)
+
+		for _, k := range keys {
+			_, err = fmt.Fprintf(w, "%s\t%s\n", k, settings[k])
+			internal.Catch(err)
+		}
+		internal.Catch(w.Flush())
+	},
+}
+
+var setAppSettingCmd = &cobra.Command{
+	Use:   "set-app-setting <name> <key> <value>",
+	Short: "Sets a setting of an app, restarting the app if it is running",
+	Args:  cobra.MinimumNArgs(3),
+	Run: func(_ *cobra.Command, args []string) {
+		internal.Catch(rpcClient().SetAppSetting(args[0], args[1], args[2]))
+		fmt.Println("OK")
+	},
+}
+
 var appLogsSinceCmd = &cobra.Command{
 	Use:   "app-logs-since <name> <timestamp>",
 	Short: "Gets logs from given app since RFC3339Nano-formated timestamp. \"beginning\" is a special timestamp to fetch all the logs",
@@ -102,7 +190,7 @@ var appLogsSinceCmd = &cobra.Command{
 			t, err = time.Parse(time.RFC3339Nano, strTime)
 			internal.Catch(err)
 		}
-		logs, err := rpcClient().LogsSince(t, args[0])
+		logs, err := rpcClient().LogsSince(t, args[0], app.LogFilter{Level: logsLevel, Limit: logsLimit})
 		internal.Catch(err)
 		if len(logs) > 0 {
 			fmt.Println(logs)
@@ -112,12 +200,33 @@ var appLogsSinceCmd = &cobra.Command{
 	},
 }
 
+var appLogsAfterCmd = &cobra.Command{
+	Use:   "app-logs-after <name> <seq>",
+	Short: "Gets logs from given app after the given log sequence number, printing the sequence number of each log. 0 fetches all the logs",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		seq, err := strconv.ParseUint(args[1], 10, 64)
+		internal.Catch(err)
+
+		logs, err := rpcClient().LogsAfter(args[0], seq)
+		internal.Catch(err)
+
+		if len(logs) == 0 {
+			fmt.Println("no logs")
+		}
+
+		for _, l := range logs {
+			fmt.Printf("%d %s", l.Seq, l.Log)
+		}
+	},
+}
+
 var execCmd = &cobra.Command{
 	Use:   "exec <command>",
 	Short: "Executes the given command",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(_ *cobra.Command, args []string) {
-		out, err := rpcClient().Exec(strings.Join(args, " "))
+		out, err := rpcClient().Exec(strings.Join(args, " "), 0)
 		internal.Catch(err)
 		fmt.Print(string(out))
 	},
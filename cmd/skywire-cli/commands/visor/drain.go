@@ -0,0 +1,29 @@
+package visor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+var drainTimeout time.Duration
+
+func init() {
+	drainCmd.Flags().DurationVar(&drainTimeout, "timeout", 0, "how long to wait for routes to become idle before shutting down (default 10m)")
+	RootCmd.AddCommand(drainCmd)
+}
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Makes the visor refuse new transports and routes, and shut down once its routes are idle",
+	Run: func(_ *cobra.Command, _ []string) {
+		status, err := rpcClient().Drain(drainTimeout)
+		internal.Catch(err)
+
+		fmt.Printf("Draining: %d routing rules in use, shutting down by %s at the latest\n",
+			status.Routes, status.Deadline.Format(time.RFC3339))
+	},
+}
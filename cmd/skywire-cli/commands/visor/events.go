@@ -0,0 +1,42 @@
+package visor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+var (
+	eventsCursor uint64
+	eventsKinds  []string
+	eventsWait   time.Duration
+)
+
+func init() {
+	eventsCmd.Flags().Uint64Var(&eventsCursor, "cursor", 0, "cursor printed by the last call, 0 for all the events kept")
+	eventsCmd.Flags().StringSliceVar(&eventsKinds, "kind", nil, "kinds of events to print, all kinds if unset")
+	eventsCmd.Flags().DurationVar(&eventsWait, "wait", 0, "how long to wait for events if there are none")
+	RootCmd.AddCommand(eventsCmd)
+}
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Prints events of transports, routes, app crashes and updates after a cursor, and the cursor to continue from",
+	Run: func(_ *cobra.Command, _ []string) {
+		out, err := rpcClient().Events(eventsCursor, eventsKinds, eventsWait)
+		internal.Catch(err)
+
+		if out.Missed > 0 {
+			fmt.Printf("%d events missed\n", out.Missed)
+		}
+
+		for _, e := range out.Events {
+			fmt.Printf("%d %s %s %s\n", e.Seq, e.Time.Format(time.RFC3339), e.Kind, e.Data)
+		}
+
+		fmt.Printf("cursor: %d\n", out.Cursor)
+	},
+}
@@ -99,13 +99,15 @@ func homeConfig() *visor.Config {
 
 func localConfig() *visor.Config {
 	c := defaultConfig()
-	c.AppsPath = "/usr/local/skycoin/skywire/apps"
-	c.Transport.LogStore.Location = "/usr/local/skycoin/skywire/transport_logs"
+	paths := pathutil.VisorSystemPaths()
+	c.AppsPath = paths.Apps
+	c.LocalPath = filepath.Join(paths.Dir, "local")
+	c.Transport.LogStore.Location = filepath.Join(paths.Dir, "transport_logs")
 	return c
 }
 
 func defaultConfig() *visor.Config {
-	conf := &visor.Config{}
+	conf := &visor.Config{Version: visor.ConfigVersion}
 
 	if sk.Null() {
 		conf.KeyPair = visor.NewKeyPair()
@@ -121,6 +123,7 @@ func defaultConfig() *visor.Config {
 	}
 
 	conf.Dmsg = visor.DefaultDmsgConfig()
+	conf.SUDPH = visor.DefaultSUDPHConfig()
 
 	ptyConf := defaultDmsgPtyConfig()
 	conf.DmsgPty = &ptyConf
@@ -184,15 +187,15 @@ func defaultSkychatConfig() visor.AppConfig {
 }
 
 func defaultSkysocksConfig(passcode string) visor.AppConfig {
-	var args []string
+	var settings map[string]string
 	if passcode != "" {
-		args = []string{"-passcode", passcode}
+		settings = map[string]string{"passcode": passcode}
 	}
 	return visor.AppConfig{
 		App:       skyenv.SkysocksName,
 		AutoStart: true,
 		Port:      routing.Port(skyenv.SkysocksPort),
-		Args:      args,
+		Settings:  settings,
 	}
 }
 
@@ -0,0 +1,62 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+func init() {
+	RootCmd.AddCommand(hypervisorsCmd)
+	hypervisorsCmd.AddCommand(lsHypervisorsCmd, addHypervisorsCmd, rmHypervisorsCmd)
+}
+
+var hypervisorsCmd = &cobra.Command{
+	Use:   "hypervisors",
+	Short: "Manages the whitelist of hypervisors allowed to manage the visor",
+}
+
+var lsHypervisorsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "Lists whitelisted hypervisor public keys",
+	Run: func(_ *cobra.Command, _ []string) {
+		pks, err := rpcClient().HypervisorPKs()
+		internal.Catch(err)
+
+		for _, pk := range pks {
+			fmt.Println(pk)
+		}
+	},
+}
+
+var addHypervisorsCmd = &cobra.Command{
+	Use:   "add <public-key>...",
+	Short: "Adds hypervisor public keys to the whitelist",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		internal.Catch(rpcClient().UpdateHypervisorPKs(parsePKs(args), nil))
+		fmt.Println("OK")
+	},
+}
+
+var rmHypervisorsCmd = &cobra.Command{
+	Use:   "rm <public-key>...",
+	Short: "Removes hypervisor public keys from the whitelist",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		internal.Catch(rpcClient().UpdateHypervisorPKs(nil, parsePKs(args)))
+		fmt.Println("OK")
+	},
+}
+
+func parsePKs(args []string) []cipher.PubKey {
+	pks := make([]cipher.PubKey, len(args))
+	for i, arg := range args {
+		pks[i] = internal.ParsePK("public-key", arg)
+	}
+
+	return pks
+}
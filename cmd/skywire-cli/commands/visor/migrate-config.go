@@ -0,0 +1,64 @@
+package visor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+var (
+	migrateOutput string
+	migrateDryRun bool
+)
+
+func init() {
+	RootCmd.AddCommand(migrateConfigCmd)
+	migrateConfigCmd.Flags().StringVarP(&migrateOutput, "output", "o", "", "path of the migrated config file. Replaces the input file if unspecified.")
+	migrateConfigCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "whether to only validate and print the migrated config, without writing any files")
+}
+
+var migrateConfigCmd = &cobra.Command{
+	Use:   "migrate-config <config-path>",
+	Short: "Validates a config file and migrates it to the latest version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		raw, err := ioutil.ReadFile(filepath.Clean(args[0]))
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to read config")
+		}
+
+		conf, version, err := visor.ParseAndMigrate(raw)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if migrateDryRun {
+			out, err := json.MarshalIndent(conf, "", "\t")
+			if err != nil {
+				logger.Fatal(err)
+			}
+
+			fmt.Println(string(out))
+
+			return
+		}
+
+		if version == visor.ConfigVersion && migrateOutput == "" {
+			fmt.Printf("Config is valid and at the latest version %q\n", version)
+			return
+		}
+
+		if migrateOutput == "" {
+			migrateOutput = args[0]
+		}
+
+		pathutil.WriteJSONConfig(conf, migrateOutput, true)
+		fmt.Printf("Migrated config from version %q to %q\n", version, visor.ConfigVersion)
+	},
+}
@@ -0,0 +1,34 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+func init() {
+	RootCmd.AddCommand(
+		rebootHostCmd,
+		shutdownHostCmd,
+	)
+}
+
+var rebootHostCmd = &cobra.Command{
+	Use:   "reboot-host",
+	Short: "Reboots the host of the visor, if power management is enabled in the visor config",
+	Run: func(_ *cobra.Command, _ []string) {
+		internal.Catch(rpcClient().Reboot())
+		fmt.Println("OK")
+	},
+}
+
+var shutdownHostCmd = &cobra.Command{
+	Use:   "shutdown-host",
+	Short: "Powers off the host of the visor, if power management is enabled in the visor config",
+	Run: func(_ *cobra.Command, _ []string) {
+		internal.Catch(rpcClient().Shutdown())
+		fmt.Println("OK")
+	},
+}
@@ -0,0 +1,22 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+func init() {
+	RootCmd.AddCommand(reloadConfigCmd)
+}
+
+var reloadConfigCmd = &cobra.Command{
+	Use:   "reload-config",
+	Short: "Makes the visor re-read its config file and apply changes which don't need a restart",
+	Run: func(_ *cobra.Command, _ []string) {
+		internal.Catch(rpcClient().ReloadConfig())
+		fmt.Println("OK")
+	},
+}
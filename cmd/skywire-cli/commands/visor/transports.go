@@ -12,7 +12,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+	"github.com/skycoin/skywire/pkg/snet/kcp"
 	"github.com/skycoin/skywire/pkg/snet/stcp"
+	"github.com/skycoin/skywire/pkg/snet/sudph"
 	"github.com/skycoin/skywire/pkg/visor"
 )
 
@@ -80,7 +82,7 @@ var (
 
 func init() {
 	const (
-		typeFlagUsage    = "type of transport to add; if unspecified, cli will attempt to establish a transport in the following order: stcp, dmsg"
+		typeFlagUsage    = "type of transport to add; if unspecified, cli will attempt to establish a transport in the following order: stcp, kcp, sudph, dmsg"
 		publicFlagUsage  = "whether to make the transport public"
 		timeoutFlagUsage = "if specified, sets an operation timeout"
 	)
@@ -108,19 +110,22 @@ var addTpCmd = &cobra.Command{
 
 			logger.Infof("Established %v transport to %v", transportType, pk)
 		} else {
-			transportType = stcp.Type
+			types := []string{stcp.Type, kcp.Type, sudph.Type, dmsg.Type}
 
-			tp, err = rpcClient().AddTransport(pk, transportType, public, timeout)
-			if err != nil {
-				logger.WithError(err).
-					Warnf("Failed to establish stcp transport. Trying to establish dmsg transport")
-
-				transportType = dmsg.Type
+			for i, tpType := range types {
+				transportType = tpType
 
 				tp, err = rpcClient().AddTransport(pk, transportType, public, timeout)
-				if err != nil {
-					logger.WithError(err).Fatalf("Failed to establish dmsg transport")
+				if err == nil {
+					break
 				}
+
+				if i == len(types)-1 {
+					logger.WithError(err).Fatalf("Failed to establish %v transport", transportType)
+				}
+
+				logger.WithError(err).
+					Warnf("Failed to establish %v transport. Trying to establish %v transport", transportType, types[i+1])
 			}
 
 			logger.Infof("Established %v transport to %v", transportType, pk)
@@ -0,0 +1,35 @@
+package visor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/cmd/skywire-cli/internal"
+)
+
+func init() {
+	RootCmd.AddCommand(uptimeCmd)
+}
+
+var uptimeCmd = &cobra.Command{
+	Use:   "uptime",
+	Short: "Prints the uptime of the visor across its restarts, its boot count and the reason of its last shutdown",
+	Run: func(_ *cobra.Command, _ []string) {
+		stats, err := rpcClient().UptimeStats()
+		internal.Catch(err)
+
+		fmt.Printf("uptime: %s (since %s)\n", seconds(stats.Uptime), stats.StartedAt.Format(time.RFC3339))
+		fmt.Printf("total uptime: %s\n", seconds(stats.TotalUptime))
+		fmt.Printf("boots: %d\n", stats.Boots)
+
+		if stats.LastShutdown != "" {
+			fmt.Printf("last shutdown: %s (last running at %s)\n", stats.LastShutdown, stats.LastShutdownAt.Format(time.RFC3339))
+		}
+	},
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s) * time.Second
+}
@@ -4,12 +4,10 @@ package commands
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"log/syslog"
 	"net/http"
 	_ "net/http/pprof" // nolint:gosec // TODO: consider removing for security reasons
 	"os"
@@ -20,7 +18,6 @@ import (
 	"time"
 
 	"github.com/pkg/profile"
-	logrussyslog "github.com/sirupsen/logrus/hooks/syslog"
 	"github.com/skycoin/dmsg/discord"
 	"github.com/skycoin/skycoin/src/util/logging"
 	"github.com/spf13/cobra"
@@ -28,6 +25,7 @@ import (
 	"github.com/skycoin/skywire/internal/utclient"
 	"github.com/skycoin/skywire/pkg/restart"
 	"github.com/skycoin/skywire/pkg/util/buildinfo"
+	"github.com/skycoin/skywire/pkg/util/daemon"
 	"github.com/skycoin/skywire/pkg/util/pathutil"
 	"github.com/skycoin/skywire/pkg/visor"
 )
@@ -45,14 +43,18 @@ type runCfg struct {
 	profileMode  string
 	port         string
 	startDelay   string
+	logFile      string
+	service      bool
 	args         []string
 
-	profileStop  func()
-	logger       *logging.Logger
-	masterLogger *logging.MasterLogger
-	conf         visor.Config
-	visor        *visor.Visor
-	restartCtx   *restart.Context
+	profileStop    func()
+	logger         *logging.Logger
+	masterLogger   *logging.MasterLogger
+	conf           *visor.Config
+	visor          *visor.Visor
+	restartCtx     *restart.Context
+	serviceStop    <-chan struct{}
+	serviceStopped func()
 }
 
 var cfg *runCfg
@@ -60,6 +62,7 @@ var cfg *runCfg
 var rootCmd = &cobra.Command{
 	Use:   "skywire-visor [config-path]",
 	Short: "Visor for skywire",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(_ *cobra.Command, args []string) {
 		if _, err := buildinfo.Get().WriteTo(log.Writer()); err != nil {
 			log.Printf("Failed to output build info: %v", err)
@@ -69,6 +72,7 @@ var rootCmd = &cobra.Command{
 
 		cfg.startProfiler().
 			startLogger().
+			startService().
 			readConfig().
 			runVisor().
 			waitOsSignals().
@@ -78,13 +82,19 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	cfg = &runCfg{}
+	cfg = &runCfg{serviceStopped: func() {}}
 	rootCmd.Flags().StringVarP(&cfg.syslogAddr, "syslog", "", "none", "syslog server address. E.g. localhost:514")
 	rootCmd.Flags().StringVarP(&cfg.tag, "tag", "", "skywire", "logging tag")
 	rootCmd.Flags().BoolVarP(&cfg.cfgFromStdin, "stdin", "i", false, "read config from STDIN")
 	rootCmd.Flags().StringVarP(&cfg.profileMode, "profile", "p", "none", "enable profiling with pprof. Mode:  none or one of: [cpu, mem, mutex, block, trace, http]")
 	rootCmd.Flags().StringVarP(&cfg.port, "port", "", "6060", "port for http-mode of pprof")
 	rootCmd.Flags().StringVarP(&cfg.startDelay, "delay", "", "0ns", "delay before visor start")
+	rootCmd.Flags().StringVar(&cfg.logFile, "log-file", "", "append logs to this file instead of writing them to stderr")
+	rootCmd.Flags().BoolVar(&cfg.service, "service", false, "run under the service manager, as installed by 'skywire-visor service install'")
+
+	if err := rootCmd.Flags().MarkHidden("service"); err != nil {
+		log.Fatal(err)
+	}
 
 	cfg.restartCtx = restart.CaptureContext()
 }
@@ -132,14 +142,21 @@ func (cfg *runCfg) startLogger() *runCfg {
 	cfg.masterLogger = logging.NewMasterLogger()
 	cfg.logger = cfg.masterLogger.PackageLogger(cfg.tag)
 
-	if cfg.syslogAddr != "none" {
-		hook, err := logrussyslog.NewSyslogHook("udp", cfg.syslogAddr, syslog.LOG_INFO, cfg.tag)
+	if cfg.logFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.logFile), 0750); err != nil {
+			cfg.logger.Fatalf("Failed to create log directory: %v", err)
+		}
+
+		f, err := os.OpenFile(filepath.Clean(cfg.logFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
 		if err != nil {
-			cfg.logger.Error("Unable to connect to syslog daemon:", err)
-		} else {
-			cfg.masterLogger.AddHook(hook)
-			cfg.masterLogger.Out = ioutil.Discard
+			cfg.logger.Fatalf("Failed to open log file: %v", err)
 		}
+
+		cfg.masterLogger.Out = f
+	}
+
+	if cfg.syslogAddr != "none" {
+		cfg.addSyslogHook()
 	}
 
 	if discordWebhookURL := discord.GetWebhookURLFromEnv(); discordWebhookURL != "" {
@@ -170,6 +187,14 @@ func (cfg *runCfg) readConfig() *runCfg {
 
 		cfg.logger.Infof("Reading config from %v", cp)
 
+		// Service managers start services in their own directory, such as / or System32:
+		// relative paths of the config are resolved from its directory instead.
+		if cfg.service {
+			if err := os.Chdir(filepath.Dir(cp)); err != nil {
+				cfg.logger.Fatalf("Failed to change to config directory: %v", err)
+			}
+		}
+
 		rdr = file
 		configPath = &cp
 	} else {
@@ -182,11 +207,31 @@ func (cfg *runCfg) readConfig() *runCfg {
 		cfg.logger.Fatalf("Failed to read config: %v", err)
 	}
 
-	if err := json.Unmarshal(raw, &cfg.conf); err != nil {
-		cfg.logger.WithField("raw", string(raw)).Fatalf("Failed to decode config: %s", err)
+	conf, version, err := visor.ParseAndMigrate(raw)
+	if err != nil {
+		cfg.logger.Fatalf("Failed to parse config: %v", err)
 	}
 
-	cfg.logger.Infof("Config: %#v", &cfg.conf)
+	if version != visor.ConfigVersion {
+		cfg.logger.Warnf("Config is at version %q and was migrated to %q; run 'skywire-cli visor migrate-config' to update the file",
+			version, visor.ConfigVersion)
+	}
+
+	overridden, err := conf.ApplyEnv(os.Environ())
+	if err != nil {
+		cfg.logger.Fatalf("Failed to apply config environment variables: %v", err)
+	}
+
+	if len(overridden) > 0 {
+		cfg.logger.Infof("Config overridden by environment variables: %s", strings.Join(overridden, ", "))
+
+		if err := conf.Validate(); err != nil {
+			cfg.logger.Fatalf("Failed to validate config: %v", err)
+		}
+	}
+
+	cfg.conf = conf
+	cfg.logger.Infof("Config: %#v", cfg.conf)
 
 	cfg.conf.Path = configPath
 
@@ -213,7 +258,7 @@ func (cfg *runCfg) runVisor() *runCfg {
 		}
 	}
 
-	vis, err := visor.NewVisor(&cfg.conf, cfg.masterLogger, cfg.restartCtx)
+	vis, err := visor.NewVisor(cfg.conf, cfg.masterLogger, cfg.restartCtx)
 	if err != nil {
 		cfg.logger.Fatal("Failed to initialize visor: ", err)
 	}
@@ -248,12 +293,77 @@ func (cfg *runCfg) runVisor() *runCfg {
 
 	cfg.visor = vis
 
+	go cfg.notifySystemd()
+
+	return cfg
+}
+
+// notifySystemd tells systemd that the visor is ready once its subsystems are up, then pings the systemd
+// watchdog until a subsystem fails, so that systemd restarts degraded and hung visors.
+// It does nothing unless the visor is run by systemd with notifications enabled.
+func (cfg *runCfg) notifySystemd() {
+	<-cfg.visor.Ready()
+
+	if sent, err := daemon.Notify(daemon.NotifyReady); err != nil {
+		cfg.logger.WithError(err).Warn("Failed to notify systemd of readiness")
+	} else if !sent {
+		return
+	}
+
+	interval, err := daemon.WatchdogInterval()
+	if err != nil {
+		cfg.logger.WithError(err).Warn("Failed to read systemd watchdog interval")
+		return
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+
+	for range t.C {
+		if err := cfg.visor.Degraded(); err != nil {
+			cfg.logger.WithError(err).Error("Visor is degraded: no longer pinging the systemd watchdog")
+
+			if _, err := daemon.Notify(daemon.NotifyStatus("Degraded: " + err.Error())); err != nil {
+				cfg.logger.WithError(err).Warn("Failed to notify systemd of status")
+			}
+
+			return
+		}
+
+		if _, err := daemon.Notify(daemon.NotifyWatchdog); err != nil {
+			cfg.logger.WithError(err).Warn("Failed to ping systemd watchdog")
+		}
+	}
+}
+
+func (cfg *runCfg) startService() *runCfg {
+	if !cfg.service {
+		return cfg
+	}
+
+	stop, stopped, err := daemon.Serve(serviceName())
+	if err != nil {
+		cfg.logger.Fatalf("Failed to run as service %s: %v", serviceName(), err)
+	}
+
+	cfg.serviceStop = stop
+	cfg.serviceStopped = stopped
+
 	return cfg
 }
 
 func (cfg *runCfg) stopVisor() *runCfg {
+	defer cfg.serviceStopped()
 	defer cfg.profileStop()
 
+	if _, err := daemon.Notify(daemon.NotifyStopping); err != nil {
+		cfg.logger.WithError(err).Warn("Failed to notify systemd of shutdown")
+	}
+
 	if err := cfg.visor.Close(); err != nil {
 		if !strings.Contains(err.Error(), "closed") {
 			cfg.logger.Fatal("Failed to close visor: ", err)
@@ -266,7 +376,28 @@ func (cfg *runCfg) stopVisor() *runCfg {
 func (cfg *runCfg) waitOsSignals() *runCfg {
 	ch := make(chan os.Signal, 2)
 	signal.Notify(ch, []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT}...)
-	<-ch
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	go func() {
+		for range hupCh {
+			cfg.logger.Info("Received SIGHUP: reloading config")
+
+			if err := cfg.visor.ReloadConfig(); err != nil {
+				cfg.logger.WithError(err).Error("Failed to reload config")
+			}
+		}
+	}()
+
+	// Visors drained over RPC or stopped by the service manager are shut down as on signals.
+	select {
+	case <-ch:
+	case <-cfg.visor.Drained():
+	case <-cfg.serviceStop:
+	}
+
+	signal.Stop(hupCh)
 
 	go func() {
 		select {
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/util/daemon"
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+func init() {
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceStartCmd, serviceStopCmd, serviceStatusCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+// serviceName returns the name of the skywire-visor service. launchd identifies daemons by reverse domain names.
+func serviceName() string {
+	if runtime.GOOS == "darwin" {
+		return "com.skycoin.skywire-visor"
+	}
+
+	return "skywire-visor"
+}
+
+// nolint:gochecknoglobals
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manages skywire-visor as a Windows service or a launchd daemon",
+}
+
+// nolint:gochecknoglobals
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install [config-path]",
+	Short: "Installs skywire-visor as a service started on boot, running with the given config",
+	Long: `Installs skywire-visor as a service started on boot, running with the given config.
+The config is looked up as when running skywire-visor if unspecified. Relative paths in the config
+are resolved from its directory. The service logs to the logs directory of the platform.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		cp, err := filepath.Abs(pathutil.FindConfigPath(args, 0, configEnv, pathutil.VisorDefaults()))
+		if err != nil {
+			log.Fatalf("Invalid config path: %v", err)
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Failed to obtain path of skywire-visor: %v", err)
+		}
+
+		logFile := filepath.Join(pathutil.VisorSystemPaths().Logs, "skywire-visor.log")
+
+		err = daemon.Install(daemon.Config{
+			Name:        serviceName(),
+			DisplayName: "Skywire Visor",
+			Description: "Hosts skywire apps and routes their traffic over the skywire network.",
+			Executable:  exe,
+			Args:        []string{cp, "--service", "--log-file", logFile},
+			LogFile:     logFile,
+		})
+		if err != nil {
+			log.Fatalf("Failed to install service %s: %v", serviceName(), err)
+		}
+
+		fmt.Printf("Installed service %s with config %s, logging to %s\n", serviceName(), cp, logFile)
+	},
+}
+
+// nolint:gochecknoglobals
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stops and uninstalls the skywire-visor service",
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := daemon.Uninstall(serviceName()); err != nil {
+			log.Fatalf("Failed to uninstall service %s: %v", serviceName(), err)
+		}
+
+		fmt.Println("OK")
+	},
+}
+
+// nolint:gochecknoglobals
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Starts the skywire-visor service",
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := daemon.Start(serviceName()); err != nil {
+			log.Fatalf("Failed to start service %s: %v", serviceName(), err)
+		}
+
+		fmt.Println("OK")
+	},
+}
+
+// nolint:gochecknoglobals
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stops the skywire-visor service",
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := daemon.Stop(serviceName()); err != nil {
+			log.Fatalf("Failed to stop service %s: %v", serviceName(), err)
+		}
+
+		fmt.Println("OK")
+	},
+}
+
+// nolint:gochecknoglobals
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Prints the status of the skywire-visor service",
+	Long: `Prints the status of the skywire-visor service: one of "not installed", "stopped", "starting",
+"running" or "stopping". The exit code is 0 if the service is running, 3 if it is not and 1 on errors.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		status, err := daemon.QueryStatus(serviceName())
+		if err != nil {
+			log.Fatalf("Failed to query service %s: %v", serviceName(), err)
+		}
+
+		fmt.Println(status)
+
+		if status != daemon.StatusRunning {
+			os.Exit(3)
+		}
+	},
+}
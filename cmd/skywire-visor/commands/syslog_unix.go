@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"io/ioutil"
+	"log/syslog"
+
+	logrussyslog "github.com/sirupsen/logrus/hooks/syslog"
+)
+
+func (cfg *runCfg) addSyslogHook() {
+	hook, err := logrussyslog.NewSyslogHook("udp", cfg.syslogAddr, syslog.LOG_INFO, cfg.tag)
+	if err != nil {
+		cfg.logger.Error("Unable to connect to syslog daemon:", err)
+		return
+	}
+
+	cfg.masterLogger.AddHook(hook)
+	cfg.masterLogger.Out = ioutil.Discard
+}
@@ -0,0 +1,6 @@
+package commands
+
+// addSyslogHook does nothing but logging an error, as syslog is not available on Windows.
+func (cfg *runCfg) addSyslogHook() {
+	cfg.logger.Errorf("Unable to connect to syslog daemon at %s: syslog is not supported on Windows", cfg.syslogAddr)
+}
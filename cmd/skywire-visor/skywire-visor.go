@@ -5,8 +5,10 @@ package main
 
 import (
 	"github.com/skycoin/skywire/cmd/skywire-visor/commands"
+	"github.com/skycoin/skywire/pkg/app/appserver"
 )
 
 func main() {
+	appserver.SandboxMain()
 	commands.Execute()
 }
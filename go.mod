@@ -8,11 +8,16 @@ require (
 	github.com/creack/pty v1.1.11 // indirect
 	github.com/frankban/quicktest v1.10.2 // indirect
 	github.com/go-chi/chi v4.1.2+incompatible
+	github.com/golang/snappy v0.0.1
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/handlers v1.5.0 // indirect
 	github.com/gorilla/securecookie v1.1.1
 	github.com/klauspost/compress v1.10.11 // indirect
+	github.com/klauspost/cpuid v1.3.1 // indirect
 	github.com/klauspost/pgzip v1.2.4 // indirect
+	github.com/klauspost/reedsolomon v1.9.3
+	github.com/lib/pq v1.7.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/mholt/archiver/v3 v3.3.0
 	github.com/nwaples/rardecode v1.1.0 // indirect
 	github.com/pierrec/lz4 v2.5.2+incompatible // indirect
@@ -31,12 +36,12 @@ require (
 	github.com/stretchr/testify v1.6.1
 	github.com/ulikunitz/xz v0.5.8 // indirect
 	go.etcd.io/bbolt v1.3.5
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	golang.org/x/net v0.0.0-20200822124328-c89045814202
 	golang.org/x/sys v0.0.0-20200831180312-196b9ba8737a // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
-	nhooyr.io/websocket v1.8.6 // indirect
+	nhooyr.io/websocket v1.8.6
 )
 
 // Uncomment for tests with alternate branches of 'dmsg'
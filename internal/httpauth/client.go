@@ -18,9 +18,9 @@ import (
 	"github.com/skycoin/skycoin/src/util/logging"
 )
 
-const (
-	invalidNonceErrorMessage = "SW-Nonce does not match"
-)
+// InvalidNonceErrorMessage is the error message of servers rejecting a request for its nonce.
+// Clients fetch the expected nonce and retry on receiving it.
+const InvalidNonceErrorMessage = "SW-Nonce does not match"
 
 var log = logging.MustGetLogger("httpauth")
 
@@ -190,7 +190,7 @@ func (c *Client) incrementNonce() {
 
 // isNonceValid checks if `res` contains an invalid nonce error.
 // The error is occurred if status code equals to `http.StatusUnauthorized`
-// and body contains `invalidNonceErrorMessage`.
+// and body contains `InvalidNonceErrorMessage`.
 func isNonceValid(res *http.Response) (bool, error) {
 	var serverResponse HTTPResponse
 
@@ -208,7 +208,7 @@ func isNonceValid(res *http.Response) (bool, error) {
 	}
 
 	isAuthorized := serverResponse.Error.Code != http.StatusUnauthorized
-	hasValidNonce := serverResponse.Error.Message != invalidNonceErrorMessage
+	hasValidNonce := serverResponse.Error.Message != InvalidNonceErrorMessage
 
 	return isAuthorized && hasValidNonce, nil
 }
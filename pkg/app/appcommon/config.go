@@ -5,9 +5,13 @@ const DefaultServerAddr = "localhost:5505"
 
 // Config defines configuration parameters for `Proc`.
 type Config struct {
-	Name       string `json:"name"`
-	ServerAddr string `json:"server_addr"`
-	VisorPK    string `json:"visor_pk"`
-	BinaryDir  string `json:"binary_dir"`
-	WorkDir    string `json:"work_dir"`
+	Name       string   `json:"name"`
+	ServerAddr string   `json:"server_addr"`
+	VisorPK    string   `json:"visor_pk"`
+	BinaryDir  string   `json:"binary_dir"`
+	Binary     string   `json:"binary,omitempty"` // Name of the binary in BinaryDir, if it is not Name.
+	WorkDir    string   `json:"work_dir"`
+	Env        []string `json:"env,omitempty"` // Additional environment of the app, as "KEY=value".
+	Limits     Limits   `json:"limits"`
+	Sandbox    Sandbox  `json:"sandbox"`
 }
@@ -8,3 +8,13 @@ const (
 	// EnvVisorPK is a name for env arg containing public key of visor.
 	EnvVisorPK = "VISOR_PK"
 )
+
+// IsReservedEnv returns whether the env arg of key is set by the visor, so that apps can't be configured with it.
+func IsReservedEnv(key string) bool {
+	switch key {
+	case EnvAppKey, EnvServerAddr, EnvVisorPK:
+		return true
+	default:
+		return false
+	}
+}
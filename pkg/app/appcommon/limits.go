@@ -0,0 +1,13 @@
+package appcommon
+
+// Limits are resource limits of an app process. Zero values are unlimited.
+type Limits struct {
+	CPU    float64 `json:"cpu,omitempty"`    // CPU time per second of wall time, e.g. 0.5 for half a core.
+	Memory uint64  `json:"memory,omitempty"` // Memory in bytes.
+	Files  uint64  `json:"files,omitempty"`  // Open file descriptors.
+}
+
+// IsZero returns whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
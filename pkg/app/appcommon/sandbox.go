@@ -0,0 +1,16 @@
+package appcommon
+
+// SeccompDefault is the built-in seccomp profile, which denies syscalls administering the host,
+// such as mounting filesystems, loading kernel modules and tracing other processes.
+const SeccompDefault = "default"
+
+// Sandbox restricts the privileges of an app process. Zero values are unrestricted.
+type Sandbox struct {
+	User    string `json:"user,omitempty"`    // System user (name or uid) to run the app as. Its work dir is made private to the user.
+	Seccomp string `json:"seccomp,omitempty"` // Seccomp profile, either "default" or the path of a JSON profile (Linux only).
+}
+
+// IsZero returns whether no restriction is set.
+func (s Sandbox) IsZero() bool {
+	return s == Sandbox{}
+}
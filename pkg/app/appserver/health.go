@@ -0,0 +1,44 @@
+package appserver
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthCheck is the health check registered by an app.
+// Apps either register an HTTP endpoint which is probed by the visor,
+// or report their health themselves, periodically.
+type HealthCheck struct {
+	URL      string    // HTTP endpoint of the app, if it registered one.
+	Reported time.Time // Time of the last health report of the app, if it reports its health itself.
+	Err      string    // Error of the last health report, empty if the app reported itself healthy.
+}
+
+// health holds the health check of an app.
+type health struct {
+	mx    sync.Mutex
+	check HealthCheck
+	set   bool
+}
+
+func (h *health) get() (HealthCheck, bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	return h.check, h.set
+}
+
+func (h *health) setURL(url string) {
+	h.mx.Lock()
+	h.check.URL = url
+	h.set = true
+	h.mx.Unlock()
+}
+
+func (h *health) report(errText string) {
+	h.mx.Lock()
+	h.check.Reported = time.Now()
+	h.check.Err = errText
+	h.set = true
+	h.mx.Unlock()
+}
@@ -0,0 +1,92 @@
+package appserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+var (
+	// ErrLimitsUnsupported is returned when resource limits of apps can't be enforced on the platform.
+	ErrLimitsUnsupported = errors.New("resource limits are not supported on this platform")
+	// ErrUsageUnsupported is returned when resource usage of apps can't be read on the platform.
+	ErrUsageUnsupported = errors.New("resource usage is not supported on this platform")
+)
+
+// Usage is the resource usage of a running app.
+type Usage struct {
+	CPUTime time.Duration    `json:"cpu_time"` // CPU time used since the app started.
+	Memory  uint64           `json:"memory"`   // Resident memory in bytes.
+	Files   int              `json:"files"`    // Open file descriptors.
+	Limits  appcommon.Limits `json:"limits"`
+}
+
+// clockTicks is the number of clock ticks per second, in which CPU times are reported in procfs.
+const clockTicks = 100
+
+// parseProcStat parses the CPU time of a process from its stat in the format of /proc/[pid]/stat.
+func parseProcStat(r io.Reader, u *Usage) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// The command name may contain spaces, but is in parentheses. It is followed by the state, which is
+	// the 3rd field, while utime and stime are the 14th and 15th fields.
+	i := strings.LastIndexByte(string(b), ')')
+	if i < 0 {
+		return errors.New("failed to parse process stat: no command name")
+	}
+
+	fields := strings.Fields(string(b[i+1:]))
+	if len(fields) < 13 {
+		return errors.New("failed to parse process stat: too few fields")
+	}
+
+	var ticks uint64
+
+	for _, f := range fields[11:13] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse process stat: %w", err)
+		}
+
+		ticks += v
+	}
+
+	u.CPUTime = time.Duration(ticks) * time.Second / clockTicks
+
+	return nil
+}
+
+// parseProcStatus parses the resident memory of a process from its status in the format of /proc/[pid]/status.
+func parseProcStatus(r io.Reader, u *Usage) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// The line is like "VmRSS:	   10240 kB".
+		parts := strings.Fields(s.Text())
+		if len(parts) < 2 || parts[0] != "VmRSS:" {
+			continue
+		}
+
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse VmRSS: %w", err)
+		}
+
+		if len(parts) > 2 && parts[2] == "kB" {
+			v *= 1024
+		}
+
+		u.Memory = v
+	}
+
+	return s.Err()
+}
@@ -0,0 +1,153 @@
+package appserver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+// cgroupRoot is the cgroup (v2) under which apps with CPU or memory limits get a cgroup each.
+const cgroupRoot = "/sys/fs/cgroup/skywire"
+
+// cgroupCPUPeriod is the period of CPU limits, in microseconds.
+const cgroupCPUPeriod = 100000
+
+// limitCmd prepares cmd to be run with limits, which are applied to the started process on Linux instead.
+func limitCmd(_ *exec.Cmd, _ appcommon.Limits) error {
+	return nil
+}
+
+// limitProc applies limits to the started process pid. Open files are limited by rlimit, while CPU and memory
+// are limited by a cgroup of the app. If cgroups can't be used, memory is limited by an address space rlimit.
+// The returned func removes the cgroup, once the process has exited.
+func limitProc(name string, pid int, l appcommon.Limits) (release func(), err error) {
+	release = func() {}
+
+	if l.Files > 0 {
+		if err := prlimit(pid, unix.RLIMIT_NOFILE, l.Files); err != nil {
+			return release, fmt.Errorf("failed to limit open files: %w", err)
+		}
+	}
+
+	if l.CPU <= 0 && l.Memory == 0 {
+		return release, nil
+	}
+
+	dir, err := joinCgroup(name, pid, l)
+	if err == nil {
+		return func() { _ = os.Remove(dir) }, nil // nolint: errcheck
+	}
+
+	if l.CPU > 0 {
+		return release, fmt.Errorf("failed to limit CPU: %w", err)
+	}
+
+	if err := prlimit(pid, unix.RLIMIT_AS, l.Memory); err != nil {
+		return release, fmt.Errorf("failed to limit memory: %w", err)
+	}
+
+	return release, nil
+}
+
+// prlimit sets both the soft and hard limit of resource of the process pid.
+func prlimit(pid, resource int, limit uint64) error {
+	rlim := unix.Rlimit{Cur: limit, Max: limit}
+
+	_, _, errno := unix.RawSyscall6(unix.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(&rlim)), 0, 0, 0) // nolint: gosec
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// joinCgroup moves the process pid to the cgroup of the app, with limits set.
+func joinCgroup(name string, pid int, l appcommon.Limits) (string, error) {
+	if err := os.MkdirAll(cgroupRoot, 0755); err != nil { // nolint: gosec
+		return "", err
+	}
+
+	// Controllers are enabled for the cgroups of apps by their parent.
+	if err := writeCgroupFile(cgroupRoot, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(cgroupRoot, name)
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) { // nolint: gosec
+		return "", err
+	}
+
+	cpuMax, memMax := "max", "max"
+	if l.CPU > 0 {
+		cpuMax = strconv.Itoa(int(l.CPU * cgroupCPUPeriod))
+	}
+
+	if l.Memory > 0 {
+		memMax = strconv.FormatUint(l.Memory, 10)
+	}
+
+	files := [][2]string{
+		{"cpu.max", fmt.Sprintf("%s %d", cpuMax, cgroupCPUPeriod)},
+		{"memory.max", memMax},
+		{"cgroup.procs", strconv.Itoa(pid)},
+	}
+
+	for _, f := range files {
+		if err := writeCgroupFile(dir, f[0], f[1]); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644) // nolint: gosec
+}
+
+// procUsage reads the resource usage of the process pid from procfs.
+func procUsage(pid int) (Usage, error) {
+	var u Usage
+
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	parseFile := func(name string, parse func(r io.Reader, u *Usage) error) error {
+		f, err := os.Open(filepath.Join(dir, name)) // nolint: gosec
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = f.Close() // nolint: errcheck
+		}()
+
+		return parse(f, &u)
+	}
+
+	if err := parseFile("stat", parseProcStat); err != nil {
+		return u, err
+	}
+
+	if err := parseFile("status", parseProcStatus); err != nil {
+		return u, err
+	}
+
+	fds, err := ioutil.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return u, err
+	}
+
+	u.Files = len(fds)
+
+	return u, nil
+}
@@ -0,0 +1,15 @@
+package appserver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcUsage(t *testing.T) {
+	u, err := procUsage(os.Getpid())
+	require.NoError(t, err)
+	require.NotZero(t, u.Memory)
+	require.NotZero(t, u.Files)
+}
@@ -0,0 +1,53 @@
+//go:build !linux
+// +build !linux
+
+package appserver
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+// limitCmd prepares cmd to be run with limits, by running it from a shell which sets rlimits first.
+// CPU can't be limited by rlimits, and Windows has no rlimits at all.
+func limitCmd(cmd *exec.Cmd, l appcommon.Limits) error {
+	if l.IsZero() {
+		return nil
+	}
+
+	if runtime.GOOS == "windows" || l.CPU > 0 {
+		return ErrLimitsUnsupported
+	}
+
+	var ulimits []string
+
+	if l.Files > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %d", l.Files))
+	}
+
+	if l.Memory > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", l.Memory/1024))
+	}
+
+	// The shell is replaced by the app, which keeps the pid and the rlimits of the shell.
+	script := strings.Join(ulimits, " && ") + ` && exec "$0" "$@"`
+
+	cmd.Args = append([]string{"/bin/sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+
+	return nil
+}
+
+// limitProc applies limits to the started process, which are set by limitCmd on this platform instead.
+func limitProc(_ string, _ int, _ appcommon.Limits) (release func(), err error) {
+	return func() {}, nil
+}
+
+// procUsage reads the resource usage of a process, which is only supported on Linux.
+func procUsage(_ int) (Usage, error) {
+	return Usage{}, ErrUsageUnsupported
+}
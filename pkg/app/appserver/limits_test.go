@@ -0,0 +1,29 @@
+package appserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcStat(t *testing.T) {
+	const stat = "1234 (my app) S 1 1234 1234 0 -1 4194560 1000 0 0 0 250 50 0 0 20 0 8 0 100 1000000 500 " +
+		"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0"
+
+	var u Usage
+	require.NoError(t, parseProcStat(strings.NewReader(stat), &u))
+	require.Equal(t, 3*time.Second, u.CPUTime)
+
+	require.Error(t, parseProcStat(strings.NewReader("1234 app S 1"), &u))
+	require.Error(t, parseProcStat(strings.NewReader("1234 (app) S 1 2 3"), &u))
+}
+
+func TestParseProcStatus(t *testing.T) {
+	const status = "Name:\tapp\nVmPeak:\t  20480 kB\nVmRSS:\t   10240 kB\nThreads:\t8\n"
+
+	var u Usage
+	require.NoError(t, parseProcStatus(strings.NewReader(status), &u))
+	require.Equal(t, uint64(10240*1024), u.Memory)
+}
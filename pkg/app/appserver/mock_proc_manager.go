@@ -16,6 +16,29 @@ type MockProcManager struct {
 	mock.Mock
 }
 
+// Connections provides a mock function with given fields: name
+func (_m *MockProcManager) Connections(name string) ([]ConnSummary, error) {
+	ret := _m.Called(name)
+
+	var r0 []ConnSummary
+	if rf, ok := ret.Get(0).(func(string) []ConnSummary); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]ConnSummary)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Exists provides a mock function with given fields: name
 func (_m *MockProcManager) Exists(name string) bool {
 	ret := _m.Called(name)
@@ -30,6 +53,27 @@ func (_m *MockProcManager) Exists(name string) bool {
 	return r0
 }
 
+// HealthCheck provides a mock function with given fields: name
+func (_m *MockProcManager) HealthCheck(name string) (HealthCheck, bool) {
+	ret := _m.Called(name)
+
+	var r0 HealthCheck
+	if rf, ok := ret.Get(0).(func(string) HealthCheck); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(HealthCheck)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // Range provides a mock function with given fields: next
 func (_m *MockProcManager) Range(next func(string, *Proc) bool) {
 	_m.Called(next)
@@ -75,6 +119,27 @@ func (_m *MockProcManager) StopAll() {
 	_m.Called()
 }
 
+// Usage provides a mock function with given fields: name
+func (_m *MockProcManager) Usage(name string) (Usage, error) {
+	ret := _m.Called(name)
+
+	var r0 Usage
+	if rf, ok := ret.Get(0).(func(string) Usage); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(Usage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Wait provides a mock function with given fields: name
 func (_m *MockProcManager) Wait(name string) error {
 	ret := _m.Called(name)
@@ -37,7 +37,12 @@ type Proc struct {
 func NewProc(log *logging.Logger, c appcommon.Config, args []string, stdout, stderr io.Writer) (*Proc, error) {
 	key := appcommon.GenerateAppKey()
 
-	binaryPath := getBinaryPath(c.BinaryDir, c.Name)
+	binary := c.Binary
+	if binary == "" {
+		binary = c.Name
+	}
+
+	binaryPath := getBinaryPath(c.BinaryDir, binary)
 
 	const (
 		appKeyEnvFormat     = appcommon.EnvAppKey + "=%s"
@@ -45,7 +50,8 @@ func NewProc(log *logging.Logger, c appcommon.Config, args []string, stdout, std
 		visorPKEnvFormat    = appcommon.EnvVisorPK + "=%s"
 	)
 
-	env := make([]string, 0, 4)
+	env := make([]string, 0, len(c.Env)+3)
+	env = append(env, c.Env...)
 	env = append(env, fmt.Sprintf(appKeyEnvFormat, key))
 	env = append(env, fmt.Sprintf(serverAddrEnvFormat, c.ServerAddr))
 	env = append(env, fmt.Sprintf(visorPKEnvFormat, c.VisorPK))
@@ -58,6 +64,14 @@ func NewProc(log *logging.Logger, c appcommon.Config, args []string, stdout, std
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 
+	if err := limitCmd(cmd, c.Limits); err != nil {
+		return nil, fmt.Errorf("failed to limit resources of app %s: %w", c.Name, err)
+	}
+
+	if err := sandboxCmd(cmd, c); err != nil {
+		return nil, fmt.Errorf("failed to sandbox app %s: %w", c.Name, err)
+	}
+
 	return &Proc{
 		key:    key,
 		config: c,
@@ -76,11 +90,24 @@ func (p *Proc) Start() error {
 		return err
 	}
 
+	release, err := limitProc(p.config.Name, p.cmd.Process.Pid, p.config.Limits)
+	if err != nil {
+		if kErr := p.cmd.Process.Kill(); kErr != nil {
+			p.log.WithError(kErr).Error("Failed to kill app.")
+		}
+
+		_ = p.cmd.Wait() // nolint: errcheck
+		release()
+
+		return fmt.Errorf("failed to limit resources of app %s: %w", p.config.Name, err)
+	}
+
 	// acquire lock immediately
 	p.waitMx.Lock()
 	go func() {
 		defer p.waitMx.Unlock()
 		p.waitErr = p.cmd.Wait()
+		release()
 	}()
 
 	return nil
@@ -117,6 +144,22 @@ func (p *Proc) Wait() error {
 	return p.waitErr
 }
 
+// Usage returns the resource usage of the application.
+func (p *Proc) Usage() (Usage, error) {
+	if atomic.LoadInt32(&p.isRunning) != 1 {
+		return Usage{}, errProcNotStarted
+	}
+
+	u, err := procUsage(p.cmd.Process.Pid)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	u.Limits = p.config.Limits
+
+	return u, nil
+}
+
 // IsRunning checks whether application cmd is running.
 func (p *Proc) IsRunning() bool {
 	return atomic.LoadInt32(&p.isRunning) == 1
@@ -28,6 +28,9 @@ type ProcManager interface {
 	Stop(name string) error
 	Wait(name string) error
 	Range(next func(name string, proc *Proc) bool)
+	Connections(name string) ([]ConnSummary, error)
+	HealthCheck(name string) (HealthCheck, bool)
+	Usage(name string) (Usage, error)
 	StopAll()
 }
 
@@ -70,6 +73,10 @@ func (m *procManager) Start(log *logging.Logger, c appcommon.Config, args []stri
 	m.mx.Unlock()
 
 	if err := p.Start(); err != nil {
+		if _, popErr := m.pop(c.Name); popErr != nil {
+			m.log.Debugf("Remove app <%v>: %v", c.Name, popErr)
+		}
+
 		return 0, err
 	}
 
@@ -135,12 +142,46 @@ func (m *procManager) Range(next func(name string, proc *Proc) bool) {
 	}
 }
 
+// Connections returns the live connections of the application.
+func (m *procManager) Connections(name string) ([]ConnSummary, error) {
+	p, err := m.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	conns, _ := m.rpcServer.Connections(p.key)
+
+	return conns, nil
+}
+
+// HealthCheck returns the health check registered by the application, if any.
+func (m *procManager) HealthCheck(name string) (HealthCheck, bool) {
+	p, err := m.get(name)
+	if err != nil {
+		return HealthCheck{}, false
+	}
+
+	return m.rpcServer.HealthCheck(p.key)
+}
+
+// Usage returns the resource usage of the application.
+func (m *procManager) Usage(name string) (Usage, error) {
+	p, err := m.get(name)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	return p.Usage()
+}
+
 // StopAll stops all the apps run with this manager instance.
 func (m *procManager) StopAll() {
 	m.mx.Lock()
 	defer m.mx.Unlock()
 
 	for name, proc := range m.procs {
+		m.rpcServer.forget(proc.key)
+
 		log := m.log.WithField("app_name", name)
 		if err := proc.Stop(); err != nil && strings.Contains(err.Error(), "process already finished") {
 			log.WithError(err).Error("Failed to stop app.")
@@ -164,6 +205,10 @@ func (m *procManager) pop(name string) (*Proc, error) {
 
 	delete(m.procs, name)
 
+	if p != nil {
+		m.rpcServer.forget(p.key)
+	}
+
 	return p, nil
 }
 
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	neturl "net/url"
+	"sort"
 	"time"
 
 	"github.com/skycoin/skycoin/src/util/logging"
@@ -57,9 +59,10 @@ func (e *RPCIOErr) ToError() error {
 
 // RPCGateway is a RPC interface for the app server.
 type RPCGateway struct {
-	lm  *idmanager.Manager // contains listeners associated with their IDs
-	cm  *idmanager.Manager // contains connections associated with their IDs
-	log *logging.Logger
+	lm     *idmanager.Manager // contains listeners associated with their IDs
+	cm     *idmanager.Manager // contains connections associated with their IDs
+	health health             // health check registered by the app
+	log    *logging.Logger
 }
 
 // NewRPCGateway constructs new server RPC interface.
@@ -98,6 +101,8 @@ func (r *RPCGateway) Dial(remote *appnet.Addr, resp *DialResp) (err error) {
 		return err
 	}
 
+	wrappedConn = newTrackedConn(wrappedConn)
+
 	if err := r.cm.Set(*reservedConnID, wrappedConn); err != nil {
 		if cErr := wrappedConn.Close(); cErr != nil {
 			r.log.WithError(cErr).Error("Error closing wrappedConn.")
@@ -114,6 +119,23 @@ func (r *RPCGateway) Dial(remote *appnet.Addr, resp *DialResp) (err error) {
 	return nil
 }
 
+// Connections returns summaries of the live connections, ordered by ID.
+func (r *RPCGateway) Connections() []ConnSummary {
+	var conns []ConnSummary
+
+	r.cm.DoRange(func(id uint16, v interface{}) bool {
+		if c, ok := v.(*trackedConn); ok {
+			conns = append(conns, c.summary(id))
+		}
+
+		return true
+	})
+
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ID < conns[j].ID })
+
+	return conns
+}
+
 // Listen starts listening.
 func (r *RPCGateway) Listen(local *appnet.Addr, lisID *uint16) (err error) {
 	defer rpcutil.LogCall(r.log, "Listen", local)(lisID, &err)
@@ -179,6 +201,8 @@ func (r *RPCGateway) Accept(lisID *uint16, resp *AcceptResp) (err error) {
 		return err
 	}
 
+	wrappedConn = newTrackedConn(wrappedConn)
+
 	if err := r.cm.Set(*connID, wrappedConn); err != nil {
 		if cErr := wrappedConn.Close(); cErr != nil {
 			r.log.WithError(cErr).Error("Failed to close wrappedConn.")
@@ -281,6 +305,31 @@ func (r *RPCGateway) CloseListener(lisID *uint16, _ *struct{}) (err error) {
 	return lis.Close()
 }
 
+// RegisterHealthURL registers an HTTP endpoint of the app, which is probed by the visor
+// to check the health of the app.
+func (r *RPCGateway) RegisterHealthURL(url *string, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "RegisterHealthURL", url)(nil, &err)
+
+	if _, err := neturl.ParseRequestURI(*url); err != nil {
+		return fmt.Errorf("invalid health URL: %w", err)
+	}
+
+	r.health.setURL(*url)
+
+	return nil
+}
+
+// ReportHealth reports the health of the app. An empty `errText` reports the app as healthy.
+func (r *RPCGateway) ReportHealth(errText *string, _ *struct{}) error {
+	r.health.report(*errText)
+	return nil
+}
+
+// HealthCheck returns the health check registered by the app, if any.
+func (r *RPCGateway) HealthCheck() (HealthCheck, bool) {
+	return r.health.get()
+}
+
 // DeadlineReq contains arguments for deadline methods.
 type DeadlineReq struct {
 	ConnID   uint16
@@ -1014,6 +1014,72 @@ func testRPCGatewayCloseListenerError(t *testing.T, l *logging.Logger) {
 	require.Equal(t, err, closeErr)
 }
 
+func TestRPCGateway_Connections(t *testing.T) {
+	rpc := NewRPCGateway(logging.MustGetLogger("rpc_gateway"))
+
+	writeBuff := []byte{1, 2, 3, 4}
+	readBuff := make([]byte, 10)
+
+	remotePK, _ := cipher.GenerateKeyPair()
+
+	mockConn := &appcommon.MockConn{}
+	mockConn.On("LocalAddr").Return(dmsg.Addr{Port: 100})
+	mockConn.On("RemoteAddr").Return(dmsg.Addr{PK: remotePK, Port: 200})
+	mockConn.On("Write", writeBuff).Return(len(writeBuff), nil)
+	mockConn.On("Read", readBuff).Return(7, nil)
+
+	wrappedConn, err := appnet.WrapConn(mockConn)
+	require.NoError(t, err)
+
+	connID := addConn(t, rpc, newTrackedConn(wrappedConn))
+
+	// Untracked connections are not listed.
+	addConn(t, rpc, &appcommon.MockConn{})
+
+	var writeResp WriteResp
+	require.NoError(t, rpc.Write(&WriteReq{ConnID: connID, B: writeBuff}, &writeResp))
+
+	var readResp ReadResp
+	require.NoError(t, rpc.Read(&ReadReq{ConnID: connID, BufLen: len(readBuff)}, &readResp))
+
+	conns := rpc.Connections()
+	require.Len(t, conns, 1)
+	require.Equal(t, connID, conns[0].ID)
+	require.Equal(t, remotePK, conns[0].Remote.PubKey)
+	require.Equal(t, routing.Port(200), conns[0].Remote.Port)
+	require.Equal(t, uint64(4), conns[0].Sent)
+	require.Equal(t, uint64(7), conns[0].Recv)
+}
+
+func TestRPCGateway_HealthCheck(t *testing.T) {
+	rpc := NewRPCGateway(logging.MustGetLogger("rpc_gateway"))
+
+	_, ok := rpc.HealthCheck()
+	require.False(t, ok)
+
+	badURL := "not a url"
+	require.Error(t, rpc.RegisterHealthURL(&badURL, nil))
+
+	_, ok = rpc.HealthCheck()
+	require.False(t, ok)
+
+	url := "http://localhost:8080/health"
+	require.NoError(t, rpc.RegisterHealthURL(&url, nil))
+
+	check, ok := rpc.HealthCheck()
+	require.True(t, ok)
+	require.Equal(t, url, check.URL)
+	require.True(t, check.Reported.IsZero())
+
+	errText := "database is down"
+	require.NoError(t, rpc.ReportHealth(&errText, nil))
+
+	check, ok = rpc.HealthCheck()
+	require.True(t, ok)
+	require.Equal(t, errText, check.Err)
+	require.False(t, check.Reported.IsZero())
+}
+
 func prepAddr(nType appnet.Type) appnet.Addr {
 	pk, _ := cipher.GenerateKeyPair()
 
@@ -0,0 +1,93 @@
+package appserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+// ErrSandboxUnsupported is returned when apps can't be sandboxed as configured on the platform.
+var ErrSandboxUnsupported = errors.New("sandboxing apps is not supported on this platform")
+
+// sandboxArg0 is the name the visor is re-executed with to run an app with a seccomp profile.
+// See SandboxMain.
+const sandboxArg0 = "skywire-app-sandbox"
+
+// maxSeccompDeny is the maximum number of syscalls denied by a seccomp profile.
+const maxSeccompDeny = 200
+
+// seccompProfile is a seccomp profile of apps. Syscalls are referred to by their names, such as "mount".
+type seccompProfile struct {
+	Deny []string `json:"deny"` // Syscalls which fail with EPERM.
+}
+
+// defaultSeccompProfile denies syscalls administering the host.
+var defaultSeccompProfile = seccompProfile{ // nolint: gochecknoglobals
+	Deny: []string{
+		"acct", "add_key", "adjtimex", "bpf", "chroot", "clock_adjtime", "clock_settime", "delete_module",
+		"finit_module", "init_module", "kexec_load", "keyctl", "lookup_dcookie", "mount", "name_to_handle_at",
+		"open_by_handle_at", "perf_event_open", "pivot_root", "process_vm_readv", "process_vm_writev", "ptrace",
+		"quotactl", "reboot", "request_key", "setdomainname", "sethostname", "setns", "settimeofday", "swapoff",
+		"swapon", "umount2", "unshare", "userfaultfd",
+	},
+}
+
+// loadSeccompProfile loads the seccomp profile of the given name, either "default" or the path of a JSON file.
+func loadSeccompProfile(name string) (seccompProfile, error) {
+	if name == appcommon.SeccompDefault {
+		return defaultSeccompProfile, nil
+	}
+
+	b, err := ioutil.ReadFile(name) // nolint: gosec
+	if err != nil {
+		return seccompProfile{}, fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+
+	var p seccompProfile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return seccompProfile{}, fmt.Errorf("failed to parse seccomp profile %s: %w", name, err)
+	}
+
+	if len(p.Deny) > maxSeccompDeny {
+		return seccompProfile{}, fmt.Errorf("seccomp profile %s denies more than %d syscalls", name, maxSeccompDeny)
+	}
+
+	return p, nil
+}
+
+// sandboxCmd prepares cmd to be run in the sandbox of the app. Apps with a seccomp profile are run by
+// re-executing the visor, which loads the profile before executing the app.
+func sandboxCmd(cmd *exec.Cmd, c appcommon.Config) error {
+	if c.Sandbox.Seccomp != "" {
+		p, err := loadSeccompProfile(c.Sandbox.Seccomp)
+		if err != nil {
+			return err
+		}
+
+		// The profile is checked before the app is started, as the sandbox can't report errors well.
+		if err := p.check(); err != nil {
+			return err
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		cmd.Args = append([]string{sandboxArg0, c.Sandbox.Seccomp, cmd.Path}, cmd.Args...)
+		cmd.Path = exe
+	}
+
+	if c.Sandbox.User != "" {
+		if err := setUser(cmd, c.Sandbox.User, c.WorkDir); err != nil {
+			return fmt.Errorf("failed to run app as user %s: %w", c.Sandbox.User, err)
+		}
+	}
+
+	return nil
+}
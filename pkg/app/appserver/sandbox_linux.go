@@ -0,0 +1,174 @@
+package appserver
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Return values of seccomp filters.
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// Offsets of fields of struct seccomp_data, which seccomp filters are run on.
+const (
+	seccompDataNR   = 0
+	seccompDataArch = 4
+)
+
+// x32SyscallBit is set in the numbers of syscalls of the x32 ABI on amd64, which are denied.
+const x32SyscallBit = 0x40000000
+
+// auditArches are the audit architectures of GOARCHes, checked by seccomp filters.
+var auditArches = map[string]uint32{ // nolint: gochecknoglobals
+	"386":     0x40000003,
+	"amd64":   0xc000003e,
+	"arm":     0x40000028,
+	"arm64":   0xc00000b7,
+	"ppc64le": 0xc0000015,
+	"riscv64": 0xc00000f3,
+	"s390x":   0x80000016,
+}
+
+// seccompSyscalls are the syscalls which can be denied by seccomp profiles.
+var seccompSyscalls = map[string]uint32{ // nolint: gochecknoglobals
+	"acct":              unix.SYS_ACCT,
+	"add_key":           unix.SYS_ADD_KEY,
+	"adjtimex":          unix.SYS_ADJTIMEX,
+	"bpf":               unix.SYS_BPF,
+	"chroot":            unix.SYS_CHROOT,
+	"clock_adjtime":     unix.SYS_CLOCK_ADJTIME,
+	"clock_settime":     unix.SYS_CLOCK_SETTIME,
+	"delete_module":     unix.SYS_DELETE_MODULE,
+	"finit_module":      unix.SYS_FINIT_MODULE,
+	"init_module":       unix.SYS_INIT_MODULE,
+	"kexec_load":        unix.SYS_KEXEC_LOAD,
+	"keyctl":            unix.SYS_KEYCTL,
+	"lookup_dcookie":    unix.SYS_LOOKUP_DCOOKIE,
+	"mount":             unix.SYS_MOUNT,
+	"name_to_handle_at": unix.SYS_NAME_TO_HANDLE_AT,
+	"open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT,
+	"perf_event_open":   unix.SYS_PERF_EVENT_OPEN,
+	"pivot_root":        unix.SYS_PIVOT_ROOT,
+	"process_vm_readv":  unix.SYS_PROCESS_VM_READV,
+	"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV,
+	"ptrace":            unix.SYS_PTRACE,
+	"quotactl":          unix.SYS_QUOTACTL,
+	"reboot":            unix.SYS_REBOOT,
+	"request_key":       unix.SYS_REQUEST_KEY,
+	"setdomainname":     unix.SYS_SETDOMAINNAME,
+	"sethostname":       unix.SYS_SETHOSTNAME,
+	"setns":             unix.SYS_SETNS,
+	"settimeofday":      unix.SYS_SETTIMEOFDAY,
+	"swapoff":           unix.SYS_SWAPOFF,
+	"swapon":            unix.SYS_SWAPON,
+	"umount2":           unix.SYS_UMOUNT2,
+	"unshare":           unix.SYS_UNSHARE,
+	"userfaultfd":       unix.SYS_USERFAULTFD,
+}
+
+// check checks whether the profile can be loaded.
+func (p seccompProfile) check() error {
+	_, err := p.filter()
+	return err
+}
+
+// filter compiles the profile to a seccomp BPF program. Syscalls of other architectures kill the process.
+func (p seccompProfile) filter() ([]unix.SockFilter, error) {
+	arch, ok := auditArches[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("seccomp on %s: %w", runtime.GOARCH, ErrSandboxUnsupported)
+	}
+
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNR),
+	}
+
+	nrs := make([]uint32, 0, len(p.Deny))
+	for _, name := range p.Deny {
+		nr, ok := seccompSyscalls[name]
+		if !ok {
+			return nil, fmt.Errorf("seccomp profile denies unknown syscall %q", name)
+		}
+
+		nrs = append(nrs, nr)
+	}
+
+	// Each check jumps to the errno return, which follows the allow return.
+	checks := len(nrs)
+	if runtime.GOARCH == "amd64" {
+		checks++
+		filter = append(filter, jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, uint8(checks), 0))
+	}
+
+	for i, nr := range nrs {
+		filter = append(filter, jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, uint8(len(nrs)-i), 0))
+	}
+
+	filter = append(filter,
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EPERM)),
+	)
+
+	return filter, nil
+}
+
+// SandboxMain runs an app with a seccomp profile and doesn't return, if the process was started to do so
+// by the app server. Otherwise, it returns right away. It is to be called first in the main func of the visor.
+func SandboxMain() {
+	if len(os.Args) < 4 || os.Args[0] != sandboxArg0 {
+		return
+	}
+
+	err := execSandboxed(os.Args[1], os.Args[2], os.Args[3:])
+
+	fmt.Fprintf(os.Stderr, "Failed to run app in sandbox: %v\n", err)
+	os.Exit(1)
+}
+
+// execSandboxed loads the seccomp profile and executes the app binary with args.
+func execSandboxed(profile, binary string, args []string) error {
+	p, err := loadSeccompProfile(profile)
+	if err != nil {
+		return err
+	}
+
+	filter, err := p.filter()
+	if err != nil {
+		return err
+	}
+
+	// Seccomp filters and no_new_privs are set for the calling thread, which then executes the app.
+	runtime.LockOSThread()
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	_, _, errno := unix.RawSyscall(unix.SYS_PRCTL, unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER,
+		uintptr(unsafe.Pointer(&prog))) // nolint: gosec
+	if errno != 0 {
+		return fmt.Errorf("failed to load seccomp profile: %w", errno)
+	}
+
+	return unix.Exec(binary, args, os.Environ())
+}
@@ -0,0 +1,45 @@
+package appserver
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+// TestMain lets the test binary act as the sandbox of apps, as the visor does.
+func TestMain(m *testing.M) {
+	SandboxMain()
+	os.Exit(m.Run())
+}
+
+func TestSeccompProfile_filter(t *testing.T) {
+	filter, err := defaultSeccompProfile.filter()
+	require.NoError(t, err)
+	require.True(t, len(filter) > len(defaultSeccompProfile.Deny))
+
+	_, err = seccompProfile{Deny: []string{"no_such_syscall"}}.filter()
+	require.Error(t, err)
+}
+
+func TestSandboxCmd(t *testing.T) {
+	if _, ok := auditArches[runtime.GOARCH]; !ok {
+		t.Skip("seccomp is not supported on this architecture")
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", "exit 3")
+
+	require.NoError(t, sandboxCmd(cmd, appcommon.Config{Sandbox: appcommon.Sandbox{Seccomp: appcommon.SeccompDefault}}))
+	require.Equal(t, sandboxArg0, cmd.Args[0])
+
+	err := cmd.Run()
+	require.Error(t, err)
+
+	exitErr, ok := err.(*exec.ExitError)
+	require.True(t, ok)
+	require.Equal(t, 3, exitErr.ExitCode())
+}
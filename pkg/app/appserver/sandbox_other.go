@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package appserver
+
+// check checks whether the profile can be loaded, which is only supported on Linux.
+func (p seccompProfile) check() error {
+	return ErrSandboxUnsupported
+}
+
+// SandboxMain runs apps with seccomp profiles on Linux. On other platforms, it returns right away.
+func SandboxMain() {}
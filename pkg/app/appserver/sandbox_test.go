@@ -0,0 +1,46 @@
+package appserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+)
+
+func TestLoadSeccompProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	writeProfile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+		return path
+	}
+
+	p, err := loadSeccompProfile(appcommon.SeccompDefault)
+	require.NoError(t, err)
+	require.Equal(t, defaultSeccompProfile, p)
+
+	p, err = loadSeccompProfile(writeProfile("ok.json", `{"deny": ["mount", "ptrace"]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"mount", "ptrace"}, p.Deny)
+
+	_, err = loadSeccompProfile(writeProfile("bad.json", `{"deny": "mount"}`))
+	require.Error(t, err)
+
+	tooMany := `{"deny": ["mount"` + strings.Repeat(`, "mount"`, maxSeccompDeny) + `]}`
+	_, err = loadSeccompProfile(writeProfile("too-many.json", tooMany))
+	require.Error(t, err)
+
+	_, err = loadSeccompProfile(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,53 @@
+//go:build !windows
+// +build !windows
+
+package appserver
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// setUser sets cmd to be run as the system user of the given name or uid, with its primary group only.
+// The work dir of the app is made private to the user.
+func setUser(cmd *exec.Cmd, name, workDir string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		var idErr error
+		if u, idErr = user.LookupId(name); idErr != nil {
+			return err
+		}
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	if workDir != "" {
+		if err := os.Chown(workDir, int(uid), int(gid)); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(workDir, 0700); err != nil {
+			return err
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	// Supplementary groups of the visor are dropped.
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package appserver
+
+import (
+	"os/exec"
+)
+
+// setUser sets cmd to be run as another user, which is not supported on Windows.
+func setUser(_ *exec.Cmd, _, _ string) error {
+	return ErrSandboxUnsupported
+}
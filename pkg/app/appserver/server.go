@@ -20,15 +20,19 @@ type Server struct {
 	rpcS   *rpc.Server
 	done   sync.WaitGroup
 	stopCh chan struct{}
+
+	gateways   map[appcommon.Key]*RPCGateway
+	gatewaysMx sync.RWMutex
 }
 
 // New constructs server.
 func New(log *logging.Logger, addr string) *Server {
 	return &Server{
-		log:    log,
-		addr:   addr,
-		rpcS:   rpc.NewServer(),
-		stopCh: make(chan struct{}),
+		log:      log,
+		addr:     addr,
+		rpcS:     rpc.NewServer(),
+		stopCh:   make(chan struct{}),
+		gateways: make(map[appcommon.Key]*RPCGateway),
 	}
 }
 
@@ -37,7 +41,49 @@ func (s *Server) Register(appKey appcommon.Key) error {
 	logger := logging.MustGetLogger(fmt.Sprintf("app_gateway:%s", appKey))
 	gateway := NewRPCGateway(logger)
 
-	return s.rpcS.RegisterName(string(appKey), gateway)
+	if err := s.rpcS.RegisterName(string(appKey), gateway); err != nil {
+		return err
+	}
+
+	s.gatewaysMx.Lock()
+	s.gateways[appKey] = gateway
+	s.gatewaysMx.Unlock()
+
+	return nil
+}
+
+// Connections returns the live connections of the app registered with appKey.
+func (s *Server) Connections(appKey appcommon.Key) ([]ConnSummary, bool) {
+	s.gatewaysMx.RLock()
+	gateway, ok := s.gateways[appKey]
+	s.gatewaysMx.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return gateway.Connections(), true
+}
+
+// HealthCheck returns the health check registered by the app registered with appKey.
+// It returns false if the app didn't register a health check.
+func (s *Server) HealthCheck(appKey appcommon.Key) (HealthCheck, bool) {
+	s.gatewaysMx.RLock()
+	gateway, ok := s.gateways[appKey]
+	s.gatewaysMx.RUnlock()
+
+	if !ok {
+		return HealthCheck{}, false
+	}
+
+	return gateway.HealthCheck()
+}
+
+// forget stops tracking the gateway of appKey, once its app has exited.
+func (s *Server) forget(appKey appcommon.Key) {
+	s.gatewaysMx.Lock()
+	delete(s.gateways, appKey)
+	s.gatewaysMx.Unlock()
 }
 
 // ListenAndServe starts listening for incoming app connections via tcp socket.
@@ -0,0 +1,61 @@
+package appserver
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/app/appnet"
+)
+
+// ConnSummary describes a live connection of an app.
+type ConnSummary struct {
+	ID      uint16      `json:"id"`
+	Local   appnet.Addr `json:"local"`
+	Remote  appnet.Addr `json:"remote"`
+	Started time.Time   `json:"started"`
+	Sent    uint64      `json:"sent"` // Bytes written by the app.
+	Recv    uint64      `json:"recv"` // Bytes read by the app.
+}
+
+// trackedConn counts bytes going through an app connection.
+type trackedConn struct {
+	net.Conn
+	started time.Time
+	sent    uint64
+	recv    uint64
+}
+
+func newTrackedConn(conn net.Conn) *trackedConn {
+	return &trackedConn{Conn: conn, started: time.Now()}
+}
+
+// Read implements net.Conn.
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.recv, uint64(n))
+
+	return n, err
+}
+
+// Write implements net.Conn.
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.sent, uint64(n))
+
+	return n, err
+}
+
+func (c *trackedConn) summary(id uint16) ConnSummary {
+	s := ConnSummary{
+		ID:      id,
+		Started: c.started,
+		Sent:    atomic.LoadUint64(&c.sent),
+		Recv:    atomic.LoadUint64(&c.recv),
+	}
+
+	s.Local, _ = c.LocalAddr().(appnet.Addr)
+	s.Remote, _ = c.RemoteAddr().(appnet.Addr)
+
+	return s
+}
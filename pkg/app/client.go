@@ -7,6 +7,8 @@ import (
 	"net/rpc"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/skycoin/src/util/logging"
@@ -171,6 +173,49 @@ func (c *Client) Listen(n appnet.Type, port routing.Port) (net.Listener, error)
 	return listener, nil
 }
 
+// RegisterHealthURL registers an HTTP endpoint of the app, which is probed by the visor
+// to check the health of the app. The app is healthy as long as the endpoint responds with a 2xx status.
+func (c *Client) RegisterHealthURL(url string) error {
+	return c.rpc.RegisterHealthURL(url)
+}
+
+// ReportHealth calls `check` every `interval`, and reports its result to the visor as the health
+// of the app, until the returned func is called.
+func (c *Client) ReportHealth(interval time.Duration, check func() error) (stop func()) {
+	done := make(chan struct{})
+
+	report := func() {
+		var errText string
+		if err := check(); err != nil {
+			errText = err.Error()
+		}
+
+		if err := c.rpc.ReportHealth(errText); err != nil {
+			c.log.WithError(err).Warn("Failed to report health.")
+		}
+	}
+
+	go func() {
+		report()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				report()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() { once.Do(func() { close(done) }) }
+}
+
 // Close closes client/server communication entirely. It closes all open
 // listeners and connections.
 func (c *Client) Close() {
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire/pkg/app/appcommon"
@@ -369,6 +371,38 @@ func TestClient_Close(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestClient_ReportHealth(t *testing.T) {
+	l := logging.MustGetLogger("app2_client")
+	visorPK, _ := cipher.GenerateKeyPair()
+
+	checkErr := errors.New("unhealthy")
+
+	reported := make(chan string, 10)
+
+	rpc := &MockRPCClient{}
+	rpc.On("ReportHealth", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		reported <- args.String(0)
+	})
+
+	cl := prepClient(l, visorPK, rpc)
+
+	healthy := true
+	stop := cl.ReportHealth(time.Millisecond, func() error {
+		if healthy {
+			healthy = false
+			return nil
+		}
+
+		return checkErr
+	})
+
+	require.Equal(t, "", <-reported)
+	require.Equal(t, checkErr.Error(), <-reported)
+
+	stop()
+	stop()
+}
+
 func prepClient(l *logging.Logger, visorPK cipher.PubKey, rpc RPCClient) *Client {
 	return &Client{
 		log:     l,
@@ -1,14 +1,13 @@
 package app
 
 import (
-	"io"
 	"os"
 	"time"
 
 	"github.com/skycoin/skycoin/src/util/logging"
 )
 
-// NewLogger returns a logger which persists app logs. This logger should be passed down
+// NewLogger returns a logger which persists app logs with their level and fields. This logger should be passed down
 // for use on any other function used by the app. It's configured from an additional app argument.
 // It modifies os.Args stripping from it such value. Should be called before using os.Args inside the app
 func NewLogger(appName string) *logging.MasterLogger {
@@ -18,7 +17,7 @@ func NewLogger(appName string) *logging.MasterLogger {
 	}
 
 	l := newAppLogger()
-	l.SetOutput(io.MultiWriter(l.Out, db))
+	l.AddHook(db)
 
 	os.Args = append([]string{os.Args[0]}, os.Args[2:]...)
 
@@ -38,7 +37,7 @@ func newPersistentLogger(path, appName string) (*logging.MasterLogger, LogStore,
 	}
 
 	l := newAppLogger()
-	l.SetOutput(io.MultiWriter(l.Out, db))
+	l.AddHook(db)
 
 	return l, db, nil
 }
@@ -1,12 +1,20 @@
 package app
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/util/logging"
 	"go.etcd.io/bbolt"
 )
 
@@ -15,13 +23,157 @@ type LogStore interface {
 	// Write implements io.Writer
 	Write(p []byte) (n int, err error)
 
+	// Hook stores the entries logged by a logger, with their level and fields.
+	logrus.Hook
+
 	// Store saves given log in db
 	Store(t time.Time, s string) error
 
-	// LogSince returns the logs since given timestamp. For optimal performance,
+	// LogSince returns the logs since given timestamp which pass the filter. For optimal performance,
 	// the timestamp should exist in the store (you can get it from previous logs),
 	// otherwise the DB will be sequentially iterated until finding entries older than given timestamp
-	LogsSince(t time.Time) ([]string, error)
+	LogsSince(t time.Time, f LogFilter) ([]string, error)
+
+	// LogsAfter returns the logs of sequence numbers greater than seq. Sequence numbers are assigned
+	// in increasing order as logs are stored, so clients can resume from the last one they got.
+	LogsAfter(seq uint64) ([]LogEntry, error)
+
+	// Rotate moves the logs out of the store into a compressed archive if the store exceeds the rotation limits.
+	// It reports whether the logs were rotated.
+	Rotate(r LogRotation) (bool, error)
+}
+
+// ErrInvalidLogFilter is returned by LogsSince for filters of unknown level or negative limit.
+var ErrInvalidLogFilter = errors.New("invalid log filter")
+
+// LogEntry is a log with its sequence number.
+type LogEntry struct {
+	Seq    uint64            `json:"seq"`
+	Log    string            `json:"log"`
+	Level  string            `json:"level,omitempty"`  // Empty for logs stored as plain text, whose level is unknown.
+	Fields map[string]string `json:"fields,omitempty"` // Fields of the log, such as "_module" and "error".
+}
+
+// LogFilter filters the logs returned by LogsSince.
+type LogFilter struct {
+	Level string `json:"level,omitempty"` // Minimum level of the logs, such as "warn". Logs of all levels pass if empty.
+	Limit int    `json:"limit,omitempty"` // Maximum number of logs, counted from the oldest. 0 for no limit.
+}
+
+// LogRotation sets when the logs of a store are rotated.
+// Rotated logs are kept as gzipped text in archives next to the store, named "<store>.1.gz" for the most recent.
+type LogRotation struct {
+	MaxSize     int64         // Size of the stored logs in bytes above which they are rotated, 0 for no limit.
+	MaxAge      time.Duration // Age of the oldest stored log above which the logs are rotated, 0 for no limit.
+	MaxArchives int           // Number of archives kept, older ones are removed. Rotated logs are dropped if 0.
+}
+
+// logRecord is a log as kept in the store, keyed by its time.
+// Logs written as plain text are kept as is, for compatibility with stores of older apps.
+type logRecord struct {
+	Level  string            `json:"level"`
+	Msg    string            `json:"msg"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// logFormatter formats logs of the store as text, as written by the app loggers.
+var logFormatter = &logging.TextFormatter{ // nolint: gochecknoglobals
+	FullTimestamp:      true,
+	AlwaysQuoteStrings: true,
+	QuoteEmptyFields:   true,
+	ForceFormatting:    true,
+	DisableColors:      true,
+	TimestampFormat:    time.RFC3339Nano,
+}
+
+// decodeLog returns the text, level and fields of the stored log of given key and value.
+func decodeLog(k, v []byte) (line, level string, fields map[string]string) {
+	var rec logRecord
+	if len(v) == 0 || v[0] != '{' || json.Unmarshal(v, &rec) != nil {
+		return string(v), levelFromLine(string(v)), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, string(k))
+	if err != nil {
+		t = time.Time{}
+	}
+
+	lvl, err := logrus.ParseLevel(rec.Level)
+	if err != nil {
+		lvl = logrus.InfoLevel
+	}
+
+	data := make(logrus.Fields, len(rec.Fields))
+	for k, v := range rec.Fields {
+		data[k] = v
+	}
+
+	b, err := logFormatter.Format(&logrus.Entry{Time: t, Level: lvl, Message: rec.Msg, Data: data})
+	if err != nil {
+		return rec.Msg, rec.Level, rec.Fields
+	}
+
+	return string(b), rec.Level, rec.Fields
+}
+
+// levelFromLine returns the level of a log line such as "[2006-01-02T15:04:05Z] WARN [app]: message",
+// or an empty string if it has none.
+func levelFromLine(line string) string {
+	i := strings.Index(line, "] ")
+	if i < 0 {
+		return ""
+	}
+
+	word := strings.SplitN(line[i+2:], " ", 2)[0]
+
+	lvl, err := logging.LevelFromString(word)
+	if err != nil {
+		return ""
+	}
+
+	return lvl.String()
+}
+
+// Validate returns an error if the filter has an unknown level or a negative limit.
+func (f LogFilter) Validate() error {
+	if f.Level != "" {
+		if _, err := logging.LevelFromString(f.Level); err != nil {
+			return fmt.Errorf("%w: unknown level %q", ErrInvalidLogFilter, f.Level)
+		}
+	}
+
+	if f.Limit < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidLogFilter)
+	}
+
+	return nil
+}
+
+// pass returns a function reporting whether logs of a level pass the filter.
+// Logs of unknown level always pass.
+func (f LogFilter) pass() (func(level string) bool, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	if f.Level == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	min, err := logging.LevelFromString(f.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(level string) bool {
+		lvl, err := logrus.ParseLevel(level)
+		return err != nil || lvl <= min
+	}, nil
+}
+
+// seqBucketName returns the name of the bucket indexing the logs of the app by sequence number.
+func seqBucketName(appName string) []byte {
+	return []byte(appName + ":seq")
 }
 
 // NewLogStore returns a LogStore with path and app name of the given kind
@@ -35,8 +187,9 @@ func NewLogStore(path, appName, kind string) (LogStore, error) {
 }
 
 type boltDBappLogs struct {
-	dbpath string
-	bucket []byte
+	dbpath    string
+	bucket    []byte
+	seqBucket []byte // sequence number -> key in bucket
 }
 
 func newBoltDB(path, appName string) (_ LogStore, err error) {
@@ -50,20 +203,46 @@ func newBoltDB(path, appName string) (_ LogStore, err error) {
 		err = cErr
 	}()
 
-	b := []byte(appName)
+	b, seqB := []byte(appName), seqBucketName(appName)
 	err = db.Update(func(tx *bbolt.Tx) error {
-		if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+		logs, err := tx.CreateBucketIfNotExists(b)
+		if err != nil {
 			return fmt.Errorf("failed to create bucket: %s", err)
 		}
 
-		return nil
+		if tx.Bucket(seqB) != nil {
+			return nil
+		}
+
+		seqs, err := tx.CreateBucket(seqB)
+		if err != nil {
+			return fmt.Errorf("failed to create bucket: %s", err)
+		}
+
+		// Index logs stored before sequence numbers were introduced.
+		return logs.ForEach(func(k, _ []byte) error {
+			return putSeq(seqs, k)
+		})
 	})
 
 	if err != nil && !strings.Contains(err.Error(), bbolt.ErrBucketExists.Error()) {
 		return nil, err
 	}
 
-	return &boltDBappLogs{path, b}, nil
+	return &boltDBappLogs{path, b, seqB}, nil
+}
+
+// putSeq indexes the log of key under the next sequence number.
+func putSeq(seqs *bbolt.Bucket, key []byte) error {
+	seq, err := seqs.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	var rawSeq [8]byte
+	binary.BigEndian.PutUint64(rawSeq[:], seq)
+
+	return seqs.Put(rawSeq[:], key)
 }
 
 // Write implements io.Writer
@@ -88,8 +267,11 @@ func (l *boltDBappLogs) Write(p []byte) (n int, err error) {
 	t := p[1:36]
 
 	err = db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(l.bucket)
-		return b.Put(t, p)
+		if err := tx.Bucket(l.bucket).Put(t, p); err != nil {
+			return err
+		}
+
+		return putSeq(tx.Bucket(l.seqBucket), t)
 	})
 
 	if err != nil {
@@ -114,13 +296,63 @@ func (l *boltDBappLogs) Store(t time.Time, s string) (err error) {
 	parsedTime := []byte(t.Format(time.RFC3339Nano))
 
 	return db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(l.bucket)
-		return b.Put(parsedTime, []byte(s))
+		if err := tx.Bucket(l.bucket).Put(parsedTime, []byte(s)); err != nil {
+			return err
+		}
+
+		return putSeq(tx.Bucket(l.seqBucket), parsedTime)
+	})
+}
+
+// Levels implements logrus.Hook
+func (l *boltDBappLogs) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (l *boltDBappLogs) Fire(e *logrus.Entry) (err error) {
+	rec := logRecord{Level: e.Level.String(), Msg: e.Message}
+	if len(e.Data) > 0 {
+		rec.Fields = make(map[string]string, len(e.Data))
+		for k, v := range e.Data {
+			rec.Fields[k] = fmt.Sprint(v)
+		}
+	}
+
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	db, err := bbolt.Open(l.dbpath, 0600, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	k := []byte(e.Time.Format(time.RFC3339Nano))
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(l.bucket).Put(k, v); err != nil {
+			return err
+		}
+
+		return putSeq(tx.Bucket(l.seqBucket), k)
 	})
 }
 
 // LogSince implements LogStore
-func (l *boltDBappLogs) LogsSince(t time.Time) (logs []string, err error) {
+func (l *boltDBappLogs) LogsSince(t time.Time, f LogFilter) (logs []string, err error) {
+	pass, err := f.pass()
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := bbolt.Open(l.dbpath, 0600, nil)
 	if err != nil {
 		return nil, err
@@ -138,39 +370,185 @@ func (l *boltDBappLogs) LogsSince(t time.Time) (logs []string, err error) {
 		parsedTime := []byte(t.Format(time.RFC3339Nano))
 		c := b.Cursor()
 
-		v := b.Get(parsedTime)
-		if v == nil {
-			logs = iterateFromBeginning(c, parsedTime)
-			return nil
+		// Logs after the given one are returned if it exists, otherwise all logs which are not before the timestamp.
+		k, v := c.Seek(parsedTime)
+		exists := bytes.Equal(k, parsedTime)
+
+		if exists {
+			k, v = c.Next()
+		} else {
+			k, v = c.First()
+		}
+
+		for ; k != nil && (f.Limit <= 0 || len(logs) < f.Limit); k, v = c.Next() {
+			if !exists && bytes.Compare(k, parsedTime) < 0 {
+				continue
+			}
+
+			if line, level, _ := decodeLog(k, v); pass(level) {
+				logs = append(logs, line)
+			}
+		}
+
+		return nil
+	})
+
+	return logs, err
+}
+
+// LogsAfter implements LogStore
+func (l *boltDBappLogs) LogsAfter(seq uint64) (logs []LogEntry, err error) {
+	db, err := bbolt.Open(l.dbpath, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		cErr := db.Close()
+		err = cErr
+	}()
+
+	logs = make([]LogEntry, 0)
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		b, c := tx.Bucket(l.bucket), tx.Bucket(l.seqBucket).Cursor()
+
+		var start [8]byte
+		binary.BigEndian.PutUint64(start[:], seq+1)
+
+		var prevKey []byte
+
+		for k, v := c.Seek(start[:]); k != nil; k, v = c.Next() {
+			// Logs of the same timestamp replace each other, so only the last of their sequence numbers is kept.
+			if len(logs) > 0 && bytes.Equal(v, prevKey) {
+				logs[len(logs)-1].Seq = binary.BigEndian.Uint64(k)
+				continue
+			}
+
+			if log := b.Get(v); log != nil {
+				line, level, fields := decodeLog(v, log)
+				logs = append(logs, LogEntry{Seq: binary.BigEndian.Uint64(k), Log: line, Level: level, Fields: fields})
+				prevKey = v
+			}
 		}
-		c.Seek(parsedTime)
-		logs = iterateFromKey(c)
+
 		return nil
 	})
 
 	return logs, err
 }
 
-func iterateFromKey(c *bbolt.Cursor) []string {
-	logs := make([]string, 0)
+// Rotate implements LogStore
+func (l *boltDBappLogs) Rotate(r LogRotation) (rotated bool, err error) {
+	db, err := bbolt.Open(l.dbpath, 0600, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(l.bucket)
+
+		if !needsRotation(b, r) {
+			return nil
+		}
+
+		if r.MaxArchives > 0 {
+			if err := l.archive(b, r.MaxArchives); err != nil {
+				return fmt.Errorf("failed to archive logs: %w", err)
+			}
+		}
+
+		// The sequence is kept, so that sequence numbers of later logs keep increasing.
+		seq := tx.Bucket(l.seqBucket).Sequence()
+
+		for _, name := range [][]byte{l.bucket, l.seqBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+
+		rotated = true
+
+		return tx.Bucket(l.seqBucket).SetSequence(seq)
+	})
+
+	return rotated, err
+}
+
+// needsRotation reports whether the logs of the bucket exceed the rotation limits.
+func needsRotation(b *bbolt.Bucket, r LogRotation) bool {
+	// Small buckets are stored inline, so their size is not part of their leaf pages.
+	if s := b.Stats(); r.MaxSize > 0 && int64(s.LeafInuse+s.InlineBucketInuse) > r.MaxSize {
+		return true
+	}
+
+	if r.MaxAge <= 0 {
+		return false
+	}
 
-	for k, v := c.Next(); k != nil; k, v = c.Next() {
-		logs = append(logs, string(v))
+	k, _ := b.Cursor().First()
+	if k == nil {
+		return false
 	}
 
-	return logs
+	t, err := time.Parse(time.RFC3339Nano, string(k))
+
+	return err == nil && time.Since(t) > r.MaxAge
+}
+
+// archivePath returns the path of the nth most recent archive of the store.
+func (l *boltDBappLogs) archivePath(n int) string {
+	return fmt.Sprintf("%s.%d.gz", l.dbpath, n)
 }
 
-func iterateFromBeginning(c *bbolt.Cursor, parsedTime []byte) []string {
-	logs := make([]string, 0)
+// archive writes the logs of the bucket to a new archive, shifting the older archives and removing the oldest.
+func (l *boltDBappLogs) archive(b *bbolt.Bucket, maxArchives int) error {
+	for n := maxArchives; n > 0; n-- {
+		var err error
+		if n == maxArchives {
+			err = os.Remove(l.archivePath(n))
+		} else {
+			err = os.Rename(l.archivePath(n), l.archivePath(n+1))
+		}
 
-	for k, v := c.First(); k != nil; k, v = c.Next() {
-		if bytes.Compare(k, parsedTime) < 0 {
-			continue
+		if err != nil && !os.IsNotExist(err) {
+			return err
 		}
+	}
 
-		logs = append(logs, string(v))
+	f, err := os.OpenFile(l.archivePath(1), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(f)
+	w := bufio.NewWriter(zw)
+
+	err = b.ForEach(func(k, v []byte) error {
+		line, _, _ := decodeLog(k, v)
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+
+		_, err := w.WriteString(line)
+		return err
+	})
+
+	for _, flush := range []func() error{w.Flush, zw.Close, f.Close} {
+		if fErr := flush(); err == nil {
+			err = fErr
+		}
 	}
 
-	return logs
+	return err
 }
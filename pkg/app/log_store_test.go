@@ -1,13 +1,18 @@
 package app
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
 )
 
 func TestLogStore(t *testing.T) {
@@ -38,7 +43,7 @@ func TestLogStore(t *testing.T) {
 	err = ls.Store(t2, "middle")
 	require.NoError(t, err)
 
-	res, err := ls.LogsSince(t1)
+	res, err := ls.LogsSince(t1, LogFilter{})
 	require.NoError(t, err)
 	require.Len(t, res, 2)
 	require.Contains(t, res[0], "middle")
@@ -46,7 +51,7 @@ func TestLogStore(t *testing.T) {
 
 	t4, err := time.Parse(time.RFC3339, "1999-02-01T00:00:00Z")
 	require.NoError(t, err)
-	res, err = ls.LogsSince(t4)
+	res, err = ls.LogsSince(t4, LogFilter{})
 	require.NoError(t, err)
 	require.Len(t, res, 3)
 	require.Contains(t, res[0], "bar")
@@ -54,3 +59,178 @@ func TestLogStore(t *testing.T) {
 	require.Contains(t, res[1], "middle")
 	require.Contains(t, res[2], "foo")
 }
+
+func TestLogStore_LogsAfter(t *testing.T) {
+	p, err := ioutil.TempFile("", "test-db")
+	require.NoError(t, err)
+
+	defer os.Remove(p.Name()) // nolint
+
+	ls, err := newBoltDB(p.Name(), "foo")
+	require.NoError(t, err)
+
+	t1 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, ls.Store(t1, "first"))
+	require.NoError(t, ls.Store(t1.Add(time.Second), "second"))
+
+	logs, err := ls.LogsAfter(0)
+	require.NoError(t, err)
+	require.Equal(t, []LogEntry{{Seq: 1, Log: "first"}, {Seq: 2, Log: "second"}}, logs)
+
+	// Logs of the same second are not lost nor repeated when resuming.
+	require.NoError(t, ls.Store(t1.Add(time.Second+time.Millisecond), "third"))
+
+	logs, err = ls.LogsAfter(2)
+	require.NoError(t, err)
+	require.Equal(t, []LogEntry{{Seq: 3, Log: "third"}}, logs)
+
+	// Logs of the same timestamp replace each other.
+	require.NoError(t, ls.Store(t1.Add(time.Minute), "fourth"))
+	require.NoError(t, ls.Store(t1.Add(time.Minute), "fifth"))
+
+	logs, err = ls.LogsAfter(3)
+	require.NoError(t, err)
+	require.Equal(t, []LogEntry{{Seq: 5, Log: "fifth"}}, logs)
+
+	logs, err = ls.LogsAfter(5)
+	require.NoError(t, err)
+	require.Empty(t, logs)
+}
+
+func TestLogStore_LogsAfterIndexesOldLogs(t *testing.T) {
+	p, err := ioutil.TempFile("", "test-db")
+	require.NoError(t, err)
+
+	defer os.Remove(p.Name()) // nolint
+
+	t1 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Store logs as done before sequence numbers were introduced.
+	db, err := bbolt.Open(p.Name(), 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(t1.Format(time.RFC3339Nano)), []byte("old"))
+	}))
+	require.NoError(t, db.Close())
+
+	ls, err := newBoltDB(p.Name(), "foo")
+	require.NoError(t, err)
+	require.NoError(t, ls.Store(t1.Add(time.Second), "new"))
+
+	logs, err := ls.LogsAfter(0)
+	require.NoError(t, err)
+	require.Equal(t, []LogEntry{{Seq: 1, Log: "old"}, {Seq: 2, Log: "new"}}, logs)
+}
+
+func TestLogStore_Hook(t *testing.T) {
+	p, err := ioutil.TempFile("", "test-db")
+	require.NoError(t, err)
+
+	defer os.Remove(p.Name()) // nolint
+
+	l, ls, err := newPersistentLogger(p.Name(), "foo")
+	require.NoError(t, err)
+
+	l.SetOutput(ioutil.Discard)
+
+	log := l.PackageLogger("foo")
+	log.Debug("starting")
+	log.WithField("conns", 2).Info("serving")
+	log.WithError(errors.New("timeout")).Warn("dial failed")
+	require.NoError(t, ls.Store(time.Now(), "["+time.Now().Format(time.RFC3339Nano)+"] ERROR [foo]: plain"))
+
+	logs, err := ls.LogsAfter(0)
+	require.NoError(t, err)
+	require.Len(t, logs, 4)
+	assert.Equal(t, "debug", logs[0].Level)
+	assert.Equal(t, map[string]string{"_module": "foo", "conns": "2"}, logs[1].Fields)
+	assert.Contains(t, logs[1].Log, "INFO [foo]: serving")
+	assert.Contains(t, logs[1].Log, `conns="2"`)
+	assert.Equal(t, "timeout", logs[2].Fields["error"])
+	assert.Equal(t, "error", logs[3].Level)
+
+	beginning := time.Unix(0, 0)
+
+	lines, err := ls.LogsSince(beginning, LogFilter{Level: "warn"})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "dial failed")
+	assert.Contains(t, lines[1], "plain")
+
+	lines, err = ls.LogsSince(beginning, LogFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "starting")
+
+	_, err = ls.LogsSince(beginning, LogFilter{Level: "loud"})
+	assert.Error(t, err)
+}
+
+func TestLogStore_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-log-rotate")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir) // nolint
+
+	path := filepath.Join(dir, "foo")
+
+	ls, err := newBoltDB(path, "foo")
+	require.NoError(t, err)
+
+	store := func(msg string, age time.Duration) {
+		require.NoError(t, ls.Store(time.Now().Add(-age), msg))
+	}
+
+	store("old", time.Hour)
+	store("new", 0)
+
+	// The limits are not exceeded.
+	rotated, err := ls.Rotate(LogRotation{MaxSize: 1 << 20, MaxAge: 2 * time.Hour, MaxArchives: 2})
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	for i := 0; i < 3; i++ {
+		rotated, err = ls.Rotate(LogRotation{MaxAge: time.Minute, MaxArchives: 2})
+		require.NoError(t, err)
+		assert.True(t, rotated)
+
+		store(fmt.Sprintf("rotated %d", i), time.Hour)
+	}
+
+	// Sequence numbers keep increasing after rotations.
+	logs, err := ls.LogsAfter(0)
+	require.NoError(t, err)
+	require.Equal(t, []LogEntry{{Seq: 5, Log: "rotated 2"}}, logs)
+
+	readArchive := func(n int) string {
+		f, err := os.Open(fmt.Sprintf("%s.%d.gz", path, n))
+		require.NoError(t, err)
+
+		defer f.Close() // nolint
+
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		b, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+
+		return string(b)
+	}
+
+	assert.Equal(t, "rotated 1\n", readArchive(1))
+	assert.Equal(t, "rotated 0\n", readArchive(2))
+
+	_, err = os.Stat(path + ".3.gz")
+	assert.True(t, os.IsNotExist(err))
+
+	// Without archives, rotated logs are dropped.
+	rotated, err = ls.Rotate(LogRotation{MaxSize: 1, MaxArchives: 0})
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, "rotated 1\n", readArchive(1))
+}
@@ -27,7 +27,7 @@ func TestNewLogger(t *testing.T) {
 	l.Info("bar")
 
 	beginning := time.Unix(0, 0)
-	res, err := dbl.(*boltDBappLogs).LogsSince(beginning)
+	res, err := dbl.(*boltDBappLogs).LogsSince(beginning, LogFilter{})
 	require.NoError(t, err)
 	require.Len(t, res, 1)
 	require.Contains(t, res[0], "bar")
@@ -142,6 +142,34 @@ func (_m *MockRPCClient) Read(connID uint16, b []byte) (int, error) {
 	return r0, r1
 }
 
+// RegisterHealthURL provides a mock function with given fields: url
+func (_m *MockRPCClient) RegisterHealthURL(url string) error {
+	ret := _m.Called(url)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(url)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReportHealth provides a mock function with given fields: errText
+func (_m *MockRPCClient) ReportHealth(errText string) error {
+	ret := _m.Called(errText)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(errText)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetDeadline provides a mock function with given fields: connID, d
 func (_m *MockRPCClient) SetDeadline(connID uint16, d time.Time) error {
 	ret := _m.Called(connID, d)
@@ -25,6 +25,8 @@ type RPCClient interface {
 	SetDeadline(connID uint16, d time.Time) error
 	SetReadDeadline(connID uint16, d time.Time) error
 	SetWriteDeadline(connID uint16, d time.Time) error
+	RegisterHealthURL(url string) error
+	ReportHealth(errText string) error
 }
 
 // rpcClient implements `RPCClient`.
@@ -145,6 +147,16 @@ func (c *rpcClient) SetWriteDeadline(id uint16, t time.Time) error {
 	return c.rpc.Call(c.formatMethod("SetWriteDeadline"), &req, nil)
 }
 
+// RegisterHealthURL sends `RegisterHealthURL` command to the server.
+func (c *rpcClient) RegisterHealthURL(url string) error {
+	return c.rpc.Call(c.formatMethod("RegisterHealthURL"), &url, nil)
+}
+
+// ReportHealth sends `ReportHealth` command to the server.
+func (c *rpcClient) ReportHealth(errText string) error {
+	return c.rpc.Call(c.formatMethod("ReportHealth"), &errText, nil)
+}
+
 // formatMethod formats complete RPC method signature.
 func (c *rpcClient) formatMethod(method string) string {
 	const methodFmt = "%s.%s"
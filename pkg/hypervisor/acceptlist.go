@@ -0,0 +1,334 @@
+package hypervisor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltAcceptListBucketName       = "accept_list"
+	boltEnrollmentTokensBucketName = "enrollment_tokens"
+	defaultEnrollmentTokenTTL      = 24 * time.Hour
+)
+
+// Errors associated with the accept-list.
+var (
+	ErrVisorNotEnrolled      = errors.New("visor is not enrolled and has no enrollment token")
+	ErrBadEnrollmentToken    = errors.New("enrollment token is invalid, expired or already used")
+	ErrBadEnrollmentTokenTTL = errors.New("enrollment token ttl should be a positive duration")
+)
+
+// AcceptListConfig configures which visors are accepted in ServeRPC.
+type AcceptListConfig struct {
+	Enable  bool            `json:"enable"`             // Only accepts enrolled visors.
+	PubKeys []cipher.PubKey `json:"pub_keys,omitempty"` // Visors enrolled by config.
+}
+
+// EnrolledVisor is a visor on the accept-list.
+type EnrolledVisor struct {
+	PK         cipher.PubKey `json:"pk"`
+	EnrolledAt time.Time     `json:"enrolled_at,omitempty"` // Zero for visors enrolled by config.
+}
+
+// EnrollmentToken is a single-use token, which visors present to be enrolled when first connecting.
+type EnrollmentToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AcceptListStore stores enrolled visors and enrollment tokens.
+// Only hashes of enrollment tokens are stored.
+type AcceptListStore interface {
+	EnrolledVisors() ([]EnrolledVisor, error)
+	Enrolled(pk cipher.PubKey) (bool, error)
+	Enroll(pk cipher.PubKey, at time.Time) error
+	Unenroll(pk cipher.PubKey) error
+	AddEnrollmentToken(token string, expiresAt time.Time) error
+	// UseEnrollmentToken removes the token, failing with ErrBadEnrollmentToken if it does not exist or expired.
+	UseEnrollmentToken(token string, now time.Time) error
+}
+
+func enrollmentTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newEnrollmentToken(ttl time.Duration) (EnrollmentToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return EnrollmentToken{}, err
+	}
+
+	return EnrollmentToken{Token: hex.EncodeToString(b), ExpiresAt: time.Now().UTC().Add(ttl)}, nil
+}
+
+// BoltAcceptListStore implements AcceptListStore, storing the accept-list in a bbolt database.
+type BoltAcceptListStore struct {
+	*bbolt.DB
+}
+
+// NewBoltAcceptListStore creates a new BoltAcceptListStore on top of an opened bbolt database.
+func NewBoltAcceptListStore(db *bbolt.DB) (*BoltAcceptListStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range []string{boltAcceptListBucketName, boltEnrollmentTokensBucketName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return &BoltAcceptListStore{DB: db}, err
+}
+
+// EnrolledVisors returns the visors enrolled in the store.
+func (s *BoltAcceptListStore) EnrolledVisors() ([]EnrolledVisor, error) {
+	enrolled := make([]EnrolledVisor, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltAcceptListBucketName)).ForEach(func(k, v []byte) error {
+			var e EnrolledVisor
+
+			copy(e.PK[:], k)
+			e.EnrolledAt = time.Unix(0, int64(binary.BigEndian.Uint64(v))).UTC()
+			enrolled = append(enrolled, e)
+
+			return nil
+		})
+	})
+
+	return enrolled, err
+}
+
+// Enrolled returns whether the visor of pk is enrolled in the store.
+func (s *BoltAcceptListStore) Enrolled(pk cipher.PubKey) (enrolled bool, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		enrolled = tx.Bucket([]byte(boltAcceptListBucketName)).Get(pk[:]) != nil
+		return nil
+	})
+
+	return enrolled, err
+}
+
+// Enroll adds the visor of pk to the accept-list.
+func (s *BoltAcceptListStore) Enroll(pk cipher.PubKey, at time.Time) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], uint64(at.UnixNano()))
+
+		return tx.Bucket([]byte(boltAcceptListBucketName)).Put(pk[:], v[:])
+	})
+}
+
+// Unenroll removes the visor of pk from the accept-list.
+func (s *BoltAcceptListStore) Unenroll(pk cipher.PubKey) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltAcceptListBucketName)).Delete(pk[:])
+	})
+}
+
+// AddEnrollmentToken stores the hash of the token.
+func (s *BoltAcceptListStore) AddEnrollmentToken(token string, expiresAt time.Time) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], uint64(expiresAt.UnixNano()))
+
+		return tx.Bucket([]byte(boltEnrollmentTokensBucketName)).Put([]byte(enrollmentTokenHash(token)), v[:])
+	})
+}
+
+// UseEnrollmentToken removes the token, failing if it does not exist or expired.
+// Expired tokens are removed as well.
+func (s *BoltAcceptListStore) UseEnrollmentToken(token string, now time.Time) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltEnrollmentTokensBucketName))
+
+		err := b.ForEach(func(k, v []byte) error {
+			if int64(binary.BigEndian.Uint64(v)) < now.UnixNano() {
+				return b.Delete(k)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		k := []byte(enrollmentTokenHash(token))
+		if b.Get(k) == nil {
+			return ErrBadEnrollmentToken
+		}
+
+		return b.Delete(k)
+	})
+}
+
+// checkEnrollment returns nil if the visor of pk is enrolled by config or in the store.
+// Otherwise, the visor is enrolled if it presents a valid enrollment token over RPC.
+func (hv *Hypervisor) checkEnrollment(pk cipher.PubKey, rpcC visor.RPCClient) error {
+	for _, enrolledPK := range hv.c.AcceptList.PubKeys {
+		if enrolledPK == pk {
+			return nil
+		}
+	}
+
+	enrolled, err := hv.accept.Enrolled(pk)
+	if err != nil || enrolled {
+		return err
+	}
+
+	type tokenRes struct {
+		token string
+		err   error
+	}
+
+	resCh := make(chan tokenRes, 1)
+
+	go func() {
+		token, err := rpcC.EnrollmentToken()
+		resCh <- tokenRes{token, err}
+	}()
+
+	var token string
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			return res.err
+		}
+
+		token = res.token
+	case <-time.After(healthTimeout):
+		return ErrVisorNotEnrolled
+	}
+
+	if token == "" {
+		return ErrVisorNotEnrolled
+	}
+
+	now := time.Now().UTC()
+	if err := hv.accept.UseEnrollmentToken(token, now); err != nil {
+		return err
+	}
+
+	return hv.accept.Enroll(pk, now)
+}
+
+type acceptListResp struct {
+	Enabled bool            `json:"enabled"`
+	Visors  []EnrolledVisor `json:"visors"`
+}
+
+// getAcceptList lists visors enrolled by config and in the store, sorted by public key.
+func (hv *Hypervisor) getAcceptList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enrolled, err := hv.accept.EnrolledVisors()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, pk := range hv.c.AcceptList.PubKeys {
+			enrolled = append(enrolled, EnrolledVisor{PK: pk})
+		}
+
+		sort.Slice(enrolled, func(i, j int) bool {
+			return enrolled[i].PK.Hex() < enrolled[j].PK.Hex()
+		})
+
+		httputil.WriteJSON(w, r, http.StatusOK, acceptListResp{
+			Enabled: hv.c.AcceptList.Enable,
+			Visors:  enrolled,
+		})
+	}
+}
+
+func (hv *Hypervisor) putAcceptList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pk cipher.PubKey
+		if err := pk.Set(chi.URLParam(r, "pk")); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		e := EnrolledVisor{PK: pk, EnrolledAt: time.Now().UTC()}
+		if err := hv.accept.Enroll(e.PK, e.EnrolledAt); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, e)
+	}
+}
+
+// deleteAcceptList unenrolls a visor. Connected visors stay connected until they reconnect.
+func (hv *Hypervisor) deleteAcceptList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pk cipher.PubKey
+		if err := pk.Set(chi.URLParam(r, "pk")); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := hv.accept.Unenroll(pk); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// postEnrollmentToken creates an enrollment token. The token itself is only returned here.
+func (hv *Hypervisor) postEnrollmentToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody struct {
+			TTL visor.Duration `json:"ttl"` // Defaults to a day.
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+			log.Warnf("postEnrollmentToken request: %v", err)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		ttl := time.Duration(reqBody.TTL)
+		if ttl == 0 {
+			ttl = defaultEnrollmentTokenTTL
+		}
+
+		if ttl < 0 {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrBadEnrollmentTokenTTL)
+			return
+		}
+
+		token, err := newEnrollmentToken(ttl)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := hv.accept.AddEnrollmentToken(token.Token, token.ExpiresAt); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, token)
+	}
+}
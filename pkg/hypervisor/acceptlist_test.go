@@ -0,0 +1,124 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// tokenRPCClient presents an enrollment token.
+type tokenRPCClient struct {
+	visor.RPCClient
+	token string
+}
+
+func (c *tokenRPCClient) EnrollmentToken() (string, error) {
+	return c.token, nil
+}
+
+func TestBoltAcceptListStore_UseEnrollmentToken(t *testing.T) {
+	_, _, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	now := time.Now()
+
+	require.NoError(t, hv.accept.AddEnrollmentToken("valid", now.Add(time.Hour)))
+	require.NoError(t, hv.accept.AddEnrollmentToken("expired", now.Add(-time.Second)))
+
+	assert.Equal(t, ErrBadEnrollmentToken, hv.accept.UseEnrollmentToken("expired", now))
+	assert.Equal(t, ErrBadEnrollmentToken, hv.accept.UseEnrollmentToken("unknown", now))
+	assert.NoError(t, hv.accept.UseEnrollmentToken("valid", now))
+	assert.Equal(t, ErrBadEnrollmentToken, hv.accept.UseEnrollmentToken("valid", now))
+}
+
+func TestCheckEnrollment(t *testing.T) {
+	_, _, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	configPK, _ := cipher.GenerateKeyPair()
+	tokenPK, _ := cipher.GenerateKeyPair()
+	unknownPK, _ := cipher.GenerateKeyPair()
+
+	hv.c.AcceptList = AcceptListConfig{Enable: true, PubKeys: []cipher.PubKey{configPK}}
+
+	var rpcC visor.RPCClient
+	for _, c := range hv.visors {
+		rpcC = c.RPC
+	}
+
+	token, err := newEnrollmentToken(time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, hv.accept.AddEnrollmentToken(token.Token, token.ExpiresAt))
+
+	assert.NoError(t, hv.checkEnrollment(configPK, rpcC))
+	assert.Equal(t, ErrVisorNotEnrolled, hv.checkEnrollment(unknownPK, rpcC))
+	assert.Equal(t, ErrBadEnrollmentToken, hv.checkEnrollment(unknownPK, &tokenRPCClient{RPCClient: rpcC, token: "bad"}))
+
+	// The token enrolls the visor, which is then accepted without one.
+	assert.NoError(t, hv.checkEnrollment(tokenPK, &tokenRPCClient{RPCClient: rpcC, token: token.Token}))
+	assert.NoError(t, hv.checkEnrollment(tokenPK, rpcC))
+	assert.Equal(t, ErrBadEnrollmentToken, hv.checkEnrollment(unknownPK, &tokenRPCClient{RPCClient: rpcC, token: token.Token}))
+}
+
+func TestAcceptListEndpoints(t *testing.T) {
+	addr, client, _, stop := makeStartMockNode(t)
+	defer stop()
+
+	pk, _ := cipher.GenerateKeyPair()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/accept-list/" + pk.Hex(),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/accept-list/bad",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/accept-list",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp acceptListResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.False(t, resp.Enabled)
+				require.Len(t, resp.Visors, 1)
+				assert.Equal(t, pk, resp.Visors[0].PK)
+			},
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/accept-list/" + pk.Hex(),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/accept-list/tokens",
+			ReqBody:    strings.NewReader(`{"ttl":"1h"}`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var token EnrollmentToken
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&token))
+				assert.Len(t, token.Token, 64)
+				assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, time.Minute)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/accept-list/tokens",
+			ReqBody:    strings.NewReader(`{"ttl":"-1h"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,578 @@
+package hypervisor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// ACME challenge types.
+const (
+	ACMEChallengeHTTP01    = "http-01"
+	ACMEChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+const (
+	defaultACMEHTTPAddr = ":80"
+	defaultACMECacheDir = "./acme-cache"
+
+	acmeAccountKeyFile = "acme_account+key"
+	acmeChallengePath  = "/.well-known/acme-challenge/"
+	acmeObtainTimeout  = 5 * time.Minute
+	acmeRenewBefore    = 30 * 24 * time.Hour
+)
+
+// Errors associated with ACME.
+var (
+	ErrNoACMEDomains        = errors.New("acme: no domains configured")
+	ErrUnknownACMEChallenge = errors.New("acme: unknown challenge type")
+	ErrACMEHostNotAllowed   = errors.New("acme: host not configured")
+)
+
+// ACMEConfig configures automatic obtaining and renewal of TLS certificates via ACME (such as Let's Encrypt).
+type ACMEConfig struct {
+	Enable       bool     `json:"enable"`
+	Domains      []string `json:"domains"`                 // Domains to obtain certificates for.
+	Email        string   `json:"email,omitempty"`         // Contact email of the ACME account. (optional)
+	CacheDir     string   `json:"cache_dir"`               // Directory to cache account keys and certificates in.
+	Challenge    string   `json:"challenge"`               // Either "http-01" or "tls-alpn-01".
+	HTTPAddr     string   `json:"http_addr,omitempty"`     // Address to serve http-01 challenges on.
+	DirectoryURL string   `json:"directory_url,omitempty"` // ACME directory, defaults to Let's Encrypt.
+}
+
+// FillDefaults fills config with default values.
+func (c *ACMEConfig) FillDefaults() {
+	if c.CacheDir == "" {
+		c.CacheDir = defaultACMECacheDir
+	}
+
+	if c.Challenge == "" {
+		c.Challenge = ACMEChallengeHTTP01
+	}
+
+	if c.HTTPAddr == "" {
+		c.HTTPAddr = defaultACMEHTTPAddr
+	}
+
+	if c.DirectoryURL == "" {
+		c.DirectoryURL = acme.LetsEncryptURL
+	}
+}
+
+// Manager returns a certificate manager based on the config.
+func (c *ACMEConfig) Manager() (*ACMEManager, error) {
+	if len(c.Domains) == 0 {
+		return nil, ErrNoACMEDomains
+	}
+
+	if c.Challenge != ACMEChallengeHTTP01 && c.Challenge != ACMEChallengeTLSALPN01 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownACMEChallenge, c.Challenge)
+	}
+
+	m := &ACMEManager{
+		c:        *c,
+		client:   &acme.Client{DirectoryURL: c.DirectoryURL},
+		domains:  make(map[string]struct{}, len(c.Domains)),
+		certs:    make(map[string]*tls.Certificate),
+		renewing: make(map[string]struct{}),
+		tokens:   make(map[string]string),
+		alpn:     make(map[string]*tls.Certificate),
+	}
+
+	for _, d := range c.Domains {
+		m.domains[normalizeACMEDomain(d)] = struct{}{}
+	}
+
+	return m, nil
+}
+
+// ACMEManager obtains TLS certificates of the configured domains via ACME on first use,
+// caches them on disk and renews them in the background before they expire.
+type ACMEManager struct {
+	c       ACMEConfig
+	client  *acme.Client
+	domains map[string]struct{}
+
+	obtainMx sync.Mutex // Serializes account registration and certificate orders.
+	reg      bool
+
+	mx       sync.Mutex
+	certs    map[string]*tls.Certificate // Certificates by domain.
+	renewing map[string]struct{}         // Domains with an ongoing background renewal.
+	tokens   map[string]string           // http-01 key authorizations by challenge path.
+	alpn     map[string]*tls.Certificate // tls-alpn-01 challenge certificates by domain.
+}
+
+// TLSConfig returns a TLS config which obtains certificates from m.
+// When tls-alpn-01 is used, the ACME ALPN protocol is advertised as well.
+func (m *ACMEManager) TLSConfig() *tls.Config {
+	conf := &tls.Config{
+		GetCertificate: m.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if m.c.Challenge == ACMEChallengeTLSALPN01 {
+		conf.NextProtos = append(conf.NextProtos, acme.ALPNProto)
+	}
+
+	return conf
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (m *ACMEManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := normalizeACMEDomain(hello.ServerName)
+	if name == "" {
+		return nil, errors.New("acme: missing server name")
+	}
+
+	if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+		m.mx.Lock()
+		cert, ok := m.alpn[name]
+		m.mx.Unlock()
+
+		if !ok {
+			return nil, fmt.Errorf("acme: no tls-alpn-01 challenge for %q", name)
+		}
+
+		return cert, nil
+	}
+
+	if _, ok := m.domains[name]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrACMEHostNotAllowed, name)
+	}
+
+	return m.cert(name)
+}
+
+// HTTPHandler serves http-01 challenges and redirects every other request to HTTPS.
+func (m *ACMEManager) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+			host := r.Host
+			if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+				host = host[:i]
+			}
+
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+			return
+		}
+
+		m.mx.Lock()
+		resp, ok := m.tokens[r.URL.Path]
+		m.mx.Unlock()
+
+		if !ok {
+			http.Error(w, "no such challenge", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err := w.Write([]byte(resp)); err != nil {
+			log.WithError(err).Warn("Failed to write ACME challenge response.")
+		}
+	})
+}
+
+// cert returns the certificate of name, obtaining one if there is no usable certificate.
+// A certificate close to its expiry is still returned, while a renewal is started in the background.
+func (m *ACMEManager) cert(name string) (*tls.Certificate, error) {
+	m.mx.Lock()
+	cert, ok := m.certs[name]
+	m.mx.Unlock()
+
+	if !ok {
+		var err error
+		if cert, err = m.loadCert(name); err != nil {
+			if !os.IsNotExist(err) {
+				log.WithError(err).WithField("domain", name).Warn("Failed to load cached certificate.")
+			}
+		} else {
+			m.mx.Lock()
+			m.certs[name] = cert
+			m.mx.Unlock()
+		}
+	}
+
+	now := time.Now()
+
+	if cert == nil || !now.Before(cert.Leaf.NotAfter) {
+		return m.obtainAndStore(name)
+	}
+
+	if now.Add(acmeRenewBefore).After(cert.Leaf.NotAfter) {
+		m.renewInBackground(name)
+	}
+
+	return cert, nil
+}
+
+func (m *ACMEManager) renewInBackground(name string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if _, ok := m.renewing[name]; ok {
+		return
+	}
+
+	m.renewing[name] = struct{}{}
+
+	go func() {
+		defer func() {
+			m.mx.Lock()
+			delete(m.renewing, name)
+			m.mx.Unlock()
+		}()
+
+		if _, err := m.obtainAndStore(name); err != nil {
+			log.WithError(err).WithField("domain", name).Error("Failed to renew certificate.")
+		}
+	}()
+}
+
+func (m *ACMEManager) obtainAndStore(name string) (*tls.Certificate, error) {
+	m.obtainMx.Lock()
+	defer m.obtainMx.Unlock()
+
+	// Another caller might have obtained the certificate in the meantime.
+	m.mx.Lock()
+	cert, ok := m.certs[name]
+	m.mx.Unlock()
+
+	if ok && time.Now().Add(acmeRenewBefore).Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), acmeObtainTimeout)
+	defer cancel()
+
+	log := log.WithField("domain", name)
+	log.Info("Obtaining certificate via ACME...")
+
+	cert, err := m.obtain(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.saveCert(name, cert); err != nil {
+		log.WithError(err).Warn("Failed to cache certificate.")
+	}
+
+	m.mx.Lock()
+	m.certs[name] = cert
+	m.mx.Unlock()
+
+	log.WithField("not_after", cert.Leaf.NotAfter).Info("Obtained certificate via ACME.")
+
+	return cert, nil
+}
+
+func (m *ACMEManager) obtain(ctx context.Context, name string) (*tls.Certificate, error) {
+	if err := m.register(ctx); err != nil {
+		return nil, err
+	}
+
+	order, err := m.client.AuthorizeOrder(ctx, acme.DomainIDs(name))
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to create order: %w", err)
+	}
+
+	for _, u := range order.AuthzURLs {
+		if err := m.authorize(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	if order, err = m.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("acme: order failed: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: name},
+		DNSNames: []string{name},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := m.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to finalize order: %w", err)
+	}
+
+	return newACMECert(der, key)
+}
+
+func (m *ACMEManager) register(ctx context.Context) error {
+	if m.reg {
+		return nil
+	}
+
+	key, err := m.accountKey()
+	if err != nil {
+		return err
+	}
+
+	m.client.Key = key
+
+	acct := &acme.Account{}
+	if m.c.Email != "" {
+		acct.Contact = []string{"mailto:" + m.c.Email}
+	}
+
+	if _, err := m.client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("acme: failed to register account: %w", err)
+	}
+
+	m.reg = true
+
+	return nil
+}
+
+// authorize fulfills the configured challenge of a pending authorization.
+func (m *ACMEManager) authorize(ctx context.Context, authzURL string) error {
+	authz, err := m.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("acme: failed to get authorization: %w", err)
+	}
+
+	if authz.Status != acme.StatusPending {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == m.c.Challenge {
+			chal = c
+			break
+		}
+	}
+
+	if chal == nil {
+		return fmt.Errorf("acme: %s challenge is not offered for %q", m.c.Challenge, authz.Identifier.Value)
+	}
+
+	cleanup, err := m.fulfill(chal, authz.Identifier.Value)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err := m.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("acme: failed to accept challenge: %w", err)
+	}
+
+	if _, err := m.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("acme: authorization failed: %w", err)
+	}
+
+	return nil
+}
+
+// fulfill makes the response of chal available, and returns a function which removes it again.
+func (m *ACMEManager) fulfill(chal *acme.Challenge, domain string) (func(), error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	switch chal.Type {
+	case ACMEChallengeHTTP01:
+		resp, err := m.client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		p := m.client.HTTP01ChallengePath(chal.Token)
+		m.tokens[p] = resp
+
+		return func() {
+			m.mx.Lock()
+			delete(m.tokens, p)
+			m.mx.Unlock()
+		}, nil
+
+	case ACMEChallengeTLSALPN01:
+		cert, err := m.client.TLSALPN01ChallengeCert(chal.Token, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		m.alpn[domain] = &cert
+
+		return func() {
+			m.mx.Lock()
+			delete(m.alpn, domain)
+			m.mx.Unlock()
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownACMEChallenge, chal.Type)
+	}
+}
+
+// accountKey loads the ACME account key from the cache dir, generating it if it does not exist.
+func (m *ACMEManager) accountKey() (crypto.Signer, error) {
+	path := filepath.Join(m.c.CacheDir, acmeAccountKeyFile)
+
+	data, err := ioutil.ReadFile(path) // nolint: gosec
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("acme: invalid account key in %s", path)
+		}
+
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(m.c.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// loadCert reads a certificate of name cached by saveCert.
+func (m *ACMEManager) loadCert(name string) (*tls.Certificate, error) {
+	data, err := ioutil.ReadFile(filepath.Join(m.c.CacheDir, name)) // nolint: gosec
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		key *ecdsa.PrivateKey
+		der [][]byte
+	)
+
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "EC PRIVATE KEY":
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, err
+			}
+		case "CERTIFICATE":
+			der = append(der, block.Bytes)
+		}
+	}
+
+	if key == nil || len(der) == 0 {
+		return nil, fmt.Errorf("acme: incomplete cached certificate of %q", name)
+	}
+
+	return newACMECert(der, key)
+}
+
+// saveCert caches the key and certificate chain of name as PEM in the cache dir.
+func (m *ACMEManager) saveCert(name string, cert *tls.Certificate) error {
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("acme: unsupported private key type")
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for _, der := range cert.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	if err := os.MkdirAll(m.c.CacheDir, 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(m.c.CacheDir, name), data, 0600)
+}
+
+func newACMECert(der [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, errors.New("acme: empty certificate chain")
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{Certificate: der, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func normalizeACMEDomain(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// ListenAndServeACME serves h over TLS on addr, using certificates that are obtained and renewed via ACME.
+// When the http-01 challenge is used, challenges are additionally served on the configured HTTP address.
+func ListenAndServeACME(addr string, c ACMEConfig, h http.Handler) error {
+	c.FillDefaults()
+
+	m, err := c.Manager()
+	if err != nil {
+		return err
+	}
+
+	if c.Challenge == ACMEChallengeHTTP01 {
+		go func() {
+			log := log.WithField("addr", c.HTTPAddr)
+			log.Info("Serving ACME http-01 challenges...")
+
+			if err := http.ListenAndServe(c.HTTPAddr, m.HTTPHandler()); err != nil {
+				log.WithError(err).Error("Stopped serving ACME http-01 challenges.")
+			}
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   h,
+		TLSConfig: m.TLSConfig(),
+	}
+
+	return srv.ListenAndServeTLS("", "")
+}
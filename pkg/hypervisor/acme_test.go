@@ -0,0 +1,107 @@
+package hypervisor
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEConfig_Manager(t *testing.T) {
+	c := ACMEConfig{}
+	c.FillDefaults()
+
+	_, err := c.Manager()
+	assert.True(t, errors.Is(err, ErrNoACMEDomains))
+
+	c.Domains = []string{"example.com"}
+	c.Challenge = "dns-01"
+
+	_, err = c.Manager()
+	assert.True(t, errors.Is(err, ErrUnknownACMEChallenge))
+}
+
+func TestACMEManager_GetCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, WriteSelfSignedCert(certFile, keyFile, []string{"example.com"}))
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	require.NoError(t, err)
+	certPEM, err := ioutil.ReadFile(certFile)
+	require.NoError(t, err)
+
+	cacheDir := filepath.Join(dir, "cache")
+	require.NoError(t, os.MkdirAll(cacheDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "example.com"), append(keyPEM, certPEM...), 0600))
+
+	c := ACMEConfig{Domains: []string{"Example.com"}, CacheDir: cacheDir}
+	c.FillDefaults()
+
+	m, err := c.Manager()
+	require.NoError(t, err)
+
+	t.Run("cached", func(t *testing.T) {
+		cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "EXAMPLE.com."})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example.com"}, cert.Leaf.DNSNames)
+	})
+
+	t.Run("not_allowed", func(t *testing.T) {
+		_, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"})
+		assert.True(t, errors.Is(err, ErrACMEHostNotAllowed))
+	})
+
+	t.Run("save_and_load", func(t *testing.T) {
+		cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		require.NoError(t, err)
+		require.NoError(t, m.saveCert("copy.example.com", cert))
+
+		loaded, err := m.loadCert("copy.example.com")
+		require.NoError(t, err)
+		assert.Equal(t, cert.Certificate, loaded.Certificate)
+		assert.Equal(t, cert.PrivateKey, loaded.PrivateKey)
+	})
+}
+
+func TestACMEManager_HTTPHandler(t *testing.T) {
+	c := ACMEConfig{Domains: []string{"example.com"}}
+	c.FillDefaults()
+
+	m, err := c.Manager()
+	require.NoError(t, err)
+
+	m.tokens[acmeChallengePath+"token"] = "token.thumbprint"
+	h := m.HTTPHandler()
+
+	t.Run("challenge", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com"+acmeChallengePath+"token", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "token.thumbprint", rr.Body.String())
+	})
+
+	t.Run("unknown_challenge", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com"+acmeChallengePath+"other", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com:80/api/visors?x=1", nil))
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://example.com/api/visors?x=1", rr.Header().Get("Location"))
+	})
+}
@@ -0,0 +1,134 @@
+package hypervisor
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// Types of active visor sessions.
+const (
+	ActivityPty  = "pty"
+	ActivityExec = "exec"
+)
+
+// ErrActiveSessions occurs when restarting or updating a visor which has active pty or exec sessions without force.
+var ErrActiveSessions = errors.New("visor has active pty or exec sessions, set 'force=true' to proceed anyway")
+
+// ActiveSession is a pty or exec session in progress on a visor.
+type ActiveSession struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Started    time.Time `json:"started"`
+}
+
+// Holder describes who holds the session.
+func (s ActiveSession) Holder() string {
+	if s.User != "" {
+		return s.User + "@" + s.RemoteAddr
+	}
+
+	return s.RemoteAddr
+}
+
+// activeSessionsResp is returned when an action is refused because of active sessions.
+type activeSessionsResp struct {
+	Error    string          `json:"error"`
+	Sessions []ActiveSession `json:"sessions"`
+}
+
+// activity tracks active pty and exec sessions per visor.
+type activity struct {
+	sessions map[cipher.PubKey]map[uuid.UUID]ActiveSession
+	mu       sync.Mutex
+}
+
+func newActivity() *activity {
+	return &activity{sessions: make(map[cipher.PubKey]map[uuid.UUID]ActiveSession)}
+}
+
+// begin records a session of the request on the visor of pk. The returned func ends it.
+func (a *activity) begin(pk cipher.PubKey, typ string, r *http.Request) func() {
+	s := ActiveSession{
+		ID:         uuid.New(),
+		Type:       typ,
+		RemoteAddr: remoteIP(r),
+		Started:    time.Now().UTC(),
+	}
+
+	if user, ok := r.Context().Value(userKey).(User); ok {
+		s.User = user.Name
+	}
+
+	a.mu.Lock()
+	if a.sessions[pk] == nil {
+		a.sessions[pk] = make(map[uuid.UUID]ActiveSession)
+	}
+	a.sessions[pk][s.ID] = s
+	a.mu.Unlock()
+
+	return func() {
+		a.mu.Lock()
+		delete(a.sessions[pk], s.ID)
+		if len(a.sessions[pk]) == 0 {
+			delete(a.sessions, pk)
+		}
+		a.mu.Unlock()
+	}
+}
+
+// active returns the active sessions of the visor of pk, oldest first.
+func (a *activity) active(pk cipher.PubKey) []ActiveSession {
+	a.mu.Lock()
+	sessions := make([]ActiveSession, 0, len(a.sessions[pk]))
+	for _, s := range a.sessions[pk] {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Started.Before(sessions[j].Started)
+	})
+
+	return sessions
+}
+
+// checkActivity writes a 409 response listing the active sessions of the visor of pk and returns false,
+// unless there are none or the 'force' query value is set.
+func (hv *Hypervisor) checkActivity(w http.ResponseWriter, r *http.Request, pk cipher.PubKey) bool {
+	force, err := httputil.BoolFromQuery(r, "force", false)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return false
+	}
+
+	if force {
+		return true
+	}
+
+	sessions := hv.activity.active(pk)
+	if len(sessions) == 0 {
+		return true
+	}
+
+	httputil.WriteJSON(w, r, http.StatusConflict, activeSessionsResp{
+		Error:    ErrActiveSessions.Error(),
+		Sessions: sessions,
+	})
+
+	return false
+}
+
+func (hv *Hypervisor) getActiveSessions() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		httputil.WriteJSON(w, r, http.StatusOK, hv.activity.active(ctx.Addr.PK))
+	})
+}
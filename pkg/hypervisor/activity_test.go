@@ -0,0 +1,77 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveSessions(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/pty/"+pk.Hex(), nil)
+	r = r.WithContext(context.WithValue(r.Context(), userKey, User{Name: "alice"}))
+	end := hv.activity.begin(pk, ActivityPty, r)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/active-sessions", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var sessions []ActiveSession
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&sessions))
+				require.Len(t, sessions, 1)
+				assert.Equal(t, ActivityPty, sessions[0].Type)
+				assert.Equal(t, "alice", sessions[0].User)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/restart", pk),
+			RespStatus: http.StatusConflict,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp activeSessionsResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Equal(t, ErrActiveSessions.Error(), resp.Error)
+				require.Len(t, resp.Sessions, 1)
+				assert.Equal(t, "alice", resp.Sessions[0].User)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/update", pk),
+			RespStatus: http.StatusConflict,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/restart?force=true", pk),
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	end()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/update", pk),
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	assert.Empty(t, hv.activity.active(pk))
+}
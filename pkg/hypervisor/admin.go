@@ -0,0 +1,87 @@
+package hypervisor
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	adminSocketPerm = 0600
+
+	// First file descriptor passed by systemd socket activation.
+	listenFDsStart = 3
+)
+
+// ServeAdmin serves the hypervisor API over a unix socket at path, without user authentication.
+// Access is restricted by the socket's file permissions, so only the user running the hypervisor may use it.
+// If the hypervisor is socket activated (systemd), the passed socket is used instead of creating one.
+func (hv *Hypervisor) ServeAdmin(path string) error {
+	lis, err := adminListener(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := lis.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close admin listener.")
+		}
+	}()
+
+	return http.Serve(lis, hv.adminHandler())
+}
+
+func (hv *Hypervisor) adminHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(newRequestLogger())
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(requestTimeout(httpTimeout))
+
+		r.Route("/v1", hv.adminRoutes)
+
+		r.Group(func(r chi.Router) {
+			r.Use(deprecatedAPI(""))
+			hv.adminRoutes(r)
+		})
+	})
+
+	return r
+}
+
+func (hv *Hypervisor) adminRoutes(r chi.Router) {
+	r.Get("/ping", hv.getPong())
+	r.Get("/openapi.json", hv.getOpenAPI())
+	r.Post("/user/unlock", hv.users.Unlock())
+
+	r.Group(func(r chi.Router) {
+		r.Use(hv.readOnlyGuard)
+		hv.apiRoutes(r)
+	})
+}
+
+func adminListener(path string) (net.Listener, error) {
+	if activated() {
+		return net.FileListener(os.NewFile(listenFDsStart, "admin-socket"))
+	}
+
+	// Remove the socket file left over by a previous run.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return listenAdminSocket(path)
+}
+
+// activated returns true if a single socket is passed to the process via socket activation.
+func activated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return false
+	}
+
+	return os.Getenv("LISTEN_FDS") == "1"
+}
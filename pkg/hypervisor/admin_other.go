@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package hypervisor
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// adminSocketUmask makes the admin socket created with adminSocketPerm.
+const adminSocketUmask = 0777 &^ adminSocketPerm
+
+// umaskMu serializes changes of the umask of the process.
+var umaskMu sync.Mutex // nolint: gochecknoglobals
+
+// listenAdminSocket creates the admin socket with the umask set, so that it is never accessible to other users,
+// not even before its permissions could be changed.
+func listenAdminSocket(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	old := syscall.Umask(adminSocketUmask)
+	defer syscall.Umask(old)
+
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,75 @@
+package hypervisor
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHypervisor_ServeAdmin(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	config := makeConfig(false)
+	config.EnableAuth = true
+	config.DBPath = filepath.Join(dir, "users.db")
+	sockPath := filepath.Join(dir, "admin.sock")
+
+	hv, err := New(nil, config)
+	require.NoError(t, err)
+
+	go func() {
+		_ = hv.ServeAdmin(sockPath) // nolint:errcheck
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(sockPath)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	info, err := os.Stat(sockPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(adminSocketPerm), info.Mode().Perm())
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+			},
+		},
+	}
+
+	// No login is required over the admin socket, even though auth is enabled.
+	resp, err := client.Get("http://unix/api/visors")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Get("http://unix/api/user")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Read-only mode applies to the admin socket too.
+	hv.setReadOnly(true)
+
+	req, err := http.NewRequest(http.MethodDelete, "http://unix/api/v1/route-finder/cache", nil)
+	require.NoError(t, err)
+
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,11 @@
+package hypervisor
+
+import (
+	"net"
+)
+
+// listenAdminSocket creates the admin socket. Windows has no file modes, so access is left to the ACLs
+// of the directory holding the socket.
+func listenAdminSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,230 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltMetaBucketName = "visor_meta"
+	maxVisorAliasLen   = 64
+	maxVisorNotesLen   = 4096
+)
+
+// Errors associated with visor aliases and notes.
+var (
+	ErrBadVisorAlias     = errors.New("visor alias should be 1 to 64 printable chars, without leading or trailing spaces")
+	ErrVisorAliasTaken   = errors.New("visor alias is already taken")
+	ErrVisorAliasNotSet  = errors.New("no visor has the given alias")
+	ErrVisorNotesTooLong = errors.New("visor notes should be at most 4096 chars")
+)
+
+// VisorMeta holds operator-provided information about a visor.
+type VisorMeta struct {
+	Alias string `json:"alias,omitempty"` // Human-friendly alias, unique (case-insensitively) among visors.
+	Notes string `json:"notes,omitempty"` // Free-form notes.
+}
+
+func (m VisorMeta) check() error {
+	if m.Alias != "" && !checkVisorAliasFormat(m.Alias) {
+		return ErrBadVisorAlias
+	}
+
+	if utf8.RuneCountInString(m.Notes) > maxVisorNotesLen {
+		return ErrVisorNotesTooLong
+	}
+
+	return nil
+}
+
+func checkVisorAliasFormat(alias string) bool {
+	if n := utf8.RuneCountInString(alias); n == 0 || n > maxVisorAliasLen {
+		return false
+	}
+
+	if strings.TrimSpace(alias) != alias {
+		return false
+	}
+
+	for _, r := range alias {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MetaStore stores aliases and notes of visors.
+type MetaStore interface {
+	Meta(pk cipher.PubKey) (VisorMeta, error)
+	Metas() (map[cipher.PubKey]VisorMeta, error)
+	SetMeta(pk cipher.PubKey, meta VisorMeta) error
+	PubKeyByAlias(alias string) (cipher.PubKey, error)
+}
+
+// BoltMetaStore implements MetaStore, storing visor aliases and notes in a bbolt database.
+type BoltMetaStore struct {
+	*bbolt.DB
+	enc *dbCipher // encrypts stored aliases and notes, if set
+}
+
+// NewBoltMetaStore creates a new BoltMetaStore on top of an opened bbolt database.
+func NewBoltMetaStore(db *bbolt.DB) (*BoltMetaStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltMetaBucketName))
+		return err
+	})
+
+	return &BoltMetaStore{DB: db}, err
+}
+
+// Meta returns the alias and notes of visor of pk.
+func (s *BoltMetaStore) Meta(pk cipher.PubKey) (meta VisorMeta, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw, err := s.enc.open(boltMetaBucketName, pk[:], tx.Bucket([]byte(boltMetaBucketName)).Get(pk[:]))
+		if err != nil || raw == nil {
+			return err
+		}
+
+		return json.Unmarshal(raw, &meta)
+	})
+
+	return meta, err
+}
+
+// Metas returns aliases and notes of all visors that have any.
+func (s *BoltMetaStore) Metas() (map[cipher.PubKey]VisorMeta, error) {
+	metas := make(map[cipher.PubKey]VisorMeta)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltMetaBucketName)).ForEach(func(k, v []byte) error {
+			var (
+				pk   cipher.PubKey
+				meta VisorMeta
+			)
+
+			v, err := s.enc.open(boltMetaBucketName, k, v)
+			if err != nil {
+				return err
+			}
+
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return err
+			}
+
+			copy(pk[:], k)
+			metas[pk] = meta
+
+			return nil
+		})
+	})
+
+	return metas, err
+}
+
+// SetMeta sets the alias and notes of visor of pk. Empty values remove them.
+func (s *BoltMetaStore) SetMeta(pk cipher.PubKey, meta VisorMeta) error {
+	if err := meta.check(); err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltMetaBucketName))
+
+		if meta == (VisorMeta{}) {
+			return b.Delete(pk[:])
+		}
+
+		if meta.Alias != "" {
+			err := b.ForEach(func(k, v []byte) error {
+				v, err := s.enc.open(boltMetaBucketName, k, v)
+				if err != nil {
+					return err
+				}
+
+				var other VisorMeta
+				if err := json.Unmarshal(v, &other); err != nil {
+					return err
+				}
+
+				if strings.EqualFold(other.Alias, meta.Alias) && string(k) != string(pk[:]) {
+					return ErrVisorAliasTaken
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		raw, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+
+		if raw, err = s.enc.seal(boltMetaBucketName, pk[:], raw); err != nil {
+			return err
+		}
+
+		return b.Put(pk[:], raw)
+	})
+}
+
+// PubKeyByAlias returns the public key of the visor of given alias (compared case-insensitively).
+func (s *BoltMetaStore) PubKeyByAlias(alias string) (pk cipher.PubKey, err error) {
+	metas, err := s.Metas()
+	if err != nil {
+		return pk, err
+	}
+
+	for pk, meta := range metas {
+		if meta.Alias != "" && strings.EqualFold(meta.Alias, alias) {
+			return pk, nil
+		}
+	}
+
+	return pk, ErrVisorAliasNotSet
+}
+
+func (hv *Hypervisor) putVisorAlias() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var meta VisorMeta
+
+		if err := httputil.ReadJSON(r, &meta); err != nil {
+			if err != io.EOF {
+				log.Warnf("putVisorAlias request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := hv.meta.SetMeta(ctx.Addr.PK, meta); err != nil {
+			switch err {
+			case ErrBadVisorAlias, ErrVisorNotesTooLong:
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			case ErrVisorAliasTaken:
+				httputil.WriteJSON(w, r, http.StatusConflict, err)
+			default:
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, meta)
+	})
+}
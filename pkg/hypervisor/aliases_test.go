@@ -0,0 +1,95 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisorAliases(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.True(t, len(pks) >= 2)
+
+	const alias = "Rack 3 / Pi #2"
+	escaped := url.PathEscape(alias)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/alias", pks[0]),
+			ReqBody:    strings.NewReader(`{"alias":" padded"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/alias", pks[0]),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"alias":%q,"notes":"Behind the fridge."}`, alias)),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/alias", pks[1]),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"alias":%q}`, strings.ToUpper(alias))),
+			RespStatus: http.StatusConflict,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/by-alias/" + escaped,
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp summaryResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Equal(t, pks[0], resp.PubKey)
+				assert.Equal(t, alias, resp.Alias)
+				assert.Equal(t, "Behind the fridge.", resp.Notes)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + escaped + "/apps",
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp []summaryResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+
+				for _, s := range resp {
+					if s.PubKey == pks[0] {
+						assert.Equal(t, alias, s.Alias)
+					} else {
+						assert.Empty(t, s.Alias)
+					}
+				}
+			},
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/alias", pks[0]),
+			ReqBody:    strings.NewReader(`{}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/by-alias/" + escaped,
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
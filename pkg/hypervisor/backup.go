@@ -0,0 +1,388 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltSnapshotBucketName = "config_snapshots"
+)
+
+// Errors associated with config snapshots.
+var (
+	ErrSnapshotNotFound = errors.New("config snapshot is not found")
+)
+
+// ConfigSnapshot is a backup of the config of a visor.
+// The secret key of the visor is never part of the snapshot.
+type ConfigSnapshot struct {
+	ID        uuid.UUID       `json:"id"`
+	VisorPK   cipher.PubKey   `json:"visor_pk"`
+	Note      string          `json:"note,omitempty"`
+	Apps      bool            `json:"apps"` // Whether app configs are included.
+	CreatedAt time.Time       `json:"created_at"`
+	Config    json.RawMessage `json:"config,omitempty"`
+}
+
+// SnapshotStore stores config snapshots of visors.
+type SnapshotStore interface {
+	Snapshots() ([]ConfigSnapshot, error)
+	Snapshot(id uuid.UUID) (*ConfigSnapshot, error)
+	AddSnapshot(s ConfigSnapshot) error
+	DeleteSnapshot(id uuid.UUID) error
+}
+
+// BoltSnapshotStore implements SnapshotStore, storing config snapshots in a bbolt database.
+type BoltSnapshotStore struct {
+	*bbolt.DB
+	enc *dbCipher // encrypts stored snapshots, if set
+}
+
+// NewBoltSnapshotStore creates a new BoltSnapshotStore on top of an opened bbolt database.
+func NewBoltSnapshotStore(db *bbolt.DB) (*BoltSnapshotStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltSnapshotBucketName))
+		return err
+	})
+
+	return &BoltSnapshotStore{DB: db}, err
+}
+
+// Snapshots returns all config snapshots, newest first.
+func (s *BoltSnapshotStore) Snapshots() ([]ConfigSnapshot, error) {
+	snapshots := make([]ConfigSnapshot, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltSnapshotBucketName)).ForEach(func(k, v []byte) error {
+			v, err := s.enc.open(boltSnapshotBucketName, k, v)
+			if err != nil {
+				return err
+			}
+
+			var snap ConfigSnapshot
+			if err := json.Unmarshal(v, &snap); err != nil {
+				return err
+			}
+
+			snapshots = append(snapshots, snap)
+
+			return nil
+		})
+	})
+
+	sortSnapshots(snapshots)
+
+	return snapshots, err
+}
+
+// Snapshot returns the config snapshot of id. Returns nil if there is none.
+func (s *BoltSnapshotStore) Snapshot(id uuid.UUID) (snap *ConfigSnapshot, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw, err := s.enc.open(boltSnapshotBucketName, id[:], tx.Bucket([]byte(boltSnapshotBucketName)).Get(id[:]))
+		if err != nil || raw == nil {
+			return err
+		}
+
+		snap = new(ConfigSnapshot)
+
+		return json.Unmarshal(raw, snap)
+	})
+
+	return snap, err
+}
+
+// AddSnapshot stores the config snapshot.
+func (s *BoltSnapshotStore) AddSnapshot(snap ConfigSnapshot) error {
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	if raw, err = s.enc.seal(boltSnapshotBucketName, snap.ID[:], raw); err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltSnapshotBucketName)).Put(snap.ID[:], raw)
+	})
+}
+
+// DeleteSnapshot removes the config snapshot of id.
+func (s *BoltSnapshotStore) DeleteSnapshot(id uuid.UUID) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltSnapshotBucketName)).Delete(id[:])
+	})
+}
+
+func sortSnapshots(snapshots []ConfigSnapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+}
+
+// snapshotVisor takes and stores a snapshot of the config of the visor of pk.
+func (hv *Hypervisor) snapshotVisor(pk cipher.PubKey, rpc visor.RPCClient, note string, apps bool) (ConfigSnapshot, error) {
+	raw, err := rpc.Config()
+	if err != nil {
+		return ConfigSnapshot{}, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return ConfigSnapshot{}, err
+	}
+
+	delete(fields, "key_pair")
+
+	if !apps {
+		delete(fields, "apps")
+	}
+
+	snap := ConfigSnapshot{
+		ID:        uuid.New(),
+		VisorPK:   pk,
+		Note:      note,
+		Apps:      apps,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if snap.Config, err = json.Marshal(fields); err != nil {
+		return ConfigSnapshot{}, err
+	}
+
+	return snap, hv.snapshots.AddSnapshot(snap)
+}
+
+// restoredConfig returns the current config with fields of the snapshot applied over it.
+// The key pair of the current config is always kept, and so are its app configs if the snapshot has none.
+func restoredConfig(current []byte, snap ConfigSnapshot) ([]byte, error) {
+	var cur, fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(current, &cur); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(snap.Config, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "key_pair")
+
+	if !snap.Apps {
+		delete(fields, "apps")
+	}
+
+	for k, v := range fields {
+		cur[k] = v
+	}
+
+	return json.Marshal(cur)
+}
+
+// postVisorSnapshot snapshots the config of the visor, including its app configs if 'apps' is set.
+func (hv *Hypervisor) postVisorSnapshot() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Note string `json:"note,omitempty"`
+			Apps bool   `json:"apps"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+			log.Warnf("postVisorSnapshot request: %v", err)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		snap, err := hv.snapshotVisor(ctx.Addr.PK, ctx.RPC, reqBody.Note, reqBody.Apps)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, snap)
+	})
+}
+
+// getVisorSnapshots lists config snapshots taken of a visor. The visor does not need to be connected.
+func (hv *Hypervisor) getVisorSnapshots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		snapshots, err := hv.snapshots.Snapshots()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		out := make([]ConfigSnapshot, 0)
+
+		for _, snap := range snapshots {
+			if snap.VisorPK == pk {
+				snap.Config = nil
+				out = append(out, snap)
+			}
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, out)
+	}
+}
+
+// postVisorRestore applies a config snapshot to the visor, which may differ from the visor it was taken of.
+// The current config of the visor is snapshotted first, so that the restore can be undone.
+// NOTE: If 'restart' is set, reply comes with a delay, as the visor is restarted to apply the config.
+func (hv *Hypervisor) postVisorRestore() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Snapshot uuid.UUID `json:"snapshot"`
+			Restart  bool      `json:"restart"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postVisorRestore request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		snap, err := hv.snapshots.Snapshot(reqBody.Snapshot)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if snap == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrSnapshotNotFound)
+			return
+		}
+
+		backup, err := hv.snapshotVisor(ctx.Addr.PK, ctx.RPC, fmt.Sprintf("before restore of snapshot %s", snap.ID), true)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, fmt.Errorf("failed to back up current config: %w", err))
+			return
+		}
+
+		current, err := ctx.RPC.Config()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		conf, err := restoredConfig(current, *snap)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := ctx.RPC.SetConfig(conf, reqBody.Restart); err != nil {
+			if errors.Is(err, visor.ErrInvalidConfig) {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		log.WithField("visor_pk", ctx.Addr.PK).
+			WithField("snapshot_id", snap.ID).
+			WithField("source_pk", snap.VisorPK).
+			Info("Restored visor config.")
+
+		output := struct {
+			Restored bool      `json:"restored"`
+			Backup   uuid.UUID `json:"backup"` // Snapshot of the config before the restore.
+		}{true, backup.ID}
+
+		httputil.WriteJSON(w, r, http.StatusOK, output)
+	})
+}
+
+// getSnapshots lists config snapshots of all visors, without their configs.
+func (hv *Hypervisor) getSnapshots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshots, err := hv.snapshots.Snapshots()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for i := range snapshots {
+			snapshots[i].Config = nil
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, snapshots)
+	}
+}
+
+func (hv *Hypervisor) getSnapshot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		snap, err := hv.snapshots.Snapshot(id)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if snap == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrSnapshotNotFound)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, snap)
+	}
+}
+
+func (hv *Hypervisor) deleteSnapshot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		snap, err := hv.snapshots.Snapshot(id)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if snap == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrSnapshotNotFound)
+			return
+		}
+
+		if err := hv.snapshots.DeleteSnapshot(id); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,164 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestConfigSnapshots(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	src, dst := pks[0], pks[1]
+
+	visorConfig := func(pk cipher.PubKey) *visor.Config {
+		conn, ok := hv.visorConn(pk)
+		require.True(t, ok)
+
+		raw, err := conn.RPC.Config()
+		require.NoError(t, err)
+
+		conf := new(visor.Config)
+		require.NoError(t, json.Unmarshal(raw, conf))
+
+		return conf
+	}
+
+	// The replacement visor starts off with a different log level and no apps.
+	conn, _ := hv.visorConn(dst)
+	raw, err := conn.RPC.Config()
+	require.NoError(t, err)
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &fields))
+	fields["log_level"], fields["apps"] = json.RawMessage(`"debug"`), json.RawMessage(`[]`)
+
+	raw, err = json.Marshal(fields)
+	require.NoError(t, err)
+	require.NoError(t, conn.RPC.SetConfig(raw, false))
+
+	var withApps, withoutApps ConfigSnapshot
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/snapshots", src),
+			ReqBody:    strings.NewReader(`{"note":"full","apps":true}`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&withApps))
+				assert.Equal(t, src, withApps.VisorPK)
+				assert.NotContains(t, string(withApps.Config), "key_pair")
+				assert.Contains(t, string(withApps.Config), `"apps"`)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/snapshots", src),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&withoutApps))
+				assert.NotContains(t, string(withoutApps.Config), `"apps"`)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/snapshots", src),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var snapshots []ConfigSnapshot
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&snapshots))
+				require.Len(t, snapshots, 2)
+				assert.Equal(t, withoutApps.ID, snapshots[0].ID)
+				assert.Nil(t, snapshots[0].Config)
+			},
+		},
+	})
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/restore", dst),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"snapshot":%q}`, uuid.New())),
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/restore", dst),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"snapshot":%q}`, withoutApps.ID)),
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	conf := visorConfig(dst)
+	assert.Equal(t, dst, conf.KeyPair.PubKey)
+	assert.Equal(t, visor.DefaultLogLevel, conf.LogLevel)
+	assert.Empty(t, conf.Apps)
+
+	var backup uuid.UUID
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/config/restore", dst),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"snapshot":%q}`, withApps.ID)),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var out struct {
+					Backup uuid.UUID `json:"backup"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+				backup = out.Backup
+			},
+		},
+	})
+
+	conf = visorConfig(dst)
+	assert.Equal(t, dst, conf.KeyPair.PubKey)
+	assert.Len(t, conf.Apps, 2)
+
+	// The config of the replacement visor was backed up before the restore.
+	snap, err := hv.snapshots.Snapshot(backup)
+	require.NoError(t, err)
+	require.NotNil(t, snap)
+	assert.Equal(t, dst, snap.VisorPK)
+	assert.True(t, snap.Apps)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/config-snapshots/%s", withApps.ID),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/config-snapshots/%s", withApps.ID),
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/config-snapshots",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var snapshots []ConfigSnapshot
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&snapshots))
+				assert.Len(t, snapshots, 3)
+			},
+		},
+	})
+}
@@ -0,0 +1,100 @@
+package hypervisor
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// ErrSameVisor occurs when a visor is tested with itself.
+var ErrSameVisor = errors.New("source and destination are the same visor")
+
+// BandwidthTestRequest is the request body of bandwidth tests.
+// Visors may be identified by public keys, unique prefixes or names.
+type BandwidthTestRequest struct {
+	Source        string         `json:"source"`
+	Destination   string         `json:"destination"`
+	TransportType string         `json:"transport_type,omitempty"` // Defaults to dmsg.
+	Duration      visor.Duration `json:"duration,omitempty"`       // Of each direction, defaults to 10s.
+}
+
+// BandwidthTestResult is the result of a bandwidth test.
+// Upload is from the source to the destination, and download from the destination to the source.
+type BandwidthTestResult struct {
+	Source      cipher.PubKey `json:"source"`
+	Destination cipher.PubKey `json:"destination"`
+	*visor.BandwidthResult
+}
+
+// postBandwidthTest measures the throughput between two connected visors, in both directions.
+// The destination is told to expect the test, which the source then runs over a transport to the destination.
+func (hv *Hypervisor) postBandwidthTest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req BandwidthTestRequest
+		if err := httputil.ReadJSON(r, &req); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		d := time.Duration(req.Duration)
+		if d < 0 || d > visor.MaxBandwidthTestDuration {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, visor.ErrBandwidthTestDuration)
+			return
+		}
+
+		var conns [2]VisorConn
+
+		for i, id := range []string{req.Source, req.Destination} {
+			pk, status, err := hv.resolveVisor(id)
+			if err != nil {
+				httputil.WriteJSON(w, r, status, err)
+				return
+			}
+
+			conn, ok := hv.visorConn(pk)
+			if !ok {
+				httputil.WriteJSON(w, r, http.StatusNotFound, ErrVisorDisconnected)
+				return
+			}
+
+			conns[i] = conn
+		}
+
+		src, dst := conns[0], conns[1]
+
+		if src.Addr.PK == dst.Addr.PK {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrSameVisor)
+			return
+		}
+
+		if _, err := hv.extendDeadline(r, visor.Duration(visor.BandwidthTestTimeout(d))); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := dst.RPC.ExpectBandwidthTest(src.Addr.PK); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		res, err := src.RPC.BandwidthTest(dst.Addr.PK, req.TransportType, d)
+		if err != nil {
+			if !writeDeadlineExceeded(w, r, err) {
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, BandwidthTestResult{
+			Source:          src.Addr.PK,
+			Destination:     dst.Addr.PK,
+			BandwidthResult: res,
+		})
+	}
+}
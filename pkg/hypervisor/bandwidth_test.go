@@ -0,0 +1,66 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostBandwidthTest(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	unknown, _ := cipher.GenerateKeyPair()
+
+	body := func(src, dst cipher.PubKey, extra string) *strings.Reader {
+		return strings.NewReader(fmt.Sprintf(`{"source":%q,"destination":%q%s}`, src, dst, extra))
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tests/bandwidth",
+			ReqBody:    body(pks[0], pks[1], `,"transport_type":"stcp","duration":"5s"`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var res BandwidthTestResult
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+				assert.Equal(t, pks[0], res.Source)
+				assert.Equal(t, pks[1], res.Destination)
+				require.NotNil(t, res.BandwidthResult)
+				assert.Equal(t, "stcp", res.TransportType)
+				assert.Greater(t, res.UploadMbps, 0.0)
+				assert.Greater(t, res.DownloadMbps, 0.0)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tests/bandwidth",
+			ReqBody:    body(pks[0], pks[0], ""),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tests/bandwidth",
+			ReqBody:    body(pks[0], pks[1], `,"duration":"1h"`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tests/bandwidth",
+			ReqBody:    body(pks[0], unknown, ""),
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
@@ -0,0 +1,243 @@
+package hypervisor
+
+import (
+	"net/http"
+	"runtime"
+	"sort"
+	"unsafe"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Estimated sizes of cached values, excluding the overhead of the maps holding them.
+const (
+	pkSize          = int64(len(cipher.PubKey{}))
+	mapEntrySize    = int64(16) // rough per entry overhead of a Go map
+	visorStateSize  = int64(unsafe.Sizeof(visorState{}))
+	appStatusSize   = int64(unsafe.Sizeof(visor.AppStatus(0)))
+	tpSampleSize    = int64(unsafe.Sizeof(tpSample{}))
+	tpRingSize      = int64(unsafe.Sizeof(tpRing{}))
+	tpStatsKeySize  = int64(unsafe.Sizeof(tpStatsKey{}))
+	watchCacheSize  = int64(unsafe.Sizeof(watchCache{}))
+	watchEventSize  = int64(unsafe.Sizeof(WatchEvent{}))
+	routeKeySize    = int64(unsafe.Sizeof(routeCacheKey{}))
+	routeEntrySize  = int64(unsafe.Sizeof(routeCacheEntry{}))
+	routingPathSize = int64(unsafe.Sizeof(routing.Path{}))
+	routingHopSize  = int64(unsafe.Sizeof(routing.Hop{}))
+)
+
+// CacheHits counts the lookups of a cache.
+type CacheHits struct {
+	Hits   uint64  `json:"hits"`
+	Misses uint64  `json:"misses"`
+	Ratio  float64 `json:"ratio"` // Hits of all lookups, 0 without lookups.
+}
+
+func newCacheHits(hits, misses uint64) *CacheHits {
+	h := &CacheHits{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		h.Ratio = float64(hits) / float64(total)
+	}
+
+	return h
+}
+
+// CacheStat describes the entries of a cache.
+type CacheStat struct {
+	Entries int        `json:"entries"`
+	Bytes   int64      `json:"bytes"`             // Estimated memory used by the entries.
+	Lookups *CacheHits `json:"lookups,omitempty"` // Only reported by caches which are looked up.
+}
+
+func (s *CacheStat) add(o CacheStat) {
+	s.Entries += o.Entries
+	s.Bytes += o.Bytes
+}
+
+// VisorCacheStats describes the entries cached for a single visor.
+type VisorCacheStats struct {
+	PK             cipher.PubKey `json:"pk"`
+	Summary        CacheStat     `json:"summary"`         // Last health and app states, compared for notifications.
+	TransportStats CacheStat     `json:"transport_stats"` // Throughput samples of transports.
+	Watches        CacheStat     `json:"watches"`         // Watched collections and their recent events.
+	Bytes          int64         `json:"bytes"`
+}
+
+// CacheBounds are the settings bounding the caches.
+type CacheBounds struct {
+	RouteFinderTTL        string `json:"route_finder_ttl"`
+	TransportStatsSamples int    `json:"transport_stats_samples"` // Samples kept per transport.
+	WatchHistory          int    `json:"watch_history"`           // Events kept per watched collection.
+}
+
+// CacheStats describes the caches of the hypervisor.
+type CacheStats struct {
+	Visors         []VisorCacheStats `json:"visors"` // Largest first.
+	Summary        CacheStat         `json:"summary"`
+	TransportStats CacheStat         `json:"transport_stats"`
+	Watches        CacheStat         `json:"watches"`
+	RouteFinder    CacheStat         `json:"route_finder"`
+	Bytes          int64             `json:"bytes"`      // Estimated memory used by all caches.
+	HeapAlloc      uint64            `json:"heap_alloc"` // Bytes of allocated heap objects of the process.
+	Bounds         CacheBounds       `json:"bounds"`
+}
+
+// getCacheStats reports the sizes, lookups and estimated memory of the caches, per visor where they are per visor.
+func (hv *Hypervisor) getCacheStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httputil.WriteJSON(w, r, http.StatusOK, hv.cacheStats())
+	}
+}
+
+func (hv *Hypervisor) cacheStats() CacheStats {
+	visors := make(map[cipher.PubKey]*VisorCacheStats)
+
+	visorStats := func(pk cipher.PubKey) *VisorCacheStats {
+		s, ok := visors[pk]
+		if !ok {
+			s = &VisorCacheStats{PK: pk}
+			visors[pk] = s
+		}
+
+		return s
+	}
+
+	stats := CacheStats{
+		Visors:      make([]VisorCacheStats, 0),
+		RouteFinder: hv.routes.stats(),
+		Bounds: CacheBounds{
+			RouteFinderTTL:        hv.routes.ttl.String(),
+			TransportStatsSamples: tpStatsCapacity,
+			WatchHistory:          watchHistoryLen,
+		},
+	}
+
+	for pk, s := range hv.notifier.stats() {
+		visorStats(pk).Summary = s
+		stats.Summary.add(s)
+	}
+
+	tpVisors, tpLookups := hv.tpStats.stats()
+	for pk, s := range tpVisors {
+		visorStats(pk).TransportStats = s
+		stats.TransportStats.add(s)
+	}
+
+	stats.TransportStats.Lookups = tpLookups
+
+	watchVisors, watchLookups := hv.watches.stats()
+	for pk, s := range watchVisors {
+		if !pk.Null() { // the change feed is of all visors
+			visorStats(pk).Watches = s
+		}
+
+		stats.Watches.add(s)
+	}
+
+	stats.Watches.Lookups = watchLookups
+
+	for _, s := range visors {
+		s.Bytes = s.Summary.Bytes + s.TransportStats.Bytes + s.Watches.Bytes
+		stats.Visors = append(stats.Visors, *s)
+	}
+
+	sort.Slice(stats.Visors, func(i, j int) bool {
+		if stats.Visors[i].Bytes != stats.Visors[j].Bytes {
+			return stats.Visors[i].Bytes > stats.Visors[j].Bytes
+		}
+
+		return stats.Visors[i].PK.Hex() < stats.Visors[j].PK.Hex()
+	})
+
+	stats.Bytes = stats.Summary.Bytes + stats.TransportStats.Bytes + stats.Watches.Bytes + stats.RouteFinder.Bytes
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats.HeapAlloc = mem.HeapAlloc
+
+	return stats
+}
+
+// stats returns the sizes of the states kept per visor.
+func (n *notifier) stats() map[cipher.PubKey]CacheStat {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	out := make(map[cipher.PubKey]CacheStat, len(n.states))
+
+	for pk, state := range n.states {
+		bytes := pkSize + visorStateSize + mapEntrySize
+		for name := range state.apps {
+			bytes += int64(len(name)) + appStatusSize + mapEntrySize
+		}
+
+		out[pk] = CacheStat{Entries: 1 + len(state.apps), Bytes: bytes}
+	}
+
+	return out
+}
+
+// stats returns the sizes of the sample rings kept per visor, and the lookups of series.
+func (s *tpStats) stats() (map[cipher.PubKey]CacheStat, *CacheHits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[cipher.PubKey]CacheStat)
+
+	for key, ring := range s.rings {
+		stat := out[key.pk]
+		stat.Entries++
+		stat.Bytes += tpStatsKeySize + tpRingSize + mapEntrySize + int64(cap(ring.samples))*tpSampleSize
+		out[key.pk] = stat
+	}
+
+	return out, newCacheHits(s.hits, s.misses)
+}
+
+// stats returns the sizes of the collections watched per visor, and the lookups of events after resource versions.
+func (h *watchHub) stats() (map[cipher.PubKey]CacheStat, *CacheHits) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[cipher.PubKey]CacheStat)
+
+	for key, c := range h.caches {
+		bytes := int64(len(key.kind)) + pkSize + watchCacheSize + mapEntrySize
+
+		for k, obj := range c.objects {
+			bytes += int64(len(k)+len(obj)) + mapEntrySize
+		}
+
+		for _, e := range c.events {
+			bytes += watchEventSize + int64(len(e.Type)+len(e.ResourceVersion)+len(e.Object)+len(e.key))
+		}
+
+		stat := out[key.pk]
+		stat.Entries += len(c.objects) + len(c.events)
+		stat.Bytes += bytes
+		out[key.pk] = stat
+	}
+
+	return out, newCacheHits(h.hits, h.misses)
+}
+
+// stats returns the size of the cached routes, and the lookups of route edges.
+func (c *routeCache) stats() CacheStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stat := CacheStat{Entries: len(c.entries), Lookups: newCacheHits(c.hits, c.misses)}
+
+	for _, e := range c.entries {
+		stat.Bytes += routeKeySize + routeEntrySize + mapEntrySize + int64(len(e.paths))*routingPathSize
+		for _, p := range e.paths {
+			stat.Bytes += int64(len(p)) * routingHopSize
+		}
+	}
+
+	return stat
+}
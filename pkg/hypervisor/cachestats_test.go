@@ -0,0 +1,73 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+func TestCacheStats(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for visorPK := range hv.visors {
+		pk = visorPK
+	}
+
+	rf := new(countingRouteFinder)
+	hv.routes = newRouteCache(rf, time.Hour)
+
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+	edges := []routing.PathEdges{{pk1, pk2}}
+
+	for i := 0; i < 4; i++ {
+		_, err := hv.routes.FindRoutes(context.Background(), edges, nil)
+		require.NoError(t, err)
+	}
+
+	hv.watches.observe(watchKey{pk: pk, kind: watchTransports}, map[string]json.RawMessage{"a": json.RawMessage(`{"id":"a"}`)})
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/admin/cache-stats",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var stats CacheStats
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&stats))
+
+				assert.Equal(t, 1, stats.RouteFinder.Entries)
+				require.NotNil(t, stats.RouteFinder.Lookups)
+				assert.Equal(t, uint64(3), stats.RouteFinder.Lookups.Hits)
+				assert.Equal(t, uint64(1), stats.RouteFinder.Lookups.Misses)
+				assert.Equal(t, 0.75, stats.RouteFinder.Lookups.Ratio)
+
+				require.NotEmpty(t, stats.Visors)
+
+				var watched *VisorCacheStats
+				for i := range stats.Visors {
+					if stats.Visors[i].PK == pk {
+						watched = &stats.Visors[i]
+					}
+				}
+
+				require.NotNil(t, watched)
+				assert.Equal(t, 1, watched.Watches.Entries)
+				assert.Greater(t, watched.Watches.Bytes, int64(0))
+				assert.GreaterOrEqual(t, stats.Bytes, watched.Bytes+stats.RouteFinder.Bytes)
+				assert.Greater(t, stats.HeapAlloc, uint64(0))
+				assert.Equal(t, watchHistoryLen, stats.Bounds.WatchHistory)
+			},
+		},
+	})
+}
@@ -0,0 +1,185 @@
+package hypervisor
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Kinds of objects of the change feed.
+const (
+	ChangeVisor     = "visor"
+	ChangeApp       = "app"
+	ChangeTransport = "transport"
+)
+
+const (
+	watchChanges          = "changes" // visors, apps and transports of all visors
+	defaultChangesTimeout = 30 * time.Second
+	changesTimeoutSlack   = 5 * time.Second // for responding once long-polls time out
+)
+
+// changesKey is the watched collection of the change feed, which does not belong to a single visor.
+var changesKey = watchKey{kind: watchChanges} // nolint: gochecknoglobals
+
+// Change is an object of the change feed.
+type Change struct {
+	Kind    string        `json:"kind"`
+	VisorPK cipher.PubKey `json:"visor_pk"`
+	Object  interface{}   `json:"object"`
+}
+
+// VisorState is the state of a visor in the change feed.
+// Its apps and transports are separate objects of the feed.
+type VisorState struct {
+	TCPAddr     string `json:"tcp_addr"`
+	Online      bool   `json:"online"`
+	Name        string `json:"name,omitempty"`
+	Alias       string `json:"alias,omitempty"`
+	RoutesCount int    `json:"routes_count"`
+}
+
+// ChangesResp is the response of the change feed.
+type ChangesResp struct {
+	Cursor  string       `json:"cursor"`
+	Changes []WatchEvent `json:"changes"`
+}
+
+// pollChanges obtains the summaries of all visors, and records changes of visors, apps and transports.
+// The apps and transports of visors which do not respond are deleted from the feed.
+func (hv *Hypervisor) pollChanges() error {
+	summaries, err := hv.visorSummaries()
+	if err != nil {
+		return err
+	}
+
+	objects := make(map[string]interface{})
+
+	for _, s := range summaries {
+		pk := s.PubKey
+
+		objects[ChangeVisor+"/"+pk.Hex()] = Change{
+			Kind:    ChangeVisor,
+			VisorPK: pk,
+			Object: VisorState{
+				TCPAddr:     s.TCPAddr,
+				Online:      s.Online,
+				Name:        s.Name,
+				Alias:       s.Alias,
+				RoutesCount: s.RoutesCount,
+			},
+		}
+
+		for _, app := range s.Apps {
+			objects[ChangeApp+"/"+pk.Hex()+"/"+app.Name] = Change{Kind: ChangeApp, VisorPK: pk, Object: app}
+		}
+
+		for _, tp := range s.Transports {
+			stripped := *tp
+			stripped.Log = nil
+			objects[ChangeTransport+"/"+pk.Hex()+"/"+tp.ID.String()] = Change{Kind: ChangeTransport, VisorPK: pk, Object: &stripped}
+		}
+	}
+
+	encoded, err := encodeWatchObjects(objects)
+	if err != nil {
+		return err
+	}
+
+	hv.watches.observe(changesKey, encoded)
+
+	return nil
+}
+
+// getChanges long-polls the changes of visors, apps and transports after the 'cursor' query.
+// Without a cursor, the current objects are returned as added. Otherwise the response is delayed until there are
+// changes, or the 'timeout' query (30s by default) expires with no changes. The returned cursor is to be passed to
+// the next request.
+func (hv *Hypervisor) getChanges() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var cursor uint64
+
+		if c := q.Get("cursor"); c != "" {
+			var err error
+			if cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+		}
+
+		timeout := defaultChangesTimeout
+
+		if t := q.Get("timeout"); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil || d <= 0 {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+
+			timeout = d
+		}
+
+		if _, err := hv.extendDeadline(r, visor.Duration(timeout+changesTimeoutSlack)); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := hv.pollChanges(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		events, version, changed, err := hv.watches.eventsSince(changesKey, cursor)
+		if err == ErrResourceVersionExpired {
+			httputil.WriteJSON(w, r, http.StatusGone, err)
+			return
+		}
+
+		respond := func() {
+			if events == nil {
+				events = []WatchEvent{}
+			}
+
+			httputil.WriteJSON(w, r, http.StatusOK, ChangesResp{Cursor: strconv.FormatUint(version, 10), Changes: events})
+		}
+
+		if len(events) == 0 {
+			unsubscribe := hv.watches.subscribe(changesKey, hv.c.WatchInterval, func() {
+				if err := hv.pollChanges(); err != nil {
+					log.WithError(err).Debug("Failed to poll changes.")
+				}
+			})
+			defer unsubscribe()
+
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			for len(events) == 0 && err == nil {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-timer.C:
+					respond()
+					return
+				case <-changed:
+				}
+
+				events, version, changed, err = hv.watches.eventsSince(changesKey, version)
+			}
+
+			if err != nil {
+				httputil.WriteJSON(w, r, http.StatusGone, err)
+				return
+			}
+		}
+
+		respond()
+	}
+}
@@ -0,0 +1,119 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanges(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	getChanges := func(query string) ChangesResp {
+		resp, err := client.Get(fmt.Sprintf("https://%s/api/v1/changes?%s", addr, query))
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, resp.Body.Close())
+		}()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var changes ChangesResp
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&changes))
+
+		return changes
+	}
+
+	decode := func(e WatchEvent) Change {
+		var c struct {
+			Change
+			Object json.RawMessage `json:"object"`
+		}
+		require.NoError(t, json.Unmarshal(e.Object, &c))
+
+		c.Change.Object = c.Object
+
+		return c.Change
+	}
+
+	initial := getChanges("")
+	visors := 0
+
+	for _, e := range initial.Changes {
+		assert.Equal(t, WatchAdded, e.Type)
+
+		if decode(e).Kind == ChangeVisor {
+			visors++
+		}
+	}
+
+	assert.Equal(t, 3, visors)
+
+	// Nothing changed.
+	idle := getChanges("timeout=50ms&cursor=" + initial.Cursor)
+	assert.Empty(t, idle.Changes)
+	assert.Equal(t, initial.Cursor, idle.Cursor)
+
+	// A transport created while long-polling ends the poll.
+	changesCh := make(chan ChangesResp, 1)
+	go func() {
+		changesCh <- getChanges("timeout=10s&cursor=" + initial.Cursor)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	remote, _ := cipher.GenerateKeyPair()
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports", pk),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_type":"native","remote_pk":%q}`, remote)),
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	select {
+	case changes := <-changesCh:
+		require.NotEmpty(t, changes.Changes)
+		assert.NotEqual(t, initial.Cursor, changes.Cursor)
+
+		var added bool
+		for _, e := range changes.Changes {
+			if c := decode(e); e.Type == WatchAdded && c.Kind == ChangeTransport {
+				assert.Equal(t, pk, c.VisorPK)
+				added = true
+			}
+		}
+
+		assert.True(t, added)
+	case <-time.After(5 * time.Second):
+		t.Fatal("long-poll did not return after a change")
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/changes?cursor=abc",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/changes?cursor=1000000",
+			RespStatus: http.StatusGone,
+		},
+	})
+}
@@ -44,17 +44,46 @@ func (hk *Key) UnmarshalText(text []byte) error {
 
 // Config configures the hypervisor.
 type Config struct {
-	PK            cipher.PubKey `json:"public_key"`
-	SK            cipher.SecKey `json:"secret_key"`
-	DBPath        string        `json:"db_path"`        // Path to store database file.
-	EnableAuth    bool          `json:"enable_auth"`    // Whether to enable user management.
-	Cookies       CookieConfig  `json:"cookies"`        // Configures cookies (for session management).
-	DmsgDiscovery string        `json:"dmsg_discovery"` // Dmsg discovery address.
-	DmsgPort      uint16        `json:"dmsg_port"`      // Dmsg port to serve on.
-	HTTPAddr      string        `json:"http_addr"`      // HTTP address to serve API/web UI on.
-	EnableTLS     bool          `json:"enable_tls"`     // Whether to enable TLS.
-	TLSCertFile   string        `json:"tls_cert_file"`  // TLS cert file location.
-	TLSKeyFile    string        `json:"tls_key_file"`   // TLS key file location.
+	Version       string              `json:"version"` // Version of the config schema.
+	PK            cipher.PubKey       `json:"public_key"`
+	SK            cipher.SecKey       `json:"secret_key"`
+	DBPath        string              `json:"db_path"`        // Path to store database file.
+	Store         StoreConfig         `json:"store"`          // Configures the shared state store (overrides db_path).
+	UserStore     UserStoreConfig     `json:"user_store"`     // Configures a separate store of users (optional).
+	DBEncryption  DBEncryptionConfig  `json:"db_encryption"`  // Configures encryption at rest of the bbolt store.
+	EnableAuth    bool                `json:"enable_auth"`    // Whether to enable user management.
+	OIDC          OIDCConfig          `json:"oidc"`           // Configures single sign-on through an OpenID Connect provider.
+	ReadOnly      bool                `json:"read_only"`      // Whether to start in read-only mode, refusing modifications through the API.
+	Cookies       CookieConfig        `json:"cookies"`        // Configures cookies (for session management).
+	LoginLimits   LoginLimitConfig    `json:"login_limits"`   // Configures brute-force protection of logins.
+	RateLimits    RateLimitConfig     `json:"rate_limits"`    // Configures rate limits and request size limits of the API.
+	IPFilter      IPFilterConfig      `json:"ip_filter"`      // Configures which client IPs may access the API and ptys.
+	DmsgDiscovery string              `json:"dmsg_discovery"` // Dmsg discovery address.
+	DmsgPort      uint16              `json:"dmsg_port"`      // Dmsg port to serve on.
+	StandbyPort   uint16              `json:"standby_port"`   // Dmsg port to accept heartbeats of standby visors on.
+	AcceptList    AcceptListConfig    `json:"accept_list"`    // Configures which visors are accepted.
+	UptimeTracker UptimeTrackerConfig `json:"uptime_tracker"` // Configures the embedded uptime tracker.
+	PtyRecording  PtyRecordingConfig  `json:"pty_recording"`  // Configures recording of pty sessions.
+	HTTPAddr      string              `json:"http_addr"`      // HTTP address to serve API/web UI on.
+	BasePath      string              `json:"base_path"`      // URL path to serve API/web UI under, such as "/skywire/" behind a reverse proxy.
+	CORSOrigins   []string            `json:"cors_origins"`   // Origins allowed to make cross-origin requests ("*" allows any).
+
+	MaxRequestTimeout time.Duration `json:"max_request_timeout"` // Upper bound of timeouts requested by exec, update and restart requests.
+	WatchInterval     time.Duration `json:"watch_interval"`      // How often watched transports and routes of visors are polled for changes.
+	ProbeInterval     time.Duration `json:"probe_interval"`      // How often the dmsg latency to connected visors is probed.
+	KeepAliveInterval time.Duration `json:"keepalive_interval"`  // How often connected visors are sent keepalives (negative disables them).
+	KeepAliveMisses   int           `json:"keepalive_misses"`    // Consecutive missed keepalives after which a visor is disconnected.
+	RPCCompression    string        `json:"rpc_compression"`     // Compression of large RPC responses of visors: "gzip", "snappy" or none if empty.
+	EnableTLS         bool          `json:"enable_tls"`          // Whether to enable TLS.
+	TLSCertFile       string        `json:"tls_cert_file"`       // TLS cert file location.
+	TLSKeyFile        string        `json:"tls_key_file"`        // TLS key file location.
+	ACME              ACMEConfig    `json:"acme"`                // Obtains TLS certificates automatically, instead of using cert/key files.
+	AdminSocket       string        `json:"admin_socket"`        // Unix socket to serve the API on without user authentication (leave blank to disable).
+
+	RouteFinder RouteFinderConfig `json:"route_finder"` // Configures route finder lookups and caching.
+
+	Notifications NotificationsConfig `json:"notifications"` // Configures webhook notifications.
+	Sync          SyncConfig          `json:"sync"`          // Configures mirroring of visors into external systems.
 }
 
 func makeConfig(testenv bool) Config {
@@ -90,6 +119,10 @@ func GenerateLocalConfig(testenv bool) Config {
 
 // FillDefaults fills the config with default values.
 func (c *Config) FillDefaults(testEnv bool) {
+	if c.Version == "" {
+		c.Version = ConfigVersion
+	}
+
 	if c.PK.Null() || c.SK.Null() {
 		c.PK, c.SK = cipher.GenerateKeyPair()
 	}
@@ -111,8 +144,41 @@ func (c *Config) FillDefaults(testEnv bool) {
 	if c.DmsgPort == 0 {
 		c.DmsgPort = skyenv.DmsgHypervisorPort
 	}
+	if c.StandbyPort == 0 {
+		c.StandbyPort = skyenv.DmsgStandbyPort
+	}
+	if c.RouteFinder.Addr == "" {
+		if testEnv {
+			c.RouteFinder.Addr = skyenv.TestRouteFinderAddr
+		} else {
+			c.RouteFinder.Addr = skyenv.DefaultRouteFinderAddr
+		}
+	}
 	c.HTTPAddr = defaultHTTPAddr
+	if c.Store.Type == "" {
+		c.Store.Type = StoreBolt
+	}
+	if c.MaxRequestTimeout == 0 {
+		c.MaxRequestTimeout = defaultMaxRequestTimeout
+	}
+	if c.WatchInterval <= 0 {
+		c.WatchInterval = defaultWatchInterval
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = defaultProbeInterval
+	}
+	if c.KeepAliveInterval == 0 {
+		c.KeepAliveInterval = defaultKeepAliveInterval
+	}
+	if c.KeepAliveMisses <= 0 {
+		c.KeepAliveMisses = defaultKeepAliveMisses
+	}
 	c.Cookies.FillDefaults()
+	c.LoginLimits.FillDefaults()
+	c.RateLimits.FillDefaults()
+	c.PtyRecording.FillDefaults()
+	c.ACME.FillDefaults()
+	c.OIDC.FillDefaults()
 }
 
 // Parse parses the file in path, and decodes to the config.
@@ -136,6 +202,20 @@ func (c *Config) Parse(path string) error {
 	return json.NewDecoder(f).Decode(c)
 }
 
+// Store types.
+const (
+	StoreBolt = "bbolt"
+	StoreSQL  = "sql"
+)
+
+// StoreConfig configures where users, sessions, visor names, uptimes and the visor registry are kept.
+// Hypervisor instances sharing a SQL store (and cookie keys) act as a single highly available hypervisor.
+type StoreConfig struct {
+	Type   string `json:"type"`             // Either "bbolt" (default, uses db_path) or "sql".
+	Driver string `json:"driver,omitempty"` // Name of the linked-in SQL driver: "postgres", unless more are linked in.
+	DSN    string `json:"dsn,omitempty"`    // SQL data source name.
+}
+
 // CookieConfig configures cookies used for hypervisor.
 type CookieConfig struct {
 	HashKey  Key `json:"hash_key"`  // Signs the cookie: 32 or 64 bytes.
@@ -0,0 +1,236 @@
+package hypervisor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	boltEncryptionBucketName = "encryption"
+	dbKDFIterations          = 100000
+	dbSaltLen                = 16
+	dbCheckPlaintext         = "skywire-hypervisor"
+)
+
+// dbSealedPrefix marks encrypted values, so that values written before encryption was enabled can be told apart.
+var dbSealedPrefix = []byte("swenc1:") // nolint: gochecknoglobals
+
+// Errors associated with database encryption.
+var (
+	ErrDBEncrypted      = errors.New("database is encrypted, but no passphrase or key file is configured")
+	ErrBadDBKey         = errors.New("database passphrase or key file is incorrect")
+	ErrDBValueNotSealed = errors.New("database value is not encrypted")
+	ErrDBEncryptionSQL  = errors.New("database encryption is only supported by the bbolt store")
+)
+
+// DBEncryptionConfig configures encryption at rest of the bbolt store.
+// Users (credentials and TOTP secrets), sessions, visor notes and visor config snapshots are encrypted.
+type DBEncryptionConfig struct {
+	Passphrase string `json:"passphrase,omitempty"` // Passphrase to derive the key from.
+	KeyFile    string `json:"key_file,omitempty"`   // File holding the secret to derive the key from (instead of passphrase).
+}
+
+// Enabled returns whether encryption is configured.
+func (c DBEncryptionConfig) Enabled() bool {
+	return c.Passphrase != "" || c.KeyFile != ""
+}
+
+func (c DBEncryptionConfig) secret() ([]byte, error) {
+	if c.KeyFile == "" {
+		return []byte(c.Passphrase), nil
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Clean(c.KeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database key file: %w", err)
+	}
+
+	secret := bytes.TrimSpace(raw)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("database key file %s is empty", c.KeyFile)
+	}
+
+	return secret, nil
+}
+
+// dbCipher encrypts and decrypts values of bbolt buckets. A nil *dbCipher leaves values as they are.
+type dbCipher struct {
+	key []byte
+}
+
+// seal encrypts a value stored under key of bucket.
+// The bucket name and key are authenticated, so that sealed values can not be moved to other keys.
+func (c *dbCipher) seal(bucket string, key, plain []byte) ([]byte, error) {
+	if c == nil {
+		return plain, nil
+	}
+
+	aead, err := chacha20poly1305.NewX(c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), len(dbSealedPrefix)+aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(append([]byte(nil), dbSealedPrefix...), nonce...)
+
+	return aead.Seal(out, nonce, plain, dbAdditionalData(bucket, key)), nil
+}
+
+// open decrypts a value stored under key of bucket.
+// Once encryption is enabled, all values are expected to be encrypted (see openDBCipher).
+func (c *dbCipher) open(bucket string, key, raw []byte) ([]byte, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	sealed := bytes.HasPrefix(raw, dbSealedPrefix)
+
+	switch {
+	case c == nil && sealed:
+		return nil, ErrDBEncrypted
+	case c == nil:
+		return raw, nil
+	case !sealed:
+		return nil, fmt.Errorf("%w: key %q of bucket %s", ErrDBValueNotSealed, key, bucket)
+	}
+
+	aead, err := chacha20poly1305.NewX(c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw = raw[len(dbSealedPrefix):]
+	if len(raw) < aead.NonceSize() {
+		return nil, ErrBadDBKey
+	}
+
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], dbAdditionalData(bucket, key))
+	if err != nil {
+		return nil, ErrBadDBKey
+	}
+
+	return plain, nil
+}
+
+// dbAdditionalData binds a sealed value to the bucket and key it is stored under.
+func dbAdditionalData(bucket string, key []byte) []byte {
+	return append(append([]byte(bucket), 0), key...)
+}
+
+// openDBCipher derives the key of the database, and verifies it against the stored check value.
+// On first use, the salt and check value are created, and existing values of the buckets are encrypted.
+// If encryption is not configured, it is verified that the database is not encrypted.
+func openDBCipher(db *bbolt.DB, c DBEncryptionConfig, buckets ...string) (*dbCipher, error) {
+	if !c.Enabled() {
+		var encrypted bool
+
+		err := db.View(func(tx *bbolt.Tx) error {
+			encrypted = tx.Bucket([]byte(boltEncryptionBucketName)) != nil
+			return nil
+		})
+		if err == nil && encrypted {
+			err = ErrDBEncrypted
+		}
+
+		return nil, err
+	}
+
+	secret, err := c.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	var enc *dbCipher
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(boltEncryptionBucketName))
+		if err != nil {
+			return err
+		}
+
+		if salt := b.Get([]byte("salt")); salt != nil {
+			enc = &dbCipher{key: pbkdf2.Key(secret, salt, dbKDFIterations, chacha20poly1305.KeySize, sha256.New)}
+
+			check, err := enc.open(boltEncryptionBucketName, []byte("check"), b.Get([]byte("check")))
+			if err != nil || string(check) != dbCheckPlaintext {
+				return ErrBadDBKey
+			}
+		} else {
+			salt := make([]byte, dbSaltLen)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+
+			enc = &dbCipher{key: pbkdf2.Key(secret, salt, dbKDFIterations, chacha20poly1305.KeySize, sha256.New)}
+
+			check, err := enc.seal(boltEncryptionBucketName, []byte("check"), []byte(dbCheckPlaintext))
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put([]byte("salt"), salt); err != nil {
+				return err
+			}
+
+			if err := b.Put([]byte("check"), check); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range buckets {
+			if err := enc.sealBucket(name, tx.Bucket([]byte(name))); err != nil {
+				return fmt.Errorf("failed to encrypt bucket %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+
+	return enc, err
+}
+
+// sealBucket encrypts values of the bucket which are not encrypted yet.
+func (c *dbCipher) sealBucket(name string, b *bbolt.Bucket) error {
+	if b == nil {
+		return nil
+	}
+
+	plain := make(map[string][]byte)
+
+	err := b.ForEach(func(k, v []byte) error {
+		if v != nil && !bytes.HasPrefix(v, dbSealedPrefix) {
+			plain[string(k)] = append([]byte(nil), v...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range plain {
+		sealed, err := c.seal(name, []byte(k), v)
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put([]byte(k), sealed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
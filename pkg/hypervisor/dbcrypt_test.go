@@ -0,0 +1,125 @@
+package hypervisor
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestDBCipher(t *testing.T) {
+	enc := &dbCipher{key: bytes.Repeat([]byte{1}, chacha20poly1305.KeySize)}
+
+	sealed, err := enc.seal(boltUserBucketName, []byte("admin"), []byte("value"))
+	require.NoError(t, err)
+
+	plain, err := enc.open(boltUserBucketName, []byte("admin"), sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), plain)
+
+	// Sealed values are bound to their bucket and key.
+	_, err = enc.open(boltUserBucketName, []byte("mallory"), sealed)
+	assert.Equal(t, ErrBadDBKey, err)
+
+	_, err = enc.open(boltSessionBucketName, []byte("admin"), sealed)
+	assert.Equal(t, ErrBadDBKey, err)
+
+	// Values which are not encrypted are rejected once encryption is enabled.
+	_, err = enc.open(boltUserBucketName, []byte("admin"), []byte("value"))
+	assert.True(t, errors.Is(err, ErrDBValueNotSealed))
+
+	plain, err = enc.open(boltUserBucketName, []byte("admin"), nil)
+	require.NoError(t, err)
+	assert.Nil(t, plain)
+
+	_, err = (*dbCipher)(nil).open(boltUserBucketName, []byte("admin"), sealed)
+	assert.Equal(t, ErrDBEncrypted, err)
+}
+
+func TestDBEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	path := filepath.Join(dir, "users.db")
+	pk, _ := cipher.GenerateKeyPair()
+	session := Session{SID: uuid.New(), User: "admin", Expiry: time.Now().Add(time.Hour).UTC().Round(0)}
+
+	closeStores := func(st stores) {
+		require.NoError(t, st.users.(*BoltUserStore).Close())
+	}
+
+	// Values stored before encryption is enabled are encrypted when it is.
+	st, err := openBoltStores(path, DBEncryptionConfig{})
+	require.NoError(t, err)
+	require.NoError(t, st.users.AddUser(User{Name: "admin", PwSalt: []byte("salt")}))
+	require.NoError(t, st.meta.SetMeta(pk, VisorMeta{Alias: "pi", Notes: "secret-notes"}))
+	closeStores(st)
+
+	enc := DBEncryptionConfig{Passphrase: "correct horse"}
+
+	st, err = openBoltStores(path, enc)
+	require.NoError(t, err)
+	require.NoError(t, st.sessions.AddSession(session))
+
+	user, err := st.users.User("admin")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, []byte("salt"), user.PwSalt)
+	closeStores(st)
+
+	// Nothing is stored in plain text.
+	db, err := bbolt.Open(path, ownerRW, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(tx *bbolt.Tx) error {
+		for _, name := range []string{boltUserBucketName, boltSessionBucketName, boltMetaBucketName} {
+			err := tx.Bucket([]byte(name)).ForEach(func(_, v []byte) error {
+				assert.True(t, bytes.HasPrefix(v, dbSealedPrefix), name)
+				assert.False(t, bytes.Contains(v, []byte("secret-notes")), name)
+				return nil
+			})
+			require.NoError(t, err)
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	_, err = openBoltStores(path, DBEncryptionConfig{Passphrase: "wrong"})
+	assert.Equal(t, ErrBadDBKey, err)
+
+	_, err = openBoltStores(path, DBEncryptionConfig{})
+	assert.Equal(t, ErrDBEncrypted, err)
+
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("correct horse\n"), ownerRW))
+
+	st, err = openBoltStores(path, DBEncryptionConfig{KeyFile: keyFile})
+	require.NoError(t, err)
+	defer closeStores(st)
+
+	got, err := st.sessions.Session(session.SID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, session.User, got.User)
+
+	meta, err := st.meta.Meta(pk)
+	require.NoError(t, err)
+	assert.Equal(t, "secret-notes", meta.Notes)
+
+	gotPK, err := st.meta.PubKeyByAlias("PI")
+	require.NoError(t, err)
+	assert.Equal(t, pk, gotPK)
+}
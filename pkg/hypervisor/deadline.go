@@ -0,0 +1,75 @@
+package hypervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Requests may extend their deadline up to this long by default.
+const defaultMaxRequestTimeout = 30 * time.Minute
+
+const deadlineKey = ctxKey("deadline")
+
+// Errors associated with request deadlines.
+var (
+	ErrBadTimeout     = errors.New("timeout should be a positive duration not exceeding the configured maximum")
+	ErrRequestTimeout = errors.New("request timed out")
+)
+
+// requestDeadline cancels the context of a request once it expires.
+type requestDeadline struct {
+	timer *time.Timer
+}
+
+// requestTimeout is an http middleware which cancels request contexts after timeout,
+// unless handlers extend the deadline with extendDeadline.
+func requestTimeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			d := &requestDeadline{timer: time.AfterFunc(timeout, cancel)}
+
+			defer func() {
+				d.timer.Stop()
+				cancel()
+			}()
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, deadlineKey, d)))
+		})
+	}
+}
+
+// extendDeadline replaces the deadline of the request with timeout from now, when a timeout is requested.
+// It returns the timeout remote calls of the request should use.
+func (hv *Hypervisor) extendDeadline(r *http.Request, timeout visor.Duration) (time.Duration, error) {
+	if timeout == 0 {
+		return httpTimeout, nil
+	}
+
+	if timeout < 0 || time.Duration(timeout) > hv.c.MaxRequestTimeout {
+		return 0, ErrBadTimeout
+	}
+
+	if d, ok := r.Context().Value(deadlineKey).(*requestDeadline); ok {
+		d.timer.Reset(time.Duration(timeout))
+	}
+
+	return time.Duration(timeout), nil
+}
+
+// writeDeadlineExceeded writes a 504 response if err is an RPC timeout, returning whether it did.
+func writeDeadlineExceeded(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err != visor.ErrRPCTimeout {
+		return false
+	}
+
+	httputil.WriteJSON(w, r, http.StatusGatewayTimeout, ErrRequestTimeout)
+
+	return true
+}
@@ -0,0 +1,110 @@
+package hypervisor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// timeoutRPCClient records requested timeouts, and times out "sleep" commands.
+type timeoutRPCClient struct {
+	visor.RPCClient
+	timeouts chan time.Duration
+}
+
+func (c timeoutRPCClient) Exec(command string, timeout time.Duration) ([]byte, error) {
+	c.timeouts <- timeout
+
+	if command == "sleep" {
+		return nil, visor.ErrRPCTimeout
+	}
+
+	return []byte("done"), nil
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for visorPK := range hv.visors {
+		pk = visorPK
+	}
+
+	timeouts := make(chan time.Duration, 3)
+
+	hv.mu.Lock()
+	c := hv.visors[pk]
+	c.RPC = timeoutRPCClient{RPCClient: c.RPC, timeouts: timeouts}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	uri := "/api/v1/visors/" + pk.Hex() + "/exec"
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"ls"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"ls","timeout":"10m"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"ls","timeout":"1000h"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"sleep","timeout":"1m"}`),
+			RespStatus: http.StatusGatewayTimeout,
+		},
+	})
+
+	assert.Equal(t, httpTimeout, <-timeouts)
+	assert.Equal(t, 10*time.Minute, <-timeouts)
+	assert.Equal(t, time.Minute, <-timeouts)
+}
+
+func TestRequestTimeout_Extend(t *testing.T) {
+	hv := &Hypervisor{c: Config{MaxRequestTimeout: time.Minute}}
+
+	var extended, expired bool
+
+	h := requestTimeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := hv.extendDeadline(r, visor.Duration(200*time.Millisecond))
+		require.NoError(t, err)
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			extended = true
+		}
+
+		select {
+		case <-r.Context().Done():
+			expired = true
+		case <-time.After(time.Second):
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.True(t, extended)
+	assert.True(t, expired)
+}
@@ -0,0 +1,486 @@
+package hypervisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltAppBinaryBucketName     = "app_binaries"
+	boltAppBinaryDataBucketName = "app_binary_data"
+
+	maxAppBinarySize = 256 << 20 // Maximum size of a stored app binary.
+	appFetchTimeout  = 5 * time.Minute
+)
+
+// Errors associated with app binaries.
+var (
+	ErrAppBinaryNotFound = errors.New("app binary is not found")
+	ErrAppBinaryTooLarge = fmt.Errorf("app binary should be at most %d bytes", maxAppBinarySize)
+	ErrBadAppBinaryURL   = errors.New("app binary URL should be an absolute http(s) URL")
+)
+
+// nolint: gochecknoglobals
+var appBinaryNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,63}$`)
+
+// AppBinary describes an app binary stored by the hypervisor, to be installed on visors.
+type AppBinary struct {
+	Name      string      `json:"name"`
+	Version   string      `json:"version,omitempty"`
+	Size      int         `json:"size"`
+	SHA256    string      `json:"sha256"`
+	Sig       *cipher.Sig `json:"sig,omitempty"`    // Signature of the checksum, checked by visors with trusted signers.
+	Source    string      `json:"source,omitempty"` // URL the binary was fetched from.
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// newAppBinary describes the binary data, checking it against the expected checksum if any.
+func newAppBinary(name, version, checksum string, sig *cipher.Sig, data []byte) (AppBinary, error) {
+	if !appBinaryNameRegexp.MatchString(name) {
+		return AppBinary{}, visor.ErrBadAppName
+	}
+
+	sum := sha256.Sum256(data)
+	b := AppBinary{
+		Name:      name,
+		Version:   version,
+		Size:      len(data),
+		SHA256:    hex.EncodeToString(sum[:]),
+		Sig:       sig,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if checksum != "" && !strings.EqualFold(checksum, b.SHA256) {
+		return AppBinary{}, visor.ErrAppChecksumMismatch
+	}
+
+	return b, nil
+}
+
+// AppBinaryStore stores app binaries to be installed on visors.
+type AppBinaryStore interface {
+	AppBinaries() ([]AppBinary, error)
+	AppBinary(name string) (*AppBinary, error)
+	AppBinaryData(name string) ([]byte, error)
+	SaveAppBinary(b AppBinary, data []byte) error
+	DeleteAppBinary(name string) error
+}
+
+// BoltAppBinaryStore implements AppBinaryStore, storing app binaries in a bbolt database.
+type BoltAppBinaryStore struct {
+	*bbolt.DB
+}
+
+// NewBoltAppBinaryStore creates a new BoltAppBinaryStore on top of an opened bbolt database.
+func NewBoltAppBinaryStore(db *bbolt.DB) (*BoltAppBinaryStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range []string{boltAppBinaryBucketName, boltAppBinaryDataBucketName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return &BoltAppBinaryStore{DB: db}, err
+}
+
+// AppBinaries describes all stored app binaries, sorted by name.
+func (s *BoltAppBinaryStore) AppBinaries() ([]AppBinary, error) {
+	binaries := make([]AppBinary, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltAppBinaryBucketName)).ForEach(func(_, v []byte) error {
+			var b AppBinary
+			if err := json.Unmarshal(v, &b); err != nil {
+				return err
+			}
+
+			binaries = append(binaries, b)
+
+			return nil
+		})
+	})
+
+	return binaries, err
+}
+
+// AppBinary describes the app binary of name. Returns nil if there is none.
+func (s *BoltAppBinaryStore) AppBinary(name string) (b *AppBinary, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltAppBinaryBucketName)).Get([]byte(name))
+		if raw == nil {
+			return nil
+		}
+
+		b = new(AppBinary)
+
+		return json.Unmarshal(raw, b)
+	})
+
+	return b, err
+}
+
+// AppBinaryData returns the app binary of name. Returns nil if there is none.
+func (s *BoltAppBinaryStore) AppBinaryData(name string) (data []byte, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		if raw := tx.Bucket([]byte(boltAppBinaryDataBucketName)).Get([]byte(name)); raw != nil {
+			data = append([]byte(nil), raw...)
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+// SaveAppBinary adds or replaces the app binary.
+func (s *BoltAppBinaryStore) SaveAppBinary(b AppBinary, data []byte) error {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(boltAppBinaryDataBucketName)).Put([]byte(b.Name), data); err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(boltAppBinaryBucketName)).Put([]byte(b.Name), raw)
+	})
+}
+
+// DeleteAppBinary removes the app binary of name.
+func (s *BoltAppBinaryStore) DeleteAppBinary(name string) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(boltAppBinaryDataBucketName)).Delete([]byte(name)); err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(boltAppBinaryBucketName)).Delete([]byte(name))
+	})
+}
+
+// readAppBinary reads a binary of at most maxAppBinarySize bytes.
+func readAppBinary(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxAppBinarySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxAppBinarySize {
+		return nil, ErrAppBinaryTooLarge
+	}
+
+	return data, nil
+}
+
+// fetchAppBinary downloads an app binary, e.g. from a release server.
+func fetchAppBinary(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, ErrBadAppBinaryURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close app binary response body.")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", u, resp.Status)
+	}
+
+	return readAppBinary(resp.Body)
+}
+
+// appBinaryStatus returns the HTTP status of errors of storing app binaries.
+func appBinaryStatus(err error) int {
+	switch err {
+	case visor.ErrBadAppName, visor.ErrAppChecksumMismatch, ErrBadAppBinaryURL:
+		return http.StatusBadRequest
+	case ErrAppBinaryTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (hv *Hypervisor) getAppBinaries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		binaries, err := hv.appBinaries.AppBinaries()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, binaries)
+	}
+}
+
+// postAppBinary fetches an app binary from a URL, e.g. of a release server, and stores it.
+// If 'sha256' is given, the binary has to match it.
+func (hv *Hypervisor) postAppBinary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody struct {
+			Name    string      `json:"name"`
+			URL     string      `json:"url"`
+			Version string      `json:"version,omitempty"`
+			SHA256  string      `json:"sha256,omitempty"`
+			Sig     *cipher.Sig `json:"sig,omitempty"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postAppBinary request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if !appBinaryNameRegexp.MatchString(reqBody.Name) {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, visor.ErrBadAppName)
+			return
+		}
+
+		data, err := fetchAppBinary(r.Context(), reqBody.URL)
+		if err != nil {
+			status := appBinaryStatus(err)
+			if status == http.StatusInternalServerError {
+				status = http.StatusBadGateway
+			}
+
+			httputil.WriteJSON(w, r, status, err)
+
+			return
+		}
+
+		b, err := newAppBinary(reqBody.Name, reqBody.Version, reqBody.SHA256, reqBody.Sig, data)
+		if err != nil {
+			httputil.WriteJSON(w, r, appBinaryStatus(err), err)
+			return
+		}
+
+		b.Source = reqBody.URL
+
+		if err := hv.appBinaries.SaveAppBinary(b, data); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, b)
+	}
+}
+
+// putAppBinary stores the request body as the app binary of name.
+// Optional 'version', 'sha256' and 'sig' queries describe the binary.
+func (hv *Hypervisor) putAppBinary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var sig *cipher.Sig
+
+		if s := q.Get("sig"); s != "" {
+			sig = new(cipher.Sig)
+			if err := sig.UnmarshalText([]byte(s)); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		data, err := readAppBinary(r.Body)
+		if err != nil {
+			httputil.WriteJSON(w, r, appBinaryStatus(err), err)
+			return
+		}
+
+		b, err := newAppBinary(chi.URLParam(r, "name"), q.Get("version"), q.Get("sha256"), sig, data)
+		if err != nil {
+			httputil.WriteJSON(w, r, appBinaryStatus(err), err)
+			return
+		}
+
+		if err := hv.appBinaries.SaveAppBinary(b, data); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, b)
+	}
+}
+
+func (hv *Hypervisor) getAppBinary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := hv.appBinaries.AppBinary(chi.URLParam(r, "name"))
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if b == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrAppBinaryNotFound)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, b)
+	}
+}
+
+func (hv *Hypervisor) deleteAppBinary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		b, err := hv.appBinaries.AppBinary(name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if b == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrAppBinaryNotFound)
+			return
+		}
+
+		if err := hv.appBinaries.DeleteAppBinary(name); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// postAppInstall pushes a stored app binary to the visor, which verifies it, registers the app and starts it if requested.
+// The app is named after the binary, unless 'app' is given.
+func (hv *Hypervisor) postAppInstall() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Binary    string       `json:"binary"`
+			App       string       `json:"app,omitempty"`
+			Port      routing.Port `json:"port"`
+			Args      []string     `json:"args,omitempty"`
+			AutoStart bool         `json:"autostart"`
+			Start     bool         `json:"start"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postAppInstall request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if reqBody.App == "" {
+			reqBody.App = reqBody.Binary
+		}
+
+		b, err := hv.appBinaries.AppBinary(reqBody.Binary)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if b == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrAppBinaryNotFound)
+			return
+		}
+
+		data, err := hv.appBinaries.AppBinaryData(b.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		in := visor.AppInstall{
+			App:       reqBody.App,
+			Binary:    data,
+			SHA256:    b.SHA256,
+			Port:      reqBody.Port,
+			Args:      reqBody.Args,
+			AutoStart: reqBody.AutoStart,
+			Start:     reqBody.Start,
+		}
+
+		if b.Sig != nil {
+			in.Sig = *b.Sig
+		}
+
+		if err := ctx.RPC.InstallApp(in); err != nil {
+			httputil.WriteJSON(w, r, appInstallStatus(err), err)
+			return
+		}
+
+		log.WithField("visor_pk", ctx.Addr.PK).
+			WithField("app", in.App).
+			WithField("sha256", b.SHA256).
+			Info("Installed app.")
+
+		apps, err := ctx.RPC.Apps()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, app := range apps {
+			if app.Name == in.App {
+				httputil.WriteJSON(w, r, http.StatusOK, app)
+				return
+			}
+		}
+
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, visor.ErrUnknownApp)
+	})
+}
+
+// appInstallStatus returns the HTTP status of errors returned by RPC.InstallApp.
+func appInstallStatus(err error) int {
+	switch {
+	case errors.Is(err, visor.ErrAppInstallDisabled) || errors.Is(err, visor.ErrAppNotSigned) ||
+		errors.Is(err, visor.ErrNoAppSigners):
+		return http.StatusForbidden
+	case errors.Is(err, visor.ErrBadAppName) || errors.Is(err, visor.ErrAppChecksumMismatch) ||
+		errors.Is(err, visor.ErrInvalidConfig):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
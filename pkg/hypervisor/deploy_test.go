@@ -0,0 +1,172 @@
+package hypervisor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestAppDeployment(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	const bin = "#!/bin/sh\necho hello\n"
+
+	sum := sha256.Sum256([]byte(bin))
+	checksum := hex.EncodeToString(sum[:])
+
+	release := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hello-arm64" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, err := w.Write([]byte(bin))
+		assert.NoError(t, err)
+	}))
+	defer release.Close()
+
+	binaryResp := func(name string) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var b AppBinary
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&b))
+			assert.Equal(t, name, b.Name)
+			assert.Equal(t, len(bin), b.Size)
+			assert.Equal(t, checksum, b.SHA256)
+		}
+	}
+
+	errResp := func(expected error) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			body, err := decodeErrorBody(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Error(), body.Error)
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/apps/binaries/hello?version=v1.0.0&sha256=" + checksum,
+			ReqBody:    strings.NewReader(bin),
+			RespStatus: http.StatusOK,
+			RespBody:   binaryResp("hello"),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/apps/binaries/hello?sha256=00",
+			ReqBody:    strings.NewReader(bin),
+			RespStatus: http.StatusBadRequest,
+			RespBody:   errResp(visor.ErrAppChecksumMismatch),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/apps/binaries/..",
+			ReqBody:    strings.NewReader(bin),
+			RespStatus: http.StatusBadRequest,
+			RespBody:   errResp(visor.ErrBadAppName),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/apps/binaries",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"name":"hello-arm64","url":"%s/hello-arm64","sha256":"%s"}`, release.URL, checksum)),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var b AppBinary
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&b))
+				assert.Equal(t, checksum, b.SHA256)
+				assert.Equal(t, release.URL+"/hello-arm64", b.Source)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/apps/binaries",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"name":"missing","url":"%s/missing"}`, release.URL)),
+			RespStatus: http.StatusBadGateway,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/apps/binaries",
+			ReqBody:    strings.NewReader(`{"name":"hello","url":"file:///bin/sh"}`),
+			RespStatus: http.StatusBadRequest,
+			RespBody:   errResp(ErrBadAppBinaryURL),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/apps/binaries",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var binaries []AppBinary
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&binaries))
+				require.Len(t, binaries, 2)
+				assert.Equal(t, "hello", binaries[0].Name)
+				assert.Equal(t, "v1.0.0", binaries[0].Version)
+				assert.Equal(t, "hello-arm64", binaries[1].Name)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/install", pk),
+			ReqBody:    strings.NewReader(`{"binary":"hello-arm64","app":"hello","port":20,"start":true}`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var app visor.AppState
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&app))
+				assert.Equal(t, "hello", app.Name)
+				assert.Equal(t, 20, int(app.Port))
+				assert.Equal(t, visor.AppStatusRunning, app.Status)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/install", pk),
+			ReqBody:    strings.NewReader(`{"binary":"missing"}`),
+			RespStatus: http.StatusNotFound,
+			RespBody:   errResp(ErrAppBinaryNotFound),
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/apps/binaries/hello",
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/apps/binaries/hello",
+			RespStatus: http.StatusNotFound,
+		},
+	})
+
+	apps, err := hv.visors[pk].RPC.Apps()
+	require.NoError(t, err)
+
+	var installed bool
+	for _, app := range apps {
+		installed = installed || app.Name == "hello"
+	}
+
+	assert.True(t, installed)
+}
+
+func TestAppInstallStatus(t *testing.T) {
+	assert.Equal(t, http.StatusForbidden, appInstallStatus(visor.ErrAppNotSigned))
+	assert.Equal(t, http.StatusForbidden, appInstallStatus(visor.ErrAppInstallDisabled))
+	assert.Equal(t, http.StatusBadRequest, appInstallStatus(visor.ErrAppChecksumMismatch))
+	assert.Equal(t, http.StatusBadRequest, appInstallStatus(fmt.Errorf("%w: apps a and b use the same port 1", visor.ErrInvalidConfig)))
+	assert.Equal(t, http.StatusInternalServerError, appInstallStatus(fmt.Errorf("disk full")))
+}
@@ -0,0 +1,255 @@
+package hypervisor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// App logs of the last hour are included in diagnostics bundles, unless requested otherwise.
+const defaultDiagnosticsLogsWindow = time.Hour
+
+// DiagnosticsRequest is the request body of collecting diagnostics bundles.
+type DiagnosticsRequest struct {
+	Visors    []string  `json:"visors,omitempty"`     // Visor identifiers (public key, prefix or name), defaults to all visors.
+	LogsSince time.Time `json:"logs_since,omitempty"` // Defaults to an hour ago.
+}
+
+// DiagnosticsManifest describes the contents of a multi-visor diagnostics bundle. It is included in the bundle as 'manifest.json'.
+type DiagnosticsManifest struct {
+	LogsSince time.Time                  `json:"logs_since"`
+	Visors    []VisorDiagnosticsManifest `json:"visors"`
+}
+
+// VisorDiagnosticsManifest describes the diagnostics of a single visor, which are found under '<pk>/' in the bundle.
+type VisorDiagnosticsManifest struct {
+	PK    cipher.PubKey `json:"pk"`
+	Name  string        `json:"name,omitempty"`
+	Files int           `json:"files"`
+	Error string        `json:"error,omitempty"`
+}
+
+func readDiagnosticsRequest(r *http.Request) (DiagnosticsRequest, bool) {
+	var reqBody DiagnosticsRequest
+
+	// The request body is optional.
+	if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+		log.Warnf("diagnostics request: %v", err)
+		return reqBody, false
+	}
+
+	if reqBody.LogsSince.IsZero() {
+		reqBody.LogsSince = time.Now().Add(-defaultDiagnosticsLogsWindow)
+	}
+
+	return reqBody, true
+}
+
+func writeDiagnosticsHeaders(w http.ResponseWriter, name string) {
+	filename := fmt.Sprintf("diagnostics-%s-%s.tar.gz", name, time.Now().UTC().Format(logsArchiveTimeFormat))
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+// postVisorDiagnostics responds with the diagnostics bundle gathered by a single visor.
+func (hv *Hypervisor) postVisorDiagnostics() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		reqBody, ok := readDiagnosticsRequest(r)
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		bundle, err := ctx.RPC.Diagnostics(reqBody.LogsSince)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeDiagnosticsHeaders(w, ctx.Addr.PK.String())
+
+		if _, err := w.Write(bundle); err != nil {
+			log.WithError(err).Warn("Failed to write diagnostics bundle.")
+		}
+	})
+}
+
+// postDiagnostics gathers diagnostics bundles of multiple visors in parallel,
+// and responds with a single gzipped tar archive containing the files of each visor under '<pk>/'.
+func (hv *Hypervisor) postDiagnostics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqBody, ok := readDiagnosticsRequest(r)
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		pks := make([]cipher.PubKey, 0, len(reqBody.Visors))
+
+		for _, id := range reqBody.Visors {
+			pk, status, err := hv.resolveVisor(id)
+			if err != nil {
+				httputil.WriteJSON(w, r, status, err)
+				return
+			}
+
+			pks = append(pks, pk)
+		}
+
+		if len(pks) == 0 {
+			hv.mu.RLock()
+			for pk := range hv.visors {
+				pks = append(pks, pk)
+			}
+			hv.mu.RUnlock()
+		}
+
+		names, err := hv.names.Names()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		bundles := make([][]byte, len(pks))
+		manifest := DiagnosticsManifest{LogsSince: reqBody.LogsSince, Visors: make([]VisorDiagnosticsManifest, len(pks))}
+
+		var wg sync.WaitGroup
+
+		wg.Add(len(pks))
+
+		for i, pk := range pks {
+			go func(i int, pk cipher.PubKey) {
+				defer wg.Done()
+
+				manifest.Visors[i] = VisorDiagnosticsManifest{PK: pk, Name: names[pk]}
+
+				conn, ok := hv.visorConn(pk)
+				if !ok {
+					manifest.Visors[i].Error = fmt.Sprintf("visor of pk '%s' not found", pk)
+					return
+				}
+
+				bundle, err := conn.RPC.Diagnostics(reqBody.LogsSince)
+				if err != nil {
+					manifest.Visors[i].Error = err.Error()
+					return
+				}
+
+				bundles[i] = bundle
+			}(i, pk)
+		}
+
+		wg.Wait()
+
+		writeDiagnosticsHeaders(w, "visors")
+
+		if err := writeDiagnosticsBundle(w, manifest, bundles); err != nil {
+			log.WithError(err).Warn("Failed to write diagnostics bundle.")
+		}
+	}
+}
+
+// writeDiagnosticsBundle merges the bundles of visors into a single gzipped tar archive with 'manifest.json'.
+func writeDiagnosticsBundle(w io.Writer, manifest DiagnosticsManifest, bundles [][]byte) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for i, bundle := range bundles {
+		if bundle == nil {
+			continue
+		}
+
+		files, err := readTarGz(bundle)
+		if err != nil {
+			manifest.Visors[i].Error = fmt.Sprintf("malformed bundle: %v", err)
+			continue
+		}
+
+		for _, f := range files {
+			f.hdr.Name = manifest.Visors[i].PK.String() + "/" + f.hdr.Name
+
+			if err := tw.WriteHeader(f.hdr); err != nil {
+				return err
+			}
+
+			if _, err := tw.Write(f.data); err != nil {
+				return err
+			}
+		}
+
+		manifest.Visors[i].Files = len(files)
+	}
+
+	rawManifest, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Name:    "manifest.json",
+		Mode:    0644,
+		Size:    int64(len(rawManifest)),
+		ModTime: time.Now(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if _, err := tw.Write(rawManifest); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+type tarFile struct {
+	hdr  *tar.Header
+	data []byte
+}
+
+// readTarGz reads all files of a gzipped tar archive.
+func readTarGz(archive []byte) ([]tarFile, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tr    = tar.NewReader(gr)
+		files []tarFile
+	)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, tarFile{hdr: hdr, data: data})
+	}
+}
@@ -0,0 +1,80 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	readBundle := func(t *testing.T, r *http.Response) map[string][]byte {
+		assert.Equal(t, "application/gzip", r.Header.Get("Content-Type"))
+		assert.Contains(t, r.Header.Get("Content-Disposition"), "diagnostics-")
+
+		raw, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		tarFiles, err := readTarGz(raw)
+		require.NoError(t, err)
+
+		files := make(map[string][]byte, len(tarFiles))
+		for _, f := range tarFiles {
+			files[f.hdr.Name] = f.data
+		}
+
+		return files
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/diagnostics", pks[0]),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				files := readBundle(t, r)
+				assert.Contains(t, files, "config.json")
+				assert.Contains(t, files, "summary.json")
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/diagnostics",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"visors":["%s","%s"]}`, pks[0], pks[1])),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				files := readBundle(t, r)
+				assert.Contains(t, files, pks[0].String()+"/summary.json")
+				assert.Contains(t, files, pks[1].String()+"/summary.json")
+				assert.NotContains(t, files, pks[2].String()+"/summary.json")
+
+				var manifest DiagnosticsManifest
+				require.NoError(t, json.NewDecoder(bytes.NewReader(files["manifest.json"])).Decode(&manifest))
+				require.Len(t, manifest.Visors, 2)
+				assert.Empty(t, manifest.Visors[0].Error)
+				assert.NotZero(t, manifest.Visors[0].Files)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/diagnostics",
+			ReqBody:    strings.NewReader(`{"visors":`),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,522 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltProfileBucketName = "config_profiles"
+)
+
+// Errors associated with config profiles.
+var (
+	ErrProfileNotFound    = errors.New("config profile is not found")
+	ErrProfileExists      = errors.New("config profile already exists")
+	ErrBadProfileName     = errors.New("config profile name should be 1 to 64 alphanumeric chars, '.', '_' or '-'")
+	ErrNoProfileTarget    = errors.New("config profile should target visors, labels or both")
+	ErrBadProfileConfig   = errors.New("config profile should hold a JSON object of visor config fields")
+	ErrProfileNameMutated = errors.New("config profile name cannot be changed")
+)
+
+// nolint: gochecknoglobals
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// ConfigProfile is the expected (partial) visor config of a group of visors.
+// Only the fields present in Config are compared against the effective config of visors.
+// The visors are given by identifiers (see resolveVisor) and/or a label selector.
+type ConfigProfile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Visors      []string          `json:"visors,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Config      json.RawMessage   `json:"config"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+func (p ConfigProfile) check() error {
+	if !profileNameRegexp.MatchString(p.Name) {
+		return ErrBadProfileName
+	}
+
+	if len(p.Visors) == 0 && len(p.Labels) == 0 {
+		return ErrNoProfileTarget
+	}
+
+	if err := checkLabels(p.Labels); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(p.Config, &fields); err != nil || fields == nil {
+		return ErrBadProfileConfig
+	}
+
+	// Catch misspelled fields and values of wrong types.
+	dec := json.NewDecoder(bytes.NewReader(p.Config))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(new(visor.Config)); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadProfileConfig, err)
+	}
+
+	return nil
+}
+
+// ProfileStore stores config profiles.
+type ProfileStore interface {
+	Profiles() ([]ConfigProfile, error)
+	Profile(name string) (*ConfigProfile, error)
+	SetProfile(p ConfigProfile) error
+	DeleteProfile(name string) error
+}
+
+// BoltProfileStore implements ProfileStore, storing config profiles in a bbolt database.
+type BoltProfileStore struct {
+	*bbolt.DB
+}
+
+// NewBoltProfileStore creates a new BoltProfileStore on top of an opened bbolt database.
+func NewBoltProfileStore(db *bbolt.DB) (*BoltProfileStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltProfileBucketName))
+		return err
+	})
+
+	return &BoltProfileStore{DB: db}, err
+}
+
+// Profiles returns all config profiles, ordered by name.
+func (s *BoltProfileStore) Profiles() ([]ConfigProfile, error) {
+	profiles := make([]ConfigProfile, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltProfileBucketName)).ForEach(func(_, v []byte) error {
+			var p ConfigProfile
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+
+			profiles = append(profiles, p)
+
+			return nil
+		})
+	})
+
+	return profiles, err
+}
+
+// Profile returns the config profile of name. Returns nil if there is none.
+func (s *BoltProfileStore) Profile(name string) (p *ConfigProfile, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltProfileBucketName)).Get([]byte(name))
+		if raw == nil {
+			return nil
+		}
+
+		p = new(ConfigProfile)
+
+		return json.Unmarshal(raw, p)
+	})
+
+	return p, err
+}
+
+// SetProfile adds or replaces the config profile.
+func (s *BoltProfileStore) SetProfile(p ConfigProfile) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltProfileBucketName)).Put([]byte(p.Name), raw)
+	})
+}
+
+// DeleteProfile removes the config profile of name.
+func (s *BoltProfileStore) DeleteProfile(name string) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltProfileBucketName)).Delete([]byte(name))
+	})
+}
+
+// ConfigDeviation is a visor config field which differs from its config profile.
+type ConfigDeviation struct {
+	Profile  string          `json:"profile"`
+	Path     string          `json:"path"` // Dot-separated path of the field. Arrays are compared as a whole.
+	Expected json.RawMessage `json:"expected"`
+	Actual   json.RawMessage `json:"actual,omitempty"` // Unset if the field is missing.
+	Missing  bool            `json:"missing,omitempty"`
+}
+
+// VisorDrift is the config drift of a single visor.
+type VisorDrift struct {
+	PK         cipher.PubKey     `json:"pk"`
+	Name       string            `json:"name,omitempty"`
+	Profiles   []string          `json:"profiles"`
+	Drifted    bool              `json:"drifted"`
+	Deviations []ConfigDeviation `json:"deviations"`
+	Error      string            `json:"error,omitempty"` // Set if the config could not be obtained.
+}
+
+// DriftReport lists the config drift of visors with assigned config profiles.
+type DriftReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Visors      []VisorDrift    `json:"visors"`
+	Drifted     int             `json:"drifted"`     // Number of visors deviating from their profiles.
+	Unreachable int             `json:"unreachable"` // Number of visors whose config could not be obtained.
+	Unassigned  []cipher.PubKey `json:"unassigned"`  // Connected visors without a config profile.
+
+	// Errors of profiles whose visors could not be selected, such as identifiers of removed visor names.
+	ProfileErrors map[string]string `json:"profile_errors,omitempty"`
+}
+
+// configDeviations compares the expected config value against the actual one, recursing into JSON objects.
+func configDeviations(profile, path string, expected, actual interface{}, found bool) []ConfigDeviation {
+	if exp, ok := expected.(map[string]interface{}); ok {
+		if act, ok := actual.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(exp))
+			for k := range exp {
+				keys = append(keys, k)
+			}
+
+			sort.Strings(keys)
+
+			var out []ConfigDeviation
+
+			for _, k := range keys {
+				v, found := act[k]
+				out = append(out, configDeviations(profile, joinConfigPath(path, k), exp[k], v, found)...)
+			}
+
+			return out
+		}
+	}
+
+	if found && reflect.DeepEqual(expected, actual) {
+		return nil
+	}
+
+	d := ConfigDeviation{Profile: profile, Path: path, Missing: !found}
+	d.Expected, _ = json.Marshal(expected) // nolint: errcheck
+
+	if found {
+		d.Actual, _ = json.Marshal(actual) // nolint: errcheck
+	}
+
+	return []ConfigDeviation{d}
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// visorDrift compares the effective config of the visor of pk against its config profiles.
+func (hv *Hypervisor) visorDrift(pk cipher.PubKey, profiles []ConfigProfile) VisorDrift {
+	d := VisorDrift{PK: pk, Profiles: make([]string, 0, len(profiles)), Deviations: make([]ConfigDeviation, 0)}
+
+	for _, p := range profiles {
+		d.Profiles = append(d.Profiles, p.Name)
+	}
+
+	conn, ok := hv.visorConn(pk)
+	if !ok {
+		d.Error = ErrVisorNotConnected.Error()
+		return d
+	}
+
+	raw, err := conn.RPC.Config()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	var actual interface{}
+	if err := json.Unmarshal(raw, &actual); err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	for _, p := range profiles {
+		var expected interface{}
+		if err := json.Unmarshal(p.Config, &expected); err != nil {
+			d.Error = fmt.Sprintf("profile %s: %v", p.Name, err)
+			return d
+		}
+
+		d.Deviations = append(d.Deviations, configDeviations(p.Name, "", expected, actual, true)...)
+	}
+
+	d.Drifted = len(d.Deviations) > 0
+
+	return d
+}
+
+// driftReport compares the effective config of all visors with assigned config profiles against them.
+// A visor may be assigned multiple profiles, in which case it is compared against each.
+func (hv *Hypervisor) driftReport() (*DriftReport, error) {
+	profiles, err := hv.profiles.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{
+		GeneratedAt:   time.Now().UTC(),
+		Unassigned:    make([]cipher.PubKey, 0),
+		ProfileErrors: make(map[string]string),
+	}
+
+	assigned := make(map[cipher.PubKey][]ConfigProfile)
+
+	for _, p := range profiles {
+		pks, _, err := hv.selectVisors(p.Visors, p.Labels)
+		if err != nil {
+			report.ProfileErrors[p.Name] = err.Error()
+			continue
+		}
+
+		for _, pk := range pks {
+			assigned[pk] = append(assigned[pk], p)
+		}
+	}
+
+	names, err := hv.names.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	report.Visors = make([]VisorDrift, 0, len(assigned))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	wg.Add(len(assigned))
+
+	for pk, profiles := range assigned {
+		go func(pk cipher.PubKey, profiles []ConfigProfile) {
+			defer wg.Done()
+
+			d := hv.visorDrift(pk, profiles)
+			d.Name = names[pk]
+
+			mu.Lock()
+			report.Visors = append(report.Visors, d)
+			mu.Unlock()
+		}(pk, profiles)
+	}
+
+	wg.Wait()
+
+	sort.Slice(report.Visors, func(i, j int) bool { return report.Visors[i].PK.Hex() < report.Visors[j].PK.Hex() })
+
+	for _, d := range report.Visors {
+		if d.Drifted {
+			report.Drifted++
+		}
+
+		if d.Error != "" {
+			report.Unreachable++
+		}
+	}
+
+	hv.mu.RLock()
+	for pk := range hv.visors {
+		if _, ok := assigned[pk]; !ok {
+			report.Unassigned = append(report.Unassigned, pk)
+		}
+	}
+	hv.mu.RUnlock()
+
+	sort.Slice(report.Unassigned, func(i, j int) bool { return report.Unassigned[i].Hex() < report.Unassigned[j].Hex() })
+
+	return report, nil
+}
+
+// getDriftReport responds with the config drift report.
+// If the 'drifted' query is true, only visors deviating from their profiles or unreachable are listed.
+func (hv *Hypervisor) getDriftReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var onlyDrifted bool
+
+		if q := r.URL.Query().Get("drifted"); q != "" {
+			var err error
+			if onlyDrifted, err = strconv.ParseBool(q); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		report, err := hv.driftReport()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if onlyDrifted {
+			visors := make([]VisorDrift, 0, report.Drifted+report.Unreachable)
+
+			for _, d := range report.Visors {
+				if d.Drifted || d.Error != "" {
+					visors = append(visors, d)
+				}
+			}
+
+			report.Visors = visors
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, report)
+	}
+}
+
+func (hv *Hypervisor) getProfiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profiles, err := hv.profiles.Profiles()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, profiles)
+	}
+}
+
+// profile obtains the config profile of the 'name' URL parameter, writing an error response if it fails.
+func (hv *Hypervisor) profile(w http.ResponseWriter, r *http.Request) (*ConfigProfile, bool) {
+	p, err := hv.profiles.Profile(chi.URLParam(r, "name"))
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	if p == nil {
+		httputil.WriteJSON(w, r, http.StatusNotFound, ErrProfileNotFound)
+		return nil, false
+	}
+
+	return p, true
+}
+
+func (hv *Hypervisor) getProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := hv.profile(w, r); ok {
+			httputil.WriteJSON(w, r, http.StatusOK, p)
+		}
+	}
+}
+
+func (hv *Hypervisor) postProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p ConfigProfile
+
+		if err := httputil.ReadJSON(r, &p); err != nil {
+			if err != io.EOF {
+				log.Warnf("postProfile request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		old, err := hv.profiles.Profile(p.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if old != nil {
+			httputil.WriteJSON(w, r, http.StatusConflict, ErrProfileExists)
+			return
+		}
+
+		hv.saveProfile(w, r, p)
+	}
+}
+
+func (hv *Hypervisor) putProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		old, ok := hv.profile(w, r)
+		if !ok {
+			return
+		}
+
+		var p ConfigProfile
+
+		if err := httputil.ReadJSON(r, &p); err != nil {
+			if err != io.EOF {
+				log.Warnf("putProfile request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if p.Name == "" {
+			p.Name = old.Name
+		}
+
+		if p.Name != old.Name {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrProfileNameMutated)
+			return
+		}
+
+		hv.saveProfile(w, r, p)
+	}
+}
+
+func (hv *Hypervisor) saveProfile(w http.ResponseWriter, r *http.Request, p ConfigProfile) {
+	if err := p.check(); err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	p.UpdatedAt = time.Now().UTC()
+
+	if err := hv.profiles.SetProfile(p); err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	httputil.WriteJSON(w, r, http.StatusOK, p)
+}
+
+func (hv *Hypervisor) deleteProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := hv.profile(w, r)
+		if !ok {
+			return
+		}
+
+		if err := hv.profiles.DeleteProfile(p.Name); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
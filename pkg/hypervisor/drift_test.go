@@ -0,0 +1,140 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftReport(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.NoError(t, hv.labels.SetLabels(pks[0], map[string]string{"site": "berlin"}))
+	require.NoError(t, hv.labels.SetLabels(pks[1], map[string]string{"site": "berlin"}))
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(`{"name":"berlin","labels":{"site":"berlin"},"config":{"log_levle":"info"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(`{"name":"berlin","labels":{"site":"berlin"},"config":[]}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(`{"name":"berlin","config":{"log_level":"info"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(`{"name":"berlin","labels":{"site":"berlin"},"config":{"log_level":"info","version":"1.0"}}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(`{"name":"berlin","labels":{"site":"berlin"},"config":{}}`),
+			RespStatus: http.StatusConflict,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/config-profiles",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"name":"locked","visors":[%q],"config":{"log_level":"debug","exec":{"disabled":true}}}`, pks[1])),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/reports/drift",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var report DriftReport
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+
+				require.Len(t, report.Visors, 2)
+				assert.Equal(t, 1, report.Drifted)
+				assert.Equal(t, []cipher.PubKey{pks[2]}, report.Unassigned)
+
+				for _, d := range report.Visors {
+					if d.PK == pks[0] {
+						assert.Equal(t, []string{"berlin"}, d.Profiles)
+						assert.False(t, d.Drifted)
+						assert.Empty(t, d.Deviations)
+
+						continue
+					}
+
+					assert.Equal(t, pks[1], d.PK)
+					assert.Equal(t, []string{"berlin", "locked"}, d.Profiles)
+					assert.True(t, d.Drifted)
+					require.Len(t, d.Deviations, 2)
+
+					assert.Equal(t, "exec", d.Deviations[0].Path)
+					assert.True(t, d.Deviations[0].Missing)
+					assert.JSONEq(t, `{"disabled":true}`, string(d.Deviations[0].Expected))
+
+					assert.Equal(t, "log_level", d.Deviations[1].Path)
+					assert.JSONEq(t, `"debug"`, string(d.Deviations[1].Expected))
+					assert.JSONEq(t, `"info"`, string(d.Deviations[1].Actual))
+				}
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/reports/drift?drifted=true",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var report DriftReport
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+				require.Len(t, report.Visors, 1)
+				assert.Equal(t, pks[1], report.Visors[0].PK)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/config-profiles/locked",
+			ReqBody:    strings.NewReader(`{"name":"renamed","labels":{"site":"berlin"},"config":{}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/config-profiles/locked",
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/config-profiles/locked",
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestConfigDeviations(t *testing.T) {
+	var expected, actual interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":1,"c":[1,2]},"d":"x"}`), &expected))
+	require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":1,"c":[2,1]},"d":{"e":"x"}}`), &actual))
+
+	devs := configDeviations("p", "", expected, actual, true)
+	require.Len(t, devs, 2)
+	assert.Equal(t, "a.c", devs[0].Path)
+	assert.Equal(t, "d", devs[1].Path)
+	assert.JSONEq(t, `{"e":"x"}`, string(devs[1].Actual))
+}
@@ -0,0 +1,136 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/rpc"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/dmsg/httputil"
+	"nhooyr.io/websocket"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// eventsPollTimeout is how long a single Events call waits for events of the visor.
+const eventsPollTimeout = 30 * time.Second
+
+// eventWatchKinds are the watched collections changed by the kinds of visor events.
+var eventWatchKinds = map[string]string{ // nolint: gochecknoglobals
+	visor.EventTransportUp:   watchTransports,
+	visor.EventTransportDown: watchTransports,
+	visor.EventRouteAdded:    watchRoutes,
+	visor.EventAppCrashed:    ChangeApp,
+	visor.EventUpdateApplied: ChangeVisor,
+}
+
+// followEvents subscribes to the events of the visor for as long as it is connected, and makes the collections
+// changed by them be polled right away, so that watches and the change feed don't wait for the next poll.
+// Visors which don't publish events are polled as before.
+func (hv *Hypervisor) followEvents(c VisorConn) {
+	log := log.WithField("visor_pk", c.Addr.PK)
+
+	var cursor uint64
+
+	for {
+		hv.mu.RLock()
+		current, ok := hv.visors[c.Addr.PK]
+		hv.mu.RUnlock()
+
+		if !ok || current.RPC != c.RPC {
+			return
+		}
+
+		out, err := c.RPC.Events(cursor, nil, eventsPollTimeout)
+		switch {
+		case err == rpc.ErrShutdown:
+			return
+		case err != nil && strings.Contains(err.Error(), "can't find method"):
+			log.Debug("Visor does not publish events.")
+			return
+		case err != nil:
+			log.WithError(err).Debug("Failed to get visor events.")
+			time.Sleep(eventsPollTimeout / 10)
+
+			continue
+		}
+
+		cursor = out.Cursor
+
+		for _, e := range out.Events {
+			hv.watches.poke(c.Addr.PK, eventWatchKinds[e.Kind])
+		}
+	}
+}
+
+// eventStream streams the events of the visor over a WebSocket, optionally filtered by the 'kind' query values.
+// Each message is a batch of events, with the cursor to resume from (the 'cursor' query) if the stream is broken,
+// and the number of events missed since the given cursor.
+func (hv *Hypervisor) eventStream() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		q := r.URL.Query()
+		kinds := q["kind"]
+
+		var cursor uint64
+
+		if c := q.Get("cursor"); c != "" {
+			var err error
+			if cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+		}
+
+		// The first call validates the kinds, before the stream is accepted.
+		out, err := ctx.RPC.Events(cursor, kinds, 0)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, visor.ErrMalformedRequest) {
+				status = http.StatusBadRequest
+			}
+
+			httputil.WriteJSON(w, r, status, err)
+
+			return
+		}
+
+		log := log.WithField("visor_pk", ctx.Addr.PK)
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			log.WithError(err).Warn("Failed to accept event stream.")
+			return
+		}
+
+		// The client is not expected to send anything, the context is cancelled once it goes away.
+		wsCtx := conn.CloseRead(r.Context())
+
+		for {
+			if len(out.Events) > 0 || out.Missed > 0 {
+				raw, err := json.Marshal(out)
+				if err == nil {
+					err = conn.Write(wsCtx, websocket.MessageText, raw)
+				}
+
+				if err != nil {
+					_ = conn.Close(websocket.StatusNormalClosure, "") // nolint: errcheck
+					return
+				}
+			}
+
+			if wsCtx.Err() != nil {
+				return
+			}
+
+			if out, err = ctx.RPC.Events(out.Cursor, kinds, eventsPollTimeout); err != nil {
+				log.WithError(err).Warn("Failed to get visor events.")
+				_ = conn.Close(websocket.StatusInternalError, "failed to get events") // nolint: errcheck
+
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestEventStream(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	streamURI := fmt.Sprintf("/api/v1/visors/%s/events?kind=%s", pk, visor.EventRouteAdded)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/events?kind=unknown", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/events?cursor=first", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "wss://"+addr+streamURI, &websocket.DialOptions{HTTPClient: client})
+	require.NoError(t, err)
+
+	defer conn.Close(websocket.StatusNormalClosure, "") // nolint: errcheck
+
+	rpc := hv.visors[pk].RPC
+
+	_, err = rpc.AddTransport(pk, "dmsg", false, 0)
+	require.NoError(t, err)
+
+	rule := routing.IntermediaryForwardRule(time.Minute, 1000, 2000, uuid.New())
+	require.NoError(t, rpc.SaveRoutingRule(rule))
+
+	_, raw, err := conn.Read(ctx)
+	require.NoError(t, err)
+
+	var out visor.EventsOut
+	require.NoError(t, json.Unmarshal(raw, &out))
+
+	// The transport event is filtered out.
+	require.Len(t, out.Events, 1)
+	assert.Equal(t, visor.EventRouteAdded, out.Events[0].Kind)
+	assert.Equal(t, uint64(2), out.Cursor)
+
+	var summary routing.RuleSummary
+	require.NoError(t, json.Unmarshal(out.Events[0].Data, &summary))
+	assert.Equal(t, routing.RouteID(1000), summary.KeyRouteID)
+}
@@ -0,0 +1,152 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/httputil"
+	"nhooyr.io/websocket"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Types of exec stream messages.
+const (
+	// Sent by the hypervisor.
+	execMsgStdout = "stdout"
+	execMsgStderr = "stderr"
+	execMsgExit   = "exit"
+	execMsgError  = "error"
+
+	// Sent by the client.
+	execMsgStdin      = "stdin"
+	execMsgCloseStdin = "close_stdin"
+	execMsgCancel     = "cancel"
+)
+
+// ExecMessage is a JSON message of the exec stream, in either direction.
+type ExecMessage struct {
+	Type  string `json:"type"`
+	Data  string `json:"data,omitempty"`  // Output (stdout, stderr) or input (stdin).
+	Code  int    `json:"code"`            // Exit code (exit).
+	Error string `json:"error,omitempty"` // Error (error).
+}
+
+// execStream runs the command of the 'command' query value on the visor and streams its output over a WebSocket.
+// The client may send stdin, close stdin or cancel the command. The stream ends with an 'exit' or 'error' message.
+func (hv *Hypervisor) execStream() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		command := r.URL.Query().Get("command")
+		if command == "" {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		id, err := ctx.RPC.ExecStart(command)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err.Error() == visor.ErrExecDisabled.Error() || err.Error() == visor.ErrExecNotAllowed.Error() {
+				status = http.StatusForbidden
+			}
+
+			httputil.WriteJSON(w, r, status, err)
+
+			return
+		}
+
+		log := log.WithField("visor_pk", ctx.Addr.PK).WithField("session_id", id)
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			log.WithError(err).Warn("Failed to accept exec stream.")
+			hv.stopExec(ctx.RPC, id)
+
+			return
+		}
+
+		defer hv.activity.begin(ctx.Addr.PK, ActivityExec, r)()
+
+		wsCtx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		go func() {
+			defer cancel()
+
+			for {
+				_, raw, err := conn.Read(wsCtx)
+				if err != nil {
+					return
+				}
+
+				var msg ExecMessage
+				if err := json.Unmarshal(raw, &msg); err != nil {
+					log.WithError(err).Warn("Malformed exec stream message.")
+					continue
+				}
+
+				switch msg.Type {
+				case execMsgStdin:
+					err = ctx.RPC.ExecWrite(id, []byte(msg.Data), false)
+				case execMsgCloseStdin:
+					err = ctx.RPC.ExecWrite(id, nil, true)
+				case execMsgCancel:
+					return
+				}
+
+				if err != nil {
+					log.WithError(err).Warn("Failed to write to exec stdin.")
+				}
+			}
+		}()
+
+		write := func(msg ExecMessage) error {
+			raw, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+
+			return conn.Write(wsCtx, websocket.MessageText, raw)
+		}
+
+		for {
+			out, err := ctx.RPC.ExecRead(id)
+			if err != nil {
+				_ = write(ExecMessage{Type: execMsgError, Error: err.Error()}) // nolint: errcheck
+				_ = conn.Close(websocket.StatusInternalError, "exec failed")   // nolint: errcheck
+
+				return
+			}
+
+			if len(out.Stdout) > 0 {
+				err = write(ExecMessage{Type: execMsgStdout, Data: string(out.Stdout)})
+			}
+
+			if err == nil && len(out.Stderr) > 0 {
+				err = write(ExecMessage{Type: execMsgStderr, Data: string(out.Stderr)})
+			}
+
+			if err == nil && out.Exited {
+				if err = write(ExecMessage{Type: execMsgExit, Code: out.ExitCode}); err == nil {
+					_ = conn.Close(websocket.StatusNormalClosure, "") // nolint: errcheck
+					return
+				}
+			}
+
+			if err != nil || wsCtx.Err() != nil {
+				// The client went away or cancelled the command.
+				hv.stopExec(ctx.RPC, id)
+				_ = conn.Close(websocket.StatusNormalClosure, "cancelled") // nolint: errcheck
+
+				return
+			}
+		}
+	})
+}
+
+func (hv *Hypervisor) stopExec(rpc visor.RPCClient, id uuid.UUID) {
+	if err := rpc.ExecStop(id); err != nil {
+		log.WithError(err).WithField("session_id", id).Warn("Failed to stop exec session.")
+	}
+}
@@ -0,0 +1,85 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func (execRestrictedRPCClient) ExecStart(string) (uuid.UUID, error) {
+	return uuid.UUID{}, errors.New(visor.ErrExecDisabled.Error())
+}
+
+func TestExecStream(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	hv.mu.Lock()
+	c := hv.visors[pks[1]]
+	c.RPC = execRestrictedRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[1]] = c
+	hv.mu.Unlock()
+
+	streamURI := func(pk cipher.PubKey, command string) string {
+		return fmt.Sprintf("/api/v1/visors/%s/exec/stream?command=%s", pk, url.QueryEscape(command))
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/exec/stream", pks[0]),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     streamURI(pks[1], "uptime"),
+			RespStatus: http.StatusForbidden,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "wss://"+addr+streamURI(pks[0], "uptime -p"), &websocket.DialOptions{HTTPClient: client})
+	require.NoError(t, err)
+
+	defer conn.Close(websocket.StatusNormalClosure, "") // nolint: errcheck
+
+	var msgs []ExecMessage
+
+	for {
+		_, raw, err := conn.Read(ctx)
+		if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+			break
+		}
+
+		require.NoError(t, err)
+
+		var msg ExecMessage
+		require.NoError(t, json.Unmarshal(raw, &msg))
+		msgs = append(msgs, msg)
+	}
+
+	assert.Equal(t, []ExecMessage{
+		{Type: execMsgStdout, Data: "mock"},
+		{Type: execMsgExit, Code: 0},
+	}, msgs)
+}
@@ -0,0 +1,262 @@
+package hypervisor
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Labels holding the geolocation of visors, in decimal degrees.
+const (
+	LabelGeoLat = "geo/lat"
+	LabelGeoLon = "geo/lon"
+)
+
+const (
+	// Weight of new samples in the moving average of RPC latencies.
+	latencySmoothing = 0.3
+
+	// Round trip time over fiber, per km of distance between visors.
+	fiberRTTPerKm = 10 * time.Microsecond
+
+	earthRadiusKm = 6371
+)
+
+// Errors associated with exit suggestions.
+var (
+	ErrNoExitFound   = errors.New("no exit visor with known latency or location is available")
+	ErrNoSocksClient = errors.New("visor has no skysocks-client app to apply the exit to")
+	ErrBadSortKey    = errors.New("visors can only be sorted by 'latency'")
+)
+
+// rpcLatencies keeps a moving average of the RPC round trip times of the connected visors.
+type rpcLatencies struct {
+	mu   sync.Mutex
+	avgs map[cipher.PubKey]time.Duration
+}
+
+func newRPCLatencies() *rpcLatencies {
+	return &rpcLatencies{avgs: make(map[cipher.PubKey]time.Duration)}
+}
+
+func (l *rpcLatencies) record(pk cipher.PubKey, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if avg, ok := l.avgs[pk]; ok {
+		d = time.Duration(latencySmoothing*float64(d) + (1-latencySmoothing)*float64(avg))
+	}
+
+	l.avgs[pk] = d
+}
+
+func (l *rpcLatencies) get(pk cipher.PubKey) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.avgs[pk]
+
+	return d, ok
+}
+
+// durationMs converts d to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// sortSummariesByLatency sorts visors by ascending RPC latency, placing visors of unknown latency last.
+func sortSummariesByLatency(summaries []summaryResp) {
+	sort.SliceStable(summaries, func(i, j int) bool {
+		li, lj := summaries[i].LatencyMs, summaries[j].LatencyMs
+		if li == 0 || lj == 0 {
+			return lj == 0 && li != 0
+		}
+
+		return li < lj
+	})
+}
+
+// geoLocation returns the location of a visor from its labels.
+func geoLocation(labels map[string]string) (lat, lon float64, ok bool) {
+	lat, err := strconv.ParseFloat(labels[LabelGeoLat], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+
+	lon, err = strconv.ParseFloat(labels[LabelGeoLon], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+// distanceKm returns the great-circle distance between two locations.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat, dLon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// ExitCandidate is a visor serving skysocks, scored as an exit of a client visor.
+type ExitCandidate struct {
+	PK           cipher.PubKey `json:"pk"`
+	Name         string        `json:"name,omitempty"`
+	Alias        string        `json:"alias,omitempty"`
+	LatencyMs    float64       `json:"rpc_latency_ms,omitempty"` // RPC latency between the hypervisor and the exit.
+	DistanceKm   float64       `json:"distance_km,omitempty"`    // Distance between the client and the exit.
+	EstimatedRTT float64       `json:"estimated_rtt_ms"`         // Score: the sum of the latency and the round trip time over the distance.
+}
+
+// ExitSuggestion is the best exit of a client visor, and all scored candidates.
+type ExitSuggestion struct {
+	For        cipher.PubKey   `json:"for"`
+	Exit       ExitCandidate   `json:"exit"`
+	Candidates []ExitCandidate `json:"candidates"`
+	Applied    bool            `json:"applied"`
+}
+
+// suggestExit scores connected visors running skysocks as exits of the client visor of pk.
+// The hypervisor's RPC latency to an exit stands in for the client's latency to it, and is
+// added to the round trip time over fiber along the distance between the client and the exit,
+// when both have 'geo/lat' and 'geo/lon' labels. Exits with neither are not scored.
+func (hv *Hypervisor) suggestExit(pk cipher.PubKey) (ExitSuggestion, *summaryResp, error) {
+	summaries, err := hv.visorSummaries()
+	if err != nil {
+		return ExitSuggestion{}, nil, err
+	}
+
+	labels, err := hv.labels.AllLabels()
+	if err != nil {
+		return ExitSuggestion{}, nil, err
+	}
+
+	var client *summaryResp
+
+	for i := range summaries {
+		if summaries[i].PubKey == pk {
+			client = &summaries[i]
+		}
+	}
+
+	if client == nil {
+		return ExitSuggestion{}, nil, ErrVisorNotConnected
+	}
+
+	clientLat, clientLon, clientLocated := geoLocation(labels[pk])
+	s := ExitSuggestion{For: pk, Candidates: make([]ExitCandidate, 0)}
+
+	for _, sum := range summaries {
+		if sum.PubKey == pk || !sum.Online || !runsApp(sum.Summary, skyenv.SkysocksName) {
+			continue
+		}
+
+		c := ExitCandidate{PK: sum.PubKey, Name: sum.Name, Alias: sum.Alias, LatencyMs: sum.LatencyMs}
+		scored := c.LatencyMs > 0
+
+		if lat, lon, ok := geoLocation(labels[sum.PubKey]); ok && clientLocated {
+			c.DistanceKm = distanceKm(clientLat, clientLon, lat, lon)
+			scored = true
+		}
+
+		if !scored {
+			continue
+		}
+
+		c.EstimatedRTT = c.LatencyMs + c.DistanceKm*durationMs(fiberRTTPerKm)
+		s.Candidates = append(s.Candidates, c)
+	}
+
+	if len(s.Candidates) == 0 {
+		return ExitSuggestion{}, client, ErrNoExitFound
+	}
+
+	sort.SliceStable(s.Candidates, func(i, j int) bool {
+		return s.Candidates[i].EstimatedRTT < s.Candidates[j].EstimatedRTT
+	})
+
+	s.Exit = s.Candidates[0]
+
+	return s, client, nil
+}
+
+func runsApp(summary *visor.Summary, name string) bool {
+	for _, app := range summary.Apps {
+		if (app.Name == name || app.Binary == name) && app.Status == visor.AppStatusRunning {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasApp(summary *visor.Summary, name string) bool {
+	for _, app := range summary.Apps {
+		if app.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getExitSuggestion suggests the best skysocks exit of the visor of the 'for' query value.
+// With POST, the exit is also set as the server of the visor's skysocks-client.
+func (hv *Hypervisor) getExitSuggestion(apply bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pk cipher.PubKey
+		if err := pk.Set(r.URL.Query().Get("for")); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		s, client, err := hv.suggestExit(pk)
+		switch err {
+		case nil:
+		case ErrVisorNotConnected, ErrNoExitFound:
+			httputil.WriteJSON(w, r, http.StatusNotFound, err)
+			return
+		default:
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !apply {
+			httputil.WriteJSON(w, r, http.StatusOK, s)
+			return
+		}
+
+		if !hasApp(client.Summary, skyenv.SkysocksClientName) {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrNoSocksClient)
+			return
+		}
+
+		c, ok := hv.visorConn(pk)
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrVisorNotConnected)
+			return
+		}
+
+		if err := c.RPC.SetAppSetting(skyenv.SkysocksClientName, "srv", s.Exit.PK.String()); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.Applied = true
+		httputil.WriteJSON(w, r, http.StatusOK, s)
+	}
+}
@@ -0,0 +1,159 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// exitRPCClient adds an app to the summaries of a mock visor, and records skysocks-client PK changes.
+type exitRPCClient struct {
+	visor.RPCClient
+	app      string
+	socksSrv *cipher.PubKey
+}
+
+func (c *exitRPCClient) Summary() (*visor.Summary, error) {
+	s, err := c.RPCClient.Summary()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Apps = append(s.Apps, &visor.AppState{Name: c.app, Status: visor.AppStatusRunning})
+
+	return s, nil
+}
+
+func (c *exitRPCClient) SetAppSetting(appName, key, value string) error {
+	if appName != skyenv.SkysocksClientName || key != "srv" {
+		return fmt.Errorf("unexpected setting %s of app %s", key, appName)
+	}
+
+	return c.socksSrv.Set(value)
+}
+
+func TestDistanceKm(t *testing.T) {
+	// Amsterdam to New York.
+	assert.InDelta(t, 5860, distanceKm(52.37, 4.90, 40.71, -74.01), 20)
+	assert.Zero(t, distanceKm(10, 10, 10, 10))
+}
+
+func TestGeoLocation(t *testing.T) {
+	lat, lon, ok := geoLocation(map[string]string{LabelGeoLat: "52.37", LabelGeoLon: "-4.9"})
+	assert.True(t, ok)
+	assert.Equal(t, 52.37, lat)
+	assert.Equal(t, -4.9, lon)
+
+	_, _, ok = geoLocation(map[string]string{LabelGeoLat: "91", LabelGeoLon: "0"})
+	assert.False(t, ok)
+
+	_, _, ok = geoLocation(map[string]string{LabelGeoLat: "10"})
+	assert.False(t, ok)
+}
+
+func TestSortSummariesByLatency(t *testing.T) {
+	summaries := []summaryResp{{Name: "a"}, {Name: "b", LatencyMs: 30}, {Name: "c", LatencyMs: 10}}
+	sortSummariesByLatency(summaries)
+
+	assert.Equal(t, "c", summaries[0].Name)
+	assert.Equal(t, "b", summaries[1].Name)
+	assert.Equal(t, "a", summaries[2].Name)
+}
+
+func TestExitSuggestion(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	clientPK, nearPK, farPK := pks[0], pks[1], pks[2]
+	unknownPK, _ := cipher.GenerateKeyPair()
+	var socksSrv cipher.PubKey
+
+	hv.mu.Lock()
+	for pk, app := range map[cipher.PubKey]string{
+		clientPK: skyenv.SkysocksClientName,
+		nearPK:   skyenv.SkysocksName,
+		farPK:    skyenv.SkysocksName,
+	} {
+		c := hv.visors[pk]
+		c.RPC = &exitRPCClient{RPCClient: c.RPC, app: app, socksSrv: &socksSrv}
+		hv.visors[pk] = c
+	}
+	hv.mu.Unlock()
+
+	// The near exit is slower over RPC, but much closer to the client.
+	hv.latencies.record(nearPK, 40*time.Millisecond)
+	hv.latencies.record(farPK, 20*time.Millisecond)
+
+	require.NoError(t, hv.labels.SetLabels(clientPK, map[string]string{LabelGeoLat: "52.37", LabelGeoLon: "4.90"}))
+	require.NoError(t, hv.labels.SetLabels(nearPK, map[string]string{LabelGeoLat: "50.11", LabelGeoLon: "8.68"}))
+	require.NoError(t, hv.labels.SetLabels(farPK, map[string]string{LabelGeoLat: "35.68", LabelGeoLon: "139.69"}))
+
+	suggestionResp := func(applied bool) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var s ExitSuggestion
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+			assert.Equal(t, clientPK, s.For)
+			assert.Equal(t, nearPK, s.Exit.PK)
+			assert.Len(t, s.Candidates, 2)
+			assert.Equal(t, applied, s.Applied)
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/suggest/exit?for=" + clientPK.Hex(),
+			RespStatus: http.StatusOK,
+			RespBody:   suggestionResp(false),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/suggest/exit?for=" + clientPK.Hex(),
+			RespStatus: http.StatusOK,
+			RespBody:   suggestionResp(true),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/suggest/exit?for=" + farPK.Hex(),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/suggest/exit?for=%s", unknownPK),
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors?sort=latency",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var summaries []summaryResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&summaries))
+				require.Len(t, summaries, 3)
+				assert.Equal(t, farPK, summaries[0].PubKey)
+				assert.Equal(t, nearPK, summaries[1].PubKey)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors?sort=name",
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+
+	assert.Equal(t, nearPK, socksSrv)
+}
@@ -0,0 +1,442 @@
+package hypervisor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the "sqlite3" driver
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+)
+
+const (
+	defaultExportDays = 90
+	maxExportDays     = 3660
+)
+
+// Event types of the exported events table.
+const (
+	exportEventConnected = "visor_connected"
+	exportEventSnapshot  = "config_snapshot"
+	exportEventPty       = "pty_session"
+	exportEventSchedule  = "schedule_run"
+)
+
+var (
+	exportVisorColumns = []exportColumn{
+		{Name: "pk", Type: "TEXT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "alias", Type: "TEXT"},
+		{Name: "notes", Type: "TEXT"},
+		{Name: "labels", Type: "TEXT"}, // JSON object
+		{Name: "online", Type: "INTEGER"},
+		{Name: "last_connected", Type: "TEXT"},
+	}
+	exportUptimeColumns = []exportColumn{
+		{Name: "pk", Type: "TEXT"},
+		{Name: "day", Type: "TEXT"},
+		{Name: "uptime_seconds", Type: "INTEGER"},
+	}
+	exportEventColumns = []exportColumn{
+		{Name: "time", Type: "TEXT"},
+		{Name: "type", Type: "TEXT"},
+		{Name: "visor_pk", Type: "TEXT"},
+		{Name: "detail", Type: "TEXT"},
+	}
+	exportBandwidthColumns = []exportColumn{
+		{Name: "visor_pk", Type: "TEXT"},
+		{Name: "transport_id", Type: "TEXT"},
+		{Name: "hour", Type: "TEXT"},
+		{Name: "sent_bytes", Type: "INTEGER"},
+		{Name: "recv_bytes", Type: "INTEGER"},
+		{Name: "avg_sent_bps", Type: "REAL"},
+		{Name: "avg_recv_bps", Type: "REAL"},
+	}
+)
+
+type exportEvent struct {
+	time   time.Time
+	typ    string
+	pk     cipher.PubKey
+	detail string
+}
+
+// getSQLiteExport responds with a SQLite database of the visors, their daily uptimes over the 'days' query
+// (90 by default), events recorded by the hypervisor and hourly bandwidth of transports, for querying them offline.
+// Times are stored as RFC 3339 text in UTC. The SQLite driver requires the hypervisor to be built with cgo.
+func (hv *Hypervisor) getSQLiteExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days, err := intFromQuery(r, "days", defaultExportDays)
+		if err != nil || days < 1 || days > maxExportDays {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		now := time.Now().UTC()
+
+		f, err := ioutil.TempFile("", "hypervisor-export-*.sqlite")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.WithError(err).Warn("Failed to close SQLite export.")
+			}
+
+			if err := os.Remove(f.Name()); err != nil {
+				log.WithError(err).Warn("Failed to remove SQLite export.")
+			}
+		}()
+
+		if err := hv.sqliteExport(f.Name(), now, days); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		filename := fmt.Sprintf("hypervisor-%s.sqlite", now.Format(logsArchiveTimeFormat))
+
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		if _, err := io.Copy(w, f); err != nil {
+			log.WithError(err).Warn("Failed to write SQLite export.")
+		}
+	}
+}
+
+// sqliteExport writes the export into the SQLite database at path.
+func (hv *Hypervisor) sqliteExport(path string, now time.Time, days int) (err error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cErr := db.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if rErr := tx.Rollback(); rErr != nil {
+				log.WithError(rErr).Warn("Failed to roll back SQLite export.")
+			}
+		}
+	}()
+
+	steps := []struct {
+		table   string
+		columns []exportColumn
+		export  func(t *exportTable) error
+	}{
+		{"visors", exportVisorColumns, hv.exportVisors},
+		{"uptimes", exportUptimeColumns, func(t *exportTable) error { return hv.exportUptimes(t, now, days) }},
+		{"events", exportEventColumns, hv.exportEvents},
+		{"bandwidth", exportBandwidthColumns, hv.exportBandwidth},
+	}
+
+	for _, step := range steps {
+		if err := exportTableRows(tx, step.table, step.columns, step.export); err != nil {
+			return fmt.Errorf("failed to export %s: %w", step.table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (hv *Hypervisor) exportVisors(t *exportTable) error {
+	names, err := hv.names.Names()
+	if err != nil {
+		return err
+	}
+
+	metas, err := hv.meta.Metas()
+	if err != nil {
+		return err
+	}
+
+	labels, err := hv.labels.AllLabels()
+	if err != nil {
+		return err
+	}
+
+	registry, err := hv.registry.Registry()
+	if err != nil {
+		return err
+	}
+
+	online := hv.onlineVisors()
+
+	lastConnected := make(map[cipher.PubKey]time.Time)
+	for _, e := range registry {
+		if e.Connected.After(lastConnected[e.VisorPK]) {
+			lastConnected[e.VisorPK] = e.Connected
+		}
+	}
+
+	pks := make(map[cipher.PubKey]struct{})
+	for pk := range names {
+		pks[pk] = struct{}{}
+	}
+
+	for pk := range metas {
+		pks[pk] = struct{}{}
+	}
+
+	for pk := range labels {
+		pks[pk] = struct{}{}
+	}
+
+	for pk := range lastConnected {
+		pks[pk] = struct{}{}
+	}
+
+	for pk := range online {
+		pks[pk] = struct{}{}
+	}
+
+	for _, pk := range sortedPKs(pks) {
+		var visorLabels interface{}
+		if len(labels[pk]) > 0 {
+			raw, err := json.Marshal(labels[pk])
+			if err != nil {
+				return err
+			}
+
+			visorLabels = string(raw)
+		}
+
+		err := t.insert(pk.Hex(), textOrNull(names[pk]), textOrNull(metas[pk].Alias), textOrNull(metas[pk].Notes),
+			visorLabels, online[pk], timeOrNull(lastConnected[pk]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hv *Hypervisor) exportUptimes(t *exportTable, now time.Time, days int) error {
+	today := now.Truncate(24 * time.Hour)
+
+	for day := today.AddDate(0, 0, 1-days); !day.After(today); day = day.AddDate(0, 0, 1) {
+		uptimes, err := hv.uptimes.Uptimes(day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+
+		pks := make(map[cipher.PubKey]struct{}, len(uptimes))
+		for pk := range uptimes {
+			pks[pk] = struct{}{}
+		}
+
+		for _, pk := range sortedPKs(pks) {
+			if err := t.insert(pk.Hex(), day.Format(uptimeDayFormat), int64(uptimes[pk].Seconds())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportEvents exports what the hypervisor recorded about visors: connections, config snapshots, pty sessions and
+// runs of schedules.
+func (hv *Hypervisor) exportEvents(t *exportTable) error {
+	var events []exportEvent
+
+	registry, err := hv.registry.Registry()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range registry {
+		events = append(events, exportEvent{time: e.Connected, typ: exportEventConnected, pk: e.VisorPK, detail: e.HypervisorPK.Hex()})
+	}
+
+	snapshots, err := hv.snapshots.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snapshots {
+		events = append(events, exportEvent{time: s.CreatedAt, typ: exportEventSnapshot, pk: s.VisorPK, detail: s.Note})
+	}
+
+	recordings, err := hv.ptyRecordings.PtyRecordings()
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range recordings {
+		events = append(events, exportEvent{time: rec.StartedAt, typ: exportEventPty, pk: rec.VisorPK, detail: rec.User})
+	}
+
+	schedules, err := hv.schedules.Schedules()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range schedules {
+		runs, err := hv.schedules.ScheduleRuns(s.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, run := range runs {
+			for _, v := range run.Visors {
+				detail := s.Name
+				if v.Error != "" {
+					detail = fmt.Sprintf("%s: %s", s.Name, v.Error)
+				}
+
+				events = append(events, exportEvent{time: run.StartedAt, typ: exportEventSchedule, pk: v.PK, detail: detail})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time.Before(events[j].time) })
+
+	for _, e := range events {
+		if err := t.insert(timeOrNull(e.time), e.typ, e.pk.Hex(), textOrNull(e.detail)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hv *Hypervisor) exportBandwidth(t *exportTable) error {
+	for _, r := range hv.tpStats.rollups() {
+		var sentBps, recvBps float64
+		if r.secs > 0 {
+			sentBps, recvBps = float64(r.sent)/r.secs, float64(r.recv)/r.secs
+		}
+
+		err := t.insert(r.key.pk.Hex(), r.key.tid.String(), r.hour.Format(time.RFC3339), r.sent, r.recv, sentBps, recvBps)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedPKs(pks map[cipher.PubKey]struct{}) []cipher.PubKey {
+	out := make([]cipher.PubKey, 0, len(pks))
+	for pk := range pks {
+		out = append(out, pk)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Hex() < out[j].Hex() })
+
+	return out
+}
+
+func textOrNull(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func timeOrNull(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// exportColumn is a column of an exported table.
+type exportColumn struct {
+	Name string
+	Type string // One of "TEXT", "INTEGER" or "REAL".
+}
+
+// exportTable inserts rows into a table of the export.
+type exportTable struct {
+	name    string
+	columns int
+	stmt    *sql.Stmt
+}
+
+// exportTableRows creates a table and fills it with the rows inserted by export.
+func exportTableRows(tx *sql.Tx, name string, columns []exportColumn, export func(t *exportTable) error) error {
+	defs := make([]string, len(columns))
+	params := make([]string, len(columns))
+
+	for i, c := range columns {
+		defs[i] = fmt.Sprintf("%s %s", sqlIdent(c.Name), c.Type)
+		params[i] = "?"
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", sqlIdent(name), strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", sqlIdent(name), strings.Join(params, ", ")))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close SQLite statement.")
+		}
+	}()
+
+	return export(&exportTable{name: name, columns: len(columns), stmt: stmt})
+}
+
+// insert inserts a row, with one value per column.
+// Values may be nil, bool, int, int64, uint64, float64 or string. NaN and infinite floats are stored as NULL.
+func (t *exportTable) insert(values ...interface{}) error {
+	if len(values) != t.columns {
+		return fmt.Errorf("table %s has %d columns, got %d values", t.name, t.columns, len(values))
+	}
+
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil, bool, int, int64, string:
+		case uint64:
+			if v > math.MaxInt64 {
+				return fmt.Errorf("value %d of table %s does not fit an INTEGER", v, t.name)
+			}
+
+			values[i] = int64(v)
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				values[i] = nil
+			}
+		default:
+			return fmt.Errorf("unsupported value type %T", v)
+		}
+	}
+
+	_, err := t.stmt.Exec(values...)
+
+	return err
+}
+
+func sqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
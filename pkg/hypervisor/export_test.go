@@ -0,0 +1,71 @@
+package hypervisor
+
+import (
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteExport(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	f, err := ioutil.TempFile("", "export-*.sqlite")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.Remove(f.Name()))
+	}()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/admin/export.sqlite?days=0",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/admin/export.sqlite?days=7",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				assert.Equal(t, "application/vnd.sqlite3", r.Header.Get("Content-Type"))
+				assert.Contains(t, r.Header.Get("Content-Disposition"), ".sqlite")
+
+				_, err := io.Copy(f, r.Body)
+				require.NoError(t, err)
+			},
+		},
+	})
+
+	require.NoError(t, f.Close())
+
+	db, err := sql.Open("sqlite3", f.Name())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	count := func(q string, args ...interface{}) int {
+		var n int
+		require.NoError(t, db.QueryRow(q, args...).Scan(&n))
+
+		return n
+	}
+
+	assert.Equal(t, 3, count("SELECT COUNT(*) FROM visors WHERE online = 1"))
+
+	for pk := range hv.visors {
+		assert.Equal(t, 1, count("SELECT COUNT(*) FROM visors WHERE pk = ?", pk.Hex()))
+	}
+
+	assert.Zero(t, count("SELECT COUNT(*) FROM events WHERE time IS NULL"))
+	assert.Zero(t, count("SELECT COUNT(*) FROM bandwidth WHERE sent_bytes < 0"))
+	assert.Zero(t, count("SELECT COUNT(*) FROM uptimes WHERE uptime_seconds < 0"))
+}
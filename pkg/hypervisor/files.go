@@ -0,0 +1,144 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Errors associated with file transfers.
+var (
+	ErrFilesUnavailable = errors.New("file transfers are not available for this visor")
+	ErrLengthRequired   = errors.New("uploads require a content length")
+)
+
+// FileUpload describes a file uploaded to a visor.
+type FileUpload struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// getFile downloads the file of the 'path' query from the visor.
+// Transfers time out after the default request timeout, or after the duration of the 'timeout' query.
+func (hv *Hypervisor) getFile() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		path, ok := hv.fileTransfer(w, r, ctx)
+		if !ok {
+			return
+		}
+
+		content, size, err := ctx.Files.Download(r.Context(), path)
+		if err != nil {
+			writeFileError(w, r, err)
+			return
+		}
+
+		defer func() {
+			if err := content.Close(); err != nil {
+				log.WithError(err).Debug("Failed to close file transfer stream.")
+			}
+		}()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+
+		if _, err := io.Copy(w, content); err != nil {
+			log.WithError(err).WithField("visor_pk", ctx.Addr.PK).Warn("Failed to download file.")
+		}
+	})
+}
+
+// postFile uploads the request body to the file of the 'path' query on the visor, replacing it if it exists.
+// The file is created with the octal permissions of the 'mode' query, or 0644.
+func (hv *Hypervisor) postFile() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var mode uint64
+
+		if m := r.URL.Query().Get("mode"); m != "" {
+			var err error
+			if mode, err = strconv.ParseUint(m, 8, 32); err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+		}
+
+		if r.ContentLength < 0 {
+			httputil.WriteJSON(w, r, http.StatusLengthRequired, ErrLengthRequired)
+			return
+		}
+
+		path, ok := hv.fileTransfer(w, r, ctx)
+		if !ok {
+			return
+		}
+
+		if err := ctx.Files.Upload(r.Context(), path, os.FileMode(mode), r.ContentLength, r.Body); err != nil {
+			writeFileError(w, r, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, FileUpload{Path: path, Size: r.ContentLength})
+	})
+}
+
+// fileTransfer reads the path and timeout of a file transfer request, and checks that the visor serves files.
+func (hv *Hypervisor) fileTransfer(w http.ResponseWriter, r *http.Request, ctx *httpCtx) (string, bool) {
+	q := r.URL.Query()
+
+	path := q.Get("path")
+	if path == "" {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+		return "", false
+	}
+
+	var timeout time.Duration
+
+	if t := q.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return "", false
+		}
+	}
+
+	if _, err := hv.extendDeadline(r, visor.Duration(timeout)); err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return "", false
+	}
+
+	if ctx.Files == nil {
+		httputil.WriteJSON(w, r, http.StatusServiceUnavailable, ErrFilesUnavailable)
+		return "", false
+	}
+
+	return path, true
+}
+
+func writeFileError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case err == visor.ErrFilesDisabled || err == visor.ErrPathNotAllowed:
+		status = http.StatusForbidden
+	case err == visor.ErrFileNotFound:
+		status = http.StatusNotFound
+	case err == visor.ErrFileTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case err == visor.ErrNotRegularFile:
+		status = http.StatusBadRequest
+	case r.Context().Err() != nil:
+		status, err = http.StatusGatewayTimeout, ErrRequestTimeout
+	}
+
+	httputil.WriteJSON(w, r, status, err)
+}
@@ -0,0 +1,94 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestFiles(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	var pk string
+
+	hv.mu.Lock()
+	for visorPK, c := range hv.visors {
+		c.Files = visor.NewMockFilesClient(&visor.FilesConfig{Allow: []string{dir}})
+		hv.visors[visorPK] = c
+		pk = visorPK.Hex()
+	}
+	hv.mu.Unlock()
+
+	path := filepath.Join(dir, "config.json")
+	content := bytes.Repeat([]byte("x"), 2<<20) // Larger than the API's body size limit.
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/visors/" + pk + "/files?mode=0600&path=" + path,
+			ReqBody:    bytes.NewReader(content),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var u FileUpload
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&u))
+				assert.Equal(t, FileUpload{Path: path, Size: int64(len(content))}, u)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk + "/files?path=" + path,
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				got, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, content, got)
+				assert.Contains(t, r.Header.Get("Content-Disposition"), "config.json")
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk + "/files?path=/etc/passwd",
+			RespStatus: http.StatusForbidden,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk + "/files?path=" + filepath.Join(dir, "missing"),
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk + "/files",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/visors/" + pk + "/files?mode=9&path=" + path,
+			ReqBody:    bytes.NewReader(content),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
@@ -0,0 +1,159 @@
+package hypervisor
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Health states of visors in the fleet health summary.
+const (
+	HealthHealthy     = "healthy"
+	HealthDegraded    = "degraded"
+	HealthUnreachable = "unreachable"
+)
+
+// FleetHealth summarizes the health of all connected visors.
+type FleetHealth struct {
+	Healthy     int                `json:"healthy"`
+	Degraded    int                `json:"degraded"`
+	Unreachable int                `json:"unreachable"`
+	Visors      []FleetVisorHealth `json:"visors"`
+}
+
+// FleetVisorHealth is the health of a single visor of the fleet.
+type FleetVisorHealth struct {
+	PK      cipher.PubKey `json:"pk"`
+	Name    string        `json:"name,omitempty"`
+	State   string        `json:"state"`
+	Failing []string      `json:"failing,omitempty"` // Components which do not respond with 200.
+	Error   string        `json:"error,omitempty"`
+}
+
+// failingComponents returns the names of components of h which are not healthy.
+func failingComponents(h *visor.HealthInfo) []string {
+	var failing []string
+
+	for _, c := range []struct {
+		name   string
+		status int
+	}{
+		{"transport_discovery", h.TransportDiscovery},
+		{"route_finder", h.RouteFinder},
+		{"setup_node", h.SetupNode},
+	} {
+		if c.status != http.StatusOK {
+			failing = append(failing, c.name)
+		}
+	}
+
+	return failing
+}
+
+// visorHealth obtains the health of a single visor, giving up after healthTimeout.
+func visorHealth(pk cipher.PubKey, c VisorConn) FleetVisorHealth {
+	type healthRes struct {
+		h   *visor.HealthInfo
+		err error
+	}
+
+	resCh := make(chan healthRes, 1)
+
+	go func() {
+		h, err := c.RPC.Health()
+		resCh <- healthRes{h, err}
+	}()
+
+	vh := FleetVisorHealth{PK: pk}
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			vh.State, vh.Error = HealthUnreachable, res.err.Error()
+			break
+		}
+
+		vh.State = HealthHealthy
+		if vh.Failing = failingComponents(res.h); len(vh.Failing) > 0 {
+			vh.State = HealthDegraded
+		}
+	case <-time.After(healthTimeout):
+		vh.State, vh.Error = HealthUnreachable, "health check timed out"
+	}
+
+	return vh
+}
+
+// fleetHealth concurrently collects the health of all connected visors.
+func (hv *Hypervisor) fleetHealth() (FleetHealth, error) {
+	names, err := hv.names.Names()
+	if err != nil {
+		return FleetHealth{}, err
+	}
+
+	hv.mu.RLock()
+	conns := make(map[cipher.PubKey]VisorConn, len(hv.visors))
+	for pk, c := range hv.visors {
+		conns[pk] = c
+	}
+	hv.mu.RUnlock()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		health = FleetHealth{Visors: make([]FleetVisorHealth, 0, len(conns))}
+	)
+
+	wg.Add(len(conns))
+
+	for pk, c := range conns {
+		go func(pk cipher.PubKey, c VisorConn) {
+			defer wg.Done()
+
+			vh := visorHealth(pk, c)
+			vh.Name = names[pk]
+
+			mu.Lock()
+			health.Visors = append(health.Visors, vh)
+			mu.Unlock()
+		}(pk, c)
+	}
+
+	wg.Wait()
+
+	sort.Slice(health.Visors, func(i, j int) bool {
+		return health.Visors[i].PK.Hex() < health.Visors[j].PK.Hex()
+	})
+
+	for _, vh := range health.Visors {
+		switch vh.State {
+		case HealthHealthy:
+			health.Healthy++
+		case HealthDegraded:
+			health.Degraded++
+		default:
+			health.Unreachable++
+		}
+	}
+
+	return health, nil
+}
+
+// getFleetHealth returns the health summary of all connected visors.
+func (hv *Hypervisor) getFleetHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health, err := hv.fleetHealth()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, health)
+	}
+}
@@ -0,0 +1,78 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+type degradedRPCClient struct {
+	visor.RPCClient
+}
+
+func (degradedRPCClient) Health() (*visor.HealthInfo, error) {
+	return &visor.HealthInfo{
+		TransportDiscovery: http.StatusOK,
+		RouteFinder:        http.StatusBadGateway,
+		SetupNode:          http.StatusServiceUnavailable,
+	}, nil
+}
+
+func TestFleetHealth(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.Len(t, pks, 3)
+
+	hv.mu.Lock()
+	c := hv.visors[pks[0]]
+	c.RPC = degradedRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[0]] = c
+
+	c = hv.visors[pks[1]]
+	c.RPC = unhealthyRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[1]] = c
+	hv.mu.Unlock()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/health",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var health FleetHealth
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&health))
+
+				assert.Equal(t, 1, health.Healthy)
+				assert.Equal(t, 1, health.Degraded)
+				assert.Equal(t, 1, health.Unreachable)
+				require.Len(t, health.Visors, 3)
+
+				for _, vh := range health.Visors {
+					switch vh.PK {
+					case pks[0]:
+						assert.Equal(t, HealthDegraded, vh.State)
+						assert.Equal(t, []string{"route_finder", "setup_node"}, vh.Failing)
+					case pks[1]:
+						assert.Equal(t, HealthUnreachable, vh.State)
+						assert.NotEmpty(t, vh.Error)
+					default:
+						assert.Equal(t, HealthHealthy, vh.State)
+						assert.Empty(t, vh.Failing)
+					}
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,285 @@
+package hypervisor
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+// TLS modes of generated configs.
+const (
+	TLSModeNone       = "none"
+	TLSModeFiles      = "files"       // Uses existing cert and key files.
+	TLSModeSelfSigned = "self-signed" // Uses a generated self-signed cert.
+	TLSModeACME       = "acme"        // Obtains certs via ACME.
+)
+
+const (
+	selfSignedCertValidity = 5 * 365 * 24 * time.Hour
+	selfSignedCertFile     = "hypervisor.crt"
+	selfSignedKeyFile      = "hypervisor.key"
+)
+
+// Errors associated with generating configs.
+var (
+	ErrUnknownTLSMode = errors.New("tls mode should be one of 'none', 'files', 'self-signed' or 'acme'")
+	ErrNoTLSFiles     = errors.New("tls cert and key files are not set")
+)
+
+// GenConfigOptions are the choices made when generating a config with GenerateConfig.
+type GenConfigOptions struct {
+	Location    pathutil.ConfigLocationType // Determines the default DB path.
+	TestEnv     bool                        // Uses the test deployment services.
+	DBPath      string                      // Overrides the default DB path of the location.
+	HTTPAddr    string                      // Defaults to ":8000".
+	EnableAuth  bool
+	TLSMode     string   // One of the TLS modes, defaults to TLSModeNone.
+	TLSCertFile string   // Used by TLSModeFiles and TLSModeSelfSigned (defaults to next to the DB).
+	TLSKeyFile  string   // Used by TLSModeFiles and TLSModeSelfSigned (defaults to next to the DB).
+	TLSHosts    []string // Hosts of the self-signed cert, or the ACME domains.
+	ACMEEmail   string
+}
+
+// GenerateConfig generates a complete config with new keys.
+// Cert files of TLSModeSelfSigned are not written, see WriteSelfSignedCert.
+func GenerateConfig(o GenConfigOptions) (Config, error) {
+	var c Config
+
+	switch o.Location {
+	case pathutil.HomeLoc:
+		c = GenerateHomeConfig(o.TestEnv)
+	case pathutil.LocalLoc:
+		c = GenerateLocalConfig(o.TestEnv)
+	case pathutil.WorkingDirLoc, "":
+		c = GenerateWorkDirConfig(o.TestEnv)
+	default:
+		return Config{}, fmt.Errorf("invalid config type: %s", o.Location)
+	}
+
+	if o.DBPath != "" {
+		c.DBPath = o.DBPath
+	}
+
+	if o.HTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(o.HTTPAddr); err != nil {
+			return Config{}, fmt.Errorf("http address: %w", err)
+		}
+
+		c.HTTPAddr = o.HTTPAddr
+	}
+
+	c.EnableAuth = o.EnableAuth
+
+	switch o.TLSMode {
+	case TLSModeNone, "":
+	case TLSModeFiles:
+		if o.TLSCertFile == "" || o.TLSKeyFile == "" {
+			return Config{}, ErrNoTLSFiles
+		}
+
+		c.EnableTLS, c.TLSCertFile, c.TLSKeyFile = true, o.TLSCertFile, o.TLSKeyFile
+	case TLSModeSelfSigned:
+		c.EnableTLS, c.TLSCertFile, c.TLSKeyFile = true, o.TLSCertFile, o.TLSKeyFile
+
+		if c.TLSCertFile == "" {
+			c.TLSCertFile = filepath.Join(filepath.Dir(c.DBPath), selfSignedCertFile)
+		}
+
+		if c.TLSKeyFile == "" {
+			c.TLSKeyFile = filepath.Join(filepath.Dir(c.DBPath), selfSignedKeyFile)
+		}
+	case TLSModeACME:
+		if len(o.TLSHosts) == 0 {
+			return Config{}, ErrNoACMEDomains
+		}
+
+		c.EnableTLS = true
+		c.ACME.Enable, c.ACME.Domains, c.ACME.Email = true, o.TLSHosts, o.ACMEEmail
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownTLSMode, o.TLSMode)
+	}
+
+	return c, nil
+}
+
+// WriteSelfSignedCert generates a self-signed cert for hosts (IPs or DNS names), and writes it and its key as PEM.
+// With no hosts, the cert is for "localhost".
+func WriteSelfSignedCert(certFile, keyFile string, hosts []string) error {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Skywire Hypervisor"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+
+	return writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		_ = f.Close() // nolint: errcheck
+		return err
+	}
+
+	return f.Close()
+}
+
+// PromptGenConfigOptions asks for the options on out, reading answers from in.
+// Current values of the options are the defaults, used for empty answers.
+func PromptGenConfigOptions(in io.Reader, out io.Writer, o *GenConfigOptions) error {
+	p := prompter{s: bufio.NewScanner(in), w: out}
+
+	if o.DBPath == "" {
+		c, err := GenerateConfig(GenConfigOptions{Location: o.Location, TestEnv: o.TestEnv})
+		if err != nil {
+			return err
+		}
+
+		o.DBPath = c.DBPath
+	}
+
+	if o.HTTPAddr == "" {
+		o.HTTPAddr = defaultHTTPAddr
+	}
+
+	if o.TLSMode == "" {
+		o.TLSMode = TLSModeNone
+	}
+
+	o.DBPath = p.ask("Database path", o.DBPath)
+	o.HTTPAddr = p.ask("HTTP address to serve on", o.HTTPAddr)
+	o.EnableAuth = p.askBool("Enable user authentication", o.EnableAuth)
+	o.TLSMode = p.ask("TLS mode (none, files, self-signed, acme)", o.TLSMode)
+
+	switch o.TLSMode {
+	case TLSModeFiles:
+		o.TLSCertFile = p.ask("TLS cert file", o.TLSCertFile)
+		o.TLSKeyFile = p.ask("TLS key file", o.TLSKeyFile)
+	case TLSModeSelfSigned:
+		o.TLSHosts = p.askList("Hosts of the cert (comma-separated)", o.TLSHosts)
+	case TLSModeACME:
+		o.TLSHosts = p.askList("Domains (comma-separated)", o.TLSHosts)
+		o.ACMEEmail = p.ask("Contact email (optional)", o.ACMEEmail)
+	}
+
+	return p.err()
+}
+
+type prompter struct {
+	s *bufio.Scanner
+	w io.Writer
+}
+
+func (p prompter) ask(question, def string) string {
+	if _, err := fmt.Fprintf(p.w, "%s [%s]: ", question, def); err != nil {
+		return def
+	}
+
+	if !p.s.Scan() {
+		return def
+	}
+
+	if answer := strings.TrimSpace(p.s.Text()); answer != "" {
+		return answer
+	}
+
+	return def
+}
+
+func (p prompter) askBool(question string, def bool) bool {
+	defAnswer := "y/N"
+	if def {
+		defAnswer = "Y/n"
+	}
+
+	switch strings.ToLower(p.ask(question, defAnswer)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+func (p prompter) askList(question string, def []string) []string {
+	answer := p.ask(question, strings.Join(def, ","))
+
+	var list []string
+
+	for _, s := range strings.Split(answer, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
+
+func (p prompter) err() error {
+	return p.s.Err()
+}
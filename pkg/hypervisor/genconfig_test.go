@@ -0,0 +1,125 @@
+package hypervisor
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c, err := GenerateConfig(GenConfigOptions{Location: pathutil.LocalLoc})
+		require.NoError(t, err)
+
+		assert.False(t, c.PK.Null())
+		assert.False(t, c.SK.Null())
+		assert.Equal(t, GenerateLocalConfig(false).DBPath, c.DBPath)
+		assert.Equal(t, defaultHTTPAddr, c.HTTPAddr)
+		assert.False(t, c.EnableTLS)
+	})
+
+	t.Run("self_signed", func(t *testing.T) {
+		c, err := GenerateConfig(GenConfigOptions{
+			DBPath:     "/var/lib/hypervisor/users.db",
+			HTTPAddr:   "127.0.0.1:8443",
+			EnableAuth: true,
+			TLSMode:    TLSModeSelfSigned,
+		})
+		require.NoError(t, err)
+
+		assert.True(t, c.EnableAuth)
+		assert.True(t, c.EnableTLS)
+		assert.Equal(t, "127.0.0.1:8443", c.HTTPAddr)
+		assert.Equal(t, "/var/lib/hypervisor/hypervisor.crt", c.TLSCertFile)
+		assert.Equal(t, "/var/lib/hypervisor/hypervisor.key", c.TLSKeyFile)
+	})
+
+	t.Run("acme", func(t *testing.T) {
+		c, err := GenerateConfig(GenConfigOptions{TLSMode: TLSModeACME, TLSHosts: []string{"hv.example.com"}})
+		require.NoError(t, err)
+
+		assert.True(t, c.ACME.Enable)
+		assert.Equal(t, []string{"hv.example.com"}, c.ACME.Domains)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := GenerateConfig(GenConfigOptions{TLSMode: TLSModeFiles, TLSCertFile: "cert.pem"})
+		assert.Equal(t, ErrNoTLSFiles, err)
+
+		_, err = GenerateConfig(GenConfigOptions{TLSMode: TLSModeACME})
+		assert.Equal(t, ErrNoACMEDomains, err)
+
+		_, err = GenerateConfig(GenConfigOptions{TLSMode: "on"})
+		assert.True(t, errors.Is(err, ErrUnknownTLSMode))
+
+		_, err = GenerateConfig(GenConfigOptions{HTTPAddr: "8000"})
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteSelfSignedCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hypervisor_cert")
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	certFile, keyFile := filepath.Join(dir, "tls", "hv.crt"), filepath.Join(dir, "tls", "hv.key")
+	require.NoError(t, WriteSelfSignedCert(certFile, keyFile, []string{"hv.local", "10.0.0.1"}))
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, cert.VerifyHostname("hv.local"))
+	assert.NoError(t, cert.VerifyHostname("10.0.0.1"))
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestPromptGenConfigOptions(t *testing.T) {
+	answers := strings.Join([]string{
+		"/srv/hv/users.db", // database path
+		"",                 // http address: default
+		"y",                // enable auth
+		"acme",             // tls mode
+		"a.example.com, b.example.com",
+		"ops@example.com",
+	}, "\n")
+
+	var (
+		out bytes.Buffer
+		o   GenConfigOptions
+	)
+
+	require.NoError(t, PromptGenConfigOptions(strings.NewReader(answers), &out, &o))
+
+	assert.Equal(t, GenConfigOptions{
+		DBPath:     "/srv/hv/users.db",
+		HTTPAddr:   defaultHTTPAddr,
+		EnableAuth: true,
+		TLSMode:    TLSModeACME,
+		TLSHosts:   []string{"a.example.com", "b.example.com"},
+		ACMEEmail:  "ops@example.com",
+	}, o)
+	assert.Contains(t, out.String(), "Database path [")
+
+	// Answers missing at the end of input keep the defaults.
+	o = GenConfigOptions{EnableAuth: true}
+	require.NoError(t, PromptGenConfigOptions(strings.NewReader(""), &out, &o))
+	assert.True(t, o.EnableAuth)
+	assert.Equal(t, TLSModeNone, o.TLSMode)
+}
@@ -3,6 +3,7 @@ package hypervisor
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,7 +16,6 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/google/uuid"
 	"github.com/skycoin/dmsg"
 	"github.com/skycoin/dmsg/cipher"
@@ -24,9 +24,13 @@ import (
 	"github.com/skycoin/skycoin/src/util/logging"
 
 	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/app/appserver"
+	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
 	"github.com/skycoin/skywire/pkg/routing"
 	"github.com/skycoin/skywire/pkg/skyenv"
 	"github.com/skycoin/skywire/pkg/util/buildinfo"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+	"github.com/skycoin/skywire/pkg/util/updater"
 	"github.com/skycoin/skywire/pkg/visor"
 )
 
@@ -49,35 +53,173 @@ type VisorConn struct {
 	Addr  dmsg.Addr
 	RPC   visor.RPCClient
 	PtyUI *dmsgpty.UI
+	Files *visor.FilesClient
+	Echo  *visor.EchoClient
 }
 
 // Hypervisor manages visors.
 type Hypervisor struct {
-	c      Config
-	assets http.FileSystem             // Web UI.
-	visors map[cipher.PubKey]VisorConn // connected remote visors.
-	users  *UserManager
-	mu     *sync.RWMutex
+	c             Config
+	assets        http.FileSystem                 // Web UI.
+	visors        map[cipher.PubKey]VisorConn     // connected remote visors.
+	standby       map[cipher.PubKey]*standbyVisor // connected standby visors (heartbeats only).
+	users         *UserManager
+	oidc          *oidcClient // Single sign-on through an OpenID Connect provider, if enabled.
+	uptimes       UptimeStore
+	names         NameStore
+	meta          MetaStore
+	wake          WakeStore
+	registry      VisorRegistry
+	labels        LabelStore
+	profiles      ProfileStore
+	snapshots     SnapshotStore
+	appBinaries   AppBinaryStore
+	accept        AcceptListStore
+	rings         UpdateRingStore
+	ptyRecordings PtyRecordingStore
+	watches       *watchHub
+	ringsMu       sync.Mutex // Serializes changes of the update rings.
+	rollouts      map[uuid.UUID]*rollout
+	notifier      *notifier
+	syncer        *syncer
+	activity      *activity
+	routes        *routeCache
+	tpStats       *tpStats
+	latencies     *rpcLatencies
+	probes        *probes
+	apiFilter     *ipFilter
+	ptyFilter     *ipFilter
+	ipLimiter     *rateLimiter
+	userLimiter   *rateLimiter
+	utTracker     *uptimeTracker // Embedded uptime tracker, if enabled.
+	readOnly      int32          // Refuses modifications through the API when 1, accessed atomically.
+	mu            *sync.RWMutex
+	done          chan struct{} // Closed to stop the background workers.
+	closeOnce     sync.Once
+
+	schedules        ScheduleStore
+	runningSchedules map[uuid.UUID]struct{}
 }
 
 // New creates a new Hypervisor.
 func New(assets http.FileSystem, config Config) (*Hypervisor, error) {
 	config.Cookies.TLS = config.EnableTLS
+	if base := config.basePath(); base != "" && (config.Cookies.Path == "" || config.Cookies.Path == "/") {
+		config.Cookies.Path = base + "/"
+	}
 
-	boltUserDB, err := NewBoltUserStore(config.DBPath)
+	st, err := openStores(config)
 	if err != nil {
 		return nil, err
 	}
 
-	singleUserDB := NewSingleUserStore("admin", boltUserDB)
+	if st.users, err = openUserStore(config, st.users); err != nil {
+		return nil, err
+	}
+
+	syncr, err := newSyncer(config.Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	hv := &Hypervisor{
+		c:             config,
+		assets:        assets,
+		visors:        make(map[cipher.PubKey]VisorConn),
+		standby:       make(map[cipher.PubKey]*standbyVisor),
+		users:         NewUserManager(NewSingleUserStore("admin", st.users), st.sessions, config.Cookies, config.LoginLimits),
+		uptimes:       st.uptimes,
+		names:         st.names,
+		meta:          st.meta,
+		wake:          st.wake,
+		registry:      st.registry,
+		labels:        st.labels,
+		profiles:      st.profiles,
+		snapshots:     st.snaps,
+		appBinaries:   st.bins,
+		accept:        st.accept,
+		rings:         st.rings,
+		ptyRecordings: st.ptyRecs,
+		watches:       newWatchHub(),
+		rollouts:      make(map[uuid.UUID]*rollout),
+		notifier:      newNotifier(config.Notifications),
+		syncer:        syncr,
+		activity:      newActivity(),
+		routes:        newRouteCache(rfclient.NewHTTP(config.RouteFinder.Addr, config.RouteFinder.Timeout), config.RouteFinder.CacheTTL),
+		tpStats:       newTpStats(),
+		latencies:     newRPCLatencies(),
+		probes:        newProbes(),
+		ipLimiter:     newRateLimiter(config.RateLimits.PerIP, config.RateLimits.Burst),
+		userLimiter:   newRateLimiter(config.RateLimits.PerUser, config.RateLimits.Burst),
+		mu:            new(sync.RWMutex),
+		done:          make(chan struct{}),
+
+		schedules:        st.schedule,
+		runningSchedules: make(map[uuid.UUID]struct{}),
+	}
+
+	if config.UptimeTracker.Enable {
+		var accept func(pk cipher.PubKey) (bool, error)
+		if config.AcceptList.Enable {
+			accept = hv.accept.Enrolled
+		}
+
+		hv.utTracker = newUptimeTracker(hv.uptimes, accept)
+	}
+
+	if hv.apiFilter, err = newIPFilter(config.IPFilter.API); err != nil {
+		return nil, err
+	}
+
+	if hv.ptyFilter, err = newIPFilter(config.IPFilter.Pty); err != nil {
+		return nil, err
+	}
+
+	if config.EnableAuth && config.OIDC.Enabled() {
+		if hv.oidc, err = newOIDCClient(config.OIDC, config.Cookies); err != nil {
+			return nil, err
+		}
+	}
+
+	hv.setReadOnly(config.ReadOnly)
+
+	go hv.notifier.serve(hv.done)
+	go hv.recordUptimes()
+	go hv.runSchedules()
+	go hv.runUpdateRings()
+	go hv.recordTransportStats()
+	go hv.probeVisors(config.ProbeInterval)
+
+	if len(config.Notifications.Webhooks) > 0 {
+		go hv.watchVisors()
+	}
 
-	return &Hypervisor{
-		c:      config,
-		assets: assets,
-		visors: make(map[cipher.PubKey]VisorConn),
-		users:  NewUserManager(singleUserDB, config.Cookies),
-		mu:     new(sync.RWMutex),
-	}, nil
+	if len(config.Sync.Targets) > 0 {
+		go hv.syncVisors()
+	}
+
+	return hv, nil
+}
+
+// Close stops the background workers of the hypervisor.
+func (hv *Hypervisor) Close() error {
+	hv.closeOnce.Do(func() { close(hv.done) })
+	return nil
+}
+
+// every calls f every interval until the hypervisor is closed.
+func (hv *Hypervisor) every(interval time.Duration, f func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hv.done:
+			return
+		case now := <-ticker.C:
+			f(now)
+		}
+	}
 }
 
 // ServeRPC serves RPC of a Hypervisor.
@@ -91,13 +233,63 @@ func (hv *Hypervisor) ServeRPC(dmsgC *dmsg.Client, lis *dmsg.Listener) error {
 		ptyDialer := dmsgpty.DmsgUIDialer(dmsgC, dmsg.Addr{PK: addr.PK, Port: skyenv.DmsgPtyPort})
 		visorConn := VisorConn{
 			Addr:  addr,
-			RPC:   visor.NewRPCClient(rpc.NewClient(conn), visor.RPCPrefix),
+			RPC:   visor.NewRPCClient(rpc.NewClientWithCodec(rpcutil.NewCompressedClientCodec(conn, hv.c.RPCCompression)), visor.RPCPrefix),
 			PtyUI: dmsgpty.NewUI(ptyDialer, dmsgpty.DefaultUIConfig()),
+			Files: visor.NewDmsgFilesClient(dmsgC, addr.PK),
+			Echo:  visor.NewDmsgEchoClient(dmsgC, addr.PK),
 		}
-		log.WithField("remote_addr", addr).Info("Accepted.")
-		hv.mu.Lock()
-		hv.visors[addr.PK] = visorConn
-		hv.mu.Unlock()
+
+		if !hv.c.AcceptList.Enable {
+			hv.addVisor(visorConn)
+			go hv.keepAlive(visorConn, conn, hv.c.KeepAliveInterval, hv.c.KeepAliveMisses)
+			go hv.followEvents(visorConn)
+
+			continue
+		}
+
+		go func() {
+			if err := hv.checkEnrollment(addr.PK, visorConn.RPC); err != nil {
+				log.WithError(err).WithField("remote_addr", addr).Warn("Rejected visor.")
+
+				if err := conn.Close(); err != nil {
+					log.WithError(err).WithField("remote_addr", addr).Warn("Failed to close rejected visor connection.")
+				}
+
+				return
+			}
+
+			hv.addVisor(visorConn)
+			go hv.followEvents(visorConn)
+			hv.keepAlive(visorConn, conn, hv.c.KeepAliveInterval, hv.c.KeepAliveMisses)
+		}()
+	}
+}
+
+// AddVisor connects a visor over an established connection, as visors accepted over dmsg are.
+// It allows serving visors reached by other means, such as the fake visors of package hypervisortest.
+func (hv *Hypervisor) AddVisor(visorConn VisorConn) {
+	hv.addVisor(visorConn)
+}
+
+// addVisor adds an accepted visor connection, and registers the visor as being served by this hypervisor.
+func (hv *Hypervisor) addVisor(visorConn VisorConn) {
+	addr := visorConn.Addr
+
+	log.WithField("remote_addr", addr).Info("Accepted.")
+	hv.mu.Lock()
+	hv.visors[addr.PK] = visorConn
+	hv.mu.Unlock()
+
+	hv.routes.invalidate(addr.PK)
+
+	entry := RegistryEntry{
+		VisorPK:      addr.PK,
+		HypervisorPK: hv.c.PK,
+		Addr:         hv.c.HTTPAddr,
+		Connected:    time.Now().UTC(),
+	}
+	if err := hv.registry.Register(entry); err != nil {
+		log.WithError(err).WithField("remote_addr", addr).Warn("Failed to register visor.")
 	}
 }
 
@@ -125,7 +317,8 @@ func (hv *Hypervisor) AddMockData(config MockConfig) error {
 				PK:   pk,
 				Port: uint16(i),
 			},
-			RPC: client,
+			RPC:  client,
+			Echo: visor.NewMockEchoClient(),
 		}
 		hv.mu.Unlock()
 	}
@@ -138,68 +331,236 @@ func (hv *Hypervisor) AddMockData(config MockConfig) error {
 // ServeHTTP implements http.Handler
 func (hv *Hypervisor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(newRequestLogger())
+	r.Use(hv.cors)
 
-	r.Route("/", func(r chi.Router) {
-		r.Route("/api", func(r chi.Router) {
-			r.Use(middleware.Timeout(httpTimeout))
+	base := hv.c.basePath()
+	mount := base
+	if mount == "" {
+		mount = "/"
+	}
 
-			r.Get("/ping", hv.getPong())
+	r.Route(mount, func(r chi.Router) {
+		r.Route("/api", func(r chi.Router) {
+			r.Use(hv.apiFilter.filter)
+			r.Use(hv.ipLimiter.limit(remoteIP))
+			r.Use(requestTimeout(httpTimeout))
 
-			if hv.c.EnableAuth {
-				r.Group(func(r chi.Router) {
-					r.Post("/create-account", hv.users.CreateAccount())
-					r.Post("/login", hv.users.Login())
-					r.Post("/logout", hv.users.Logout())
-				})
-			}
+			r.Route("/v1", hv.v1Routes)
 
+			// Unversioned paths are kept for compatibility with older clients.
 			r.Group(func(r chi.Router) {
-				if hv.c.EnableAuth {
-					r.Use(hv.users.Authorize)
-				}
-				r.Get("/user", hv.users.UserInfo())
-				r.Post("/change-password", hv.users.ChangePassword())
-				r.Get("/about", hv.getAbout())
-				r.Get("/visors", hv.getVisors())
-				r.Get("/visors/{pk}", hv.getVisor())
-				r.Get("/visors/{pk}/health", hv.getHealth())
-				r.Get("/visors/{pk}/uptime", hv.getUptime())
-				r.Get("/visors/{pk}/apps", hv.getApps())
-				r.Get("/visors/{pk}/apps/{app}", hv.getApp())
-				r.Put("/visors/{pk}/apps/{app}", hv.putApp())
-				r.Get("/visors/{pk}/apps/{app}/logs", hv.appLogsSince())
-				r.Get("/visors/{pk}/transport-types", hv.getTransportTypes())
-				r.Get("/visors/{pk}/transports", hv.getTransports())
-				r.Post("/visors/{pk}/transports", hv.postTransport())
-				r.Get("/visors/{pk}/transports/{tid}", hv.getTransport())
-				r.Delete("/visors/{pk}/transports/{tid}", hv.deleteTransport())
-				r.Get("/visors/{pk}/routes", hv.getRoutes())
-				r.Post("/visors/{pk}/routes", hv.postRoute())
-				r.Get("/visors/{pk}/routes/{rid}", hv.getRoute())
-				r.Put("/visors/{pk}/routes/{rid}", hv.putRoute())
-				r.Delete("/visors/{pk}/routes/{rid}", hv.deleteRoute())
-				r.Get("/visors/{pk}/routegroups", hv.getRouteGroups())
-				r.Post("/visors/{pk}/restart", hv.restart())
-				r.Post("/visors/{pk}/exec", hv.exec())
-				r.Post("/visors/{pk}/update", hv.update())
-				r.Get("/visors/{pk}/update/available", hv.updateAvailable())
+				r.Use(deprecatedAPI(base))
+				hv.v1Routes(r)
 			})
 		})
 
+		if hv.utTracker != nil {
+			r.Route("/uptime-tracker", hv.uptimeTrackerRoutes)
+		}
+
 		r.Route("/pty", func(r chi.Router) {
+			r.Use(hv.ptyFilter.filter)
 			if hv.c.EnableAuth {
 				r.Use(hv.users.Authorize)
 			}
+			r.Use(roleGuard)
+			r.Use(hv.readOnlyGuard)
 			r.Get("/{pk}", hv.getPty())
 		})
 
-		r.Handle("/*", http.FileServer(hv.assets))
+		r.Handle("/*", hv.assetsHandler())
 	})
 
 	r.ServeHTTP(w, req)
 }
 
+// v1Routes registers the routes of version 1 of the API.
+func (hv *Hypervisor) v1Routes(r chi.Router) {
+	r.Get("/ping", hv.getPong())
+	r.Get("/openapi.json", hv.getOpenAPI())
+
+	if hv.c.EnableAuth {
+		r.Group(func(r chi.Router) {
+			r.Use(limitBody(hv.c.RateLimits.MaxBodySize))
+			r.Post("/create-account", hv.users.CreateAccount())
+			r.Post("/login", hv.users.Login())
+			r.Post("/logout", hv.users.Logout())
+
+			if hv.oidc != nil {
+				r.Get("/oidc/login", hv.getOIDCLogin())
+				r.Get("/oidc/callback", hv.getOIDCCallback())
+			}
+		})
+	}
+
+	r.Group(func(r chi.Router) {
+		if hv.c.EnableAuth {
+			r.Use(hv.users.Authorize)
+		}
+		r.Use(hv.userLimiter.limit(requestUser))
+
+		r.Group(func(r chi.Router) {
+			r.Use(limitBody(hv.c.RateLimits.MaxBodySize))
+			r.Get("/user", hv.users.UserInfo())
+			r.With(localUserOnly).Post("/change-password", hv.users.ChangePassword())
+			r.With(localUserOnly).Post("/user/2fa/setup", hv.users.SetupTOTP())
+			r.With(localUserOnly).Post("/user/2fa/enable", hv.users.EnableTOTP())
+			r.With(localUserOnly).Post("/user/2fa/disable", hv.users.DisableTOTP())
+			r.Get("/user/sessions", hv.users.Sessions())
+			r.Delete("/user/sessions/{id}", hv.users.RevokeSession())
+			r.With(roleGuard).Post("/user/unlock", hv.users.Unlock())
+			r.Get("/read-only", hv.getReadOnly())
+			r.With(roleGuard).Put("/read-only", hv.putReadOnly())
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(roleGuard)
+			r.Use(hv.readOnlyGuard)
+			hv.apiRoutes(r)
+		})
+	})
+}
+
+// apiRoutes registers the API routes which do not depend on the logged in user.
+func (hv *Hypervisor) apiRoutes(r chi.Router) {
+	// App binaries are uploaded as raw request bodies, which are limited separately.
+	r.Put("/apps/binaries/{name}", hv.putAppBinary())
+	r.Route("/visors/{pk}", hv.visorRoutes)
+	r.Route("/visors/by-name/{name}", hv.visorRoutes)
+	r.Route("/visors/by-alias/{alias}", hv.visorRoutes)
+
+	r.Group(func(r chi.Router) {
+		r.Use(limitBody(hv.c.RateLimits.MaxBodySize))
+
+		r.Get("/about", hv.getAbout())
+		r.Get("/visors", hv.getVisors())
+		r.Get("/visors/standby", hv.getStandbyVisors())
+		r.Get("/changes", hv.getChanges())
+		r.Get("/health", hv.getFleetHealth())
+		r.Get("/uptimes", hv.getUptimes())
+		r.Get("/topology", hv.getTopology())
+		r.Get("/cluster/visors", hv.getClusterVisors())
+		r.Get("/accept-list", hv.getAcceptList())
+		r.Post("/accept-list/tokens", hv.postEnrollmentToken())
+		r.Put("/accept-list/{pk}", hv.putAcceptList())
+		r.Delete("/accept-list/{pk}", hv.deleteAcceptList())
+		r.Post("/diagnostics", hv.postDiagnostics())
+		r.Post("/tests/bandwidth", hv.postBandwidthTest())
+		r.Get("/route-finder/routes", hv.getRouteFinderRoutes())
+		r.Get("/suggest/exit", hv.getExitSuggestion(false))
+		r.Post("/suggest/exit", hv.getExitSuggestion(true))
+		r.Delete("/route-finder/cache", hv.deleteRouteFinderCache())
+		r.Get("/admin/cache-stats", hv.getCacheStats())
+		r.Get("/admin/export.sqlite", hv.getSQLiteExport())
+		r.Get("/updates/rollout", hv.getRollouts())
+		r.Post("/updates/rollout", hv.postRollout())
+		r.Get("/updates/rollout/{id}", hv.getRollout())
+		r.Post("/updates/rollout/{id}/abort", hv.abortRollout())
+		r.Get("/updates/rings", hv.getUpdateRings())
+		r.Put("/updates/rings", hv.putUpdateRings())
+		r.Get("/pty-recordings", hv.getPtyRecordings())
+		r.Get("/pty-recordings/{id}", hv.getPtyRecording())
+		r.Delete("/pty-recordings/{id}", hv.deletePtyRecording())
+		r.Get("/notifications/config", hv.getNotificationsConfig())
+		r.Post("/logs/collect", hv.postLogsCollect())
+		r.Get("/labels", hv.getLabels())
+		r.Get("/schedules", hv.getSchedules())
+		r.Post("/schedules", hv.postSchedule())
+		r.Get("/schedules/{id}", hv.getSchedule())
+		r.Put("/schedules/{id}", hv.putSchedule())
+		r.Delete("/schedules/{id}", hv.deleteSchedule())
+		r.Get("/schedules/{id}/runs", hv.getScheduleRuns())
+		r.Post("/schedules/{id}/run", hv.postScheduleRun())
+		r.Get("/config-profiles", hv.getProfiles())
+		r.Post("/config-profiles", hv.postProfile())
+		r.Get("/config-profiles/{name}", hv.getProfile())
+		r.Put("/config-profiles/{name}", hv.putProfile())
+		r.Delete("/config-profiles/{name}", hv.deleteProfile())
+		r.Get("/reports/drift", hv.getDriftReport())
+		r.Get("/config-snapshots", hv.getSnapshots())
+		r.Get("/config-snapshots/{id}", hv.getSnapshot())
+		r.Delete("/config-snapshots/{id}", hv.deleteSnapshot())
+		r.Post("/tools/decode-rule", hv.postDecodeRule())
+		r.Get("/apps/binaries", hv.getAppBinaries())
+		r.Post("/apps/binaries", hv.postAppBinary())
+		r.Get("/apps/binaries/{name}", hv.getAppBinary())
+		r.Delete("/apps/binaries/{name}", hv.deleteAppBinary())
+	})
+}
+
+// visorRoutes registers the routes of a single visor.
+// The visor is identified by either the 'pk' or the 'name' URL parameter.
+func (hv *Hypervisor) visorRoutes(r chi.Router) {
+	// Files are uploaded as raw request bodies, which are not limited.
+	r.Post("/files", hv.postFile())
+
+	r.Group(func(r chi.Router) {
+		r.Use(limitBody(hv.c.RateLimits.MaxBodySize))
+
+		r.Get("/", hv.getVisor())
+		r.Put("/name", hv.putVisorName())
+		r.Put("/alias", hv.putVisorAlias())
+		r.Get("/health", hv.getHealth())
+		r.Post("/ping", hv.postPing())
+		r.Get("/uptime", hv.getUptime())
+		r.Get("/uptime/stats", hv.getUptimeStats())
+		r.Get("/resources", hv.getResources())
+		r.Get("/apps", hv.getApps())
+		r.Post("/apps/install", hv.postAppInstall())
+		r.Get("/apps/{app}", hv.getApp())
+		r.Put("/apps/{app}", hv.putApp())
+		r.Get("/apps/{app}/logs", hv.appLogsSince())
+		r.Get("/apps/{app}/connections", hv.getAppConnections())
+		r.Get("/apps/{app}/usage", hv.getAppUsage())
+		r.Get("/apps/{app}/settings", hv.getAppSettings())
+		r.Put("/apps/{app}/settings/{key}", hv.putAppSetting())
+		r.Post("/apps/{app}/update", hv.updateApp())
+		r.Get("/transport-types", hv.getTransportTypes())
+		r.Get("/transports", hv.getTransports())
+		r.Post("/transports", hv.postTransport())
+		r.Delete("/transports", hv.deleteTransports())
+		r.Get("/transports/{tid}", hv.getTransport())
+		r.Delete("/transports/{tid}", hv.deleteTransport())
+		r.Get("/transports/{tid}/stats", hv.getTransportStats())
+		r.Get("/transport-policies", hv.getTransportPolicies())
+		r.Put("/transport-policies", hv.putTransportPolicies())
+		r.Get("/transport-maintenance", hv.getTransportMaintenance())
+		r.Put("/transport-maintenance", hv.putTransportMaintenance())
+		r.Get("/routes", hv.getRoutes())
+		r.Post("/routes", hv.postRoute())
+		r.Delete("/routes", hv.deleteRoutes())
+		r.Get("/routes/{rid}", hv.getRoute())
+		r.Put("/routes/{rid}", hv.putRoute())
+		r.Delete("/routes/{rid}", hv.deleteRoute())
+		r.Get("/routegroups", hv.getRouteGroups())
+		r.Get("/config", hv.getVisorConfig())
+		r.Post("/diagnostics", hv.postVisorDiagnostics())
+		r.Put("/config", hv.putVisorConfig())
+		r.Get("/config/snapshots", hv.getVisorSnapshots())
+		r.Post("/config/snapshots", hv.postVisorSnapshot())
+		r.Post("/config/restore", hv.postVisorRestore())
+		r.Post("/restart", hv.restart())
+		r.Post("/drain", hv.drain())
+		r.Post("/reboot", hv.power(true))
+		r.Post("/shutdown", hv.power(false))
+		r.Post("/exec", hv.exec())
+		r.Get("/exec/stream", hv.execStream())
+		r.Get("/events", hv.eventStream())
+		r.Post("/update", hv.update())
+		r.Get("/update/available", hv.updateAvailable())
+		r.Post("/connectivity-test", hv.connectivityTest())
+		r.Get("/active-sessions", hv.getActiveSessions())
+		r.Get("/wake-config", hv.getWakeConfig())
+		r.Put("/wake-config", hv.putWakeConfig())
+		r.Delete("/wake-config", hv.deleteWakeConfig())
+		r.Post("/wake", hv.postWake())
+		r.Get("/labels", hv.getVisorLabels())
+		r.Put("/labels", hv.putVisorLabels())
+		r.Get("/files", hv.getFile())
+	})
+}
+
 func (hv *Hypervisor) getPong() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if _, err := w.Write([]byte(`"PONG!"`)); err != nil {
@@ -276,53 +637,123 @@ func (hv *Hypervisor) getUptime() http.HandlerFunc {
 	})
 }
 
+// getUptimeStats gets given visor's uptime across its restarts, boot count and last shutdown reason
+func (hv *Hypervisor) getUptimeStats() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		stats, err := ctx.RPC.UptimeStats()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, stats)
+	})
+}
+
+func (hv *Hypervisor) getResources() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		res, err := ctx.RPC.Resources()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, res)
+	})
+}
+
 type summaryResp struct {
 	TCPAddr string `json:"tcp_addr"`
 	Online  bool   `json:"online"`
+	Name    string `json:"name,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+	Notes   string `json:"notes,omitempty"`
+	// Moving average of RPC round trip times, measured while tracking uptimes.
+	LatencyMs float64 `json:"rpc_latency_ms,omitempty"`
+	// Last dmsg probe of the visor.
+	Probe *ProbeResult `json:"probe,omitempty"`
 	*visor.Summary
 }
 
 // provides summary of all visors.
 func (hv *Hypervisor) getVisors() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		hv.mu.RLock()
-		wg := new(sync.WaitGroup)
-		wg.Add(len(hv.visors))
-		summaries, i := make([]summaryResp, len(hv.visors)), 0
-
-		for pk, c := range hv.visors {
-			go func(pk cipher.PubKey, c VisorConn, i int) {
-				log := log.
-					WithField("visor_addr", c.Addr).
-					WithField("func", "getVisors")
-
-				log.Debug("Requesting summary via RPC.")
-
-				summary, err := c.RPC.Summary()
-				if err != nil {
-					log.WithError(err).
-						Warn("Failed to obtain summary via RPC.")
-					summary = &visor.Summary{PubKey: pk}
-				} else {
-					log.Debug("Obtained summary via RPC.")
-				}
-				summaries[i] = summaryResp{
-					TCPAddr: c.Addr.String(),
-					Online:  err == nil,
-					Summary: summary,
-				}
-				wg.Done()
-			}(pk, c, i)
-			i++
+		sortKey := r.URL.Query().Get("sort")
+		if sortKey != "" && sortKey != "latency" {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrBadSortKey)
+			return
 		}
 
-		wg.Wait()
-		hv.mu.RUnlock()
+		summaries, err := hv.visorSummaries()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if sortKey == "latency" {
+			sortSummariesByLatency(summaries)
+		}
 
 		httputil.WriteJSON(w, r, http.StatusOK, summaries)
 	}
 }
 
+// visorSummaries concurrently obtains summaries of all connected visors.
+func (hv *Hypervisor) visorSummaries() ([]summaryResp, error) {
+	names, err := hv.names.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := hv.meta.Metas()
+	if err != nil {
+		return nil, err
+	}
+
+	hv.mu.RLock()
+	wg := new(sync.WaitGroup)
+	wg.Add(len(hv.visors))
+	summaries, i := make([]summaryResp, len(hv.visors)), 0
+
+	for pk, c := range hv.visors {
+		go func(pk cipher.PubKey, c VisorConn, i int) {
+			log := log.
+				WithField("visor_addr", c.Addr).
+				WithField("func", "getVisors")
+
+			log.Debug("Requesting summary via RPC.")
+
+			summary, err := c.RPC.Summary()
+			if err != nil {
+				log.WithError(err).
+					Warn("Failed to obtain summary via RPC.")
+				summary = &visor.Summary{PubKey: pk}
+			} else {
+				log.Debug("Obtained summary via RPC.")
+			}
+			summaries[i] = summaryResp{
+				TCPAddr: c.Addr.String(),
+				Online:  err == nil,
+				Name:    names[pk],
+				Alias:   metas[pk].Alias,
+				Notes:   metas[pk].Notes,
+				Summary: summary,
+			}
+			if latency, ok := hv.latencies.get(pk); ok {
+				summaries[i].LatencyMs = durationMs(latency)
+			}
+			summaries[i].Probe, _ = hv.probes.get(pk)
+			wg.Done()
+		}(pk, c, i)
+		i++
+	}
+
+	wg.Wait()
+	hv.mu.RUnlock()
+
+	return summaries, nil
+}
+
 // provides summary of single visor.
 func (hv *Hypervisor) getVisor() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
@@ -332,8 +763,26 @@ func (hv *Hypervisor) getVisor() http.HandlerFunc {
 			return
 		}
 
+		name, err := hv.names.Name(ctx.Addr.PK)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		meta, err := hv.meta.Meta(ctx.Addr.PK)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		probe, _ := hv.probes.get(ctx.Addr.PK)
+
 		httputil.WriteJSON(w, r, http.StatusOK, summaryResp{
 			TCPAddr: ctx.Addr.String(),
+			Name:    name,
+			Alias:   meta.Alias,
+			Notes:   meta.Notes,
+			Probe:   probe,
 			Summary: summary,
 		})
 	})
@@ -341,7 +790,9 @@ func (hv *Hypervisor) getVisor() http.HandlerFunc {
 
 func (hv *Hypervisor) getPty() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		ctx.PtyUI.Handler()(w, r)
+		defer hv.activity.begin(ctx.Addr.PK, ActivityPty, r)()
+
+		ctx.PtyUI.Handler()(hv.recordPty(w, r, ctx.Addr.PK), r)
 	})
 }
 
@@ -370,10 +821,12 @@ func (hv *Hypervisor) getApp() http.HandlerFunc {
 func (hv *Hypervisor) putApp() http.HandlerFunc {
 	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
 		var reqBody struct {
-			AutoStart *bool          `json:"autostart,omitempty"`
-			Status    *int           `json:"status,omitempty"`
-			Passcode  *string        `json:"passcode,omitempty"`
-			PK        *cipher.PubKey `json:"pk,omitempty"`
+			AutoStart *bool              `json:"autostart,omitempty"`
+			Status    *int               `json:"status,omitempty"`
+			Passcode  *string            `json:"passcode,omitempty"`
+			PK        *cipher.PubKey     `json:"pk,omitempty"`
+			Args      *[]string          `json:"args,omitempty"`
+			Env       *map[string]string `json:"env,omitempty"`
 		}
 
 		if err := httputil.ReadJSON(r, &reqBody); err != nil {
@@ -400,25 +853,43 @@ func (hv *Hypervisor) putApp() http.HandlerFunc {
 			skysocksClientName = "skysocks-client"
 		)
 
-		if reqBody.Passcode != nil && ctx.App.Name == skysocksName {
-			if err := ctx.RPC.SetSocksPassword(*reqBody.Passcode); err != nil {
-				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		// 'passcode' and 'pk' are kept for clients which predate app settings.
+		if reqBody.Passcode != nil && ctx.App.Binary == skysocksName {
+			if err := ctx.RPC.SetAppSetting(ctx.App.Name, "passcode", *reqBody.Passcode); err != nil {
+				httputil.WriteJSON(w, r, appConfigStatus(err), err)
 				return
 			}
 		}
 
-		if reqBody.PK != nil && ctx.App.Name == skysocksClientName {
-			log.Errorf("SETTING PK: %s", *reqBody.PK)
-			if err := ctx.RPC.SetSocksClientPK(*reqBody.PK); err != nil {
-				log.Errorf("ERROR SETTING PK")
-				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		if reqBody.PK != nil && ctx.App.Binary == skysocksClientName {
+			if err := ctx.RPC.SetAppSetting(ctx.App.Name, "srv", reqBody.PK.String()); err != nil {
+				httputil.WriteJSON(w, r, appConfigStatus(err), err)
+				return
+			}
+		}
+
+		if reqBody.Args != nil {
+			if err := ctx.RPC.SetAppArgs(ctx.App.Name, *reqBody.Args); err != nil {
+				httputil.WriteJSON(w, r, appConfigStatus(err), err)
+				return
+			}
+
+			ctx.App.Args = *reqBody.Args
+		}
+
+		if reqBody.Env != nil {
+			if err := ctx.RPC.SetAppEnv(ctx.App.Name, *reqBody.Env); err != nil {
+				httputil.WriteJSON(w, r, appConfigStatus(err), err)
 				return
 			}
+
+			ctx.App.Env = *reqBody.Env
 		}
 
 		if reqBody.Status != nil {
 			switch *reqBody.Status {
 			case statusStop:
+				hv.notifier.expectStop(ctx.Addr.PK, ctx.App.Name)
 				if err := ctx.RPC.StopApp(ctx.App.Name); err != nil {
 					httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 					return
@@ -442,12 +913,93 @@ func (hv *Hypervisor) putApp() http.HandlerFunc {
 
 // LogsRes parses logs as json, along with the last obtained timestamp for use on subsequent requests
 type LogsRes struct {
-	LastLogTimestamp string   `json:"last_log_timestamp"`
+	LastLogTimestamp string   `json:"last_log_timestamp,omitempty"`
+	LastSeq          uint64   `json:"last_seq,omitempty"` // Set when logs are requested by 'after_seq'.
 	Logs             []string `json:"logs"`
 }
 
+type appConnResp struct {
+	appserver.ConnSummary
+	Duration string `json:"duration"`
+}
+
+func (hv *Hypervisor) getAppUsage() http.HandlerFunc {
+	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		usage, err := ctx.RPC.AppUsage(ctx.App.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, usage)
+	})
+}
+
+func (hv *Hypervisor) getAppSettings() http.HandlerFunc {
+	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		settings, err := ctx.RPC.GetAppSettings(ctx.App.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, settings)
+	})
+}
+
+func (hv *Hypervisor) putAppSetting() http.HandlerFunc {
+	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Value *string `json:"value"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil || reqBody.Value == nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		if err := ctx.RPC.SetAppSetting(ctx.App.Name, chi.URLParam(r, "key"), *reqBody.Value); err != nil {
+			httputil.WriteJSON(w, r, appConfigStatus(err), err)
+			return
+		}
+
+		settings, err := ctx.RPC.GetAppSettings(ctx.App.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, settings)
+	})
+}
+
+func (hv *Hypervisor) getAppConnections() http.HandlerFunc {
+	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		conns, err := ctx.RPC.AppConnections(ctx.App.Name)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := make([]appConnResp, 0, len(conns))
+		for _, c := range conns {
+			resp = append(resp, appConnResp{
+				ConnSummary: c,
+				Duration:    time.Since(c.Started).Round(time.Second).String(),
+			})
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, resp)
+	})
+}
+
 func (hv *Hypervisor) appLogsSince() http.HandlerFunc {
 	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if afterSeq := r.URL.Query().Get("after_seq"); afterSeq != "" {
+			hv.appLogsAfter(w, r, ctx, afterSeq)
+			return
+		}
+
 		since := r.URL.Query().Get("since")
 		since = strings.Replace(since, " ", "+", 1) // we need to put '+' again that was replaced in the query string
 
@@ -457,7 +1009,21 @@ func (hv *Hypervisor) appLogsSince() http.HandlerFunc {
 			t = time.Unix(0, 0)
 		}
 
-		logs, err := ctx.RPC.LogsSince(t, ctx.App.Name)
+		filter := app.LogFilter{Level: r.URL.Query().Get("level")}
+
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+		}
+
+		if err := filter.Validate(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		logs, err := ctx.RPC.LogsSince(t, ctx.App.Name, filter)
 		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
@@ -475,20 +1041,71 @@ func (hv *Hypervisor) appLogsSince() http.HandlerFunc {
 	})
 }
 
+// appLogsAfter responds with the logs after the sequence number of the last log the client already has.
+// Unlike logs since a timestamp, no new logs is not an error, so clients can poll with the returned 'last_seq'.
+func (hv *Hypervisor) appLogsAfter(w http.ResponseWriter, r *http.Request, ctx *httpCtx, afterSeq string) {
+	seq, err := strconv.ParseUint(afterSeq, 10, 64)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+		return
+	}
+
+	entries, err := ctx.RPC.LogsAfter(ctx.App.Name, seq)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := &LogsRes{LastSeq: seq, Logs: make([]string, 0, len(entries))}
+
+	for _, e := range entries {
+		res.Logs = append(res.Logs, e.Log)
+		res.LastSeq = e.Seq
+	}
+
+	if n := len(res.Logs); n > 0 {
+		if _, ok := logTimestamp(res.Logs[n-1]); ok {
+			res.LastLogTimestamp = app.TimestampFromLog(res.Logs[n-1])
+		}
+	}
+
+	httputil.WriteJSON(w, r, http.StatusOK, res)
+}
+
 func (hv *Hypervisor) getTransportTypes() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		types, err := ctx.RPC.TransportTypes()
+		withCaps, err := httputil.BoolFromQuery(r, "capabilities", false)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		infos, err := transportTypeInfos(ctx.RPC)
 		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
+		if withCaps {
+			httputil.WriteJSON(w, r, http.StatusOK, infos)
+			return
+		}
+
+		types := make([]string, 0, len(infos))
+		for _, info := range infos {
+			types = append(types, info.Type)
+		}
+
 		httputil.WriteJSON(w, r, http.StatusOK, types)
 	})
 }
 
 func (hv *Hypervisor) getTransports() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if hv.serveWatch(w, r, ctx, watchTransports) {
+			return
+		}
+
 		qTypes := strSliceFromQuery(r, "type", nil)
 
 		qPKs, err := pkSliceFromQuery(r, "pk", nil)
@@ -503,22 +1120,69 @@ func (hv *Hypervisor) getTransports() http.HandlerFunc {
 			return
 		}
 
-		transports, err := ctx.RPC.Transports(qTypes, qPKs, qLogs)
+		q, err := transportsQueryFromRequest(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		transports, err := ctx.RPC.Transports(qTypes, qPKs, qLogs || q.needsLogs())
 		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
+
+		if qTypes == nil && qPKs == nil {
+			objects := make(map[string]interface{}, len(transports))
+			for _, tp := range transports {
+				stripped := *tp
+				stripped.Log = nil
+				objects[tp.ID.String()] = &stripped
+			}
+
+			hv.observeList(w, ctx.Addr.PK, watchTransports, objects)
+		}
+
+		transports = q.apply(transports)
+
+		if !qLogs {
+			for i, tp := range transports {
+				stripped := *tp
+				stripped.Log = nil
+				transports[i] = &stripped
+			}
+		}
+
 		httputil.WriteJSON(w, r, http.StatusOK, transports)
 	})
 }
 
+// postTransportReq is the request body of transport creation.
+// Types of 'transport_types' are tried in order until one succeeds, each up to the attempts of 'retry'.
+type postTransportReq struct {
+	TpType  string          `json:"transport_type"`
+	TpTypes []string        `json:"transport_types,omitempty"` // Replaces 'transport_type'.
+	Remote  cipher.PubKey   `json:"remote_pk"`
+	Public  bool            `json:"public"`
+	Retry   *TransportRetry `json:"retry,omitempty"`
+	Timeout visor.Duration  `json:"timeout,omitempty"` // Defaults to the time all attempts may take.
+}
+
+// postTransportResp is the created transport, and the attempts made to create it.
+type postTransportResp struct {
+	*visor.TransportSummary
+	Attempts []TransportAttempt `json:"attempts"`
+}
+
+// postTransportErr is the response of failed transport creations.
+type postTransportErr struct {
+	Error    string             `json:"error"`
+	Attempts []TransportAttempt `json:"attempts"`
+}
+
 func (hv *Hypervisor) postTransport() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		var reqBody struct {
-			TpType string        `json:"transport_type"`
-			Remote cipher.PubKey `json:"remote_pk"`
-			Public bool          `json:"public"`
-		}
+		var reqBody postTransportReq
 
 		if err := httputil.ReadJSON(r, &reqBody); err != nil {
 			if err != io.EOF {
@@ -530,14 +1194,66 @@ func (hv *Hypervisor) postTransport() http.HandlerFunc {
 			return
 		}
 
-		const timeout = 30 * time.Second
-		summary, err := ctx.RPC.AddTransport(reqBody.Remote, reqBody.TpType, reqBody.Public, timeout)
+		types := reqBody.TpTypes
+		if len(types) == 0 {
+			types = []string{reqBody.TpType}
+		}
+
+		var retry TransportRetry
+		if reqBody.Retry != nil {
+			retry = *reqBody.Retry
+		}
+
+		if err := retry.fill(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		timeout := reqBody.Timeout
+		if budget := retry.budget(len(types)); timeout == 0 && budget > transportCreateTimeout {
+			if budget > hv.c.MaxRequestTimeout {
+				budget = hv.c.MaxRequestTimeout
+			}
+
+			timeout = visor.Duration(budget)
+		}
+
+		if _, err := hv.extendDeadline(r, timeout); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		infos, err := transportTypeInfos(ctx.RPC)
 		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		httputil.WriteJSON(w, r, http.StatusOK, summary)
+		for _, tpType := range types {
+			if !supportsTransportType(infos, tpType) {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, fmt.Errorf("%w: %q", ErrUnsupportedTransportType, tpType))
+				return
+			}
+		}
+
+		summary, attempts, err := addTransport(r.Context(), ctx.RPC, reqBody.Remote, types, reqBody.Public, retry)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == visor.ErrRPCTimeout {
+				status, err = http.StatusGatewayTimeout, ErrRequestTimeout
+			}
+
+			httputil.WriteJSON(w, r, status, postTransportErr{Error: err.Error(), Attempts: attempts})
+
+			return
+		}
+
+		hv.routes.invalidate(ctx.Addr.PK)
+		hv.routes.invalidate(reqBody.Remote)
+		hv.watches.poke(ctx.Addr.PK, watchTransports)
+		hv.watches.poke(reqBody.Remote, watchTransports)
+
+		httputil.WriteJSON(w, r, http.StatusOK, postTransportResp{TransportSummary: summary, Attempts: attempts})
 	})
 }
 
@@ -547,14 +1263,68 @@ func (hv *Hypervisor) getTransport() http.HandlerFunc {
 	})
 }
 
-func (hv *Hypervisor) deleteTransport() http.HandlerFunc {
-	return hv.withCtx(hv.tpCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		if err := ctx.RPC.RemoveTransport(ctx.Tp.ID); err != nil {
+func (hv *Hypervisor) deleteTransport() http.HandlerFunc {
+	return hv.withCtx(hv.tpCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if err := ctx.RPC.RemoveTransport(ctx.Tp.ID); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		hv.routes.invalidate(ctx.Tp.Local)
+		hv.routes.invalidate(ctx.Tp.Remote)
+		hv.watches.poke(ctx.Tp.Local, watchTransports)
+		hv.watches.poke(ctx.Tp.Remote, watchTransports)
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	})
+}
+
+// RemovedTransports is the response of bulk transport removals.
+type RemovedTransports struct {
+	Removed []uuid.UUID `json:"removed"`
+}
+
+// deleteTransports removes the transports of the visor, filtered by the 'type' and 'remote' queries like getTransports.
+// All transports are removed when no filter is given.
+func (hv *Hypervisor) deleteTransports() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		qTypes := strSliceFromQuery(r, "type", nil)
+
+		qRemotes, err := pkSliceFromQuery(r, "remote", nil)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		transports, err := ctx.RPC.Transports(qTypes, qRemotes, false)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := RemovedTransports{Removed: make([]uuid.UUID, 0, len(transports))}
+
+		for _, tp := range transports {
+			if err = ctx.RPC.RemoveTransport(tp.ID); err != nil {
+				break
+			}
+
+			resp.Removed = append(resp.Removed, tp.ID)
+			hv.routes.invalidate(tp.Remote)
+			hv.watches.poke(tp.Remote, watchTransports)
+		}
+
+		if len(resp.Removed) > 0 {
+			hv.routes.invalidate(ctx.Addr.PK)
+			hv.watches.poke(ctx.Addr.PK, watchTransports)
+		}
+
+		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
-		httputil.WriteJSON(w, r, http.StatusOK, true)
+		httputil.WriteJSON(w, r, http.StatusOK, resp)
 	})
 }
 
@@ -579,6 +1349,10 @@ func makeRoutingRuleResp(key routing.RouteID, rule routing.Rule, summary bool) r
 
 func (hv *Hypervisor) getRoutes() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if hv.serveWatch(w, r, ctx, watchRoutes) {
+			return
+		}
+
 		qSummary, err := httputil.BoolFromQuery(r, "summary", false)
 		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
@@ -591,12 +1365,21 @@ func (hv *Hypervisor) getRoutes() http.HandlerFunc {
 			return
 		}
 
-		resp := make([]routingRuleResp, len(rules))
-		for i, rule := range rules {
-			resp[i] = makeRoutingRuleResp(rule.KeyRouteID(), rule, qSummary)
+		objects := make(map[string]interface{}, len(rules))
+		for _, rule := range rules {
+			objects[ruleWatchKey(rule.KeyRouteID())] = makeRoutingRuleResp(rule.KeyRouteID(), rule, true)
 		}
 
-		httputil.WriteJSON(w, r, http.StatusOK, resp)
+		hv.observeList(w, ctx.Addr.PK, watchRoutes, objects)
+
+		// Rule tables may be huge, so responses are encoded and sent as they are made.
+		err = streamJSONArray(w, http.StatusOK, len(rules), func(i int) interface{} {
+			return makeRoutingRuleResp(rules[i].KeyRouteID(), rules[i], qSummary)
+		})
+
+		if err != nil {
+			log.WithError(err).WithField("visor_pk", ctx.Addr.PK).Warn("Failed to stream routing rules.")
+		}
 	})
 }
 
@@ -619,11 +1402,17 @@ func (hv *Hypervisor) postRoute() http.HandlerFunc {
 			return
 		}
 
+		if !hv.checkRuleConflict(w, r, ctx, rule, false) {
+			return
+		}
+
 		if err := ctx.RPC.SaveRoutingRule(rule); err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
+		hv.watches.poke(ctx.Addr.PK, watchRoutes)
+
 		httputil.WriteJSON(w, r, http.StatusOK, makeRoutingRuleResp(rule.KeyRouteID(), rule, true))
 	})
 }
@@ -665,15 +1454,49 @@ func (hv *Hypervisor) putRoute() http.HandlerFunc {
 			return
 		}
 
+		if rule.KeyRouteID() != ctx.RtKey {
+			errMsg := fmt.Errorf("route ID of rule (%v) does not match route ID in path (%v)", rule.KeyRouteID(), ctx.RtKey)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, errMsg)
+			return
+		}
+
+		if !hv.checkRuleConflict(w, r, ctx, rule, true) {
+			return
+		}
+
 		if err := ctx.RPC.SaveRoutingRule(rule); err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
+		hv.watches.poke(ctx.Addr.PK, watchRoutes)
+
 		httputil.WriteJSON(w, r, http.StatusOK, makeRoutingRuleResp(ctx.RtKey, rule, true))
 	})
 }
 
+type ruleConflictResp struct {
+	Error    string                `json:"error"`
+	Conflict *routing.RuleConflict `json:"conflict"`
+}
+
+// checkRuleConflict checks the rule against the visor's routing table before it is saved.
+// On conflict, it writes a 409 response and returns false.
+func (hv *Hypervisor) checkRuleConflict(w http.ResponseWriter, r *http.Request, ctx *httpCtx, rule routing.Rule, replace bool) bool {
+	rules, err := ctx.RPC.RoutingRules()
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return false
+	}
+
+	if conflict := routing.FindRuleConflict(rules, rule, replace); conflict != nil {
+		httputil.WriteJSON(w, r, http.StatusConflict, ruleConflictResp{Error: conflict.Error(), Conflict: conflict})
+		return false
+	}
+
+	return true
+}
+
 func (hv *Hypervisor) deleteRoute() http.HandlerFunc {
 	return hv.withCtx(hv.routeCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
 		if err := ctx.RPC.RemoveRoutingRule(ctx.RtKey); err != nil {
@@ -681,10 +1504,96 @@ func (hv *Hypervisor) deleteRoute() http.HandlerFunc {
 			return
 		}
 
+		hv.watches.poke(ctx.Addr.PK, watchRoutes)
+
 		httputil.WriteJSON(w, r, http.StatusOK, true)
 	})
 }
 
+// ErrExpiredRoutesFilter occurs when expired routes are removed with other filters.
+var ErrExpiredRoutesFilter = errors.New("expired routes can not be filtered further, as their details are not listed")
+
+// RemovedRoutes is the response of bulk route removals.
+type RemovedRoutes struct {
+	Removed []routing.RouteID `json:"removed"`
+}
+
+// deleteRoutes removes the routing rules of the visor, filtered by the 'type' (rule type) and 'transport'
+// (next transport ID) queries. With 'expired=true', only rules of which keep-alive timeout is exceeded are removed,
+// without waiting for the visor to collect them.
+// All rules are removed when no filter is given.
+func (hv *Hypervisor) deleteRoutes() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		qExpired, err := httputil.BoolFromQuery(r, "expired", false)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		qTypes := strSliceFromQuery(r, "type", nil)
+		qTransports := strSliceFromQuery(r, "transport", nil)
+
+		tids := make(map[uuid.UUID]bool, len(qTransports))
+		for _, s := range qTransports {
+			tid, err := uuid.Parse(s)
+			if err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			tids[tid] = true
+		}
+
+		var keys []routing.RouteID
+
+		if qExpired {
+			if qTypes != nil || qTransports != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrExpiredRoutesFilter)
+				return
+			}
+		} else {
+			rules, err := ctx.RPC.RoutingRules()
+			if err != nil {
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			for _, rule := range rules {
+				if qTypes != nil && !containsFold(qTypes, rule.Type().String()) {
+					continue
+				}
+
+				if qTransports != nil && (rule.Type() == routing.RuleConsume || !tids[rule.NextTransportID()]) {
+					continue
+				}
+
+				keys = append(keys, rule.KeyRouteID())
+			}
+		}
+
+		removed, err := ctx.RPC.RemoveRoutingRules(keys, qExpired)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		hv.watches.poke(ctx.Addr.PK, watchRoutes)
+
+		httputil.WriteJSON(w, r, http.StatusOK, RemovedRoutes{Removed: removed})
+	})
+}
+
+// containsFold reports whether s is in list, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
 type routeGroupResp struct {
 	routing.RuleConsumeFields
 	FwdRule routing.RuleForwardFields `json:"resp"`
@@ -718,14 +1627,151 @@ func (hv *Hypervisor) getRouteGroups() http.HandlerFunc {
 	})
 }
 
+func (hv *Hypervisor) getVisorConfig() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		conf, err := ctx.RPC.Config()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, json.RawMessage(conf))
+	})
+}
+
+// NOTE: If 'restart' is set, reply comes with a delay, as the visor is restarted to apply the config.
+func (hv *Hypervisor) putVisorConfig() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Config  json.RawMessage `json:"config"`
+			Restart bool            `json:"restart"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil || len(reqBody.Config) == 0 {
+			if err != nil && err != io.EOF {
+				log.Warnf("putVisorConfig request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := ctx.RPC.SetConfig(reqBody.Config, reqBody.Restart); err != nil {
+			if errors.Is(err, visor.ErrInvalidConfig) {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	})
+}
+
+// timeoutRequest is the optional request body of long-running maintenance endpoints.
+type timeoutRequest struct {
+	Timeout visor.Duration `json:"timeout,omitempty"` // Replaces the default HTTP timeout, up to 'max_request_timeout'.
+}
+
+// readTimeout reads the timeout of the optional request body and extends the request deadline accordingly.
+// It writes an error response and returns false on failure.
+func (hv *Hypervisor) readTimeout(w http.ResponseWriter, r *http.Request, name string) (time.Duration, bool) {
+	var reqBody timeoutRequest
+
+	if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+		log.Warnf("%s request: %v", name, err)
+		httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+		return 0, false
+	}
+
+	timeout, err := hv.extendDeadline(r, reqBody.Timeout)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	return timeout, true
+}
+
 // NOTE: Reply comes with a delay, because of check if new executable is started successfully.
 func (hv *Hypervisor) restart() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		if err := ctx.RPC.Restart(); err != nil {
+		timeout, ok := hv.readTimeout(w, r, "restart")
+		if !ok {
+			return
+		}
+
+		if !hv.checkActivity(w, r, ctx.Addr.PK) {
+			return
+		}
+
+		if err := ctx.RPC.Restart(timeout); err != nil {
+			if !writeDeadlineExceeded(w, r, err) {
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	})
+}
+
+// drain makes the visor refuse new transports and routes, and shut down once its routes are idle
+// or the 'timeout' of the request body passes.
+func (hv *Hypervisor) drain() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Timeout visor.Duration `json:"timeout,omitempty"` // Defaults to visor.DefaultDrainTimeout.
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		if !hv.checkActivity(w, r, ctx.Addr.PK) {
+			return
+		}
+
+		status, err := ctx.RPC.Drain(time.Duration(reqBody.Timeout))
+		if err != nil {
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
+		httputil.WriteJSON(w, r, http.StatusOK, status)
+	})
+}
+
+// power reboots the host of the visor, or shuts it down unless reboot is set, if enabled in the visor config.
+func (hv *Hypervisor) power(reboot bool) http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if !hv.checkActivity(w, r, ctx.Addr.PK) {
+			return
+		}
+
+		call := ctx.RPC.Shutdown
+		if reboot {
+			call = ctx.RPC.Reboot
+		}
+
+		if err := call(); err != nil {
+			status := http.StatusInternalServerError
+			if err.Error() == visor.ErrPowerDisabled.Error() {
+				status = http.StatusForbidden
+			}
+
+			httputil.WriteJSON(w, r, status, err)
+
+			return
+		}
+
 		httputil.WriteJSON(w, r, http.StatusOK, true)
 	})
 }
@@ -734,7 +1780,8 @@ func (hv *Hypervisor) restart() http.HandlerFunc {
 func (hv *Hypervisor) exec() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
 		var reqBody struct {
-			Command string `json:"command"`
+			Command string         `json:"command"`
+			Timeout visor.Duration `json:"timeout,omitempty"` // The command is killed after this long, defaults to the HTTP timeout.
 		}
 
 		if err := httputil.ReadJSON(r, &reqBody); err != nil {
@@ -747,9 +1794,26 @@ func (hv *Hypervisor) exec() http.HandlerFunc {
 			return
 		}
 
-		out, err := ctx.RPC.Exec(reqBody.Command)
+		timeout, err := hv.extendDeadline(r, reqBody.Timeout)
 		if err != nil {
-			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		end := hv.activity.begin(ctx.Addr.PK, ActivityExec, r)
+		out, err := ctx.RPC.Exec(reqBody.Command, timeout)
+		end()
+
+		if err != nil && (err.Error() == visor.ErrExecDisabled.Error() || err.Error() == visor.ErrExecNotAllowed.Error()) {
+			httputil.WriteJSON(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		if err != nil {
+			if !writeDeadlineExceeded(w, r, err) {
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
 			return
 		}
 
@@ -763,9 +1827,62 @@ func (hv *Hypervisor) exec() http.HandlerFunc {
 
 func (hv *Hypervisor) update() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
-		updated, err := ctx.RPC.Update()
+		timeout, ok := hv.readTimeout(w, r, "update")
+		if !ok {
+			return
+		}
+
+		if !hv.checkActivity(w, r, ctx.Addr.PK) {
+			return
+		}
+
+		updated, err := ctx.RPC.Update(timeout)
+		if err != nil {
+			if writeDeadlineExceeded(w, r, err) {
+				return
+			}
+
+			hv.notifier.Notify(EventUpdateFailed, ctx.Addr.PK, err.Error())
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		output := struct {
+			Updated bool `json:"updated"`
+		}{updated}
+
+		httputil.WriteJSON(w, r, http.StatusOK, output)
+	})
+}
+
+// appConfigStatus returns the HTTP status of errors of changing app configs over RPC.
+func appConfigStatus(err error) int {
+	if errors.Is(err, visor.ErrInvalidConfig) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// updateApp updates a single app of the visor to the latest release, restarting it if it is running.
+func (hv *Hypervisor) updateApp() http.HandlerFunc {
+	return hv.withCtx(hv.appCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		updated, err := ctx.RPC.UpdateApp(ctx.App.Name)
 		if err != nil {
+			if err.Error() == updater.ErrNotReleasedApp.Error() {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			if err.Error() == updater.ErrAlreadyStarted.Error() {
+				httputil.WriteJSON(w, r, http.StatusConflict, err)
+				return
+			}
+
+			hv.notifier.Notify(EventUpdateFailed, ctx.Addr.PK, fmt.Sprintf("%s: %v", ctx.App.Name, err))
 			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+
 			return
 		}
 
@@ -777,6 +1894,44 @@ func (hv *Hypervisor) update() http.HandlerFunc {
 	})
 }
 
+// connectivityTest runs a connectivity test of the visor.
+// The optional probe visor may be identified by a public key, a unique prefix or a name.
+func (hv *Hypervisor) connectivityTest() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Probe         string `json:"probe,omitempty"`
+			TransportType string `json:"transport_type,omitempty"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil && err != io.EOF {
+			log.Warnf("connectivityTest request: %v", err)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		var probe cipher.PubKey
+
+		if reqBody.Probe != "" {
+			pk, status, err := hv.resolveVisor(reqBody.Probe)
+			if err != nil {
+				httputil.WriteJSON(w, r, status, err)
+				return
+			}
+
+			probe = pk
+		}
+
+		report, err := ctx.RPC.ConnectivityTest(probe, reqBody.TransportType)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, report)
+	})
+}
+
 func (hv *Hypervisor) updateAvailable() http.HandlerFunc {
 	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
 		version, err := ctx.RPC.UpdateAvailable()
@@ -842,9 +1997,9 @@ func (hv *Hypervisor) withCtx(vFunc valuesFunc, hFunc handlerFunc) http.HandlerF
 }
 
 func (hv *Hypervisor) visorCtx(w http.ResponseWriter, r *http.Request) (*httpCtx, bool) {
-	pk, err := pkFromParam(r, "pk")
+	pk, status, err := hv.visorPK(r)
 	if err != nil {
-		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		httputil.WriteJSON(w, r, status, err)
 		return nil, false
 	}
 
@@ -855,6 +2010,11 @@ func (hv *Hypervisor) visorCtx(w http.ResponseWriter, r *http.Request) (*httpCtx
 		return nil, false
 	}
 
+	if t := traceFromRequest(r); t != nil {
+		t.VisorPK = pk
+		visor.RPC = tracedRPC(r, visor.RPC)
+	}
+
 	return &httpCtx{
 		VisorConn: visor,
 	}, true
@@ -935,13 +2095,6 @@ func (hv *Hypervisor) routeCtx(w http.ResponseWriter, r *http.Request) (*httpCtx
 	return ctx, true
 }
 
-func pkFromParam(r *http.Request, key string) (cipher.PubKey, error) {
-	pk := cipher.PubKey{}
-	err := pk.UnmarshalText([]byte(chi.URLParam(r, key)))
-
-	return pk, err
-}
-
 func uuidFromParam(r *http.Request, key string) (uuid.UUID, error) {
 	return uuid.Parse(chi.URLParam(r, key))
 }
@@ -983,3 +2136,17 @@ func pkSliceFromQuery(r *http.Request, key string, defaultVal []cipher.PubKey) (
 
 	return pks, nil
 }
+
+func intFromQuery(r *http.Request, key string, defaultVal int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return defaultVal, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid '%s' query value: %w", key, err)
+	}
+
+	return i, nil
+}
@@ -2,6 +2,7 @@ package hypervisor
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,12 +11,21 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/skycoin/src/util/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/app/appnet"
+	"github.com/skycoin/skywire/pkg/app/appserver"
+	"github.com/skycoin/skywire/pkg/util/updater"
+	"github.com/skycoin/skywire/pkg/visor"
 )
 
 func TestMain(m *testing.M) {
@@ -75,6 +85,14 @@ func TestNewNode(t *testing.T) {
 	t.Run("change_password", func(t *testing.T) {
 		testNodeChangePassword(t, config)
 	})
+
+	t.Run("revoke_session", func(t *testing.T) {
+		testNodeRevokeSession(t, config)
+	})
+
+	t.Run("login_rate_limit_headers", func(t *testing.T) {
+		testNodeLoginRateLimitHeaders(t, config)
+	})
 }
 
 func makeStartNode(t *testing.T, config Config) (string, *http.Client, func()) {
@@ -105,6 +123,27 @@ func makeStartNode(t *testing.T, config Config) (string, *http.Client, func()) {
 	}
 }
 
+// makeStartMockNode starts a hypervisor with mock visors and user management disabled.
+func makeStartMockNode(t *testing.T) (string, *http.Client, *Hypervisor, func()) {
+	config := makeConfig(false)
+
+	confDir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+
+	config.DBPath = filepath.Join(confDir, "users.db")
+
+	hv, err := New(nil, config)
+	require.NoError(t, err)
+	require.NoError(t, hv.AddMockData(MockConfig{Visors: 3, MaxTpsPerVisor: 3, MaxRoutesPerVisor: 3}))
+
+	srv := httptest.NewTLSServer(hv)
+
+	return srv.Listener.Addr().String(), srv.Client(), hv, func() {
+		srv.Close()
+		require.NoError(t, os.RemoveAll(confDir))
+	}
+}
+
 type TestCase struct {
 	ReqMethod  string
 	ReqURI     string
@@ -440,6 +479,114 @@ type ErrorBody struct {
 	Error string `json:"error"`
 }
 
+// - Create account.
+// - Login.
+// - List sessions (should contain the current session).
+// - Revoke the current session.
+// - Attempt action (should fail).
+func testNodeRevokeSession(t *testing.T, config Config) {
+	addr, client, stop := makeStartNode(t, config)
+	defer stop()
+
+	var sid string
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/create-account",
+			ReqBody:    strings.NewReader(goodPayload),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/login",
+			ReqBody:    strings.NewReader(goodPayload),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/user/sessions",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var sessions []struct {
+					SID       string `json:"sid"`
+					User      string `json:"username"`
+					IP        string `json:"ip"`
+					UserAgent string `json:"user_agent"`
+					Current   bool   `json:"current"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&sessions))
+				require.Len(t, sessions, 1)
+				assert.Equal(t, "admin", sessions[0].User)
+				assert.Equal(t, "127.0.0.1", sessions[0].IP)
+				assert.NotEmpty(t, sessions[0].UserAgent)
+				assert.True(t, sessions[0].Current)
+				sid = sessions[0].SID
+			},
+		},
+	})
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/user/sessions/not-a-uuid",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/user/sessions/" + sid,
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors",
+			RespStatus: http.StatusUnauthorized,
+			RespBody: func(t *testing.T, r *http.Response) {
+				body, err := decodeErrorBody(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, ErrBadSession.Error(), body.Error)
+			},
+		},
+	})
+}
+
+func testNodeLoginRateLimitHeaders(t *testing.T, config Config) {
+	addr, client, stop := makeStartNode(t, config)
+	defer stop()
+
+	limit := strconv.Itoa(config.LoginLimits.MaxFailures)
+	remaining := func(n int) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			assert.Equal(t, limit, r.Header.Get("X-RateLimit-Limit"))
+			assert.Equal(t, strconv.Itoa(n), r.Header.Get("X-RateLimit-Remaining"))
+			assert.NotEmpty(t, r.Header.Get("X-RateLimit-Reset"))
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/create-account",
+			ReqBody:    strings.NewReader(goodPayload),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/login",
+			ReqBody:    strings.NewReader(changedPasswordPayload),
+			RespStatus: http.StatusUnauthorized,
+			RespBody:   remaining(config.LoginLimits.MaxFailures - 1),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/login",
+			ReqBody:    strings.NewReader(goodPayload),
+			RespStatus: http.StatusOK,
+			RespBody:   remaining(config.LoginLimits.MaxFailures),
+		},
+	})
+}
+
 func decodeErrorBody(rb io.Reader) (*ErrorBody, error) {
 	b := new(ErrorBody)
 	dec := json.NewDecoder(rb)
@@ -447,3 +594,432 @@ func decodeErrorBody(rb io.Reader) (*ErrorBody, error) {
 
 	return b, dec.Decode(b)
 }
+
+func TestVisorConfig(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	var conf map[string]interface{}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/config", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&conf))
+				assert.Equal(t, "1.0", conf["version"])
+			},
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/config", pk),
+			ReqBody:    strings.NewReader(`{"config":{"log_level":"loud"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/config", pk),
+			ReqBody:    strings.NewReader(`{"config":{"version":"1.1","log_level":"debug"}}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/config", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&conf))
+				assert.Equal(t, "1.1", conf["version"])
+			},
+		},
+	})
+}
+
+func TestConnectivityTest(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	checkReport := func(probed bool) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var report visor.ConnectivityReport
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+			assert.True(t, report.Passed)
+			require.Len(t, report.Checks, 6)
+
+			for _, c := range report.Checks {
+				if c.Name == visor.CheckProbeTransport || c.Name == visor.CheckEcho {
+					assert.Equal(t, !probed, c.Skipped, c.Name)
+				}
+			}
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/connectivity-test", pks[0]),
+			RespStatus: http.StatusOK,
+			RespBody:   checkReport(false),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/connectivity-test", pks[0]),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"probe":%q}`, pks[1].Hex()[:12])),
+			RespStatus: http.StatusOK,
+			RespBody:   checkReport(true),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/connectivity-test", pks[0]),
+			ReqBody:    strings.NewReader(`{"probe":"unknown-visor"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+}
+
+// execRestrictedRPCClient refuses to execute commands, as a visor with restricted exec does.
+type execRestrictedRPCClient struct {
+	visor.RPCClient
+}
+
+func (execRestrictedRPCClient) Exec(command string, _ time.Duration) ([]byte, error) {
+	if command == "uptime" {
+		return []byte("up"), nil
+	}
+
+	return nil, errors.New(visor.ErrExecNotAllowed.Error()) // as received over RPC
+}
+
+func TestExecRestricted(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+
+	hv.mu.Lock()
+	for pk = range hv.visors {
+		break
+	}
+	c := hv.visors[pk]
+	c.RPC = execRestrictedRPCClient{RPCClient: c.RPC}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	uri := fmt.Sprintf("/api/v1/visors/%s/exec", pk)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"uptime"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"rm -rf /"}`),
+			RespStatus: http.StatusForbidden,
+		},
+	})
+}
+
+// connectedAppRPCClient reports a single app with a single connection.
+type connectedAppRPCClient struct {
+	visor.RPCClient
+	remote cipher.PubKey
+}
+
+func (connectedAppRPCClient) Apps() ([]*visor.AppState, error) {
+	return []*visor.AppState{{Name: "skysocks", Port: 3, Status: visor.AppStatusRunning}}, nil
+}
+
+func (c connectedAppRPCClient) AppConnections(string) ([]appserver.ConnSummary, error) {
+	return []appserver.ConnSummary{{
+		ID:      1,
+		Remote:  appnet.Addr{Net: appnet.TypeSkynet, PubKey: c.remote, Port: 1024},
+		Started: time.Now().Add(-time.Minute),
+		Sent:    100,
+	}}, nil
+}
+
+func TestGetAppConnections(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	remote, _ := cipher.GenerateKeyPair()
+
+	hv.mu.Lock()
+	for pk = range hv.visors {
+		break
+	}
+	c := hv.visors[pk]
+	c.RPC = connectedAppRPCClient{RPCClient: c.RPC, remote: remote}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/skysocks/connections", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var conns []appConnResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&conns))
+				require.Len(t, conns, 1)
+				assert.Equal(t, remote, conns[0].Remote.PubKey)
+				assert.Equal(t, uint64(100), conns[0].Sent)
+				assert.Equal(t, "1m0s", conns[0].Duration)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/unknown/connections", pk),
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestGetResources(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+
+	hv.mu.RLock()
+	for pk = range hv.visors {
+		break
+	}
+	hv.mu.RUnlock()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/resources", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var res visor.Resources
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+				assert.Greater(t, res.CPUs, 0)
+				assert.NotEmpty(t, res.Disks)
+				assert.NotEmpty(t, res.NICs)
+			},
+		},
+	})
+}
+
+type updateAppRPCClient struct {
+	visor.RPCClient
+}
+
+func (updateAppRPCClient) Apps() ([]*visor.AppState, error) {
+	return []*visor.AppState{{Name: "skysocks", Port: 3}, {Name: "custom", Port: 20}}, nil
+}
+
+func (updateAppRPCClient) UpdateApp(appName string) (bool, error) {
+	if appName != "skysocks" {
+		return false, updater.ErrNotReleasedApp
+	}
+
+	return true, nil
+}
+
+func TestUpdateApp(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+
+	hv.mu.Lock()
+	for pk = range hv.visors {
+		break
+	}
+	c := hv.visors[pk]
+	c.RPC = updateAppRPCClient{RPCClient: c.RPC}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/skysocks/update", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp struct {
+					Updated bool `json:"updated"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.True(t, resp.Updated)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/custom/update", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/unknown/update", pk),
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestPutAppArgsEnv(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0", pk),
+			ReqBody:    strings.NewReader(`{"args":["-addr",":8001"],"env":{"FOO_MODE":"fast"}}`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var app visor.AppState
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&app))
+				assert.Equal(t, []string{"-addr", ":8001"}, app.Args)
+				assert.Equal(t, map[string]string{"FOO_MODE": "fast"}, app.Env)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0", pk),
+			ReqBody:    strings.NewReader(`{"env":{"APP_KEY":"stolen"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var app visor.AppState
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&app))
+				assert.Equal(t, []string{"-addr", ":8001"}, app.Args)
+				assert.Equal(t, map[string]string{"FOO_MODE": "fast"}, app.Env)
+			},
+		},
+	})
+}
+
+type logsAfterRPCClient struct {
+	visor.RPCClient
+}
+
+func (logsAfterRPCClient) LogsAfter(_ string, seq uint64) ([]app.LogEntry, error) {
+	logs := []app.LogEntry{
+		{Seq: 1, Log: "[2020-01-01T00:00:00.000000000Z] first\n"},
+		{Seq: 2, Log: "[2020-01-01T00:00:01.000000000Z] second\n"},
+	}
+
+	return logs[seq:], nil
+}
+
+func TestAppLogsAfter(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+
+	hv.mu.Lock()
+	for pk = range hv.visors {
+		break
+	}
+	c := hv.visors[pk]
+	c.RPC = logsAfterRPCClient{RPCClient: c.RPC}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	logsResp := func(lastSeq uint64, n int) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var res LogsRes
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+			assert.Equal(t, lastSeq, res.LastSeq)
+			assert.Len(t, res.Logs, n)
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0/logs?after_seq=0", pk),
+			RespStatus: http.StatusOK,
+			RespBody:   logsResp(2, 2),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0/logs?after_seq=1", pk),
+			RespStatus: http.StatusOK,
+			RespBody:   logsResp(2, 1),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0/logs?after_seq=2", pk),
+			RespStatus: http.StatusOK,
+			RespBody:   logsResp(2, 0),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/apps/foo.v1.0/logs?after_seq=last", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+}
+
+// powerDisabledRPCClient refuses power calls, as a visor without power management enabled does.
+type powerDisabledRPCClient struct {
+	visor.RPCClient
+}
+
+func (powerDisabledRPCClient) Reboot() error {
+	return errors.New(visor.ErrPowerDisabled.Error()) // as received over RPC
+}
+
+func TestPower(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	hv.mu.Lock()
+	c := hv.visors[pks[1]]
+	c.RPC = powerDisabledRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[1]] = c
+	hv.mu.Unlock()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/reboot", pks[0]),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/shutdown", pks[0]),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/reboot", pks[1]),
+			RespStatus: http.StatusForbidden,
+		},
+	})
+}
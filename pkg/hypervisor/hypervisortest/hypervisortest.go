@@ -0,0 +1,469 @@
+// Package hypervisortest provides fake visors and a hypervisor serving them, for testing the hypervisor API.
+// Fake visors serve the visor RPC in memory, over net.Pipe connections, with configurable state, latency and failures.
+package hypervisortest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+var log = logging.MustGetLogger("hypervisortest") // nolint: gochecknoglobals
+
+// Config is the initial state of a fake visor.
+type Config struct {
+	PK         cipher.PubKey // Generated if null.
+	Apps       []*visor.AppState
+	Transports []*visor.TransportSummary
+	Rules      []routing.Rule
+	Health     *visor.HealthInfo // All components are healthy if nil.
+	Uptime     time.Duration
+	Latency    time.Duration // Delay of every RPC call.
+}
+
+// Visor is a fake visor.
+// Methods of the visor RPC which are not served respond with the error of net/rpc for unknown methods.
+type Visor struct {
+	mu       sync.Mutex
+	conf     Config
+	failures map[string]error
+	calls    map[string]int
+	conns    []net.Conn
+}
+
+// NewVisor creates a fake visor.
+func NewVisor(conf Config) *Visor {
+	if conf.PK.Null() {
+		conf.PK, _ = cipher.GenerateKeyPair()
+	}
+
+	if conf.Health == nil {
+		conf.Health = &visor.HealthInfo{TransportDiscovery: 200, RouteFinder: 200, SetupNode: 200}
+	}
+
+	return &Visor{
+		conf:     conf,
+		failures: make(map[string]error),
+		calls:    make(map[string]int),
+	}
+}
+
+// PK returns the public key of the visor.
+func (v *Visor) PK() cipher.PubKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.conf.PK
+}
+
+// Update changes the state of the visor.
+func (v *Visor) Update(fn func(conf *Config)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fn(&v.conf)
+}
+
+// SetLatency sets the delay of every RPC call.
+func (v *Visor) SetLatency(d time.Duration) {
+	v.Update(func(conf *Config) { conf.Latency = d })
+}
+
+// Fail makes calls of the RPC method fail with err, or succeed again if err is nil.
+func (v *Visor) Fail(method string, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err == nil {
+		delete(v.failures, method)
+		return
+	}
+
+	v.failures[method] = err
+}
+
+// Calls returns the number of calls of the RPC method.
+func (v *Visor) Calls(method string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.calls[method]
+}
+
+// Conn serves the visor RPC over a new in-memory connection, and returns the hypervisor's side of it.
+func (v *Visor) Conn() (hypervisor.VisorConn, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(visor.RPCPrefix, &gateway{v: v}); err != nil {
+		return hypervisor.VisorConn{}, err
+	}
+
+	hvConn, visorConn := net.Pipe()
+
+	v.mu.Lock()
+	v.conns = append(v.conns, hvConn, visorConn)
+	pk := v.conf.PK
+	v.mu.Unlock()
+
+	go srv.ServeCodec(rpcutil.NewServerCodec(visorConn, log.WithField("visor_pk", pk)))
+
+	return hypervisor.VisorConn{
+		Addr: dmsg.Addr{PK: pk, Port: skyenv.DmsgHypervisorPort},
+		RPC:  visor.NewRPCClient(rpc.NewClientWithCodec(rpcutil.NewClientCodec(hvConn)), visor.RPCPrefix),
+	}, nil
+}
+
+// Close closes the connections of the visor.
+func (v *Visor) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var firstErr error
+
+	for _, conn := range v.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	v.conns = nil
+
+	return firstErr
+}
+
+// call records a call of method, waits for the latency of the visor and returns its injected failure.
+func (v *Visor) call(method string) error {
+	v.mu.Lock()
+	v.calls[method]++
+	latency, err := v.conf.Latency, v.failures[method]
+	v.mu.Unlock()
+
+	time.Sleep(latency)
+
+	return err
+}
+
+// Connect connects the visors to hv.
+func Connect(hv *hypervisor.Hypervisor, visors ...*Visor) error {
+	for _, v := range visors {
+		conn, err := v.Conn()
+		if err != nil {
+			return err
+		}
+
+		hv.AddVisor(conn)
+	}
+
+	return nil
+}
+
+// Server is a hypervisor without user authentication, serving its API over HTTP.
+type Server struct {
+	*httptest.Server
+	Hypervisor *hypervisor.Hypervisor
+	dir        string
+}
+
+// NewServer starts a Server with the visors connected.
+// Its API is served under Server.URL + "/api/v1".
+func NewServer(visors ...*Visor) (*Server, error) {
+	dir, err := ioutil.TempDir("", "hypervisortest")
+	if err != nil {
+		return nil, err
+	}
+
+	conf := hypervisor.GenerateWorkDirConfig(true)
+	conf.DBPath = filepath.Join(dir, "users.db")
+	conf.EnableAuth = false
+
+	hv, err := hypervisor.New(nil, conf)
+	if err == nil {
+		err = Connect(hv, visors...)
+	}
+
+	if err != nil {
+		if rmErr := os.RemoveAll(dir); rmErr != nil {
+			log.WithError(rmErr).Warn("Failed to remove temporary directory.")
+		}
+
+		return nil, err
+	}
+
+	return &Server{Server: httptest.NewServer(hv), Hypervisor: hv, dir: dir}, nil
+}
+
+// Close stops serving, and removes the database of the hypervisor.
+func (s *Server) Close() {
+	s.Server.Close()
+
+	if err := os.RemoveAll(s.dir); err != nil {
+		log.WithError(err).Warn("Failed to remove temporary directory.")
+	}
+}
+
+// gateway serves the visor RPC of a fake visor.
+type gateway struct {
+	v *Visor
+}
+
+// Health implements the visor RPC.
+func (g *gateway) Health(_ *struct{}, out *visor.HealthInfo) error {
+	return g.do("Health", func(c *Config) error {
+		*out = *c.Health
+		return nil
+	})
+}
+
+// Uptime implements the visor RPC.
+func (g *gateway) Uptime(_ *struct{}, out *float64) error {
+	return g.do("Uptime", func(c *Config) error {
+		*out = c.Uptime.Seconds()
+		return nil
+	})
+}
+
+// Summary implements the visor RPC.
+func (g *gateway) Summary(_ *struct{}, out *visor.Summary) error {
+	return g.do("Summary", func(c *Config) error {
+		*out = visor.Summary{
+			PubKey:      c.PK,
+			Apps:        c.Apps,
+			Transports:  c.Transports,
+			RoutesCount: len(c.Rules),
+		}
+
+		return nil
+	})
+}
+
+// Apps implements the visor RPC.
+func (g *gateway) Apps(_ *struct{}, out *[]*visor.AppState) error {
+	return g.do("Apps", func(c *Config) error {
+		*out = c.Apps
+		return nil
+	})
+}
+
+// StartApp implements the visor RPC.
+func (g *gateway) StartApp(name *string, _ *struct{}) error {
+	return g.setAppStatus("StartApp", *name, visor.AppStatusRunning)
+}
+
+// StopApp implements the visor RPC.
+func (g *gateway) StopApp(name *string, _ *struct{}) error {
+	return g.setAppStatus("StopApp", *name, visor.AppStatusStopped)
+}
+
+func (g *gateway) setAppStatus(method, name string, status visor.AppStatus) error {
+	return g.do(method, func(c *Config) error {
+		for _, app := range c.Apps {
+			if app.Name == name {
+				app.Status = status
+				return nil
+			}
+		}
+
+		return fmt.Errorf("app %q not found", name)
+	})
+}
+
+// TransportTypes implements the visor RPC.
+func (g *gateway) TransportTypes(_ *struct{}, out *[]string) error {
+	return g.do("TransportTypes", func(*Config) error {
+		*out = []string{dmsg.Type, "stcp"}
+		return nil
+	})
+}
+
+// Transports implements the visor RPC.
+func (g *gateway) Transports(in *visor.TransportsIn, out *[]*visor.TransportSummary) error {
+	return g.do("Transports", func(c *Config) error {
+		tps := make([]*visor.TransportSummary, 0, len(c.Transports))
+
+		for _, tp := range c.Transports {
+			if len(in.FilterTypes) > 0 && !containsType(in.FilterTypes, tp.Type) {
+				continue
+			}
+
+			if len(in.FilterPubKeys) > 0 && !containsPK(in.FilterPubKeys, tp.Remote) {
+				continue
+			}
+
+			summary := *tp
+			if !in.ShowLogs {
+				summary.Log = nil
+			}
+
+			tps = append(tps, &summary)
+		}
+
+		*out = tps
+
+		return nil
+	})
+}
+
+// Transport implements the visor RPC.
+func (g *gateway) Transport(in *uuid.UUID, out *visor.TransportSummary) error {
+	return g.do("Transport", func(c *Config) error {
+		for _, tp := range c.Transports {
+			if tp.ID == *in {
+				*out = *tp
+				return nil
+			}
+		}
+
+		return fmt.Errorf("transport of id %q not found", *in)
+	})
+}
+
+// RemoveTransport implements the visor RPC.
+func (g *gateway) RemoveTransport(tid *uuid.UUID, _ *struct{}) error {
+	return g.do("RemoveTransport", func(c *Config) error {
+		for i, tp := range c.Transports {
+			if tp.ID == *tid {
+				c.Transports = append(c.Transports[:i:i], c.Transports[i+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("transport of id %q not found", *tid)
+	})
+}
+
+// RoutingRules implements the visor RPC.
+func (g *gateway) RoutingRules(_ *struct{}, out *[]routing.Rule) error {
+	return g.do("RoutingRules", func(c *Config) error {
+		*out = append([]routing.Rule{}, c.Rules...)
+		return nil
+	})
+}
+
+// RoutingRule implements the visor RPC.
+func (g *gateway) RoutingRule(key *routing.RouteID, out *routing.Rule) error {
+	return g.do("RoutingRule", func(c *Config) error {
+		for _, rule := range c.Rules {
+			if rule.KeyRouteID() == *key {
+				*out = rule
+				return nil
+			}
+		}
+
+		return fmt.Errorf("rule of id %v not found", *key)
+	})
+}
+
+// SaveRoutingRule implements the visor RPC.
+func (g *gateway) SaveRoutingRule(in *routing.Rule, _ *struct{}) error {
+	return g.do("SaveRoutingRule", func(c *Config) error {
+		for i, rule := range c.Rules {
+			if rule.KeyRouteID() == in.KeyRouteID() {
+				c.Rules[i] = *in
+				return nil
+			}
+		}
+
+		c.Rules = append(c.Rules, *in)
+
+		return nil
+	})
+}
+
+// RemoveRoutingRule implements the visor RPC.
+func (g *gateway) RemoveRoutingRule(key *routing.RouteID, _ *struct{}) error {
+	return g.do("RemoveRoutingRule", func(c *Config) error {
+		c.Rules = removeRules(c.Rules, []routing.RouteID{*key})
+		return nil
+	})
+}
+
+// RemoveRoutingRules implements the visor RPC.
+// Rules of fake visors do not expire.
+func (g *gateway) RemoveRoutingRules(in *visor.RemoveRoutingRulesIn, out *[]routing.RouteID) error {
+	return g.do("RemoveRoutingRules", func(c *Config) error {
+		removed := make([]routing.RouteID, 0, len(in.Keys))
+
+		for _, rule := range c.Rules {
+			for _, key := range in.Keys {
+				if rule.KeyRouteID() == key {
+					removed = append(removed, key)
+					break
+				}
+			}
+		}
+
+		c.Rules = removeRules(c.Rules, removed)
+		*out = removed
+
+		return nil
+	})
+}
+
+// do serves a call of method with fn, unless a failure is injected.
+func (g *gateway) do(method string, fn func(c *Config) error) error {
+	if err := g.v.call(method); err != nil {
+		return err
+	}
+
+	g.v.mu.Lock()
+	defer g.v.mu.Unlock()
+
+	return fn(&g.v.conf)
+}
+
+func removeRules(rules []routing.Rule, keys []routing.RouteID) []routing.Rule {
+	out := rules[:0:0]
+
+	for _, rule := range rules {
+		keep := true
+
+		for _, key := range keys {
+			if rule.KeyRouteID() == key {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			out = append(out, rule)
+		}
+	}
+
+	return out
+}
+
+func containsType(types []string, t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsPK(pks []cipher.PubKey, pk cipher.PubKey) bool {
+	for _, v := range pks {
+		if v == pk {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package hypervisortest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/hypervisor"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestServer(t *testing.T) {
+	remote, _ := cipher.GenerateKeyPair()
+
+	v1 := NewVisor(Config{
+		Apps:  []*visor.AppState{{Name: "skychat", Status: visor.AppStatusStopped}},
+		Rules: []routing.Rule{routing.IntermediaryForwardRule(time.Hour, 1, 2, uuid.New())},
+	})
+	v2 := NewVisor(Config{
+		Transports: []*visor.TransportSummary{{ID: uuid.New(), Remote: remote, Type: "stcp"}},
+		Health:     &visor.HealthInfo{TransportDiscovery: http.StatusOK, RouteFinder: http.StatusNotFound, SetupNode: http.StatusOK},
+	})
+
+	srv, err := NewServer(v1, v2)
+	require.NoError(t, err)
+
+	defer srv.Close()
+
+	defer func() {
+		assert.NoError(t, v1.Close())
+		assert.NoError(t, v2.Close())
+	}()
+
+	get := func(path string, v interface{}) int {
+		resp, err := srv.Client().Get(srv.URL + "/api/v1" + path)
+		require.NoError(t, err)
+
+		defer func() {
+			assert.NoError(t, resp.Body.Close())
+		}()
+
+		if v != nil && resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+
+		return resp.StatusCode
+	}
+
+	t.Run("summaries", func(t *testing.T) {
+		var visors []struct {
+			PK   cipher.PubKey     `json:"local_pk"`
+			Apps []*visor.AppState `json:"apps"`
+		}
+
+		require.Equal(t, http.StatusOK, get("/visors", &visors))
+		require.Len(t, visors, 2)
+
+		for _, s := range visors {
+			if s.PK == v1.PK() {
+				require.Len(t, s.Apps, 1)
+				assert.Equal(t, "skychat", s.Apps[0].Name)
+			}
+		}
+	})
+
+	t.Run("state", func(t *testing.T) {
+		var health hypervisor.VisorHealth
+		require.Equal(t, http.StatusOK, get(fmt.Sprintf("/visors/%s/health", v2.PK()), &health))
+		assert.Equal(t, http.StatusNotFound, health.RouteFinder)
+
+		var tps []*visor.TransportSummary
+		require.Equal(t, http.StatusOK, get(fmt.Sprintf("/visors/%s/transports?type=stcp", v2.PK()), &tps))
+		require.Len(t, tps, 1)
+		assert.Equal(t, remote, tps[0].Remote)
+
+		v2.Update(func(c *Config) { c.Transports = nil })
+		require.Equal(t, http.StatusOK, get(fmt.Sprintf("/visors/%s/transports", v2.PK()), &tps))
+		assert.Empty(t, tps)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		v1.Fail("Apps", errors.New("injected failure"))
+		assert.Equal(t, http.StatusInternalServerError, get(fmt.Sprintf("/visors/%s/apps", v1.PK()), nil))
+
+		v1.Fail("Apps", nil)
+		assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/visors/%s/apps", v1.PK()), nil))
+		assert.Equal(t, 2, v1.Calls("Apps"))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		v1.SetLatency(50 * time.Millisecond)
+		defer v1.SetLatency(0)
+
+		start := time.Now()
+		assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/visors/%s/routes", v1.PK()), nil))
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	})
+}
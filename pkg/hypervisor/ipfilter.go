@@ -0,0 +1,126 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// Errors related to IP filters.
+var (
+	ErrIPDenied    = errors.New("access from this IP address is denied")
+	ErrBadIPFilter = errors.New("invalid ip filter")
+)
+
+// IPFilterConfig configures which client IPs may access the API and ptys.
+// Filters apply to the address of the connecting peer, before authentication. The admin socket is not filtered.
+type IPFilterConfig struct {
+	API IPRules `json:"api"` // Rules of /api.
+	Pty IPRules `json:"pty"` // Rules of /pty.
+}
+
+// IPRules are CIDR ranges (or single IPs) of clients to allow and deny.
+// If 'allow' is set, only clients in its ranges are allowed. Ranges of 'deny' take precedence.
+type IPRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func newIPFilter(rules IPRules) (*ipFilter, error) {
+	allow, err := parseCIDRs(rules.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := parseCIDRs(rules.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ipFilter{allow: allow, deny: deny}, nil
+}
+
+// parseCIDRs parses CIDR ranges, where single IPs are ranges of their own.
+func parseCIDRs(ranges []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ranges))
+
+	for _, s := range ranges {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %q is neither an IP nor a CIDR range", ErrBadIPFilter, s)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadIPFilter, err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(f.allow) == 0 {
+		return true
+	}
+
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filter is an http middleware which refuses requests of clients which are not allowed.
+func (f *ipFilter) filter(next http.Handler) http.Handler {
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := net.ParseIP(remoteIP(r))
+
+		if !f.allowed(ip) {
+			log.WithField("ip", remoteIP(r)).WithField("path", r.URL.Path).Debug("Denied request by ip filter.")
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrIPDenied)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,69 @@
+package hypervisor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter_Allowed(t *testing.T) {
+	f, err := newIPFilter(IPRules{
+		Allow: []string{"10.0.0.0/8", "192.168.1.10", "fd00::/8"},
+		Deny:  []string{"10.0.0.13"},
+	})
+	require.NoError(t, err)
+
+	for ip, allowed := range map[string]bool{
+		"10.1.2.3":     true,
+		"10.0.0.13":    false,
+		"192.168.1.10": true,
+		"192.168.1.11": false,
+		"fd00::1":      true,
+		"2001:db8::1":  false,
+	} {
+		assert.Equal(t, allowed, f.allowed(net.ParseIP(ip)), ip)
+	}
+
+	f, err = newIPFilter(IPRules{Deny: []string{"203.0.113.0/24"}})
+	require.NoError(t, err)
+	assert.True(t, f.allowed(net.ParseIP("198.51.100.1")))
+	assert.False(t, f.allowed(net.ParseIP("203.0.113.7")))
+	assert.False(t, f.allowed(nil))
+
+	_, err = newIPFilter(IPRules{Allow: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+
+	_, err = newIPFilter(IPRules{Deny: []string{"localhost"}})
+	assert.Error(t, err)
+}
+
+func TestIPFilter_Routes(t *testing.T) {
+	_, _, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var err error
+
+	hv.apiFilter, err = newIPFilter(IPRules{Allow: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	hv.ptyFilter, err = newIPFilter(IPRules{Deny: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	serve := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		hv.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/api/v1/ping", "127.0.0.1:4000"))
+	assert.Equal(t, http.StatusForbidden, serve("/api/v1/ping", "198.51.100.1:4000"))
+	assert.Equal(t, http.StatusForbidden, serve("/pty/"+hv.c.PK.Hex(), "127.0.0.1:4000"))
+}
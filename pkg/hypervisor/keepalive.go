@@ -0,0 +1,64 @@
+package hypervisor
+
+import (
+	"io"
+	"net/rpc"
+	"time"
+)
+
+const (
+	defaultKeepAliveInterval = 15 * time.Second
+	defaultKeepAliveMisses   = 3
+)
+
+// keepAlive calls KeepAlive on the visor every interval, for as long as it is connected over conn.
+// The connection is closed after 'misses' consecutive failures, so that the visor shows offline
+// rather than hanging until an RPC times out, and the visor redials.
+func (hv *Hypervisor) keepAlive(c VisorConn, conn io.Closer, interval time.Duration, misses int) {
+	if interval <= 0 {
+		return
+	}
+
+	if misses <= 0 {
+		misses = defaultKeepAliveMisses
+	}
+
+	log := log.WithField("visor_pk", c.Addr.PK)
+	timeout := interval * time.Duration(misses)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+
+	for range ticker.C {
+		hv.mu.RLock()
+		current, ok := hv.visors[c.Addr.PK]
+		hv.mu.RUnlock()
+
+		if !ok || current.RPC != c.RPC {
+			return
+		}
+
+		switch err := c.RPC.KeepAlive(interval, timeout); err {
+		case nil:
+			failures = 0
+			continue
+		case rpc.ErrShutdown:
+			return
+		default:
+			failures++
+			log.WithError(err).WithField("failures", failures).Debug("Visor missed keepalive.")
+		}
+
+		if failures >= misses {
+			log.WithField("failures", failures).Warn("Visor missed keepalives, closing connection.")
+
+			if err := conn.Close(); err != nil {
+				log.WithError(err).Warn("Failed to close visor connection.")
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package hypervisor
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+type keepAliveRPCClient struct {
+	visor.RPCClient
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (c *keepAliveRPCClient) KeepAlive(_, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+
+	return c.err
+}
+
+type closeRecorder chan struct{}
+
+func (c closeRecorder) Close() error {
+	close(c)
+	return nil
+}
+
+func TestHypervisor_KeepAlive(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+
+	rpcC := &keepAliveRPCClient{err: errors.New("timeout")}
+	c := VisorConn{Addr: dmsg.Addr{PK: pk}, RPC: rpcC}
+	hv := &Hypervisor{mu: new(sync.RWMutex), visors: map[cipher.PubKey]VisorConn{pk: c}}
+
+	closed := make(closeRecorder)
+	done := make(chan struct{})
+
+	go func() {
+		hv.keepAlive(c, closed, 10*time.Millisecond, 3)
+		close(done)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection of visor which missed keepalives was not closed")
+	}
+
+	<-done
+
+	rpcC.mu.Lock()
+	assert.Equal(t, 3, rpcC.calls)
+	rpcC.mu.Unlock()
+
+	// Keepalives stop when the visor reconnects.
+	rpcC = &keepAliveRPCClient{}
+	c = VisorConn{Addr: dmsg.Addr{PK: pk}, RPC: rpcC}
+
+	done = make(chan struct{})
+
+	go func() {
+		hv.keepAlive(c, make(closeRecorder), 10*time.Millisecond, 3)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("keepalives were sent to a replaced connection")
+	}
+}
@@ -0,0 +1,285 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltLabelBucketName = "visor_labels"
+	maxVisorLabels      = 32
+)
+
+// Errors associated with visor labels.
+var (
+	ErrBadLabel         = errors.New("label keys should be 1 to 63 alphanumeric chars, '.', '_', '-' or '/', and values at most 63 of the same chars")
+	ErrTooManyLabels    = errors.New("visor should have at most 32 labels")
+	ErrBadLabelSelector = errors.New("label selector should be a comma-separated list of 'key=value' pairs")
+)
+
+// nolint: gochecknoglobals
+var (
+	labelKeyRegexp   = regexp.MustCompile(`^[a-zA-Z0-9._/-]{1,63}$`)
+	labelValueRegexp = regexp.MustCompile(`^[a-zA-Z0-9._/-]{0,63}$`)
+)
+
+func checkLabels(labels map[string]string) error {
+	if len(labels) > maxVisorLabels {
+		return ErrTooManyLabels
+	}
+
+	for k, v := range labels {
+		if !labelKeyRegexp.MatchString(k) || !labelValueRegexp.MatchString(v) {
+			return fmt.Errorf("%w: %q=%q", ErrBadLabel, k, v)
+		}
+	}
+
+	return nil
+}
+
+// parseLabelSelector parses a selector of form "key1=value1,key2=value2".
+func parseLabelSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: %q", ErrBadLabelSelector, s)
+		}
+
+		selector[kv[0]] = kv[1]
+	}
+
+	if err := checkLabels(selector); err != nil {
+		return nil, err
+	}
+
+	return selector, nil
+}
+
+// matchLabels returns whether labels have all the key-value pairs of the selector.
+func matchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LabelStore stores key-value labels of visors, used to select groups of visors.
+type LabelStore interface {
+	Labels(pk cipher.PubKey) (map[string]string, error)
+	AllLabels() (map[cipher.PubKey]map[string]string, error)
+	SetLabels(pk cipher.PubKey, labels map[string]string) error
+}
+
+// BoltLabelStore implements LabelStore, storing labels in a bbolt database.
+type BoltLabelStore struct {
+	*bbolt.DB
+}
+
+// NewBoltLabelStore creates a new BoltLabelStore on top of an opened bbolt database.
+func NewBoltLabelStore(db *bbolt.DB) (*BoltLabelStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltLabelBucketName))
+		return err
+	})
+
+	return &BoltLabelStore{DB: db}, err
+}
+
+// Labels returns the labels of visor of pk.
+func (s *BoltLabelStore) Labels(pk cipher.PubKey) (labels map[string]string, err error) {
+	labels = make(map[string]string)
+
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltLabelBucketName)).Get(pk[:])
+		if raw == nil {
+			return nil
+		}
+
+		return json.Unmarshal(raw, &labels)
+	})
+
+	return labels, err
+}
+
+// AllLabels returns the labels of all visors that have any.
+func (s *BoltLabelStore) AllLabels() (map[cipher.PubKey]map[string]string, error) {
+	all := make(map[cipher.PubKey]map[string]string)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltLabelBucketName)).ForEach(func(k, v []byte) error {
+			var (
+				pk     cipher.PubKey
+				labels map[string]string
+			)
+
+			if err := json.Unmarshal(v, &labels); err != nil {
+				return err
+			}
+
+			copy(pk[:], k)
+			all[pk] = labels
+
+			return nil
+		})
+	})
+
+	return all, err
+}
+
+// SetLabels replaces the labels of visor of pk. No labels remove them.
+func (s *BoltLabelStore) SetLabels(pk cipher.PubKey, labels map[string]string) error {
+	if err := checkLabels(labels); err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltLabelBucketName))
+
+		if len(labels) == 0 {
+			return b.Delete(pk[:])
+		}
+
+		raw, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(pk[:], raw)
+	})
+}
+
+// selectVisors returns the sorted public keys of visors given by identifiers (see resolveVisor),
+// together with those having all labels of the selector. Visors do not need to be connected.
+func (hv *Hypervisor) selectVisors(ids []string, selector map[string]string) ([]cipher.PubKey, int, error) {
+	selected := make(map[cipher.PubKey]struct{})
+
+	for _, id := range ids {
+		pk, status, err := hv.resolveVisor(id)
+		if err != nil {
+			return nil, status, err
+		}
+
+		selected[pk] = struct{}{}
+	}
+
+	if len(selector) > 0 {
+		all, err := hv.labels.AllLabels()
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		for pk, labels := range all {
+			if matchLabels(labels, selector) {
+				selected[pk] = struct{}{}
+			}
+		}
+	}
+
+	pks := make([]cipher.PubKey, 0, len(selected))
+	for pk := range selected {
+		pks = append(pks, pk)
+	}
+
+	sort.Slice(pks, func(i, j int) bool { return pks[i].Hex() < pks[j].Hex() })
+
+	return pks, http.StatusOK, nil
+}
+
+func (hv *Hypervisor) getVisorLabels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		labels, err := hv.labels.Labels(pk)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, labels)
+	}
+}
+
+// putVisorLabels replaces the labels of a visor. The visor does not need to be connected.
+func (hv *Hypervisor) putVisorLabels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		labels := make(map[string]string)
+
+		if err := httputil.ReadJSON(r, &labels); err != nil {
+			if err != io.EOF {
+				log.Warnf("putVisorLabels request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := hv.labels.SetLabels(pk, labels); err != nil {
+			if errors.Is(err, ErrBadLabel) || err == ErrTooManyLabels {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			} else {
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, labels)
+	}
+}
+
+// getLabels lists labels of visors, optionally only of those matching the 'selector' query.
+func (hv *Hypervisor) getLabels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var selector map[string]string
+
+		if s := r.URL.Query().Get("selector"); s != "" {
+			var err error
+			if selector, err = parseLabelSelector(s); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		all, err := hv.labels.AllLabels()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		out := make(map[string]map[string]string, len(all))
+
+		for pk, labels := range all {
+			if matchLabels(labels, selector) {
+				out[pk.Hex()] = labels
+			}
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, out)
+	}
+}
@@ -0,0 +1,89 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisorLabels(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	// Labels may be set on visors which are not connected.
+	offline, _ := cipher.GenerateKeyPair()
+	pks = append(pks, offline)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/labels", pks[0]),
+			ReqBody:    strings.NewReader(`{"ring":"canary","site":"berlin"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/labels", offline),
+			ReqBody:    strings.NewReader(`{"ring":"canary"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/labels", pks[1]),
+			ReqBody:    strings.NewReader(`{"bad key":"x"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/labels", pks[0]),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var labels map[string]string
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&labels))
+				assert.Equal(t, map[string]string{"ring": "canary", "site": "berlin"}, labels)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/labels?selector=ring=canary,site=berlin",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var all map[string]map[string]string
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&all))
+				assert.Len(t, all, 1)
+				assert.Contains(t, all, pks[0].Hex())
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/labels?selector=ring",
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+
+	selected, _, err := hv.selectVisors(nil, map[string]string{"ring": "canary"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []cipher.PubKey{pks[0], offline}, selected)
+
+	selected, _, err = hv.selectVisors([]string{pks[1].Hex(), pks[0].Hex()}, map[string]string{"ring": "canary"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []cipher.PubKey{pks[0], pks[1], offline}, selected)
+
+	// Removing all labels.
+	require.NoError(t, hv.labels.SetLabels(offline, nil))
+
+	all, err := hv.labels.AllLabels()
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
@@ -0,0 +1,267 @@
+//go:build loadtest
+// +build loadtest
+
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const loadTestPassword = "Secure1234!" // nolint:gosec
+
+// LoadTestConfig configures a load test.
+type LoadTestConfig struct {
+	Users    int           // Number of concurrent dashboard users.
+	Duration time.Duration // How long to generate load for.
+	Think    time.Duration // Pause between consecutive requests of a single user.
+}
+
+// EndpointStats is the latency distribution of a single endpoint.
+type EndpointStats struct {
+	Endpoint string
+	Requests int
+	Errors   int
+	Min      time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// LoadReport is the result of a load test.
+type LoadReport struct {
+	Users     int
+	Duration  time.Duration
+	Endpoints []EndpointStats
+}
+
+// WriteTo writes the report as a table.
+func (lr *LoadReport) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "users: %d, duration: %v\n\n", lr.Users, lr.Duration)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\terrors\tmin\tp50\tp90\tp99\tmax\t")
+
+	for _, s := range lr.Endpoints {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%v\t%v\t%v\t\n",
+			s.Endpoint, s.Requests, s.Errors, s.Min, s.P50, s.P90, s.P99, s.Max)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+type loadRecorder struct {
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	mu        sync.Mutex
+}
+
+func (lr *loadRecorder) record(endpoint string, d time.Duration, err error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	lr.latencies[endpoint] = append(lr.latencies[endpoint], d)
+
+	if err != nil {
+		lr.errors[endpoint]++
+	}
+}
+
+func (lr *loadRecorder) report(users int, d time.Duration) *LoadReport {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	report := &LoadReport{Users: users, Duration: d}
+
+	for endpoint, ls := range lr.latencies {
+		sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+
+		percentile := func(p int) time.Duration {
+			return ls[(len(ls)-1)*p/100]
+		}
+
+		report.Endpoints = append(report.Endpoints, EndpointStats{
+			Endpoint: endpoint,
+			Requests: len(ls),
+			Errors:   lr.errors[endpoint],
+			Min:      ls[0],
+			P50:      percentile(50),
+			P90:      percentile(90),
+			P99:      percentile(99),
+			Max:      ls[len(ls)-1],
+		})
+	}
+
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+	})
+
+	return report
+}
+
+// RunLoadTest simulates concurrent dashboard users against the hypervisor,
+// which is expected to be populated with mock data (see AddMockData).
+// If user management is enabled, every simulated user logs in with its own session.
+func RunLoadTest(hv *Hypervisor, c LoadTestConfig) (*LoadReport, error) {
+	if c.Users <= 0 {
+		return nil, fmt.Errorf("number of users should be positive")
+	}
+
+	srv := httptest.NewServer(hv)
+	defer srv.Close()
+
+	if hv.c.EnableAuth {
+		body := fmt.Sprintf(`{"username":"admin","password":%q}`, loadTestPassword)
+
+		resp, err := http.Post(srv.URL+"/api/v1/create-account", "application/json", strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := resp.Body.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	rec := &loadRecorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+
+	var (
+		wg       sync.WaitGroup
+		deadline = time.Now().Add(c.Duration)
+		errCh    = make(chan error, c.Users)
+	)
+
+	wg.Add(c.Users)
+
+	for i := 0; i < c.Users; i++ {
+		go func(seed int64) {
+			defer wg.Done()
+
+			u, err := newLoadUser(srv.URL, hv.c.EnableAuth, rec, seed)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			for time.Now().Before(deadline) {
+				u.browse(c.Think)
+			}
+		}(int64(i))
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	return rec.report(c.Users, c.Duration), nil
+}
+
+// loadUser simulates a single dashboard user.
+type loadUser struct {
+	url    string
+	client *http.Client
+	rec    *loadRecorder
+	r      *rand.Rand
+}
+
+func newLoadUser(url string, login bool, rec *loadRecorder, seed int64) (*loadUser, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &loadUser{
+		url:    url,
+		client: &http.Client{Jar: jar},
+		rec:    rec,
+		r:      rand.New(rand.NewSource(seed)), // nolint:gosec
+	}
+
+	if login {
+		body := fmt.Sprintf(`{"username":"admin","password":%q}`, loadTestPassword)
+		if err := u.do(http.MethodPost, "/api/v1/login", "/api/v1/login", body, nil); err != nil {
+			return nil, fmt.Errorf("login: %w", err)
+		}
+	}
+
+	return u, nil
+}
+
+// browse walks through the pages of the dashboard, as a user would.
+func (u *loadUser) browse(think time.Duration) {
+	var visors []struct {
+		PK string `json:"local_pk"`
+	}
+
+	if err := u.get("/api/v1/visors", "/api/v1/visors", &visors); err != nil || len(visors) == 0 {
+		time.Sleep(think)
+		return
+	}
+
+	pk := visors[u.r.Intn(len(visors))].PK
+
+	for _, page := range []string{"", "/health", "/uptime", "/apps", "/transports", "/routes"} {
+		time.Sleep(think)
+		u.get("/api/v1/visors/{pk}"+page, "/api/v1/visors/"+pk+page, nil) // nolint:errcheck
+	}
+}
+
+func (u *loadUser) get(endpoint, path string, v interface{}) error {
+	return u.do(http.MethodGet, endpoint, path, "", v)
+}
+
+func (u *loadUser) do(method, endpoint, path, body string, v interface{}) error {
+	req, err := http.NewRequest(method, u.url+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	resp, err := u.client.Do(req)
+	if err == nil {
+		if v != nil && resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(v)
+		} else {
+			_, err = io.Copy(ioutil.Discard, resp.Body)
+		}
+
+		if cErr := resp.Body.Close(); err == nil {
+			err = cErr
+		}
+
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+		}
+	}
+
+	u.rec.record(method+" "+endpoint, time.Since(start), err)
+
+	return err
+}
@@ -0,0 +1,44 @@
+//go:build loadtest
+// +build loadtest
+
+package hypervisor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLoadTest(t *testing.T) {
+	for _, auth := range []bool{false, true} {
+		dir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+		require.NoError(t, err)
+
+		config := makeConfig(false)
+		config.DBPath = filepath.Join(dir, "users.db")
+		config.RateLimits.PerIP = -1
+		config.RateLimits.PerUser = -1
+
+		hv, err := New(nil, config)
+		require.NoError(t, err)
+		require.NoError(t, hv.AddMockData(MockConfig{Visors: 5, MaxTpsPerVisor: 10, MaxRoutesPerVisor: 10, EnableAuth: auth}))
+
+		report, err := RunLoadTest(hv, LoadTestConfig{Users: 10, Duration: time.Second})
+		require.NoError(t, err)
+
+		_, err = report.WriteTo(os.Stdout)
+		require.NoError(t, err)
+
+		for _, s := range report.Endpoints {
+			assert.Zero(t, s.Errors, s.Endpoint)
+			assert.True(t, s.Min <= s.P50 && s.P50 <= s.P90 && s.P90 <= s.P99 && s.P99 <= s.Max)
+		}
+
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
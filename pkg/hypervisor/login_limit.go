@@ -0,0 +1,279 @@
+package hypervisor
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/httputil"
+)
+
+const (
+	defaultMaxLoginFailures = 5
+	defaultLoginBaseDelay   = time.Second
+	defaultLoginMaxDelay    = 30 * time.Second
+	defaultLoginLockout     = 15 * time.Minute
+)
+
+// Errors associated with login limits.
+var (
+	ErrLoginTooSoon  = errors.New("too many failed login attempts, try again later")
+	ErrLoginLockedIP = errors.New("too many failed login attempts from this address, temporarily locked")
+	ErrLoginLocked   = errors.New("too many failed login attempts for this account, temporarily locked")
+)
+
+// LoginLimitConfig configures brute-force protection of logins.
+// Failed attempts are counted both per client IP and per account.
+type LoginLimitConfig struct {
+	MaxFailures int           `json:"max_failures"` // Consecutive failures that trigger a lockout.
+	BaseDelay   time.Duration `json:"base_delay"`   // Required delay after the second failure, doubled with each further failure.
+	MaxDelay    time.Duration `json:"max_delay"`    // Upper bound of the delay between attempts.
+	Lockout     time.Duration `json:"lockout"`      // How long a lockout lasts, also how long failures are remembered.
+}
+
+// FillDefaults fills config with default values.
+func (c *LoginLimitConfig) FillDefaults() {
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = defaultMaxLoginFailures
+	}
+
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = defaultLoginBaseDelay
+	}
+
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultLoginMaxDelay
+	}
+
+	if c.Lockout <= 0 {
+		c.Lockout = defaultLoginLockout
+	}
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+type loginLimiter struct {
+	c         LoginLimitConfig
+	ips       map[string]*loginFailures
+	accounts  map[string]*loginFailures
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func newLoginLimiter(c LoginLimitConfig) *loginLimiter {
+	c.FillDefaults()
+
+	return &loginLimiter{
+		c:        c,
+		ips:      make(map[string]*loginFailures),
+		accounts: make(map[string]*loginFailures),
+	}
+}
+
+// delay returns the delay required after the given number of consecutive failures.
+// A single failure (such as a typo) does not cause a delay.
+func (l *loginLimiter) delay(failures int) time.Duration {
+	if failures <= 1 {
+		return 0
+	}
+
+	d := float64(l.c.BaseDelay) * math.Pow(2, float64(failures-2))
+	if d > float64(l.c.MaxDelay) {
+		return l.c.MaxDelay
+	}
+
+	return time.Duration(d)
+}
+
+// check returns an error and the time to wait if a login attempt is not allowed yet.
+func (l *loginLimiter) check(ip, account string, now time.Time) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		wait time.Duration
+		err  error
+	)
+
+	test := func(f *loginFailures, lockErr error) {
+		if f == nil {
+			return
+		}
+
+		if d := f.lockedUntil.Sub(now); d > 0 {
+			if err == nil || err == ErrLoginTooSoon || d > wait {
+				wait, err = d, lockErr
+			}
+
+			return
+		}
+
+		if d := f.last.Add(l.delay(f.count)).Sub(now); d > 0 && err == nil {
+			wait, err = d, ErrLoginTooSoon
+		}
+	}
+
+	test(l.entry(l.ips, ip, now), ErrLoginLockedIP)
+	test(l.entry(l.accounts, account, now), ErrLoginLocked)
+
+	return wait, err
+}
+
+// fail records a failed login attempt.
+func (l *loginLimiter) fail(ip, account string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	record := func(m map[string]*loginFailures, key string) {
+		f := l.entry(m, key, now)
+		if f == nil {
+			f = new(loginFailures)
+			m[key] = f
+		}
+
+		f.count++
+		f.last = now
+
+		if f.count >= l.c.MaxFailures {
+			f.count = 0
+			f.lockedUntil = now.Add(l.c.Lockout)
+		}
+	}
+
+	record(l.ips, ip)
+	record(l.accounts, account)
+}
+
+// succeed forgets failed login attempts of the client and account.
+func (l *loginLimiter) succeed(ip, account string) {
+	l.mu.Lock()
+	delete(l.ips, ip)
+	delete(l.accounts, account)
+	l.mu.Unlock()
+}
+
+// unlock forgets failed login attempts of the given IP and/or account. If both are empty, all are forgotten.
+func (l *loginLimiter) unlock(ip, account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ip == "" && account == "" {
+		l.ips = make(map[string]*loginFailures)
+		l.accounts = make(map[string]*loginFailures)
+
+		return
+	}
+
+	delete(l.ips, ip)
+	delete(l.accounts, account)
+}
+
+// state returns the number of failed attempts remaining before a lockout, and when that number is reset.
+// The stricter of the IP and account counters is reported.
+func (l *loginLimiter) state(ip, account string, now time.Time) (int, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	remaining, reset := l.c.MaxFailures, now
+
+	for _, f := range []*loginFailures{l.entry(l.ips, ip, now), l.entry(l.accounts, account, now)} {
+		if f == nil {
+			continue
+		}
+
+		r, t := l.c.MaxFailures-f.count, f.last.Add(l.c.Lockout)
+		if f.lockedUntil.After(now) {
+			r, t = 0, f.lockedUntil
+		}
+
+		if r < remaining {
+			remaining = r
+		}
+
+		if t.After(reset) {
+			reset = t
+		}
+	}
+
+	return remaining, reset
+}
+
+// entry returns the failures of key, removing it if it has expired. Must be called with mu held.
+func (l *loginLimiter) entry(m map[string]*loginFailures, key string, now time.Time) *loginFailures {
+	f, ok := m[key]
+	if !ok {
+		return nil
+	}
+
+	if now.After(f.lockedUntil) && now.Sub(f.last) > l.c.Lockout {
+		delete(m, key)
+		return nil
+	}
+
+	return f
+}
+
+// sweep forgets expired failures once a minute, so that failures of clients and accounts which are not tried again
+// do not accumulate. Must be called with mu held.
+func (l *loginLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+
+	for _, m := range []map[string]*loginFailures{l.ips, l.accounts} {
+		for key := range m {
+			l.entry(m, key, now)
+		}
+	}
+}
+
+// setRateLimitHeaders sets the X-RateLimit-* headers, allowing clients to throttle themselves.
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining int, reset time.Time) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+}
+
+// setLimitHeaders sets the rate limit headers of a login attempt.
+func (l *loginLimiter) setLimitHeaders(w http.ResponseWriter, ip, account string) {
+	remaining, reset := l.state(ip, account, time.Now())
+	setRateLimitHeaders(w, l.c.MaxFailures, remaining, reset)
+}
+
+func writeLoginLimited(w http.ResponseWriter, r *http.Request, wait time.Duration, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	httputil.WriteJSON(w, r, http.StatusTooManyRequests, err)
+}
+
+// Unlock returns a HandlerFunc that lifts login delays and lockouts of an IP address and/or account.
+// If neither is specified, all are lifted.
+func (s *UserManager) Unlock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rb struct {
+			Username string `json:"username,omitempty"`
+			IP       string `json:"ip,omitempty"`
+		}
+
+		if err := httputil.ReadJSON(r, &rb); err != nil && err != io.EOF {
+			log.Warnf("Unlock request: %v", err)
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		s.limiter.unlock(rb.IP, rb.Username)
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,131 @@
+package hypervisor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter(LoginLimitConfig{
+		MaxFailures: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    3 * time.Second,
+		Lockout:     time.Minute,
+	})
+
+	now := time.Now()
+
+	t.Run("delay", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), l.delay(1))
+		assert.Equal(t, time.Second, l.delay(2))
+		assert.Equal(t, 2*time.Second, l.delay(3))
+		assert.Equal(t, 3*time.Second, l.delay(10))
+	})
+
+	t.Run("exponential_delay", func(t *testing.T) {
+		l.fail("1.1.1.1", "admin", now)
+
+		_, err := l.check("1.1.1.1", "admin", now)
+		require.NoError(t, err)
+
+		l.fail("1.1.1.1", "admin", now)
+
+		wait, err := l.check("1.1.1.1", "admin", now)
+		assert.Equal(t, ErrLoginTooSoon, err)
+		assert.Equal(t, time.Second, wait)
+
+		// Account failures also apply to other addresses.
+		_, err = l.check("2.2.2.2", "admin", now)
+		assert.Equal(t, ErrLoginTooSoon, err)
+
+		_, err = l.check("1.1.1.1", "admin", now.Add(time.Second))
+		require.NoError(t, err)
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		l.fail("1.1.1.1", "admin", now.Add(time.Second))
+		l.fail("1.1.1.1", "admin", now.Add(3*time.Second))
+
+		wait, err := l.check("9.9.9.9", "admin", now.Add(4*time.Second))
+		assert.Equal(t, ErrLoginLocked, err)
+		assert.Equal(t, time.Minute-time.Second, wait)
+
+		_, err = l.check("1.1.1.1", "other", now.Add(4*time.Second))
+		assert.Equal(t, ErrLoginLockedIP, err)
+
+		_, err = l.check("1.1.1.1", "admin", now.Add(3*time.Second+time.Minute))
+		require.NoError(t, err)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			l.fail("3.3.3.3", "admin", now)
+		}
+
+		_, err := l.check("3.3.3.3", "admin", now)
+		require.Error(t, err)
+
+		l.unlock("", "admin")
+
+		_, err = l.check("3.3.3.3", "admin", now)
+		assert.Equal(t, ErrLoginLockedIP, err)
+
+		l.unlock("", "")
+
+		_, err = l.check("3.3.3.3", "admin", now)
+		require.NoError(t, err)
+	})
+
+	t.Run("success_resets", func(t *testing.T) {
+		l.fail("4.4.4.4", "user", now)
+		l.fail("4.4.4.4", "user", now)
+		l.succeed("4.4.4.4", "user")
+
+		_, err := l.check("4.4.4.4", "user", now)
+		require.NoError(t, err)
+	})
+}
+
+func TestLoginLimiter_State(t *testing.T) {
+	l := newLoginLimiter(LoginLimitConfig{MaxFailures: 3, Lockout: time.Minute})
+	now := time.Now()
+
+	remaining, reset := l.state("1.1.1.1", "admin", now)
+	assert.Equal(t, 3, remaining)
+	assert.Equal(t, now, reset)
+
+	l.fail("1.1.1.1", "admin", now)
+	l.fail("2.2.2.2", "admin", now.Add(time.Second))
+
+	remaining, reset = l.state("1.1.1.1", "admin", now.Add(time.Second))
+	assert.Equal(t, 1, remaining)
+	assert.Equal(t, now.Add(time.Second+time.Minute), reset)
+
+	l.fail("1.1.1.1", "admin", now.Add(2*time.Second))
+
+	remaining, reset = l.state("1.1.1.1", "admin", now.Add(2*time.Second))
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, now.Add(2*time.Second+time.Minute), reset)
+}
+
+func TestLoginLimiter_Sweep(t *testing.T) {
+	l := newLoginLimiter(LoginLimitConfig{MaxFailures: 3, Lockout: time.Minute})
+	now := time.Now()
+
+	for i := 0; i < 100; i++ {
+		l.fail(fmt.Sprintf("10.0.0.%d", i), fmt.Sprintf("user%d", i), now)
+	}
+
+	assert.Len(t, l.ips, 100)
+	assert.Len(t, l.accounts, 100)
+
+	// Expired failures are forgotten, even though those clients and accounts are not tried again.
+	l.fail("1.1.1.1", "admin", now.Add(2*time.Minute))
+
+	assert.Len(t, l.ips, 1)
+	assert.Len(t, l.accounts, 1)
+}
@@ -0,0 +1,269 @@
+package hypervisor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/app"
+)
+
+const logsArchiveTimeFormat = "20060102T150405Z"
+
+// LogsCollectRequest is the request body of collecting logs across visors.
+type LogsCollectRequest struct {
+	Visors []string  `json:"visors,omitempty"` // Visor identifiers (public key, prefix or name), defaults to all visors.
+	Apps   []string  `json:"apps,omitempty"`   // Apps to collect logs of, defaults to all apps.
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to,omitempty"` // Defaults to now.
+}
+
+// LogsManifest describes the contents of a logs archive. It is included in the archive as 'manifest.json'.
+type LogsManifest struct {
+	From   time.Time           `json:"from"`
+	To     time.Time           `json:"to"`
+	Visors []VisorLogsManifest `json:"visors"`
+}
+
+// VisorLogsManifest describes the collected logs of a single visor.
+type VisorLogsManifest struct {
+	PK    cipher.PubKey  `json:"pk"`
+	Name  string         `json:"name,omitempty"`
+	Apps  map[string]int `json:"apps"` // Number of collected log lines per app.
+	Error string         `json:"error,omitempty"`
+}
+
+type visorLogs struct {
+	manifest VisorLogsManifest
+	logs     map[string][]string
+}
+
+// collectLogs returns the logs of apps of the given visor logged within [from, to].
+func collectLogs(conn VisorConn, apps []string, from, to time.Time) (map[string][]string, error) {
+	if len(apps) == 0 {
+		states, err := conn.RPC.Apps()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range states {
+			if validLogsAppName(s.Name) {
+				apps = append(apps, s.Name)
+			}
+		}
+	}
+
+	logs := make(map[string][]string, len(apps))
+
+	for _, a := range apps {
+		lines, err := conn.RPC.LogsSince(from, a, app.LogFilter{})
+		if err != nil {
+			return logs, fmt.Errorf("app %s: %w", a, err)
+		}
+
+		logs[a] = filterLogsBefore(lines, to)
+	}
+
+	return logs, nil
+}
+
+// validLogsAppName returns true if the app name can be used as a file name in a logs archive.
+func validLogsAppName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// filterLogsBefore removes the log lines logged after t. Lines of unknown time are kept.
+func filterLogsBefore(lines []string, t time.Time) []string {
+	filtered := make([]string, 0, len(lines))
+
+	for _, l := range lines {
+		if lt, ok := logTimestamp(l); ok && lt.After(t) {
+			continue
+		}
+
+		filtered = append(filtered, l)
+	}
+
+	return filtered
+}
+
+// logTimestamp parses the timestamp at the start of an app log line, formatted as "[<RFC3339Nano>] ...".
+func logTimestamp(l string) (time.Time, bool) {
+	end := strings.IndexByte(l, ']')
+	if !strings.HasPrefix(l, "[") || end < 0 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, l[1:end])
+
+	return t, err == nil
+}
+
+// postLogsCollect pulls app logs of a time window from multiple visors in parallel,
+// and responds with a gzipped tar archive of them.
+func (hv *Hypervisor) postLogsCollect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody LogsCollectRequest
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postLogsCollect request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if reqBody.To.IsZero() {
+			reqBody.To = time.Now()
+		}
+
+		if reqBody.To.Before(reqBody.From) {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, fmt.Errorf("'to' is before 'from'"))
+			return
+		}
+
+		for _, a := range reqBody.Apps {
+			if !validLogsAppName(a) {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, fmt.Errorf("invalid app name %q", a))
+				return
+			}
+		}
+
+		pks := make([]cipher.PubKey, 0, len(reqBody.Visors))
+
+		for _, id := range reqBody.Visors {
+			pk, status, err := hv.resolveVisor(id)
+			if err != nil {
+				httputil.WriteJSON(w, r, status, err)
+				return
+			}
+
+			pks = append(pks, pk)
+		}
+
+		if len(pks) == 0 {
+			hv.mu.RLock()
+			for pk := range hv.visors {
+				pks = append(pks, pk)
+			}
+			hv.mu.RUnlock()
+		}
+
+		names, err := hv.names.Names()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		results := make([]visorLogs, len(pks))
+
+		var wg sync.WaitGroup
+
+		wg.Add(len(pks))
+
+		for i, pk := range pks {
+			go func(i int, pk cipher.PubKey) {
+				defer wg.Done()
+
+				res := visorLogs{
+					manifest: VisorLogsManifest{PK: pk, Name: names[pk], Apps: make(map[string]int)},
+				}
+
+				conn, ok := hv.visorConn(pk)
+				if !ok {
+					res.manifest.Error = fmt.Sprintf("visor of pk '%s' not found", pk)
+					results[i] = res
+
+					return
+				}
+
+				logs, err := collectLogs(conn, reqBody.Apps, reqBody.From, reqBody.To)
+				if err != nil {
+					res.manifest.Error = err.Error()
+				}
+
+				for a, lines := range logs {
+					res.manifest.Apps[a] = len(lines)
+				}
+
+				res.logs = logs
+				results[i] = res
+			}(i, pk)
+		}
+
+		wg.Wait()
+
+		filename := fmt.Sprintf("logs-%s-%s.tar.gz",
+			reqBody.From.UTC().Format(logsArchiveTimeFormat), reqBody.To.UTC().Format(logsArchiveTimeFormat))
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		if err := writeLogsArchive(w, reqBody.From, reqBody.To, results); err != nil {
+			log.WithError(err).Warn("Failed to write logs archive.")
+		}
+	}
+}
+
+// writeLogsArchive writes a gzipped tar archive containing 'manifest.json' and a '<pk>/<app>.log' file per app.
+func writeLogsArchive(w io.Writer, from, to time.Time, results []visorLogs) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	writeFile := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		_, err := tw.Write(data)
+
+		return err
+	}
+
+	manifest := LogsManifest{From: from, To: to, Visors: make([]VisorLogsManifest, 0, len(results))}
+
+	for _, res := range results {
+		manifest.Visors = append(manifest.Visors, res.manifest)
+
+		for a, lines := range res.logs {
+			data := strings.Join(lines, "")
+			if err := writeFile(fmt.Sprintf("%s/%s.log", res.manifest.PK, a), []byte(data)); err != nil {
+				return err
+			}
+		}
+	}
+
+	rawManifest, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile("manifest.json", rawManifest); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
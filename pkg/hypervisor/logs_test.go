@@ -0,0 +1,125 @@
+package hypervisor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// logsRPCClient serves fixed app logs on top of a mock RPC client.
+type logsRPCClient struct {
+	visor.RPCClient
+	logs []string
+}
+
+func (c logsRPCClient) LogsSince(t time.Time, _ string, _ app.LogFilter) ([]string, error) {
+	var logs []string
+
+	for _, l := range c.logs {
+		if lt, ok := logTimestamp(l); ok && !lt.Before(t) {
+			logs = append(logs, l)
+		}
+	}
+
+	return logs, nil
+}
+
+func TestFilterLogsBefore(t *testing.T) {
+	lines := []string{
+		"[2020-05-01T10:00:00.000000000+02:00] INFO: a\n",
+		"[2020-05-01T08:30:00Z] INFO: b\n",
+		"no timestamp\n",
+		"[2020-05-01T12:00:00.5+02:00] INFO: c\n",
+	}
+
+	to := time.Date(2020, 5, 1, 8, 45, 0, 0, time.UTC)
+	assert.Equal(t, lines[:3], filterLogsBefore(lines, to))
+}
+
+func TestLogsCollect(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	start := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	logs := make([]string, 0, 10)
+
+	for i := 0; i < 10; i++ {
+		logs = append(logs, fmt.Sprintf("[%s] INFO: line %d\n", start.Add(time.Duration(i)*time.Hour).Format(time.RFC3339Nano), i))
+	}
+
+	var pks []cipher.PubKey
+
+	hv.mu.Lock()
+	for pk, conn := range hv.visors {
+		conn.RPC = logsRPCClient{RPCClient: conn.RPC, logs: logs}
+		hv.visors[pk] = conn
+		pks = append(pks, pk)
+	}
+	hv.mu.Unlock()
+
+	body := fmt.Sprintf(`{"visors":[%q],"apps":["skychat"],"from":%q,"to":%q}`,
+		pks[0].Hex()[:10], start.Add(2*time.Hour).Format(time.RFC3339), start.Add(5*time.Hour).Format(time.RFC3339))
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/logs/collect",
+			ReqBody:    strings.NewReader(`{"from":"2020-05-02T00:00:00Z","to":"2020-05-01T00:00:00Z"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/logs/collect",
+			ReqBody:    strings.NewReader(`{"apps":["../../etc/cron.d/x"],"from":"2020-05-01T00:00:00Z"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/logs/collect",
+			ReqBody:    strings.NewReader(body),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				assert.Equal(t, "application/gzip", r.Header.Get("Content-Type"))
+
+				gr, err := gzip.NewReader(r.Body)
+				require.NoError(t, err)
+
+				files := make(map[string][]byte)
+				tr := tar.NewReader(gr)
+
+				for {
+					hdr, err := tr.Next()
+					if err != nil {
+						break
+					}
+
+					files[hdr.Name], err = ioutil.ReadAll(tr)
+					require.NoError(t, err)
+				}
+
+				require.Len(t, files, 2)
+				assert.Equal(t, strings.Join(logs[2:6], ""), string(files[pks[0].Hex()+"/skychat.log"]))
+
+				var manifest LogsManifest
+				require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+				require.Len(t, manifest.Visors, 1)
+				assert.Equal(t, pks[0], manifest.Visors[0].PK)
+				assert.Equal(t, map[string]int{"skychat": 4}, manifest.Visors[0].Apps)
+				assert.Empty(t, manifest.Visors[0].Error)
+			},
+		},
+	})
+}
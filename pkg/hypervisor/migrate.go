@@ -0,0 +1,132 @@
+package hypervisor
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+)
+
+// ConfigVersion is the version of the hypervisor config schema.
+// Configs of older versions are migrated to it by ParseAndMigrate.
+const ConfigVersion = "1.0"
+
+// configMigrations migrate hypervisor configs from older versions, in order.
+var configMigrations = []cfgutil.Migration{ // nolint: gochecknoglobals
+	// Unversioned configs have the same fields as version 1.0.
+	{From: "", To: "1.0", Migrate: func(map[string]interface{}) error { return nil }},
+}
+
+// ParseAndMigrate decodes a JSON encoded hypervisor config over the default config, migrating it from older versions
+// and validating it. Unknown fields are refused. It also returns the version the config was at.
+// Errors about the config are returned as *cfgutil.FieldError, which wrap cfgutil.ErrInvalidConfig.
+func ParseAndMigrate(raw []byte, testEnv bool) (Config, string, error) {
+	raw, from, err := cfgutil.Migrate(raw, "version", ConfigVersion, configMigrations)
+	if err != nil {
+		return Config{}, from, err
+	}
+
+	conf := makeConfig(testEnv)
+	if err := cfgutil.Decode(raw, &conf); err != nil {
+		return Config{}, from, err
+	}
+
+	if err := conf.Validate(); err != nil {
+		return Config{}, from, err
+	}
+
+	return conf, from, nil
+}
+
+// Validate checks the config for errors that would prevent the hypervisor from starting with it.
+// Errors are returned as *cfgutil.FieldError.
+func (c *Config) Validate() error {
+	invalid := cfgutil.Invalid
+
+	if c.PK.Null() || c.SK.Null() {
+		return invalid("secret_key", "is not set")
+	}
+
+	if pk, err := c.SK.PubKey(); err != nil || pk != c.PK {
+		return invalid("public_key", "does not match the secret key")
+	}
+
+	if c.DmsgDiscovery == "" {
+		return invalid("dmsg_discovery", "is not set")
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+		return invalid("http_addr", "%v", err)
+	}
+
+	if c.BasePath != "" && !strings.HasPrefix(c.BasePath, "/") {
+		return invalid("base_path", "should start with '/'")
+	}
+
+	switch c.Store.Type {
+	case StoreBolt:
+		if c.DBPath == "" {
+			return invalid("db_path", "is not set")
+		}
+	case StoreSQL:
+		if c.Store.Driver == "" || c.Store.DSN == "" {
+			return invalid("store", "driver and dsn should be set for the %q store", StoreSQL)
+		}
+
+		if !sqlDriverRegistered(c.Store.Driver) {
+			return invalid("store.driver", "driver %q is not linked in, available drivers: %v", c.Store.Driver, sql.Drivers())
+		}
+	default:
+		return invalid("store.type", "unknown type %q", c.Store.Type)
+	}
+
+	switch c.UserStore.Type {
+	case "", StoreBolt, StoreSQL, UserStoreFile:
+	default:
+		return invalid("user_store.type", "unknown type %q", c.UserStore.Type)
+	}
+
+	if err := rpcutil.CheckCompression(c.RPCCompression); err != nil {
+		return invalid("rpc_compression", "unknown compression %q", c.RPCCompression)
+	}
+
+	if c.EnableTLS && !c.ACME.Enable && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		return invalid("tls_cert_file", "tls cert and key files should be set, unless acme is enabled")
+	}
+
+	if c.ACME.Enable {
+		if len(c.ACME.Domains) == 0 {
+			return invalid("acme.domains", "is empty")
+		}
+
+		if c.ACME.Challenge != ACMEChallengeHTTP01 && c.ACME.Challenge != ACMEChallengeTLSALPN01 {
+			return invalid("acme.challenge", "unknown challenge type %q", c.ACME.Challenge)
+		}
+	}
+
+	for name, ranges := range map[string][]string{
+		"ip_filter.api.allow": c.IPFilter.API.Allow,
+		"ip_filter.api.deny":  c.IPFilter.API.Deny,
+		"ip_filter.pty.allow": c.IPFilter.Pty.Allow,
+		"ip_filter.pty.deny":  c.IPFilter.Pty.Deny,
+	} {
+		if _, err := parseCIDRs(ranges); err != nil {
+			return invalid(name, "%v", err)
+		}
+	}
+
+	if c.KeepAliveMisses < 0 {
+		return invalid("keepalive_misses", "is negative")
+	}
+
+	for i, origin := range c.CORSOrigins {
+		if origin == "" {
+			return invalid(fmt.Sprintf("cors_origins[%d]", i), "is empty")
+		}
+	}
+
+	return nil
+}
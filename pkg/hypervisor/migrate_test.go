@@ -0,0 +1,62 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+)
+
+func TestParseAndMigrate(t *testing.T) {
+	conf := makeConfig(true)
+	conf.DBPath = "users.db"
+	conf.Version = ""
+
+	raw, err := json.Marshal(conf)
+	require.NoError(t, err)
+
+	parsed, version, err := ParseAndMigrate(raw, true)
+	require.NoError(t, err)
+	assert.Equal(t, "", version)
+	assert.Equal(t, ConfigVersion, parsed.Version)
+	assert.Equal(t, conf.PK, parsed.PK)
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		path   string
+	}{
+		{"store type", func(c *Config) { c.Store.Type = "mongo" }, "store.type"},
+		{"store driver", func(c *Config) { c.Store = StoreConfig{Type: StoreSQL, Driver: "mysql", DSN: "hv"} }, "store.driver"},
+		{"tls files", func(c *Config) { c.EnableTLS = true }, "tls_cert_file"},
+		{"ip filter", func(c *Config) { c.IPFilter.API.Allow = []string{"10.0.0.0/33"} }, "ip_filter.api.allow"},
+		{"base path", func(c *Config) { c.BasePath = "skywire" }, "base_path"},
+		{"rpc compression", func(c *Config) { c.RPCCompression = "zstd" }, "rpc_compression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := conf
+			tt.modify(&c)
+
+			raw, err := json.Marshal(c)
+			require.NoError(t, err)
+
+			_, _, err = ParseAndMigrate(raw, true)
+
+			var fErr *cfgutil.FieldError
+			require.True(t, errors.As(err, &fErr), err)
+			assert.Equal(t, tt.path, fErr.Path)
+		})
+	}
+
+	_, _, err = ParseAndMigrate([]byte(`{"http_adr": ":8000"}`), true)
+
+	var fErr *cfgutil.FieldError
+	require.True(t, errors.As(err, &fErr))
+	assert.Equal(t, "http_adr", fErr.Path)
+}
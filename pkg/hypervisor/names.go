@@ -0,0 +1,199 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltNameBucketName = "visor_names"
+)
+
+// Errors associated with visor names.
+var (
+	ErrBadVisorName    = errors.New("visor name should be 1 to 32 chars of letters, digits, '-' or '_'")
+	ErrVisorNameTaken  = errors.New("visor name is already taken")
+	ErrVisorNameNotSet = errors.New("no visor has the given name")
+)
+
+// NameStore stores hypervisor-assigned short names of visors.
+type NameStore interface {
+	Name(pk cipher.PubKey) (string, error)
+	PubKey(name string) (cipher.PubKey, error)
+	Names() (map[cipher.PubKey]string, error)
+	SetName(pk cipher.PubKey, name string) error
+}
+
+// BoltNameStore implements NameStore, storing names in a bbolt database.
+type BoltNameStore struct {
+	*bbolt.DB
+}
+
+// NewBoltNameStore creates a new BoltNameStore on top of an opened bbolt database.
+func NewBoltNameStore(db *bbolt.DB) (*BoltNameStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltNameBucketName))
+		return err
+	})
+
+	return &BoltNameStore{DB: db}, err
+}
+
+// Name returns the name of visor of pk. Returns an empty string if no name is set.
+func (s *BoltNameStore) Name(pk cipher.PubKey) (name string, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		name = string(tx.Bucket([]byte(boltNameBucketName)).Get(pk[:]))
+		return nil
+	})
+
+	return name, err
+}
+
+// PubKey returns the public key of the visor of given name.
+func (s *BoltNameStore) PubKey(name string) (pk cipher.PubKey, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(boltNameBucketName)).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if string(v) == name {
+				copy(pk[:], k)
+				return nil
+			}
+		}
+
+		return ErrVisorNameNotSet
+	})
+
+	return pk, err
+}
+
+// Names returns names of all named visors.
+func (s *BoltNameStore) Names() (map[cipher.PubKey]string, error) {
+	names := make(map[cipher.PubKey]string)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltNameBucketName)).ForEach(func(k, v []byte) error {
+			var pk cipher.PubKey
+			copy(pk[:], k)
+			names[pk] = string(v)
+
+			return nil
+		})
+	})
+
+	return names, err
+}
+
+// SetName sets the name of visor of pk. An empty name removes the name.
+func (s *BoltNameStore) SetName(pk cipher.PubKey, name string) error {
+	if name != "" && !checkVisorNameFormat(name) {
+		return ErrBadVisorName
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltNameBucketName))
+
+		if name == "" {
+			return b.Delete(pk[:])
+		}
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if string(v) == name && string(k) != string(pk[:]) {
+				return ErrVisorNameTaken
+			}
+		}
+
+		return b.Put(pk[:], []byte(name))
+	})
+}
+
+func checkVisorNameFormat(name string) bool {
+	return regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`).MatchString(name)
+}
+
+func (hv *Hypervisor) putVisorName() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var reqBody struct {
+			Name string `json:"name"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("putVisorName request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := hv.names.SetName(ctx.Addr.PK, reqBody.Name); err != nil {
+			switch err {
+			case ErrBadVisorName:
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			case ErrVisorNameTaken:
+				httputil.WriteJSON(w, r, http.StatusConflict, err)
+			default:
+				httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	})
+}
+
+// visorPK obtains the visor public key from either the 'pk', 'name' or 'alias' URL parameter.
+// The 'pk' parameter may also hold a visor name or a unique public key prefix (see resolveVisor).
+func (hv *Hypervisor) visorPK(r *http.Request) (cipher.PubKey, int, error) {
+	if name := chi.URLParam(r, "name"); name != "" {
+		pk, err := hv.names.PubKey(name)
+		if err == ErrVisorNameNotSet {
+			return pk, http.StatusNotFound, fmt.Errorf("visor of name '%s' not found", name)
+		}
+
+		if err != nil {
+			return pk, http.StatusInternalServerError, err
+		}
+
+		return pk, http.StatusOK, nil
+	}
+
+	if alias := unescapedURLParam(r, "alias"); alias != "" {
+		pk, err := hv.meta.PubKeyByAlias(alias)
+		if err == ErrVisorAliasNotSet {
+			return pk, http.StatusNotFound, fmt.Errorf("visor of alias '%s' not found", alias)
+		}
+
+		if err != nil {
+			return pk, http.StatusInternalServerError, err
+		}
+
+		return pk, http.StatusOK, nil
+	}
+
+	return hv.resolveVisor(unescapedURLParam(r, "pk"))
+}
+
+// unescapedURLParam returns the URL parameter of key. Parameters are taken from the raw path when the path
+// contains escaped characters (such as "%2F" in aliases), in which case they need unescaping.
+func unescapedURLParam(r *http.Request, key string) string {
+	v := chi.URLParam(r, key)
+
+	if unescaped, err := url.PathUnescape(v); err == nil {
+		return unescaped
+	}
+
+	return v
+}
@@ -0,0 +1,120 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisorNames(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.True(t, len(pks) >= 2)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/name", pks[0]),
+			ReqBody:    strings.NewReader(`{"name":"bad name!"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/name", pks[0]),
+			ReqBody:    strings.NewReader(`{"name":"rpi-1"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/name", pks[1]),
+			ReqBody:    strings.NewReader(`{"name":"rpi-1"}`),
+			RespStatus: http.StatusConflict,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors/by-name/rpi-1",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp summaryResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Equal(t, "rpi-1", resp.Name)
+				assert.Equal(t, pks[0], resp.PubKey)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors/by-name/rpi-1/apps",
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors/by-name/rpi-2/apps",
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     fmt.Sprintf("/api/visors/%s/name", pks[0]),
+			ReqBody:    strings.NewReader(`{"name":""}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors/by-name/rpi-1",
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
+
+func TestHypervisor_ResolveVisor(t *testing.T) {
+	_, _, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	pk1, pk2 := cipher.PubKey{0x02, 0xab, 0xcd, 0x01}, cipher.PubKey{0x02, 0xab, 0xcd, 0x02}
+	require.NoError(t, hv.names.SetName(pk1, "rpi-1"))
+	require.NoError(t, hv.names.SetName(pk2, "rpi-2"))
+
+	tests := []struct {
+		id     string
+		pk     cipher.PubKey
+		status int
+		err    error
+	}{
+		{id: pk1.Hex(), pk: pk1, status: http.StatusOK},
+		{id: "rpi-2", pk: pk2, status: http.StatusOK},
+		{id: "02abcd01", pk: pk1, status: http.StatusOK},
+		{id: "02ABCD02", pk: pk2, status: http.StatusOK},
+		{id: "02abcd", status: http.StatusConflict, err: ErrAmbiguousVisorID},
+		{id: "02ab", status: http.StatusConflict, err: ErrAmbiguousVisorID},
+		{id: "0abcdef0", status: http.StatusNotFound, err: ErrVisorIDNotFound},
+		{id: "02a", status: http.StatusBadRequest, err: ErrBadVisorID},
+		{id: "not-a-visor", status: http.StatusBadRequest, err: ErrBadVisorID},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.id, func(t *testing.T) {
+			pk, status, err := hv.resolveVisor(tc.id)
+			assert.Equal(t, tc.status, status)
+
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.pk, pk)
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/internal/netutil"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	defaultNotifyInterval = 30 * time.Second
+	defaultWebhookRetries = 5
+	webhookBackoff        = time.Second
+	webhookBackoffFactor  = 2
+	webhookTimeout        = 10 * time.Second
+	webhookQueueSize      = 64 // Notifications waiting for delivery per webhook, above which they are dropped.
+)
+
+// Events that trigger notifications.
+const (
+	EventVisorOnline    = "visor_online"
+	EventVisorOffline   = "visor_offline"
+	EventAppCrashed     = "app_crashed"
+	EventUpdateFailed   = "update_failed"
+	EventHealthDegraded = "health_degraded"
+)
+
+// NotificationsConfig configures webhook notifications.
+type NotificationsConfig struct {
+	Webhooks      []WebhookConfig `json:"webhooks,omitempty"`
+	CheckInterval time.Duration   `json:"check_interval,omitempty"` // How often visors are checked for changes.
+	Retries       uint32          `json:"retries,omitempty"`        // How many times a failed webhook call is retried.
+}
+
+// WebhookConfig configures a single webhook.
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // Events to POST to the URL, defaults to all events.
+}
+
+func (wh WebhookConfig) wants(event string) bool {
+	if len(wh.Events) == 0 {
+		return true
+	}
+
+	for _, e := range wh.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Notification is the JSON payload POSTed to webhooks.
+type Notification struct {
+	Event   string        `json:"event"`
+	VisorPK cipher.PubKey `json:"visor_pk"`
+	Time    time.Time     `json:"time"`
+	Message string        `json:"message,omitempty"`
+}
+
+type visorState struct {
+	online bool
+	health visor.HealthInfo
+	apps   map[string]visor.AppStatus
+}
+
+type notifier struct {
+	c        NotificationsConfig
+	client   *http.Client
+	queues   []chan Notification // one per webhook, delivered in order by serve
+	states   map[cipher.PubKey]visorState
+	stopping map[cipher.PubKey]map[string]struct{} // apps stopped through the hypervisor
+	mu       sync.Mutex
+}
+
+func newNotifier(c NotificationsConfig) *notifier {
+	if c.CheckInterval <= 0 {
+		c.CheckInterval = defaultNotifyInterval
+	}
+
+	if c.Retries == 0 {
+		c.Retries = defaultWebhookRetries
+	}
+
+	queues := make([]chan Notification, len(c.Webhooks))
+	for i := range queues {
+		queues[i] = make(chan Notification, webhookQueueSize)
+	}
+
+	return &notifier{
+		c:        c,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queues:   queues,
+		states:   make(map[cipher.PubKey]visorState),
+		stopping: make(map[cipher.PubKey]map[string]struct{}),
+	}
+}
+
+// Notify queues the notification for all webhooks interested in it, which serve POSTs.
+func (n *notifier) Notify(event string, pk cipher.PubKey, msg string) {
+	notification := Notification{
+		Event:   event,
+		VisorPK: pk,
+		Time:    time.Now().UTC(),
+		Message: msg,
+	}
+
+	for i, wh := range n.c.Webhooks {
+		if !wh.wants(event) {
+			continue
+		}
+
+		select {
+		case n.queues[i] <- notification:
+		default:
+			log.WithField("url", wh.URL).WithField("event", event).Warn("Webhook queue is full, dropping notification.")
+		}
+	}
+}
+
+// serve delivers the queued notifications with one worker per webhook, until done is closed.
+func (n *notifier) serve(done <-chan struct{}) {
+	var wg sync.WaitGroup
+
+	for i, wh := range n.c.Webhooks {
+		wg.Add(1)
+
+		go func(url string, queue <-chan Notification) {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				case notification := <-queue:
+					n.post(url, notification)
+				}
+			}
+		}(wh.URL, n.queues[i])
+	}
+
+	wg.Wait()
+}
+
+func (n *notifier) post(url string, notification Notification) {
+	log := log.WithField("url", url).WithField("event", notification.Event)
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode notification.")
+		return
+	}
+
+	err = netutil.NewRetrier(webhookBackoff, n.c.Retries, webhookBackoffFactor).Do(func() error {
+		resp, err := n.client.Post(url, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close webhook response body.")
+		}
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("webhook %s responded with status %d", url, resp.StatusCode)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.WithError(err).Warn("Failed to deliver notification.")
+	}
+}
+
+// expectStop marks the app as intentionally stopped, so stopping it is not reported as a crash.
+func (n *notifier) expectStop(pk cipher.PubKey, app string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopping[pk] == nil {
+		n.stopping[pk] = make(map[string]struct{})
+	}
+
+	n.stopping[pk][app] = struct{}{}
+}
+
+// update compares the new visor state against the previous one and sends notifications of changes.
+func (n *notifier) update(pk cipher.PubKey, state visorState) {
+	n.mu.Lock()
+	prev, seen := n.states[pk]
+	n.states[pk] = state
+	stopping := n.stopping[pk]
+	delete(n.stopping, pk)
+	n.mu.Unlock()
+
+	if !seen {
+		return
+	}
+
+	switch {
+	case prev.online && !state.online:
+		n.Notify(EventVisorOffline, pk, "")
+		return
+	case !prev.online && state.online:
+		n.Notify(EventVisorOnline, pk, "")
+		return
+	case !state.online:
+		return
+	}
+
+	if healthDegraded(prev.health, state.health) {
+		n.Notify(EventHealthDegraded, pk, fmt.Sprintf("%+v", state.health))
+	}
+
+	if state.apps == nil {
+		return
+	}
+
+	for app, status := range prev.apps {
+		if status != visor.AppStatusRunning || state.apps[app] == visor.AppStatusRunning {
+			continue
+		}
+
+		if _, ok := stopping[app]; ok {
+			continue
+		}
+
+		n.Notify(EventAppCrashed, pk, app)
+	}
+}
+
+func healthDegraded(prev, cur visor.HealthInfo) bool {
+	degraded := func(prev, cur int) bool {
+		return prev == http.StatusOK && cur != http.StatusOK
+	}
+
+	return degraded(prev.TransportDiscovery, cur.TransportDiscovery) ||
+		degraded(prev.RouteFinder, cur.RouteFinder) ||
+		degraded(prev.SetupNode, cur.SetupNode)
+}
+
+// watchVisors periodically polls visors and notifies of state changes.
+func (hv *Hypervisor) watchVisors() {
+	hv.every(hv.notifier.c.CheckInterval, func(time.Time) {
+		for pk, online := range hv.onlineVisors() {
+			state := visorState{online: online}
+
+			if online {
+				conn, ok := hv.visorConn(pk)
+				if !ok {
+					continue
+				}
+
+				if h, err := conn.RPC.Health(); err == nil {
+					state.health = *h
+				}
+
+				if apps, err := conn.RPC.Apps(); err == nil {
+					state.apps = make(map[string]visor.AppStatus, len(apps))
+					for _, a := range apps {
+						state.apps[a.Name] = a.Status
+					}
+				}
+			}
+
+			if !online {
+				hv.routes.invalidate(pk)
+			}
+
+			hv.notifier.update(pk, state)
+		}
+	})
+}
+
+func (hv *Hypervisor) getNotificationsConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httputil.WriteJSON(w, r, http.StatusOK, hv.notifier.c)
+	}
+}
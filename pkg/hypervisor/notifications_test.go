@@ -0,0 +1,77 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestNotifier(t *testing.T) {
+	notifications := make(chan Notification, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		notifications <- n
+	}))
+	defer srv.Close()
+
+	n := newNotifier(NotificationsConfig{
+		Webhooks: []WebhookConfig{{URL: srv.URL, Events: []string{EventVisorOffline, EventAppCrashed}}},
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go n.serve(done)
+
+	pk, _ := cipher.GenerateKeyPair()
+	healthy := visor.HealthInfo{TransportDiscovery: http.StatusOK, RouteFinder: http.StatusOK, SetupNode: http.StatusOK}
+
+	expect := func(event, msg string) {
+		select {
+		case got := <-notifications:
+			assert.Equal(t, event, got.Event)
+			assert.Equal(t, pk, got.VisorPK)
+			assert.Equal(t, msg, got.Message)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s notification", event)
+		}
+	}
+
+	running := map[string]visor.AppStatus{"skychat": visor.AppStatusRunning, "skysocks": visor.AppStatusRunning}
+
+	n.update(pk, visorState{online: true, health: healthy, apps: running})
+
+	// Stopping an app through the hypervisor is not a crash.
+	n.expectStop(pk, "skysocks")
+	n.update(pk, visorState{online: true, health: healthy, apps: map[string]visor.AppStatus{
+		"skychat":  visor.AppStatusRunning,
+		"skysocks": visor.AppStatusStopped,
+	}})
+
+	n.update(pk, visorState{online: true, health: healthy, apps: map[string]visor.AppStatus{
+		"skychat":  visor.AppStatusStopped,
+		"skysocks": visor.AppStatusStopped,
+	}})
+	expect(EventAppCrashed, "skychat")
+
+	// Not subscribed to visor_online events.
+	n.update(pk, visorState{online: false})
+	expect(EventVisorOffline, "")
+	n.update(pk, visorState{online: true, health: healthy, apps: running})
+
+	select {
+	case got := <-notifications:
+		t.Fatalf("unexpected notification: %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
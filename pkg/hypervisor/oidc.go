@@ -0,0 +1,760 @@
+package hypervisor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// SessionProviderOIDC is the provider of sessions of users logged in through OpenID Connect.
+const SessionProviderOIDC = "oidc"
+
+const (
+	oidcStateCookieName     = "swm-oidc"
+	oidcLoginTimeout        = 10 * time.Minute // to complete the login at the identity provider
+	oidcHTTPTimeout         = 10 * time.Second
+	oidcMetadataTTL         = time.Hour
+	oidcKeysRefreshInterval = time.Minute // at least between fetches of keys, when tokens are signed by unknown keys
+	oidcClockSkew           = time.Minute
+	oidcMaxResponseSize     = 1 << 20
+)
+
+// Errors related to OpenID Connect logins.
+var (
+	ErrBadOIDCConfig       = errors.New("invalid oidc config")
+	ErrOIDCState           = errors.New("oidc login state is either missing, expired or does not match")
+	ErrOIDCProvider        = errors.New("oidc identity provider failed")
+	ErrBadIDToken          = errors.New("invalid oidc id token")
+	ErrOIDCGroupNotAllowed = errors.New("user is not in any of the allowed groups")
+)
+
+// OIDCConfig configures logins through an OpenID Connect identity provider, with the authorization code flow.
+// Users of the provider are not kept in the user store: their groups are mapped to the roles of their sessions.
+type OIDCConfig struct {
+	Issuer        string            `json:"issuer,omitempty"`         // Issuer URL, enables OIDC logins when set.
+	ClientID      string            `json:"client_id,omitempty"`      // Client ID registered at the provider.
+	ClientSecret  string            `json:"client_secret,omitempty"`  // Client secret (optional for public clients).
+	RedirectURL   string            `json:"redirect_url,omitempty"`   // External URL of the callback, ending with "/api/v1/oidc/callback".
+	Scopes        []string          `json:"scopes,omitempty"`         // Scopes requested besides "openid".
+	UsernameClaim string            `json:"username_claim,omitempty"` // ID token claim of the username.
+	GroupsClaim   string            `json:"groups_claim,omitempty"`   // ID token claim of the groups.
+	AllowedGroups []string          `json:"allowed_groups,omitempty"` // Groups allowed to log in (any if empty).
+	GroupRoles    map[string]string `json:"group_roles,omitempty"`    // Roles ("admin" or "viewer") of groups.
+	DefaultRole   string            `json:"default_role,omitempty"`   // Role of users in none of the groups of 'group_roles'.
+}
+
+// Enabled returns true if OIDC logins are configured.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// FillDefaults fills the defaults of enabled OIDC logins.
+func (c *OIDCConfig) FillDefaults() {
+	if !c.Enabled() {
+		return
+	}
+
+	if c.Scopes == nil {
+		c.Scopes = []string{"profile", "email", "groups"}
+	}
+
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+
+	if c.DefaultRole == "" {
+		c.DefaultRole = RoleViewer
+	}
+}
+
+func (c OIDCConfig) validate() error {
+	if c.ClientID == "" || c.RedirectURL == "" {
+		return fmt.Errorf("%w: 'client_id' and 'redirect_url' are required", ErrBadOIDCConfig)
+	}
+
+	if !validRole(c.DefaultRole) {
+		return fmt.Errorf("%w: unknown default role %q", ErrBadOIDCConfig, c.DefaultRole)
+	}
+
+	for group, role := range c.GroupRoles {
+		if !validRole(role) {
+			return fmt.Errorf("%w: unknown role %q of group %q", ErrBadOIDCConfig, role, group)
+		}
+	}
+
+	return nil
+}
+
+// role returns the role of a user in the given groups, or false if the user is not allowed to log in.
+// Users in multiple groups get the role of most privileges.
+func (c OIDCConfig) role(groups []string) (string, bool) {
+	allowed := len(c.AllowedGroups) == 0
+	role := ""
+
+	for _, g := range groups {
+		for _, a := range c.AllowedGroups {
+			allowed = allowed || g == a
+		}
+
+		switch c.GroupRoles[g] {
+		case RoleAdmin:
+			role = RoleAdmin
+		case RoleViewer:
+			if role == "" {
+				role = RoleViewer
+			}
+		}
+	}
+
+	if role == "" {
+		role = c.DefaultRole
+	}
+
+	return role, allowed
+}
+
+// oidcMetadata is the part of the discovery document of an OpenID Connect provider in use.
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLoginState is kept in a signed cookie while users log in at the identity provider.
+type oidcLoginState struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+	Redirect string
+	Expires  time.Time
+}
+
+type oidcClient struct {
+	c      OIDCConfig
+	client *http.Client
+	crypto *securecookie.SecureCookie
+
+	mx          sync.Mutex
+	meta        *oidcMetadata
+	metaFetched time.Time
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+func newOIDCClient(c OIDCConfig, cookies CookieConfig) (*oidcClient, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return &oidcClient{
+		c:      c,
+		client: &http.Client{Timeout: oidcHTTPTimeout},
+		crypto: securecookie.New(cookies.HashKey, cookies.BlockKey),
+	}, nil
+}
+
+func (o *oidcClient) metadata(ctx context.Context) (*oidcMetadata, error) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	if o.meta != nil && time.Since(o.metaFetched) < oidcMetadataTTL {
+		return o.meta, nil
+	}
+
+	issuer := strings.TrimSuffix(o.c.Issuer, "/")
+
+	var meta oidcMetadata
+	if err := o.getJSON(ctx, issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: discovered issuer %q does not match", ErrOIDCProvider, meta.Issuer)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrOIDCProvider)
+	}
+
+	o.meta, o.metaFetched = &meta, time.Now()
+
+	return o.meta, nil
+}
+
+// verificationKeys returns the keys of the provider which may have signed a token of given key ID.
+// Keys are fetched again if none matches, as providers rotate their keys.
+func (o *oidcClient) verificationKeys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	meta, err := o.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	keys := matchingKeys(o.keys, kid)
+	if len(keys) > 0 || time.Since(o.keysFetched) < oidcKeysRefreshInterval {
+		return keys, nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := o.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	o.keys, o.keysFetched = make(map[string]crypto.PublicKey, len(set.Keys)), time.Now()
+
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		pub, err := k.publicKey()
+		if err != nil {
+			log.WithError(err).WithField("kid", k.Kid).Warn("Ignoring key of oidc provider.")
+			continue
+		}
+
+		id := k.Kid
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+
+		o.keys[id] = pub
+	}
+
+	return matchingKeys(o.keys, kid), nil
+}
+
+func matchingKeys(keys map[string]crypto.PublicKey, kid string) []crypto.PublicKey {
+	if kid != "" {
+		if k, ok := keys[kid]; ok {
+			return []crypto.PublicKey{k}
+		}
+
+		return nil
+	}
+
+	out := make([]crypto.PublicKey, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k)
+	}
+
+	return out
+}
+
+func (o *oidcClient) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	return o.do(req, v)
+}
+
+func (o *oidcClient) do(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close oidc response body.")
+		}
+	}()
+
+	raw, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s responded with status %d: %s", ErrOIDCProvider, req.URL.Path, resp.StatusCode,
+			strings.TrimSpace(string(raw)))
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	return nil
+}
+
+// authCodeURL returns the URL of the provider to log in at, and the state to keep until the callback.
+func (o *oidcClient) authCodeURL(ctx context.Context, redirect string) (string, oidcLoginState, error) {
+	meta, err := o.metadata(ctx)
+	if err != nil {
+		return "", oidcLoginState{}, err
+	}
+
+	st := oidcLoginState{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: randomToken(),
+		Redirect: redirect,
+		Expires:  time.Now().Add(oidcLoginTimeout),
+	}
+
+	challenge := sha256.Sum256([]byte(st.Verifier))
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.c.ClientID},
+		"redirect_uri":          {o.c.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, o.c.Scopes...), " ")},
+		"state":                 {st.State},
+		"nonce":                 {st.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + q.Encode(), st, nil
+}
+
+// exchange redeems an authorization code, and returns the verified claims of the ID token.
+func (o *oidcClient) exchange(ctx context.Context, code string, st oidcLoginState) (map[string]interface{}, error) {
+	meta, err := o.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.c.RedirectURL},
+		"code_verifier": {st.Verifier},
+	}
+
+	if o.c.ClientSecret == "" {
+		form.Set("client_id", o.c.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if o.c.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.c.ClientID), url.QueryEscape(o.c.ClientSecret))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+
+	if err := o.do(req, &tokens); err != nil {
+		return nil, err
+	}
+
+	return o.verifyIDToken(ctx, tokens.IDToken, st.Nonce, time.Now())
+}
+
+// verifyIDToken verifies the signature, issuer, audience, expiry and nonce of an ID token, and returns its claims.
+func (o *oidcClient) verifyIDToken(ctx context.Context, token, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrBadIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrBadIDToken)
+	}
+
+	keys, err := o.verificationKeys(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := false
+	for _, k := range keys {
+		if verifyJWTSignature(header.Alg, k, parts[0]+"."+parts[1], sig) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, fmt.Errorf("%w: signature does not match any key of the provider", ErrBadIDToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(o.c.Issuer, "/") {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrBadIDToken, iss)
+	}
+
+	if !audienceContains(claims["aud"], o.c.ClientID) {
+		return nil, fmt.Errorf("%w: not issued to this client", ErrBadIDToken)
+	}
+
+	if azp, ok := claims["azp"].(string); ok && azp != o.c.ClientID {
+		return nil, fmt.Errorf("%w: authorized for another party", ErrBadIDToken)
+	}
+
+	exp, _ := claims["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrBadIDToken)
+	}
+
+	if n, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrBadIDToken)
+	}
+
+	return claims, nil
+}
+
+// user returns the username and groups from the claims of an ID token.
+// The subject identifies users without the username claim.
+func (o *oidcClient) user(claims map[string]interface{}) (string, []string) {
+	name, _ := claims[o.c.UsernameClaim].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+
+	var groups []string
+
+	switch v := claims[o.c.GroupsClaim].(type) {
+	case string:
+		groups = []string{v}
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+
+	return name, groups
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: malformed encoding", ErrBadIDToken)
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadIDToken, err)
+	}
+
+	return nil
+}
+
+// verifyJWTSignature verifies RSA (PKCS #1 v1.5) and ECDSA signatures of JWTs.
+// Other algorithms, including unsigned tokens, are refused.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, sig []byte) bool {
+	if len(alg) != 5 {
+		return false
+	}
+
+	var hash crypto.Hash
+
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed)) // nolint: errcheck
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return false
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+
+		return ecdsa.Verify(k, digest, r, s)
+
+	default:
+		return false
+	}
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	param := func(s string) (*big.Int, error) {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(raw) == 0 {
+			return nil, fmt.Errorf("malformed %s key parameter", k.Kty)
+		}
+
+		return new(big.Int).SetBytes(raw), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := param(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := param(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := param(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := param(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func randomToken() string {
+	return base64.RawURLEncoding.EncodeToString(cipher.RandByte(32))
+}
+
+// getOIDCLogin redirects to the identity provider to log in. Once logged in, the callback redirects to the path of
+// the 'redirect' query (the web UI by default).
+func (hv *Hypervisor) getOIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redirect := r.URL.Query().Get("redirect")
+		if redirect == "" {
+			redirect = hv.c.basePath() + "/"
+		}
+
+		// Only local paths are accepted, not to redirect users elsewhere.
+		if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		if _, _, ok := hv.users.session(r); ok {
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+			return
+		}
+
+		authURL, st, err := hv.oidc.authCodeURL(r.Context(), redirect)
+		if err != nil {
+			log.WithError(err).Error("Failed to start oidc login.")
+			httputil.WriteJSON(w, r, http.StatusBadGateway, err)
+
+			return
+		}
+
+		value, err := hv.oidc.crypto.Encode(oidcStateCookieName, st)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		hv.setOIDCStateCookie(w, value, st.Expires)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// getOIDCCallback completes logins at the identity provider, creating sessions with roles mapped from the groups
+// of users.
+func (hv *Hypervisor) getOIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var st oidcLoginState
+
+		cookie, err := r.Cookie(oidcStateCookieName)
+		if err != nil || hv.oidc.crypto.Decode(oidcStateCookieName, cookie.Value, &st) != nil ||
+			time.Now().After(st.Expires) || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(st.State)) != 1 {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrOIDCState)
+			return
+		}
+
+		hv.setOIDCStateCookie(w, "", time.Time{})
+
+		if e := q.Get("error"); e != "" {
+			log.WithField("error", e).WithField("description", q.Get("error_description")).Warn("Oidc login failed.")
+			httputil.WriteJSON(w, r, http.StatusUnauthorized, fmt.Errorf("%w: %s", ErrOIDCProvider, e))
+
+			return
+		}
+
+		claims, err := hv.oidc.exchange(r.Context(), q.Get("code"), st)
+		if err != nil {
+			log.WithError(err).Warn("Failed to complete oidc login.")
+
+			status := http.StatusBadGateway
+			if errors.Is(err, ErrBadIDToken) {
+				status = http.StatusUnauthorized
+			}
+
+			httputil.WriteJSON(w, r, status, err)
+
+			return
+		}
+
+		name, groups := hv.oidc.user(claims)
+		if name == "" {
+			httputil.WriteJSON(w, r, http.StatusUnauthorized, fmt.Errorf("%w: no username", ErrBadIDToken))
+			return
+		}
+
+		role, ok := hv.oidc.c.role(groups)
+		if !ok {
+			log.WithField("user", name).WithField("groups", groups).Warn("Refused oidc login.")
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrOIDCGroupNotAllowed)
+
+			return
+		}
+
+		now := time.Now()
+		session := Session{
+			User:      name,
+			IP:        remoteIP(r),
+			UserAgent: r.UserAgent(),
+			Created:   now,
+			Expiry:    now.Add(hv.c.Cookies.ExpiresDuration),
+			Provider:  SessionProviderOIDC,
+			Role:      role,
+		}
+
+		if err := hv.users.newSession(w, session); err != nil {
+			log.WithError(err).Errorf("Failed to create a new session")
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		log.WithField("user", name).WithField("role", role).Info("Logged in through oidc.")
+
+		http.Redirect(w, r, st.Redirect, http.StatusSeeOther)
+	}
+}
+
+// setOIDCStateCookie sets the login state cookie, or deletes it if value is empty.
+// It is sent on the top-level redirect back from the identity provider, so it is lax.
+func (hv *Hypervisor) setOIDCStateCookie(w http.ResponseWriter, value string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     hv.c.Cookies.Path,
+		Domain:   hv.c.Cookies.Domain,
+		Expires:  expires,
+		Secure:   hv.c.Cookies.Secure(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if value == "" {
+		c.Expires, c.MaxAge = time.Time{}, -1
+	}
+
+	http.SetCookie(w, c)
+}
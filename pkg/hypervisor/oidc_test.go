@@ -0,0 +1,358 @@
+package hypervisor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDCProvider is an OpenID Connect provider issuing ID tokens of the next claims for any code.
+type fakeOIDCProvider struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	claims    map[string]interface{}
+	challenge string
+}
+
+func newFakeOIDCProvider(t *testing.T, clientID string) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &fakeOIDCProvider{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != clientID || secret != "secret" || r.FormValue("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(verifier[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		writeTestJSON(w, map[string]string{"id_token": signTestJWT(t, "RS256", "k1", key, p.claims)})
+	})
+
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *fakeOIDCProvider) setClaims(claims map[string]interface{}, challenge string) {
+	p.mu.Lock()
+	p.claims, p.challenge = claims, challenge
+	p.mu.Unlock()
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) // nolint: errcheck, gosec
+}
+
+func signTestJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+
+		sig = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	const clientID = "hypervisor"
+
+	provider := newFakeOIDCProvider(t, clientID)
+	defer provider.Close()
+
+	config := makeConfig(false)
+	config.EnableAuth = true
+	config.OIDC = OIDCConfig{
+		Issuer:        provider.URL,
+		ClientID:      clientID,
+		ClientSecret:  "secret",
+		RedirectURL:   "https://hypervisor.example.com/api/v1/oidc/callback",
+		AllowedGroups: []string{"ops", "staff"},
+		GroupRoles:    map[string]string{"ops": RoleAdmin},
+	}
+	config.FillDefaults(false)
+
+	confDir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+
+	config.DBPath = filepath.Join(confDir, "users.db")
+
+	addr, client, stop := makeStartNode(t, config)
+	defer stop()
+
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	get := func(path string) *http.Response {
+		resp, err := client.Get("https://" + addr + path)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	// login logs in through the provider as a user of given groups, and returns the response of the callback.
+	login := func(t *testing.T, groups []string, tamper func(claims map[string]interface{})) *http.Response {
+		jar, err := cookiejar.New(&cookiejar.Options{})
+		require.NoError(t, err)
+
+		client.Jar = jar
+
+		resp := get("/api/v1/oidc/login?redirect=/visors")
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		authURL, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, provider.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+
+		q := authURL.Query()
+		assert.Equal(t, clientID, q.Get("client_id"))
+		assert.Equal(t, config.OIDC.RedirectURL, q.Get("redirect_uri"))
+		assert.Equal(t, "openid profile email groups", q.Get("scope"))
+
+		claims := map[string]interface{}{
+			"iss":                provider.URL,
+			"aud":                clientID,
+			"sub":                "1234",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"nonce":              q.Get("nonce"),
+			"preferred_username": "alice",
+			"groups":             groups,
+		}
+
+		if tamper != nil {
+			tamper(claims)
+		}
+
+		provider.setClaims(claims, q.Get("code_challenge"))
+
+		return get("/api/v1/oidc/callback?code=good&state=" + url.QueryEscape(q.Get("state")))
+	}
+
+	var adminSID uuid.UUID
+
+	t.Run("admin", func(t *testing.T) {
+		resp := login(t, []string{"ops", "staff"}, nil)
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		assert.Equal(t, "/visors", resp.Header.Get("Location"))
+
+		resp, err := client.Get("https://" + addr + "/api/v1/user")
+		require.NoError(t, err)
+
+		var info struct {
+			Username string  `json:"username"`
+			Current  Session `json:"current_session"`
+		}
+
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, "alice", info.Username)
+		assert.Equal(t, SessionProviderOIDC, info.Current.Provider)
+		assert.Equal(t, RoleAdmin, info.Current.Role)
+		adminSID = info.Current.SID
+
+		resp, err = client.Post("https://"+addr+"/api/v1/change-password", "application/json", strings.NewReader(changePasswordPayload))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		resp := login(t, []string{"staff"}, func(claims map[string]interface{}) { claims["preferred_username"] = "bob" })
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		assert.Equal(t, http.StatusOK, get("/api/v1/visors").StatusCode)
+
+		// Viewers only see and revoke their own sessions.
+		resp, err := client.Get("https://" + addr + "/api/v1/user/sessions")
+		require.NoError(t, err)
+
+		var sessions []Session
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&sessions))
+		require.NoError(t, resp.Body.Close())
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "bob", sessions[0].User)
+
+		assert.Equal(t, http.StatusForbidden, get("/api/v1/user/sessions?all=true").StatusCode)
+
+		req, err := http.NewRequest(http.MethodDelete, "https://"+addr+"/api/v1/user/sessions/"+adminSID.String(), nil)
+		require.NoError(t, err)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		req, err = http.NewRequest(http.MethodPut, "https://"+addr+"/api/v1/read-only", strings.NewReader(`{"read_only":true}`))
+		require.NoError(t, err)
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = client.Post("https://"+addr+"/api/v1/schedules", "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("group_not_allowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, login(t, []string{"guests"}, nil).StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, get("/api/v1/visors").StatusCode)
+	})
+
+	t.Run("bad_nonce", func(t *testing.T) {
+		resp := login(t, []string{"ops"}, func(claims map[string]interface{}) { claims["nonce"] = "replayed" })
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("other_audience", func(t *testing.T) {
+		resp := login(t, []string{"ops"}, func(claims map[string]interface{}) { claims["aud"] = []string{"other"} })
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("bad_state", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/oidc/callback?code=good&state=forged").StatusCode)
+	})
+
+	t.Run("open_redirect", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/oidc/login?redirect=//evil.example.com").StatusCode)
+	})
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	o := &oidcClient{
+		c:           OIDCConfig{Issuer: "https://idp.example.com", ClientID: "hv"},
+		meta:        &oidcMetadata{},
+		metaFetched: time.Now(),
+		keys:        map[string]crypto.PublicKey{"ec": &key.PublicKey},
+		keysFetched: time.Now(),
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   "https://idp.example.com/",
+		"aud":   []string{"hv", "other"},
+		"azp":   "hv",
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": "n",
+	}
+
+	token := signTestJWT(t, "ES256", "ec", key, claims)
+
+	_, err = o.verifyIDToken(context.Background(), token, "n", now)
+	assert.NoError(t, err)
+
+	_, err = o.verifyIDToken(context.Background(), token, "n", now.Add(time.Hour))
+	assert.Error(t, err, "expired")
+
+	_, err = o.verifyIDToken(context.Background(), signTestJWT(t, "RS256", "ec", key, claims), "n", now)
+	assert.Error(t, err, "algorithm of another key type")
+
+	parts := strings.Split(token, ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	_, err = o.verifyIDToken(context.Background(), unsigned, "n", now)
+	assert.Error(t, err, "unsigned")
+}
+
+func TestOIDCConfigRole(t *testing.T) {
+	c := OIDCConfig{
+		AllowedGroups: []string{"ops", "staff"},
+		GroupRoles:    map[string]string{"ops": RoleAdmin, "staff": RoleViewer},
+		DefaultRole:   RoleViewer,
+	}
+
+	role, ok := c.role([]string{"staff", "ops"})
+	assert.True(t, ok)
+	assert.Equal(t, RoleAdmin, role)
+
+	role, ok = c.role([]string{"staff"})
+	assert.True(t, ok)
+	assert.Equal(t, RoleViewer, role)
+
+	_, ok = c.role([]string{"guests"})
+	assert.False(t, ok)
+
+	c.AllowedGroups, c.DefaultRole = nil, RoleAdmin
+
+	role, ok = c.role(nil)
+	assert.True(t, ok)
+	assert.Equal(t, RoleAdmin, role)
+
+	assert.Error(t, OIDCConfig{ClientID: "hv", RedirectURL: "https://hv", DefaultRole: "root"}.validate())
+}
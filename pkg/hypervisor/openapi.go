@@ -0,0 +1,346 @@
+package hypervisor
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/util/buildinfo"
+)
+
+const (
+	apiV1Prefix    = "/api/v1"
+	openAPIVersion = "3.0.3"
+)
+
+// apiSummaries describes the API endpoints, keyed by "<METHOD> <path relative to the API prefix>".
+// Routes of visors identified by name or alias share the summaries of routes of visors identified by public key.
+// nolint:gochecknoglobals
+var apiSummaries = map[string]string{
+	"GET /ping":                                  "Checks that the hypervisor is up",
+	"GET /openapi.json":                          "Returns this document",
+	"POST /create-account":                       "Creates the admin account",
+	"POST /login":                                "Logs in, setting the session cookie",
+	"POST /logout":                               "Logs out of the current session",
+	"GET /oidc/login":                            "Redirects to the OpenID Connect provider to log in",
+	"GET /oidc/callback":                         "Completes logins at the OpenID Connect provider",
+	"GET /user":                                  "Returns info of the logged in user",
+	"POST /change-password":                      "Changes the password of the logged in user",
+	"POST /user/2fa/setup":                       "Generates a TOTP secret for two-factor authentication",
+	"POST /user/2fa/enable":                      "Enables two-factor authentication",
+	"POST /user/2fa/disable":                     "Disables two-factor authentication",
+	"GET /user/sessions":                         "Lists active sessions of the user, or of all users with ?all=true (admins only)",
+	"DELETE /user/sessions/{id}":                 "Revokes a session (admins only for sessions of other users)",
+	"POST /user/unlock":                          "Lifts login delays and lockouts",
+	"GET /read-only":                             "Returns whether the hypervisor is in read-only mode",
+	"PUT /read-only":                             "Toggles read-only mode, in which modifications through the API are refused",
+	"GET /about":                                 "Returns info about the hypervisor",
+	"GET /health":                                "Summarizes the health of all connected visors",
+	"GET /visors":                                "Lists connected visors, optionally sorted by RPC latency",
+	"GET /changes":                               "Long-polls changes of visors, apps and transports after a cursor",
+	"GET /visors/standby":                        "Lists standby visors, which only send heartbeats",
+	"GET /uptimes":                               "Exports uptime history in the uptime tracker format",
+	"GET /cluster/visors":                        "Lists visors connected to any hypervisor instance sharing the store",
+	"GET /suggest/exit":                          "Suggests the nearest skysocks exit of a visor, by RPC latency and geolocation labels",
+	"POST /suggest/exit":                         "Sets the suggested skysocks exit as the server of a visor's skysocks-client",
+	"GET /route-finder/routes":                   "Looks up forward and reverse routes between two visors (cached)",
+	"DELETE /route-finder/cache":                 "Drops cached route finder responses",
+	"GET /admin/cache-stats":                     "Returns sizes, hit ratios and memory estimates of the hypervisor's caches",
+	"GET /admin/export.sqlite":                   "Exports visors, uptime history, events and bandwidth as a SQLite database",
+	"GET /topology":                              "Returns the network graph formed by transports of the connected visors",
+	"GET /updates/rollout":                       "Lists update rollouts",
+	"POST /updates/rollout":                      "Starts an update rollout",
+	"GET /updates/rollout/{id}":                  "Returns an update rollout",
+	"POST /updates/rollout/{id}/abort":           "Aborts an update rollout",
+	"GET /updates/rings":                         "Returns the update rings and the progress of updates through them",
+	"PUT /updates/rings":                         "Replaces the update rings, which roll out updates in stages automatically",
+	"GET /pty-recordings":                        "Lists recorded pty sessions, optionally filtered by visor and user",
+	"GET /pty-recordings/{id}":                   "Returns a recorded pty session as an asciicast file, for replay",
+	"DELETE /pty-recordings/{id}":                "Deletes a recorded pty session",
+	"GET /notifications/config":                  "Returns the webhook notifications config",
+	"POST /logs/collect":                         "Collects app logs of multiple visors into a gzipped tar archive",
+	"GET /accept-list":                           "Lists visors enrolled to connect, when the accept-list is enabled",
+	"POST /accept-list/tokens":                   "Creates a single-use enrollment token for visors to present when first connecting",
+	"PUT /accept-list/{pk}":                      "Enrolls a visor",
+	"DELETE /accept-list/{pk}":                   "Unenrolls a visor",
+	"POST /diagnostics":                          "Collects diagnostics bundles of multiple visors into a gzipped tar archive",
+	"POST /tests/bandwidth":                      "Measures the throughput between two visors in both directions",
+	"GET /labels":                                "Lists labels of visors, optionally filtered by a label selector",
+	"GET /schedules":                             "Lists scheduled tasks",
+	"POST /schedules":                            "Creates a scheduled task",
+	"GET /schedules/{id}":                        "Returns a scheduled task",
+	"PUT /schedules/{id}":                        "Replaces a scheduled task",
+	"DELETE /schedules/{id}":                     "Removes a scheduled task and its run history",
+	"GET /schedules/{id}/runs":                   "Returns the run history of a scheduled task",
+	"POST /schedules/{id}/run":                   "Runs a scheduled task immediately",
+	"GET /config-profiles":                       "Lists config profiles",
+	"POST /config-profiles":                      "Creates a config profile",
+	"GET /config-profiles/{name}":                "Returns a config profile",
+	"PUT /config-profiles/{name}":                "Replaces a config profile",
+	"DELETE /config-profiles/{name}":             "Removes a config profile",
+	"GET /reports/drift":                         "Compares the configs of visors against their config profiles",
+	"GET /config-snapshots":                      "Lists config snapshots of all visors",
+	"GET /config-snapshots/{id}":                 "Returns a config snapshot",
+	"DELETE /config-snapshots/{id}":              "Removes a config snapshot",
+	"POST /tools/decode-rule":                    "Decodes a hex-encoded routing rule into its summary",
+	"GET /apps/binaries":                         "Lists app binaries stored for installing on visors",
+	"POST /apps/binaries":                        "Fetches an app binary from a URL and stores it",
+	"GET /apps/binaries/{name}":                  "Describes a stored app binary",
+	"PUT /apps/binaries/{name}":                  "Uploads an app binary",
+	"DELETE /apps/binaries/{name}":               "Removes a stored app binary",
+	"GET /visors/{pk}":                           "Returns a visor's summary",
+	"PUT /visors/{pk}/name":                      "Sets a visor's name",
+	"PUT /visors/{pk}/alias":                     "Sets a visor's alias and notes",
+	"GET /visors/{pk}/health":                    "Returns a visor's health",
+	"POST /visors/{pk}/ping":                     "Measures the dmsg round trip time to a visor",
+	"GET /visors/{pk}/uptime":                    "Returns a visor's uptime",
+	"GET /visors/{pk}/uptime/stats":              "Returns a visor's uptime across restarts, boot count and last shutdown reason",
+	"GET /visors/{pk}/resources":                 "Returns the CPU load, memory, disk and network interface usage of a visor's host",
+	"GET /visors/{pk}/apps":                      "Lists a visor's apps",
+	"POST /visors/{pk}/apps/install":             "Installs a stored app binary on a visor and registers the app",
+	"GET /visors/{pk}/apps/{app}":                "Returns an app",
+	"PUT /visors/{pk}/apps/{app}":                "Changes an app's status or settings",
+	"POST /visors/{pk}/apps/{app}/update":        "Updates an app to the latest release, without updating the visor",
+	"GET /visors/{pk}/apps/{app}/connections":    "Lists an app's live connections",
+	"GET /visors/{pk}/apps/{app}/usage":          "Returns the CPU time, memory and open files of a running app, and its limits",
+	"GET /visors/{pk}/apps/{app}/settings":       "Returns an app's key-value settings",
+	"PUT /visors/{pk}/apps/{app}/settings/{key}": "Sets an app's setting, restarting the app if it is running",
+	"GET /visors/{pk}/apps/{app}/logs":           "Returns an app's logs since a timestamp, filtered by 'level' and capped by 'limit', or after a log sequence number",
+	"GET /visors/{pk}/transport-types":           "Lists supported transport types",
+	"GET /visors/{pk}/transports":                "Lists a visor's transports",
+	"POST /visors/{pk}/transports":               "Creates a transport",
+	"DELETE /visors/{pk}/transports":             "Removes the transports of a visor matching the type and remote filters",
+	"GET /visors/{pk}/transports/{tid}":          "Returns a transport",
+	"DELETE /visors/{pk}/transports/{tid}":       "Removes a transport",
+	"GET /visors/{pk}/transports/{tid}/stats":    "Returns the throughput series of a transport",
+	"GET /visors/{pk}/transport-policies":        "Returns the time-window transport policies of a visor and their state",
+	"PUT /visors/{pk}/transport-policies":        "Replaces the time-window transport policies of a visor",
+	"GET /visors/{pk}/transport-maintenance":     "Returns the transport targets of a visor and the transports established for them",
+	"PUT /visors/{pk}/transport-maintenance":     "Replaces the transport targets of a visor",
+	"GET /visors/{pk}/routes":                    "Lists a visor's routing rules",
+	"POST /visors/{pk}/routes":                   "Adds a routing rule",
+	"DELETE /visors/{pk}/routes":                 "Removes the routing rules of a visor matching the type, transport or expired filters",
+	"GET /visors/{pk}/routes/{rid}":              "Returns a routing rule",
+	"PUT /visors/{pk}/routes/{rid}":              "Replaces a routing rule",
+	"DELETE /visors/{pk}/routes/{rid}":           "Removes a routing rule",
+	"GET /visors/{pk}/routegroups":               "Lists a visor's route groups",
+	"GET /visors/{pk}/config":                    "Returns a visor's config",
+	"POST /visors/{pk}/diagnostics":              "Downloads a visor's diagnostics bundle of logs, redacted config, tables, health and goroutine dumps",
+	"PUT /visors/{pk}/config":                    "Replaces a visor's config",
+	"GET /visors/{pk}/config/snapshots":          "Lists config snapshots taken of a visor",
+	"POST /visors/{pk}/config/snapshots":         "Snapshots a visor's config into the hypervisor database",
+	"POST /visors/{pk}/config/restore":           "Applies a config snapshot to a visor",
+	"POST /visors/{pk}/restart":                  "Restarts a visor, within an optional timeout",
+	"POST /visors/{pk}/drain":                    "Makes a visor refuse new transports and routes, and shut down once its routes are idle or a timeout passes",
+	"POST /visors/{pk}/reboot":                   "Reboots the host of a visor, if enabled in its config",
+	"POST /visors/{pk}/shutdown":                 "Powers off the host of a visor, if enabled in its config",
+	"POST /visors/{pk}/exec":                     "Executes a command on a visor, within an optional timeout",
+	"GET /visors/{pk}/exec/stream":               "Streams a command's output over a WebSocket, with stdin and cancellation",
+	"GET /visors/{pk}/events":                    "Streams a visor's transport, route, app crash and update events over a WebSocket",
+	"POST /visors/{pk}/update":                   "Updates a visor, within an optional timeout",
+	"GET /visors/{pk}/update/available":          "Checks whether a visor update is available",
+	"POST /visors/{pk}/connectivity-test":        "Runs a connectivity test of a visor",
+	"GET /visors/{pk}/wake-config":               "Returns the Wake-on-LAN configuration of a visor",
+	"PUT /visors/{pk}/wake-config":               "Sets the Wake-on-LAN configuration of a visor",
+	"DELETE /visors/{pk}/wake-config":            "Removes the Wake-on-LAN configuration of a visor",
+	"POST /visors/{pk}/wake":                     "Wakes a visor's machine with Wake-on-LAN",
+	"GET /visors/{pk}/labels":                    "Returns the labels of a visor",
+	"PUT /visors/{pk}/labels":                    "Replaces the labels of a visor",
+	"GET /visors/{pk}/active-sessions":           "Lists pty and exec sessions in progress on a visor",
+	"GET /visors/{pk}/files":                     "Downloads a file from an allowed path of a visor",
+	"POST /visors/{pk}/files":                    "Uploads the request body to a file in an allowed path of a visor",
+}
+
+// apiPublicPaths are API paths that do not require a session.
+// nolint:gochecknoglobals
+var apiPublicPaths = map[string]bool{
+	"/ping":           true,
+	"/openapi.json":   true,
+	"/create-account": true,
+	"/login":          true,
+	"/logout":         true,
+	"/oidc/login":     true,
+	"/oidc/callback":  true,
+}
+
+// OpenAPI is an OpenAPI 3 document.
+type OpenAPI struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       OpenAPIInfo                     `json:"info"`
+	Servers    []OpenAPIServer                 `json:"servers"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components OpenAPIComponents               `json:"components"`
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIServer is a server object of an OpenAPI document.
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIComponents is the components object of an OpenAPI document.
+type OpenAPIComponents struct {
+	Schemas         map[string]interface{} `json:"schemas"`
+	SecuritySchemes map[string]interface{} `json:"securitySchemes,omitempty"`
+}
+
+// Operation is an operation object of an OpenAPI document.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody map[string]interface{} `json:"requestBody,omitempty"`
+	Responses   map[string]interface{} `json:"responses"`
+	Security    []map[string][]string  `json:"security,omitempty"`
+}
+
+// Parameter is a parameter object of an OpenAPI document.
+type Parameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+// openAPI generates the OpenAPI document of the versioned API, by walking its routes.
+func (hv *Hypervisor) openAPI() (*OpenAPI, error) {
+	doc := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info:    OpenAPIInfo{Title: "Skywire Hypervisor API", Version: buildinfo.Version()},
+		Servers: []OpenAPIServer{{URL: hv.c.basePath() + apiV1Prefix}},
+		Paths:   make(map[string]map[string]Operation),
+		Components: OpenAPIComponents{
+			Schemas: map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"error": map[string]string{"type": "string"}},
+				},
+			},
+		},
+	}
+
+	if hv.c.EnableAuth {
+		doc.Components.SecuritySchemes = map[string]interface{}{
+			"cookieAuth": map[string]string{"type": "apiKey", "in": "cookie", "name": sessionCookieName},
+		}
+	}
+
+	r := chi.NewRouter()
+	hv.v1Routes(r)
+
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		if doc.Paths[route] == nil {
+			doc.Paths[route] = make(map[string]Operation)
+		}
+
+		doc.Paths[route][strings.ToLower(method)] = hv.operation(method, route)
+
+		return nil
+	})
+
+	return doc, err
+}
+
+func (hv *Hypervisor) operation(method, route string) Operation {
+	op := Operation{
+		OperationID: operationID(method, route),
+		Summary:     apiSummaries[method+" "+regexp.MustCompile(`/visors/by-(name|alias)/{[^}]+}`).ReplaceAllString(route, "/visors/{pk}")],
+		Responses: map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Success",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{}},
+			},
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]string{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+
+	if parts := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2); parts[0] != "" {
+		op.Tags = []string{parts[0]}
+	}
+
+	for _, m := range regexp.MustCompile(`{([^}]+)}`).FindAllStringSubmatch(route, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   map[string]string{"type": "string"},
+		})
+	}
+
+	if method == http.MethodPost || method == http.MethodPut {
+		op.RequestBody = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]string{"type": "object"},
+				},
+			},
+		}
+	}
+
+	if hv.c.EnableAuth && !apiPublicPaths[route] {
+		op.Security = []map[string][]string{{"cookieAuth": {}}}
+	}
+
+	return op
+}
+
+// operationID derives an operation ID from the method and route, such as "getVisorsPkApps".
+func operationID(method, route string) string {
+	var b strings.Builder
+
+	b.WriteString(strings.ToLower(method))
+
+	words := regexp.MustCompile(`[a-zA-Z0-9]+`).FindAllString(route, -1)
+	for _, w := range words {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+
+	return b.String()
+}
+
+func (hv *Hypervisor) getOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := hv.openAPI()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, doc)
+	}
+}
+
+// deprecatedAPI returns a middleware marking responses of the unversioned API paths under base as deprecated,
+// pointing clients at their versioned successors.
+func deprecatedAPI(base string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			successor := base + apiV1Prefix + strings.TrimPrefix(r.URL.Path, base+"/api")
+
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
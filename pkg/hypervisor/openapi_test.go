@@ -0,0 +1,63 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	addr, client, _, stop := makeStartMockNode(t)
+	defer stop()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/openapi.json",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var doc OpenAPI
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&doc))
+
+				assert.Equal(t, openAPIVersion, doc.OpenAPI)
+				assert.Equal(t, apiV1Prefix, doc.Servers[0].URL)
+
+				op, ok := doc.Paths["/visors/{pk}/apps/{app}"]["put"]
+				require.True(t, ok)
+				assert.Equal(t, "putVisorsPkAppsApp", op.OperationID)
+				assert.Equal(t, []string{"visors"}, op.Tags)
+				assert.Len(t, op.Parameters, 2)
+				assert.NotNil(t, op.RequestBody)
+
+				_, ok = doc.Paths["/visors/{pk}"]["get"]
+				assert.True(t, ok)
+
+				for path, ops := range doc.Paths {
+					for method, op := range ops {
+						assert.NotEmpty(t, op.Summary, "%s %s has no summary", method, path)
+					}
+				}
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				assert.Empty(t, r.Header.Get("Deprecation"))
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/visors",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				assert.Equal(t, "true", r.Header.Get("Deprecation"))
+				assert.Equal(t, `</api/v1/visors>; rel="successor-version"`, r.Header.Get("Link"))
+			},
+		},
+	})
+}
@@ -0,0 +1,142 @@
+package hypervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+)
+
+const (
+	defaultProbeInterval = 30 * time.Second
+	probeTimeout         = 5 * time.Second
+)
+
+// ErrProbeUnavailable occurs when a visor can not be probed, such as mock visors.
+var ErrProbeUnavailable = errors.New("probing is not available for this visor")
+
+// ProbeResult describes the reachability of a visor over dmsg, measured by echoing data off the visor.
+type ProbeResult struct {
+	Reachable    bool      `json:"reachable"`                // Whether the last probe succeeded.
+	LatencyMs    float64   `json:"latency_ms,omitempty"`     // Round trip time of the last successful probe.
+	AvgLatencyMs float64   `json:"avg_latency_ms,omitempty"` // Moving average of round trip times.
+	Failures     int       `json:"failures,omitempty"`       // Consecutive failed probes.
+	Error        string    `json:"error,omitempty"`          // Error of the last probe, if it failed.
+	Time         time.Time `json:"time"`                     // Time of the last probe.
+}
+
+// probes keeps the last probe results of the connected visors.
+type probes struct {
+	mu      sync.Mutex
+	results map[cipher.PubKey]ProbeResult
+}
+
+func newProbes() *probes {
+	return &probes{results: make(map[cipher.PubKey]ProbeResult)}
+}
+
+func (p *probes) record(pk cipher.PubKey, rtt time.Duration, err error, now time.Time) ProbeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := p.results[pk]
+	res.Time = now.UTC()
+	res.Reachable = err == nil
+
+	if err != nil {
+		res.Failures++
+		res.Error = err.Error()
+	} else {
+		ms := durationMs(rtt)
+		if res.AvgLatencyMs == 0 {
+			res.AvgLatencyMs = ms
+		} else {
+			res.AvgLatencyMs = latencySmoothing*ms + (1-latencySmoothing)*res.AvgLatencyMs
+		}
+
+		res.LatencyMs, res.Failures, res.Error = ms, 0, ""
+	}
+
+	p.results[pk] = res
+
+	return res
+}
+
+func (p *probes) get(pk cipher.PubKey) (*ProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res, ok := p.results[pk]
+	if !ok {
+		return nil, false
+	}
+
+	return &res, true
+}
+
+// probeVisors periodically probes the connected visors.
+func (hv *Hypervisor) probeVisors(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+
+	hv.every(interval, func(time.Time) {
+		hv.probeAll()
+	})
+}
+
+func (hv *Hypervisor) probeAll() {
+	hv.mu.RLock()
+	conns := make(map[cipher.PubKey]VisorConn, len(hv.visors))
+	for pk, c := range hv.visors {
+		conns[pk] = c
+	}
+	hv.mu.RUnlock()
+
+	var wg sync.WaitGroup
+
+	for pk, c := range conns {
+		if c.Echo == nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(pk cipher.PubKey, c VisorConn) {
+			defer wg.Done()
+
+			if res := hv.probe(context.Background(), pk, c); !res.Reachable {
+				log.WithField("visor_pk", pk).WithField("failures", res.Failures).Debug("Failed to probe visor: ", res.Error)
+			}
+		}(pk, c)
+	}
+
+	wg.Wait()
+}
+
+// probe measures the dmsg round trip time to the visor, and records the result.
+func (hv *Hypervisor) probe(ctx context.Context, pk cipher.PubKey, c VisorConn) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	rtt, err := c.Echo.Ping(ctx)
+
+	return hv.probes.record(pk, rtt, err, time.Now())
+}
+
+// postPing probes the visor right away, and returns the result.
+// Failed probes are reported in the result, rather than as errors.
+func (hv *Hypervisor) postPing() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		if ctx.Echo == nil {
+			httputil.WriteJSON(w, r, http.StatusServiceUnavailable, ErrProbeUnavailable)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, hv.probe(r.Context(), ctx.Addr.PK, ctx.VisorConn))
+	})
+}
@@ -0,0 +1,111 @@
+package hypervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestProbes(t *testing.T) {
+	p := newProbes()
+	pk := cipher.PubKey{1}
+	now := time.Now()
+
+	res := p.record(pk, 10*time.Millisecond, nil, now)
+	assert.True(t, res.Reachable)
+	assert.Equal(t, 10.0, res.LatencyMs)
+	assert.Equal(t, 10.0, res.AvgLatencyMs)
+
+	res = p.record(pk, 20*time.Millisecond, nil, now)
+	assert.Equal(t, 20.0, res.LatencyMs)
+	assert.InDelta(t, 13.0, res.AvgLatencyMs, 0.001)
+
+	p.record(pk, 0, errors.New("timeout"), now)
+	res = p.record(pk, 0, errors.New("timeout"), now)
+	assert.False(t, res.Reachable)
+	assert.Equal(t, 2, res.Failures)
+	assert.Equal(t, "timeout", res.Error)
+	assert.Equal(t, 20.0, res.LatencyMs, "the last successful latency is kept")
+
+	res = p.record(pk, 10*time.Millisecond, nil, now)
+	assert.Zero(t, res.Failures)
+	assert.Empty(t, res.Error)
+}
+
+func TestPostPing(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	hv.mu.Lock()
+	c := hv.visors[pks[1]]
+	c.Echo = visor.NewEchoClient(func(context.Context) (net.Conn, error) {
+		return nil, errors.New("no route to visor")
+	})
+	hv.visors[pks[1]] = c
+	hv.mu.Unlock()
+
+	probe := func(reachable bool) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var res ProbeResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+			assert.Equal(t, reachable, res.Reachable)
+
+			if reachable {
+				assert.Greater(t, res.LatencyMs, 0.0)
+			} else {
+				assert.Equal(t, 1, res.Failures)
+			}
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/ping", pks[0]),
+			RespStatus: http.StatusOK,
+			RespBody:   probe(true),
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/ping", pks[1]),
+			RespStatus: http.StatusOK,
+			RespBody:   probe(false),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var summaries []summaryResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&summaries))
+
+				probed := make(map[cipher.PubKey]*ProbeResult)
+				for _, s := range summaries {
+					probed[s.PubKey] = s.Probe
+				}
+
+				require.NotNil(t, probed[pks[0]])
+				assert.True(t, probed[pks[0]].Reachable)
+				require.NotNil(t, probed[pks[1]])
+				assert.False(t, probed[pks[1]].Reachable)
+				assert.Nil(t, probed[pks[2]])
+			},
+		},
+	})
+}
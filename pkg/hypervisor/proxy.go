@@ -0,0 +1,115 @@
+package hypervisor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preflight responses are cached by browsers for this long.
+const corsMaxAge = 10 * time.Minute
+
+// The base URL of the web UI, rewritten when served under a base path.
+var uiBaseHref = []byte(`<base href="/">`) // nolint: gochecknoglobals
+
+// basePath returns the configured base path without a trailing slash, such as "/skywire". It is empty by default.
+func (c *Config) basePath() string {
+	p := strings.Trim(c.BasePath, "/")
+	if p == "" {
+		return ""
+	}
+
+	return "/" + p
+}
+
+// corsAllowed returns whether cross-origin requests of origin are allowed.
+func (c *Config) corsAllowed(origin string) bool {
+	for _, o := range c.CORSOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cors is an http middleware allowing cross-origin requests of the configured origins, with credentials.
+// Preflight requests of allowed origins are answered directly.
+func (hv *Hypervisor) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !hv.c.corsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Expose-Headers", RequestIDHeader)
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// assetsHandler serves the web UI. Under a base path, the base URL of its index page is rewritten,
+// so that the UI loads its scripts and calls the API under the base path too.
+func (hv *Hypervisor) assetsHandler() http.Handler {
+	fs := http.FileServer(hv.assets)
+
+	base := hv.c.basePath()
+	if base == "" {
+		return fs
+	}
+
+	return http.StripPrefix(base, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" {
+			http.Redirect(w, r, base+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			fs.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := hv.assets.Open("/index.html")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		index, err := ioutil.ReadAll(f)
+		if cErr := f.Close(); cErr != nil {
+			log.WithError(cErr).Warn("Failed to close index.html.")
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		index = bytes.Replace(index, uiBaseHref, []byte(`<base href="`+base+`/">`), 1)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if _, err := w.Write(index); err != nil {
+			log.WithError(err).Warn("Failed to write index.html.")
+		}
+	}))
+}
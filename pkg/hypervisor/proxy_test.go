@@ -0,0 +1,100 @@
+package hypervisor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_BasePath(t *testing.T) {
+	for in, out := range map[string]string{"": "", "/": "", "skywire": "/skywire", "/skywire/": "/skywire", "/a/b/": "/a/b"} {
+		c := Config{BasePath: in}
+		assert.Equal(t, out, c.basePath(), in)
+	}
+}
+
+func TestBasePathAndCORS(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	assetsDir := filepath.Join(dir, "assets")
+	require.NoError(t, os.Mkdir(assetsDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(assetsDir, "index.html"), []byte(`<head><base href="/"></head>`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(assetsDir, "main.js"), []byte(`main()`), 0600))
+
+	config := makeConfig(false)
+	config.DBPath = filepath.Join(dir, "users.db")
+	config.BasePath = "/skywire/"
+	config.CORSOrigins = []string{"https://ui.example.com"}
+
+	hv, err := New(http.Dir(assetsDir), config)
+	require.NoError(t, err)
+	assert.Equal(t, "/skywire/", hv.c.Cookies.Path)
+
+	srv := httptest.NewTLSServer(hv)
+	defer srv.Close()
+
+	do := func(method, path, origin string) (*http.Response, string) {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		require.NoError(t, err)
+
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+
+		client := srv.Client()
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp, string(body)
+	}
+
+	resp, _ := do(http.MethodGet, "/skywire/api/v1/ping", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = do(http.MethodGet, "/skywire/api/ping", "")
+	assert.Equal(t, "</skywire/api/v1/ping>; rel=\"successor-version\"", resp.Header.Get("Link"))
+
+	resp, _ = do(http.MethodGet, "/api/v1/ping", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body := do(http.MethodGet, "/skywire/", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `<head><base href="/skywire/"></head>`, body)
+
+	resp, body = do(http.MethodGet, "/skywire/main.js", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "main()", body)
+
+	resp, _ = do(http.MethodGet, "/skywire", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "/skywire/", resp.Header.Get("Location"))
+
+	resp, _ = do(http.MethodGet, "/skywire/api/v1/ping", "https://ui.example.com")
+	assert.Equal(t, "https://ui.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+
+	resp, _ = do(http.MethodOptions, "/skywire/api/v1/login", "https://ui.example.com")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Methods"), http.MethodPost)
+
+	resp, _ = do(http.MethodGet, "/skywire/api/v1/ping", "https://evil.example.com")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
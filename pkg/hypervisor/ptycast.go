@@ -0,0 +1,315 @@
+package hypervisor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Asciicast event types.
+const (
+	castOutput = "o"
+	castInput  = "i"
+)
+
+// castRecorder records terminal output and input as an asciicast v2 file.
+// See https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+type castRecorder struct {
+	start     time.Time
+	max       int
+	buf       bytes.Buffer
+	truncated bool
+	partial   map[string][]byte // Incomplete UTF-8 sequences at the end of the last event of each type.
+	mu        sync.Mutex
+}
+
+func newCastRecorder(start time.Time, title string, max int) *castRecorder {
+	rec := &castRecorder{start: start, max: max, partial: make(map[string][]byte)}
+
+	header, err := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     80,
+		"height":    24,
+		"timestamp": start.Unix(),
+		"title":     title,
+	})
+	if err != nil {
+		panic(err) // should never happen
+	}
+
+	rec.buf.Write(header)
+	rec.buf.WriteByte('\n')
+
+	return rec
+}
+
+// record records an event of data at t. Events beyond the maximum size are dropped.
+func (rec *castRecorder) record(t time.Time, kind string, data []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	data = append(rec.partial[kind], data...)
+	data, rec.partial[kind] = splitIncompleteRune(data)
+
+	if len(data) == 0 || rec.truncated {
+		return
+	}
+
+	event, err := json.Marshal([]interface{}{t.Sub(rec.start).Seconds(), kind, string(data)})
+	if err != nil {
+		return
+	}
+
+	if rec.max > 0 && rec.buf.Len()+len(event)+1 > rec.max {
+		rec.truncated = true
+		return
+	}
+
+	rec.buf.Write(event)
+	rec.buf.WriteByte('\n')
+}
+
+// cast returns the recorded asciicast file, and whether events were dropped.
+func (rec *castRecorder) cast() ([]byte, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]byte(nil), rec.buf.Bytes()...), rec.truncated
+}
+
+// splitIncompleteRune splits off an incomplete UTF-8 sequence at the end of data,
+// so that characters split across WebSocket messages are recorded whole.
+func splitIncompleteRune(data []byte) ([]byte, []byte) {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i], append([]byte(nil), data[len(data)-i:]...)
+			}
+
+			break
+		}
+	}
+
+	return data, nil
+}
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+)
+
+// wsFrameParser extracts the payloads of WebSocket data messages from one direction of a connection.
+// Server to client streams start with the HTTP response of the handshake, which is skipped.
+type wsFrameParser struct {
+	buf        []byte
+	skipHeader bool
+	opcode     byte
+	onData     func(data []byte)
+}
+
+var errBadWSFrame = errors.New("malformed websocket frame")
+
+// Write implements io.Writer, never failing so that it can be used to tee connections.
+func (p *wsFrameParser) Write(b []byte) (int, error) {
+	if p.onData == nil {
+		return len(b), nil
+	}
+
+	p.buf = append(p.buf, b...)
+
+	if p.skipHeader {
+		i := bytes.Index(p.buf, []byte("\r\n\r\n"))
+		if i < 0 {
+			return len(b), nil
+		}
+
+		p.buf, p.skipHeader = p.buf[i+4:], false
+	}
+
+	for {
+		n, err := p.parseFrame()
+		if err != nil {
+			// Stop recording rather than recording garbage.
+			p.onData, p.buf = nil, nil
+			return len(b), nil
+		}
+
+		if n == 0 {
+			return len(b), nil
+		}
+
+		p.buf = p.buf[n:]
+	}
+}
+
+// parseFrame parses a single frame at the start of the buffer, returning its size or 0 if it is incomplete.
+func (p *wsFrameParser) parseFrame() (int, error) {
+	if len(p.buf) < 2 {
+		return 0, nil
+	}
+
+	opcode := p.buf[0] & 0x0f
+	masked := p.buf[1]&0x80 != 0
+	size := uint64(p.buf[1] & 0x7f)
+	n := 2
+
+	switch size {
+	case 126:
+		if len(p.buf) < n+2 {
+			return 0, nil
+		}
+
+		size, n = uint64(binary.BigEndian.Uint16(p.buf[n:])), n+2
+	case 127:
+		if len(p.buf) < n+8 {
+			return 0, nil
+		}
+
+		size, n = binary.BigEndian.Uint64(p.buf[n:]), n+8
+		if size > 1<<31 {
+			return 0, errBadWSFrame
+		}
+	}
+
+	var mask []byte
+	if masked {
+		if len(p.buf) < n+4 {
+			return 0, nil
+		}
+
+		mask, n = p.buf[n:n+4], n+4
+	}
+
+	if uint64(len(p.buf)-n) < size {
+		return 0, nil
+	}
+
+	payload := append([]byte(nil), p.buf[n:n+int(size)]...)
+	if mask != nil {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	switch opcode {
+	case wsText, wsBinary:
+		p.opcode = opcode
+		p.onData(payload)
+	case wsContinuation:
+		if p.opcode != 0 {
+			p.onData(payload)
+		}
+	}
+
+	return n + int(size), nil
+}
+
+// recordingConn records the WebSocket messages of a hijacked connection, and calls onClose once it is closed.
+type recordingConn struct {
+	net.Conn
+	in, out *wsFrameParser
+	inMu    sync.Mutex
+	outMu   sync.Mutex
+	once    sync.Once
+	onClose func()
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+
+	c.inMu.Lock()
+	_, _ = c.in.Write(b[:n])
+	c.inMu.Unlock()
+
+	return n, err
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+
+	c.outMu.Lock()
+	_, _ = c.out.Write(b[:n])
+	c.outMu.Unlock()
+
+	return n, err
+}
+
+func (c *recordingConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+
+	return err
+}
+
+// recordingResponseWriter records the WebSocket session of the connection it is hijacked for.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	rec     *castRecorder
+	onClose func()
+}
+
+// Hijack implements http.Hijacker.
+func (w *recordingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := func(kind string) func(data []byte) {
+		return func(data []byte) { w.rec.record(time.Now(), kind, data) }
+	}
+
+	rc := &recordingConn{
+		Conn:    conn,
+		in:      &wsFrameParser{onData: record(castInput)},
+		out:     &wsFrameParser{onData: record(castOutput), skipHeader: true},
+		onClose: w.onClose,
+	}
+
+	// Client data buffered before hijacking is recorded right away, and read before the rest of the connection.
+	buffered, err := brw.Reader.Peek(brw.Reader.Buffered())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered = append([]byte(nil), buffered...)
+
+	if _, err := rc.in.Write(buffered); err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(&prefixReader{prefix: buffered, conn: rc})
+
+	return rc, bufio.NewReadWriter(reader, bufio.NewWriter(rc)), nil
+}
+
+// prefixReader reads prefix before reading from conn, without recording prefix again.
+type prefixReader struct {
+	prefix []byte
+	conn   *recordingConn
+}
+
+func (r *prefixReader) Read(b []byte) (int, error) {
+	if len(r.prefix) > 0 {
+		n := copy(b, r.prefix)
+		r.prefix = r.prefix[n:]
+
+		return n, nil
+	}
+
+	return r.conn.Read(b)
+}
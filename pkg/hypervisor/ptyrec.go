@@ -0,0 +1,294 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltPtyRecordingBucketName     = "pty_recordings"
+	boltPtyRecordingCastBucketName = "pty_recording_casts"
+	defaultMaxPtyRecordingSize     = 16 << 20
+)
+
+// ErrPtyRecordingNotFound is returned when a pty recording does not exist.
+var ErrPtyRecordingNotFound = errors.New("pty recording is not found")
+
+// PtyRecordingConfig configures recording of pty sessions.
+type PtyRecordingConfig struct {
+	Enable  bool `json:"enable"`   // Records pty sessions opened through '/pty/{pk}'.
+	MaxSize int  `json:"max_size"` // Maximum size of a recording in bytes, beyond which the rest of a session is not recorded.
+}
+
+// FillDefaults fills config with default values.
+func (c *PtyRecordingConfig) FillDefaults() {
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxPtyRecordingSize
+	}
+}
+
+// PtyRecording describes a recorded pty session.
+type PtyRecording struct {
+	ID         uuid.UUID     `json:"id"`
+	VisorPK    cipher.PubKey `json:"visor_pk"`
+	User       string        `json:"user,omitempty"`
+	RemoteAddr string        `json:"remote_addr"`
+	StartedAt  time.Time     `json:"started_at"`
+	EndedAt    time.Time     `json:"ended_at"`
+	Size       int           `json:"size"`
+	Truncated  bool          `json:"truncated"` // Whether the end of the session exceeded the maximum size.
+}
+
+// PtyRecordingStore stores pty session recordings in the asciicast v2 format.
+type PtyRecordingStore interface {
+	PtyRecordings() ([]PtyRecording, error)
+	PtyRecording(id uuid.UUID) (*PtyRecording, error)
+	PtyRecordingCast(id uuid.UUID) ([]byte, error)
+	AddPtyRecording(rec PtyRecording, cast []byte) error
+	DeletePtyRecording(id uuid.UUID) error
+}
+
+// BoltPtyRecordingStore implements PtyRecordingStore, storing pty recordings in a bbolt database.
+type BoltPtyRecordingStore struct {
+	*bbolt.DB
+}
+
+// NewBoltPtyRecordingStore creates a new BoltPtyRecordingStore on top of an opened bbolt database.
+func NewBoltPtyRecordingStore(db *bbolt.DB) (*BoltPtyRecordingStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range []string{boltPtyRecordingBucketName, boltPtyRecordingCastBucketName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return &BoltPtyRecordingStore{DB: db}, err
+}
+
+// PtyRecordings describes all pty recordings, latest first.
+func (s *BoltPtyRecordingStore) PtyRecordings() ([]PtyRecording, error) {
+	recs := make([]PtyRecording, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltPtyRecordingBucketName)).ForEach(func(_, v []byte) error {
+			var rec PtyRecording
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+
+			recs = append(recs, rec)
+
+			return nil
+		})
+	})
+
+	sortPtyRecordings(recs)
+
+	return recs, err
+}
+
+// PtyRecording describes the pty recording of id. Returns nil if there is none.
+func (s *BoltPtyRecordingStore) PtyRecording(id uuid.UUID) (rec *PtyRecording, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltPtyRecordingBucketName)).Get(id[:])
+		if raw == nil {
+			return nil
+		}
+
+		rec = new(PtyRecording)
+
+		return json.Unmarshal(raw, rec)
+	})
+
+	return rec, err
+}
+
+// PtyRecordingCast returns the asciicast file of the pty recording of id. Returns nil if there is none.
+func (s *BoltPtyRecordingStore) PtyRecordingCast(id uuid.UUID) (cast []byte, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		if raw := tx.Bucket([]byte(boltPtyRecordingCastBucketName)).Get(id[:]); raw != nil {
+			cast = append([]byte(nil), raw...)
+		}
+
+		return nil
+	})
+
+	return cast, err
+}
+
+// AddPtyRecording stores the pty recording.
+func (s *BoltPtyRecordingStore) AddPtyRecording(rec PtyRecording, cast []byte) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(boltPtyRecordingCastBucketName)).Put(rec.ID[:], cast); err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(boltPtyRecordingBucketName)).Put(rec.ID[:], raw)
+	})
+}
+
+// DeletePtyRecording removes the pty recording of id.
+func (s *BoltPtyRecordingStore) DeletePtyRecording(id uuid.UUID) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(boltPtyRecordingCastBucketName)).Delete(id[:]); err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(boltPtyRecordingBucketName)).Delete(id[:])
+	})
+}
+
+func sortPtyRecordings(recs []PtyRecording) {
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].StartedAt.After(recs[j].StartedAt)
+	})
+}
+
+// recordPty wraps w to record the pty session of the request, if it is upgraded to a WebSocket.
+// The recording is stored once the connection is closed.
+func (hv *Hypervisor) recordPty(w http.ResponseWriter, r *http.Request, pk cipher.PubKey) http.ResponseWriter {
+	if !hv.c.PtyRecording.Enable || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return w
+	}
+
+	// Compressed messages can not be recorded.
+	r.Header.Del("Sec-WebSocket-Extensions")
+
+	meta := PtyRecording{
+		ID:         uuid.New(),
+		VisorPK:    pk,
+		User:       requestUser(r),
+		RemoteAddr: remoteIP(r),
+		StartedAt:  time.Now().UTC(),
+	}
+
+	title := fmt.Sprintf("%s on %s", meta.User, pk)
+	if meta.User == "" {
+		title = fmt.Sprintf("%s on %s", meta.RemoteAddr, pk)
+	}
+
+	rec := newCastRecorder(meta.StartedAt, title, hv.c.PtyRecording.MaxSize)
+
+	return &recordingResponseWriter{
+		ResponseWriter: w,
+		rec:            rec,
+		onClose: func() {
+			cast, truncated := rec.cast()
+
+			meta.EndedAt = time.Now().UTC()
+			meta.Size = len(cast)
+			meta.Truncated = truncated
+
+			if err := hv.ptyRecordings.AddPtyRecording(meta, cast); err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Warn("Failed to store pty recording.")
+			}
+		},
+	}
+}
+
+// getPtyRecordings lists pty recordings, latest first.
+// The 'visor' and 'user' queries filter recordings by visor public key and user.
+func (hv *Hypervisor) getPtyRecordings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recs, err := hv.ptyRecordings.PtyRecordings()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		q := r.URL.Query()
+
+		var pk cipher.PubKey
+		if v := q.Get("visor"); v != "" {
+			if err := pk.UnmarshalText([]byte(v)); err != nil {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		filtered := make([]PtyRecording, 0, len(recs))
+
+		for _, rec := range recs {
+			if (pk.Null() || rec.VisorPK == pk) && (q.Get("user") == "" || rec.User == q.Get("user")) {
+				filtered = append(filtered, rec)
+			}
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, filtered)
+	}
+}
+
+// getPtyRecording serves a pty recording as an asciicast file, to be replayed with asciinema or its web player.
+func (hv *Hypervisor) getPtyRecording() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		cast, err := hv.ptyRecordings.PtyRecordingCast(id)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if cast == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrPtyRecordingNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-asciicast")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.cast"`, id))
+
+		if _, err := w.Write(cast); err != nil {
+			log.WithError(err).Warn("Failed to write pty recording.")
+		}
+	}
+}
+
+func (hv *Hypervisor) deletePtyRecording() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		rec, err := hv.ptyRecordings.PtyRecording(id)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if rec == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrPtyRecordingNotFound)
+			return
+		}
+
+		if err := hv.ptyRecordings.DeletePtyRecording(id); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,257 @@
+package hypervisor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsFrame encodes a single final WebSocket frame, masked if mask is not nil.
+func wsFrame(opcode byte, payload []byte, mask []byte) []byte {
+	frame := []byte{0x80 | opcode}
+
+	maskBit := byte(0)
+	if mask != nil {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	default:
+		frame = append(frame, maskBit|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+
+	if mask == nil {
+		return append(frame, payload...)
+	}
+
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+// castEvents parses the events of an asciicast file, checking its header.
+func castEvents(t *testing.T, cast []byte) [][]interface{} {
+	lines := strings.Split(strings.TrimSpace(string(cast)), "\n")
+
+	var header map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, float64(2), header["version"])
+
+	events := make([][]interface{}, 0, len(lines)-1)
+
+	for _, line := range lines[1:] {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestCastRecorder(t *testing.T) {
+	start := time.Now()
+
+	t.Run("split_runes", func(t *testing.T) {
+		rec := newCastRecorder(start, "test", 0)
+
+		snowman := []byte("☃")
+		rec.record(start.Add(time.Second), castOutput, append([]byte("a"), snowman[:1]...))
+		rec.record(start.Add(2*time.Second), castOutput, snowman[1:])
+		rec.record(start.Add(2*time.Second), castInput, []byte("q"))
+
+		cast, truncated := rec.cast()
+		assert.False(t, truncated)
+
+		events := castEvents(t, cast)
+		require.Len(t, events, 3)
+		assert.Equal(t, []interface{}{float64(1), castOutput, "a"}, events[0])
+		assert.Equal(t, []interface{}{float64(2), castOutput, "☃"}, events[1])
+		assert.Equal(t, []interface{}{float64(2), castInput, "q"}, events[2])
+	})
+
+	t.Run("max_size", func(t *testing.T) {
+		rec := newCastRecorder(start, "test", 100)
+
+		for i := 0; i < 10; i++ {
+			rec.record(start, castOutput, []byte("0123456789"))
+		}
+
+		cast, truncated := rec.cast()
+		assert.True(t, truncated)
+		assert.LessOrEqual(t, len(cast), 100)
+	})
+}
+
+func TestWSFrameParser(t *testing.T) {
+	var got []string
+
+	p := &wsFrameParser{skipHeader: true, onData: func(data []byte) { got = append(got, string(data)) }}
+
+	stream := []byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n")
+	stream = append(stream, wsFrame(wsText, []byte("hello"), nil)...)
+	stream = append(stream, wsFrame(0x9, []byte("ping"), nil)...)
+	stream = append(stream, wsFrame(wsBinary, bytes.Repeat([]byte("x"), 300), []byte{1, 2, 3, 4})...)
+
+	// Data is written byte by byte, to check that incomplete frames are buffered.
+	for i := range stream {
+		n, err := p.Write(stream[i : i+1])
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	}
+
+	assert.Equal(t, []string{"hello", strings.Repeat("x", 300)}, got)
+}
+
+func TestRecordingResponseWriter(t *testing.T) {
+	recorded := make(chan []byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newCastRecorder(time.Now(), "test", 0)
+		w = &recordingResponseWriter{
+			ResponseWriter: w,
+			rec:            rec,
+			onClose: func() {
+				cast, _ := rec.cast()
+				recorded <- cast
+			},
+		}
+
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+
+		_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+		require.NoError(t, err)
+
+		_, err = conn.Write(wsFrame(wsText, []byte("$ "), nil))
+		require.NoError(t, err)
+
+		// Reads the client's frame, which may have been buffered before hijacking.
+		frame := make([]byte, len(wsFrame(wsText, []byte("ls\r"), []byte{1, 2, 3, 4})))
+		_, err = brw.Read(frame[:1])
+		require.NoError(t, err)
+		_, err = brw.Read(frame[1:])
+		require.NoError(t, err)
+
+		require.NoError(t, conn.Close())
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"
+	_, err = conn.Write(append([]byte(req), wsFrame(wsText, []byte("ls\r"), []byte{1, 2, 3, 4})...))
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	select {
+	case cast := <-recorded:
+		events := castEvents(t, cast)
+		require.Len(t, events, 2)
+
+		// The client's input was buffered before hijacking, so it is recorded first.
+		assert.Equal(t, []interface{}{castInput, "ls\r"}, events[0][1:])
+		assert.Equal(t, []interface{}{castOutput, "$ "}, events[1][1:])
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording was not stored")
+	}
+}
+
+func TestPtyRecordings(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for visorPK := range hv.visors {
+		pk = visorPK
+	}
+
+	now := time.Now().UTC()
+	recs := []PtyRecording{
+		{ID: uuid.New(), VisorPK: pk, User: "alice", StartedAt: now.Add(-time.Hour)},
+		{ID: uuid.New(), VisorPK: pk, User: "bob", StartedAt: now},
+	}
+
+	for _, rec := range recs {
+		require.NoError(t, hv.ptyRecordings.AddPtyRecording(rec, []byte(`{"version":2}`+"\n")))
+	}
+
+	list := func(want ...PtyRecording) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var got []PtyRecording
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			require.Len(t, got, len(want))
+
+			for i := range want {
+				assert.Equal(t, want[i].ID, got[i].ID)
+			}
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/pty-recordings",
+			RespStatus: http.StatusOK,
+			RespBody:   list(recs[1], recs[0]),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/pty-recordings?user=alice&visor=" + pk.Hex(),
+			RespStatus: http.StatusOK,
+			RespBody:   list(recs[0]),
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/pty-recordings/" + recs[0].ID.String(),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				assert.Equal(t, "application/x-asciicast", r.Header.Get("Content-Type"))
+
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, `{"version":2}`+"\n", string(body))
+			},
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/pty-recordings/" + recs[0].ID.String(),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/pty-recordings/" + recs[0].ID.String(),
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/pty-recordings/" + recs[0].ID.String(),
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package hypervisor
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/httputil"
+)
+
+const (
+	defaultRequestsPerIP   = 600
+	defaultRequestsPerUser = 600
+	defaultRequestBurst    = 100
+	defaultMaxBodySize     = 1 << 20
+)
+
+// Errors associated with rate and size limits.
+var (
+	ErrRateLimited     = errors.New("too many requests, try again later")
+	ErrRequestTooLarge = errors.New("request body is too large")
+)
+
+// RateLimitConfig configures rate limits and body size limits of the API.
+// Rates are requests per minute, and negative values disable a limit.
+type RateLimitConfig struct {
+	PerIP       int   `json:"per_ip"`        // Requests per minute allowed from each client IP.
+	PerUser     int   `json:"per_user"`      // Requests per minute allowed for each logged in user.
+	Burst       int   `json:"burst"`         // Requests allowed in quick succession, before the rates apply.
+	MaxBodySize int64 `json:"max_body_size"` // Maximum size of JSON request bodies in bytes.
+}
+
+// FillDefaults fills config with default values.
+func (c *RateLimitConfig) FillDefaults() {
+	if c.PerIP == 0 {
+		c.PerIP = defaultRequestsPerIP
+	}
+
+	if c.PerUser == 0 {
+		c.PerUser = defaultRequestsPerUser
+	}
+
+	if c.Burst <= 0 {
+		c.Burst = defaultRequestBurst
+	}
+
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket rate limiter of requests, keyed by client.
+type rateLimiter struct {
+	perMinute int
+	burst     int
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// newRateLimiter returns nil if perMinute disables the limit.
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		perMinute: perMinute,
+		burst:     burst,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) capacity() float64 {
+	return float64(l.burst)
+}
+
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens = math.Min(l.capacity(), b.tokens+now.Sub(b.last).Minutes()*float64(l.perMinute))
+	b.last = now
+}
+
+// take takes a token of key. It returns the tokens remaining, and the time to wait if none are left.
+func (l *rateLimiter) take(key string, now time.Time) (int, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.capacity(), last: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / float64(l.perMinute) * float64(time.Minute))
+		return 0, wait, false
+	}
+
+	b.tokens--
+
+	return int(b.tokens), 0, true
+}
+
+// sweep forgets full buckets once a minute, so that the limiter does not grow indefinitely.
+// Must be called with mu held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.capacity() {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limit returns an http middleware which limits the requests of each key returned by keyFn.
+// Requests without a key are not limited.
+func (l *rateLimiter) limit(keyFn func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+
+			remaining, wait, ok := l.take(key, now)
+			setRateLimitHeaders(w, l.burst, remaining, now.Add(wait))
+
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				httputil.WriteJSON(w, r, http.StatusTooManyRequests, ErrRateLimited)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestUser returns the name of the user authorized to make the request, if any.
+func requestUser(r *http.Request) string {
+	if user, ok := r.Context().Value(userKey).(User); ok {
+		return user.Name
+	}
+
+	return ""
+}
+
+// limitBody is an http middleware which limits the size of request bodies to max bytes.
+// Requests declaring larger bodies are rejected right away, while others fail to read past the limit.
+func limitBody(max int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				httputil.WriteJSON(w, r, http.StatusRequestEntityTooLarge, ErrRequestTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
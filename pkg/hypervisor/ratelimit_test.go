@@ -0,0 +1,104 @@
+package hypervisor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(60, 3)
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		remaining, _, ok := l.take("a", now)
+		require.True(t, ok)
+		assert.Equal(t, i, remaining)
+	}
+
+	_, wait, ok := l.take("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Other clients have limits of their own.
+	_, _, ok = l.take("b", now)
+	assert.True(t, ok)
+
+	_, _, ok = l.take("a", now.Add(time.Second))
+	assert.True(t, ok)
+
+	// Full buckets are forgotten.
+	l.take("c", now.Add(time.Hour))
+	assert.Len(t, l.buckets, 1)
+
+	assert.Nil(t, newRateLimiter(-1, 3))
+}
+
+func TestRequestLimits(t *testing.T) {
+	config := makeConfig(false)
+	config.RateLimits = RateLimitConfig{PerIP: 60, Burst: 5, MaxBodySize: 64}
+
+	confDir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(confDir)) }()
+
+	config.DBPath = filepath.Join(confDir, "users.db")
+
+	hv, err := New(nil, config)
+	require.NoError(t, err)
+	require.NoError(t, hv.AddMockData(MockConfig{Visors: 1}))
+
+	srv := httptest.NewTLSServer(hv)
+	defer srv.Close()
+
+	var pk string
+	for visorPK := range hv.visors {
+		pk = visorPK.Hex()
+	}
+
+	uri := "/api/v1/visors/" + pk + "/exec"
+
+	testCases(t, srv.Listener.Addr().String(), srv.Client(), []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"ls"}`),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`{"command":"` + strings.Repeat("a", 64) + `"}`),
+			RespStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors",
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	var limited *http.Response
+
+	for i := 0; i < 10 && limited == nil; i++ {
+		resp, err := srv.Client().Get(srv.URL + "/api/v1/visors")
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			limited = resp
+		}
+	}
+
+	require.NotNil(t, limited)
+	assert.Equal(t, "0", limited.Header.Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, limited.Header.Get("Retry-After"))
+}
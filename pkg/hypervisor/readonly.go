@@ -0,0 +1,78 @@
+package hypervisor
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// ErrReadOnly is returned for requests which would modify state while the hypervisor is in read-only mode.
+var ErrReadOnly = errors.New("hypervisor is in read-only mode")
+
+// ReadOnlyStatus is the read-only mode of the hypervisor.
+type ReadOnlyStatus struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+func (hv *Hypervisor) isReadOnly() bool {
+	return atomic.LoadInt32(&hv.readOnly) == 1
+}
+
+func (hv *Hypervisor) setReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	atomic.StoreInt32(&hv.readOnly, v)
+}
+
+// readOnlyGuard is an http middleware which refuses requests that may modify state while in read-only mode.
+// Besides non-GET requests, these are interactive sessions upgraded to WebSockets, such as exec streams and ptys.
+func (hv *Hypervisor) readOnlyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hv.isReadOnly() && (!safeMethod(r.Method) || strings.EqualFold(r.Header.Get("Upgrade"), "websocket")) {
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrReadOnly)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func (hv *Hypervisor) getReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httputil.WriteJSON(w, r, http.StatusOK, ReadOnlyStatus{ReadOnly: hv.isReadOnly()})
+	}
+}
+
+// putReadOnly toggles read-only mode until the hypervisor restarts, after which 'read_only' of the config applies.
+func (hv *Hypervisor) putReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody ReadOnlyStatus
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("putReadOnly request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		hv.setReadOnly(reqBody.ReadOnly)
+
+		log.WithField("read_only", reqBody.ReadOnly).WithField("user", requestUser(r)).Info("Read-only mode toggled.")
+
+		httputil.WriteJSON(w, r, http.StatusOK, reqBody)
+	}
+}
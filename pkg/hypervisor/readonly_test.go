@@ -0,0 +1,80 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnly(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk string
+	for visorPK := range hv.visors {
+		pk = visorPK.Hex()
+	}
+
+	readOnly := func(want bool) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var s ReadOnlyStatus
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+			assert.Equal(t, want, s.ReadOnly)
+		}
+	}
+
+	exec := func(status int) TestCase {
+		return TestCase{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/visors/" + pk + "/exec",
+			ReqBody:    strings.NewReader(`{"command":"ls"}`),
+			RespStatus: status,
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/read-only",
+			RespStatus: http.StatusOK,
+			RespBody:   readOnly(false),
+		},
+		exec(http.StatusOK),
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/read-only",
+			ReqBody:    strings.NewReader(`{"read_only":true}`),
+			RespStatus: http.StatusOK,
+			RespBody:   readOnly(true),
+		},
+		exec(http.StatusForbidden),
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     "/api/v1/visors/" + pk + "/transports/00000000-0000-0000-0000-000000000000",
+			RespStatus: http.StatusForbidden,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk + "/exec/stream",
+			ReqMod:     func(req *http.Request) { req.Header.Set("Upgrade", "websocket") },
+			RespStatus: http.StatusForbidden,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/" + pk,
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/read-only",
+			ReqBody:    strings.NewReader(`{"read_only":false}`),
+			RespStatus: http.StatusOK,
+			RespBody:   readOnly(false),
+		},
+		exec(http.StatusOK),
+	})
+}
@@ -0,0 +1,243 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltRegistryBucketName = "visor_registry"
+)
+
+// ErrUnknownStoreType occurs when the configured store type is not supported.
+var ErrUnknownStoreType = errors.New("unknown store type")
+
+// RegistryEntry records which hypervisor instance a visor is connected to.
+type RegistryEntry struct {
+	VisorPK      cipher.PubKey `json:"visor_pk"`
+	HypervisorPK cipher.PubKey `json:"hypervisor_pk"`
+	Addr         string        `json:"addr,omitempty"`
+	Connected    time.Time     `json:"connected"`
+}
+
+// VisorRegistry records visor connections of all hypervisor instances sharing the store.
+type VisorRegistry interface {
+	Register(entry RegistryEntry) error
+	Registry() ([]RegistryEntry, error)
+}
+
+// BoltVisorRegistry implements VisorRegistry, storing entries in a bbolt database.
+type BoltVisorRegistry struct {
+	*bbolt.DB
+}
+
+// NewBoltVisorRegistry creates a new BoltVisorRegistry on top of an opened bbolt database.
+func NewBoltVisorRegistry(db *bbolt.DB) (*BoltVisorRegistry, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltRegistryBucketName))
+		return err
+	})
+
+	return &BoltVisorRegistry{DB: db}, err
+}
+
+// Register records the entry, replacing any previous entry of the same visor.
+func (s *BoltVisorRegistry) Register(entry RegistryEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltRegistryBucketName)).Put(entry.VisorPK[:], raw)
+	})
+}
+
+// Registry returns all recorded entries.
+func (s *BoltVisorRegistry) Registry() ([]RegistryEntry, error) {
+	entries := make([]RegistryEntry, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltRegistryBucketName)).ForEach(func(_, v []byte) error {
+			var entry RegistryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// ClusterVisor is a visor as seen by the hypervisor cluster.
+type ClusterVisor struct {
+	RegistryEntry
+	Local bool `json:"local"` // Whether the visor is connected to the serving instance.
+}
+
+// getClusterVisors lists visors connected to any hypervisor instance sharing the store.
+func (hv *Hypervisor) getClusterVisors() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := hv.registry.Registry()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		visors := make([]ClusterVisor, 0, len(entries))
+
+		for _, e := range entries {
+			_, local := hv.visorConn(e.VisorPK)
+			visors = append(visors, ClusterVisor{RegistryEntry: e, Local: local})
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, visors)
+	}
+}
+
+type stores struct {
+	users    UserStore
+	sessions SessionStore
+	names    NameStore
+	meta     MetaStore
+	wake     WakeStore
+	uptimes  UptimeStore
+	registry VisorRegistry
+	labels   LabelStore
+	schedule ScheduleStore
+	profiles ProfileStore
+	snaps    SnapshotStore
+	bins     AppBinaryStore
+	accept   AcceptListStore
+	rings    UpdateRingStore
+	ptyRecs  PtyRecordingStore
+}
+
+// openStores opens the state stores of the configured type.
+func openStores(c Config) (stores, error) {
+	switch c.Store.Type {
+	case StoreSQL:
+		if c.DBEncryption.Enabled() {
+			return stores{}, ErrDBEncryptionSQL
+		}
+
+		s, err := NewSQLStore(c.Store.Driver, c.Store.DSN)
+		if err != nil {
+			return stores{}, err
+		}
+
+		return stores{
+			users:    s,
+			sessions: s,
+			names:    s,
+			meta:     s,
+			wake:     s,
+			uptimes:  s,
+			registry: s,
+			labels:   s,
+			schedule: s,
+			profiles: s,
+			snaps:    s,
+			bins:     s,
+			accept:   s,
+			rings:    s,
+			ptyRecs:  s,
+		}, nil
+
+	case StoreBolt, "":
+		return openBoltStores(c.DBPath, c.DBEncryption)
+
+	default:
+		return stores{}, fmt.Errorf("%w: %q", ErrUnknownStoreType, c.Store.Type)
+	}
+}
+
+func openBoltStores(path string, encryption DBEncryptionConfig) (st stores, err error) {
+	users, err := NewBoltUserStore(path)
+	if err != nil {
+		return st, err
+	}
+
+	sessions, err := NewBoltSessionStore(users.DB)
+	if err != nil {
+		return st, err
+	}
+
+	meta, err := NewBoltMetaStore(users.DB)
+	if err != nil {
+		return st, err
+	}
+
+	snaps, err := NewBoltSnapshotStore(users.DB)
+	if err != nil {
+		return st, err
+	}
+
+	enc, err := openDBCipher(users.DB, encryption,
+		boltUserBucketName, boltSessionBucketName, boltMetaBucketName, boltSnapshotBucketName)
+	if err != nil {
+		if cErr := users.Close(); cErr != nil {
+			log.WithError(cErr).Warn("Failed to close database.")
+		}
+
+		return st, err
+	}
+
+	users.enc, sessions.enc, meta.enc, snaps.enc = enc, enc, enc, enc
+	st.users, st.sessions, st.meta, st.snaps = users, sessions, meta, snaps
+
+	if st.names, err = NewBoltNameStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.wake, err = NewBoltWakeStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.uptimes, err = NewBoltUptimeStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.registry, err = NewBoltVisorRegistry(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.labels, err = NewBoltLabelStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.schedule, err = NewBoltScheduleStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.profiles, err = NewBoltProfileStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.bins, err = NewBoltAppBinaryStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.accept, err = NewBoltAcceptListStore(users.DB); err != nil {
+		return st, err
+	}
+
+	if st.rings, err = NewBoltUpdateRingStore(users.DB); err != nil {
+		return st, err
+	}
+
+	st.ptyRecs, err = NewBoltPtyRecordingStore(users.DB)
+
+	return st, err
+}
@@ -0,0 +1,74 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterVisors(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var localPK cipher.PubKey
+	for pk := range hv.visors {
+		localPK = pk
+		break
+	}
+
+	remotePK, _ := cipher.GenerateKeyPair()
+	otherHV, _ := cipher.GenerateKeyPair()
+
+	require.NoError(t, hv.registry.Register(RegistryEntry{VisorPK: localPK, HypervisorPK: hv.c.PK, Connected: time.Now()}))
+	require.NoError(t, hv.registry.Register(RegistryEntry{VisorPK: remotePK, HypervisorPK: hv.c.PK, Connected: time.Now()}))
+
+	// The visor moved to another instance.
+	require.NoError(t, hv.registry.Register(RegistryEntry{VisorPK: remotePK, HypervisorPK: otherHV, Connected: time.Now()}))
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/cluster/visors",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var visors []ClusterVisor
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&visors))
+				require.Len(t, visors, 2)
+
+				for _, v := range visors {
+					switch v.VisorPK {
+					case localPK:
+						assert.True(t, v.Local)
+						assert.Equal(t, hv.c.PK, v.HypervisorPK)
+					case remotePK:
+						assert.False(t, v.Local)
+						assert.Equal(t, otherHV, v.HypervisorPK)
+					default:
+						t.Errorf("unexpected visor %s", v.VisorPK)
+					}
+				}
+			},
+		},
+	})
+}
+
+func TestOpenStores(t *testing.T) {
+	_, err := openStores(Config{Store: StoreConfig{Type: "etcd"}})
+	assert.True(t, errors.Is(err, ErrUnknownStoreType))
+
+	_, err = openStores(Config{Store: StoreConfig{Type: StoreSQL, Driver: "no-such-driver"}})
+	assert.Error(t, err)
+}
+
+func TestSQLStore_Rebind(t *testing.T) {
+	q := `UPDATE hv_users SET data = ? WHERE name = ?`
+
+	assert.Equal(t, q, (&SQLStore{driver: "sqlite3"}).rebind(q))
+	assert.Equal(t, `UPDATE hv_users SET data = $1 WHERE name = $2`, (&SQLStore{driver: "postgres"}).rebind(q))
+}
@@ -0,0 +1,119 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/skycoin/dmsg/cipher"
+)
+
+const (
+	minVisorPKPrefixLen = 4
+	maxAmbiguousMatches = 5
+)
+
+// Errors associated with resolving visor identifiers.
+var (
+	ErrBadVisorID       = errors.New("visor identifier should be a public key, a unique public key prefix, or a visor name or alias")
+	ErrVisorIDNotFound  = errors.New("no visor matches the identifier")
+	ErrAmbiguousVisorID = errors.New("visor identifier is ambiguous")
+)
+
+// resolveVisor resolves a visor identifier to a public key. The identifier may be either:
+// a full public key, an assigned visor name or alias, or a unique prefix (of at least 4 hex chars) of a known public key.
+// An exact name or alias match takes precedence over a public key prefix match.
+func (hv *Hypervisor) resolveVisor(id string) (cipher.PubKey, int, error) {
+	var pk cipher.PubKey
+	if err := pk.UnmarshalText([]byte(id)); err == nil {
+		return pk, http.StatusOK, nil
+	}
+
+	if checkVisorNameFormat(id) {
+		pk, err := hv.names.PubKey(id)
+		if err == nil {
+			return pk, http.StatusOK, nil
+		}
+
+		if err != ErrVisorNameNotSet {
+			return pk, http.StatusInternalServerError, err
+		}
+	}
+
+	if checkVisorAliasFormat(id) {
+		pk, err := hv.meta.PubKeyByAlias(id)
+		if err == nil {
+			return pk, http.StatusOK, nil
+		}
+
+		if err != ErrVisorAliasNotSet {
+			return pk, http.StatusInternalServerError, err
+		}
+	}
+
+	id = strings.ToLower(id)
+	if len(id) < minVisorPKPrefixLen || !regexp.MustCompile(`^[0-9a-f]+$`).MatchString(id) {
+		return cipher.PubKey{}, http.StatusBadRequest, fmt.Errorf("%w: %q", ErrBadVisorID, id)
+	}
+
+	matches, err := hv.matchVisorPrefix(id)
+	if err != nil {
+		return cipher.PubKey{}, http.StatusInternalServerError, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return cipher.PubKey{}, http.StatusNotFound, fmt.Errorf("%w: %q", ErrVisorIDNotFound, id)
+	case 1:
+		return matches[0], http.StatusOK, nil
+	}
+
+	candidates := make([]string, 0, maxAmbiguousMatches)
+	for i, pk := range matches {
+		if i == maxAmbiguousMatches {
+			candidates = append(candidates, fmt.Sprintf("and %d more", len(matches)-i))
+			break
+		}
+
+		candidates = append(candidates, pk.Hex())
+	}
+
+	return cipher.PubKey{}, http.StatusConflict,
+		fmt.Errorf("%w: %q matches %s", ErrAmbiguousVisorID, id, strings.Join(candidates, ", "))
+}
+
+// matchVisorPrefix returns the sorted public keys of connected or named visors that start with prefix.
+func (hv *Hypervisor) matchVisorPrefix(prefix string) ([]cipher.PubKey, error) {
+	names, err := hv.names.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[cipher.PubKey]struct{}, len(names))
+	for pk := range names {
+		known[pk] = struct{}{}
+	}
+
+	hv.mu.RLock()
+	for pk := range hv.visors {
+		known[pk] = struct{}{}
+	}
+	hv.mu.RUnlock()
+
+	var matches []cipher.PubKey
+
+	for pk := range known {
+		if strings.HasPrefix(pk.Hex(), prefix) {
+			matches = append(matches, pk)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Hex() < matches[j].Hex()
+	})
+
+	return matches, nil
+}
@@ -0,0 +1,408 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltUpdateRingsBucketName = "update_rings"
+	updateRingsKey            = "rings"
+	updateRingsCheckInterval  = time.Minute
+)
+
+// Update ring phases.
+const (
+	RingsIdle     = "idle"     // Waiting for an update to be available to the first ring.
+	RingsUpdating = "updating" // Rolling out to the current ring.
+	RingsSoaking  = "soaking"  // Waiting for the soak time of the current ring to pass.
+	RingsHalted   = "halted"   // Stopped by a failure, until the rings are enabled again.
+)
+
+// Errors associated with update rings.
+var (
+	ErrNoRingTarget = errors.New("update ring should target visors, labels or both")
+	ErrRingSoak     = errors.New("update ring visors failed verification after soaking")
+)
+
+// UpdateRing is a group of visors updated together, such as canaries.
+// Visors matching several rings belong to the first of them.
+type UpdateRing struct {
+	Name      string            `json:"name"`
+	Visors    []string          `json:"visors,omitempty"` // Visor identifiers (see resolveVisor).
+	Labels    map[string]string `json:"labels,omitempty"` // Label selector of visors.
+	BatchSize int               `json:"batch_size"`       // Number of visors to update at once, defaults to 1.
+	Soak      visor.Duration    `json:"soak"`             // How long the ring has to stay healthy before the next ring is updated.
+}
+
+// UpdateRingsState is the progress of an update through the rings.
+type UpdateRingsState struct {
+	Phase     string     `json:"phase"`
+	Ring      int        `json:"ring"` // Index of the ring being updated or soaking.
+	RolloutID *uuid.UUID `json:"rollout_id,omitempty"`
+	SoakUntil *time.Time `json:"soak_until,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// UpdateRings updates visors automatically in stages: once an update is available to the first ring,
+// it is rolled out ring by ring, each one being promoted to the next after soaking successfully.
+type UpdateRings struct {
+	Enabled  bool             `json:"enabled"`
+	Rings    []UpdateRing     `json:"rings"`
+	Rollback bool             `json:"rollback"` // Roll back updated visors of a ring if its rollout fails.
+	Force    bool             `json:"force"`    // Update visors even if they have active pty or exec sessions.
+	Verify   VerifyConfig     `json:"verify"`   // Verification of updated visors, also applied after soaking.
+	State    UpdateRingsState `json:"state"`    // Ignored on updates.
+}
+
+func (u UpdateRings) check() error {
+	names := make(map[string]bool, len(u.Rings))
+
+	for _, ring := range u.Rings {
+		if ring.Name == "" {
+			return errors.New("update ring has no name")
+		}
+
+		if names[ring.Name] {
+			return fmt.Errorf("update ring %q is defined more than once", ring.Name)
+		}
+
+		names[ring.Name] = true
+
+		if len(ring.Visors) == 0 && len(ring.Labels) == 0 {
+			return fmt.Errorf("update ring %q: %w", ring.Name, ErrNoRingTarget)
+		}
+
+		if err := checkLabels(ring.Labels); err != nil {
+			return fmt.Errorf("update ring %q: %w", ring.Name, err)
+		}
+
+		if ring.BatchSize < 0 || ring.Soak < 0 {
+			return fmt.Errorf("update ring %q: batch size and soak should not be negative", ring.Name)
+		}
+	}
+
+	return nil
+}
+
+// UpdateRingStore stores the update rings and their state.
+type UpdateRingStore interface {
+	UpdateRings() (UpdateRings, error)
+	SetUpdateRings(u UpdateRings) error
+}
+
+// BoltUpdateRingStore implements UpdateRingStore, storing update rings in a bbolt database.
+type BoltUpdateRingStore struct {
+	*bbolt.DB
+}
+
+// NewBoltUpdateRingStore creates a new BoltUpdateRingStore on top of an opened bbolt database.
+func NewBoltUpdateRingStore(db *bbolt.DB) (*BoltUpdateRingStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltUpdateRingsBucketName))
+		return err
+	})
+
+	return &BoltUpdateRingStore{DB: db}, err
+}
+
+// UpdateRings returns the update rings, which are disabled and empty if none are stored.
+func (s *BoltUpdateRingStore) UpdateRings() (u UpdateRings, err error) {
+	u = UpdateRings{Rings: make([]UpdateRing, 0), State: UpdateRingsState{Phase: RingsIdle}}
+
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltUpdateRingsBucketName)).Get([]byte(updateRingsKey))
+		if raw == nil {
+			return nil
+		}
+
+		return json.Unmarshal(raw, &u)
+	})
+
+	return u, err
+}
+
+// SetUpdateRings replaces the update rings.
+func (s *BoltUpdateRingStore) SetUpdateRings(u UpdateRings) error {
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltUpdateRingsBucketName)).Put([]byte(updateRingsKey), raw)
+	})
+}
+
+// runUpdateRings advances the update rings every updateRingsCheckInterval.
+func (hv *Hypervisor) runUpdateRings() {
+	hv.every(updateRingsCheckInterval, hv.stepUpdateRings)
+}
+
+// stepUpdateRings advances the update rings by at most one phase.
+func (hv *Hypervisor) stepUpdateRings(now time.Time) {
+	hv.ringsMu.Lock()
+	defer hv.ringsMu.Unlock()
+
+	u, err := hv.rings.UpdateRings()
+	if err != nil {
+		log.WithError(err).Warn("Failed to obtain update rings.")
+		return
+	}
+
+	if !u.Enabled || len(u.Rings) == 0 {
+		return
+	}
+
+	prev := u.State
+
+	switch u.State.Phase {
+	case RingsIdle, "":
+		if hv.ringUpdateAvailable(u, 0) {
+			hv.startRing(&u, 0, now)
+		}
+
+	case RingsUpdating:
+		hv.checkRingRollout(&u, now)
+
+	case RingsSoaking:
+		if u.State.SoakUntil != nil && now.Before(*u.State.SoakUntil) {
+			return
+		}
+
+		if err := hv.verifyRing(u, u.State.Ring); err != nil {
+			hv.haltRings(&u, fmt.Errorf("%w: %v", ErrRingSoak, err), now)
+			break
+		}
+
+		if next := u.State.Ring + 1; next < len(u.Rings) {
+			hv.startRing(&u, next, now)
+		} else {
+			log.Info("Update rolled out to all update rings.")
+			u.State = UpdateRingsState{Phase: RingsIdle, UpdatedAt: now}
+		}
+
+	case RingsHalted:
+		// Enabling halted rings resumes them from the ring which failed.
+		hv.startRing(&u, u.State.Ring, now)
+	}
+
+	if u.State == prev {
+		return
+	}
+
+	if err := hv.rings.SetUpdateRings(u); err != nil {
+		log.WithError(err).Warn("Failed to save update rings.")
+	}
+}
+
+// ringVisors returns the connected visors of the ring at index i, excluding those of previous rings.
+func (hv *Hypervisor) ringVisors(u UpdateRings, i int) []cipher.PubKey {
+	taken := make(map[cipher.PubKey]bool)
+
+	for j := 0; j < i; j++ {
+		pks, _, err := hv.selectVisors(u.Rings[j].Visors, u.Rings[j].Labels)
+		if err != nil {
+			log.WithError(err).WithField("ring", u.Rings[j].Name).Warn("Failed to select update ring visors.")
+		}
+
+		for _, pk := range pks {
+			taken[pk] = true
+		}
+	}
+
+	pks, _, err := hv.selectVisors(u.Rings[i].Visors, u.Rings[i].Labels)
+	if err != nil {
+		log.WithError(err).WithField("ring", u.Rings[i].Name).Warn("Failed to select update ring visors.")
+	}
+
+	var visors []cipher.PubKey
+
+	for _, pk := range pks {
+		if _, ok := hv.visorConn(pk); ok && !taken[pk] {
+			visors = append(visors, pk)
+		}
+	}
+
+	return visors
+}
+
+// ringUpdateAvailable returns whether an update is available to any visor of the ring at index i.
+func (hv *Hypervisor) ringUpdateAvailable(u UpdateRings, i int) bool {
+	for _, pk := range hv.ringVisors(u, i) {
+		conn, ok := hv.visorConn(pk)
+		if !ok {
+			continue
+		}
+
+		if version, err := conn.RPC.UpdateAvailable(); err == nil && version != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startRing starts the rollout to the ring at index i.
+// Rings without connected visors are skipped, as if they soaked successfully.
+func (hv *Hypervisor) startRing(u *UpdateRings, i int, now time.Time) {
+	ring := u.Rings[i]
+
+	pks := hv.ringVisors(*u, i)
+	if len(pks) == 0 {
+		u.State = UpdateRingsState{Phase: RingsSoaking, Ring: i, SoakUntil: &now, UpdatedAt: now}
+		return
+	}
+
+	ro, err := hv.newRollout(RolloutRequest{
+		Visors:    pks,
+		BatchSize: ring.BatchSize,
+		Rollback:  u.Rollback,
+		Force:     u.Force,
+		Verify:    u.Verify,
+	})
+	if err != nil {
+		u.State.Ring = i
+		hv.haltRings(u, err, now)
+
+		return
+	}
+
+	hv.mu.Lock()
+	hv.rollouts[ro.r.ID] = ro
+	hv.mu.Unlock()
+
+	go ro.run()
+
+	log.WithField("ring", ring.Name).WithField("rollout_id", ro.r.ID).Info("Rolling out update to update ring.")
+
+	id := ro.r.ID
+	u.State = UpdateRingsState{Phase: RingsUpdating, Ring: i, RolloutID: &id, UpdatedAt: now}
+}
+
+// checkRingRollout moves on to soaking once the rollout of the current ring is done.
+// Rollouts lost to a hypervisor restart are started again.
+func (hv *Hypervisor) checkRingRollout(u *UpdateRings, now time.Time) {
+	var (
+		ro *rollout
+		ok bool
+	)
+
+	if u.State.RolloutID != nil {
+		hv.mu.RLock()
+		ro, ok = hv.rollouts[*u.State.RolloutID]
+		hv.mu.RUnlock()
+	}
+
+	if !ok {
+		hv.startRing(u, u.State.Ring, now)
+		return
+	}
+
+	switch r := ro.Rollout(); r.Status {
+	case RolloutRunning:
+	case RolloutDone:
+		soakUntil := now.Add(time.Duration(u.Rings[u.State.Ring].Soak))
+		u.State = UpdateRingsState{
+			Phase:     RingsSoaking,
+			Ring:      u.State.Ring,
+			RolloutID: u.State.RolloutID,
+			SoakUntil: &soakUntil,
+			UpdatedAt: now,
+		}
+	default:
+		hv.haltRings(u, errors.New(r.Error), now)
+	}
+}
+
+// verifyRing checks that the visors of the ring at index i still pass verification.
+func (hv *Hypervisor) verifyRing(u UpdateRings, i int) error {
+	for _, pk := range hv.ringVisors(u, i) {
+		if err := hv.checkVisor(pk, u.Verify, false, time.Time{}); err != nil {
+			return fmt.Errorf("%s: %v", pk, err)
+		}
+	}
+
+	return nil
+}
+
+// haltRings stops the update rings until they are enabled again.
+func (hv *Hypervisor) haltRings(u *UpdateRings, err error, now time.Time) {
+	log.WithError(err).WithField("ring", u.Rings[u.State.Ring].Name).Warn("Update rings halted.")
+
+	u.Enabled = false
+	u.State.Phase = RingsHalted
+	u.State.Error = err.Error()
+	u.State.UpdatedAt = now
+}
+
+func (hv *Hypervisor) getUpdateRings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := hv.rings.UpdateRings()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, u)
+	}
+}
+
+// putUpdateRings replaces the update rings, keeping their state if the current ring still exists.
+func (hv *Hypervisor) putUpdateRings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody UpdateRings
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("putUpdateRings request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := reqBody.check(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if reqBody.Rings == nil {
+			reqBody.Rings = make([]UpdateRing, 0)
+		}
+
+		hv.ringsMu.Lock()
+		defer hv.ringsMu.Unlock()
+
+		u, err := hv.rings.UpdateRings()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		reqBody.State = u.State
+		if reqBody.State.Ring >= len(reqBody.Rings) {
+			reqBody.State = UpdateRingsState{Phase: RingsIdle, UpdatedAt: time.Now()}
+		}
+
+		if err := hv.rings.SetUpdateRings(reqBody); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, reqBody)
+	}
+}
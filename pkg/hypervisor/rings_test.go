@@ -0,0 +1,121 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/util/updater"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// outdatedRPCClient always has an update available.
+type outdatedRPCClient struct {
+	visor.RPCClient
+}
+
+func (outdatedRPCClient) UpdateAvailable() (*updater.Version, error) {
+	return &updater.Version{Major: 1}, nil
+}
+
+func TestUpdateRings(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.Len(t, pks, 3)
+
+	hv.mu.Lock()
+	c := hv.visors[pks[0]]
+	c.RPC = outdatedRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[0]] = c
+	hv.mu.Unlock()
+
+	rings := `{"enabled":true,"rings":[` +
+		`{"name":"canary","visors":["` + pks[0].Hex() + `"],"soak":"1h"},` +
+		`{"name":"broad","visors":["` + pks[0].Hex() + `","` + pks[1].Hex() + `","` + pks[2].Hex() + `"],"batch_size":2}]}`
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/updates/rings",
+			ReqBody:    strings.NewReader(`{"rings":[{"name":"canary"}]}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     "/api/v1/updates/rings",
+			ReqBody:    strings.NewReader(rings),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/updates/rings",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var u UpdateRings
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&u))
+				assert.True(t, u.Enabled)
+				assert.Len(t, u.Rings, 2)
+				assert.Equal(t, RingsIdle, u.State.Phase)
+			},
+		},
+	})
+
+	state := func() UpdateRingsState {
+		u, err := hv.rings.UpdateRings()
+		require.NoError(t, err)
+
+		return u.State
+	}
+
+	// Waits for the rollout of the current ring to finish, and returns the visors it updated.
+	rolledOut := func() []cipher.PubKey {
+		hv.mu.RLock()
+		ro := hv.rollouts[*state().RolloutID]
+		hv.mu.RUnlock()
+
+		require.Eventually(t, func() bool { return ro.Rollout().Status == RolloutDone }, 5*time.Second, 10*time.Millisecond)
+
+		var updated []cipher.PubKey
+		for _, v := range ro.Rollout().Visors {
+			updated = append(updated, v.PK)
+		}
+
+		return updated
+	}
+
+	now := time.Now()
+
+	hv.stepUpdateRings(now)
+	require.Equal(t, RingsUpdating, state().Phase)
+	assert.Equal(t, []cipher.PubKey{pks[0]}, rolledOut())
+
+	hv.stepUpdateRings(now)
+	require.Equal(t, RingsSoaking, state().Phase)
+
+	// The next ring is only updated after soaking.
+	hv.stepUpdateRings(now.Add(30 * time.Minute))
+	require.Equal(t, RingsSoaking, state().Phase)
+
+	hv.stepUpdateRings(now.Add(2 * time.Hour))
+	require.Equal(t, RingsUpdating, state().Phase)
+	assert.Equal(t, 1, state().Ring)
+	assert.ElementsMatch(t, []cipher.PubKey{pks[1], pks[2]}, rolledOut())
+
+	hv.stepUpdateRings(now.Add(2 * time.Hour))
+	require.Equal(t, RingsSoaking, state().Phase)
+
+	hv.stepUpdateRings(now.Add(2 * time.Hour))
+	assert.Equal(t, RingsIdle, state().Phase)
+}
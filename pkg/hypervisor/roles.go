@@ -0,0 +1,62 @@
+package hypervisor
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/skycoin/dmsg/httputil"
+)
+
+// Roles of sessions.
+const (
+	RoleAdmin  = "admin"  // may use the whole API
+	RoleViewer = "viewer" // may only read, as in read-only mode
+)
+
+// Errors related to roles.
+var (
+	ErrViewerRole   = errors.New("session only has the viewer role")
+	ErrExternalUser = errors.New("user is managed by an external identity provider")
+
+	ErrOtherUsersSessions = errors.New("sessions of other users require the admin role")
+)
+
+func validRole(role string) bool {
+	return role == RoleAdmin || role == RoleViewer
+}
+
+// requestRole returns the role of the session of the request.
+// Sessions of local users, and requests without sessions (as user management is disabled), are admins.
+func requestRole(r *http.Request) string {
+	if session, ok := r.Context().Value(sessionKey).(Session); ok && session.Role != "" {
+		return session.Role
+	}
+
+	return RoleAdmin
+}
+
+// roleGuard is an http middleware which refuses requests that may modify state from sessions of the viewer role.
+func roleGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestRole(r) == RoleViewer && (!safeMethod(r.Method) || strings.EqualFold(r.Header.Get("Upgrade"), "websocket")) {
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrViewerRole)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localUserOnly is an http middleware which refuses requests from sessions of users of external identity providers,
+// such as changes of passwords which are not kept by the hypervisor.
+func localUserOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session, ok := r.Context().Value(sessionKey).(Session); ok && session.Provider != "" {
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrExternalUser)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,528 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	defaultVerifyTimeout = 5 * time.Minute
+	verifyPollInterval   = 5 * time.Second
+)
+
+// Rollout statuses.
+const (
+	RolloutRunning = "running"
+	RolloutDone    = "done"
+	RolloutFailed  = "failed"
+	RolloutAborted = "aborted"
+)
+
+// Errors associated with rollouts.
+var (
+	ErrRolloutNotFound = errors.New("rollout is not found")
+	ErrNoRolloutVisors = errors.New("no visors to roll out to")
+	ErrCanaryFailed    = errors.New("canary verification failed, rollout stopped")
+	ErrBatchFailed     = errors.New("batch verification failed, rollout stopped")
+	ErrRolloutAborted  = errors.New("rollout is aborted")
+	ErrRolloutFinished = errors.New("rollout is already finished")
+)
+
+// VerifyConfig configures how an updated visor is verified.
+// A visor is always required to be reachable over RPC after the update.
+type VerifyConfig struct {
+	Health  bool           `json:"health"`            // Require all health checks to report OK.
+	Apps    bool           `json:"apps"`              // Require all auto-started apps to be running.
+	Command string         `json:"command,omitempty"` // Command to execute on the visor, must not fail.
+	Timeout visor.Duration `json:"timeout,omitempty"` // How long to wait for verification to pass.
+}
+
+// RolloutRequest is the request body of a rollout.
+// Canaries are updated first, followed by the remaining visors in batches.
+// Each batch is updated concurrently and has to pass verification before the next batch is started.
+type RolloutRequest struct {
+	Visors    []cipher.PubKey `json:"visors,omitempty"` // Visors to update, defaults to all.
+	Canaries  int             `json:"canaries"`         // Number of visors to update and verify first.
+	BatchSize int             `json:"batch_size"`       // Number of visors to update at once, defaults to 1.
+	Rollback  bool            `json:"rollback"`         // Roll back updated visors if the rollout fails or is aborted.
+	Force     bool            `json:"force"`            // Update visors even if they have active pty or exec sessions.
+	Verify    VerifyConfig    `json:"verify"`
+}
+
+// RolloutVisor is the rollout progress of a single visor.
+type RolloutVisor struct {
+	PK         cipher.PubKey `json:"pk"`
+	Canary     bool          `json:"canary"`
+	Done       bool          `json:"done"`
+	Updated    bool          `json:"updated"`
+	Verified   bool          `json:"verified"`
+	RolledBack bool          `json:"rolled_back"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Rollout represents a fleet update rollout.
+type Rollout struct {
+	ID         uuid.UUID       `json:"id"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Request    RolloutRequest  `json:"request"`
+	Visors     []*RolloutVisor `json:"visors"`
+	Batch      int             `json:"batch"`   // Batch currently being rolled out, starting from 1.
+	Batches    int             `json:"batches"` // Total number of batches, including the canary batch.
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+type rollout struct {
+	hv      *Hypervisor
+	r       Rollout
+	aborted bool
+	mu      sync.RWMutex
+}
+
+func (hv *Hypervisor) newRollout(req RolloutRequest) (*rollout, error) {
+	pks := req.Visors
+	if len(pks) == 0 {
+		hv.mu.RLock()
+		for pk := range hv.visors {
+			pks = append(pks, pk)
+		}
+		hv.mu.RUnlock()
+
+		sort.Slice(pks, func(i, j int) bool { return pks[i].Hex() < pks[j].Hex() })
+	}
+
+	if len(pks) == 0 {
+		return nil, ErrNoRolloutVisors
+	}
+
+	for _, pk := range pks {
+		if _, ok := hv.visorConn(pk); !ok {
+			return nil, fmt.Errorf("visor of pk '%s' not found", pk)
+		}
+	}
+
+	if req.Canaries < 0 || req.Canaries > len(pks) {
+		return nil, fmt.Errorf("number of canaries should be between 0 and %d", len(pks))
+	}
+
+	if req.BatchSize < 0 {
+		return nil, errors.New("batch size should not be negative")
+	}
+
+	if req.BatchSize == 0 {
+		req.BatchSize = 1
+	}
+
+	if req.Verify.Timeout <= 0 {
+		req.Verify.Timeout = visor.Duration(defaultVerifyTimeout)
+	}
+
+	visors := make([]*RolloutVisor, len(pks))
+	for i, pk := range pks {
+		visors[i] = &RolloutVisor{PK: pk, Canary: i < req.Canaries}
+	}
+
+	ro := &rollout{
+		hv: hv,
+		r: Rollout{
+			ID:        uuid.New(),
+			Status:    RolloutRunning,
+			Request:   req,
+			Visors:    visors,
+			StartedAt: time.Now(),
+		},
+	}
+
+	ro.r.Batches = len(ro.batches())
+
+	return ro, nil
+}
+
+// Rollout returns a snapshot of the rollout state.
+func (ro *rollout) Rollout() Rollout {
+	ro.mu.RLock()
+	defer ro.mu.RUnlock()
+
+	out := ro.r
+	out.Visors = make([]*RolloutVisor, len(ro.r.Visors))
+
+	for i, v := range ro.r.Visors {
+		vCopy := *v
+		out.Visors[i] = &vCopy
+	}
+
+	return out
+}
+
+// batches splits the rollout visors into the canary batch, followed by batches of the requested size.
+func (ro *rollout) batches() [][]*RolloutVisor {
+	var batches [][]*RolloutVisor
+
+	visors := ro.r.Visors
+	if n := ro.r.Request.Canaries; n > 0 {
+		batches = append(batches, visors[:n])
+		visors = visors[n:]
+	}
+
+	for len(visors) > 0 {
+		n := ro.r.Request.BatchSize
+		if n > len(visors) {
+			n = len(visors)
+		}
+
+		batches = append(batches, visors[:n])
+		visors = visors[n:]
+	}
+
+	return batches
+}
+
+func (ro *rollout) run() {
+	for i, batch := range ro.batches() {
+		ro.mu.Lock()
+		aborted := ro.aborted
+		ro.r.Batch = i + 1
+		ro.mu.Unlock()
+
+		if aborted {
+			ro.rollback()
+			ro.finish(ErrRolloutAborted)
+
+			return
+		}
+
+		if err := ro.updateBatch(batch); err != nil {
+			if batch[0].Canary {
+				err = fmt.Errorf("%w: %v", ErrCanaryFailed, err)
+			} else {
+				err = fmt.Errorf("%w: %v", ErrBatchFailed, err)
+			}
+
+			ro.rollback()
+			ro.finish(err)
+
+			return
+		}
+	}
+
+	ro.finish(nil)
+}
+
+// Abort stops the rollout before the next batch is started.
+func (ro *rollout) Abort() error {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	if ro.r.Status != RolloutRunning {
+		return ErrRolloutFinished
+	}
+
+	ro.aborted = true
+
+	return nil
+}
+
+// updateBatch concurrently updates and verifies visors of the batch.
+func (ro *rollout) updateBatch(batch []*RolloutVisor) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []string
+	)
+
+	wg.Add(len(batch))
+
+	for _, v := range batch {
+		go func(v *RolloutVisor) {
+			defer wg.Done()
+
+			if err := ro.updateVisor(v); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %v", v.PK, err))
+				mu.Unlock()
+			}
+		}(v)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d visor(s) failed: %s", len(errs), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// rollback rolls back visors updated by the rollout, if requested.
+func (ro *rollout) rollback() {
+	if !ro.r.Request.Rollback {
+		return
+	}
+
+	for _, v := range ro.r.Visors {
+		ro.mu.RLock()
+		updated := v.Updated
+		ro.mu.RUnlock()
+
+		if !updated {
+			continue
+		}
+
+		err := errors.New("visor is not found")
+		if conn, ok := ro.hv.visorConn(v.PK); ok {
+			err = conn.RPC.Rollback()
+		}
+
+		ro.mu.Lock()
+		if err != nil {
+			v.Error = fmt.Sprintf("rollback: %v", err)
+		} else {
+			v.RolledBack = true
+		}
+		ro.mu.Unlock()
+
+		log.WithField("rollout_id", ro.r.ID).
+			WithField("visor_pk", v.PK).
+			WithError(err).
+			Info("Rolled back visor.")
+	}
+}
+
+func (ro *rollout) finish(err error) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	now := time.Now()
+	ro.r.FinishedAt = &now
+	ro.r.Status = RolloutDone
+
+	switch {
+	case errors.Is(err, ErrRolloutAborted):
+		ro.r.Status = RolloutAborted
+		ro.r.Error = err.Error()
+	case err != nil:
+		ro.r.Status = RolloutFailed
+		ro.r.Error = err.Error()
+	}
+
+	log.WithField("rollout_id", ro.r.ID).
+		WithField("status", ro.r.Status).
+		WithError(err).
+		Info("Rollout finished.")
+}
+
+func (ro *rollout) updateVisor(v *RolloutVisor) (err error) {
+	defer func() {
+		ro.mu.Lock()
+		v.Done = true
+		if err != nil {
+			v.Error = err.Error()
+		}
+		ro.mu.Unlock()
+	}()
+
+	conn, ok := ro.hv.visorConn(v.PK)
+	if !ok {
+		return fmt.Errorf("visor of pk '%s' not found", v.PK)
+	}
+
+	if sessions := ro.hv.activity.active(v.PK); len(sessions) > 0 && !ro.r.Request.Force {
+		return fmt.Errorf("%w: %d session(s), first held by %s", ErrActiveSessions, len(sessions), sessions[0].Holder())
+	}
+
+	startedAt := time.Now()
+
+	updated, err := conn.RPC.Update(0)
+	if err != nil {
+		ro.hv.notifier.Notify(EventUpdateFailed, v.PK, err.Error())
+		return fmt.Errorf("update: %w", err)
+	}
+
+	ro.mu.Lock()
+	v.Updated = updated
+	ro.mu.Unlock()
+
+	if err := ro.hv.verifyVisor(v.PK, ro.r.Request.Verify, updated, startedAt); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	ro.mu.Lock()
+	v.Verified = true
+	ro.mu.Unlock()
+
+	return nil
+}
+
+// verifyVisor waits until the visor of pk passes verification, or the verification timeout is reached.
+// If restarted is set, the visor is also required to have been restarted after 'since'.
+func (hv *Hypervisor) verifyVisor(pk cipher.PubKey, conf VerifyConfig, restarted bool, since time.Time) error {
+	deadline := time.Now().Add(time.Duration(conf.Timeout))
+
+	for {
+		err := hv.checkVisor(pk, conf, restarted, since)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(verifyPollInterval).After(deadline) {
+			return err
+		}
+
+		time.Sleep(verifyPollInterval)
+	}
+}
+
+func (hv *Hypervisor) checkVisor(pk cipher.PubKey, conf VerifyConfig, restarted bool, since time.Time) error {
+	conn, ok := hv.visorConn(pk)
+	if !ok {
+		return fmt.Errorf("visor of pk '%s' not found", pk)
+	}
+
+	uptime, err := conn.RPC.Uptime()
+	if err != nil {
+		return fmt.Errorf("visor is unreachable: %w", err)
+	}
+
+	if restarted && time.Duration(uptime*float64(time.Second)) > time.Since(since) {
+		return errors.New("visor is not yet restarted")
+	}
+
+	if conf.Health {
+		h, err := conn.RPC.Health()
+		if err != nil {
+			return fmt.Errorf("health: %w", err)
+		}
+
+		if h.TransportDiscovery != http.StatusOK || h.RouteFinder != http.StatusOK || h.SetupNode != http.StatusOK {
+			return fmt.Errorf("health check failed: %+v", *h)
+		}
+	}
+
+	if conf.Apps {
+		apps, err := conn.RPC.Apps()
+		if err != nil {
+			return fmt.Errorf("apps: %w", err)
+		}
+
+		for _, a := range apps {
+			if a.AutoStart && a.Status != visor.AppStatusRunning {
+				return fmt.Errorf("app %s is not running", a.Name)
+			}
+		}
+	}
+
+	if conf.Command != "" {
+		if out, err := conn.RPC.Exec(conf.Command, 0); err != nil {
+			return fmt.Errorf("command %q failed: %v: %s", conf.Command, err, out)
+		}
+	}
+
+	return nil
+}
+
+func (hv *Hypervisor) postRollout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody RolloutRequest
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postRollout request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		ro, err := hv.newRollout(reqBody)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		hv.mu.Lock()
+		hv.rollouts[ro.r.ID] = ro
+		hv.mu.Unlock()
+
+		go ro.run()
+
+		httputil.WriteJSON(w, r, http.StatusOK, ro.Rollout())
+	}
+}
+
+func (hv *Hypervisor) getRollouts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hv.mu.RLock()
+		rollouts := make([]Rollout, 0, len(hv.rollouts))
+		for _, ro := range hv.rollouts {
+			rollouts = append(rollouts, ro.Rollout())
+		}
+		hv.mu.RUnlock()
+
+		sort.Slice(rollouts, func(i, j int) bool {
+			return rollouts[i].StartedAt.Before(rollouts[j].StartedAt)
+		})
+
+		httputil.WriteJSON(w, r, http.StatusOK, rollouts)
+	}
+}
+
+func (hv *Hypervisor) getRollout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		hv.mu.RLock()
+		ro, ok := hv.rollouts[id]
+		hv.mu.RUnlock()
+
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrRolloutNotFound)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, ro.Rollout())
+	}
+}
+
+func (hv *Hypervisor) abortRollout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuidFromParam(r, "id")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		hv.mu.RLock()
+		ro, ok := hv.rollouts[id]
+		hv.mu.RUnlock()
+
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrRolloutNotFound)
+			return
+		}
+
+		if err := ro.Abort(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusConflict, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, ro.Rollout())
+	}
+}
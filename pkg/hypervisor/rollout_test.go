@@ -0,0 +1,208 @@
+package hypervisor
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+type unhealthyRPCClient struct {
+	visor.RPCClient
+}
+
+func (unhealthyRPCClient) Health() (*visor.HealthInfo, error) {
+	return nil, errors.New("unhealthy")
+}
+
+func makeMockHypervisor(t *testing.T, n int) (*Hypervisor, []cipher.PubKey) {
+	hv := &Hypervisor{
+		visors:   make(map[cipher.PubKey]VisorConn),
+		rollouts: make(map[uuid.UUID]*rollout),
+		notifier: newNotifier(NotificationsConfig{}),
+		activity: newActivity(),
+		routes:   newRouteCache(rfclient.NewMock(), 0),
+		mu:       new(sync.RWMutex),
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	pks := make([]cipher.PubKey, n)
+
+	for i := range pks {
+		pk, client, err := visor.NewMockRPCClient(r, 1, 1)
+		require.NoError(t, err)
+
+		hv.visors[pk] = VisorConn{Addr: dmsg.Addr{PK: pk}, RPC: client}
+		pks[i] = pk
+	}
+
+	return hv, pks
+}
+
+func TestRollout(t *testing.T) {
+	verify := VerifyConfig{Health: true, Apps: true, Timeout: visor.Duration(time.Millisecond)}
+
+	t.Run("canaries_pass", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 3)
+
+		ro, err := hv.newRollout(RolloutRequest{Visors: pks, Canaries: 1, Verify: verify})
+		require.NoError(t, err)
+
+		ro.run()
+
+		state := ro.Rollout()
+		assert.Equal(t, RolloutDone, state.Status)
+		for _, v := range state.Visors {
+			assert.True(t, v.Done)
+			assert.True(t, v.Verified)
+		}
+	})
+
+	t.Run("canary_fails", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 3)
+
+		c := hv.visors[pks[0]]
+		c.RPC = unhealthyRPCClient{RPCClient: c.RPC}
+		hv.visors[pks[0]] = c
+
+		ro, err := hv.newRollout(RolloutRequest{Visors: pks, Canaries: 1, Verify: verify})
+		require.NoError(t, err)
+
+		ro.run()
+
+		state := ro.Rollout()
+		assert.Equal(t, RolloutFailed, state.Status)
+		assert.True(t, state.Visors[0].Done)
+		assert.False(t, state.Visors[0].Verified)
+
+		for _, v := range state.Visors[1:] {
+			assert.False(t, v.Done)
+		}
+	})
+
+	t.Run("active_sessions", func(t *testing.T) {
+		for _, force := range []bool{false, true} {
+			hv, pks := makeMockHypervisor(t, 1)
+
+			end := hv.activity.begin(pks[0], ActivityExec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+			ro, err := hv.newRollout(RolloutRequest{Visors: pks, Verify: verify, Force: force})
+			require.NoError(t, err)
+
+			ro.run()
+			end()
+
+			state := ro.Rollout()
+			if force {
+				assert.Equal(t, RolloutDone, state.Status)
+				continue
+			}
+
+			assert.Equal(t, RolloutFailed, state.Status)
+			assert.Contains(t, state.Visors[0].Error, ErrActiveSessions.Error())
+		}
+	})
+
+	t.Run("too_many_canaries", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 2)
+
+		_, err := hv.newRollout(RolloutRequest{Visors: pks, Canaries: 3})
+		assert.Error(t, err)
+	})
+}
+
+type rollbackRPCClient struct {
+	visor.RPCClient
+	rolledBack *int32
+}
+
+func (rollbackRPCClient) Update(time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (rollbackRPCClient) Uptime() (float64, error) {
+	return 0, nil
+}
+
+func (c rollbackRPCClient) Rollback() error {
+	atomic.AddInt32(c.rolledBack, 1)
+	return nil
+}
+
+func TestRollout_Batches(t *testing.T) {
+	verify := VerifyConfig{Health: true, Timeout: visor.Duration(time.Millisecond)}
+
+	t.Run("batch_sizes", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 6)
+
+		ro, err := hv.newRollout(RolloutRequest{Visors: pks, Canaries: 1, BatchSize: 2, Verify: verify})
+		require.NoError(t, err)
+
+		batches := ro.batches()
+		require.Len(t, batches, 4)
+		assert.Equal(t, 4, ro.Rollout().Batches)
+		assert.Len(t, batches[0], 1)
+		assert.Len(t, batches[1], 2)
+		assert.Len(t, batches[3], 1)
+
+		ro.run()
+		assert.Equal(t, RolloutDone, ro.Rollout().Status)
+	})
+
+	t.Run("failed_batch_rolls_back", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 4)
+
+		var rolledBack int32
+		for _, pk := range pks {
+			c := hv.visors[pk]
+			c.RPC = rollbackRPCClient{RPCClient: c.RPC, rolledBack: &rolledBack}
+			hv.visors[pk] = c
+		}
+
+		c := hv.visors[pks[3]]
+		c.RPC = unhealthyRPCClient{RPCClient: c.RPC}
+		hv.visors[pks[3]] = c
+
+		ro, err := hv.newRollout(RolloutRequest{Visors: pks, BatchSize: 2, Rollback: true, Verify: verify})
+		require.NoError(t, err)
+
+		ro.run()
+
+		state := ro.Rollout()
+		assert.Equal(t, RolloutFailed, state.Status)
+		assert.Equal(t, 2, state.Batch)
+		assert.EqualValues(t, 4, atomic.LoadInt32(&rolledBack))
+
+		for _, v := range state.Visors {
+			assert.True(t, v.RolledBack)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		hv, pks := makeMockHypervisor(t, 2)
+
+		ro, err := hv.newRollout(RolloutRequest{Visors: pks, Verify: verify})
+		require.NoError(t, err)
+
+		require.NoError(t, ro.Abort())
+		ro.run()
+
+		state := ro.Rollout()
+		assert.Equal(t, RolloutAborted, state.Status)
+		assert.False(t, state.Visors[0].Done)
+		assert.Equal(t, ErrRolloutFinished, ro.Abort())
+	})
+}
@@ -0,0 +1,200 @@
+package hypervisor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+const (
+	defaultRouteCacheTTL = 10 * time.Second
+	defaultMinHops       = 1
+	defaultMaxHops       = 5
+)
+
+// RouteFinderConfig configures access to the route finder.
+type RouteFinderConfig struct {
+	Addr     string        `json:"addr"`                // Route finder address.
+	Timeout  time.Duration `json:"timeout,omitempty"`   // Timeout of route finder requests.
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"` // How long responses are cached for, defaults to 10s.
+}
+
+type routeCacheKey struct {
+	edges   routing.PathEdges
+	minHops uint16
+	maxHops uint16
+}
+
+type routeCacheEntry struct {
+	paths   []routing.Path
+	expires time.Time
+}
+
+// routeCache implements rfclient.Client, caching responses of the underlying route finder client.
+// Entries expire after the TTL, and are invalidated earlier when the topology around a visor changes.
+type routeCache struct {
+	rfc     rfclient.Client
+	ttl     time.Duration
+	entries map[routeCacheKey]routeCacheEntry
+	hits    uint64 // edges served from the cache
+	misses  uint64 // edges requested from the route finder
+	mu      sync.Mutex
+}
+
+func newRouteCache(rfc rfclient.Client, ttl time.Duration) *routeCache {
+	if ttl <= 0 {
+		ttl = defaultRouteCacheTTL
+	}
+
+	return &routeCache{
+		rfc:     rfc,
+		ttl:     ttl,
+		entries: make(map[routeCacheKey]routeCacheEntry),
+	}
+}
+
+// FindRoutes implements rfclient.Client. Only edges without a valid cache entry are requested from the route finder.
+func (c *routeCache) FindRoutes(ctx context.Context, rts []routing.PathEdges, opts *rfclient.RouteOptions) (map[routing.PathEdges][]routing.Path, error) {
+	var o rfclient.RouteOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	out := make(map[routing.PathEdges][]routing.Path, len(rts))
+	var missing []routing.PathEdges
+
+	now := time.Now()
+
+	c.mu.Lock()
+	for _, edges := range rts {
+		e, ok := c.entries[routeCacheKey{edges: edges, minHops: o.MinHops, maxHops: o.MaxHops}]
+		if ok && now.Before(e.expires) {
+			out[edges] = e.paths
+			c.hits++
+			continue
+		}
+
+		missing = append(missing, edges)
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	paths, err := c.rfc.FindRoutes(ctx, missing, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for edges, p := range paths {
+		c.entries[routeCacheKey{edges: edges, minHops: o.MinHops, maxHops: o.MaxHops}] = routeCacheEntry{paths: p, expires: expires}
+		out[edges] = p
+	}
+
+	return out, nil
+}
+
+// invalidate removes cached routes which start, end or pass through the visor of pk.
+// A null pk removes all cached routes.
+func (c *routeCache) invalidate(pk cipher.PubKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pk.Null() {
+		c.entries = make(map[routeCacheKey]routeCacheEntry)
+		return
+	}
+
+	for key, e := range c.entries {
+		if key.edges[0] == pk || key.edges[1] == pk || pathsInclude(e.paths, pk) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func pathsInclude(paths []routing.Path, pk cipher.PubKey) bool {
+	for _, p := range paths {
+		for _, hop := range p {
+			if hop.From == pk || hop.To == pk {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// routeFinderResp is the response of getRouteFinderRoutes.
+type routeFinderResp struct {
+	Forward []routing.Path `json:"forward"`
+	Reverse []routing.Path `json:"reverse"`
+}
+
+// getRouteFinderRoutes looks up forward and reverse routes between two visors.
+// Query values: src, dst (required), min_hops and max_hops (optional).
+func (hv *Hypervisor) getRouteFinderRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var src, dst cipher.PubKey
+		if err := src.Set(q.Get("src")); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		if err := dst.Set(q.Get("dst")); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		opts := &rfclient.RouteOptions{MinHops: defaultMinHops, MaxHops: defaultMaxHops}
+		for name, v := range map[string]*uint16{"min_hops": &opts.MinHops, "max_hops": &opts.MaxHops} {
+			if s := q.Get(name); s != "" {
+				n, err := strconv.ParseUint(s, 10, 16)
+				if err != nil {
+					httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+					return
+				}
+
+				*v = uint16(n)
+			}
+		}
+
+		forward := routing.PathEdges{src, dst}
+		reverse := routing.PathEdges{dst, src}
+
+		paths, err := hv.routes.FindRoutes(r.Context(), []routing.PathEdges{forward, reverse}, opts)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadGateway, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, routeFinderResp{
+			Forward: paths[forward],
+			Reverse: paths[reverse],
+		})
+	}
+}
+
+// deleteRouteFinderCache drops all cached route finder responses.
+func (hv *Hypervisor) deleteRouteFinderCache() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hv.routes.invalidate(cipher.PubKey{})
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,84 @@
+package hypervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+// countingRouteFinder returns a direct route for every requested edge, and counts requested edges.
+type countingRouteFinder struct {
+	requested int
+}
+
+func (rf *countingRouteFinder) FindRoutes(_ context.Context, rts []routing.PathEdges, _ *rfclient.RouteOptions) (map[routing.PathEdges][]routing.Path, error) {
+	out := make(map[routing.PathEdges][]routing.Path, len(rts))
+
+	for _, edges := range rts {
+		rf.requested++
+		out[edges] = []routing.Path{{{
+			TpID: transport.MakeTransportID(edges[0], edges[1], ""),
+			From: edges[0],
+			To:   edges[1],
+		}}}
+	}
+
+	return out, nil
+}
+
+func TestRouteCache(t *testing.T) {
+	rf := new(countingRouteFinder)
+	c := newRouteCache(rf, time.Hour)
+
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+	pk3, _ := cipher.GenerateKeyPair()
+
+	fwd := routing.PathEdges{pk1, pk2}
+	rev := routing.PathEdges{pk2, pk1}
+	other := routing.PathEdges{pk3, pk1}
+	opts := &rfclient.RouteOptions{MinHops: 1, MaxHops: 5}
+
+	find := func(rts ...routing.PathEdges) {
+		paths, err := c.FindRoutes(context.Background(), rts, opts)
+		require.NoError(t, err)
+		require.Len(t, paths, len(rts))
+	}
+
+	find(fwd, rev)
+	assert.Equal(t, 2, rf.requested)
+
+	find(fwd, rev)
+	assert.Equal(t, 2, rf.requested)
+
+	// Different options are cached separately.
+	_, err := c.FindRoutes(context.Background(), []routing.PathEdges{fwd}, &rfclient.RouteOptions{MinHops: 2, MaxHops: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 3, rf.requested)
+
+	// Only the uncached edge is requested.
+	find(fwd, other)
+	assert.Equal(t, 4, rf.requested)
+
+	c.invalidate(pk2)
+	find(fwd, rev, other)
+	assert.Equal(t, 6, rf.requested)
+
+	c.invalidate(cipher.PubKey{})
+	find(other)
+	assert.Equal(t, 7, rf.requested)
+
+	c.ttl = time.Nanosecond
+	find(fwd)
+	time.Sleep(time.Millisecond)
+	find(fwd)
+	assert.Equal(t, 9, rf.requested)
+}
@@ -0,0 +1,663 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/util/cron"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	boltScheduleBucketName    = "schedules"
+	boltScheduleRunBucketName = "schedule_runs"
+	maxScheduleRuns           = 50 // Run history kept per schedule.
+)
+
+// Schedule actions.
+const (
+	ScheduleRestart    = "restart"     // Restart the visor.
+	ScheduleUpdate     = "update"      // Update the visor.
+	ScheduleRestartApp = "restart_app" // Restart (or start) an app.
+	ScheduleExec       = "exec"        // Execute a command.
+	ScheduleHealth     = "health"      // Collect health info.
+)
+
+// Schedule run triggers.
+const (
+	ScheduleTriggerCron   = "cron"
+	ScheduleTriggerManual = "manual"
+)
+
+// Errors associated with schedules.
+var (
+	ErrScheduleNotFound    = errors.New("schedule is not found")
+	ErrNoScheduleTarget    = errors.New("schedule should target visors, labels or both")
+	ErrBadScheduleAction   = errors.New("schedule action should be one of: restart, update, restart_app, exec, health")
+	ErrScheduleRunning     = errors.New("schedule is already running")
+	ErrVisorNotConnected   = errors.New("visor is not connected")
+	ErrScheduleAppRequired = errors.New("restart_app action requires an app")
+	ErrScheduleCmdRequired = errors.New("exec action requires a command")
+)
+
+// ScheduleAction is the action a schedule runs against each of its visors.
+type ScheduleAction struct {
+	Type    string `json:"type"`
+	App     string `json:"app,omitempty"`     // App of the restart_app action.
+	Command string `json:"command,omitempty"` // Command of the exec action.
+}
+
+// Schedule is a recurring action run against visors.
+// The visors are given by identifiers (see resolveVisor) and/or a label selector, and are resolved on every run.
+type Schedule struct {
+	ID        uuid.UUID         `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Cron      string            `json:"cron"`                // Cron expression, such as "0 3 * * *" or "@weekly".
+	TimeZone  string            `json:"time_zone,omitempty"` // IANA time zone of the cron expression, defaults to UTC.
+	Visors    []string          `json:"visors,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Action    ScheduleAction    `json:"action"`
+	Force     bool              `json:"force"` // Restart or update visors even if they have active pty or exec sessions.
+	Disabled  bool              `json:"disabled"`
+	CreatedAt time.Time         `json:"created_at"`
+	NextRun   *time.Time        `json:"next_run,omitempty"` // Not stored.
+}
+
+func (s Schedule) expr() (*cron.Expr, *time.Location, error) {
+	e, err := cron.Parse(s.Cron)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return e, loc, nil
+}
+
+func (s Schedule) check() error {
+	if _, _, err := s.expr(); err != nil {
+		return err
+	}
+
+	if len(s.Visors) == 0 && len(s.Labels) == 0 {
+		return ErrNoScheduleTarget
+	}
+
+	if err := checkLabels(s.Labels); err != nil {
+		return err
+	}
+
+	switch s.Action.Type {
+	case ScheduleRestart, ScheduleUpdate, ScheduleHealth:
+	case ScheduleRestartApp:
+		if s.Action.App == "" {
+			return ErrScheduleAppRequired
+		}
+	case ScheduleExec:
+		if s.Action.Command == "" {
+			return ErrScheduleCmdRequired
+		}
+	default:
+		return ErrBadScheduleAction
+	}
+
+	return nil
+}
+
+// withNextRun returns a copy of the schedule with the next run time set, if it is enabled.
+func (s Schedule) withNextRun(now time.Time) Schedule {
+	s.NextRun = nil
+
+	if s.Disabled {
+		return s
+	}
+
+	if e, loc, err := s.expr(); err == nil {
+		if next, err := e.Next(now.In(loc)); err == nil {
+			s.NextRun = &next
+		}
+	}
+
+	return s
+}
+
+// ScheduleRun is the result of a single run of a schedule.
+type ScheduleRun struct {
+	ScheduleID uuid.UUID          `json:"schedule_id"`
+	Trigger    string             `json:"trigger"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Error      string             `json:"error,omitempty"` // Set if visors could not be selected.
+	Visors     []ScheduleRunVisor `json:"visors"`
+}
+
+// ScheduleRunVisor is the result of a schedule action on a single visor.
+type ScheduleRunVisor struct {
+	PK     cipher.PubKey `json:"pk"`
+	Output string        `json:"output,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ScheduleStore stores schedules and their run history.
+type ScheduleStore interface {
+	Schedules() ([]Schedule, error)
+	Schedule(id uuid.UUID) (*Schedule, error)
+	SetSchedule(s Schedule) error
+	DeleteSchedule(id uuid.UUID) error
+	AddScheduleRun(run ScheduleRun) error
+	ScheduleRuns(id uuid.UUID) ([]ScheduleRun, error)
+}
+
+// BoltScheduleStore implements ScheduleStore, storing schedules in a bbolt database.
+type BoltScheduleStore struct {
+	*bbolt.DB
+}
+
+// NewBoltScheduleStore creates a new BoltScheduleStore on top of an opened bbolt database.
+func NewBoltScheduleStore(db *bbolt.DB) (*BoltScheduleStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(boltScheduleBucketName)); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists([]byte(boltScheduleRunBucketName))
+		return err
+	})
+
+	return &BoltScheduleStore{DB: db}, err
+}
+
+// Schedules returns all schedules, ordered by creation time.
+func (s *BoltScheduleStore) Schedules() ([]Schedule, error) {
+	schedules := make([]Schedule, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltScheduleBucketName)).ForEach(func(_, v []byte) error {
+			var sched Schedule
+			if err := json.Unmarshal(v, &sched); err != nil {
+				return err
+			}
+
+			schedules = append(schedules, sched)
+
+			return nil
+		})
+	})
+
+	sortSchedules(schedules)
+
+	return schedules, err
+}
+
+// Schedule returns the schedule of id. Returns nil if there is none.
+func (s *BoltScheduleStore) Schedule(id uuid.UUID) (sched *Schedule, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltScheduleBucketName)).Get(id[:])
+		if raw == nil {
+			return nil
+		}
+
+		sched = new(Schedule)
+
+		return json.Unmarshal(raw, sched)
+	})
+
+	return sched, err
+}
+
+// SetSchedule adds or replaces the schedule.
+func (s *BoltScheduleStore) SetSchedule(sched Schedule) error {
+	sched.NextRun = nil
+
+	raw, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltScheduleBucketName)).Put(sched.ID[:], raw)
+	})
+}
+
+// DeleteSchedule removes the schedule of id, together with its run history.
+func (s *BoltScheduleStore) DeleteSchedule(id uuid.UUID) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(boltScheduleBucketName)).Delete(id[:]); err != nil {
+			return err
+		}
+
+		c := tx.Bucket([]byte(boltScheduleRunBucketName)).Cursor()
+		for k, _ := c.Seek(id[:]); k != nil && bytes.HasPrefix(k, id[:]); k, _ = c.Seek(id[:]) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddScheduleRun records the run, removing the oldest runs of the schedule beyond maxScheduleRuns.
+func (s *BoltScheduleStore) AddScheduleRun(run ScheduleRun) error {
+	raw, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	id := run.ScheduleID
+	key := make([]byte, len(id)+8)
+	copy(key, id[:])
+	binary.BigEndian.PutUint64(key[len(id):], uint64(run.StartedAt.UnixNano()))
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltScheduleRunBucketName))
+		if err := b.Put(key, raw); err != nil {
+			return err
+		}
+
+		var keys [][]byte
+
+		c := b.Cursor()
+		for k, _ := c.Seek(id[:]); k != nil && bytes.HasPrefix(k, id[:]); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		for i := 0; i < len(keys)-maxScheduleRuns; i++ {
+			if err := b.Delete(keys[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ScheduleRuns returns the recorded runs of the schedule of id, newest first.
+func (s *BoltScheduleStore) ScheduleRuns(id uuid.UUID) ([]ScheduleRun, error) {
+	runs := make([]ScheduleRun, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(boltScheduleRunBucketName)).Cursor()
+
+		for k, v := c.Seek(id[:]); k != nil && bytes.HasPrefix(k, id[:]); k, v = c.Next() {
+			var run ScheduleRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+
+			runs = append(runs, run)
+		}
+
+		return nil
+	})
+
+	sortScheduleRuns(runs)
+
+	return runs, err
+}
+
+func sortSchedules(schedules []Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+}
+
+func sortScheduleRuns(runs []ScheduleRun) {
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+}
+
+// runSchedules runs the due enabled schedules at the start of every minute.
+// Runs missed while the hypervisor was down are not caught up on.
+func (hv *Hypervisor) runSchedules() {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		t := time.NewTimer(next.Sub(now))
+
+		select {
+		case <-hv.done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		hv.runDueSchedules(next)
+	}
+}
+
+// runDueSchedules starts the runs of enabled schedules matching the minute of t.
+func (hv *Hypervisor) runDueSchedules(t time.Time) {
+	schedules, err := hv.schedules.Schedules()
+	if err != nil {
+		log.WithError(err).Warn("Failed to obtain schedules.")
+		return
+	}
+
+	for _, s := range schedules {
+		if s.Disabled {
+			continue
+		}
+
+		e, loc, err := s.expr()
+		if err != nil {
+			log.WithError(err).WithField("schedule_id", s.ID).Warn("Bad schedule.")
+			continue
+		}
+
+		if !e.Matches(t.In(loc)) {
+			continue
+		}
+
+		go func(s Schedule) {
+			if _, err := hv.runSchedule(s, ScheduleTriggerCron); err != nil {
+				log.WithError(err).WithField("schedule_id", s.ID).Warn("Failed to run schedule.")
+			}
+		}(s)
+	}
+}
+
+// runSchedule runs the action of the schedule concurrently against its visors, and records the run.
+// A schedule may not run more than once at a time.
+func (hv *Hypervisor) runSchedule(s Schedule, trigger string) (ScheduleRun, error) {
+	hv.mu.Lock()
+	if _, ok := hv.runningSchedules[s.ID]; ok {
+		hv.mu.Unlock()
+		return ScheduleRun{}, ErrScheduleRunning
+	}
+	hv.runningSchedules[s.ID] = struct{}{}
+	hv.mu.Unlock()
+
+	defer func() {
+		hv.mu.Lock()
+		delete(hv.runningSchedules, s.ID)
+		hv.mu.Unlock()
+	}()
+
+	run := ScheduleRun{
+		ScheduleID: s.ID,
+		Trigger:    trigger,
+		StartedAt:  time.Now().UTC(),
+	}
+
+	pks, _, err := hv.selectVisors(s.Visors, s.Labels)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	run.Visors = make([]ScheduleRunVisor, len(pks))
+
+	var wg sync.WaitGroup
+	wg.Add(len(pks))
+
+	for i, pk := range pks {
+		go func(v *ScheduleRunVisor, pk cipher.PubKey) {
+			defer wg.Done()
+
+			v.PK = pk
+
+			out, err := hv.runScheduleAction(pk, s)
+			v.Output = out
+			if err != nil {
+				v.Error = err.Error()
+			}
+		}(&run.Visors[i], pk)
+	}
+
+	wg.Wait()
+
+	run.FinishedAt = time.Now().UTC()
+
+	log.WithField("schedule_id", s.ID).
+		WithField("action", s.Action.Type).
+		WithField("visors", len(pks)).
+		Info("Schedule run finished.")
+
+	return run, hv.schedules.AddScheduleRun(run)
+}
+
+func (hv *Hypervisor) runScheduleAction(pk cipher.PubKey, s Schedule) (string, error) {
+	conn, ok := hv.visorConn(pk)
+	if !ok {
+		return "", ErrVisorNotConnected
+	}
+
+	switch s.Action.Type {
+	case ScheduleRestart, ScheduleUpdate:
+		if sessions := hv.activity.active(pk); len(sessions) > 0 && !s.Force {
+			return "", fmt.Errorf("%w: %d session(s), first held by %s", ErrActiveSessions, len(sessions), sessions[0].Holder())
+		}
+	}
+
+	switch s.Action.Type {
+	case ScheduleRestart:
+		return "", conn.RPC.Restart(0)
+
+	case ScheduleUpdate:
+		updated, err := conn.RPC.Update(0)
+		if err != nil {
+			hv.notifier.Notify(EventUpdateFailed, pk, err.Error())
+			return "", err
+		}
+
+		if !updated {
+			return "already up to date", nil
+		}
+
+		return "updated", nil
+
+	case ScheduleRestartApp:
+		apps, err := conn.RPC.Apps()
+		if err != nil {
+			return "", err
+		}
+
+		for _, a := range apps {
+			if a.Name == s.Action.App && a.Status == visor.AppStatusRunning {
+				if err := conn.RPC.StopApp(a.Name); err != nil {
+					return "", fmt.Errorf("stop: %w", err)
+				}
+			}
+		}
+
+		return "", conn.RPC.StartApp(s.Action.App)
+
+	case ScheduleExec:
+		out, err := conn.RPC.Exec(s.Action.Command, 0)
+		return string(out), err
+
+	case ScheduleHealth:
+		h, err := conn.RPC.Health()
+		if err != nil {
+			return "", err
+		}
+
+		raw, err := json.Marshal(h)
+
+		return string(raw), err
+
+	default:
+		return "", ErrBadScheduleAction
+	}
+}
+
+func (hv *Hypervisor) getSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedules, err := hv.schedules.Schedules()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		now := time.Now()
+		for i, s := range schedules {
+			schedules[i] = s.withNextRun(now)
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, schedules)
+	}
+}
+
+// schedule obtains the schedule of the 'id' URL parameter, writing an error response if it fails.
+func (hv *Hypervisor) schedule(w http.ResponseWriter, r *http.Request) (*Schedule, bool) {
+	id, err := uuidFromParam(r, "id")
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	s, err := hv.schedules.Schedule(id)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	if s == nil {
+		httputil.WriteJSON(w, r, http.StatusNotFound, ErrScheduleNotFound)
+		return nil, false
+	}
+
+	return s, true
+}
+
+func (hv *Hypervisor) getSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s, ok := hv.schedule(w, r); ok {
+			httputil.WriteJSON(w, r, http.StatusOK, s.withNextRun(time.Now()))
+		}
+	}
+}
+
+func (hv *Hypervisor) postSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var s Schedule
+
+		if err := httputil.ReadJSON(r, &s); err != nil {
+			if err != io.EOF {
+				log.Warnf("postSchedule request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		s.ID = uuid.New()
+		s.CreatedAt = time.Now().UTC()
+
+		hv.saveSchedule(w, r, s)
+	}
+}
+
+// putSchedule replaces a schedule, keeping its ID and creation time.
+func (hv *Hypervisor) putSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		old, ok := hv.schedule(w, r)
+		if !ok {
+			return
+		}
+
+		var s Schedule
+
+		if err := httputil.ReadJSON(r, &s); err != nil {
+			if err != io.EOF {
+				log.Warnf("putSchedule request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		s.ID, s.CreatedAt = old.ID, old.CreatedAt
+
+		hv.saveSchedule(w, r, s)
+	}
+}
+
+func (hv *Hypervisor) saveSchedule(w http.ResponseWriter, r *http.Request, s Schedule) {
+	if err := s.check(); err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := hv.schedules.SetSchedule(s); err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	httputil.WriteJSON(w, r, http.StatusOK, s.withNextRun(time.Now()))
+}
+
+func (hv *Hypervisor) deleteSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := hv.schedule(w, r)
+		if !ok {
+			return
+		}
+
+		if err := hv.schedules.DeleteSchedule(s.ID); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+func (hv *Hypervisor) getScheduleRuns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := hv.schedule(w, r)
+		if !ok {
+			return
+		}
+
+		runs, err := hv.schedules.ScheduleRuns(s.ID)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, runs)
+	}
+}
+
+// postScheduleRun runs a schedule immediately, regardless of whether it is disabled, and responds with the run.
+func (hv *Hypervisor) postScheduleRun() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := hv.schedule(w, r)
+		if !ok {
+			return
+		}
+
+		run, err := hv.runSchedule(*s, ScheduleTriggerManual)
+		if err == ErrScheduleRunning {
+			httputil.WriteJSON(w, r, http.StatusConflict, err)
+			return
+		}
+
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, run)
+	}
+}
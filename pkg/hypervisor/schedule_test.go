@@ -0,0 +1,167 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedules(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	require.NoError(t, hv.labels.SetLabels(pks[0], map[string]string{"ring": "canary"}))
+	require.NoError(t, hv.labels.SetLabels(pks[1], map[string]string{"ring": "canary"}))
+
+	var sched Schedule
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/schedules",
+			ReqBody:    strings.NewReader(`{"cron":"61 * * * *","labels":{"ring":"canary"},"action":{"type":"health"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/schedules",
+			ReqBody:    strings.NewReader(`{"cron":"@daily","action":{"type":"health"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/schedules",
+			ReqBody:    strings.NewReader(`{"cron":"@daily","labels":{"ring":"canary"},"action":{"type":"exec"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/schedules",
+			ReqBody:    strings.NewReader(`{"cron":"0 3 * * sun","time_zone":"Nowhere/Nothing","labels":{"ring":"canary"},"action":{"type":"update"}}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/schedules",
+			ReqBody:    strings.NewReader(`{"name":"weekly health","cron":"0 3 * * sun","labels":{"ring":"canary"},"action":{"type":"health"}}`),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&sched))
+				assert.NotEqual(t, uuid.UUID{}, sched.ID)
+				require.NotNil(t, sched.NextRun)
+				assert.Equal(t, time.Sunday, sched.NextRun.Weekday())
+				assert.Equal(t, 3, sched.NextRun.Hour())
+			},
+		},
+	})
+
+	uri := fmt.Sprintf("/api/v1/schedules/%s", sched.ID)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri + "/run",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var run ScheduleRun
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+				assert.Equal(t, ScheduleTriggerManual, run.Trigger)
+				require.Len(t, run.Visors, 2)
+
+				for _, v := range run.Visors {
+					assert.Contains(t, []cipher.PubKey{pks[0], pks[1]}, v.PK)
+					assert.Empty(t, v.Error)
+					assert.Contains(t, v.Output, "transport_discovery")
+				}
+			},
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"cron":"* * * * *","visors":[%q],"action":{"type":"exec","command":"echo"}}`, pks[2])),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var s Schedule
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&s))
+				assert.Equal(t, sched.ID, s.ID)
+				assert.Equal(t, ScheduleExec, s.Action.Type)
+			},
+		},
+	})
+
+	hv.runDueSchedules(time.Now())
+
+	require.Eventually(t, func() bool {
+		runs, err := hv.schedules.ScheduleRuns(sched.ID)
+		require.NoError(t, err)
+
+		return len(runs) == 2 && runs[0].Trigger == ScheduleTriggerCron
+	}, 5*time.Second, 10*time.Millisecond)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri + "/runs",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var runs []ScheduleRun
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&runs))
+				require.Len(t, runs, 2)
+				require.Len(t, runs[0].Visors, 1)
+				assert.Equal(t, pks[2], runs[0].Visors[0].PK)
+				assert.Equal(t, "mock", runs[0].Visors[0].Output)
+			},
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri,
+			RespStatus: http.StatusNotFound,
+		},
+	})
+
+	runs, err := hv.schedules.ScheduleRuns(sched.ID)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestBoltScheduleStore_AddScheduleRun(t *testing.T) {
+	_, _, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	id, other := uuid.New(), uuid.New()
+	start := time.Now()
+
+	for i := 0; i < maxScheduleRuns+5; i++ {
+		require.NoError(t, hv.schedules.AddScheduleRun(ScheduleRun{ScheduleID: id, StartedAt: start.Add(time.Duration(i) * time.Second)}))
+	}
+
+	require.NoError(t, hv.schedules.AddScheduleRun(ScheduleRun{ScheduleID: other, StartedAt: start}))
+
+	runs, err := hv.schedules.ScheduleRuns(id)
+	require.NoError(t, err)
+	require.Len(t, runs, maxScheduleRuns)
+	assert.True(t, runs[0].StartedAt.Equal(start.Add(time.Duration(maxScheduleRuns+4)*time.Second)))
+	assert.True(t, runs[maxScheduleRuns-1].StartedAt.Equal(start.Add(5*time.Second)))
+
+	runs, err = hv.schedules.ScheduleRuns(other)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
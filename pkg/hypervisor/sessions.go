@@ -0,0 +1,256 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltSessionBucketName = "sessions"
+)
+
+// ErrSessionNotFound occurs when the session of given ID does not exist.
+var ErrSessionNotFound = errors.New("session is either expired, revoked, or not found")
+
+// SessionStore stores user sessions server-side.
+type SessionStore interface {
+	Session(sid uuid.UUID) (*Session, error)
+	Sessions() ([]Session, error)
+	AddSession(session Session) error
+	RemoveSession(sid uuid.UUID) error
+	RemoveUserSessions(user string) error
+}
+
+// BoltSessionStore implements SessionStore, storing sessions in a bbolt database.
+type BoltSessionStore struct {
+	*bbolt.DB
+	enc *dbCipher // encrypts stored sessions, if set
+}
+
+// NewBoltSessionStore creates a new BoltSessionStore on top of an opened bbolt database.
+func NewBoltSessionStore(db *bbolt.DB) (*BoltSessionStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltSessionBucketName))
+		return err
+	})
+
+	return &BoltSessionStore{DB: db}, err
+}
+
+// Session obtains a single session. Returns nil if the session does not exist.
+func (s *BoltSessionStore) Session(sid uuid.UUID) (session *Session, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw, err := s.enc.open(boltSessionBucketName, sid[:], tx.Bucket([]byte(boltSessionBucketName)).Get(sid[:]))
+		if err != nil || raw == nil {
+			return err
+		}
+
+		session = new(Session)
+
+		return json.Unmarshal(raw, session)
+	})
+
+	return session, err
+}
+
+// Sessions obtains all sessions.
+func (s *BoltSessionStore) Sessions() ([]Session, error) {
+	sessions := make([]Session, 0)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltSessionBucketName)).ForEach(func(k, v []byte) error {
+			v, err := s.enc.open(boltSessionBucketName, k, v)
+			if err != nil {
+				return err
+			}
+
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+
+			sessions = append(sessions, session)
+
+			return nil
+		})
+	})
+
+	return sessions, err
+}
+
+// AddSession adds a session, replacing any existing session of the same ID.
+func (s *BoltSessionStore) AddSession(session Session) error {
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if raw, err = s.enc.seal(boltSessionBucketName, session.SID[:], raw); err != nil {
+		return err
+	}
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltSessionBucketName)).Put(session.SID[:], raw)
+	})
+}
+
+// RemoveSession removes the session of given ID.
+func (s *BoltSessionStore) RemoveSession(sid uuid.UUID) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltSessionBucketName))
+		if b.Get(sid[:]) == nil {
+			return ErrSessionNotFound
+		}
+
+		return b.Delete(sid[:])
+	})
+}
+
+// RemoveUserSessions removes all sessions of the given user.
+func (s *BoltSessionStore) RemoveUserSessions(user string) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltSessionBucketName))
+
+		var sids [][]byte
+
+		err := b.ForEach(func(k, v []byte) error {
+			v, err := s.enc.open(boltSessionBucketName, k, v)
+			if err != nil {
+				return err
+			}
+
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+
+			if session.User == user {
+				sids = append(sids, k)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, sid := range sids {
+			if err := b.Delete(sid); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Sessions returns a HandlerFunc that lists the active sessions of the user of the request.
+// Sessions of all users are listed with the 'all' query, which requires the admin role.
+func (s *UserManager) Sessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, _ := r.Context().Value(sessionKey).(Session) // nolint:errcheck
+
+		all := r.URL.Query().Get("all") == "true"
+		if all && requestRole(r) != RoleAdmin {
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrOtherUsersSessions)
+			return
+		}
+
+		sessions, err := s.sessions.Sessions()
+		if err != nil {
+			log.WithError(err).Error("Failed to obtain sessions")
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		type sessionResp struct {
+			Session
+			Current bool `json:"current"`
+		}
+
+		resp := make([]sessionResp, 0, len(sessions))
+		now := time.Now()
+
+		for _, session := range sessions {
+			if now.After(session.Expiry) {
+				if err := s.sessions.RemoveSession(session.SID); err != nil && err != ErrSessionNotFound {
+					log.WithError(err).Warn("Failed to remove expired session")
+				}
+
+				continue
+			}
+
+			if !all && session.User != current.User {
+				continue
+			}
+
+			resp = append(resp, sessionResp{Session: session, Current: session.SID == current.SID})
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, resp)
+	}
+}
+
+// RevokeSession returns a HandlerFunc that revokes the session of the given ID.
+// Revoking sessions of other users than that of the request requires the admin role.
+func (s *UserManager) RevokeSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		session, err := s.sessions.Session(sid)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to obtain session %s", sid)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		if session == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrSessionNotFound)
+			return
+		}
+
+		current, _ := r.Context().Value(sessionKey).(Session) // nolint:errcheck
+		if session.User != current.User && requestRole(r) != RoleAdmin {
+			httputil.WriteJSON(w, r, http.StatusForbidden, ErrOtherUsersSessions)
+			return
+		}
+
+		if err := s.sessions.RemoveSession(sid); err != nil {
+			if err == ErrSessionNotFound {
+				httputil.WriteJSON(w, r, http.StatusNotFound, err)
+				return
+			}
+
+			log.WithError(err).Errorf("Failed to remove session %s", sid)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// remoteIP returns the IP address of the client making the request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,1396 @@
+package hypervisor
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq" // registers the "postgres" driver
+	"github.com/skycoin/dmsg/cipher"
+)
+
+// SQL schema shared by all SQLStore tables.
+// Column types are limited to those understood by PostgreSQL, MySQL and SQLite alike.
+var sqlSchema = []string{ // nolint: gochecknoglobals
+	`CREATE TABLE IF NOT EXISTS hv_users (name VARCHAR(64) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_sessions (sid VARCHAR(36) PRIMARY KEY, username VARCHAR(64) NOT NULL, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_visor_names (pk VARCHAR(66) PRIMARY KEY, name VARCHAR(32) NOT NULL UNIQUE)`,
+	`CREATE TABLE IF NOT EXISTS hv_visor_meta (pk VARCHAR(66) PRIMARY KEY, alias VARCHAR(256) NOT NULL, notes TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_visor_wake (pk VARCHAR(66) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_uptimes (pk VARCHAR(66) NOT NULL, day VARCHAR(10) NOT NULL, secs BIGINT NOT NULL, PRIMARY KEY (pk, day))`,
+	`CREATE TABLE IF NOT EXISTS hv_visor_registry (pk VARCHAR(66) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_visor_labels (pk VARCHAR(66) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_schedules (id VARCHAR(36) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_config_profiles (name VARCHAR(64) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_config_snapshots (id VARCHAR(36) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_app_binaries (name VARCHAR(64) PRIMARY KEY, data TEXT NOT NULL, bin TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_schedule_runs (id VARCHAR(36) NOT NULL, started BIGINT NOT NULL, data TEXT NOT NULL, PRIMARY KEY (id, started))`,
+	`CREATE TABLE IF NOT EXISTS hv_accept_list (pk VARCHAR(66) PRIMARY KEY, enrolled BIGINT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_enrollment_tokens (hash VARCHAR(64) PRIMARY KEY, expires BIGINT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_update_rings (name VARCHAR(16) PRIMARY KEY, data TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS hv_pty_recordings (id VARCHAR(36) PRIMARY KEY, data TEXT NOT NULL, cast_data TEXT NOT NULL)`,
+}
+
+// SQLStore implements UserStore, SessionStore, NameStore, MetaStore, WakeStore, UptimeStore, VisorRegistry,
+// LabelStore, ScheduleStore, ProfileStore, SnapshotStore, AppBinaryStore, AcceptListStore, UpdateRingStore
+// and PtyRecordingStore on top of a SQL database.
+// Multiple hypervisor instances may share the same database to serve the same users and visors.
+//
+// The PostgreSQL driver "postgres" is linked in by this package. Binaries using other databases
+// need to import their driver for its side effects.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSQLStore opens the database of given driver and DSN, and creates the tables if needed.
+func NewSQLStore(driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLStore{db: db, driver: driver}
+
+	for _, q := range sqlSchema {
+		if _, err := db.Exec(q); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// sqlDriverRegistered returns true if a SQL driver of given name is linked in.
+func sqlDriverRegistered(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind replaces '?' placeholders with the positional '$n' ones when the driver requires them.
+func (s *SQLStore) rebind(q string) string {
+	if s.driver != "postgres" && s.driver != "pgx" {
+		return q
+	}
+
+	var (
+		b strings.Builder
+		n int
+	)
+
+	for _, c := range q {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+func (s *SQLStore) exec(tx *sql.Tx, q string, args ...interface{}) (int64, error) {
+	res, err := tx.Exec(s.rebind(q), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQLStore) update(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback() // nolint: errcheck
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// User obtains a single user. Returns nil if user does not exist.
+func (s *SQLStore) User(name string) (*User, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_users WHERE name = ?`), name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeUser(raw)
+}
+
+// AddUser adds a new user.
+func (s *SQLStore) AddUser(user User) error {
+	encoded, err := user.Encode()
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRow(s.rebind(`SELECT COUNT(*) FROM hv_users WHERE name = ?`), user.Name).Scan(&n); err != nil {
+			return err
+		}
+
+		if n > 0 {
+			return ErrUserExists
+		}
+
+		_, err := s.exec(tx, `INSERT INTO hv_users (name, data) VALUES (?, ?)`,
+			user.Name, base64.StdEncoding.EncodeToString(encoded))
+
+		return err
+	})
+}
+
+// SetUser changes an existing user.
+func (s *SQLStore) SetUser(user User) error {
+	encoded, err := user.Encode()
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_users SET data = ? WHERE name = ?`,
+			base64.StdEncoding.EncodeToString(encoded), user.Name)
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// RemoveUser removes a user of given username.
+func (s *SQLStore) RemoveUser(name string) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM hv_users WHERE name = ?`), name)
+	return err
+}
+
+// Session obtains a single session. Returns nil if the session does not exist.
+func (s *SQLStore) Session(sid uuid.UUID) (*Session, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_sessions WHERE sid = ?`), sid.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Sessions obtains all sessions.
+func (s *SQLStore) Sessions() ([]Session, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_sessions`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close session rows.")
+		}
+	}()
+
+	sessions := make([]Session, 0)
+
+	for rows.Next() {
+		var (
+			data    string
+			session Session
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// AddSession adds a session, replacing any existing session of the same ID.
+func (s *SQLStore) AddSession(session Session) error {
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	sid := session.SID.String()
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_sessions SET username = ?, data = ? WHERE sid = ?`, session.User, string(raw), sid)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_sessions (sid, username, data) VALUES (?, ?, ?)`, sid, session.User, string(raw))
+		return err
+	})
+}
+
+// RemoveSession removes the session of given ID.
+func (s *SQLStore) RemoveSession(sid uuid.UUID) error {
+	res, err := s.db.Exec(s.rebind(`DELETE FROM hv_sessions WHERE sid = ?`), sid.String())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RemoveUserSessions removes all sessions of the given user.
+func (s *SQLStore) RemoveUserSessions(user string) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM hv_sessions WHERE username = ?`), user)
+	return err
+}
+
+// Name returns the name of visor of pk. Returns an empty string if no name is set.
+func (s *SQLStore) Name(pk cipher.PubKey) (string, error) {
+	var name string
+
+	err := s.db.QueryRow(s.rebind(`SELECT name FROM hv_visor_names WHERE pk = ?`), pk.Hex()).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return name, err
+}
+
+// PubKey returns the public key of the visor of given name.
+func (s *SQLStore) PubKey(name string) (cipher.PubKey, error) {
+	var (
+		pk    cipher.PubKey
+		pkHex string
+	)
+
+	err := s.db.QueryRow(s.rebind(`SELECT pk FROM hv_visor_names WHERE name = ?`), name).Scan(&pkHex)
+	if err == sql.ErrNoRows {
+		return pk, ErrVisorNameNotSet
+	}
+
+	if err != nil {
+		return pk, err
+	}
+
+	return pk, pk.UnmarshalText([]byte(pkHex))
+}
+
+// Names returns names of all named visors.
+func (s *SQLStore) Names() (map[cipher.PubKey]string, error) {
+	rows, err := s.db.Query(`SELECT pk, name FROM hv_visor_names`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close visor name rows.")
+		}
+	}()
+
+	names := make(map[cipher.PubKey]string)
+
+	for rows.Next() {
+		var (
+			pk          cipher.PubKey
+			pkHex, name string
+		)
+
+		if err := rows.Scan(&pkHex, &name); err != nil {
+			return nil, err
+		}
+
+		if err := pk.UnmarshalText([]byte(pkHex)); err != nil {
+			return nil, err
+		}
+
+		names[pk] = name
+	}
+
+	return names, rows.Err()
+}
+
+// SetName sets the name of visor of pk. An empty name removes the name.
+func (s *SQLStore) SetName(pk cipher.PubKey, name string) error {
+	if name != "" && !checkVisorNameFormat(name) {
+		return ErrBadVisorName
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		if name == "" {
+			_, err := s.exec(tx, `DELETE FROM hv_visor_names WHERE pk = ?`, pk.Hex())
+			return err
+		}
+
+		var n int
+		q := s.rebind(`SELECT COUNT(*) FROM hv_visor_names WHERE name = ? AND pk <> ?`)
+		if err := tx.QueryRow(q, name, pk.Hex()).Scan(&n); err != nil {
+			return err
+		}
+
+		if n > 0 {
+			return ErrVisorNameTaken
+		}
+
+		updated, err := s.exec(tx, `UPDATE hv_visor_names SET name = ? WHERE pk = ?`, name, pk.Hex())
+		if err != nil || updated > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_visor_names (pk, name) VALUES (?, ?)`, pk.Hex(), name)
+		return err
+	})
+}
+
+// Meta returns the alias and notes of visor of pk.
+func (s *SQLStore) Meta(pk cipher.PubKey) (VisorMeta, error) {
+	var meta VisorMeta
+
+	err := s.db.QueryRow(s.rebind(`SELECT alias, notes FROM hv_visor_meta WHERE pk = ?`), pk.Hex()).
+		Scan(&meta.Alias, &meta.Notes)
+	if err == sql.ErrNoRows {
+		return meta, nil
+	}
+
+	return meta, err
+}
+
+// Metas returns aliases and notes of all visors that have any.
+func (s *SQLStore) Metas() (map[cipher.PubKey]VisorMeta, error) {
+	rows, err := s.db.Query(`SELECT pk, alias, notes FROM hv_visor_meta`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close visor meta rows.")
+		}
+	}()
+
+	metas := make(map[cipher.PubKey]VisorMeta)
+
+	for rows.Next() {
+		var (
+			pk    cipher.PubKey
+			pkHex string
+			meta  VisorMeta
+		)
+
+		if err := rows.Scan(&pkHex, &meta.Alias, &meta.Notes); err != nil {
+			return nil, err
+		}
+
+		if err := pk.UnmarshalText([]byte(pkHex)); err != nil {
+			return nil, err
+		}
+
+		metas[pk] = meta
+	}
+
+	return metas, rows.Err()
+}
+
+// SetMeta sets the alias and notes of visor of pk. Empty values remove them.
+func (s *SQLStore) SetMeta(pk cipher.PubKey, meta VisorMeta) error {
+	if err := meta.check(); err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		if meta == (VisorMeta{}) {
+			_, err := s.exec(tx, `DELETE FROM hv_visor_meta WHERE pk = ?`, pk.Hex())
+			return err
+		}
+
+		if meta.Alias != "" {
+			var n int
+			q := s.rebind(`SELECT COUNT(*) FROM hv_visor_meta WHERE LOWER(alias) = LOWER(?) AND pk <> ?`)
+			if err := tx.QueryRow(q, meta.Alias, pk.Hex()).Scan(&n); err != nil {
+				return err
+			}
+
+			if n > 0 {
+				return ErrVisorAliasTaken
+			}
+		}
+
+		updated, err := s.exec(tx, `UPDATE hv_visor_meta SET alias = ?, notes = ? WHERE pk = ?`, meta.Alias, meta.Notes, pk.Hex())
+		if err != nil || updated > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_visor_meta (pk, alias, notes) VALUES (?, ?, ?)`, pk.Hex(), meta.Alias, meta.Notes)
+		return err
+	})
+}
+
+// PubKeyByAlias returns the public key of the visor of given alias (compared case-insensitively).
+func (s *SQLStore) PubKeyByAlias(alias string) (cipher.PubKey, error) {
+	var (
+		pk    cipher.PubKey
+		pkHex string
+	)
+
+	q := s.rebind(`SELECT pk FROM hv_visor_meta WHERE alias <> '' AND LOWER(alias) = LOWER(?)`)
+	if err := s.db.QueryRow(q, alias).Scan(&pkHex); err == sql.ErrNoRows {
+		return pk, ErrVisorAliasNotSet
+	} else if err != nil {
+		return pk, err
+	}
+
+	return pk, pk.UnmarshalText([]byte(pkHex))
+}
+
+// Wake returns the Wake-on-LAN configuration of visor of pk. Returns nil if there is none.
+func (s *SQLStore) Wake(pk cipher.PubKey) (*WakeConfig, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_visor_wake WHERE pk = ?`), pk.Hex()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var c WakeConfig
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// SetWake sets the Wake-on-LAN configuration of visor of pk. A nil configuration removes it.
+func (s *SQLStore) SetWake(pk cipher.PubKey, c *WakeConfig) error {
+	return s.update(func(tx *sql.Tx) error {
+		if c == nil {
+			_, err := s.exec(tx, `DELETE FROM hv_visor_wake WHERE pk = ?`, pk.Hex())
+			return err
+		}
+
+		raw, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+
+		n, err := s.exec(tx, `UPDATE hv_visor_wake SET data = ? WHERE pk = ?`, string(raw), pk.Hex())
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_visor_wake (pk, data) VALUES (?, ?)`, pk.Hex(), string(raw))
+		return err
+	})
+}
+
+// AddUptime adds d to the recorded uptime of visor of pk on the given day.
+func (s *SQLStore) AddUptime(pk cipher.PubKey, day time.Time, d time.Duration) error {
+	dayStr := day.UTC().Format(uptimeDayFormat)
+	secs := int64(d.Seconds())
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_uptimes SET secs = secs + ? WHERE pk = ? AND day = ?`, secs, pk.Hex(), dayStr)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_uptimes (pk, day, secs) VALUES (?, ?, ?)`, pk.Hex(), dayStr, secs)
+		return err
+	})
+}
+
+// Uptimes returns the total recorded uptime per visor for days within [from, to).
+func (s *SQLStore) Uptimes(from, to time.Time) (map[cipher.PubKey]time.Duration, error) {
+	q := s.rebind(`SELECT pk, secs FROM hv_uptimes WHERE day >= ? AND day < ?`)
+
+	rows, err := s.db.Query(q, from.UTC().Format(uptimeDayFormat), to.UTC().Format(uptimeDayFormat))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close uptime rows.")
+		}
+	}()
+
+	uptimes := make(map[cipher.PubKey]time.Duration)
+
+	for rows.Next() {
+		var (
+			pk    cipher.PubKey
+			pkHex string
+			secs  int64
+		)
+
+		if err := rows.Scan(&pkHex, &secs); err != nil {
+			return nil, err
+		}
+
+		if err := pk.UnmarshalText([]byte(pkHex)); err != nil {
+			return nil, err
+		}
+
+		uptimes[pk] += time.Duration(secs) * time.Second
+	}
+
+	return uptimes, rows.Err()
+}
+
+// Register records the entry, replacing any previous entry of the same visor.
+func (s *SQLStore) Register(entry RegistryEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	pk := entry.VisorPK.Hex()
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_visor_registry SET data = ? WHERE pk = ?`, string(raw), pk)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_visor_registry (pk, data) VALUES (?, ?)`, pk, string(raw))
+		return err
+	})
+}
+
+// Registry returns all recorded entries.
+func (s *SQLStore) Registry() ([]RegistryEntry, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_visor_registry`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close visor registry rows.")
+		}
+	}()
+
+	entries := make([]RegistryEntry, 0)
+
+	for rows.Next() {
+		var (
+			data  string
+			entry RegistryEntry
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Labels returns the labels of visor of pk.
+func (s *SQLStore) Labels(pk cipher.PubKey) (map[string]string, error) {
+	var data string
+
+	labels := make(map[string]string)
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_visor_labels WHERE pk = ?`), pk.Hex()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return labels, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, json.Unmarshal([]byte(data), &labels)
+}
+
+// AllLabels returns the labels of all visors that have any.
+func (s *SQLStore) AllLabels() (map[cipher.PubKey]map[string]string, error) {
+	rows, err := s.db.Query(`SELECT pk, data FROM hv_visor_labels`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close visor label rows.")
+		}
+	}()
+
+	all := make(map[cipher.PubKey]map[string]string)
+
+	for rows.Next() {
+		var (
+			pk     cipher.PubKey
+			pkHex  string
+			data   string
+			labels map[string]string
+		)
+
+		if err := rows.Scan(&pkHex, &data); err != nil {
+			return nil, err
+		}
+
+		if err := pk.UnmarshalText([]byte(pkHex)); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &labels); err != nil {
+			return nil, err
+		}
+
+		all[pk] = labels
+	}
+
+	return all, rows.Err()
+}
+
+// SetLabels replaces the labels of visor of pk. No labels remove them.
+func (s *SQLStore) SetLabels(pk cipher.PubKey, labels map[string]string) error {
+	if err := checkLabels(labels); err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		if len(labels) == 0 {
+			_, err := s.exec(tx, `DELETE FROM hv_visor_labels WHERE pk = ?`, pk.Hex())
+			return err
+		}
+
+		raw, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+
+		n, err := s.exec(tx, `UPDATE hv_visor_labels SET data = ? WHERE pk = ?`, string(raw), pk.Hex())
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_visor_labels (pk, data) VALUES (?, ?)`, pk.Hex(), string(raw))
+		return err
+	})
+}
+
+// Schedules returns all schedules, ordered by creation time.
+func (s *SQLStore) Schedules() ([]Schedule, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_schedules`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close schedule rows.")
+		}
+	}()
+
+	schedules := make([]Schedule, 0)
+
+	for rows.Next() {
+		var (
+			data  string
+			sched Schedule
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &sched); err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, sched)
+	}
+
+	sortSchedules(schedules)
+
+	return schedules, rows.Err()
+}
+
+// Schedule returns the schedule of id. Returns nil if there is none.
+func (s *SQLStore) Schedule(id uuid.UUID) (*Schedule, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_schedules WHERE id = ?`), id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sched Schedule
+	if err := json.Unmarshal([]byte(data), &sched); err != nil {
+		return nil, err
+	}
+
+	return &sched, nil
+}
+
+// SetSchedule adds or replaces the schedule.
+func (s *SQLStore) SetSchedule(sched Schedule) error {
+	sched.NextRun = nil
+
+	raw, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_schedules SET data = ? WHERE id = ?`, string(raw), sched.ID.String())
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_schedules (id, data) VALUES (?, ?)`, sched.ID.String(), string(raw))
+		return err
+	})
+}
+
+// DeleteSchedule removes the schedule of id, together with its run history.
+func (s *SQLStore) DeleteSchedule(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `DELETE FROM hv_schedules WHERE id = ?`, id.String()); err != nil {
+			return err
+		}
+
+		_, err := s.exec(tx, `DELETE FROM hv_schedule_runs WHERE id = ?`, id.String())
+		return err
+	})
+}
+
+// AddScheduleRun records the run, removing the oldest runs of the schedule beyond maxScheduleRuns.
+func (s *SQLStore) AddScheduleRun(run ScheduleRun) error {
+	raw, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	id := run.ScheduleID.String()
+
+	return s.update(func(tx *sql.Tx) error {
+		q := `INSERT INTO hv_schedule_runs (id, started, data) VALUES (?, ?, ?)`
+		if _, err := s.exec(tx, q, id, run.StartedAt.UnixNano(), string(raw)); err != nil {
+			return err
+		}
+
+		var n int
+		if err := tx.QueryRow(s.rebind(`SELECT COUNT(*) FROM hv_schedule_runs WHERE id = ?`), id).Scan(&n); err != nil {
+			return err
+		}
+
+		if n <= maxScheduleRuns {
+			return nil
+		}
+
+		var oldest int64
+		q = `SELECT MIN(started) FROM hv_schedule_runs WHERE id = ?`
+		for ; n > maxScheduleRuns; n-- {
+			if err := tx.QueryRow(s.rebind(q), id).Scan(&oldest); err != nil {
+				return err
+			}
+
+			if _, err := s.exec(tx, `DELETE FROM hv_schedule_runs WHERE id = ? AND started = ?`, id, oldest); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ScheduleRuns returns the recorded runs of the schedule of id, newest first.
+func (s *SQLStore) ScheduleRuns(id uuid.UUID) ([]ScheduleRun, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT data FROM hv_schedule_runs WHERE id = ?`), id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close schedule run rows.")
+		}
+	}()
+
+	runs := make([]ScheduleRun, 0)
+
+	for rows.Next() {
+		var (
+			data string
+			run  ScheduleRun
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &run); err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, run)
+	}
+
+	sortScheduleRuns(runs)
+
+	return runs, rows.Err()
+}
+
+// Profiles returns all config profiles, ordered by name.
+func (s *SQLStore) Profiles() ([]ConfigProfile, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_config_profiles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close config profile rows.")
+		}
+	}()
+
+	profiles := make([]ConfigProfile, 0)
+
+	for rows.Next() {
+		var (
+			data string
+			p    ConfigProfile
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	return profiles, rows.Err()
+}
+
+// Profile returns the config profile of name. Returns nil if there is none.
+func (s *SQLStore) Profile(name string) (*ConfigProfile, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_config_profiles WHERE name = ?`), name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var p ConfigProfile
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// SetProfile adds or replaces the config profile.
+func (s *SQLStore) SetProfile(p ConfigProfile) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_config_profiles SET data = ? WHERE name = ?`, string(raw), p.Name)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_config_profiles (name, data) VALUES (?, ?)`, p.Name, string(raw))
+		return err
+	})
+}
+
+// DeleteProfile removes the config profile of name.
+func (s *SQLStore) DeleteProfile(name string) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `DELETE FROM hv_config_profiles WHERE name = ?`, name)
+		return err
+	})
+}
+
+// Snapshots returns all config snapshots, newest first.
+func (s *SQLStore) Snapshots() ([]ConfigSnapshot, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_config_snapshots`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close config snapshot rows.")
+		}
+	}()
+
+	snapshots := make([]ConfigSnapshot, 0)
+
+	for rows.Next() {
+		var (
+			data string
+			snap ConfigSnapshot
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &snap); err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snap)
+	}
+
+	sortSnapshots(snapshots)
+
+	return snapshots, rows.Err()
+}
+
+// Snapshot returns the config snapshot of id. Returns nil if there is none.
+func (s *SQLStore) Snapshot(id uuid.UUID) (*ConfigSnapshot, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_config_snapshots WHERE id = ?`), id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snap ConfigSnapshot
+	if err := json.Unmarshal([]byte(data), &snap); err != nil {
+		return nil, err
+	}
+
+	return &snap, nil
+}
+
+// AddSnapshot stores the config snapshot.
+func (s *SQLStore) AddSnapshot(snap ConfigSnapshot) error {
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `INSERT INTO hv_config_snapshots (id, data) VALUES (?, ?)`, snap.ID.String(), string(raw))
+		return err
+	})
+}
+
+// DeleteSnapshot removes the config snapshot of id.
+func (s *SQLStore) DeleteSnapshot(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `DELETE FROM hv_config_snapshots WHERE id = ?`, id.String())
+		return err
+	})
+}
+
+// AppBinaries describes all stored app binaries, sorted by name.
+func (s *SQLStore) AppBinaries() ([]AppBinary, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_app_binaries ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close app binary rows.")
+		}
+	}()
+
+	binaries := make([]AppBinary, 0)
+
+	for rows.Next() {
+		var (
+			data string
+			b    AppBinary
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &b); err != nil {
+			return nil, err
+		}
+
+		binaries = append(binaries, b)
+	}
+
+	return binaries, rows.Err()
+}
+
+// AppBinary describes the app binary of name. Returns nil if there is none.
+func (s *SQLStore) AppBinary(name string) (*AppBinary, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_app_binaries WHERE name = ?`), name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var b AppBinary
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// AppBinaryData returns the app binary of name. Returns nil if there is none.
+// Binaries are stored base64 encoded, as TEXT is portable across drivers.
+func (s *SQLStore) AppBinaryData(name string) ([]byte, error) {
+	var bin string
+
+	err := s.db.QueryRow(s.rebind(`SELECT bin FROM hv_app_binaries WHERE name = ?`), name).Scan(&bin)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(bin)
+}
+
+// SaveAppBinary adds or replaces the app binary.
+func (s *SQLStore) SaveAppBinary(b AppBinary, data []byte) error {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	bin := base64.StdEncoding.EncodeToString(data)
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_app_binaries SET data = ?, bin = ? WHERE name = ?`, string(raw), bin, b.Name)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_app_binaries (name, data, bin) VALUES (?, ?, ?)`, b.Name, string(raw), bin)
+		return err
+	})
+}
+
+// DeleteAppBinary removes the app binary of name.
+func (s *SQLStore) DeleteAppBinary(name string) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `DELETE FROM hv_app_binaries WHERE name = ?`, name)
+		return err
+	})
+}
+
+// EnrolledVisors returns the visors enrolled in the store.
+func (s *SQLStore) EnrolledVisors() ([]EnrolledVisor, error) {
+	rows, err := s.db.Query(`SELECT pk, enrolled FROM hv_accept_list`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close accept-list rows.")
+		}
+	}()
+
+	enrolled := make([]EnrolledVisor, 0)
+
+	for rows.Next() {
+		var (
+			e     EnrolledVisor
+			pkHex string
+			at    int64
+		)
+
+		if err := rows.Scan(&pkHex, &at); err != nil {
+			return nil, err
+		}
+
+		if err := e.PK.UnmarshalText([]byte(pkHex)); err != nil {
+			return nil, err
+		}
+
+		e.EnrolledAt = time.Unix(0, at).UTC()
+		enrolled = append(enrolled, e)
+	}
+
+	return enrolled, rows.Err()
+}
+
+// Enrolled returns whether the visor of pk is enrolled in the store.
+func (s *SQLStore) Enrolled(pk cipher.PubKey) (bool, error) {
+	var n int
+
+	err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM hv_accept_list WHERE pk = ?`), pk.Hex()).Scan(&n)
+
+	return n > 0, err
+}
+
+// Enroll adds the visor of pk to the accept-list.
+func (s *SQLStore) Enroll(pk cipher.PubKey, at time.Time) error {
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_accept_list SET enrolled = ? WHERE pk = ?`, at.UnixNano(), pk.Hex())
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_accept_list (pk, enrolled) VALUES (?, ?)`, pk.Hex(), at.UnixNano())
+		return err
+	})
+}
+
+// Unenroll removes the visor of pk from the accept-list.
+func (s *SQLStore) Unenroll(pk cipher.PubKey) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `DELETE FROM hv_accept_list WHERE pk = ?`, pk.Hex())
+		return err
+	})
+}
+
+// AddEnrollmentToken stores the hash of the token.
+func (s *SQLStore) AddEnrollmentToken(token string, expiresAt time.Time) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `INSERT INTO hv_enrollment_tokens (hash, expires) VALUES (?, ?)`,
+			enrollmentTokenHash(token), expiresAt.UnixNano())
+		return err
+	})
+}
+
+// UseEnrollmentToken removes the token, failing if it does not exist or expired.
+// Expired tokens are removed as well.
+func (s *SQLStore) UseEnrollmentToken(token string, now time.Time) error {
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `DELETE FROM hv_enrollment_tokens WHERE expires < ?`, now.UnixNano()); err != nil {
+			return err
+		}
+
+		n, err := s.exec(tx, `DELETE FROM hv_enrollment_tokens WHERE hash = ?`, enrollmentTokenHash(token))
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return ErrBadEnrollmentToken
+		}
+
+		return nil
+	})
+}
+
+// UpdateRings returns the update rings, which are disabled and empty if none are stored.
+func (s *SQLStore) UpdateRings() (UpdateRings, error) {
+	u := UpdateRings{Rings: make([]UpdateRing, 0), State: UpdateRingsState{Phase: RingsIdle}}
+
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_update_rings WHERE name = ?`), updateRingsKey).Scan(&data)
+	if err == sql.ErrNoRows {
+		return u, nil
+	}
+
+	if err != nil {
+		return u, err
+	}
+
+	err = json.Unmarshal([]byte(data), &u)
+
+	return u, err
+}
+
+// SetUpdateRings replaces the update rings.
+func (s *SQLStore) SetUpdateRings(u UpdateRings) error {
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		n, err := s.exec(tx, `UPDATE hv_update_rings SET data = ? WHERE name = ?`, string(raw), updateRingsKey)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = s.exec(tx, `INSERT INTO hv_update_rings (name, data) VALUES (?, ?)`, updateRingsKey, string(raw))
+		return err
+	})
+}
+
+// PtyRecordings describes all pty recordings, latest first.
+func (s *SQLStore) PtyRecordings() ([]PtyRecording, error) {
+	rows, err := s.db.Query(`SELECT data FROM hv_pty_recordings`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close pty recording rows.")
+		}
+	}()
+
+	recs := make([]PtyRecording, 0)
+
+	for rows.Next() {
+		var (
+			data string
+			rec  PtyRecording
+		)
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, err
+		}
+
+		recs = append(recs, rec)
+	}
+
+	sortPtyRecordings(recs)
+
+	return recs, rows.Err()
+}
+
+// PtyRecording describes the pty recording of id. Returns nil if there is none.
+func (s *SQLStore) PtyRecording(id uuid.UUID) (*PtyRecording, error) {
+	var data string
+
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM hv_pty_recordings WHERE id = ?`), id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rec PtyRecording
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// PtyRecordingCast returns the asciicast file of the pty recording of id. Returns nil if there is none.
+func (s *SQLStore) PtyRecordingCast(id uuid.UUID) ([]byte, error) {
+	var cast string
+
+	err := s.db.QueryRow(s.rebind(`SELECT cast_data FROM hv_pty_recordings WHERE id = ?`), id.String()).Scan(&cast)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return []byte(cast), err
+}
+
+// AddPtyRecording stores the pty recording.
+func (s *SQLStore) AddPtyRecording(rec PtyRecording, cast []byte) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `INSERT INTO hv_pty_recordings (id, data, cast_data) VALUES (?, ?, ?)`,
+			rec.ID.String(), string(raw), string(cast))
+		return err
+	})
+}
+
+// DeletePtyRecording removes the pty recording of id.
+func (s *SQLStore) DeletePtyRecording(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `DELETE FROM hv_pty_recordings WHERE id = ?`, id.String())
+		return err
+	})
+}
@@ -0,0 +1,132 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/util/buildinfo"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Standby visors which miss this many heartbeats in a row are disconnected.
+const standbyMissedHeartbeats = 3
+
+// standbyVisor is a visor which registered with heartbeats only, without serving RPC.
+type standbyVisor struct {
+	Addr        dmsg.Addr
+	Heartbeat   visor.Heartbeat
+	ConnectedAt time.Time
+	LastSeen    time.Time
+}
+
+// ServeStandby accepts heartbeat streams of standby visors from lis.
+func (hv *Hypervisor) ServeStandby(lis *dmsg.Listener) error {
+	for {
+		conn, err := lis.AcceptStream()
+		if err != nil {
+			return err
+		}
+
+		go hv.serveStandbyConn(conn.RawRemoteAddr(), conn)
+	}
+}
+
+// serveStandbyConn records heartbeats received on conn, until it fails or no heartbeats come in time.
+func (hv *Hypervisor) serveStandbyConn(addr dmsg.Addr, conn net.Conn) {
+	log := log.WithField("remote_addr", addr)
+	log.Info("Accepted standby visor.")
+
+	defer func() {
+		hv.mu.Lock()
+		delete(hv.standby, addr.PK)
+		hv.mu.Unlock()
+
+		if err := conn.Close(); err != nil {
+			log.WithError(err).Debug("Failed to close standby conn.")
+		}
+	}()
+
+	connectedAt := time.Now().UTC()
+	dec := json.NewDecoder(conn)
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(standbyMissedHeartbeats * visor.StandbyHeartbeatInterval)); err != nil {
+			log.WithError(err).Warn("Failed to set read deadline.")
+			return
+		}
+
+		var hb visor.Heartbeat
+		if err := dec.Decode(&hb); err != nil {
+			log.WithError(err).Info("Standby visor disconnected.")
+			return
+		}
+
+		hv.mu.Lock()
+		hv.standby[addr.PK] = &standbyVisor{
+			Addr:        addr,
+			Heartbeat:   hb,
+			ConnectedAt: connectedAt,
+			LastSeen:    time.Now().UTC(),
+		}
+		hv.mu.Unlock()
+	}
+}
+
+type standbyVisorResp struct {
+	PK          cipher.PubKey   `json:"pk"`
+	Name        string          `json:"name,omitempty"`
+	Alias       string          `json:"alias,omitempty"`
+	Addr        dmsg.Addr       `json:"addr"`
+	BuildInfo   *buildinfo.Info `json:"build_info,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	Uptime      float64         `json:"uptime"`
+	ConnectedAt time.Time       `json:"connected_at"`
+	LastSeen    time.Time       `json:"last_seen"`
+}
+
+// getStandbyVisors lists visors registered with heartbeats only, sorted by public key.
+func (hv *Hypervisor) getStandbyVisors() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names, err := hv.names.Names()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		metas, err := hv.meta.Metas()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		hv.mu.RLock()
+		visors := make([]standbyVisorResp, 0, len(hv.standby))
+		for pk, v := range hv.standby {
+			visors = append(visors, standbyVisorResp{
+				PK:          pk,
+				Name:        names[pk],
+				Alias:       metas[pk].Alias,
+				Addr:        v.Addr,
+				BuildInfo:   v.Heartbeat.BuildInfo,
+				StartedAt:   v.Heartbeat.StartedAt,
+				Uptime:      v.Heartbeat.Uptime,
+				ConnectedAt: v.ConnectedAt,
+				LastSeen:    v.LastSeen,
+			})
+		}
+		hv.mu.RUnlock()
+
+		sort.Slice(visors, func(i, j int) bool {
+			return visors[i].PK.Hex() < visors[j].PK.Hex()
+		})
+
+		httputil.WriteJSON(w, r, http.StatusOK, visors)
+	}
+}
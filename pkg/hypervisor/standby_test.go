@@ -0,0 +1,64 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/util/buildinfo"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestStandbyVisors(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	pk, _ := cipher.GenerateKeyPair()
+	startedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	visorConn, hvConn := net.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		hv.serveStandbyConn(dmsg.Addr{PK: pk, Port: skyenv.DmsgStandbyPort}, hvConn)
+		close(done)
+	}()
+
+	hb := visor.Heartbeat{BuildInfo: buildinfo.Get(), StartedAt: startedAt, Uptime: 3600}
+	require.NoError(t, json.NewEncoder(visorConn).Encode(hb))
+
+	// The heartbeat is recorded once the next one can be written.
+	require.NoError(t, json.NewEncoder(visorConn).Encode(hb))
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/visors/standby",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var visors []standbyVisorResp
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&visors))
+				require.Len(t, visors, 1)
+				assert.Equal(t, pk, visors[0].PK)
+				assert.Equal(t, startedAt, visors[0].StartedAt)
+				assert.Equal(t, float64(3600), visors[0].Uptime)
+				assert.NotNil(t, visors[0].BuildInfo)
+			},
+		},
+	})
+
+	require.NoError(t, visorConn.Close())
+	<-done
+
+	hv.mu.RLock()
+	assert.Empty(t, hv.standby)
+	hv.mu.RUnlock()
+}
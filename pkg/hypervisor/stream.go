@@ -0,0 +1,68 @@
+package hypervisor
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	streamBufSize    = 32 * 1024
+	streamFlushEvery = 512 // elements
+)
+
+// streamJSONArray writes a JSON array of n elements, where elem(i) returns the i-th element.
+// Elements are encoded one by one and periodically flushed, so that the response is sent
+// chunked and never held in memory as a whole.
+func streamJSONArray(w http.ResponseWriter, code int, n int, elem func(i int) interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher) // nolint: errcheck
+	bw := bufio.NewWriterSize(w, streamBufSize)
+
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		return nil
+	}
+
+	if err := bw.WriteByte('['); err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+
+		raw, err := json.Marshal(elem(i))
+		if err != nil {
+			return err
+		}
+
+		if _, err := bw.Write(raw); err != nil {
+			return err
+		}
+
+		if (i+1)%streamFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := bw.WriteString("]\n"); err != nil {
+		return err
+	}
+
+	return flush()
+}
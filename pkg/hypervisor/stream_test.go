@@ -0,0 +1,32 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamJSONArray(t *testing.T) {
+	for _, n := range []int{0, 1, streamFlushEvery, streamFlushEvery*3 + 7} {
+		w := httptest.NewRecorder()
+
+		err := streamJSONArray(w, http.StatusOK, n, func(i int) interface{} {
+			return map[string]int{"i": i}
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var out []map[string]int
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		require.Len(t, out, n)
+
+		for i, v := range out {
+			assert.Equal(t, i, v["i"])
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Outbound sync target types.
+const (
+	SyncTargetHTTP   = "http"
+	SyncTargetConsul = "consul"
+	SyncTargetEtcd   = "etcd"
+)
+
+// Outbound sync events, as sent to targets of type "http".
+const (
+	SyncEventUpdate = "visor_updated"
+	SyncEventRemove = "visor_removed"
+)
+
+const (
+	defaultSyncInterval = 30 * time.Second
+	defaultSyncPrefix   = "skywire/visors"
+	syncTimeout         = 10 * time.Second
+)
+
+// ErrUnknownSyncTarget occurs when the configured sync target type is not supported.
+var ErrUnknownSyncTarget = errors.New("unknown sync target type")
+
+// SyncConfig configures mirroring of the visor registry and summaries into external systems.
+type SyncConfig struct {
+	Targets  []SyncTargetConfig `json:"targets,omitempty"`
+	Interval time.Duration      `json:"interval,omitempty"` // How often visors are checked for changes.
+}
+
+// SyncTargetConfig configures a single outbound sync target.
+type SyncTargetConfig struct {
+	Type   string `json:"type"`             // One of "http", "consul" or "etcd".
+	URL    string `json:"url"`              // Endpoint to POST events to, or the Consul agent / etcd gateway address.
+	Prefix string `json:"prefix,omitempty"` // Key prefix (consul and etcd only).
+	Token  string `json:"token,omitempty"`  // Authentication token. (optional)
+}
+
+// SyncEvent is the JSON payload POSTed to targets of type "http".
+type SyncEvent struct {
+	Event   string          `json:"event"`
+	VisorPK cipher.PubKey   `json:"visor_pk"`
+	Time    time.Time       `json:"time"`
+	Visor   json.RawMessage `json:"visor,omitempty"`
+}
+
+type syncSink interface {
+	Put(pk cipher.PubKey, raw []byte) error
+	Delete(pk cipher.PubKey) error
+}
+
+func newSyncSink(c SyncTargetConfig, client *http.Client) (syncSink, error) {
+	if c.Prefix == "" {
+		c.Prefix = defaultSyncPrefix
+	}
+
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	c.Prefix = strings.Trim(c.Prefix, "/")
+
+	switch c.Type {
+	case SyncTargetHTTP:
+		return &httpSyncSink{c: c, client: client}, nil
+	case SyncTargetConsul:
+		return &consulSyncSink{c: c, client: client}, nil
+	case SyncTargetEtcd:
+		return &etcdSyncSink{c: c, client: client}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSyncTarget, c.Type)
+	}
+}
+
+func doSyncRequest(client *http.Client, method, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close sync response body.")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s %s responded with status %d", method, url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// httpSyncSink POSTs a SyncEvent to an HTTP endpoint on every change.
+type httpSyncSink struct {
+	c      SyncTargetConfig
+	client *http.Client
+}
+
+func (s *httpSyncSink) post(event SyncEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if s.c.Token != "" {
+		header.Set("Authorization", "Bearer "+s.c.Token)
+	}
+
+	return doSyncRequest(s.client, http.MethodPost, s.c.URL, payload, header)
+}
+
+func (s *httpSyncSink) Put(pk cipher.PubKey, raw []byte) error {
+	return s.post(SyncEvent{Event: SyncEventUpdate, VisorPK: pk, Time: time.Now().UTC(), Visor: raw})
+}
+
+func (s *httpSyncSink) Delete(pk cipher.PubKey) error {
+	return s.post(SyncEvent{Event: SyncEventRemove, VisorPK: pk, Time: time.Now().UTC()})
+}
+
+// consulSyncSink stores visors under '<prefix>/<pk>' in the Consul KV store.
+type consulSyncSink struct {
+	c      SyncTargetConfig
+	client *http.Client
+}
+
+func (s *consulSyncSink) do(method string, pk cipher.PubKey, body []byte) error {
+	header := http.Header{}
+	if s.c.Token != "" {
+		header.Set("X-Consul-Token", s.c.Token)
+	}
+
+	url := fmt.Sprintf("%s/v1/kv/%s/%s", s.c.URL, s.c.Prefix, pk.Hex())
+
+	return doSyncRequest(s.client, method, url, body, header)
+}
+
+func (s *consulSyncSink) Put(pk cipher.PubKey, raw []byte) error {
+	return s.do(http.MethodPut, pk, raw)
+}
+
+func (s *consulSyncSink) Delete(pk cipher.PubKey) error {
+	return s.do(http.MethodDelete, pk, nil)
+}
+
+// etcdSyncSink stores visors under '<prefix>/<pk>' in etcd, via its v3 JSON gateway.
+type etcdSyncSink struct {
+	c      SyncTargetConfig
+	client *http.Client
+}
+
+func (s *etcdSyncSink) do(op string, body map[string]string) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if s.c.Token != "" {
+		header.Set("Authorization", s.c.Token)
+	}
+
+	return doSyncRequest(s.client, http.MethodPost, s.c.URL+"/v3/kv/"+op, payload, header)
+}
+
+func (s *etcdSyncSink) key(pk cipher.PubKey) string {
+	return base64.StdEncoding.EncodeToString([]byte(s.c.Prefix + "/" + pk.Hex()))
+}
+
+func (s *etcdSyncSink) Put(pk cipher.PubKey, raw []byte) error {
+	return s.do("put", map[string]string{
+		"key":   s.key(pk),
+		"value": base64.StdEncoding.EncodeToString(raw),
+	})
+}
+
+func (s *etcdSyncSink) Delete(pk cipher.PubKey) error {
+	return s.do("deleterange", map[string]string{"key": s.key(pk)})
+}
+
+// syncer pushes changed visor records to all sinks.
+type syncer struct {
+	c     SyncConfig
+	sinks []syncSink
+	last  []map[cipher.PubKey]string // last successfully pushed record, per sink
+}
+
+func newSyncer(c SyncConfig) (*syncer, error) {
+	if c.Interval <= 0 {
+		c.Interval = defaultSyncInterval
+	}
+
+	client := &http.Client{Timeout: syncTimeout}
+	s := &syncer{c: c}
+
+	for _, tc := range c.Targets {
+		sink, err := newSyncSink(tc, client)
+		if err != nil {
+			return nil, err
+		}
+
+		s.sinks = append(s.sinks, sink)
+		s.last = append(s.last, make(map[cipher.PubKey]string))
+	}
+
+	return s, nil
+}
+
+// makeSyncRecords encodes visor summaries, leaving out transport logs which change constantly.
+func makeSyncRecords(summaries []summaryResp) (map[cipher.PubKey][]byte, error) {
+	records := make(map[cipher.PubKey][]byte, len(summaries))
+
+	for _, s := range summaries {
+		if s.Summary == nil {
+			continue
+		}
+
+		summary := *s.Summary
+		summary.Transports = make([]*visor.TransportSummary, len(s.Transports))
+
+		for i, tp := range s.Transports {
+			tpCopy := *tp
+			tpCopy.Log = nil
+			summary.Transports[i] = &tpCopy
+		}
+
+		s.Summary = &summary
+
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+
+		records[summary.PubKey] = raw
+	}
+
+	return records, nil
+}
+
+// sync pushes records which changed since the last successful push, and removes records which are gone.
+// Failed pushes are retried on the next call.
+func (s *syncer) sync(records map[cipher.PubKey][]byte) {
+	for i, sink := range s.sinks {
+		last := s.last[i]
+
+		for pk, raw := range records {
+			if last[pk] == string(raw) {
+				continue
+			}
+
+			if err := sink.Put(pk, raw); err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Warn("Failed to sync visor.")
+				continue
+			}
+
+			last[pk] = string(raw)
+		}
+
+		for pk := range last {
+			if _, ok := records[pk]; ok {
+				continue
+			}
+
+			if err := sink.Delete(pk); err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Warn("Failed to remove synced visor.")
+				continue
+			}
+
+			delete(last, pk)
+		}
+	}
+}
+
+// syncVisors periodically mirrors visor summaries into the configured sync targets.
+func (hv *Hypervisor) syncVisors() {
+	hv.syncOnce(time.Now())
+	hv.every(hv.syncer.c.Interval, hv.syncOnce)
+}
+
+func (hv *Hypervisor) syncOnce(time.Time) {
+	summaries, err := hv.visorSummaries()
+	if err != nil {
+		log.WithError(err).Warn("Failed to obtain visor summaries for sync.")
+		return
+	}
+
+	records, err := makeSyncRecords(summaries)
+	if err != nil {
+		log.WithError(err).Warn("Failed to encode visor summaries for sync.")
+		return
+	}
+
+	hv.syncer.sync(records)
+}
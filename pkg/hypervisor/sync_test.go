@@ -0,0 +1,143 @@
+package hypervisor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+func startSyncTarget(t *testing.T) (*httptest.Server, func() []syncRequest) {
+	var (
+		mu   sync.Mutex
+		reqs []syncRequest
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mu.Lock()
+		reqs = append(reqs, syncRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: body})
+		mu.Unlock()
+	}))
+
+	return srv, func() []syncRequest {
+		mu.Lock()
+		defer mu.Unlock()
+
+		out := reqs
+		reqs = nil
+
+		return out
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	srv, requests := startSyncTarget(t)
+	defer srv.Close()
+
+	s, err := newSyncer(SyncConfig{Targets: []SyncTargetConfig{
+		{Type: SyncTargetHTTP, URL: srv.URL + "/hook", Token: "secret"},
+		{Type: SyncTargetConsul, URL: srv.URL, Prefix: "fleet"},
+		{Type: SyncTargetEtcd, URL: srv.URL},
+	}})
+	require.NoError(t, err)
+
+	pk, _ := cipher.GenerateKeyPair()
+	records := map[cipher.PubKey][]byte{pk: []byte(`{"online":true}`)}
+
+	s.sync(records)
+	reqs := requests()
+	require.Len(t, reqs, 3)
+
+	byPath := make(map[string]syncRequest)
+	for _, r := range reqs {
+		byPath[r.Path] = r
+	}
+
+	hook := byPath["/hook"]
+	assert.Equal(t, http.MethodPost, hook.Method)
+	assert.Equal(t, "Bearer secret", hook.Header.Get("Authorization"))
+
+	var event SyncEvent
+	require.NoError(t, json.Unmarshal(hook.Body, &event))
+	assert.Equal(t, SyncEventUpdate, event.Event)
+	assert.Equal(t, pk, event.VisorPK)
+	assert.JSONEq(t, `{"online":true}`, string(event.Visor))
+
+	consul := byPath["/v1/kv/fleet/"+pk.Hex()]
+	assert.Equal(t, http.MethodPut, consul.Method)
+	assert.Equal(t, `{"online":true}`, string(consul.Body))
+
+	var etcdPut map[string]string
+	require.NoError(t, json.Unmarshal(byPath["/v3/kv/put"].Body, &etcdPut))
+	key, err := base64.StdEncoding.DecodeString(etcdPut["key"])
+	require.NoError(t, err)
+	assert.Equal(t, defaultSyncPrefix+"/"+pk.Hex(), string(key))
+
+	// Nothing changed, nothing is pushed.
+	s.sync(records)
+	assert.Empty(t, requests())
+
+	// Removed visors are deleted.
+	s.sync(map[cipher.PubKey][]byte{})
+	reqs = requests()
+	require.Len(t, reqs, 3)
+
+	for _, r := range reqs {
+		switch r.Path {
+		case "/hook":
+			require.NoError(t, json.Unmarshal(r.Body, &event))
+			assert.Equal(t, SyncEventRemove, event.Event)
+		case "/v1/kv/fleet/" + pk.Hex():
+			assert.Equal(t, http.MethodDelete, r.Method)
+		default:
+			assert.Equal(t, "/v3/kv/deleterange", r.Path)
+		}
+	}
+}
+
+func TestSyncer_Retry(t *testing.T) {
+	fail := int32(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := newSyncer(SyncConfig{Targets: []SyncTargetConfig{{Type: SyncTargetConsul, URL: srv.URL}}})
+	require.NoError(t, err)
+
+	pk, _ := cipher.GenerateKeyPair()
+	records := map[cipher.PubKey][]byte{pk: []byte(`{}`)}
+
+	s.sync(records)
+	assert.Empty(t, s.last[0])
+
+	atomic.StoreInt32(&fail, 0)
+	s.sync(records)
+	assert.Equal(t, `{}`, s.last[0][pk])
+}
+
+func TestNewSyncer_UnknownTarget(t *testing.T) {
+	_, err := newSyncer(SyncConfig{Targets: []SyncTargetConfig{{Type: "zookeeper"}}})
+	assert.True(t, errors.Is(err, ErrUnknownSyncTarget))
+}
@@ -0,0 +1,68 @@
+package hypervisor
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+// decodeRuleResp is the response of decoding a routing rule.
+type decodeRuleResp struct {
+	routingRuleResp
+	Type        string `json:"type"`        // Name of the rule type.
+	Description string `json:"description"` // Rule as formatted in visor logs.
+}
+
+// decodeRule decodes a hex-encoded routing rule, as given in routingRuleResp.Rule.
+// Whitespace and a leading "0x" are ignored, so that rules may be pasted from logs as they are.
+func decodeRule(s string) (routing.Rule, error) {
+	s = strings.Join(strings.Fields(s), "")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := routing.Rule(raw)
+
+	return rule, rule.Validate()
+}
+
+// postDecodeRule decodes a raw hex routing rule into its summary, without needing a visor.
+func (hv *Hypervisor) postDecodeRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqBody struct {
+			Rule string `json:"rule"`
+		}
+
+		if err := httputil.ReadJSON(r, &reqBody); err != nil {
+			if err != io.EOF {
+				log.Warnf("postDecodeRule request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		rule, err := decodeRule(reqBody.Rule)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		resp := decodeRuleResp{
+			routingRuleResp: makeRoutingRuleResp(rule.KeyRouteID(), rule, true),
+			Type:            rule.Type().String(),
+			Description:     rule.String(),
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, resp)
+	}
+}
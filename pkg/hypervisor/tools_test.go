@@ -0,0 +1,65 @@
+package hypervisor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+func TestDecodeRule(t *testing.T) {
+	addr, client, _, stop := makeStartMockNode(t)
+	defer stop()
+
+	pk, _ := cipher.GenerateKeyPair()
+	tpID := uuid.New()
+	rule := routing.ForwardRule(time.Minute, 7, 8, tpID, pk, pk, 1, 2)
+	raw := hex.EncodeToString(rule)
+
+	// As copied from logs: prefixed and wrapped.
+	pasted := "0x" + raw[:20] + "\n  " + raw[20:]
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tools/decode-rule",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"rule":%q}`, pasted)),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, resp *http.Response) {
+				var out decodeRuleResp
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+
+				assert.Equal(t, routing.RouteID(7), out.Key)
+				assert.Equal(t, raw, out.Rule)
+				assert.Equal(t, "Forward", out.Type)
+				assert.Equal(t, rule.String(), out.Description)
+				require.NotNil(t, out.Summary)
+				require.NotNil(t, out.Summary.ForwardFields)
+				assert.Equal(t, tpID, out.Summary.ForwardFields.NextTID)
+				assert.Equal(t, routing.RouteID(8), out.Summary.ForwardFields.NextRID)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tools/decode-rule",
+			ReqBody:    strings.NewReader(`{"rule":"zz"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     "/api/v1/tools/decode-rule",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"rule":%q}`, raw[:40])),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,141 @@
+package hypervisor
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/transport"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Topology is a graph of the network, as seen by the visors connected to the hypervisor.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is a visor of the network.
+type TopologyNode struct {
+	PK      cipher.PubKey `json:"pk"`
+	Name    string        `json:"name,omitempty"`
+	Managed bool          `json:"managed"` // Whether the visor is connected to the hypervisor.
+	Online  bool          `json:"online"`  // Whether the visor responded (always false for unmanaged visors).
+}
+
+// TopologyEdge is a transport between two visors.
+type TopologyEdge struct {
+	ID      uuid.UUID        `json:"id"`
+	Edges   [2]cipher.PubKey `json:"edges"` // Sorted, as in transport entries.
+	Type    string           `json:"type"`
+	Public  bool             `json:"public"`
+	IsSetup bool             `json:"is_setup"`
+}
+
+// topology builds the network graph from transports of the connected visors.
+// Transports reported by both of their edges are included once.
+func (hv *Hypervisor) topology() (*Topology, error) {
+	names, err := hv.names.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	hv.mu.RLock()
+	conns := make(map[cipher.PubKey]VisorConn, len(hv.visors))
+	for pk, c := range hv.visors {
+		conns[pk] = c
+	}
+	hv.mu.RUnlock()
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		online     = make(map[cipher.PubKey]bool, len(conns))
+		transports = make(map[uuid.UUID]*visor.TransportSummary)
+	)
+
+	wg.Add(len(conns))
+
+	for pk, c := range conns {
+		go func(pk cipher.PubKey, c VisorConn) {
+			defer wg.Done()
+
+			tps, err := c.RPC.Transports(nil, nil, false)
+			if err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Warn("Failed to obtain transports.")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			online[pk] = err == nil
+
+			for _, tp := range tps {
+				transports[tp.ID] = tp
+			}
+		}(pk, c)
+	}
+
+	wg.Wait()
+
+	t := &Topology{
+		Nodes: make([]TopologyNode, 0, len(conns)),
+		Edges: make([]TopologyEdge, 0, len(transports)),
+	}
+
+	nodes := make(map[cipher.PubKey]struct{}, len(conns))
+	addNode := func(pk cipher.PubKey) {
+		if _, ok := nodes[pk]; ok {
+			return
+		}
+
+		nodes[pk] = struct{}{}
+
+		_, managed := conns[pk]
+		t.Nodes = append(t.Nodes, TopologyNode{PK: pk, Name: names[pk], Managed: managed, Online: online[pk]})
+	}
+
+	for pk := range conns {
+		addNode(pk)
+	}
+
+	for _, tp := range transports {
+		addNode(tp.Local)
+		addNode(tp.Remote)
+
+		t.Edges = append(t.Edges, TopologyEdge{
+			ID:      tp.ID,
+			Edges:   transport.SortEdges(tp.Local, tp.Remote),
+			Type:    tp.Type,
+			Public:  tp.Public,
+			IsSetup: tp.IsSetup,
+		})
+	}
+
+	sort.Slice(t.Nodes, func(i, j int) bool {
+		return bytes.Compare(t.Nodes[i].PK[:], t.Nodes[j].PK[:]) < 0
+	})
+
+	sort.Slice(t.Edges, func(i, j int) bool {
+		return bytes.Compare(t.Edges[i].ID[:], t.Edges[j].ID[:]) < 0
+	})
+
+	return t, nil
+}
+
+func (hv *Hypervisor) getTopology() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := hv.topology()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, t)
+	}
+}
@@ -0,0 +1,81 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+func TestTopology(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+
+	hv.mu.RLock()
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+	hv.mu.RUnlock()
+
+	require.True(t, len(pks) >= 2)
+	require.NoError(t, hv.names.SetName(pks[0], "rpi-1"))
+
+	// Both edges report the same transport.
+	connA, _ := hv.visorConn(pks[0])
+	_, err := connA.RPC.AddTransport(pks[1], "dmsg", true, 0)
+	require.NoError(t, err)
+
+	connB, _ := hv.visorConn(pks[1])
+	_, err = connB.RPC.AddTransport(pks[0], "dmsg", true, 0)
+	require.NoError(t, err)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     "/api/v1/topology",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var topology Topology
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&topology))
+
+				nodes := make(map[cipher.PubKey]TopologyNode)
+				for _, n := range topology.Nodes {
+					nodes[n.PK] = n
+				}
+
+				require.Len(t, nodes, len(topology.Nodes))
+
+				for _, pk := range pks {
+					assert.True(t, nodes[pk].Managed)
+					assert.True(t, nodes[pk].Online)
+				}
+
+				assert.Equal(t, "rpi-1", nodes[pks[0]].Name)
+
+				tid := transport.MakeTransportID(pks[0], pks[1], "dmsg")
+				found := 0
+
+				for _, e := range topology.Edges {
+					_, ok1 := nodes[e.Edges[0]]
+					_, ok2 := nodes[e.Edges[1]]
+					assert.True(t, ok1 && ok2)
+
+					if e.ID == tid {
+						found++
+						assert.Equal(t, transport.SortEdges(pks[0], pks[1]), e.Edges)
+						assert.True(t, e.Public)
+					}
+				}
+
+				assert.Equal(t, 1, found)
+			},
+		},
+	})
+}
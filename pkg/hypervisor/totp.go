@@ -0,0 +1,81 @@
+package hypervisor
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec // SHA1 is what authenticator apps expect.
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+)
+
+// TOTP (RFC 6238) parameters, as supported by common authenticator apps.
+const (
+	totpIssuer    = "Skywire Hypervisor"
+	totpSecretLen = 20
+	totpDigits    = 6
+	totpPeriod    = 30 * time.Second
+	totpSkew      = 1 // Number of periods before and after the current one that are also accepted.
+)
+
+func newTOTPSecret() []byte {
+	return cipher.RandByte(totpSecretLen)
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:]) // nolint:errcheck
+
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000)
+}
+
+// checkTOTP checks the code against the secret at time t.
+// It returns the matching step, which should be recorded to prevent reuse of the code.
+func checkTOTP(secret []byte, code string, t time.Time) (int64, bool) {
+	now := totpStep(t)
+
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func totpEncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// totpURI returns the provisioning URI, which authenticator apps accept in the form of a QR code.
+func totpURI(account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", totpEncodeSecret(secret))
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
@@ -0,0 +1,47 @@
+package hypervisor
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	// Test vectors of RFC 6238, truncated to 6 digits.
+	secret := []byte("12345678901234567890")
+
+	assert.Equal(t, "287082", totpCode(secret, totpStep(time.Unix(59, 0))))
+	assert.Equal(t, "081804", totpCode(secret, totpStep(time.Unix(1111111109, 0))))
+	assert.Equal(t, "050471", totpCode(secret, totpStep(time.Unix(1111111111, 0))))
+
+	now := time.Unix(1111111109, 0)
+
+	step, ok := checkTOTP(secret, "081804", now.Add(totpPeriod))
+	assert.True(t, ok)
+	assert.Equal(t, totpStep(now), step)
+
+	_, ok = checkTOTP(secret, "081804", now.Add(3*totpPeriod))
+	assert.False(t, ok)
+}
+
+func TestUser_VerifyTOTP(t *testing.T) {
+	user := User{TOTPSecret: newTOTPSecret()}
+	code := totpCode(user.TOTPSecret, totpStep(time.Now()))
+
+	assert.True(t, user.VerifyTOTP(code))
+	assert.False(t, user.VerifyTOTP(code), "codes should not be reusable")
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(totpURI("admin", []byte("12345678901234567890")))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.True(t, strings.HasSuffix(u.Path, ":admin"))
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", u.Query().Get("secret"))
+}
@@ -0,0 +1,58 @@
+package hypervisor
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// getTransportMaintenance returns the transport targets of a visor, with the transports established for them.
+func (hv *Hypervisor) getTransportMaintenance() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		status, err := ctx.RPC.TransportMaintenance()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, status)
+	})
+}
+
+// putTransportMaintenance replaces the transport targets of a visor, which the visor maintains from then on.
+func (hv *Hypervisor) putTransportMaintenance() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var targets []visor.TransportTarget
+
+		if err := httputil.ReadJSON(r, &targets); err != nil {
+			if err != io.EOF {
+				log.Warnf("putTransportMaintenance request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := visor.ValidateTransportTargets(targets); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := ctx.RPC.SetTransportTargets(targets); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		status, err := ctx.RPC.TransportMaintenance()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, status)
+	})
+}
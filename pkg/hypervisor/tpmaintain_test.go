@@ -0,0 +1,65 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestTransportMaintenance(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk string
+	for visorPK := range hv.visors {
+		pk = visorPK.Hex()
+	}
+
+	uri := "/api/v1/visors/" + pk + "/transport-maintenance"
+
+	statusResp := func(types ...string) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var s visor.TransportMaintenanceStatus
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+			require.Len(t, s.Targets, len(types))
+
+			for i, tpType := range types {
+				assert.Equal(t, tpType, s.Targets[i].Type)
+			}
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp(),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`[{"type":"stcp","count":3},{"type":"dmsg","count":2}]`),
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp("stcp", "dmsg"),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`[{"type":"stcp","count":0}]`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp("stcp", "dmsg"),
+		},
+	})
+}
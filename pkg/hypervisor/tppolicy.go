@@ -0,0 +1,60 @@
+package hypervisor
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// getTransportPolicies returns the transport policies of a visor, which are active and which transports they suspended.
+func (hv *Hypervisor) getTransportPolicies() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		status, err := ctx.RPC.TransportPolicies()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, status)
+	})
+}
+
+// putTransportPolicies replaces the transport policies of a visor, which the visor enforces from then on.
+func (hv *Hypervisor) putTransportPolicies() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		var policies []visor.TransportPolicy
+
+		if err := httputil.ReadJSON(r, &policies); err != nil {
+			if err != io.EOF {
+				log.Warnf("putTransportPolicies request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := visor.ValidateTransportPolicies(policies); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := ctx.RPC.SetTransportPolicies(policies); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		status, err := ctx.RPC.TransportPolicies()
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		hv.routes.invalidate(ctx.Addr.PK)
+
+		httputil.WriteJSON(w, r, http.StatusOK, status)
+	})
+}
@@ -0,0 +1,63 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestTransportPolicies(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk string
+	for visorPK := range hv.visors {
+		pk = visorPK.Hex()
+	}
+
+	uri := "/api/v1/visors/" + pk + "/transport-policies"
+	alwaysOn := `[{"name":"always","types":["stcp"],"windows":[{"start":"00:00","end":"23:59"},{"start":"23:59","end":"00:00"}]}]`
+
+	statusResp := func(policies int, active []string) func(t *testing.T, r *http.Response) {
+		return func(t *testing.T, r *http.Response) {
+			var s visor.TransportPolicyStatus
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+			assert.Len(t, s.Policies, policies)
+			assert.Equal(t, active, s.Active)
+		}
+	}
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp(0, []string{}),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(alwaysOn),
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp(1, []string{"always"}),
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(`[{"name":"bad","windows":[{"start":"25:00","end":"06:00"}]}]`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+			RespBody:   statusResp(1, []string{"always"}),
+		},
+	})
+}
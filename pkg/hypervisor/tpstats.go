@@ -0,0 +1,290 @@
+package hypervisor
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+const (
+	tpStatsInterval      = 30 * time.Second
+	tpStatsCapacity      = int(24 * time.Hour / tpStatsInterval) // samples kept per transport
+	defaultTpStatsWindow = time.Hour
+)
+
+// ErrNoTransportStats occurs when no samples were recorded for a transport.
+var ErrNoTransportStats = errors.New("no stats recorded for transport")
+
+// TransportStatsPoint is the throughput of a transport between two consecutive samples.
+type TransportStatsPoint struct {
+	Time    time.Time `json:"time"`
+	Sent    uint64    `json:"sent"`     // Total sent bytes at Time.
+	Recv    uint64    `json:"recv"`     // Total received bytes at Time.
+	SentBps float64   `json:"sent_bps"` // Bytes per second sent since the previous sample.
+	RecvBps float64   `json:"recv_bps"` // Bytes per second received since the previous sample.
+}
+
+// TransportStats is a throughput series of a transport.
+type TransportStats struct {
+	ID       uuid.UUID             `json:"id"`
+	Window   string                `json:"window"`
+	Interval string                `json:"interval"`
+	Points   []TransportStatsPoint `json:"points"`
+}
+
+type tpSample struct {
+	t          time.Time
+	sent, recv uint64
+}
+
+// tpRing is a fixed size ring buffer of transport samples.
+type tpRing struct {
+	samples []tpSample
+	next    int
+	full    bool
+}
+
+func (r *tpRing) add(s tpSample) {
+	if r.samples == nil {
+		r.samples = make([]tpSample, tpStatsCapacity)
+	}
+
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns samples newer than t, oldest first.
+func (r *tpRing) since(t time.Time) []tpSample {
+	var ordered []tpSample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+
+	ordered = append(ordered, r.samples[:r.next]...)
+
+	for i, s := range ordered {
+		if s.t.After(t) {
+			return ordered[i:]
+		}
+	}
+
+	return nil
+}
+
+type tpStatsKey struct {
+	pk  cipher.PubKey
+	tid uuid.UUID
+}
+
+// tpStats keeps recent byte counter samples of transports of the connected visors.
+type tpStats struct {
+	mu     sync.Mutex
+	rings  map[tpStatsKey]*tpRing
+	hits   uint64 // series of sampled transports
+	misses uint64 // series of transports without samples
+}
+
+func newTpStats() *tpStats {
+	return &tpStats{rings: make(map[tpStatsKey]*tpRing)}
+}
+
+// record adds samples of the visor's transports. Transports the visor no longer reports are dropped.
+func (s *tpStats) record(pk cipher.PubKey, tps []*visor.TransportSummary, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[uuid.UUID]struct{}, len(tps))
+
+	for _, tp := range tps {
+		if tp.Log == nil {
+			continue
+		}
+
+		seen[tp.ID] = struct{}{}
+
+		key := tpStatsKey{pk: pk, tid: tp.ID}
+		ring, ok := s.rings[key]
+		if !ok {
+			ring = new(tpRing)
+			s.rings[key] = ring
+		}
+
+		ring.add(tpSample{t: now, sent: tp.Log.SentBytes, recv: tp.Log.RecvBytes})
+	}
+
+	for key := range s.rings {
+		if _, ok := seen[key.tid]; key.pk == pk && !ok {
+			delete(s.rings, key)
+		}
+	}
+}
+
+// series returns the throughput series of a transport over the window ending at now.
+func (s *tpStats) series(pk cipher.PubKey, tid uuid.UUID, window time.Duration, now time.Time) ([]TransportStatsPoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, ok := s.rings[tpStatsKey{pk: pk, tid: tid}]
+	if !ok {
+		s.misses++
+		return nil, false
+	}
+
+	s.hits++
+
+	samples := ring.since(now.Add(-window))
+	points := make([]TransportStatsPoint, 0, len(samples))
+
+	for i, cur := range samples {
+		p := TransportStatsPoint{Time: cur.t, Sent: cur.sent, Recv: cur.recv}
+
+		if i > 0 {
+			prev := samples[i-1]
+			if secs := cur.t.Sub(prev.t).Seconds(); secs > 0 {
+				p.SentBps = float64(counterDelta(prev.sent, cur.sent)) / secs
+				p.RecvBps = float64(counterDelta(prev.recv, cur.recv)) / secs
+			}
+		}
+
+		points = append(points, p)
+	}
+
+	return points, true
+}
+
+// bandwidthRollup is the traffic of a transport within an hour.
+type bandwidthRollup struct {
+	key        tpStatsKey
+	hour       time.Time
+	sent, recv uint64
+	secs       float64 // time covered by the samples
+}
+
+// rollups sums the traffic between consecutive samples of each transport per hour.
+func (s *tpStats) rollups() []bandwidthRollup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []bandwidthRollup
+
+	for key, ring := range s.rings {
+		samples := ring.since(time.Time{})
+
+		for i := 1; i < len(samples); i++ {
+			prev, cur := samples[i-1], samples[i]
+			hour := cur.t.UTC().Truncate(time.Hour)
+
+			if n := len(out); n == 0 || out[n-1].key != key || !out[n-1].hour.Equal(hour) {
+				out = append(out, bandwidthRollup{key: key, hour: hour})
+			}
+
+			r := &out[len(out)-1]
+			r.sent += counterDelta(prev.sent, cur.sent)
+			r.recv += counterDelta(prev.recv, cur.recv)
+			r.secs += cur.t.Sub(prev.t).Seconds()
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].key != out[j].key {
+			if out[i].key.pk != out[j].key.pk {
+				return out[i].key.pk.Hex() < out[j].key.pk.Hex()
+			}
+
+			return out[i].key.tid.String() < out[j].key.tid.String()
+		}
+
+		return out[i].hour.Before(out[j].hour)
+	})
+
+	return out
+}
+
+// counterDelta returns the increase of a byte counter, treating a decrease as a reset.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+
+	return cur - prev
+}
+
+// recordTransportStats periodically samples byte counters of transports of the connected visors.
+func (hv *Hypervisor) recordTransportStats() {
+	hv.every(tpStatsInterval, hv.sampleTransports)
+}
+
+func (hv *Hypervisor) sampleTransports(now time.Time) {
+	hv.mu.RLock()
+	conns := make(map[cipher.PubKey]VisorConn, len(hv.visors))
+	for pk, c := range hv.visors {
+		conns[pk] = c
+	}
+	hv.mu.RUnlock()
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(conns))
+
+	for pk, c := range conns {
+		go func(pk cipher.PubKey, c VisorConn) {
+			defer wg.Done()
+
+			tps, err := c.RPC.Transports(nil, nil, true)
+			if err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Debug("Failed to sample transports.")
+				return
+			}
+
+			hv.tpStats.record(pk, tps, now)
+		}(pk, c)
+	}
+
+	wg.Wait()
+}
+
+// getTransportStats returns the throughput series of a transport.
+// Query value 'window' (e.g. '15m', '1h') limits the series, defaults to 1h and is at most 24h.
+func (hv *Hypervisor) getTransportStats() http.HandlerFunc {
+	return hv.withCtx(hv.visorCtx, func(w http.ResponseWriter, r *http.Request, ctx *httpCtx) {
+		tid, err := uuidFromParam(r, "tid")
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		window := defaultTpStatsWindow
+		if v := r.URL.Query().Get("window"); v != "" {
+			if window, err = time.ParseDuration(v); err != nil || window <= 0 ||
+				window > time.Duration(tpStatsCapacity)*tpStatsInterval {
+				httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+				return
+			}
+		}
+
+		points, ok := hv.tpStats.series(ctx.Addr.PK, tid, window, time.Now())
+		if !ok {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrNoTransportStats)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, TransportStats{
+			ID:       tid,
+			Window:   window.String(),
+			Interval: tpStatsInterval.String(),
+			Points:   points,
+		})
+	})
+}
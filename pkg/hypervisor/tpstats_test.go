@@ -0,0 +1,145 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/transport"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestTpStats(t *testing.T) {
+	s := newTpStats()
+	pk, _ := cipher.GenerateKeyPair()
+	id1, id2 := uuid.New(), uuid.New()
+	start := time.Now().Add(-time.Hour)
+
+	summaries := func(sent, recv uint64, ids ...uuid.UUID) []*visor.TransportSummary {
+		var tps []*visor.TransportSummary
+		for _, id := range ids {
+			tps = append(tps, &visor.TransportSummary{ID: id, Log: &transport.LogEntry{SentBytes: sent, RecvBytes: recv}})
+		}
+		return tps
+	}
+
+	s.record(pk, summaries(0, 0, id1, id2), start)
+	s.record(pk, summaries(3000, 300, id1, id2), start.Add(30*time.Second))
+	s.record(pk, summaries(600, 60, id1), start.Add(time.Minute)) // counters were reset, id2 is gone
+
+	points, ok := s.series(pk, id1, 2*time.Hour, time.Now())
+	require.True(t, ok)
+	require.Len(t, points, 3)
+	assert.Zero(t, points[0].SentBps)
+	assert.Equal(t, 100.0, points[1].SentBps)
+	assert.Equal(t, 10.0, points[1].RecvBps)
+	assert.Equal(t, 20.0, points[2].SentBps)
+	assert.Equal(t, 2.0, points[2].RecvBps)
+
+	points, ok = s.series(pk, id1, time.Hour-45*time.Second, time.Now())
+	require.True(t, ok)
+	assert.Len(t, points, 1)
+
+	_, ok = s.series(pk, id2, time.Hour, time.Now())
+	assert.False(t, ok)
+}
+
+func TestTpStatsRollups(t *testing.T) {
+	s := newTpStats()
+	pk, _ := cipher.GenerateKeyPair()
+	id := uuid.New()
+	hour := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tps := func(sent, recv uint64) []*visor.TransportSummary {
+		return []*visor.TransportSummary{{ID: id, Log: &transport.LogEntry{SentBytes: sent, RecvBytes: recv}}}
+	}
+
+	s.record(pk, tps(0, 0), hour.Add(-30*time.Second))
+	s.record(pk, tps(100, 10), hour.Add(30*time.Second))
+	s.record(pk, tps(400, 40), hour.Add(time.Hour-30*time.Second))
+	s.record(pk, tps(50, 5), hour.Add(time.Hour+30*time.Second)) // counters were reset
+
+	rollups := s.rollups()
+	require.Len(t, rollups, 2)
+	assert.Equal(t, hour, rollups[0].hour)
+	assert.Equal(t, uint64(400), rollups[0].sent)
+	assert.Equal(t, uint64(40), rollups[0].recv)
+	assert.Equal(t, 3600.0, rollups[0].secs)
+	assert.Equal(t, hour.Add(time.Hour), rollups[1].hour)
+	assert.Equal(t, uint64(50), rollups[1].sent)
+}
+
+func TestTpRingWraps(t *testing.T) {
+	var r tpRing
+	start := time.Now()
+
+	for i := 0; i < tpStatsCapacity+10; i++ {
+		r.add(tpSample{t: start.Add(time.Duration(i) * time.Second), sent: uint64(i)})
+	}
+
+	samples := r.since(time.Time{})
+	require.Len(t, samples, tpStatsCapacity)
+	assert.Equal(t, uint64(10), samples[0].sent)
+	assert.Equal(t, uint64(tpStatsCapacity+9), samples[len(samples)-1].sent)
+}
+
+func TestGetTransportStats(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var (
+		pk  cipher.PubKey
+		tps []*visor.TransportSummary
+	)
+	for pk = range hv.visors {
+		var err error
+		tps, err = hv.visors[pk].RPC.Transports(nil, nil, true)
+		require.NoError(t, err)
+
+		if len(tps) > 0 {
+			break
+		}
+	}
+
+	if len(tps) == 0 {
+		t.Skip("mock visors have no transports")
+	}
+
+	now := time.Now()
+	hv.sampleTransports(now.Add(-time.Minute))
+	hv.sampleTransports(now)
+
+	uri := fmt.Sprintf("/api/v1/visors/%s/transports/%s/stats", pk, tps[0].ID)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri + "?window=1h",
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var stats TransportStats
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&stats))
+				assert.Equal(t, tps[0].ID, stats.ID)
+				assert.Equal(t, "1h0m0s", stats.Window)
+				assert.Len(t, stats.Points, 2)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri + "?window=48h",
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports/%s/stats", pk, uuid.New()),
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package hypervisor
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// RequestIDHeader holds the ID of a request. It is taken from the request if valid, and set on the response.
+const RequestIDHeader = "X-Request-ID"
+
+const traceKey = ctxKey("trace")
+
+// Request IDs taken from clients are limited, as they end up in logs.
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`) // nolint: gochecknoglobals
+
+// requestTrace is shared between the request log middleware and the handlers,
+// which fill in the user and visor of the request as they become known.
+type requestTrace struct {
+	ID      string
+	User    string
+	VisorPK cipher.PubKey
+}
+
+// traceFromRequest returns the trace of the request, or nil if it is not traced.
+func traceFromRequest(r *http.Request) *requestTrace {
+	t, _ := r.Context().Value(traceKey).(*requestTrace) // nolint: errcheck
+	return t
+}
+
+// tracedRPCClient is implemented by RPC clients which can send request IDs to visors.
+type tracedRPCClient interface {
+	WithRequestID(requestID string) visor.RPCClient
+}
+
+// tracedRPC returns the RPC client sending the request ID of r along with calls, if possible.
+func tracedRPC(r *http.Request, rpcC visor.RPCClient) visor.RPCClient {
+	t := traceFromRequest(r)
+	if t == nil {
+		return rpcC
+	}
+
+	if tc, ok := rpcC.(tracedRPCClient); ok {
+		return tc.WithRequestID(t.ID)
+	}
+
+	return rpcC
+}
+
+// newRequestLogger returns a middleware logging a JSON line for each request.
+// The request ID is passed on to visors in RPC calls made by the handlers, and logged there too.
+func newRequestLogger() func(next http.Handler) http.Handler {
+	reqLog := logrus.New()
+	reqLog.SetOutput(os.Stdout)
+	reqLog.SetFormatter(&logrus.JSONFormatter{})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &requestTrace{ID: r.Header.Get(RequestIDHeader)}
+			if !requestIDRegexp.MatchString(t.ID) {
+				t.ID = uuid.New().String()
+			}
+
+			w.Header().Set(RequestIDHeader, t.ID)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), traceKey, t)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			fields := logrus.Fields{
+				"request_id":  t.ID,
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"remote_addr": remoteIP(r),
+				"status":      status,
+				"bytes":       ww.BytesWritten(),
+				"latency_ms":  durationMs(time.Since(start)),
+			}
+
+			if t.User != "" {
+				fields["user"] = t.User
+			}
+
+			if !t.VisorPK.Null() {
+				fields["visor_pk"] = t.VisorPK
+			}
+
+			reqLog.WithFields(fields).Info("Request served.")
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package hypervisor
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDHeader(t *testing.T) {
+	addr, client, _, stop := makeStartMockNode(t)
+	defer stop()
+
+	requestID := func(id string) (string, int) {
+		req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/api/v1/visors", nil)
+		require.NoError(t, err)
+
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp.Header.Get(RequestIDHeader), resp.StatusCode
+	}
+
+	id, status := requestID("client-id.1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "client-id.1", id)
+
+	// Missing and invalid request IDs are replaced.
+	id, _ = requestID("")
+	assert.Len(t, id, 36)
+
+	id, _ = requestID("bad id")
+	assert.Len(t, id, 36)
+}
@@ -0,0 +1,231 @@
+package hypervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Errors associated with transport creation.
+var (
+	ErrUnsupportedTransportType = errors.New("transport type is not supported by the visor")
+	ErrBadTransportRetry        = errors.New("retry attempts should be between 1 and 10, and backoff not negative")
+)
+
+const (
+	transportCreateTimeout  = 30 * time.Second // of each attempt
+	maxTransportAttempts    = 10               // per transport type
+	defaultTransportBackoff = time.Second
+)
+
+// TransportRetry is the retry policy of transport creation.
+type TransportRetry struct {
+	Attempts int            `json:"attempts,omitempty"` // Per transport type, defaults to 1.
+	Backoff  visor.Duration `json:"backoff,omitempty"`  // Between attempts, defaults to 1s.
+}
+
+func (rp *TransportRetry) fill() error {
+	if rp.Attempts == 0 {
+		rp.Attempts = 1
+	}
+
+	if rp.Backoff == 0 {
+		rp.Backoff = visor.Duration(defaultTransportBackoff)
+	}
+
+	if rp.Attempts < 1 || rp.Attempts > maxTransportAttempts || rp.Backoff < 0 {
+		return ErrBadTransportRetry
+	}
+
+	return nil
+}
+
+// budget returns how long trying types with the retry policy may take.
+func (rp TransportRetry) budget(types int) time.Duration {
+	attempts := time.Duration(rp.Attempts * types)
+	return attempts*transportCreateTimeout + (attempts-1)*time.Duration(rp.Backoff)
+}
+
+// TransportAttempt is an attempt at creating a transport.
+type TransportAttempt struct {
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"` // Empty if the attempt succeeded.
+}
+
+// addTransport creates a transport to remote, trying the types in order, each up to the attempts of the retry policy.
+// It returns the created transport, if any, and the attempts made.
+func addTransport(ctx context.Context, rpc visor.RPCClient, remote cipher.PubKey, types []string, public bool,
+	rp TransportRetry) (*visor.TransportSummary, []TransportAttempt, error) {
+	var (
+		attempts []TransportAttempt
+		lastErr  error
+	)
+
+	for _, tpType := range types {
+		for i := 0; i < rp.Attempts; i++ {
+			if len(attempts) > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, attempts, visor.ErrRPCTimeout
+				case <-time.After(time.Duration(rp.Backoff)):
+				}
+			}
+
+			summary, err := rpc.AddTransport(remote, tpType, public, transportCreateTimeout)
+			if err == nil {
+				return summary, append(attempts, TransportAttempt{Type: tpType}), nil
+			}
+
+			attempts = append(attempts, TransportAttempt{Type: tpType, Error: err.Error()})
+			lastErr = err
+		}
+	}
+
+	return nil, attempts, lastErr
+}
+
+// transportTypeInfos returns the transport types supported by a visor, along with their capabilities.
+// Visors which predate capability reporting have their types returned with unknown capabilities.
+func transportTypeInfos(rpc visor.RPCClient) ([]visor.TransportTypeInfo, error) {
+	infos, err := rpc.TransportTypeInfos()
+	if err == nil || !strings.Contains(err.Error(), "can't find method") {
+		return infos, err
+	}
+
+	types, err := rpc.TransportTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	infos = make([]visor.TransportTypeInfo, 0, len(types))
+	for _, t := range types {
+		infos = append(infos, visor.TransportTypeInfo{Type: t})
+	}
+
+	return infos, nil
+}
+
+func supportsTransportType(infos []visor.TransportTypeInfo, tpType string) bool {
+	for _, info := range infos {
+		if info.Type == tpType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Values of the 'sort' query of transports.
+const (
+	sortTransportsByBytes   = "bytes"   // Most bytes transferred first.
+	sortTransportsByCreated = "created" // Newest first.
+	sortTransportsByType    = "type"    // Alphabetically by type.
+)
+
+// transportsQuery filters and sorts transports by activity recorded in transport logs.
+type transportsQuery struct {
+	activeSince time.Time
+	minBytes    uint64
+	sort        string
+}
+
+// transportsQueryFromRequest parses the 'active_since', 'min_bytes' and 'sort' query values.
+// 'active_since' is either an RFC3339 timestamp or a duration relative to now (such as '1h').
+func transportsQueryFromRequest(r *http.Request) (transportsQuery, error) {
+	var q transportsQuery
+
+	if v := r.URL.Query().Get("active_since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			d, dErr := time.ParseDuration(v)
+			if dErr != nil {
+				return q, fmt.Errorf("invalid 'active_since' query value of '%s'", v)
+			}
+
+			t = time.Now().Add(-d)
+		}
+
+		q.activeSince = t
+	}
+
+	if v := r.URL.Query().Get("min_bytes"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("invalid 'min_bytes' query value of '%s'", v)
+		}
+
+		q.minBytes = n
+	}
+
+	switch q.sort = r.URL.Query().Get("sort"); q.sort {
+	case "", sortTransportsByBytes, sortTransportsByCreated, sortTransportsByType:
+	default:
+		return q, fmt.Errorf("invalid 'sort' query value of '%s'", q.sort)
+	}
+
+	return q, nil
+}
+
+// needsLogs returns true if transport logs are required to apply the query.
+func (q transportsQuery) needsLogs() bool {
+	return !q.activeSince.IsZero() || q.minBytes > 0 ||
+		q.sort == sortTransportsByBytes || q.sort == sortTransportsByCreated
+}
+
+func (q transportsQuery) apply(tps []*visor.TransportSummary) []*visor.TransportSummary {
+	out := make([]*visor.TransportSummary, 0, len(tps))
+
+	for _, tp := range tps {
+		if !q.activeSince.IsZero() && (tp.Log == nil || tp.Log.LastActivity < q.activeSince.Unix()) {
+			continue
+		}
+
+		if q.minBytes > 0 && (tp.Log == nil || tp.Log.TotalBytes() < q.minBytes) {
+			continue
+		}
+
+		out = append(out, tp)
+	}
+
+	var less func(a, b *visor.TransportSummary) bool
+
+	switch q.sort {
+	case sortTransportsByBytes:
+		less = func(a, b *visor.TransportSummary) bool { return logBytes(a) > logBytes(b) }
+	case sortTransportsByCreated:
+		less = func(a, b *visor.TransportSummary) bool { return logCreatedAt(a) > logCreatedAt(b) }
+	case sortTransportsByType:
+		less = func(a, b *visor.TransportSummary) bool { return a.Type < b.Type }
+	default:
+		return out
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return less(out[i], out[j]) })
+
+	return out
+}
+
+func logBytes(tp *visor.TransportSummary) uint64 {
+	if tp.Log == nil {
+		return 0
+	}
+
+	return tp.Log.TotalBytes()
+}
+
+func logCreatedAt(tp *visor.TransportSummary) int64 {
+	if tp.Log == nil {
+		return 0
+	}
+
+	return tp.Log.CreatedAt
+}
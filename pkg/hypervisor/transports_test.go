@@ -0,0 +1,290 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/transport"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestTransportsQuery(t *testing.T) {
+	now := time.Now().Unix()
+
+	tps := []*visor.TransportSummary{
+		{Type: "stcp", Log: &transport.LogEntry{RecvBytes: 10, CreatedAt: now - 300, LastActivity: now - 200}},
+		{Type: "dmsg", Log: &transport.LogEntry{RecvBytes: 500, SentBytes: 500, CreatedAt: now - 100, LastActivity: now}},
+		{Type: "dmsg", Log: &transport.LogEntry{SentBytes: 100, CreatedAt: now - 200, LastActivity: now - 10}},
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		q, err := transportsQueryFromRequest(httptest.NewRequest("GET", "/?active_since=1m&min_bytes=100", nil))
+		require.NoError(t, err)
+		assert.True(t, q.needsLogs())
+
+		out := q.apply(tps)
+		assert.Equal(t, []*visor.TransportSummary{tps[1], tps[2]}, out)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		q, err := transportsQueryFromRequest(httptest.NewRequest("GET", "/?sort=bytes", nil))
+		require.NoError(t, err)
+		assert.Equal(t, []*visor.TransportSummary{tps[1], tps[2], tps[0]}, q.apply(tps))
+
+		q, err = transportsQueryFromRequest(httptest.NewRequest("GET", "/?sort=created", nil))
+		require.NoError(t, err)
+		assert.Equal(t, []*visor.TransportSummary{tps[1], tps[2], tps[0]}, q.apply(tps))
+
+		q, err = transportsQueryFromRequest(httptest.NewRequest("GET", "/?sort=type", nil))
+		require.NoError(t, err)
+		assert.False(t, q.needsLogs())
+		assert.Equal(t, []*visor.TransportSummary{tps[1], tps[2], tps[0]}, q.apply(tps))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, query := range []string{"active_since=yesterday", "min_bytes=-1", "sort=size"} {
+			_, err := transportsQueryFromRequest(httptest.NewRequest("GET", "/?"+query, nil))
+			assert.Error(t, err, query)
+		}
+	})
+}
+
+// legacyRPCClient mimics a visor which does not report transport type capabilities.
+type legacyRPCClient struct {
+	visor.RPCClient
+}
+
+func (legacyRPCClient) TransportTypeInfos() ([]visor.TransportTypeInfo, error) {
+	return nil, errors.New("rpc: can't find method RPC.TransportTypeInfos")
+}
+
+func TestTransportTypes(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pks []cipher.PubKey
+	for pk := range hv.visors {
+		pks = append(pks, pk)
+	}
+
+	hv.mu.Lock()
+	c := hv.visors[pks[1]]
+	c.RPC = legacyRPCClient{RPCClient: c.RPC}
+	hv.visors[pks[1]] = c
+	hv.mu.Unlock()
+
+	remote, _ := cipher.GenerateKeyPair()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transport-types", pks[0]),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var types []string
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&types))
+				assert.Equal(t, []string{"messaging", "native"}, types)
+			},
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transport-types?capabilities=true", pks[1]),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var infos []visor.TransportTypeInfo
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&infos))
+				require.Len(t, infos, 2)
+				assert.Equal(t, "messaging", infos[0].Type)
+				assert.False(t, infos[0].Known)
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports", pks[0]),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_type":"quic","remote_pk":%q}`, remote)),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports", pks[0]),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_type":"native","remote_pk":%q}`, remote)),
+			RespStatus: http.StatusOK,
+		},
+	})
+}
+
+func TestBulkRemovals(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	rpc := hv.visors[pk].RPC
+
+	remote, _ := cipher.GenerateKeyPair()
+	_, err := rpc.AddTransport(remote, "stcp", false, time.Second)
+	require.NoError(t, err)
+
+	tid := uuid.New()
+	fwd := routing.ForwardRule(time.Hour, 0xfff0, 1, tid, pk, remote, 0, 0)
+	require.NoError(t, rpc.SaveRoutingRule(fwd))
+
+	countTransports := func(types []string) int {
+		tps, err := rpc.Transports(types, nil, false)
+		require.NoError(t, err)
+		return len(tps)
+	}
+
+	countRules := func(typ routing.RuleType) int {
+		rules, err := rpc.RoutingRules()
+		require.NoError(t, err)
+
+		n := 0
+		for _, rule := range rules {
+			if rule.Type() == typ {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	stcpTps, allTps := countTransports([]string{"stcp"}), countTransports(nil)
+	consumeRules := countRules(routing.RuleConsume)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/routes?expired=true&type=forward", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/routes?transport=abc", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/routes?transport=%s", pk, tid),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp RemovedRoutes
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Equal(t, []routing.RouteID{0xfff0}, resp.Removed)
+			},
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/routes?type=Forward&type=intermediaryforward", pk),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/routes?expired=true", pk),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports?type=stcp", pk),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp RemovedTransports
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Len(t, resp.Removed, stcpTps)
+			},
+		},
+	})
+
+	assert.Zero(t, countRules(routing.RuleForward))
+	assert.Equal(t, consumeRules, countRules(routing.RuleConsume))
+	assert.Zero(t, countTransports([]string{"stcp"}))
+	assert.Equal(t, allTps-stcpTps, countTransports(nil))
+}
+
+// failingTransportsRPC fails to create transports of some types.
+type failingTransportsRPC struct {
+	visor.RPCClient
+	failing map[string]bool
+}
+
+func (rpc failingTransportsRPC) AddTransport(remote cipher.PubKey, tpType string, public bool, timeout time.Duration) (*visor.TransportSummary, error) {
+	if rpc.failing[tpType] {
+		return nil, fmt.Errorf("failed to dial over %s", tpType)
+	}
+
+	return rpc.RPCClient.AddTransport(remote, tpType, public, timeout)
+}
+
+func TestPostTransportFallback(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for pk = range hv.visors {
+		break
+	}
+
+	hv.mu.Lock()
+	c := hv.visors[pk]
+	c.RPC = failingTransportsRPC{RPCClient: c.RPC, failing: map[string]bool{"native": true}}
+	hv.visors[pk] = c
+	hv.mu.Unlock()
+
+	remote, _ := cipher.GenerateKeyPair()
+	uri := fmt.Sprintf("/api/v1/visors/%s/transports", pk)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_types":["native"],"remote_pk":%q,"retry":{"attempts":11}}`, remote)),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_types":["native"],"remote_pk":%q,"retry":{"attempts":2,"backoff":"1ms"}}`, remote)),
+			RespStatus: http.StatusInternalServerError,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp postTransportErr
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Len(t, resp.Attempts, 2)
+				assert.Contains(t, resp.Error, "native")
+			},
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_types":["native","messaging"],"remote_pk":%q,"retry":{"attempts":2,"backoff":"1ms"}}`, remote)),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				var resp struct {
+					Type     string             `json:"type"`
+					Remote   cipher.PubKey      `json:"remote_pk"`
+					Attempts []TransportAttempt `json:"attempts"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.Equal(t, "messaging", resp.Type)
+				assert.Equal(t, remote, resp.Remote)
+				require.Len(t, resp.Attempts, 3)
+				assert.NotEmpty(t, resp.Attempts[1].Error)
+				assert.Equal(t, TransportAttempt{Type: "messaging"}, resp.Attempts[2])
+			},
+		},
+	})
+}
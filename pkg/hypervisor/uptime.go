@@ -0,0 +1,258 @@
+package hypervisor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	boltUptimeBucketName = "uptimes"
+	uptimeDayFormat      = "2006-01-02"
+	uptimeRecordInterval = time.Minute
+)
+
+// UptimeStore stores long-term uptime history of visors, bucketed per day.
+type UptimeStore interface {
+	AddUptime(pk cipher.PubKey, day time.Time, d time.Duration) error
+	Uptimes(from, to time.Time) (map[cipher.PubKey]time.Duration, error)
+}
+
+// BoltUptimeStore implements UptimeStore, storing uptime history in a bbolt database.
+type BoltUptimeStore struct {
+	*bbolt.DB
+}
+
+// NewBoltUptimeStore creates a new BoltUptimeStore on top of an opened bbolt database.
+func NewBoltUptimeStore(db *bbolt.DB) (*BoltUptimeStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltUptimeBucketName))
+		return err
+	})
+
+	return &BoltUptimeStore{DB: db}, err
+}
+
+// AddUptime adds d to the recorded uptime of visor of pk on the given day.
+func (s *BoltUptimeStore) AddUptime(pk cipher.PubKey, day time.Time, d time.Duration) error {
+	key := uptimeKey(pk, day)
+
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltUptimeBucketName))
+
+		var secs uint64
+		if raw := b.Get(key); len(raw) == 8 {
+			secs = binary.BigEndian.Uint64(raw)
+		}
+
+		secs += uint64(d.Seconds())
+
+		raw := make([]byte, 8)
+		binary.BigEndian.PutUint64(raw, secs)
+
+		return b.Put(key, raw)
+	})
+}
+
+// Uptimes returns the total recorded uptime per visor for days within [from, to).
+func (s *BoltUptimeStore) Uptimes(from, to time.Time) (map[cipher.PubKey]time.Duration, error) {
+	fromDay := from.UTC().Format(uptimeDayFormat)
+	toDay := to.UTC().Format(uptimeDayFormat)
+	uptimes := make(map[cipher.PubKey]time.Duration)
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltUptimeBucketName)).ForEach(func(k, v []byte) error {
+			pk, day, err := parseUptimeKey(k)
+			if err != nil {
+				return err
+			}
+
+			if day < fromDay || day >= toDay || len(v) != 8 {
+				return nil
+			}
+
+			uptimes[pk] += time.Duration(binary.BigEndian.Uint64(v)) * time.Second
+
+			return nil
+		})
+	})
+
+	return uptimes, err
+}
+
+func uptimeKey(pk cipher.PubKey, day time.Time) []byte {
+	return []byte(pk.Hex() + ":" + day.UTC().Format(uptimeDayFormat))
+}
+
+func parseUptimeKey(k []byte) (cipher.PubKey, string, error) {
+	parts := strings.SplitN(string(k), ":", 2)
+	if len(parts) != 2 {
+		return cipher.PubKey{}, "", fmt.Errorf("malformed uptime key %q", k)
+	}
+
+	var pk cipher.PubKey
+	if err := pk.UnmarshalText([]byte(parts[0])); err != nil {
+		return cipher.PubKey{}, "", err
+	}
+
+	return pk, parts[1], nil
+}
+
+// UptimeDef is a single visor's uptime entry.
+// The format matches the one served by the uptime tracker's '/uptimes' endpoint,
+// which is consumed by the reward system.
+type UptimeDef struct {
+	Key        string  `json:"key"`
+	Uptime     uint64  `json:"uptime"`
+	Downtime   uint64  `json:"downtime"`
+	Percentage float64 `json:"percentage"`
+	Online     bool    `json:"online"`
+}
+
+func makeUptimeDefs(uptimes map[cipher.PubKey]time.Duration, online map[cipher.PubKey]bool, total time.Duration) []UptimeDef {
+	defs := make([]UptimeDef, 0, len(uptimes))
+
+	for pk, uptime := range uptimes {
+		if uptime > total {
+			uptime = total
+		}
+
+		def := UptimeDef{
+			Key:      pk.Hex(),
+			Uptime:   uint64(uptime.Seconds()),
+			Downtime: uint64((total - uptime).Seconds()),
+			Online:   online[pk],
+		}
+
+		if total > 0 {
+			def.Percentage = float64(uptime) / float64(total) * 100
+		}
+
+		defs = append(defs, def)
+	}
+
+	return defs
+}
+
+// recordUptimes periodically checks which visors are reachable and records their uptime.
+func (hv *Hypervisor) recordUptimes() {
+	hv.every(uptimeRecordInterval, func(now time.Time) {
+		if hv.utTracker != nil {
+			hv.utTracker.flush(now)
+		}
+
+		for pk, ok := range hv.onlineVisors() {
+			// Visors reporting to the embedded uptime tracker are recorded by it.
+			if !ok || (hv.utTracker != nil && hv.utTracker.reporting(pk, now)) {
+				continue
+			}
+
+			if err := hv.uptimes.AddUptime(pk, now, uptimeRecordInterval); err != nil {
+				log.WithError(err).WithField("visor_pk", pk).Warn("Failed to record uptime.")
+			}
+		}
+	})
+}
+
+// onlineVisors concurrently checks whether each visor responds over RPC.
+func (hv *Hypervisor) onlineVisors() map[cipher.PubKey]bool {
+	hv.mu.RLock()
+	conns := make(map[cipher.PubKey]VisorConn, len(hv.visors))
+	for pk, c := range hv.visors {
+		conns[pk] = c
+	}
+	hv.mu.RUnlock()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		online = make(map[cipher.PubKey]bool, len(conns))
+	)
+
+	wg.Add(len(conns))
+
+	for pk, c := range conns {
+		go func(pk cipher.PubKey, c VisorConn) {
+			defer wg.Done()
+
+			errCh := make(chan error, 1)
+			start := time.Now()
+			go func() {
+				_, err := c.RPC.Uptime()
+				errCh <- err
+			}()
+
+			var ok bool
+			select {
+			case err := <-errCh:
+				if ok = err == nil; ok {
+					hv.latencies.record(pk, time.Since(start))
+				}
+			case <-time.After(healthTimeout):
+			}
+
+			mu.Lock()
+			online[pk] = ok
+			mu.Unlock()
+		}(pk, c)
+	}
+
+	wg.Wait()
+
+	return online
+}
+
+// getUptimes exports uptime history in the uptime tracker format.
+// The 'year' and 'month' query values select the period, defaulting to the current month.
+func (hv *Hypervisor) getUptimes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+
+		year, err := intFromQuery(r, "year", now.Year())
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		month, err := intFromQuery(r, "month", int(now.Month()))
+		if err != nil || month < 1 || month > 12 {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, fmt.Errorf("invalid month %q", r.URL.Query().Get("month")))
+			return
+		}
+
+		from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(0, 1, 0)
+
+		if from.After(now) {
+			httputil.WriteJSON(w, r, http.StatusOK, []UptimeDef{})
+			return
+		}
+
+		total := to.Sub(from)
+		if to.After(now) {
+			total = now.Sub(from)
+		}
+
+		uptimes, err := hv.uptimes.Uptimes(from, to)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		online := hv.onlineVisors()
+		for pk := range online {
+			if _, ok := uptimes[pk]; !ok {
+				uptimes[pk] = 0
+			}
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, makeUptimeDefs(uptimes, online, total))
+	}
+}
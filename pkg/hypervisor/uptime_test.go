@@ -0,0 +1,71 @@
+package hypervisor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltUptimeStore(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	users, err := NewBoltUserStore(filepath.Join(dir, "users.db"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, users.Close())
+	}()
+
+	s, err := NewBoltUptimeStore(users.DB)
+	require.NoError(t, err)
+
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+
+	sep := time.Date(2020, time.September, 30, 12, 0, 0, 0, time.UTC)
+	oct := time.Date(2020, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.AddUptime(pk1, sep, time.Hour))
+	require.NoError(t, s.AddUptime(pk1, sep, time.Hour))
+	require.NoError(t, s.AddUptime(pk1, oct, time.Minute))
+	require.NoError(t, s.AddUptime(pk2, oct, time.Hour))
+
+	from := time.Date(2020, time.September, 1, 0, 0, 0, 0, time.UTC)
+	uptimes, err := s.Uptimes(from, from.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, map[cipher.PubKey]time.Duration{pk1: 2 * time.Hour}, uptimes)
+
+	uptimes, err = s.Uptimes(from.AddDate(0, 1, 0), from.AddDate(0, 2, 0))
+	require.NoError(t, err)
+	assert.Equal(t, map[cipher.PubKey]time.Duration{pk1: time.Minute, pk2: time.Hour}, uptimes)
+}
+
+func TestMakeUptimeDefs(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+
+	defs := makeUptimeDefs(
+		map[cipher.PubKey]time.Duration{pk: 3 * time.Hour},
+		map[cipher.PubKey]bool{pk: true},
+		4*time.Hour,
+	)
+
+	require.Len(t, defs, 1)
+	assert.Equal(t, UptimeDef{
+		Key:        pk.Hex(),
+		Uptime:     3 * 60 * 60,
+		Downtime:   60 * 60,
+		Percentage: 75,
+		Online:     true,
+	}, defs[0])
+}
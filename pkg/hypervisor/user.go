@@ -43,6 +43,10 @@ type User struct {
 	Name   string
 	PwSalt []byte
 	PwHash cipher.SHA256
+
+	TOTPSecret        []byte // Enables two-factor authentication when set.
+	PendingTOTPSecret []byte // Secret awaiting confirmation before two-factor authentication is enabled.
+	TOTPLastStep      int64  // Time step of the last accepted code, to prevent codes being reused.
 }
 
 // SetName checks the provided name, and sets the name if format is valid.
@@ -73,6 +77,24 @@ func (u *User) VerifyPassword(password string) bool {
 	return cipher.SumSHA256(append([]byte(password), u.PwSalt...)) == u.PwHash
 }
 
+// TOTPEnabled returns true if two-factor authentication is enabled for the user.
+func (u *User) TOTPEnabled() bool {
+	return len(u.TOTPSecret) > 0
+}
+
+// VerifyTOTP verifies a TOTP code against the user's enabled secret.
+// Codes of time steps that are already used are rejected.
+func (u *User) VerifyTOTP(code string) bool {
+	step, ok := checkTOTP(u.TOTPSecret, code, time.Now())
+	if !ok || step <= u.TOTPLastStep {
+		return false
+	}
+
+	u.TOTPLastStep = step
+
+	return true
+}
+
 // Encode encodes the user to bytes.
 func (u *User) Encode() ([]byte, error) {
 	var buf bytes.Buffer
@@ -104,6 +126,7 @@ type UserStore interface {
 // BoltUserStore implements UserStore, storing users in a bbolt database file.
 type BoltUserStore struct {
 	*bbolt.DB
+	enc *dbCipher // encrypts stored users, if set
 }
 
 // NewBoltUserStore creates a new BoltUserStore.
@@ -129,9 +152,9 @@ func NewBoltUserStore(path string) (*BoltUserStore, error) {
 func (s *BoltUserStore) User(name string) (user *User, err error) {
 	err = s.View(func(tx *bbolt.Tx) error {
 		users := tx.Bucket([]byte(boltUserBucketName))
-		rawUser := users.Get([]byte(name))
-		if rawUser == nil {
-			return nil
+		rawUser, err := s.enc.open(boltUserBucketName, []byte(name), users.Get([]byte(name)))
+		if err != nil || rawUser == nil {
+			return err
 		}
 
 		user, err = DecodeUser(rawUser)
@@ -149,7 +172,7 @@ func (s *BoltUserStore) AddUser(user User) error {
 			return ErrUserExists
 		}
 
-		encoded, err := user.Encode()
+		encoded, err := s.encodeUser(user)
 		if err != nil {
 			return err
 		}
@@ -166,7 +189,7 @@ func (s *BoltUserStore) SetUser(user User) error {
 			return ErrUserNotFound
 		}
 
-		encoded, err := user.Encode()
+		encoded, err := s.encodeUser(user)
 		if err != nil {
 			return err
 		}
@@ -175,6 +198,15 @@ func (s *BoltUserStore) SetUser(user User) error {
 	})
 }
 
+func (s *BoltUserStore) encodeUser(user User) ([]byte, error) {
+	encoded, err := user.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.enc.seal(boltUserBucketName, []byte(user.Name), encoded)
+}
+
 // RemoveUser removes a user of given username.
 func (s *BoltUserStore) RemoveUser(name string) error {
 	return s.Update(func(tx *bbolt.Tx) error {
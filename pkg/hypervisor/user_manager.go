@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,6 +26,11 @@ var (
 	ErrMalformedRequest  = errors.New("request format is malformed")
 	ErrBadUsernameFormat = errors.New("format of 'username' is not accepted")
 	ErrUserNotFound      = errors.New("user is either deleted or not found")
+	ErrTOTPRequired      = errors.New("two-factor authentication code is required")
+	ErrBadTOTP           = errors.New("two-factor authentication code is incorrect")
+	ErrTOTPEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled    = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotSetup      = errors.New("two-factor authentication is not set up")
 )
 
 // for use with context.Context
@@ -40,32 +44,38 @@ const (
 
 // Session represents a user session.
 type Session struct {
-	SID    uuid.UUID `json:"sid"`
-	User   string    `json:"username"`
-	Expiry time.Time `json:"expiry"`
+	SID       uuid.UUID `json:"sid"`
+	User      string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Created   time.Time `json:"created"`
+	Expiry    time.Time `json:"expiry"`
+	Provider  string    `json:"provider,omitempty"` // Identity provider of the user, empty for local users.
+	Role      string    `json:"role,omitempty"`     // Role of the session, admin if empty.
 }
 
 // UserManager manages the users and sessions.
 type UserManager struct {
 	c        CookieConfig
 	db       UserStore
-	sessions map[uuid.UUID]Session
+	sessions SessionStore
+	limiter  *loginLimiter
 	crypto   *securecookie.SecureCookie
-	mu       *sync.RWMutex
 }
 
 // NewUserManager creates a new UserManager.
-func NewUserManager(users UserStore, config CookieConfig) *UserManager {
+func NewUserManager(users UserStore, sessions SessionStore, config CookieConfig, limits LoginLimitConfig) *UserManager {
 	return &UserManager{
 		db:       users,
 		c:        config,
-		sessions: make(map[uuid.UUID]Session),
+		sessions: sessions,
+		limiter:  newLoginLimiter(limits),
 		crypto:   securecookie.New(config.HashKey, config.BlockKey),
-		mu:       new(sync.RWMutex),
 	}
 }
 
 // Login returns a HandlerFunc for login operations.
+// Responses to login attempts carry X-RateLimit-* headers describing the failed attempts left before a lockout.
 func (s *UserManager) Login() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if _, _, ok := s.session(r); ok {
@@ -76,6 +86,7 @@ func (s *UserManager) Login() http.HandlerFunc {
 		var rb struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
+			TOTP     string `json:"totp,omitempty"` // Required if two-factor authentication is enabled.
 		}
 
 		if err := httputil.ReadJSON(r, &rb); err != nil {
@@ -93,6 +104,14 @@ func (s *UserManager) Login() http.HandlerFunc {
 			return
 		}
 
+		ip := remoteIP(r)
+
+		if wait, err := s.limiter.check(ip, rb.Username, time.Now()); err != nil {
+			s.limiter.setLimitHeaders(w, ip, rb.Username)
+			writeLoginLimited(w, r, wait, err)
+			return
+		}
+
 		user, err := s.db.User(rb.Username)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -102,13 +121,45 @@ func (s *UserManager) Login() http.HandlerFunc {
 		}
 
 		if user == nil || !user.VerifyPassword(rb.Password) {
+			s.limiter.fail(ip, rb.Username, time.Now())
+			s.limiter.setLimitHeaders(w, ip, rb.Username)
 			httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrBadLogin)
+
 			return
 		}
 
+		if user.TOTPEnabled() {
+			if rb.TOTP == "" {
+				httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrTOTPRequired)
+				return
+			}
+
+			if !user.VerifyTOTP(rb.TOTP) {
+				s.limiter.fail(ip, rb.Username, time.Now())
+				s.limiter.setLimitHeaders(w, ip, rb.Username)
+				httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrBadTOTP)
+
+				return
+			}
+
+			if err := s.db.SetUser(*user); err != nil {
+				log.WithError(err).Errorf("Failed to update user %q data", user.Name)
+				w.WriteHeader(http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		s.limiter.succeed(ip, rb.Username)
+		s.limiter.setLimitHeaders(w, ip, rb.Username)
+
+		now := time.Now()
 		session := Session{
-			User:   rb.Username,
-			Expiry: time.Now().Add(s.c.ExpiresDuration),
+			User:      rb.Username,
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+			Created:   now,
+			Expiry:    now.Add(s.c.ExpiresDuration),
 		}
 
 		if err := s.newSession(w, session); err != nil {
@@ -144,6 +195,10 @@ func (s *UserManager) Authorize(next http.Handler) http.Handler {
 			return
 		}
 
+		if t := traceFromRequest(r); t != nil {
+			t.User = user.Name
+		}
+
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, userKey, user)
 		ctx = context.WithValue(ctx, sessionKey, session)
@@ -182,13 +237,136 @@ func (s *UserManager) ChangePassword() http.HandlerFunc {
 		}
 
 		if err := s.db.SetUser(user); err != nil {
-			log.WithError(err).Errorf("Failed to update user %q data", user.Name)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeUserStoreErr(w, r, err, "Failed to update user %q data", user.Name)
+			return
+		}
 
+		if err := s.sessions.RemoveUserSessions(user.Name); err != nil {
+			log.WithError(err).Errorf("Failed to remove sessions of user %q", user.Name)
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// SetupTOTP returns a HandlerFunc that generates a new TOTP secret for the user.
+// Two-factor authentication is enabled once a code of the secret is confirmed via EnableTOTP.
+func (s *UserManager) SetupTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(userKey).(User)
+		if user.TOTPEnabled() {
+			httputil.WriteJSON(w, r, http.StatusConflict, ErrTOTPEnabled)
+			return
+		}
+
+		user.PendingTOTPSecret = newTOTPSecret()
+
+		if err := s.db.SetUser(user); err != nil {
+			writeUserStoreErr(w, r, err, "Failed to update user %q data", user.Name)
+			return
+		}
+
+		resp := struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"` // Provisioning URI, to be displayed as a QR code.
+		}{
+			Secret: totpEncodeSecret(user.PendingTOTPSecret),
+			URI:    totpURI(user.Name, user.PendingTOTPSecret),
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, resp)
+	}
+}
+
+// EnableTOTP returns a HandlerFunc that enables two-factor authentication,
+// after confirming a code of the secret generated by SetupTOTP.
+func (s *UserManager) EnableTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rb struct {
+			Code string `json:"code"`
+		}
+
+		if err := httputil.ReadJSON(r, &rb); err != nil {
+			if err != io.EOF {
+				log.Warnf("EnableTOTP request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		user := r.Context().Value(userKey).(User)
+		if user.TOTPEnabled() {
+			httputil.WriteJSON(w, r, http.StatusConflict, ErrTOTPEnabled)
+			return
+		}
+
+		if len(user.PendingTOTPSecret) == 0 {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrTOTPNotSetup)
+			return
+		}
+
+		user.TOTPSecret, user.PendingTOTPSecret = user.PendingTOTPSecret, nil
+		user.TOTPLastStep = 0
+
+		if !user.VerifyTOTP(rb.Code) {
+			httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrBadTOTP)
+			return
+		}
+
+		if err := s.db.SetUser(user); err != nil {
+			writeUserStoreErr(w, r, err, "Failed to update user %q data", user.Name)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// DisableTOTP returns a HandlerFunc that disables two-factor authentication.
+// Both the password and a current code are required.
+func (s *UserManager) DisableTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rb struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+
+		if err := httputil.ReadJSON(r, &rb); err != nil {
+			if err != io.EOF {
+				log.Warnf("DisableTOTP request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		user := r.Context().Value(userKey).(User)
+		if !user.TOTPEnabled() {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrTOTPNotEnabled)
+			return
+		}
+
+		if !user.VerifyPassword(rb.Password) {
+			httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrBadLogin)
+			return
+		}
+
+		if !user.VerifyTOTP(rb.Code) {
+			httputil.WriteJSON(w, r, http.StatusUnauthorized, ErrBadTOTP)
+			return
+		}
+
+		user.TOTPSecret = nil
+		user.TOTPLastStep = 0
+
+		if err := s.db.SetUser(user); err != nil {
+			writeUserStoreErr(w, r, err, "Failed to update user %q data", user.Name)
 			return
 		}
 
-		s.delAllSessionsOfUser(user.Name)
 		httputil.WriteJSON(w, r, http.StatusOK, true)
 	}
 }
@@ -228,9 +406,7 @@ func (s *UserManager) CreateAccount() http.HandlerFunc {
 				return
 			}
 
-			log.WithError(err).Errorf("Failed to create user %q account", user.Name)
-			w.WriteHeader(http.StatusInternalServerError)
-
+			writeUserStoreErr(w, r, err, "Failed to create user %q account", user.Name)
 			return
 		}
 
@@ -256,26 +432,32 @@ func (s *UserManager) UserInfo() http.HandlerFunc {
 			session = sessionIfc.(Session)
 		}
 
-		var otherSessions []Session
+		sessions, err := s.sessions.Sessions()
+		if err != nil {
+			log.WithError(err).Error("Failed to obtain sessions")
+			w.WriteHeader(http.StatusInternalServerError)
 
-		s.mu.RLock()
+			return
+		}
+
+		var otherSessions []Session
 
-		for _, s := range s.sessions {
+		for _, s := range sessions {
 			if s.User == user.Name && s.SID != session.SID {
 				otherSessions = append(otherSessions, s)
 			}
 		}
 
-		s.mu.RUnlock()
-
 		resp := struct {
-			Username string    `json:"username"`
-			Current  Session   `json:"current_session"`
-			Sessions []Session `json:"other_sessions"`
+			Username  string    `json:"username"`
+			TwoFactor bool      `json:"two_factor"`
+			Current   Session   `json:"current_session"`
+			Sessions  []Session `json:"other_sessions"`
 		}{
-			Username: user.Name,
-			Current:  session,
-			Sessions: otherSessions,
+			Username:  user.Name,
+			TwoFactor: user.TOTPEnabled(),
+			Current:   session,
+			Sessions:  otherSessions,
 		}
 
 		httputil.WriteJSON(w, r, http.StatusOK, resp)
@@ -285,9 +467,9 @@ func (s *UserManager) UserInfo() http.HandlerFunc {
 func (s *UserManager) newSession(w http.ResponseWriter, session Session) error {
 	session.SID = uuid.New()
 
-	s.mu.Lock()
-	s.sessions[session.SID] = session
-	s.mu.Unlock()
+	if err := s.sessions.AddSession(session); err != nil {
+		return fmt.Errorf("add session: %w", err)
+	}
 
 	value, err := s.crypto.Encode(sessionCookieName, session.SID)
 	if err != nil {
@@ -319,9 +501,9 @@ func (s *UserManager) delSession(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	s.mu.Lock()
-	delete(s.sessions, sid)
-	s.mu.Unlock()
+	if err := s.sessions.RemoveSession(sid); err != nil && err != ErrSessionNotFound {
+		return err
+	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookieName,
@@ -336,18 +518,6 @@ func (s *UserManager) delSession(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (s *UserManager) delAllSessionsOfUser(userName string) {
-	s.mu.Lock()
-
-	for sid, session := range s.sessions {
-		if session.User == userName {
-			delete(s.sessions, sid)
-		}
-	}
-
-	s.mu.Unlock()
-}
-
 func (s *UserManager) session(r *http.Request) (User, Session, bool) {
 	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil {
@@ -360,31 +530,48 @@ func (s *UserManager) session(r *http.Request) (User, Session, bool) {
 		return User{}, Session{}, false
 	}
 
-	s.mu.RLock()
-	session, ok := s.sessions[sid]
-	s.mu.RUnlock()
-
-	if !ok {
+	session, err := s.sessions.Session(sid)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to fetch session %s", sid)
 		return User{}, Session{}, false
 	}
 
-	user, err := s.db.User(session.User)
-	if err != nil {
-		log.WithError(err).Errorf("Failed to fetch user %q data", user.Name)
+	if session == nil {
 		return User{}, Session{}, false
 	}
 
+	// Users of external identity providers are not kept in the user store.
+	user := &User{Name: session.User}
+
+	if session.Provider == "" {
+		if user, err = s.db.User(session.User); err != nil {
+			log.WithError(err).Errorf("Failed to fetch user %q data", session.User)
+			return User{}, Session{}, false
+		}
+	}
+
 	if user == nil {
 		return User{}, Session{}, false
 	}
 
 	if time.Now().After(session.Expiry) {
-		s.mu.Lock()
-		delete(s.sessions, sid)
-		s.mu.Unlock()
+		if err := s.sessions.RemoveSession(sid); err != nil && err != ErrSessionNotFound {
+			log.WithError(err).Warnf("Failed to remove expired session %s", sid)
+		}
 
 		return User{}, Session{}, false
 	}
 
-	return *user, session, true
+	return *user, *session, true
+}
+
+// writeUserStoreErr responds to a failed change of a user, refusing it if users are managed outside of the API.
+func writeUserStoreErr(w http.ResponseWriter, r *http.Request, err error, format string, args ...interface{}) {
+	if err == ErrUserStoreReadOnly {
+		httputil.WriteJSON(w, r, http.StatusForbidden, err)
+		return
+	}
+
+	log.WithError(err).Errorf(format, args...)
+	w.WriteHeader(http.StatusInternalServerError)
 }
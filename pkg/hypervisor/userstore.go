@@ -0,0 +1,246 @@
+package hypervisor
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+)
+
+// User store types, in addition to StoreBolt and StoreSQL.
+const (
+	UserStoreFile = "file"
+)
+
+// Errors related to user stores.
+var (
+	ErrUserStoreReadOnly = errors.New("users are managed in a read-only users file")
+	ErrBadUsersFile      = errors.New("invalid users file")
+)
+
+// UserStoreConfig configures where users are kept, separately from the rest of the state.
+// It allows authenticating against existing user databases, while the state is kept in the shared store.
+type UserStoreConfig struct {
+	Type   string `json:"type,omitempty"`   // Either "" (default, kept in the shared store), "bbolt", "sql" or "file".
+	Path   string `json:"path,omitempty"`   // Path of the bbolt database or the users file.
+	Driver string `json:"driver,omitempty"` // Name of the linked-in SQL driver, such as "postgres".
+	DSN    string `json:"dsn,omitempty"`    // SQL data source name.
+}
+
+// openUserStore opens the configured user store, or returns shared if users are kept in the shared store.
+func openUserStore(c Config, shared UserStore) (UserStore, error) {
+	uc := c.UserStore
+
+	switch uc.Type {
+	case "":
+		return shared, nil
+
+	case StoreBolt:
+		users, err := NewBoltUserStore(uc.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if users.enc, err = openDBCipher(users.DB, c.DBEncryption, boltUserBucketName); err != nil {
+			if cErr := users.Close(); cErr != nil {
+				log.WithError(cErr).Warn("Failed to close user database.")
+			}
+
+			return nil, err
+		}
+
+		return users, nil
+
+	case StoreSQL:
+		return NewSQLStore(uc.Driver, uc.DSN)
+
+	case UserStoreFile:
+		return NewFileUserStore(uc.Path)
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStoreType, uc.Type)
+	}
+}
+
+// FileUser is an entry of a users file.
+type FileUser struct {
+	Name         string `json:"name"`
+	PasswordSalt string `json:"password_salt"`         // Hex encoded.
+	PasswordHash string `json:"password_hash"`         // Hex encoded SHA256 of the password followed by the salt.
+	TOTPSecret   string `json:"totp_secret,omitempty"` // Base32 encoded, enables two-factor authentication.
+}
+
+// NewFileUser creates an entry of a users file, with the password salted and hashed.
+func NewFileUser(name, password string) (FileUser, error) {
+	var u User
+
+	if !u.SetName(name) {
+		return FileUser{}, ErrBadUsernameFormat
+	}
+
+	if err := u.SetPassword(password); err != nil {
+		return FileUser{}, err
+	}
+
+	return FileUser{
+		Name:         u.Name,
+		PasswordSalt: hex.EncodeToString(u.PwSalt),
+		PasswordHash: hex.EncodeToString(u.PwHash[:]),
+	}, nil
+}
+
+func (fu FileUser) user() (*User, error) {
+	salt, err := hex.DecodeString(fu.PasswordSalt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("%w: bad password salt of user %q", ErrBadUsersFile, fu.Name)
+	}
+
+	rawHash, err := hex.DecodeString(fu.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad password hash of user %q", ErrBadUsersFile, fu.Name)
+	}
+
+	hash, err := cipher.SHA256FromBytes(rawHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad password hash of user %q", ErrBadUsersFile, fu.Name)
+	}
+
+	u := &User{Name: fu.Name, PwSalt: salt, PwHash: hash}
+
+	if fu.TOTPSecret != "" {
+		secret := strings.ToUpper(strings.TrimRight(fu.TOTPSecret, "="))
+		if u.TOTPSecret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+			return nil, fmt.Errorf("%w: bad TOTP secret of user %q", ErrBadUsersFile, fu.Name)
+		}
+	}
+
+	return u, nil
+}
+
+// FileUserStore implements UserStore, reading users from a static JSON file of FileUser entries.
+// The file is read again once it is modified, so users are managed by editing the file (such as with
+// configuration management tools) instead of through the API, which refuses changes of users.
+// Time steps of used TOTP codes are only kept in memory.
+type FileUserStore struct {
+	path string
+
+	mx      sync.Mutex
+	modTime time.Time
+	users   map[string]*User
+	steps   map[string]int64 // time steps of the last accepted TOTP codes
+}
+
+// NewFileUserStore creates a new FileUserStore reading the users file at path.
+func NewFileUserStore(path string) (*FileUserStore, error) {
+	s := &FileUserStore{
+		path:  filepath.Clean(path),
+		steps: make(map[string]int64),
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reload reads the users file if it was modified since it was last read.
+func (s *FileUserStore) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	if s.users != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var entries []FileUser
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadUsersFile, err)
+	}
+
+	users := make(map[string]*User, len(entries))
+
+	for _, e := range entries {
+		if _, ok := users[e.Name]; ok {
+			return fmt.Errorf("%w: duplicate user %q", ErrBadUsersFile, e.Name)
+		}
+
+		u, err := e.user()
+		if err != nil {
+			return err
+		}
+
+		users[e.Name] = u
+	}
+
+	s.users, s.modTime = users, info.ModTime()
+
+	return nil
+}
+
+// User obtains a single user. Returns nil if user does not exist.
+func (s *FileUserStore) User(name string) (*User, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	u, ok := s.users[name]
+	if !ok {
+		return nil, nil
+	}
+
+	user := *u
+	user.TOTPLastStep = s.steps[name]
+
+	return &user, nil
+}
+
+// AddUser always fails, as users are added to the file.
+func (s *FileUserStore) AddUser(User) error {
+	return ErrUserStoreReadOnly
+}
+
+// SetUser records the time step of the last accepted TOTP code of a user.
+// Changes of the credentials of users are refused.
+func (s *FileUserStore) SetUser(user User) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	u, ok := s.users[user.Name]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if user.PwHash != u.PwHash || string(user.PwSalt) != string(u.PwSalt) ||
+		string(user.TOTPSecret) != string(u.TOTPSecret) || len(user.PendingTOTPSecret) > 0 {
+		return ErrUserStoreReadOnly
+	}
+
+	s.steps[user.Name] = user.TOTPLastStep
+
+	return nil
+}
+
+// RemoveUser always fails, as users are removed from the file.
+func (s *FileUserStore) RemoveUser(string) error {
+	return ErrUserStoreReadOnly
+}
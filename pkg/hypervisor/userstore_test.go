@@ -0,0 +1,127 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileUserStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hv-users")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	path := filepath.Join(dir, "users.json")
+
+	writeUsers := func(users ...FileUser) {
+		raw, err := json.Marshal(users)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, raw, ownerRW))
+	}
+
+	admin, err := NewFileUser("admin", "Secr3t!pass")
+	require.NoError(t, err)
+
+	writeUsers(admin)
+
+	s, err := NewFileUserStore(path)
+	require.NoError(t, err)
+
+	user, err := s.User("admin")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.True(t, user.VerifyPassword("Secr3t!pass"))
+	assert.False(t, user.VerifyPassword("wrong"))
+
+	missing, err := s.User("other")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	t.Run("read-only", func(t *testing.T) {
+		assert.Equal(t, ErrUserStoreReadOnly, s.AddUser(User{Name: "other"}))
+		assert.Equal(t, ErrUserStoreReadOnly, s.RemoveUser("admin"))
+
+		changed := *user
+		require.NoError(t, changed.SetPassword("N3w!password"))
+		assert.Equal(t, ErrUserStoreReadOnly, s.SetUser(changed))
+
+		changed = *user
+		changed.PendingTOTPSecret = newTOTPSecret()
+		assert.Equal(t, ErrUserStoreReadOnly, s.SetUser(changed))
+
+		assert.Equal(t, ErrUserNotFound, s.SetUser(User{Name: "other"}))
+	})
+
+	t.Run("totp_steps", func(t *testing.T) {
+		used := *user
+		used.TOTPLastStep = 42
+		require.NoError(t, s.SetUser(used))
+
+		user, err := s.User("admin")
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), user.TOTPLastStep)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		other, err := NewFileUser("other", "Oth3r!pass")
+		require.NoError(t, err)
+
+		other.TOTPSecret = totpEncodeSecret(newTOTPSecret())
+		writeUsers(admin, other)
+
+		// Ensure the modification time differs on file systems of coarse resolution.
+		later := time.Now().Add(time.Second)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		user, err := s.User("other")
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		assert.True(t, user.TOTPEnabled())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bad := admin
+		bad.PasswordHash = "abc"
+		writeUsers(bad)
+
+		_, err := NewFileUserStore(path)
+		assert.Error(t, err)
+
+		writeUsers(admin, admin)
+
+		_, err = NewFileUserStore(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestOpenUserStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hv-users")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	shared := &FileUserStore{}
+
+	users, err := openUserStore(Config{}, shared)
+	require.NoError(t, err)
+	assert.Equal(t, shared, users)
+
+	users, err = openUserStore(Config{UserStore: UserStoreConfig{Type: StoreBolt, Path: filepath.Join(dir, "users.db")}}, shared)
+	require.NoError(t, err)
+	require.IsType(t, &BoltUserStore{}, users)
+	require.NoError(t, users.(*BoltUserStore).Close())
+
+	_, err = openUserStore(Config{UserStore: UserStoreConfig{Type: "ldap"}}, shared)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package hypervisor
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/internal/httpauth"
+)
+
+// Reports of a visor closer than this apart count as continuous uptime.
+// Visors report every second, so this tolerates brief network hiccups.
+const utReportGap = 30 * time.Second
+
+// UptimeTrackerConfig configures the embedded uptime tracker.
+type UptimeTrackerConfig struct {
+	// Enable serves an uptime tracker at '/uptime-tracker', for private networks without access to the public one.
+	// Visors report to it with their 'uptime_tracker.addr' set to "<hypervisor address>/uptime-tracker".
+	Enable bool `json:"enable"`
+}
+
+// uptimeTracker implements the visor facing API of the uptime tracker, recording reports into an UptimeStore.
+// Visors authenticate their reports by signing them with incrementing nonces.
+type uptimeTracker struct {
+	store  UptimeStore
+	accept func(pk cipher.PubKey) (bool, error) // Whether the visor may report, nil accepts all.
+
+	mu       sync.Mutex
+	nonces   map[cipher.PubKey]httpauth.Nonce
+	lastSeen map[cipher.PubKey]time.Time
+	pending  map[cipher.PubKey]time.Duration // Uptime not yet added to the store.
+}
+
+func newUptimeTracker(store UptimeStore, accept func(pk cipher.PubKey) (bool, error)) *uptimeTracker {
+	return &uptimeTracker{
+		store:    store,
+		accept:   accept,
+		nonces:   make(map[cipher.PubKey]httpauth.Nonce),
+		lastSeen: make(map[cipher.PubKey]time.Time),
+		pending:  make(map[cipher.PubKey]time.Duration),
+	}
+}
+
+// report records a report of pk signed with nonce, which should be the next nonce of pk.
+func (t *uptimeTracker) report(pk cipher.PubKey, nonce httpauth.Nonce, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if nonce != t.nonces[pk] {
+		return false
+	}
+
+	t.nonces[pk]++
+
+	if last, ok := t.lastSeen[pk]; ok && now.Sub(last) <= utReportGap {
+		t.pending[pk] += now.Sub(last)
+	}
+
+	t.lastSeen[pk] = now
+
+	return true
+}
+
+// reporting returns whether the visor of pk reports its uptime to the tracker.
+func (t *uptimeTracker) reporting(pk cipher.PubKey, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.lastSeen[pk]
+
+	return ok && now.Sub(last) <= utReportGap
+}
+
+// flush adds the uptime reported since the last flush to the store.
+func (t *uptimeTracker) flush(now time.Time) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[cipher.PubKey]time.Duration)
+	t.mu.Unlock()
+
+	for pk, d := range pending {
+		if err := t.store.AddUptime(pk, now, d); err != nil {
+			log.WithError(err).WithField("visor_pk", pk).Warn("Failed to record reported uptime.")
+		}
+	}
+}
+
+func (t *uptimeTracker) getNonce() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pk cipher.PubKey
+		if err := pk.UnmarshalText([]byte(chi.URLParam(r, "pk"))); err != nil {
+			writeTrackerError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		t.mu.Lock()
+		nonce := t.nonces[pk]
+		t.mu.Unlock()
+
+		httputil.WriteJSON(w, r, http.StatusOK, httpauth.NextNonceResponse{Edge: pk, NextNonce: nonce})
+	}
+}
+
+func (t *uptimeTracker) update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth, err := httpauth.AuthFromHeaders(r.Header)
+		if err != nil {
+			writeTrackerError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeTrackerError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := auth.Verify(body); err != nil {
+			writeTrackerError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		if t.accept != nil {
+			ok, err := t.accept(auth.Key)
+			if err != nil {
+				writeTrackerError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			if !ok {
+				writeTrackerError(w, r, http.StatusForbidden, ErrVisorNotEnrolled)
+				return
+			}
+		}
+
+		if !t.report(auth.Key, auth.Nonce, time.Now()) {
+			writeTrackerError(w, r, http.StatusUnauthorized, errors.New(httpauth.InvalidNonceErrorMessage))
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, httpauth.HTTPResponse{Data: true})
+	}
+}
+
+// writeTrackerError writes an error in the format of the uptime tracker, which clients use to detect stale nonces.
+func writeTrackerError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	httputil.WriteJSON(w, r, code, httpauth.HTTPResponse{
+		Error: &httpauth.HTTPError{Message: err.Error(), Code: code},
+	})
+}
+
+// uptimeTrackerRoutes registers the routes of the embedded uptime tracker.
+// Its '/uptimes' endpoint serves uptime history like the public uptime tracker does.
+func (hv *Hypervisor) uptimeTrackerRoutes(r chi.Router) {
+	r.Get("/security/nonces/{pk}", hv.utTracker.getNonce())
+	r.Get("/update", hv.utTracker.update())
+	r.Get("/uptimes", hv.getUptimes())
+}
@@ -0,0 +1,79 @@
+package hypervisor
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/internal/utclient"
+)
+
+func TestUptimeTracker_Report(t *testing.T) {
+	tr := newUptimeTracker(nil, nil)
+	pk, _ := cipher.GenerateKeyPair()
+	now := time.Now()
+
+	require.True(t, tr.report(pk, 0, now))
+	require.True(t, tr.report(pk, 1, now.Add(10*time.Second)))
+	assert.False(t, tr.report(pk, 1, now.Add(20*time.Second)), "reused nonce")
+
+	// Reports too far apart are not counted as uptime.
+	require.True(t, tr.report(pk, 2, now.Add(time.Minute)))
+	require.True(t, tr.report(pk, 3, now.Add(time.Minute+20*time.Second)))
+
+	assert.Equal(t, 30*time.Second, tr.pending[pk])
+	assert.True(t, tr.reporting(pk, now.Add(time.Minute+30*time.Second)))
+	assert.False(t, tr.reporting(pk, now.Add(5*time.Minute)))
+}
+
+func TestUptimeTracker(t *testing.T) {
+	config := makeConfig(false)
+	config.UptimeTracker.Enable = true
+
+	confDir, err := ioutil.TempDir(os.TempDir(), "SWHV")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(confDir)) }()
+
+	config.DBPath = filepath.Join(confDir, "users.db")
+
+	hv, err := New(nil, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(hv)
+	defer srv.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	c, err := utclient.NewHTTP(srv.URL+"/uptime-tracker", pk, sk)
+	require.NoError(t, err)
+
+	require.NoError(t, c.UpdateVisorUptime(context.Background()))
+	assert.True(t, hv.utTracker.reporting(pk, time.Now()))
+
+	// Clients catch up with nonces they do not expect, such as after a hypervisor restart.
+	hv.utTracker.mu.Lock()
+	hv.utTracker.nonces[pk] = 5
+	hv.utTracker.mu.Unlock()
+
+	require.NoError(t, c.UpdateVisorUptime(context.Background()))
+
+	hv.utTracker.mu.Lock()
+	assert.EqualValues(t, 6, hv.utTracker.nonces[pk])
+	hv.utTracker.pending[pk] = time.Hour
+	hv.utTracker.mu.Unlock()
+
+	now := time.Now()
+	hv.utTracker.flush(now)
+
+	uptimes, err := hv.uptimes.Uptimes(now, now.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, uptimes[pk])
+}
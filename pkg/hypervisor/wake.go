@@ -0,0 +1,221 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+	"go.etcd.io/bbolt"
+
+	"github.com/skycoin/skywire/pkg/util/wol"
+)
+
+const (
+	boltWakeBucketName = "visor_wake"
+)
+
+// Errors associated with Wake-on-LAN.
+var (
+	ErrWakeNotConfigured = errors.New("wake-on-lan is not configured for the visor")
+	ErrRelayNotConnected = errors.New("relay visor is not connected")
+)
+
+// WakeConfig configures how a visor is woken up with Wake-on-LAN.
+type WakeConfig struct {
+	MAC   string        `json:"mac"`             // MAC address of the visor's machine.
+	Addr  string        `json:"addr,omitempty"`  // Broadcast address to send the magic packet to, defaults to 255.255.255.255:9.
+	Relay cipher.PubKey `json:"relay,omitempty"` // Visor on the same LAN to send the packet from, instead of the hypervisor.
+}
+
+func (c WakeConfig) check() error {
+	hw, err := net.ParseMAC(c.MAC)
+	if err != nil {
+		return err
+	}
+
+	if _, err := wol.MagicPacket(hw); err != nil {
+		return err
+	}
+
+	if c.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WakeStore stores Wake-on-LAN configurations of visors.
+type WakeStore interface {
+	Wake(pk cipher.PubKey) (*WakeConfig, error)
+	SetWake(pk cipher.PubKey, c *WakeConfig) error
+}
+
+// BoltWakeStore implements WakeStore, storing configurations in a bbolt database.
+type BoltWakeStore struct {
+	*bbolt.DB
+}
+
+// NewBoltWakeStore creates a new BoltWakeStore on top of an opened bbolt database.
+func NewBoltWakeStore(db *bbolt.DB) (*BoltWakeStore, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltWakeBucketName))
+		return err
+	})
+
+	return &BoltWakeStore{DB: db}, err
+}
+
+// Wake returns the Wake-on-LAN configuration of visor of pk. Returns nil if there is none.
+func (s *BoltWakeStore) Wake(pk cipher.PubKey) (c *WakeConfig, err error) {
+	err = s.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(boltWakeBucketName)).Get(pk[:])
+		if raw == nil {
+			return nil
+		}
+
+		c = new(WakeConfig)
+
+		return json.Unmarshal(raw, c)
+	})
+
+	return c, err
+}
+
+// SetWake sets the Wake-on-LAN configuration of visor of pk. A nil configuration removes it.
+func (s *BoltWakeStore) SetWake(pk cipher.PubKey, c *WakeConfig) error {
+	return s.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(boltWakeBucketName))
+
+		if c == nil {
+			return b.Delete(pk[:])
+		}
+
+		raw, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(pk[:], raw)
+	})
+}
+
+func (hv *Hypervisor) getWakeConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		c, err := hv.wake.Wake(pk)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if c == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrWakeNotConfigured)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, c)
+	}
+}
+
+// putWakeConfig sets the Wake-on-LAN configuration of a visor. The visor does not need to be connected.
+func (hv *Hypervisor) putWakeConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		var c WakeConfig
+
+		if err := httputil.ReadJSON(r, &c); err != nil {
+			if err != io.EOF {
+				log.Warnf("putWakeConfig request: %v", err)
+			}
+
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+
+			return
+		}
+
+		if err := c.check(); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := hv.wake.SetWake(pk, &c); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, c)
+	}
+}
+
+func (hv *Hypervisor) deleteWakeConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		if err := hv.wake.SetWake(pk, nil); err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
+
+// postWake sends a Wake-on-LAN magic packet to the visor's machine,
+// either directly from the hypervisor or from the configured relay visor.
+func (hv *Hypervisor) postWake() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pk, status, err := hv.visorPK(r)
+		if err != nil {
+			httputil.WriteJSON(w, r, status, err)
+			return
+		}
+
+		c, err := hv.wake.Wake(pk)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		if c == nil {
+			httputil.WriteJSON(w, r, http.StatusNotFound, ErrWakeNotConfigured)
+			return
+		}
+
+		if c.Relay.Null() {
+			err = wol.Send(c.MAC, c.Addr)
+		} else if relay, ok := hv.visorConn(c.Relay); ok {
+			err = relay.RPC.WakeOnLAN(c.MAC, c.Addr)
+		} else {
+			httputil.WriteJSON(w, r, http.StatusBadGateway, fmt.Errorf("%w: %s", ErrRelayNotConnected, c.Relay))
+			return
+		}
+
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		httputil.WriteJSON(w, r, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,85 @@
+package hypervisor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWakeVisor(t *testing.T) {
+	addr, client, _, stop := makeStartMockNode(t)
+	defer stop()
+
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	// The visor to wake is offline, so it is unknown to the hypervisor.
+	pk, _ := cipher.GenerateKeyPair()
+	uri := fmt.Sprintf("/api/v1/visors/%s/wake", pk)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			RespStatus: http.StatusNotFound,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri + "-config",
+			ReqBody:    strings.NewReader(`{"mac":"not-a-mac"}`),
+			RespStatus: http.StatusBadRequest,
+		},
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri + "-config",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"mac":"01:23:45:67:89:ab","addr":%q}`, l.LocalAddr())),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			RespStatus: http.StatusOK,
+		},
+	})
+
+	buf := make([]byte, 256)
+	require.NoError(t, l.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := l.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 102, n)
+	assert.Equal(t, []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}, buf[6:12])
+
+	relay, _ := cipher.GenerateKeyPair()
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPut,
+			ReqURI:     uri + "-config",
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"mac":"01:23:45:67:89:ab","relay":%q}`, relay)),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     uri,
+			RespStatus: http.StatusBadGateway,
+		},
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     uri + "-config",
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     uri + "-config",
+			RespStatus: http.StatusNotFound,
+		},
+	})
+}
@@ -0,0 +1,432 @@
+package hypervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+// Watch event types.
+const (
+	WatchAdded    = "ADDED"
+	WatchModified = "MODIFIED"
+	WatchDeleted  = "DELETED"
+	WatchError    = "ERROR" // The watch ended, with the error as object.
+)
+
+// Watchable collections of visors.
+const (
+	watchTransports = "transports"
+	watchRoutes     = "routes"
+)
+
+const (
+	defaultWatchInterval = 5 * time.Second
+	watchHistoryLen      = 1024 // events kept per collection for watches resuming from a resource version
+	resourceVersionHdr   = "X-Resource-Version"
+)
+
+// Errors associated with watches.
+var (
+	ErrResourceVersionExpired = errors.New("resource version is too old, list again to get a recent one")
+	ErrVisorDisconnected      = errors.New("visor is not connected")
+)
+
+// WatchEvent describes a change of an object of a watched collection.
+type WatchEvent struct {
+	Type            string          `json:"type"`
+	ResourceVersion string          `json:"resource_version"`
+	Object          json.RawMessage `json:"object"`
+
+	version uint64
+	key     string
+}
+
+type watchKey struct {
+	pk   cipher.PubKey
+	kind string
+}
+
+// watchCache holds the last observed state of a collection, and the recent changes to it.
+type watchCache struct {
+	objects  map[string]json.RawMessage
+	synced   bool
+	since    uint64 // changes after this version are kept in events
+	version  uint64 // version of the current state
+	events   []WatchEvent
+	changed  chan struct{} // closed on changes
+	watchers int
+	poke     chan struct{}
+	stop     chan struct{}
+}
+
+// watchHub keeps the watched collections of visors, versioned by a single counter.
+type watchHub struct {
+	version uint64
+	caches  map[watchKey]*watchCache
+	hits    uint64 // lookups of events after a resource version which were kept
+	misses  uint64 // lookups of events after an expired resource version
+	mu      sync.Mutex
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{caches: make(map[watchKey]*watchCache)}
+}
+
+func (h *watchHub) cache(key watchKey) *watchCache {
+	c, ok := h.caches[key]
+	if !ok {
+		c = &watchCache{
+			objects: make(map[string]json.RawMessage),
+			changed: make(chan struct{}),
+			poke:    make(chan struct{}, 1),
+		}
+		h.caches[key] = c
+	}
+
+	return c
+}
+
+// observe records the current objects of a collection, and returns the resource version of the collection.
+func (h *watchHub) observe(key watchKey, objects map[string]json.RawMessage) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.cache(key)
+
+	if !c.synced {
+		h.version++
+		c.objects, c.synced, c.since, c.version = objects, true, h.version, h.version
+
+		return c.version
+	}
+
+	var events []WatchEvent
+
+	for k, obj := range objects {
+		if old, ok := c.objects[k]; !ok {
+			events = append(events, WatchEvent{Type: WatchAdded, Object: obj, key: k})
+		} else if !bytes.Equal(old, obj) {
+			events = append(events, WatchEvent{Type: WatchModified, Object: obj, key: k})
+		}
+	}
+
+	for k, obj := range c.objects {
+		if _, ok := objects[k]; !ok {
+			events = append(events, WatchEvent{Type: WatchDeleted, Object: obj, key: k})
+		}
+	}
+
+	if len(events) == 0 {
+		return c.version
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].key < events[j].key })
+
+	for i := range events {
+		h.version++
+		events[i].version = h.version
+		events[i].ResourceVersion = strconv.FormatUint(h.version, 10)
+	}
+
+	c.objects, c.version = objects, h.version
+	c.events = append(c.events, events...)
+
+	if n := len(c.events) - watchHistoryLen; n > 0 {
+		c.since = c.events[n-1].version
+		c.events = append([]WatchEvent(nil), c.events[n:]...)
+	}
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	return c.version
+}
+
+// eventsSince returns the events of a collection after version, and a channel closed on further changes.
+// Version 0 returns the current objects as added events.
+func (h *watchHub) eventsSince(key watchKey, version uint64) ([]WatchEvent, uint64, <-chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.cache(key)
+
+	if version == 0 {
+		keys := make([]string, 0, len(c.objects))
+		for k := range c.objects {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		rv := strconv.FormatUint(c.version, 10)
+		events := make([]WatchEvent, 0, len(keys))
+
+		for _, k := range keys {
+			events = append(events, WatchEvent{Type: WatchAdded, ResourceVersion: rv, Object: c.objects[k]})
+		}
+
+		return events, c.version, c.changed, nil
+	}
+
+	// Versions after the current one were not given by this hypervisor, which may have restarted since.
+	if version < c.since || version > c.version {
+		h.misses++
+		return nil, 0, nil, ErrResourceVersionExpired
+	}
+
+	h.hits++
+
+	i := sort.Search(len(c.events), func(i int) bool { return c.events[i].version > version })
+	events := append([]WatchEvent(nil), c.events[i:]...)
+
+	if version < c.version {
+		version = c.version
+	}
+
+	return events, version, c.changed, nil
+}
+
+// subscribe registers a watcher of a collection. The collection is polled with poll while it has watchers.
+func (h *watchHub) subscribe(key watchKey, interval time.Duration, poll func()) (unsubscribe func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.cache(key)
+
+	if c.watchers++; c.watchers == 1 {
+		c.stop = make(chan struct{})
+		go runWatchPoller(interval, c.poke, c.stop, poll)
+	}
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if c.watchers--; c.watchers == 0 {
+			close(c.stop)
+		}
+	}
+}
+
+// poke makes watched collections of the visor of pk be polled right away, after they were changed through the API.
+// The change feed of all visors is polled as well.
+func (h *watchHub) poke(pk cipher.PubKey, kind string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range []watchKey{{pk: pk, kind: kind}, changesKey} {
+		if c, ok := h.caches[key]; ok && c.watchers > 0 {
+			select {
+			case c.poke <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func runWatchPoller(interval time.Duration, poke, stop <-chan struct{}, poll func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-poke:
+		}
+
+		poll()
+	}
+}
+
+// watchObjects lists the objects of a watchable collection of a visor, keyed by their IDs.
+func watchObjects(rpc visor.RPCClient, kind string) (map[string]json.RawMessage, error) {
+	objects := make(map[string]interface{})
+
+	switch kind {
+	case watchTransports:
+		tps, err := rpc.Transports(nil, nil, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tp := range tps {
+			objects[tp.ID.String()] = tp
+		}
+	case watchRoutes:
+		rules, err := rpc.RoutingRules()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			objects[ruleWatchKey(rule.KeyRouteID())] = makeRoutingRuleResp(rule.KeyRouteID(), rule, true)
+		}
+	}
+
+	return encodeWatchObjects(objects)
+}
+
+func ruleWatchKey(key routing.RouteID) string {
+	return strconv.FormatUint(uint64(key), 10)
+}
+
+func encodeWatchObjects(objects map[string]interface{}) (map[string]json.RawMessage, error) {
+	encoded := make(map[string]json.RawMessage, len(objects))
+
+	for k, obj := range objects {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded[k] = raw
+	}
+
+	return encoded, nil
+}
+
+// pollWatch lists a watched collection of a visor, and records changes to it.
+func (hv *Hypervisor) pollWatch(key watchKey) error {
+	conn, ok := hv.visorConn(key.pk)
+	if !ok {
+		return ErrVisorDisconnected
+	}
+
+	objects, err := watchObjects(conn.RPC, key.kind)
+	if err != nil {
+		return err
+	}
+
+	hv.watches.observe(key, objects)
+
+	return nil
+}
+
+// observeList records a full list of a visor's collection, and sets the resource version header of the response.
+func (hv *Hypervisor) observeList(w http.ResponseWriter, pk cipher.PubKey, kind string, objects map[string]interface{}) {
+	encoded, err := encodeWatchObjects(objects)
+	if err != nil {
+		log.WithError(err).Warn("Failed to encode watched objects.")
+		return
+	}
+
+	version := hv.watches.observe(watchKey{pk: pk, kind: kind}, encoded)
+	w.Header().Set(resourceVersionHdr, strconv.FormatUint(version, 10))
+}
+
+// serveWatch watches the collection if the 'watch' query is set, returning whether the request was served.
+func (hv *Hypervisor) serveWatch(w http.ResponseWriter, r *http.Request, ctx *httpCtx, kind string) bool {
+	watch, err := httputil.BoolFromQuery(r, "watch", false)
+	if err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return true
+	}
+
+	if watch {
+		hv.watch(w, r, ctx, kind)
+	}
+
+	return watch
+}
+
+// watch streams changes of a visor's collection as lines of JSON encoded WatchEvents.
+// Watches start after the 'resourceVersion' query, or with the current objects as added events if it is not set.
+// Watches end after the 'timeout' query, or the maximum request timeout, and may be resumed from the last event.
+func (hv *Hypervisor) watch(w http.ResponseWriter, r *http.Request, ctx *httpCtx, kind string) {
+	q := r.URL.Query()
+
+	var version uint64
+
+	if rv := q.Get("resourceVersion"); rv != "" {
+		var err error
+		if version, err = strconv.ParseUint(rv, 10, 64); err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+	}
+
+	timeout := visor.Duration(hv.c.MaxRequestTimeout)
+
+	if t := q.Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			httputil.WriteJSON(w, r, http.StatusBadRequest, ErrMalformedRequest)
+			return
+		}
+
+		timeout = visor.Duration(d)
+	}
+
+	if _, err := hv.extendDeadline(r, timeout); err != nil {
+		httputil.WriteJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	key := watchKey{pk: ctx.Addr.PK, kind: kind}
+	log := log.WithField("visor_pk", key.pk).WithField("collection", kind)
+
+	if err := hv.pollWatch(key); err != nil {
+		httputil.WriteJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	events, version, changed, err := hv.watches.eventsSince(key, version)
+	if err == ErrResourceVersionExpired {
+		httputil.WriteJSON(w, r, http.StatusGone, err)
+		return
+	}
+
+	unsubscribe := hv.watches.subscribe(key, hv.c.WatchInterval, func() {
+		if err := hv.pollWatch(key); err != nil {
+			log.WithError(err).Debug("Failed to poll watched collection.")
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(resourceVersionHdr, strconv.FormatUint(version, 10))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher) // nolint: errcheck
+	enc := json.NewEncoder(w)
+
+	for {
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+
+		if events, version, changed, err = hv.watches.eventsSince(key, version); err != nil {
+			// The watcher fell too far behind, and should list again.
+			raw, _ := json.Marshal(map[string]interface{}{"status": http.StatusGone, "error": err.Error()}) // nolint: errcheck
+			_ = enc.Encode(WatchEvent{Type: WatchError, Object: raw})                                       // nolint: errcheck
+
+			return
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package hypervisor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/visor"
+)
+
+func TestWatchHub(t *testing.T) {
+	h := newWatchHub()
+	key := watchKey{pk: cipher.PubKey{1}, kind: watchTransports}
+
+	objects := func(kv ...string) map[string]json.RawMessage {
+		m := make(map[string]json.RawMessage)
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = json.RawMessage(kv[i+1])
+		}
+
+		return m
+	}
+
+	v1 := h.observe(key, objects("a", "1", "b", "1"))
+
+	events, version, _, err := h.eventsSince(key, 0)
+	require.NoError(t, err)
+	assert.Equal(t, v1, version)
+	require.Len(t, events, 2)
+	assert.Equal(t, WatchAdded, events[0].Type)
+
+	// Unchanged objects do not change the version.
+	assert.Equal(t, v1, h.observe(key, objects("a", "1", "b", "1")))
+
+	v2 := h.observe(key, objects("a", "2", "c", "1"))
+
+	events, version, changed, err := h.eventsSince(key, v1)
+	require.NoError(t, err)
+	assert.Equal(t, v2, version)
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	assert.Equal(t, []string{WatchModified, WatchDeleted, WatchAdded}, types)
+	assert.Equal(t, strconv.FormatUint(v2, 10), events[2].ResourceVersion)
+
+	h.observe(key, objects("a", "3"))
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("watchers were not notified of changes")
+	}
+
+	for i := 0; i < watchHistoryLen; i++ {
+		h.observe(key, objects("a", strconv.Itoa(i+4)))
+	}
+
+	_, _, _, err = h.eventsSince(key, v2)
+	assert.Equal(t, ErrResourceVersionExpired, err)
+}
+
+func TestWatchTransports(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for visorPK := range hv.visors {
+		pk = visorPK
+	}
+
+	remote, _ := cipher.GenerateKeyPair()
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/api/v1/visors/%s/transports", addr, pk))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	rv := resp.Header.Get(resourceVersionHdr)
+	require.NotEmpty(t, rv)
+
+	resp, err = client.Get(fmt.Sprintf("https://%s/api/v1/visors/%s/transports?watch=true&resourceVersion=%s", addr, pk, rv))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+
+	events := make(chan WatchEvent)
+
+	go func() {
+		defer close(events)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var e WatchEvent
+			if err := json.Unmarshal(scanner.Bytes(), &e); err == nil {
+				events <- e
+			}
+		}
+	}()
+
+	next := func() (string, visor.TransportSummary) {
+		select {
+		case e := <-events:
+			var tp visor.TransportSummary
+			require.NoError(t, json.Unmarshal(e.Object, &tp))
+
+			return e.Type, tp
+		case <-time.After(5 * time.Second):
+			t.Fatal("no watch event")
+		}
+
+		return "", visor.TransportSummary{}
+	}
+
+	var added visor.TransportSummary
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodPost,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports", pk),
+			ReqBody:    strings.NewReader(fmt.Sprintf(`{"transport_type":"native","remote_pk":%q}`, remote)),
+			RespStatus: http.StatusOK,
+			RespBody: func(t *testing.T, r *http.Response) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&added))
+			},
+		},
+	})
+
+	typ, tp := next()
+	assert.Equal(t, WatchAdded, typ)
+	assert.Equal(t, added.ID, tp.ID)
+
+	testCases(t, addr, client, []TestCase{
+		{
+			ReqMethod:  http.MethodDelete,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports/%s", pk, added.ID),
+			RespStatus: http.StatusOK,
+		},
+		{
+			ReqMethod:  http.MethodGet,
+			ReqURI:     fmt.Sprintf("/api/v1/visors/%s/transports?watch=true&resourceVersion=x", pk),
+			RespStatus: http.StatusBadRequest,
+		},
+	})
+
+	typ, tp = next()
+	assert.Equal(t, WatchDeleted, typ)
+	assert.Equal(t, added.ID, tp.ID)
+}
+
+func TestWatchRoutes(t *testing.T) {
+	addr, client, hv, stop := makeStartMockNode(t)
+	defer stop()
+
+	var pk cipher.PubKey
+	for visorPK := range hv.visors {
+		pk = visorPK
+	}
+
+	rules, err := hv.visors[pk].RPC.RoutingRules()
+	require.NoError(t, err)
+
+	// Watches without a resource version start with the current objects.
+	resp, err := client.Get(fmt.Sprintf("https://%s/api/v1/visors/%s/routes?watch=true&timeout=1s", addr, pk))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+
+	var added int
+
+	scanner := bufio.NewScanner(resp.Body)
+	for added < len(rules) && scanner.Scan() {
+		var e WatchEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		assert.Equal(t, WatchAdded, e.Type)
+		assert.Equal(t, resp.Header.Get(resourceVersionHdr), e.ResourceVersion)
+
+		added++
+	}
+
+	assert.Equal(t, len(rules), added)
+}
@@ -81,6 +81,25 @@ func (_m *MockRouter) DialRoutes(ctx context.Context, rPK cipher.PubKey, lPort r
 	return r0, r1
 }
 
+// Drain provides a mock function with given fields:
+func (_m *MockRouter) Drain() {
+	_m.Called()
+}
+
+// Failure provides a mock function with given fields:
+func (_m *MockRouter) Failure() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // IntroduceRules provides a mock function with given fields: rules
 func (_m *MockRouter) IntroduceRules(rules routing.EdgeRules) error {
 	ret := _m.Called(rules)
@@ -95,6 +114,36 @@ func (_m *MockRouter) IntroduceRules(rules routing.EdgeRules) error {
 	return r0
 }
 
+// PacketStats provides a mock function with given fields:
+func (_m *MockRouter) PacketStats() PacketStats {
+	ret := _m.Called()
+
+	var r0 PacketStats
+	if rf, ok := ret.Get(0).(func() PacketStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(PacketStats)
+	}
+
+	return r0
+}
+
+// RemoveExpiredRules provides a mock function with given fields:
+func (_m *MockRouter) RemoveExpiredRules() []routing.Rule {
+	ret := _m.Called()
+
+	var r0 []routing.Rule
+	if rf, ok := ret.Get(0).(func() []routing.Rule); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]routing.Rule)
+		}
+	}
+
+	return r0
+}
+
 // ReserveKeys provides a mock function with given fields: n
 func (_m *MockRouter) ReserveKeys(n int) ([]routing.RouteID, error) {
 	ret := _m.Called(n)
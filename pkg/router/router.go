@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/rpc"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/skycoin/dmsg"
@@ -42,6 +43,9 @@ var (
 
 	// ErrRemoteEmptyPK occurs when the specified remote public key is empty.
 	ErrRemoteEmptyPK = errors.New("empty remote public key")
+
+	// ErrDraining is returned for new routes once the router is draining.
+	ErrDraining = errors.New("router is draining, new routes are refused")
 )
 
 // Config configures Router.
@@ -54,6 +58,7 @@ type Config struct {
 	RouteGroupDialer setupclient.RouteGroupDialer
 	SetupNodes       []cipher.PubKey
 	RulesGCInterval  time.Duration
+	RuleHook         func(rule routing.Rule) // If set, called when rules are saved to the routing table.
 }
 
 // SetDefaults sets default values for certain empty values.
@@ -123,6 +128,27 @@ type Router interface {
 	Rule(routing.RouteID) (routing.Rule, error)
 	SaveRule(routing.Rule) error
 	DelRules([]routing.RouteID)
+	RemoveExpiredRules() []routing.Rule
+
+	// PacketStats returns the counters of packets handled since the router was created.
+	PacketStats() PacketStats
+
+	// Drain makes the router refuse new routes, both dialed and set up by setup nodes.
+	// Existing routes are kept until they are closed or time out.
+	Drain()
+
+	// Failure returns the error which stopped the router from handling packets or setup requests,
+	// or nil while it handles them.
+	Failure() error
+}
+
+// PacketStats are counters of packets handled by the router.
+type PacketStats struct {
+	DataReceived      uint64 `json:"data_received"`
+	CloseReceived     uint64 `json:"close_received"`
+	KeepAliveReceived uint64 `json:"keep_alive_received"`
+	Forwarded         uint64 `json:"forwarded"` // Packets forwarded to the next hop.
+	Dropped           uint64 `json:"dropped"`   // Packets which failed to be handled.
 }
 
 // Router implements visor.PacketRouter. It manages routing table by
@@ -144,6 +170,10 @@ type router struct {
 	done          chan struct{}
 	wg            sync.WaitGroup
 	once          sync.Once
+	stats         PacketStats // updated atomically
+	draining      int32       // set atomically by Drain
+	failureMx     sync.Mutex
+	failure       error // set once the router stops serving unexpectedly
 }
 
 // New constructs a new Router.
@@ -205,6 +235,10 @@ func (r *router) DialRoutes(
 		return nil, fmt.Errorf("failed to dial routes: %v", err)
 	}
 
+	if r.isDraining() {
+		return nil, ErrDraining
+	}
+
 	lPK := r.conf.PubKey
 	forwardDesc := routing.NewRouteDescriptor(lPK, rPK, lPort, rPort)
 
@@ -303,10 +337,15 @@ func (r *router) serveTransportManager(ctx context.Context) {
 			}
 
 			r.logger.WithError(err).Error("Stopped reading packets due to unexpected error.")
+			r.fail(fmt.Errorf("stopped reading packets: %w", err))
 			return
 		}
 
+		r.countReceived(packet.Type())
+
 		if err := r.handleTransportPacket(ctx, packet); err != nil {
+			atomic.AddUint64(&r.stats.Dropped, 1)
+
 			if err == transport.ErrNotServing {
 				r.logger.WithError(err).Warnf("Stopped serving Transport.")
 				return
@@ -326,6 +365,7 @@ func (r *router) serveSetup() {
 				log.Info("Setup client stopped serving.")
 			} else {
 				log.Error("Setup client stopped serving due to unexpected error.")
+				r.fail(fmt.Errorf("setup client stopped serving: %w", err))
 			}
 			return
 		}
@@ -384,6 +424,28 @@ func (r *router) handleTransportPacket(ctx context.Context, packet routing.Packe
 	}
 }
 
+func (r *router) countReceived(t routing.PacketType) {
+	switch t {
+	case routing.DataPacket:
+		atomic.AddUint64(&r.stats.DataReceived, 1)
+	case routing.ClosePacket:
+		atomic.AddUint64(&r.stats.CloseReceived, 1)
+	case routing.KeepAlivePacket:
+		atomic.AddUint64(&r.stats.KeepAliveReceived, 1)
+	}
+}
+
+// PacketStats returns the counters of packets handled since the router was created.
+func (r *router) PacketStats() PacketStats {
+	return PacketStats{
+		DataReceived:      atomic.LoadUint64(&r.stats.DataReceived),
+		CloseReceived:     atomic.LoadUint64(&r.stats.CloseReceived),
+		KeepAliveReceived: atomic.LoadUint64(&r.stats.KeepAliveReceived),
+		Forwarded:         atomic.LoadUint64(&r.stats.Forwarded),
+		Dropped:           atomic.LoadUint64(&r.stats.Dropped),
+	}
+}
+
 func (r *router) handleDataPacket(ctx context.Context, packet routing.Packet) error {
 	rule, err := r.GetRule(packet.RouteID())
 	if err != nil {
@@ -595,6 +657,8 @@ func (r *router) forwardPacket(ctx context.Context, packet routing.Packet, rule
 		return err
 	}
 
+	atomic.AddUint64(&r.stats.Forwarded, 1)
+
 	// successfully forwarded packet, may update the rule activity now
 	if err := r.UpdateRuleActivity(rule.KeyRouteID()); err != nil {
 		r.logger.Errorf("Failed to update activity for rule with route ID %d: %v", rule.KeyRouteID(), err)
@@ -670,12 +734,17 @@ func (r *router) SaveRoutingRules(rules ...routing.Rule) error {
 		}
 
 		r.logger.Infof("Save new Routing Rule with ID %d %s", rule.KeyRouteID(), rule)
+		r.ruleSaved(rule)
 	}
 
 	return nil
 }
 
 func (r *router) ReserveKeys(n int) ([]routing.RouteID, error) {
+	if r.isDraining() {
+		return nil, ErrDraining
+	}
+
 	ids, err := r.rt.ReserveKeys(n)
 	if err != nil {
 		r.logger.WithError(err).Error("Error reserving IDs")
@@ -715,6 +784,10 @@ func (r *router) removeRouteGroup(desc routing.RouteDescriptor) {
 }
 
 func (r *router) IntroduceRules(rules routing.EdgeRules) error {
+	if r.isDraining() {
+		return ErrDraining
+	}
+
 	select {
 	case <-r.done:
 		return io.ErrClosedPipe
@@ -731,6 +804,32 @@ func (r *router) IntroduceRules(rules routing.EdgeRules) error {
 	}
 }
 
+// Drain makes the router refuse new routes.
+func (r *router) Drain() {
+	atomic.StoreInt32(&r.draining, 1)
+}
+
+func (r *router) isDraining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+// Failure returns the error which stopped the router from serving, if any.
+func (r *router) Failure() error {
+	r.failureMx.Lock()
+	defer r.failureMx.Unlock()
+
+	return r.failure
+}
+
+func (r *router) fail(err error) {
+	r.failureMx.Lock()
+	defer r.failureMx.Unlock()
+
+	if r.failure == nil {
+		r.failure = err
+	}
+}
+
 // RoutesCount returns count of the routes stored within the routing table.
 func (r *router) RoutesCount() int {
 	return r.rt.Count()
@@ -748,7 +847,19 @@ func (r *router) Rule(id routing.RouteID) (routing.Rule, error) {
 
 // SaveRule stores the `rule` within the routing table.
 func (r *router) SaveRule(rule routing.Rule) error {
-	return r.rt.SaveRule(rule)
+	if err := r.rt.SaveRule(rule); err != nil {
+		return err
+	}
+
+	r.ruleSaved(rule)
+
+	return nil
+}
+
+func (r *router) ruleSaved(rule routing.Rule) {
+	if r.conf.RuleHook != nil {
+		r.conf.RuleHook(rule)
+	}
 }
 
 // DelRules removes rules associated with `ids` from the routing table.
@@ -780,13 +891,15 @@ func (r *router) rulesGCLoop() {
 		case <-r.done:
 			return
 		case <-ticker.C:
-			r.rulesGC()
+			r.RemoveExpiredRules()
 		}
 	}
 }
 
-func (r *router) rulesGC() {
-	log := r.logger.WithField("func", "router.rulesGC")
+// RemoveExpiredRules removes the rules of which keep-alive timeout is exceeded, without waiting for the
+// periodic garbage collection, and returns them.
+func (r *router) RemoveExpiredRules() []routing.Rule {
+	log := r.logger.WithField("func", "router.RemoveExpiredRules")
 
 	removedRules := r.rt.CollectGarbage()
 	log.WithField("rules_count", len(removedRules)).
@@ -795,6 +908,8 @@ func (r *router) rulesGC() {
 	for _, rule := range removedRules {
 		r.removeRouteGroupOfRule(rule)
 	}
+
+	return removedRules
 }
 
 func (r *router) removeRouteGroupOfRule(rule routing.Rule) {
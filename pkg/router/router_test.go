@@ -574,6 +574,29 @@ func TestRouter_Rules(t *testing.T) {
 	t.Run("RemoveRouteDescriptor", func(t *testing.T) {
 		testRemoveRouteDescriptor(t, r, rt)
 	})
+
+	// TEST: Ensure only expired rules are removed.
+	t.Run("RemoveExpiredRules", func(t *testing.T) {
+		testRemoveExpiredRules(t, r, rt)
+	})
+}
+
+func testRemoveExpiredRules(t *testing.T, r *router, rt routing.Table) {
+	clearRoutingTableRules(rt)
+
+	ids, err := r.rt.ReserveKeys(2)
+	require.NoError(t, err)
+
+	expiredRule := routing.IntermediaryForwardRule(-10*time.Minute, ids[0], 3, uuid.New())
+	require.NoError(t, r.rt.SaveRule(expiredRule))
+
+	rule := routing.IntermediaryForwardRule(10*time.Minute, ids[1], 3, uuid.New())
+	require.NoError(t, r.rt.SaveRule(rule))
+
+	removed := r.RemoveExpiredRules()
+	require.Len(t, removed, 1)
+	assert.Equal(t, ids[0], removed[0].KeyRouteID())
+	assert.Equal(t, 1, rt.Count())
 }
 
 func testRemoveRouteDescriptor(t *testing.T, r *router, rt routing.Table) {
@@ -726,3 +749,29 @@ func (e *TestEnv) GenRouterConfig(i int) *Config {
 func (e *TestEnv) Teardown() {
 	e.teardown()
 }
+
+func TestRouter_Drain(t *testing.T) {
+	keys := snettest.GenKeyPairs(1)
+
+	nEnv := snettest.NewEnv(t, keys, []string{dmsg.Type})
+	defer nEnv.Teardown()
+
+	rEnv := NewTestEnv(t, nEnv.Nets)
+	defer rEnv.Teardown()
+
+	r0, err := New(nEnv.Nets[0], rEnv.GenRouterConfig(0))
+	require.NoError(t, err)
+
+	_, err = r0.ReserveKeys(1)
+	require.NoError(t, err)
+
+	r0.Drain()
+
+	_, err = r0.ReserveKeys(1)
+	assert.Equal(t, ErrDraining, err)
+
+	assert.Equal(t, ErrDraining, r0.IntroduceRules(routing.EdgeRules{}))
+
+	_, err = r0.DialRoutes(context.Background(), keys[0].PK, 0, 0, nil)
+	assert.Equal(t, ErrDraining, err)
+}
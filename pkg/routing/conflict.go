@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"fmt"
+)
+
+// Reasons of rule conflicts.
+const (
+	ConflictDuplicateRouteID   = "duplicate_route_id"
+	ConflictOverlappingConsume = "overlapping_consume_rule"
+)
+
+// RuleConflict is returned when a rule conflicts with a rule already present in the routing table.
+type RuleConflict struct {
+	Reason   string       `json:"reason"`
+	RouteID  RouteID      `json:"route_id"` // Key route ID of the existing rule.
+	Existing *RuleSummary `json:"existing"`
+}
+
+// Error implements error.
+func (c *RuleConflict) Error() string {
+	switch c.Reason {
+	case ConflictDuplicateRouteID:
+		return fmt.Sprintf("rule of route ID %v already exists", c.RouteID)
+	case ConflictOverlappingConsume:
+		return fmt.Sprintf("consume rule of route ID %v has the same local public key and port", c.RouteID)
+	default:
+		return fmt.Sprintf("rule conflicts with rule of route ID %v: %s", c.RouteID, c.Reason)
+	}
+}
+
+// FindRuleConflict checks whether saving rule would conflict with the existing rules.
+// If replace is set, the rule is expected to replace an existing rule of the same key route ID.
+// It returns nil if there is no conflict.
+func FindRuleConflict(existing []Rule, rule Rule, replace bool) *RuleConflict {
+	key := rule.KeyRouteID()
+
+	for _, r := range existing {
+		if r.KeyRouteID() == key {
+			if replace {
+				continue
+			}
+
+			return &RuleConflict{Reason: ConflictDuplicateRouteID, RouteID: key, Existing: r.Summary()}
+		}
+
+		if rule.Type() == RuleConsume && r.Type() == RuleConsume && sameLocalAddr(r.RouteDescriptor(), rule.RouteDescriptor()) {
+			return &RuleConflict{Reason: ConflictOverlappingConsume, RouteID: r.KeyRouteID(), Existing: r.Summary()}
+		}
+	}
+
+	return nil
+}
+
+// sameLocalAddr returns true if the route descriptors of consume rules have the same local public key and port.
+// Inbound packets are consumed by the local address, so it may only be used by a single rule.
+func sameLocalAddr(a, b RouteDescriptor) bool {
+	return a.SrcPK() == b.SrcPK() && a.SrcPort() == b.SrcPort()
+}
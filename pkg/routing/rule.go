@@ -50,9 +50,11 @@ const (
 	RuleIntermediaryForward = RuleType(2)
 )
 
+// ErrBadRule occurs when a rule is of unknown type, or too short to hold the fields of its type.
+var ErrBadRule = errors.New("malformed routing rule")
+
 // Rule represents a routing rule.
 // There are two types of routing rules; App and Forward.
-//
 type Rule []byte
 
 func (r Rule) assertLen(l int) {
@@ -61,6 +63,33 @@ func (r Rule) assertLen(l int) {
 	}
 }
 
+// Validate checks that the rule is of a known type, and long enough to hold the fields of its type.
+// Accessors of a valid rule do not panic.
+func (r Rule) Validate() error {
+	if len(r) < RuleHeaderSize {
+		return fmt.Errorf("%w: %d bytes is shorter than the %d byte header", ErrBadRule, len(r), RuleHeaderSize)
+	}
+
+	var minLen int
+
+	switch t := r.Type(); t {
+	case RuleConsume:
+		minLen = RuleHeaderSize + routeDescriptorSize
+	case RuleForward:
+		minLen = RuleHeaderSize + routeDescriptorSize + 4 + uuidSize
+	case RuleIntermediaryForward:
+		minLen = RuleHeaderSize + 4 + uuidSize
+	default:
+		return fmt.Errorf("%w: unknown rule type %d", ErrBadRule, t)
+	}
+
+	if len(r) < minLen {
+		return fmt.Errorf("%w: %s rule of %d bytes is shorter than %d bytes", ErrBadRule, r.Type(), len(r), minLen)
+	}
+
+	return nil
+}
+
 // KeepAlive returns rule's keep-alive timeout.
 func (r Rule) KeepAlive() time.Duration {
 	r.assertLen(RuleHeaderSize)
@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -67,3 +68,58 @@ func TestIntermediaryForwardRule(t *testing.T) {
 	rule.SetKeyRouteID(3)
 	assert.Equal(t, RouteID(3), rule.KeyRouteID())
 }
+
+func TestRule_Validate(t *testing.T) {
+	trID := uuid.New()
+	pk, _ := cipher.GenerateKeyPair()
+
+	for _, rule := range []Rule{
+		ConsumeRule(time.Minute, 1, pk, pk, 2, 3),
+		ForwardRule(time.Minute, 1, 2, trID, pk, pk, 3, 4),
+		IntermediaryForwardRule(time.Minute, 1, 2, trID),
+	} {
+		assert.NoError(t, rule.Validate(), rule.Type())
+		assert.True(t, errors.Is(rule[:RuleHeaderSize+4].Validate(), ErrBadRule), rule.Type())
+	}
+
+	assert.True(t, errors.Is(Rule(make([]byte, RuleHeaderSize-1)).Validate(), ErrBadRule))
+
+	unknown := IntermediaryForwardRule(time.Minute, 1, 2, trID)
+	unknown.setType(RuleType(9))
+	assert.True(t, errors.Is(unknown.Validate(), ErrBadRule))
+}
+
+func TestFindRuleConflict(t *testing.T) {
+	keepAlive := 2 * time.Minute
+	localPK, _ := cipher.GenerateKeyPair()
+	remotePK, _ := cipher.GenerateKeyPair()
+
+	existing := []Rule{
+		ConsumeRule(keepAlive, 1, localPK, remotePK, 2, 3),
+		IntermediaryForwardRule(keepAlive, 2, 3, uuid.New()),
+	}
+
+	assert.Nil(t, FindRuleConflict(existing, ConsumeRule(keepAlive, 3, localPK, remotePK, 4, 3), false))
+	assert.Nil(t, FindRuleConflict(existing, IntermediaryForwardRule(keepAlive, 2, 4, uuid.New()), true))
+
+	c := FindRuleConflict(existing, IntermediaryForwardRule(keepAlive, 2, 4, uuid.New()), false)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, ConflictDuplicateRouteID, c.Reason)
+		assert.Equal(t, RouteID(2), c.RouteID)
+	}
+
+	c = FindRuleConflict(existing, ConsumeRule(keepAlive, 3, localPK, remotePK, 2, 3), false)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, ConflictOverlappingConsume, c.Reason)
+		assert.Equal(t, RouteID(1), c.RouteID)
+	}
+
+	// Only the local public key and port matter.
+	otherPK, _ := cipher.GenerateKeyPair()
+
+	c = FindRuleConflict(existing, ConsumeRule(keepAlive, 3, localPK, otherPK, 2, 4), false)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, ConflictOverlappingConsume, c.Reason)
+		assert.Equal(t, RouteID(1), c.RouteID)
+	}
+}
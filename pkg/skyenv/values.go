@@ -11,6 +11,8 @@ const (
 	DefaultRouteFinderAddr   = "http://routefinder.skywire.skycoin.com"
 	DefaultUptimeTrackerAddr = "http://uptime-tracker.skywire.skycoin.com"
 	DefaultSetupPK           = "0324579f003e6b4048bae2def4365e634d8e0e3054a20fc7af49daf2a179658557"
+	DefaultSTUNServer1       = "stun.l.google.com:19302"
+	DefaultSTUNServer2       = "stun1.l.google.com:19302"
 )
 
 // Constants for testing deployment.
@@ -27,6 +29,11 @@ const (
 	DmsgAwaitSetupPort = uint16(136) // Listening port of a visor for setup operations.
 	DmsgTransportPort  = uint16(45)  // Listening port of a visor for incoming transports.
 	DmsgHypervisorPort = uint16(46)  // Listening port of a visor for incoming hypervisor connections.
+	DmsgEchoPort       = uint16(47)  // Listening port of a visor's echo server, used by connectivity tests.
+	DmsgStandbyPort    = uint16(48)  // Listening port of a hypervisor for heartbeats of standby visors.
+	DmsgFilesPort      = uint16(49)  // Listening port of a visor for file transfers from hypervisors.
+	BandwidthTestPort  = uint16(50)  // Listening port of a visor for bandwidth tests, on each transport network.
+	DmsgSUDPHPort      = uint16(51)  // Listening port of a visor for sudph hole punching offers.
 )
 
 // Default dmsgpty constants.
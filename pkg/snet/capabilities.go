@@ -0,0 +1,39 @@
+package snet
+
+import (
+	"sync"
+)
+
+// Capabilities describes the properties of a network type, as used by transports.
+type Capabilities struct {
+	NATTraversal bool `json:"nat_traversal"` // Whether visors behind NAT can be reached.
+	Reliable     bool `json:"reliable"`      // Whether delivery is reliable and ordered.
+	Encrypted    bool `json:"encrypted"`     // Whether the network encrypts traffic itself.
+}
+
+var (
+	capabilitiesMu sync.RWMutex
+	capabilities   = map[string]Capabilities{ // nolint: gochecknoglobals
+		DmsgType:  {NATTraversal: true, Reliable: true, Encrypted: true},
+		STCPType:  {NATTraversal: false, Reliable: true, Encrypted: false},
+		SUDPHType: {NATTraversal: true, Reliable: true, Encrypted: false},
+		KCPType:   {NATTraversal: false, Reliable: true, Encrypted: false},
+	}
+)
+
+// RegisterCapabilities registers (or replaces) the capabilities of a network type.
+// Network types added by plugins should call this so the capabilities are reported to hypervisors.
+func RegisterCapabilities(netType string, c Capabilities) {
+	capabilitiesMu.Lock()
+	capabilities[netType] = c
+	capabilitiesMu.Unlock()
+}
+
+// NetworkCapabilities returns the registered capabilities of a network type.
+func NetworkCapabilities(netType string) (Capabilities, bool) {
+	capabilitiesMu.RLock()
+	c, ok := capabilities[netType]
+	capabilitiesMu.RUnlock()
+
+	return c, ok
+}
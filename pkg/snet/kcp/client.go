@@ -0,0 +1,283 @@
+package kcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/snet/stcp"
+)
+
+// Type is kcp type.
+const Type = "kcp"
+
+// Client is the central control for incoming and outgoing 'kcp' connections. Like stcp, remotes are resolved with
+// a PKTable and connections are authenticated with the stcp handshake, but they are streamed with KCP over UDP,
+// which recovers from loss faster than TCP on high-latency or lossy links.
+type Client struct {
+	log *logging.Logger
+
+	lPK  cipher.PubKey
+	lSK  cipher.SecKey
+	t    stcp.PKTable
+	tMx  sync.RWMutex
+	conf Config
+	p    *stcp.Porter
+
+	kcpL    *Listener   // listener connections are dialed from
+	kcpLs   []*Listener // all listeners, which are closed with the client
+	serving bool
+	lMap    map[uint16]*stcp.Listener // key: lPort
+	mx      sync.Mutex
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewClient creates a kcp Client, of which sessions are configured with conf.
+func NewClient(pk cipher.PubKey, sk cipher.SecKey, t stcp.PKTable, conf Config) *Client {
+	return &Client{
+		log:  logging.MustGetLogger(Type),
+		lPK:  pk,
+		lSK:  sk,
+		t:    t,
+		conf: conf,
+		p:    stcp.NewPorter(stcp.PorterMinEphemeral),
+		lMap: make(map[uint16]*stcp.Listener),
+		done: make(chan struct{}),
+	}
+}
+
+// SetLogger sets a logger for Client.
+func (c *Client) SetLogger(log *logging.Logger) {
+	c.log = log
+}
+
+// SetTable replaces the PKTable used to resolve the addresses of remote public keys.
+// Existing connections are not affected.
+func (c *Client) SetTable(t stcp.PKTable) {
+	c.tMx.Lock()
+	c.t = t
+	c.tMx.Unlock()
+}
+
+func (c *Client) table() stcp.PKTable {
+	c.tMx.RLock()
+	defer c.tMx.RUnlock()
+
+	return c.t
+}
+
+// Serve binds the UDP socket of the client to udpAddr, and accepts connections of remotes on it.
+func (c *Client) Serve(udpAddr string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.serving {
+		return errors.New("already listening")
+	}
+
+	// A socket bound for dialing is replaced, but is kept for its connections until the client is closed.
+	kcpL, err := c.listen(udpAddr)
+	if err != nil {
+		return err
+	}
+
+	c.kcpL, c.serving = kcpL, true
+	c.log.Infof("listening on udp addr: %v", kcpL.Addr())
+
+	return nil
+}
+
+// listen binds a UDP socket to udpAddr, and accepts sessions on it. It is called with the lock held.
+func (c *Client) listen(udpAddr string) (*Listener, error) {
+	addr, err := net.ResolveUDPAddr("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	kcpL, err := Listen(conn, c.conf)
+	if err != nil {
+		_ = conn.Close() // nolint:errcheck
+		return nil, err
+	}
+
+	c.kcpLs = append(c.kcpLs, kcpL)
+	go c.serve(kcpL)
+
+	return kcpL, nil
+}
+
+func (c *Client) serve(kcpL *Listener) {
+	for {
+		sess, err := kcpL.AcceptSession()
+		if err != nil {
+			if !c.isClosed() {
+				c.log.Debugf("stopped accepting kcp sessions on %v: %v", kcpL.Addr(), err)
+			}
+
+			return
+		}
+
+		go func() {
+			if err := c.acceptSession(sess); err != nil {
+				c.log.Warnf("failed to accept incoming connection: %v", err)
+			}
+		}()
+	}
+}
+
+func (c *Client) acceptSession(sess *Session) error {
+	var lis *stcp.Listener
+
+	hs := stcp.ResponderHandshake(func(f2 stcp.Frame2) error {
+		c.mx.Lock()
+		defer c.mx.Unlock()
+
+		var ok bool
+		if lis, ok = c.lMap[f2.DstAddr.Port]; !ok {
+			return errors.New("not listening on given port")
+		}
+
+		return nil
+	})
+
+	conn, err := stcp.NewConn(sess, time.Now().Add(stcp.HandshakeTimeout), hs, nil)
+	if err != nil {
+		return err
+	}
+
+	return lis.Introduce(conn)
+}
+
+// Dial dials a new connection to specified remote public key and port.
+// If the client is not serving, connections are dialed from a UDP socket bound to a random port.
+func (c *Client) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (*stcp.Conn, error) {
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	udpAddr, ok := c.table().Addr(rPK)
+	if !ok {
+		return nil, fmt.Errorf("pk table: entry of %s does not exist", rPK)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	kcpL, err := c.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := kcpL.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	lPort, freePort, err := c.p.ReserveEphemeral(ctx)
+	if err != nil {
+		_ = sess.Close() // nolint:errcheck
+		return nil, err
+	}
+
+	hs := stcp.InitiatorHandshake(c.lSK, dmsg.Addr{PK: c.lPK, Port: lPort}, dmsg.Addr{PK: rPK, Port: rPort})
+
+	return stcp.NewConn(sess, time.Now().Add(stcp.HandshakeTimeout), hs, freePort)
+}
+
+// dialer returns the listener connections are dialed from, which is bound to a random port if not serving.
+func (c *Client) dialer() (*Listener, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	if c.kcpL == nil {
+		kcpL, err := c.listen(":0")
+		if err != nil {
+			return nil, err
+		}
+
+		c.kcpL = kcpL
+	}
+
+	return c.kcpL, nil
+}
+
+// Listen creates a new listener for kcp.
+// The created Listener cannot actually accept remote connections unless Serve is called beforehand.
+func (c *Client) Listen(lPort uint16) (*stcp.Listener, error) {
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	ok, freePort := c.p.Reserve(lPort)
+	if !ok {
+		return nil, errors.New("port is already occupied")
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	lAddr := dmsg.Addr{PK: c.lPK, Port: lPort}
+	lis := stcp.NewListener(lAddr, freePort)
+	c.lMap[lPort] = lis
+
+	return lis, nil
+}
+
+// Close closes the Client.
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.once.Do(func() {
+		close(c.done)
+
+		c.mx.Lock()
+		defer c.mx.Unlock()
+
+		for _, kcpL := range c.kcpLs {
+			_ = kcpL.Close() // nolint:errcheck
+		}
+
+		for _, lis := range c.lMap {
+			_ = lis.Close() // nolint:errcheck
+		}
+	})
+
+	return nil
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Type returns the stream type.
+func (c *Client) Type() string {
+	return Type
+}
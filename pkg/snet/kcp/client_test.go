@@ -0,0 +1,61 @@
+package kcp
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/snet/stcp"
+)
+
+func TestClient(t *testing.T) {
+	conf := Config{SendWindow: 512, DataShards: 10, ParityShards: 3}
+
+	pk1, sk1 := cipher.GenerateKeyPair()
+	pk2, sk2 := cipher.GenerateKeyPair()
+
+	c1 := NewClient(pk1, sk1, stcp.NewTable(nil), conf)
+	c2 := NewClient(pk2, sk2, stcp.NewTable(nil), conf)
+
+	defer func() {
+		require.NoError(t, c1.Close())
+		require.NoError(t, c2.Close())
+	}()
+
+	// c1 is not serving, so it dials from a random port.
+	require.NoError(t, c2.Serve("127.0.0.1:0"))
+	c1.SetTable(stcp.NewTable(map[cipher.PubKey]string{pk2: c2.kcpL.Addr().String()}))
+
+	lis, err := c2.Listen(10)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err = c1.Dial(ctx, pk1, 10)
+	require.Error(t, err)
+
+	conn1, err := c1.Dial(ctx, pk2, 10)
+	require.NoError(t, err)
+
+	conn2, err := lis.Accept()
+	require.NoError(t, err)
+
+	require.Equal(t, conn1.LocalAddr(), conn2.RemoteAddr())
+	require.Equal(t, conn1.RemoteAddr(), conn2.LocalAddr())
+
+	_, err = conn1.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn2, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	require.NoError(t, conn1.Close())
+	require.NoError(t, conn2.Close())
+}
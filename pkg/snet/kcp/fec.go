@@ -0,0 +1,307 @@
+package kcp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	// fecHeaderSize is the size of the header of FEC packets: the sequence number of the shard, followed by the
+	// numbers of data and parity shards of its group. Groups are identified by seq / (data + parity).
+	fecHeaderSize = 6
+
+	// fecOverhead is the size FEC adds to packets: the header, and the size of the packet within its data shard.
+	fecOverhead = fecHeaderSize + 2
+
+	// fecMaxGroups is the number of groups buffered by remotes, for the recovery of packets.
+	fecMaxGroups = 64
+)
+
+// fecConn adds forward error correction to the packets of a packet connection. Packets sent to each remote are
+// grouped by data shards, and parity shards are sent after each group, from which the remote recovers as many lost
+// packets of the group. Remotes must use FEC as well, but may use different numbers of shards.
+type fecConn struct {
+	net.PacketConn
+	data, parity int
+	rs           reedsolomon.Encoder // nil without parity shards
+
+	mx       sync.Mutex
+	encoders map[string]*fecEncoder // key: remote address
+
+	// Decoding is only done by ReadFrom, which is not called concurrently.
+	decoders  map[string]*fecDecoder
+	codecs    map[[2]int]reedsolomon.Encoder // key: numbers of data and parity shards
+	recovered []fecPacket
+	buf       []byte
+}
+
+type fecPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+type fecEncoder struct {
+	seq    uint32
+	shards [][]byte
+}
+
+type fecDecoder struct {
+	groups map[uint32]*fecGroup
+	newest uint32
+}
+
+type fecGroup struct {
+	shards [][]byte
+	count  int
+	done   bool
+}
+
+func newFECConn(conn net.PacketConn, data, parity int) (*fecConn, error) {
+	c := &fecConn{
+		PacketConn: conn,
+		data:       data,
+		parity:     parity,
+		encoders:   make(map[string]*fecEncoder),
+		decoders:   make(map[string]*fecDecoder),
+		codecs:     make(map[[2]int]reedsolomon.Encoder),
+		buf:        make([]byte, readBufSize),
+	}
+
+	if parity == 0 {
+		return c, nil
+	}
+
+	rs, err := reedsolomon.New(data, parity)
+	if err != nil {
+		return nil, err
+	}
+
+	c.rs = rs
+	c.codecs[[2]int{data, parity}] = rs
+
+	return c, nil
+}
+
+// WriteTo implements net.PacketConn. It sends b as a data shard, followed by parity shards if it completes a group.
+func (c *fecConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	enc, ok := c.encoders[addr.String()]
+	if !ok {
+		enc = &fecEncoder{}
+		c.encoders[addr.String()] = enc
+	}
+
+	shard := make([]byte, 2+len(b))
+	binary.LittleEndian.PutUint16(shard, uint16(len(b)))
+	copy(shard[2:], b)
+
+	if _, err := c.writeShard(enc, shard, addr); err != nil {
+		return 0, err
+	}
+
+	if c.parity == 0 {
+		return len(b), nil
+	}
+
+	enc.shards = append(enc.shards, shard)
+	if len(enc.shards) < c.data {
+		return len(b), nil
+	}
+
+	// The group is complete: pad data shards to the same size, and send parity shards.
+	size := 0
+	for _, s := range enc.shards {
+		if len(s) > size {
+			size = len(s)
+		}
+	}
+
+	shards := make([][]byte, c.data+c.parity)
+	for i, s := range enc.shards {
+		shards[i] = append(s, make([]byte, size-len(s))...)
+	}
+
+	for i := c.data; i < len(shards); i++ {
+		shards[i] = make([]byte, size)
+	}
+
+	err := c.rs.Encode(shards)
+	enc.shards = enc.shards[:0]
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range shards[c.data:] {
+		if _, err := c.writeShard(enc, s, addr); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (c *fecConn) writeShard(enc *fecEncoder, shard []byte, addr net.Addr) (int, error) {
+	pkt := make([]byte, fecHeaderSize+len(shard))
+	binary.LittleEndian.PutUint32(pkt, enc.seq)
+	pkt[4], pkt[5] = byte(c.data), byte(c.parity)
+	copy(pkt[fecHeaderSize:], shard)
+
+	enc.seq++
+
+	return c.PacketConn.WriteTo(pkt, addr)
+}
+
+// ReadFrom implements net.PacketConn. It returns the packets of data shards received, and those recovered.
+func (c *fecConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		if len(c.recovered) > 0 {
+			p := c.recovered[0]
+			c.recovered = c.recovered[1:]
+
+			return copy(b, p.data), p.addr, nil
+		}
+
+		n, addr, err := c.PacketConn.ReadFrom(c.buf)
+		if err != nil {
+			return 0, addr, err
+		}
+
+		if pkt, ok := c.decode(c.buf[:n], addr); ok {
+			return copy(b, pkt), addr, nil
+		}
+	}
+}
+
+// decode handles a shard received from addr. It returns the packet of data shards.
+func (c *fecConn) decode(pkt []byte, addr net.Addr) ([]byte, bool) {
+	if len(pkt) < fecOverhead {
+		return nil, false
+	}
+
+	seq := binary.LittleEndian.Uint32(pkt)
+	data, parity := int(pkt[4]), int(pkt[5])
+	shard := pkt[fecHeaderSize:]
+
+	n := data + parity
+	if data == 0 {
+		return nil, false
+	}
+
+	index := int(seq % uint32(n))
+
+	var packet []byte
+
+	if index < data {
+		size := int(binary.LittleEndian.Uint16(shard))
+		if len(shard) < 2+size {
+			return nil, false
+		}
+
+		packet = shard[2 : 2+size]
+	}
+
+	if parity == 0 {
+		return packet, packet != nil
+	}
+
+	rs, ok := c.codecs[[2]int{data, parity}]
+	if !ok {
+		var err error
+		if rs, err = reedsolomon.New(data, parity); err != nil {
+			return packet, packet != nil
+		}
+
+		c.codecs[[2]int{data, parity}] = rs
+	}
+
+	dec, ok := c.decoders[addr.String()]
+	if !ok {
+		dec = &fecDecoder{groups: make(map[uint32]*fecGroup)}
+		c.decoders[addr.String()] = dec
+	}
+
+	c.buffer(dec, rs, data, parity, seq, index, shard, addr)
+
+	return packet, packet != nil
+}
+
+// buffer buffers shard in its group, and recovers the lost data shards of the group once possible.
+func (c *fecConn) buffer(dec *fecDecoder, rs reedsolomon.Encoder, data, parity int, seq uint32, index int,
+	shard []byte, addr net.Addr) {
+	n := data + parity
+	id := seq / uint32(n)
+
+	g, ok := dec.groups[id]
+	if !ok {
+		if len(dec.groups) > 0 && timeDiff(id, dec.newest) <= -fecMaxGroups {
+			return
+		}
+
+		g = &fecGroup{shards: make([][]byte, n)}
+		dec.groups[id] = g
+
+		if len(dec.groups) == 1 || timeDiff(id, dec.newest) > 0 {
+			dec.newest = id
+		}
+
+		for old := range dec.groups {
+			if timeDiff(old, dec.newest) <= -fecMaxGroups {
+				delete(dec.groups, old)
+			}
+		}
+	}
+
+	if g.done || len(g.shards) != n || g.shards[index] != nil {
+		return
+	}
+
+	g.shards[index] = append([]byte(nil), shard...)
+	g.count++
+
+	if g.count < data {
+		return
+	}
+
+	g.done = true
+
+	// Shards are padded to the size of the parity shards.
+	size := 0
+	for _, s := range g.shards {
+		if s != nil && len(s) > size {
+			size = len(s)
+		}
+	}
+
+	for i, s := range g.shards {
+		if s != nil {
+			g.shards[i] = append(s, make([]byte, size-len(s))...)
+		}
+	}
+
+	var recovered []int
+
+	for i, s := range g.shards[:data] {
+		if s == nil {
+			recovered = append(recovered, i)
+		}
+	}
+
+	if err := rs.ReconstructData(g.shards); err != nil {
+		return
+	}
+
+	for _, i := range recovered {
+		s := g.shards[i]
+		if size := int(binary.LittleEndian.Uint16(s)); len(s) >= 2+size && size > 0 {
+			c.recovered = append(c.recovered, fecPacket{data: s[2 : 2+size], addr: addr})
+		}
+	}
+
+	g.shards = nil
+}
@@ -0,0 +1,766 @@
+// Package kcp implements KCP, an ARQ protocol which provides reliable and ordered streams over
+// unreliable packet networks such as UDP, trading bandwidth for latency. It follows the reference
+// implementation (https://github.com/skywind3000/kcp), so that segments are compatible with it.
+package kcp
+
+import (
+	"encoding/binary"
+)
+
+// Protocol constants, as defined by the reference implementation.
+const (
+	rtoNoDelay = 30    // minimum retransmission timeout (ms) in no delay mode
+	rtoMin     = 100   // minimum retransmission timeout (ms)
+	rtoDefault = 200   // initial retransmission timeout (ms)
+	rtoMax     = 60000 // maximum retransmission timeout (ms)
+
+	cmdPush = 81 // push data
+	cmdAck  = 82 // acknowledge a segment
+	cmdWask = 83 // ask for the receive window of the remote
+	cmdWins = 84 // tell the receive window to the remote
+	cmdFin  = 85 // end of the stream, which is not part of the reference implementation
+
+	askSend = 1 // need to send cmdWask
+	askTell = 2 // need to send cmdWins
+
+	wndSnd      = 32
+	wndRcv      = 128 // must be at least the maximum number of fragments of a message
+	mtuDefault  = 1400
+	interval    = 100 // default flush interval (ms)
+	overhead    = 24  // size of segment headers
+	deadLink    = 20  // transmissions of a segment after which the link is dead
+	threshInit  = 2
+	threshMin   = 2
+	probeInit   = 7000   // initial interval (ms) of window probes
+	probeLimit  = 120000 // maximum interval (ms) of window probes
+	maxFragment = 255
+)
+
+// timeDiff returns a - b, for timestamps and sequence numbers which wrap around.
+func timeDiff(a, b uint32) int32 {
+	return int32(a - b)
+}
+
+// segment is a KCP segment.
+type segment struct {
+	conv uint32
+	cmd  uint8
+	frg  uint8 // index of the fragment of a message, counting down to 0
+	wnd  uint16
+	ts   uint32
+	sn   uint32
+	una  uint32
+
+	rto      uint32
+	xmit     uint32 // transmissions
+	resendts uint32
+	fastack  uint32 // acknowledgements of later segments since the last transmission
+
+	data []byte
+}
+
+// encode appends the header of the segment to buf.
+func (seg *segment) encode(buf []byte) []byte {
+	var h [overhead]byte
+
+	binary.LittleEndian.PutUint32(h[0:], seg.conv)
+	h[4] = seg.cmd
+	h[5] = seg.frg
+	binary.LittleEndian.PutUint16(h[6:], seg.wnd)
+	binary.LittleEndian.PutUint32(h[8:], seg.ts)
+	binary.LittleEndian.PutUint32(h[12:], seg.sn)
+	binary.LittleEndian.PutUint32(h[16:], seg.una)
+	binary.LittleEndian.PutUint32(h[20:], uint32(len(seg.data)))
+
+	return append(buf, h[:]...)
+}
+
+type ackItem struct {
+	sn uint32
+	ts uint32
+}
+
+// control is the control block of a KCP connection. It is not safe for concurrent use, and is driven by
+// calling update regularly, with input for each packet received, and with send and recv for the data streamed.
+type control struct {
+	conv, mtu, mss, state        uint32
+	sndUna, sndNxt, rcvNxt       uint32
+	ssthresh                     uint32
+	rxRttval, rxSrtt             int32
+	rxRto, rxMinRto              uint32
+	sndWnd, rcvWnd, rmtWnd, cwnd uint32
+	probe                        uint32
+	current, interval, tsFlush   uint32
+	nodelay                      bool
+	updated                      bool
+	tsProbe, probeWait           uint32
+	deadLink, incr               uint32
+	fastResend                   int
+	noCwnd                       bool
+	stream                       bool
+	finRcvd                      bool // whether the remote ended the stream
+	sndQueue, rcvQueue           []segment
+	sndBuf, rcvBuf               []segment
+	ackList                      []ackItem
+	buffer                       []byte
+	output                       func(buf []byte)
+}
+
+// newControl creates the control block of the connection conv, which sends packets with output.
+// Packets passed to output are only valid until output returns.
+func newControl(conv uint32, output func(buf []byte)) *control {
+	return &control{
+		conv:     conv,
+		sndWnd:   wndSnd,
+		rcvWnd:   wndRcv,
+		rmtWnd:   wndRcv,
+		mtu:      mtuDefault,
+		mss:      mtuDefault - overhead,
+		buffer:   make([]byte, 0, mtuDefault),
+		rxRto:    rtoDefault,
+		rxMinRto: rtoMin,
+		interval: interval,
+		tsFlush:  interval,
+		ssthresh: threshInit,
+		deadLink: deadLink,
+		output:   output,
+	}
+}
+
+// peekSize returns the size of the next message to receive, or -1 if none is complete yet.
+func (c *control) peekSize() int {
+	if len(c.rcvQueue) == 0 {
+		return -1
+	}
+
+	seg := &c.rcvQueue[0]
+	if seg.cmd == cmdFin {
+		return -1
+	}
+
+	if seg.frg == 0 {
+		return len(seg.data)
+	}
+
+	if len(c.rcvQueue) < int(seg.frg)+1 {
+		return -1
+	}
+
+	length := 0
+
+	for i := range c.rcvQueue {
+		seg := &c.rcvQueue[i]
+		length += len(seg.data)
+
+		if seg.frg == 0 {
+			break
+		}
+	}
+
+	return length
+}
+
+// recv copies the next message to buf, which must be at least as large as peekSize.
+// It returns the size of the message, or -1 if none is complete yet.
+func (c *control) recv(buf []byte) int {
+	size := c.peekSize()
+	if size < 0 || size > len(buf) {
+		return -1
+	}
+
+	recovered := len(c.rcvQueue) >= int(c.rcvWnd)
+
+	n, count := 0, 0
+
+	for i := range c.rcvQueue {
+		seg := &c.rcvQueue[i]
+		n += copy(buf[n:], seg.data)
+		count++
+
+		if seg.frg == 0 {
+			break
+		}
+	}
+
+	c.rcvQueue = removeFront(c.rcvQueue, count)
+	c.moveRcvBuf()
+
+	// Tell the remote that the window opened up again.
+	if len(c.rcvQueue) < int(c.rcvWnd) && recovered {
+		c.probe |= askTell
+	}
+
+	return n
+}
+
+// send queues buf to be sent. It returns false if buf needs more fragments than a message may have.
+func (c *control) send(buf []byte) bool {
+	if c.stream && len(c.sndQueue) > 0 {
+		// Fill up the last segment.
+		last := &c.sndQueue[len(c.sndQueue)-1]
+		if n := int(c.mss) - len(last.data); n > 0 && last.cmd != cmdFin {
+			if n > len(buf) {
+				n = len(buf)
+			}
+
+			last.data = append(last.data, buf[:n]...)
+			buf = buf[n:]
+		}
+	}
+
+	if len(buf) == 0 {
+		return true
+	}
+
+	count := (len(buf) + int(c.mss) - 1) / int(c.mss)
+	if count > maxFragment {
+		return false
+	}
+
+	for i := 0; i < count; i++ {
+		size := len(buf)
+		if size > int(c.mss) {
+			size = int(c.mss)
+		}
+
+		seg := segment{data: make([]byte, size, c.mss)}
+		copy(seg.data, buf)
+
+		if !c.stream {
+			seg.frg = uint8(count - i - 1)
+		}
+
+		c.sndQueue = append(c.sndQueue, seg)
+		buf = buf[size:]
+	}
+
+	return true
+}
+
+// sendFin queues the end of the stream, to be received after all the data queued before.
+func (c *control) sendFin() {
+	c.sndQueue = append(c.sndQueue, segment{cmd: cmdFin})
+}
+
+// finished reports whether all the data was received, and the remote ended the stream.
+func (c *control) finished() bool {
+	return len(c.rcvQueue) > 0 && c.rcvQueue[0].cmd == cmdFin
+}
+
+func (c *control) updateAck(rtt int32) {
+	if c.rxSrtt == 0 {
+		c.rxSrtt = rtt
+		c.rxRttval = rtt / 2
+	} else {
+		delta := rtt - c.rxSrtt
+		if delta < 0 {
+			delta = -delta
+		}
+
+		c.rxRttval = (3*c.rxRttval + delta) / 4
+		c.rxSrtt = (7*c.rxSrtt + rtt) / 8
+
+		if c.rxSrtt < 1 {
+			c.rxSrtt = 1
+		}
+	}
+
+	rto := uint32(c.rxSrtt) + maxUint32(c.interval, uint32(4*c.rxRttval))
+	c.rxRto = boundUint32(c.rxMinRto, rto, rtoMax)
+}
+
+func (c *control) shrinkBuf() {
+	if len(c.sndBuf) > 0 {
+		c.sndUna = c.sndBuf[0].sn
+	} else {
+		c.sndUna = c.sndNxt
+	}
+}
+
+func (c *control) parseAck(sn uint32) {
+	if timeDiff(sn, c.sndUna) < 0 || timeDiff(sn, c.sndNxt) >= 0 {
+		return
+	}
+
+	for i := range c.sndBuf {
+		seg := &c.sndBuf[i]
+		if sn == seg.sn {
+			c.sndBuf = append(c.sndBuf[:i], c.sndBuf[i+1:]...)
+			break
+		}
+
+		if timeDiff(sn, seg.sn) < 0 {
+			break
+		}
+	}
+}
+
+func (c *control) parseFastAck(sn uint32) {
+	if timeDiff(sn, c.sndUna) < 0 || timeDiff(sn, c.sndNxt) >= 0 {
+		return
+	}
+
+	for i := range c.sndBuf {
+		seg := &c.sndBuf[i]
+		if timeDiff(sn, seg.sn) < 0 {
+			break
+		}
+
+		if sn != seg.sn {
+			seg.fastack++
+		}
+	}
+}
+
+func (c *control) parseUna(una uint32) {
+	count := 0
+
+	for i := range c.sndBuf {
+		if timeDiff(una, c.sndBuf[i].sn) <= 0 {
+			break
+		}
+
+		count++
+	}
+
+	c.sndBuf = removeFront(c.sndBuf, count)
+}
+
+func (c *control) parseData(newSeg segment) {
+	sn := newSeg.sn
+	if timeDiff(sn, c.rcvNxt+c.rcvWnd) >= 0 || timeDiff(sn, c.rcvNxt) < 0 {
+		return
+	}
+
+	// Insert the segment in order, unless it is a duplicate.
+	i := len(c.rcvBuf) - 1
+	for ; i >= 0; i-- {
+		seg := &c.rcvBuf[i]
+		if seg.sn == sn {
+			return
+		}
+
+		if timeDiff(sn, seg.sn) > 0 {
+			break
+		}
+	}
+
+	c.rcvBuf = append(c.rcvBuf, segment{})
+	copy(c.rcvBuf[i+2:], c.rcvBuf[i+1:])
+	c.rcvBuf[i+1] = newSeg
+
+	c.moveRcvBuf()
+}
+
+// moveRcvBuf moves the segments which are next in order from rcvBuf to rcvQueue.
+func (c *control) moveRcvBuf() {
+	count := 0
+
+	for i := range c.rcvBuf {
+		seg := &c.rcvBuf[i]
+		if seg.sn != c.rcvNxt || len(c.rcvQueue)+count >= int(c.rcvWnd) {
+			break
+		}
+
+		if seg.cmd == cmdFin {
+			c.finRcvd = true
+		}
+
+		c.rcvNxt++
+		count++
+	}
+
+	if count > 0 {
+		c.rcvQueue = append(c.rcvQueue, c.rcvBuf[:count]...)
+		c.rcvBuf = removeFront(c.rcvBuf, count)
+	}
+}
+
+// input handles a packet received from the remote. It returns false if the packet is not a valid KCP packet
+// of the connection.
+func (c *control) input(data []byte) bool {
+	prevUna := c.sndUna
+
+	var (
+		maxAck uint32
+		flag   bool
+	)
+
+	if len(data) < overhead {
+		return false
+	}
+
+	for len(data) >= overhead {
+		var seg segment
+
+		seg.conv = binary.LittleEndian.Uint32(data)
+		seg.cmd = data[4]
+		seg.frg = data[5]
+		seg.wnd = binary.LittleEndian.Uint16(data[6:])
+		seg.ts = binary.LittleEndian.Uint32(data[8:])
+		seg.sn = binary.LittleEndian.Uint32(data[12:])
+		seg.una = binary.LittleEndian.Uint32(data[16:])
+		length := binary.LittleEndian.Uint32(data[20:])
+		data = data[overhead:]
+
+		if seg.conv != c.conv || uint32(len(data)) < length {
+			return false
+		}
+
+		if seg.cmd < cmdPush || seg.cmd > cmdFin {
+			return false
+		}
+
+		c.rmtWnd = uint32(seg.wnd)
+		c.parseUna(seg.una)
+		c.shrinkBuf()
+
+		switch seg.cmd {
+		case cmdAck:
+			if rtt := timeDiff(c.current, seg.ts); rtt >= 0 {
+				c.updateAck(rtt)
+			}
+
+			c.parseAck(seg.sn)
+			c.shrinkBuf()
+
+			if !flag || timeDiff(seg.sn, maxAck) > 0 {
+				flag = true
+				maxAck = seg.sn
+			}
+		case cmdPush, cmdFin:
+			if timeDiff(seg.sn, c.rcvNxt+c.rcvWnd) < 0 {
+				c.ackList = append(c.ackList, ackItem{sn: seg.sn, ts: seg.ts})
+
+				if timeDiff(seg.sn, c.rcvNxt) >= 0 {
+					seg.data = make([]byte, length)
+					copy(seg.data, data)
+					c.parseData(seg)
+				}
+			}
+		case cmdWask:
+			c.probe |= askTell
+		case cmdWins:
+			// The window was already updated.
+		}
+
+		data = data[length:]
+	}
+
+	if flag {
+		c.parseFastAck(maxAck)
+	}
+
+	// Grow the congestion window as segments get acknowledged.
+	if timeDiff(c.sndUna, prevUna) > 0 && c.cwnd < c.rmtWnd {
+		mss := c.mss
+		if c.cwnd < c.ssthresh {
+			c.cwnd++
+			c.incr += mss
+		} else {
+			if c.incr < mss {
+				c.incr = mss
+			}
+
+			c.incr += (mss*mss)/c.incr + (mss / 16)
+			if (c.cwnd+1)*mss <= c.incr {
+				c.cwnd = (c.incr + mss - 1) / mss
+			}
+		}
+
+		if c.cwnd > c.rmtWnd {
+			c.cwnd = c.rmtWnd
+			c.incr = c.rmtWnd * mss
+		}
+	}
+
+	return true
+}
+
+func (c *control) wndUnused() uint16 {
+	if len(c.rcvQueue) < int(c.rcvWnd) {
+		return uint16(int(c.rcvWnd) - len(c.rcvQueue))
+	}
+
+	return 0
+}
+
+// flush sends pending acknowledgements, window probes and segments.
+func (c *control) flush() {
+	if !c.updated {
+		return
+	}
+
+	current := c.current
+	buf := c.buffer[:0]
+
+	// makeSpace outputs the buffered packet if it cannot take space more bytes.
+	makeSpace := func(space int) {
+		if len(buf)+space > int(c.mtu) {
+			c.output(buf)
+			buf = buf[:0]
+		}
+	}
+
+	seg := segment{conv: c.conv, cmd: cmdAck, wnd: c.wndUnused(), una: c.rcvNxt}
+
+	for _, ack := range c.ackList {
+		makeSpace(overhead)
+		seg.sn, seg.ts = ack.sn, ack.ts
+		buf = seg.encode(buf)
+	}
+
+	c.ackList = c.ackList[:0]
+
+	// Probe the window of the remote while it is closed.
+	if c.rmtWnd == 0 {
+		if c.probeWait == 0 {
+			c.probeWait = probeInit
+			c.tsProbe = current + c.probeWait
+		} else if timeDiff(current, c.tsProbe) >= 0 {
+			if c.probeWait < probeInit {
+				c.probeWait = probeInit
+			}
+
+			c.probeWait += c.probeWait / 2
+			if c.probeWait > probeLimit {
+				c.probeWait = probeLimit
+			}
+
+			c.tsProbe = current + c.probeWait
+			c.probe |= askSend
+		}
+	} else {
+		c.tsProbe = 0
+		c.probeWait = 0
+	}
+
+	seg.sn, seg.ts = 0, 0
+
+	if c.probe&askSend != 0 {
+		seg.cmd = cmdWask
+		makeSpace(overhead)
+		buf = seg.encode(buf)
+	}
+
+	if c.probe&askTell != 0 {
+		seg.cmd = cmdWins
+		makeSpace(overhead)
+		buf = seg.encode(buf)
+	}
+
+	c.probe = 0
+
+	cwnd := minUint32(c.sndWnd, c.rmtWnd)
+	if !c.noCwnd {
+		cwnd = minUint32(c.cwnd, cwnd)
+	}
+
+	// Move segments from the send queue to the send buffer, as the window allows.
+	count := 0
+
+	for ; count < len(c.sndQueue) && timeDiff(c.sndNxt, c.sndUna+cwnd) < 0; count++ {
+		newSeg := c.sndQueue[count]
+		newSeg.conv = c.conv
+		if newSeg.cmd != cmdFin {
+			newSeg.cmd = cmdPush
+		}
+
+		newSeg.sn = c.sndNxt
+		c.sndNxt++
+		c.sndBuf = append(c.sndBuf, newSeg)
+	}
+
+	c.sndQueue = removeFront(c.sndQueue, count)
+
+	resent := uint32(c.fastResend)
+	if c.fastResend <= 0 {
+		resent = 0xffffffff
+	}
+
+	rtoMin := c.rxRto >> 3
+	if c.nodelay {
+		rtoMin = 0
+	}
+
+	change, lost := false, false
+
+	for i := range c.sndBuf {
+		s := &c.sndBuf[i]
+		needSend := false
+
+		switch {
+		case s.xmit == 0:
+			needSend = true
+			s.rto = c.rxRto
+			s.resendts = current + s.rto + rtoMin
+		case timeDiff(current, s.resendts) >= 0:
+			needSend = true
+
+			if c.nodelay {
+				s.rto += c.rxRto / 2
+			} else {
+				s.rto += maxUint32(s.rto, c.rxRto)
+			}
+
+			s.resendts = current + s.rto
+			lost = true
+		case s.fastack >= resent:
+			needSend = true
+			s.fastack = 0
+			s.resendts = current + s.rto
+			change = true
+		}
+
+		if !needSend {
+			continue
+		}
+
+		s.xmit++
+		s.ts = current
+		s.wnd = seg.wnd
+		s.una = c.rcvNxt
+
+		makeSpace(overhead + len(s.data))
+		buf = s.encode(buf)
+		buf = append(buf, s.data...)
+
+		if s.xmit >= c.deadLink {
+			c.state = 0xffffffff
+		}
+	}
+
+	if len(buf) > 0 {
+		c.output(buf)
+	}
+
+	// Shrink the congestion window on losses.
+	if change {
+		inflight := c.sndNxt - c.sndUna
+		c.ssthresh = maxUint32(inflight/2, threshMin)
+		c.cwnd = c.ssthresh + resent
+		c.incr = c.cwnd * c.mss
+	}
+
+	if lost {
+		c.ssthresh = maxUint32(cwnd/2, threshMin)
+		c.cwnd = 1
+		c.incr = c.mss
+	}
+
+	if c.cwnd < 1 {
+		c.cwnd = 1
+		c.incr = c.mss
+	}
+}
+
+// update sets the current time (in ms) and flushes if the flush interval passed.
+func (c *control) update(current uint32) {
+	c.current = current
+
+	if !c.updated {
+		c.updated = true
+		c.tsFlush = current
+	}
+
+	slap := timeDiff(current, c.tsFlush)
+	if slap >= 10000 || slap < -10000 {
+		c.tsFlush = current
+		slap = 0
+	}
+
+	if slap >= 0 {
+		c.tsFlush += c.interval
+		if timeDiff(current, c.tsFlush) >= 0 {
+			c.tsFlush = current + c.interval
+		}
+
+		c.flush()
+	}
+}
+
+// setMTU sets the maximum size of packets passed to output.
+func (c *control) setMTU(mtu int) bool {
+	if mtu < 50 {
+		return false
+	}
+
+	c.mtu = uint32(mtu)
+	c.mss = c.mtu - overhead
+	c.buffer = make([]byte, 0, mtu)
+
+	return true
+}
+
+// setNoDelay tunes the control for latency: nodelay lowers retransmission timeouts, intervalMs is the flush
+// interval, resend is the number of acknowledgements of later segments after which a segment is retransmitted
+// (0 to disable fast retransmission) and noCwnd disables congestion control.
+func (c *control) setNoDelay(nodelay bool, intervalMs, resend int, noCwnd bool) {
+	c.nodelay = nodelay
+	c.rxMinRto = rtoMin
+
+	if nodelay {
+		c.rxMinRto = rtoNoDelay
+	}
+
+	if intervalMs > 0 {
+		c.interval = boundUint32(10, uint32(intervalMs), 5000)
+	}
+
+	c.fastResend = resend
+	c.noCwnd = noCwnd
+}
+
+// setWindow sets the maximum numbers of segments in flight, and awaiting to be received.
+func (c *control) setWindow(snd, rcv int) {
+	if snd > 0 {
+		c.sndWnd = uint32(snd)
+	}
+
+	if rcv > 0 {
+		c.rcvWnd = maxUint32(uint32(rcv), wndRcv)
+	}
+}
+
+// waitSnd returns the number of segments which are not acknowledged yet.
+func (c *control) waitSnd() int {
+	return len(c.sndBuf) + len(c.sndQueue)
+}
+
+// dead reports whether a segment was transmitted deadLink times without being acknowledged.
+func (c *control) dead() bool {
+	return c.state != 0
+}
+
+func removeFront(segs []segment, count int) []segment {
+	if count == 0 {
+		return segs
+	}
+
+	n := copy(segs, segs[count:])
+	for i := n; i < len(segs); i++ {
+		segs[i] = segment{}
+	}
+
+	return segs[:n]
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func boundUint32(lower, middle, upper uint32) uint32 {
+	return minUint32(maxUint32(lower, middle), upper)
+}
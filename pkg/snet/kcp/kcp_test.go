@@ -0,0 +1,287 @@
+package kcp
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/nettest"
+)
+
+func TestControl(t *testing.T) {
+	// Packets are dropped, duplicated and reordered on the way.
+	rnd := rand.New(rand.NewSource(1))
+
+	var aToB, bToA [][]byte
+
+	lossy := func(queue *[][]byte) func(buf []byte) {
+		return func(buf []byte) {
+			pkt := append([]byte(nil), buf...)
+
+			switch p := rnd.Intn(10); {
+			case p < 2:
+				return
+			case p < 3:
+				*queue = append(*queue, pkt)
+			case p < 4 && len(*queue) > 0:
+				*queue = append((*queue)[:len(*queue)-1], pkt, (*queue)[len(*queue)-1])
+				return
+			}
+
+			*queue = append(*queue, pkt)
+		}
+	}
+
+	a := newControl(1, lossy(&aToB))
+	b := newControl(1, lossy(&bToA))
+
+	for _, c := range []*control{a, b} {
+		c.stream = true
+		c.setNoDelay(true, 10, 2, true)
+	}
+
+	want := make([]byte, 1<<18)
+	rnd.Read(want)
+
+	var got []byte
+
+	buf := make([]byte, mtuDefault)
+	sent := 0
+
+	for clock := uint32(0); clock < 600000 && len(got) < len(want); clock += 10 {
+		for sent < len(want) && a.waitSnd() < int(a.sndWnd) {
+			n := int(a.mss)
+			if sent+n > len(want) {
+				n = len(want) - sent
+			}
+
+			require.True(t, a.send(want[sent:sent+n]))
+			sent += n
+		}
+
+		a.update(clock)
+		b.update(clock)
+
+		for _, pkt := range aToB {
+			require.True(t, b.input(pkt))
+		}
+
+		for _, pkt := range bToA {
+			require.True(t, a.input(pkt))
+		}
+
+		aToB, bToA = aToB[:0], bToA[:0]
+
+		for {
+			n := b.recv(buf)
+			if n < 0 {
+				break
+			}
+
+			got = append(got, buf[:n]...)
+		}
+
+		require.False(t, a.dead())
+	}
+
+	require.True(t, bytes.Equal(want, got))
+}
+
+func TestControl_Fin(t *testing.T) {
+	var packets [][]byte
+
+	a := newControl(1, func(buf []byte) { packets = append(packets, append([]byte(nil), buf...)) })
+	b := newControl(1, func([]byte) {})
+	a.setNoDelay(true, 10, 2, true)
+
+	require.True(t, a.send([]byte("hello")))
+	a.sendFin()
+	a.update(0)
+
+	for _, pkt := range packets {
+		require.True(t, b.input(pkt))
+	}
+
+	require.True(t, b.finRcvd)
+	require.False(t, b.finished())
+
+	buf := make([]byte, 16)
+	require.Equal(t, 5, b.recv(buf))
+	require.Equal(t, "hello", string(buf[:5]))
+	require.True(t, b.finished())
+	require.Equal(t, -1, b.recv(buf))
+}
+
+func TestSession(t *testing.T) {
+	mp := func() (c1, c2 net.Conn, stop func(), err error) {
+		l1, l2 := listen(t), listen(t)
+
+		s1, err := l1.Dial(l2.Addr())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		s2, err := l2.AcceptSession()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		stop = func() {
+			require.NoError(t, l1.Close())
+			require.NoError(t, l2.Close())
+		}
+
+		return s1, s2, stop, nil
+	}
+
+	nettest.TestConn(t, mp)
+}
+
+func TestSession_FEC(t *testing.T) {
+	conf := Config{DataShards: 4, ParityShards: 2}
+
+	conn1, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	conn2, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// The second packet of each group is lost, and is recovered from parity shards.
+	l1, err := Listen(&lossyConn{PacketConn: conn1, drop: func(i int) bool { return i%6 == 1 }}, conf)
+	require.NoError(t, err)
+
+	l2, err := Listen(conn2, conf)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, l1.Close())
+		require.NoError(t, l2.Close())
+	}()
+
+	s1, err := l1.Dial(l2.Addr())
+	require.NoError(t, err)
+
+	s2, err := l2.AcceptSession()
+	require.NoError(t, err)
+
+	want := make([]byte, 1<<16)
+	rand.New(rand.NewSource(0)).Read(want)
+
+	go func() {
+		_, err := s1.Write(want)
+		require.NoError(t, err)
+	}()
+
+	got := make([]byte, len(want))
+	_, err = io.ReadFull(s2, got)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(want, got))
+}
+
+func TestFECConn(t *testing.T) {
+	conn1, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	conn2, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// Two data shards of the group are lost, and the first parity shard.
+	dropped := map[int]bool{1: true, 3: true, 4: true}
+
+	c1, err := newFECConn(&lossyConn{PacketConn: conn1, drop: func(i int) bool { return dropped[i] }}, 4, 3)
+	require.NoError(t, err)
+
+	c2, err := newFECConn(conn2, 2, 1)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, c1.Close())
+		require.NoError(t, c2.Close())
+	}()
+
+	want := []string{"a", "bb", "ccc", "dddd"}
+	for _, pkt := range want {
+		_, err := c1.WriteTo([]byte(pkt), c2.LocalAddr())
+		require.NoError(t, err)
+	}
+
+	got := make([]string, 0, len(want))
+	buf := make([]byte, readBufSize)
+
+	for len(got) < len(want) {
+		n, addr, err := c2.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, c1.LocalAddr().String(), addr.String())
+
+		got = append(got, string(buf[:n]))
+	}
+
+	require.ElementsMatch(t, want, got)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, Config{}.Validate())
+	require.NoError(t, Config{SendWindow: 1024, MTU: 500, DataShards: 10, ParityShards: 3}.Validate())
+
+	for _, c := range []Config{
+		{RecvWindow: -1},
+		{MTU: 10},
+		{MTU: 1 << 16},
+		{ParityShards: 3},
+		{DataShards: 200, ParityShards: 100},
+	} {
+		require.Error(t, c.Validate())
+	}
+}
+
+func TestListener_Close(t *testing.T) {
+	l1, l2 := listen(t), listen(t)
+
+	s, err := l1.Dial(l2.Addr())
+	require.NoError(t, err)
+
+	require.NoError(t, l1.Close())
+	require.NoError(t, l2.Close())
+
+	_, err = s.Write([]byte("hello"))
+	require.Equal(t, ErrClosed, err)
+
+	_, err = l2.Accept()
+	require.Equal(t, ErrClosed, err)
+}
+
+// lossyConn drops the packets it writes for which drop returns true, given their index.
+type lossyConn struct {
+	net.PacketConn
+	drop func(i int) bool
+
+	mx      sync.Mutex
+	written int
+}
+
+func (c *lossyConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mx.Lock()
+	i := c.written
+	c.written++
+	c.mx.Unlock()
+
+	if c.drop(i) {
+		return len(b), nil
+	}
+
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func listen(t *testing.T) *Listener {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l, err := Listen(conn, Config{})
+	require.NoError(t, err)
+
+	return l
+}
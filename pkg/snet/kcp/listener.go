@@ -0,0 +1,202 @@
+package kcp
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"sync"
+)
+
+const (
+	acceptBacklog = 128
+	readBufSize   = 4096
+)
+
+// sessionKey identifies a session of a listener.
+type sessionKey struct {
+	addr string
+	conv uint32
+}
+
+// Listener serves sessions over a packet connection: sessions are accepted from remotes which dial the listener,
+// and dialed with Dial. Sessions are identified by their remote addresses, and the conversation ids chosen by their
+// dialers.
+type Listener struct {
+	conn net.PacketConn
+	conf Config
+
+	mu       sync.Mutex
+	sessions map[sessionKey]*Session
+	accept   chan *Session
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// Listen serves sessions over conn, which is closed with the listener.
+func Listen(conn net.PacketConn, conf Config) (*Listener, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	conf = conf.withDefaults()
+
+	if conf.DataShards > 0 {
+		fec, err := newFECConn(conn, conf.DataShards, conf.ParityShards)
+		if err != nil {
+			return nil, err
+		}
+
+		conn = fec
+	}
+
+	l := &Listener{
+		conn:     conn,
+		conf:     conf,
+		sessions: make(map[sessionKey]*Session),
+		accept:   make(chan *Session, acceptBacklog),
+		done:     make(chan struct{}),
+	}
+
+	go l.serve()
+
+	return l, nil
+}
+
+// mtu returns the MTU of sessions, which leaves room for FEC.
+func (l *Listener) mtu() int {
+	if l.conf.DataShards > 0 {
+		return l.conf.MTU - fecOverhead
+	}
+
+	return l.conf.MTU
+}
+
+func (l *Listener) serve() {
+	defer l.Close() // nolint:errcheck
+
+	buf := make([]byte, readBufSize)
+
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		if n < overhead {
+			continue
+		}
+
+		key := sessionKey{addr: addr.String(), conv: binary.LittleEndian.Uint32(buf)}
+
+		l.mu.Lock()
+
+		s, ok := l.sessions[key]
+		if !ok {
+			s = l.acceptSession(key, addr, buf[:n])
+		}
+
+		l.mu.Unlock()
+
+		if s != nil {
+			s.input(buf[:n])
+		}
+	}
+}
+
+// acceptSession creates a session with the remote which sent pkt, if pkt opens the session: it is either the
+// announcement of a dialed session, or its first segment. Other packets of unknown sessions are of sessions which
+// were closed, and are dropped. It is called with the lock held.
+func (l *Listener) acceptSession(key sessionKey, addr net.Addr, pkt []byte) *Session {
+	cmd, sn := pkt[4], binary.LittleEndian.Uint32(pkt[12:])
+	if cmd != cmdWins && (cmd != cmdPush && cmd != cmdFin || sn != 0) {
+		return nil
+	}
+
+	s := newSession(l, key, addr)
+
+	select {
+	case l.accept <- s:
+		l.sessions[key] = s
+		return s
+	default:
+		// The backlog is full, so the remote will have to retransmit.
+		go s.die(ErrClosed)
+		return nil
+	}
+}
+
+// Accept waits for and returns the next session dialed by a remote.
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptSession()
+}
+
+// AcceptSession waits for and returns the next session dialed by a remote.
+func (l *Listener) AcceptSession() (*Session, error) {
+	select {
+	case s := <-l.accept:
+		return s, nil
+	case <-l.done:
+		return nil, ErrClosed
+	}
+}
+
+// Dial creates a session with the listener at raddr, which is announced to the remote so that it accepts the session.
+func (l *Listener) Dial(raddr net.Addr) (*Session, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	key := sessionKey{addr: raddr.String()}
+
+	for {
+		key.conv = rand.Uint32() // nolint:gosec
+		if _, ok := l.sessions[key]; !ok {
+			break
+		}
+	}
+
+	s := newSession(l, key, raddr)
+	l.sessions[key] = s
+	s.open()
+
+	return s, nil
+}
+
+func (l *Listener) remove(key sessionKey) {
+	l.mu.Lock()
+	delete(l.sessions, key)
+	l.mu.Unlock()
+}
+
+// Close closes the listener and its packet connection, which ends all its sessions.
+func (l *Listener) Close() error {
+	err := ErrClosed
+
+	l.doneOnce.Do(func() {
+		close(l.done)
+		err = l.conn.Close()
+
+		l.mu.Lock()
+		sessions := make([]*Session, 0, len(l.sessions))
+		for _, s := range l.sessions {
+			sessions = append(sessions, s)
+		}
+		l.mu.Unlock()
+
+		for _, s := range sessions {
+			s.die(ErrClosed)
+		}
+	})
+
+	return err
+}
+
+// Addr returns the local address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
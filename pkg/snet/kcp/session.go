@@ -0,0 +1,435 @@
+package kcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWindow is the default size (in segments) of the send and receive windows of sessions.
+	DefaultWindow = 256
+
+	// DefaultMTU is the default maximum size of packets sent by sessions, which fits in the MTU of most links.
+	DefaultMTU = 1350
+
+	// minMTU is the lowest MTU which leaves room for data in segments.
+	minMTU = 64
+
+	// lingerTimeout is how long closed sessions wait for data in flight to be acknowledged.
+	lingerTimeout = 10 * time.Second
+
+	// openInterval is the interval at which dialed sessions announce themselves until the remote responds.
+	openInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrClosed is returned when using a closed session or listener.
+	ErrClosed = errors.New("kcp: use of closed session")
+
+	// ErrDeadLink is returned when the remote of a session stopped acknowledging data.
+	ErrDeadLink = errors.New("kcp: remote stopped responding")
+
+	// nolint:gochecknoglobals
+	epoch = time.Now()
+)
+
+// now returns the time in ms, as used by control blocks.
+func now() uint32 {
+	return uint32(time.Since(epoch) / time.Millisecond)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "kcp: i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Config configures sessions. Zero values are replaced with defaults.
+type Config struct {
+	SendWindow int // Maximum number of segments in flight.
+	RecvWindow int // Maximum number of segments received but not read yet.
+	MTU        int // Maximum size of packets.
+
+	// Forward error correction is enabled if DataShards is set: after each DataShards packets sent to a remote,
+	// ParityShards packets are sent, which recover as many lost packets. Remotes must enable it as well.
+	DataShards   int
+	ParityShards int
+}
+
+// Validate checks the config for values which cannot be used.
+func (c Config) Validate() error {
+	switch {
+	case c.SendWindow < 0 || c.RecvWindow < 0:
+		return errors.New("windows cannot be negative")
+	case c.MTU != 0 && c.MTU < minMTU:
+		return fmt.Errorf("mtu cannot be lower than %d", minMTU)
+	case c.MTU > readBufSize:
+		return fmt.Errorf("mtu cannot be higher than %d", readBufSize)
+	case c.DataShards < 0 || c.ParityShards < 0:
+		return errors.New("numbers of shards cannot be negative")
+	case c.DataShards == 0 && c.ParityShards > 0:
+		return errors.New("parity shards require data shards")
+	case c.DataShards+c.ParityShards > 255:
+		return errors.New("there cannot be more than 255 shards")
+	}
+
+	return nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.SendWindow <= 0 {
+		c.SendWindow = DefaultWindow
+	}
+
+	if c.RecvWindow <= 0 {
+		c.RecvWindow = DefaultWindow
+	}
+
+	if c.MTU <= 0 {
+		c.MTU = DefaultMTU
+	}
+
+	return c
+}
+
+// Session is a reliable and ordered stream with a remote endpoint of a listener. It implements net.Conn.
+// Closing a session ends the stream once all data written before is received by the remote, which then reads io.EOF.
+type Session struct {
+	l     *Listener
+	key   sessionKey
+	raddr net.Addr
+
+	mu       sync.Mutex
+	c        *control
+	buf      []byte // part of a received segment, which is not read yet
+	opening  bool   // whether the session is dialed, and nothing was sent or received yet
+	openedAt time.Time
+	closed   bool
+	closedAt time.Time
+	err      error // reason the session died
+	rd, wd   time.Time
+
+	readEvent  chan struct{}
+	writeEvent chan struct{}
+	done       chan struct{}
+	doneOnce   sync.Once
+}
+
+func newSession(l *Listener, key sessionKey, raddr net.Addr) *Session {
+	s := &Session{
+		l:          l,
+		key:        key,
+		raddr:      raddr,
+		readEvent:  make(chan struct{}, 1),
+		writeEvent: make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	s.c = newControl(key.conv, s.output)
+	s.c.stream = true
+	s.c.setMTU(l.mtu())
+	s.c.setWindow(l.conf.SendWindow, l.conf.RecvWindow)
+	s.c.setNoDelay(true, 10, 2, true)
+	s.c.update(now())
+
+	go s.run()
+
+	return s
+}
+
+// output is called by the control block, with its lock held.
+func (s *Session) output(buf []byte) {
+	_, _ = s.l.conn.WriteTo(buf, s.raddr) // nolint:errcheck
+}
+
+// open announces a dialed session to the remote, which accepts it. The announcement is a window update,
+// which is repeated until data is sent or received.
+func (s *Session) open() {
+	s.mu.Lock()
+	s.opening = true
+	s.openedAt = time.Now()
+	s.c.probe |= askTell
+	s.c.flush()
+	s.mu.Unlock()
+}
+
+// input passes a packet from the remote to the control block.
+func (s *Session) input(pkt []byte) {
+	s.mu.Lock()
+	s.opening = false
+	s.c.current = now()
+	s.c.input(pkt)
+
+	readable := s.c.peekSize() > 0 || s.c.finished()
+	writable := s.c.waitSnd() < int(s.c.sndWnd)
+	s.mu.Unlock()
+
+	if readable {
+		notify(s.readEvent)
+	}
+
+	if writable {
+		notify(s.writeEvent)
+	}
+}
+
+// run drives the control block until the session dies.
+func (s *Session) run() {
+	ticker := time.NewTicker(time.Duration(s.c.interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+
+		if s.opening && time.Since(s.openedAt) >= openInterval {
+			s.openedAt = time.Now()
+			s.c.probe |= askTell
+		}
+
+		s.c.update(now())
+		dead := s.c.dead()
+		// Closed sessions are done once the ends of both streams were received.
+		finished := s.closed && (s.c.waitSnd() == 0 && s.c.finRcvd || time.Since(s.closedAt) > lingerTimeout)
+		s.mu.Unlock()
+
+		switch {
+		case dead:
+			s.die(ErrDeadLink)
+		case finished:
+			s.die(ErrClosed)
+		}
+	}
+}
+
+// die releases the session, which fails all pending and later operations with err.
+func (s *Session) die(err error) {
+	s.doneOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+
+		close(s.done)
+		s.l.remove(s.key)
+	})
+}
+
+// Read implements io.Reader. It returns io.EOF once the remote closed the session and all data was read.
+func (s *Session) Read(b []byte) (int, error) {
+	for {
+		s.mu.Lock()
+
+		n, ok, err := s.tryRead(b)
+		deadline := s.rd
+
+		s.mu.Unlock()
+
+		if ok {
+			return n, err
+		}
+
+		if err := s.wait(s.readEvent, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// tryRead reads to b, if there is anything to read. ok is false if Read is to wait for more.
+// It is called with the lock held.
+func (s *Session) tryRead(b []byte) (n int, ok bool, err error) {
+	switch {
+	case s.closed:
+		return 0, true, ErrClosed
+	case !s.rd.IsZero() && !time.Now().Before(s.rd):
+		return 0, true, timeoutError{}
+	case len(b) == 0:
+		return 0, true, nil
+	case len(s.buf) > 0:
+		n = copy(b, s.buf)
+		s.buf = s.buf[n:]
+
+		return n, true, nil
+	}
+
+	if size := s.c.peekSize(); size > 0 {
+		if len(b) >= size {
+			n = s.c.recv(b)
+		} else {
+			buf := make([]byte, size)
+			s.c.recv(buf)
+			n = copy(b, buf)
+			s.buf = buf[n:]
+		}
+
+		if len(s.buf) > 0 || s.c.peekSize() > 0 {
+			notify(s.readEvent)
+		}
+
+		return n, true, nil
+	}
+
+	switch {
+	case s.c.finished():
+		return 0, true, io.EOF
+	case s.err != nil:
+		return 0, true, s.err
+	}
+
+	return 0, false, nil
+}
+
+// Write implements io.Writer. It blocks while the send window is full.
+func (s *Session) Write(b []byte) (int, error) {
+	written := 0
+
+	for {
+		s.mu.Lock()
+
+		switch {
+		case s.closed:
+			s.mu.Unlock()
+			return written, ErrClosed
+		case s.err != nil:
+			err := s.err
+			s.mu.Unlock()
+
+			return written, err
+		case !s.wd.IsZero() && !time.Now().Before(s.wd):
+			s.mu.Unlock()
+			return written, timeoutError{}
+		}
+
+		s.opening = false
+
+		for len(b) > 0 && s.c.waitSnd() < int(s.c.sndWnd) {
+			n := len(b)
+			if n > int(s.c.mss) {
+				n = int(s.c.mss)
+			}
+
+			s.c.send(b[:n])
+			b = b[n:]
+			written += n
+		}
+
+		s.c.current = now()
+		s.c.flush()
+
+		deadline := s.wd
+
+		s.mu.Unlock()
+
+		if len(b) == 0 {
+			return written, nil
+		}
+
+		if err := s.wait(s.writeEvent, deadline); err != nil {
+			return written, err
+		}
+	}
+}
+
+// wait blocks until event is notified, the session dies or the deadline passes.
+func (s *Session) wait(event chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case <-event:
+		return nil
+	case <-s.done:
+		return nil
+	case <-timeout:
+		return timeoutError{}
+	}
+}
+
+// Close implements io.Closer. Data written before is still delivered to the remote, as long as it responds.
+func (s *Session) Close() error {
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+
+	s.closed = true
+	s.closedAt = time.Now()
+	s.c.sendFin()
+	s.c.current = now()
+	s.c.flush()
+
+	s.mu.Unlock()
+
+	notify(s.readEvent)
+	notify(s.writeEvent)
+
+	return nil
+}
+
+// LocalAddr implements net.Conn.
+func (s *Session) LocalAddr() net.Addr {
+	return s.l.Addr()
+}
+
+// RemoteAddr implements net.Conn.
+func (s *Session) RemoteAddr() net.Addr {
+	return s.raddr
+}
+
+// SetDeadline implements net.Conn.
+func (s *Session) SetDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.rd, s.wd = t, t
+	s.mu.Unlock()
+
+	notify(s.readEvent)
+	notify(s.writeEvent)
+
+	return nil
+}
+
+// SetReadDeadline implements net.Conn.
+func (s *Session) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.rd = t
+	s.mu.Unlock()
+
+	notify(s.readEvent)
+
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.
+func (s *Session) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.wd = t
+	s.mu.Unlock()
+
+	notify(s.writeEvent)
+
+	return nil
+}
+
+// notify wakes up a goroutine waiting for event, if any.
+func notify(event chan struct{}) {
+	select {
+	case event <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,47 @@
+package snet_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/snet"
+	"github.com/skycoin/skywire/pkg/snet/snettest"
+)
+
+func TestNetwork_KCP(t *testing.T) {
+	keys := snettest.GenKeyPairs(2)
+	nEnv := snettest.NewEnv(t, keys, []string{snet.KCPType})
+	defer nEnv.Teardown()
+
+	require.Contains(t, nEnv.Nets[0].TransportNetworks(), snet.KCPType)
+
+	lis, err := nEnv.Nets[1].Listen(snet.KCPType, snet.TransportPort)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn0, err := nEnv.Nets[0].Dial(ctx, snet.KCPType, keys[1].PK, snet.TransportPort)
+	require.NoError(t, err)
+	require.Equal(t, keys[1].PK, conn0.RemotePK())
+	require.Equal(t, snet.KCPType, conn0.Network())
+
+	conn1, err := lis.AcceptConn()
+	require.NoError(t, err)
+	require.Equal(t, keys[0].PK, conn1.RemotePK())
+
+	_, err = conn0.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn1, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	require.NoError(t, conn0.Close())
+	require.NoError(t, conn1.Close())
+}
@@ -14,7 +14,9 @@ import (
 	"github.com/skycoin/dmsg/disc"
 	"github.com/skycoin/skycoin/src/util/logging"
 
+	"github.com/skycoin/skywire/pkg/snet/kcp"
 	"github.com/skycoin/skywire/pkg/snet/stcp"
+	"github.com/skycoin/skywire/pkg/snet/sudph"
 )
 
 // Default ports.
@@ -23,12 +25,15 @@ const (
 	SetupPort      = uint16(36)  // Listening port of a setup node.
 	AwaitSetupPort = uint16(136) // Listening port of a visor for setup operations.
 	TransportPort  = uint16(45)  // Listening port of a visor for incoming transports.
+	SUDPHPort      = uint16(51)  // Listening port of a visor for sudph hole punching offers.
 )
 
 // Network types.
 const (
-	DmsgType = dmsg.Type
-	STCPType = stcp.Type
+	DmsgType  = dmsg.Type
+	STCPType  = stcp.Type
+	SUDPHType = sudph.Type
+	KCPType   = kcp.Type
 )
 
 var (
@@ -63,12 +68,67 @@ func (c *STCPConfig) Type() string {
 	return STCPType
 }
 
+// SUDPHConfig defines config for SUDPH network, which punches holes through NATs to establish UDP transports.
+// Hole punching offers are exchanged over dmsg, which is required.
+type SUDPHConfig struct {
+	LocalAddr   string   `json:"local_address"` // Address of the UDP socket, a random port is used if unset.
+	STUNServers []string `json:"stun_servers"`  // Servers telling visors the addresses their NATs map them to.
+}
+
+// Type returns SUDPHType.
+func (c *SUDPHConfig) Type() string {
+	return SUDPHType
+}
+
+// KCPConfig defines config for KCP network, which streams transports over UDP with KCP. It recovers from loss faster
+// than STCP, which suits high-latency or lossy links such as satellite and mobile ones. Like STCP, remotes are
+// resolved with a public key table.
+type KCPConfig struct {
+	PubKeyTable map[cipher.PubKey]string `json:"pk_table"`
+	LocalAddr   string                   `json:"local_address"`
+	SendWindow  int                      `json:"send_window,omitempty"` // Segments in flight, 256 if unset.
+	RecvWindow  int                      `json:"recv_window,omitempty"` // Segments received but not read, 256 if unset.
+	MTU         int                      `json:"mtu,omitempty"`         // Maximum size of UDP packets, 1350 if unset.
+	FEC         *KCPFECConfig            `json:"fec,omitempty"`         // Forward error correction, disabled if unset.
+}
+
+// KCPFECConfig defines forward error correction of KCP transports: after each DataShards packets sent to a visor,
+// ParityShards packets are sent, which recover as many lost packets without retransmission. Both visors of
+// transports must enable it.
+type KCPFECConfig struct {
+	DataShards   int `json:"data_shards"`
+	ParityShards int `json:"parity_shards"`
+}
+
+// Type returns KCPType.
+func (c *KCPConfig) Type() string {
+	return KCPType
+}
+
+// Session returns the config of KCP sessions.
+func (c *KCPConfig) Session() kcp.Config {
+	conf := kcp.Config{
+		SendWindow: c.SendWindow,
+		RecvWindow: c.RecvWindow,
+		MTU:        c.MTU,
+	}
+
+	if c.FEC != nil {
+		conf.DataShards = c.FEC.DataShards
+		conf.ParityShards = c.FEC.ParityShards
+	}
+
+	return conf
+}
+
 // Config represents a network configuration.
 type Config struct {
 	PubKey cipher.PubKey
 	SecKey cipher.SecKey
 	Dmsg   *DmsgConfig
 	STCP   *STCPConfig
+	SUDPH  *SUDPHConfig
+	KCP    *KCPConfig
 }
 
 // Network represents a network between nodes in Skywire.
@@ -77,12 +137,16 @@ type Network struct {
 	networks []string // networks to be used with transports
 	dmsgC    *dmsg.Client
 	stcpC    *stcp.Client
+	sudphC   *sudph.Client
+	kcpC     *kcp.Client
 }
 
 // New creates a network from a config.
 func New(conf Config) *Network {
 	var dmsgC *dmsg.Client
 	var stcpC *stcp.Client
+	var sudphC *sudph.Client
+	var kcpC *kcp.Client
 
 	if conf.Dmsg != nil {
 		c := &dmsg.Config{
@@ -98,11 +162,21 @@ func New(conf Config) *Network {
 		stcpC.SetLogger(logging.MustGetLogger("snet.stcpC"))
 	}
 
-	return NewRaw(conf, dmsgC, stcpC)
+	if conf.SUDPH != nil && dmsgC != nil {
+		sudphC = sudph.NewClient(conf.PubKey, conf.SecKey, sudph.DmsgSignaling(dmsgC, SUDPHPort), conf.SUDPH.STUNServers)
+		sudphC.SetLogger(logging.MustGetLogger("snet.sudphC"))
+	}
+
+	if conf.KCP != nil {
+		kcpC = kcp.NewClient(conf.PubKey, conf.SecKey, stcp.NewTable(conf.KCP.PubKeyTable), conf.KCP.Session())
+		kcpC.SetLogger(logging.MustGetLogger("snet.kcpC"))
+	}
+
+	return NewRaw(conf, dmsgC, stcpC, sudphC, kcpC)
 }
 
-// NewRaw creates a network from a config and a dmsg client.
-func NewRaw(conf Config, dmsgC *dmsg.Client, stcpC *stcp.Client) *Network {
+// NewRaw creates a network from a config and clients of its network types, which may be nil.
+func NewRaw(conf Config, dmsgC *dmsg.Client, stcpC *stcp.Client, sudphC *sudph.Client, kcpC *kcp.Client) *Network {
 	networks := make([]string, 0)
 
 	if dmsgC != nil {
@@ -113,11 +187,21 @@ func NewRaw(conf Config, dmsgC *dmsg.Client, stcpC *stcp.Client) *Network {
 		networks = append(networks, STCPType)
 	}
 
+	if sudphC != nil {
+		networks = append(networks, SUDPHType)
+	}
+
+	if kcpC != nil {
+		networks = append(networks, KCPType)
+	}
+
 	return &Network{
 		conf:     conf,
 		networks: networks,
 		dmsgC:    dmsgC,
 		stcpC:    stcpC,
+		sudphC:   sudphC,
+		kcpC:     kcpC,
 	}
 }
 
@@ -139,13 +223,31 @@ func (n *Network) Init(_ context.Context) error {
 		}
 	}
 
+	if n.sudphC != nil {
+		signaling, err := n.dmsgC.Listen(SUDPHPort)
+		if err != nil {
+			return fmt.Errorf("failed to initiate 'sudph': %v", err)
+		}
+
+		if err := n.sudphC.Serve(n.conf.SUDPH.LocalAddr, signaling); err != nil {
+			_ = signaling.Close() // nolint:errcheck
+			return fmt.Errorf("failed to initiate 'sudph': %v", err)
+		}
+	}
+
+	if n.kcpC != nil && n.conf.KCP.LocalAddr != "" {
+		if err := n.kcpC.Serve(n.conf.KCP.LocalAddr); err != nil {
+			return fmt.Errorf("failed to initiate 'kcp': %v", err)
+		}
+	}
+
 	return nil
 }
 
 // Close closes underlying connections.
 func (n *Network) Close() error {
 	wg := new(sync.WaitGroup)
-	wg.Add(2)
+	wg.Add(4)
 
 	var dmsgErr error
 	go func() {
@@ -159,6 +261,18 @@ func (n *Network) Close() error {
 		wg.Done()
 	}()
 
+	var sudphErr error
+	go func() {
+		sudphErr = n.sudphC.Close()
+		wg.Done()
+	}()
+
+	var kcpErr error
+	go func() {
+		kcpErr = n.kcpC.Close()
+		wg.Done()
+	}()
+
 	wg.Wait()
 
 	if dmsgErr != nil {
@@ -167,6 +281,12 @@ func (n *Network) Close() error {
 	if stcpErr != nil {
 		return stcpErr
 	}
+	if sudphErr != nil {
+		return sudphErr
+	}
+	if kcpErr != nil {
+		return kcpErr
+	}
 	return nil
 }
 
@@ -185,6 +305,12 @@ func (n *Network) Dmsg() *dmsg.Client { return n.dmsgC }
 // STcp returns the underlying stcp.Client.
 func (n *Network) STcp() *stcp.Client { return n.stcpC }
 
+// SUDPH returns the underlying sudph.Client.
+func (n *Network) SUDPH() *sudph.Client { return n.sudphC }
+
+// KCP returns the underlying kcp.Client.
+func (n *Network) KCP() *kcp.Client { return n.kcpC }
+
 // Dialer is an entity that can be dialed and asked for its type.
 type Dialer interface {
 	Dial(ctx context.Context, remote cipher.PubKey, port uint16) (net.Conn, error)
@@ -212,6 +338,20 @@ func (n *Network) Dial(ctx context.Context, network string, pk cipher.PubKey, po
 			return nil, err
 		}
 
+		return makeConn(conn, network), nil
+	case SUDPHType:
+		conn, err := n.sudphC.Dial(ctx, pk, port)
+		if err != nil {
+			return nil, err
+		}
+
+		return makeConn(conn, network), nil
+	case KCPType:
+		conn, err := n.kcpC.Dial(ctx, pk, port)
+		if err != nil {
+			return nil, err
+		}
+
 		return makeConn(conn, network), nil
 	default:
 		return nil, ErrUnknownNetwork
@@ -234,6 +374,20 @@ func (n *Network) Listen(network string, port uint16) (*Listener, error) {
 			return nil, err
 		}
 
+		return makeListener(lis, network), nil
+	case SUDPHType:
+		lis, err := n.sudphC.Listen(port)
+		if err != nil {
+			return nil, err
+		}
+
+		return makeListener(lis, network), nil
+	case KCPType:
+		lis, err := n.kcpC.Listen(port)
+		if err != nil {
+			return nil, err
+		}
+
 		return makeListener(lis, network), nil
 	default:
 		return nil, ErrUnknownNetwork
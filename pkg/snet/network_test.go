@@ -19,3 +19,19 @@ func TestDisassembleAddr(t *testing.T) {
 	require.Equal(t, pk, gotPK)
 	require.Equal(t, port, gotPort)
 }
+
+func TestCapabilities(t *testing.T) {
+	c, ok := NetworkCapabilities(DmsgType)
+	require.True(t, ok)
+	require.True(t, c.NATTraversal)
+
+	_, ok = NetworkCapabilities("plugin")
+	require.False(t, ok)
+
+	want := Capabilities{Reliable: true, Encrypted: true}
+	RegisterCapabilities("plugin", want)
+
+	c, ok = NetworkCapabilities("plugin")
+	require.True(t, ok)
+	require.Equal(t, want, c)
+}
@@ -13,7 +13,9 @@ import (
 	"golang.org/x/net/nettest"
 
 	"github.com/skycoin/skywire/pkg/snet"
+	"github.com/skycoin/skywire/pkg/snet/kcp"
 	"github.com/skycoin/skywire/pkg/snet/stcp"
+	"github.com/skycoin/skywire/pkg/snet/sudph"
 )
 
 // KeyPair holds a public/private key pair.
@@ -63,7 +65,7 @@ func NewEnv(t *testing.T, keys []KeyPair, networks []string) *Env {
 
 	table := stcp.NewTable(tableEntries)
 
-	var hasDmsg, hasStcp bool
+	var hasDmsg, hasStcp, hasSUDPH, hasKCP bool
 
 	for _, network := range networks {
 		switch network {
@@ -71,6 +73,11 @@ func NewEnv(t *testing.T, keys []KeyPair, networks []string) *Env {
 			hasDmsg = true
 		case stcp.Type:
 			hasStcp = true
+		case sudph.Type:
+			// Hole punching offers are exchanged over dmsg.
+			hasDmsg, hasSUDPH = true, true
+		case kcp.Type:
+			hasKCP = true
 		}
 	}
 
@@ -80,6 +87,8 @@ func NewEnv(t *testing.T, keys []KeyPair, networks []string) *Env {
 	for i, pairs := range keys {
 		var dmsgClient *dmsg.Client
 		var stcpClient *stcp.Client
+		var sudphClient *sudph.Client
+		var kcpClient *kcp.Client
 
 		if hasDmsg {
 			dmsgClient = dmsg.NewClient(pairs.PK, pairs.SK, dmsgD, nil)
@@ -90,6 +99,16 @@ func NewEnv(t *testing.T, keys []KeyPair, networks []string) *Env {
 			stcpClient = stcp.NewClient(pairs.PK, pairs.SK, table)
 		}
 
+		if hasSUDPH {
+			signaling := sudph.DmsgSignaling(dmsgClient, snet.SUDPHPort)
+			sudphClient = sudph.NewClient(pairs.PK, pairs.SK, signaling, nil)
+		}
+
+		if hasKCP {
+			// KCP uses the same ports as stcp, over UDP.
+			kcpClient = kcp.NewClient(pairs.PK, pairs.SK, table, kcp.Config{})
+		}
+
 		port := 7033
 		n := snet.NewRaw(
 			snet.Config{
@@ -101,9 +120,17 @@ func NewEnv(t *testing.T, keys []KeyPair, networks []string) *Env {
 				STCP: &snet.STCPConfig{
 					LocalAddr: "127.0.0.1:" + strconv.Itoa(port+i),
 				},
+				SUDPH: &snet.SUDPHConfig{
+					LocalAddr: "127.0.0.1:0",
+				},
+				KCP: &snet.KCPConfig{
+					LocalAddr: "127.0.0.1:" + strconv.Itoa(port+i),
+				},
 			},
 			dmsgClient,
 			stcpClient,
+			sudphClient,
+			kcpClient,
 		)
 		require.NoError(t, n.Init(context.TODO()))
 		ns[i] = n
@@ -25,7 +25,9 @@ type Conn struct {
 	freePort func()
 }
 
-func newConn(conn net.Conn, deadline time.Time, hs Handshake, freePort func()) (*Conn, error) {
+// NewConn performs the handshake hs over conn, and wraps conn once it succeeds. Otherwise, conn is closed.
+// freePort is called once the connection is closed, or if the handshake fails.
+func NewConn(conn net.Conn, deadline time.Time, hs Handshake, freePort func()) (*Conn, error) {
 	lAddr, rAddr, err := hs(conn, deadline)
 	if err != nil {
 		_ = conn.Close() //nolint:errcheck
@@ -67,7 +69,9 @@ type Listener struct {
 	mx       sync.Mutex
 }
 
-func newListener(lAddr dmsg.Addr, freePort func()) *Listener {
+// NewListener creates a listener of lAddr, to which connections are introduced by clients.
+// freePort is called once the listener is closed.
+func NewListener(lAddr dmsg.Addr, freePort func()) *Listener {
 	return &Listener{
 		lAddr:    lAddr,
 		freePort: freePort,
@@ -76,7 +80,7 @@ func newListener(lAddr dmsg.Addr, freePort func()) *Listener {
 	}
 }
 
-// Introduce is used by stcp.Client to introduce stcp.Conn to Listener.
+// Introduce is used by clients to introduce connections to Listener.
 func (l *Listener) Introduce(conn *Conn) error {
 	select {
 	case <-l.done:
@@ -129,6 +133,7 @@ type Client struct {
 	lPK cipher.PubKey
 	lSK cipher.SecKey
 	t   PKTable
+	tMx sync.RWMutex
 	p   *Porter
 
 	lTCP net.Listener
@@ -146,7 +151,7 @@ func NewClient(pk cipher.PubKey, sk cipher.SecKey, t PKTable) *Client {
 		lPK:  pk,
 		lSK:  sk,
 		t:    t,
-		p:    newPorter(PorterMinEphemeral),
+		p:    NewPorter(PorterMinEphemeral),
 		lMap: make(map[uint16]*Listener),
 		done: make(chan struct{}),
 	}
@@ -157,6 +162,21 @@ func (c *Client) SetLogger(log *logging.Logger) {
 	c.log = log
 }
 
+// SetTable replaces the PKTable used to resolve the addresses of remote public keys.
+// Existing connections are not affected.
+func (c *Client) SetTable(t PKTable) {
+	c.tMx.Lock()
+	c.t = t
+	c.tMx.Unlock()
+}
+
+func (c *Client) table() PKTable {
+	c.tMx.RLock()
+	defer c.tMx.RUnlock()
+
+	return c.t
+}
+
 // Serve serves the listening portion of the client.
 func (c *Client) Serve(tcpAddr string) error {
 	if c.lTCP != nil {
@@ -204,7 +224,7 @@ func (c *Client) acceptTCPConn() error {
 		}
 		return nil
 	})
-	conn, err := newConn(tcpConn, time.Now().Add(HandshakeTimeout), hs, nil)
+	conn, err := NewConn(tcpConn, time.Now().Add(HandshakeTimeout), hs, nil)
 	if err != nil {
 		return err
 	}
@@ -217,7 +237,7 @@ func (c *Client) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (*Co
 		return nil, io.ErrClosedPipe
 	}
 
-	tcpAddr, ok := c.t.Addr(rPK)
+	tcpAddr, ok := c.table().Addr(rPK)
 	if !ok {
 		return nil, fmt.Errorf("pk table: entry of %s does not exist", rPK)
 	}
@@ -231,7 +251,7 @@ func (c *Client) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (*Co
 		return nil, err
 	}
 	hs := InitiatorHandshake(c.lSK, dmsg.Addr{PK: c.lPK, Port: lPort}, dmsg.Addr{PK: rPK, Port: rPort})
-	return newConn(conn, time.Now().Add(HandshakeTimeout), hs, freePort)
+	return NewConn(conn, time.Now().Add(HandshakeTimeout), hs, freePort)
 }
 
 // Listen creates a new listener for stcp.
@@ -250,7 +270,7 @@ func (c *Client) Listen(lPort uint16) (*Listener, error) {
 	defer c.mx.Unlock()
 
 	lAddr := dmsg.Addr{PK: c.lPK, Port: lPort}
-	lis := newListener(lAddr, freePort)
+	lis := NewListener(lAddr, freePort)
 	c.lMap[lPort] = lis
 	return lis, nil
 }
@@ -36,11 +36,11 @@ func prepareConns(t *testing.T) (*Conn, *Conn, func()) {
 	done := make(chan struct{})
 
 	go func() {
-		b, respErr = newConn(bConn, time.Now().Add(HandshakeTimeout), rhs, nil)
+		b, respErr = NewConn(bConn, time.Now().Add(HandshakeTimeout), rhs, nil)
 		close(done)
 	}()
 
-	a, err := newConn(aConn, time.Now().Add(HandshakeTimeout), ihs, nil)
+	a, err := NewConn(aConn, time.Now().Add(HandshakeTimeout), ihs, nil)
 	require.NoError(t, err)
 
 	<-done
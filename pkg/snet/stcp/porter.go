@@ -18,7 +18,8 @@ type Porter struct {
 	mx     sync.Mutex
 }
 
-func newPorter(minEph uint16) *Porter {
+// NewPorter creates a Porter, which reserves ephemeral ports from minEph.
+func NewPorter(minEph uint16) *Porter {
 	ports := make(map[uint16]struct{})
 	ports[0] = struct{}{} // port 0 is invalid
 
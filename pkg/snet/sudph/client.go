@@ -0,0 +1,464 @@
+// Package sudph implements sudph, a network type which establishes direct UDP connections between visors behind NATs
+// by hole punching. Visors learn the addresses their NATs map their sockets to from STUN servers, and exchange them as
+// candidates over a signaling channel (dmsg). Both visors then send probes to the candidates of each other until one
+// gets through, which opens the mappings of both NATs. Connections are then streamed over the punched path with KCP,
+// and are authenticated with the stcp handshake.
+package sudph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/snet/kcp"
+	"github.com/skycoin/skywire/pkg/snet/stcp"
+)
+
+// Type is sudph type.
+const Type = "sudph"
+
+const (
+	// punchInterval is the interval between probes sent to the candidates of a remote.
+	punchInterval = 100 * time.Millisecond
+
+	// punchTimeout is how long hole punching is attempted for.
+	punchTimeout = 10 * time.Second
+
+	// stunTimeout is how long STUN servers are waited for.
+	stunTimeout = 2 * time.Second
+
+	// signalingTimeout is how long the exchange of candidates may take.
+	signalingTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNotServing is returned when dialing with a client which does not serve a UDP socket yet.
+	ErrNotServing = errors.New("sudph client is not serving")
+)
+
+// SignalingDialer dials the signaling channel of the visor rPK.
+type SignalingDialer func(ctx context.Context, rPK cipher.PubKey) (net.Conn, error)
+
+// DmsgSignaling returns a SignalingDialer which dials port of remotes over dmsg.
+func DmsgSignaling(dmsgC *dmsg.Client, port uint16) SignalingDialer {
+	return func(ctx context.Context, rPK cipher.PubKey) (net.Conn, error) {
+		stream, err := dmsgC.Dial(ctx, dmsg.Addr{PK: rPK, Port: port})
+		if err != nil {
+			return nil, err
+		}
+
+		return stream, nil
+	}
+}
+
+// offer is sent by the dialing visor over the signaling channel, and is answered with the candidates of the remote.
+type offer struct {
+	Token      punchToken `json:"token"`
+	Candidates []string   `json:"candidates"`
+}
+
+type answer struct {
+	Candidates []string `json:"candidates"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Client is the central control for incoming and outgoing 'sudph' connections.
+type Client struct {
+	log *logging.Logger
+
+	lPK           cipher.PubKey
+	lSK           cipher.SecKey
+	dialSignaling SignalingDialer
+	stunServers   []string
+	p             *stcp.Porter
+
+	conn      *udpConn
+	kcpL      *kcp.Listener
+	signaling net.Listener
+	lMap      map[uint16]*stcp.Listener // key: lPort
+	mx        sync.Mutex
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewClient creates a sudph Client, which exchanges candidates over signaling channels dialed with dialSignaling,
+// and learns its reflexive address from stunServers.
+func NewClient(pk cipher.PubKey, sk cipher.SecKey, dialSignaling SignalingDialer, stunServers []string) *Client {
+	return &Client{
+		log:           logging.MustGetLogger(Type),
+		lPK:           pk,
+		lSK:           sk,
+		dialSignaling: dialSignaling,
+		stunServers:   stunServers,
+		p:             stcp.NewPorter(stcp.PorterMinEphemeral),
+		lMap:          make(map[uint16]*stcp.Listener),
+		done:          make(chan struct{}),
+	}
+}
+
+// SetLogger sets a logger for Client.
+func (c *Client) SetLogger(log *logging.Logger) {
+	c.log = log
+}
+
+// Serve binds the UDP socket of the client to udpAddr, and answers offers of remotes received from signaling.
+// Dialing requires the client to be serving, as connections are established from the same socket.
+func (c *Client) Serve(udpAddr string, signaling net.Listener) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.conn != nil {
+		return errors.New("already listening")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+
+	udpConn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	conn := newUDPConn(udpConn)
+
+	kcpL, err := kcp.Listen(conn, kcp.Config{})
+	if err != nil {
+		_ = udpConn.Close() // nolint:errcheck
+		return err
+	}
+
+	c.conn, c.kcpL = conn, kcpL
+	c.signaling = signaling
+	c.log.Infof("listening on udp addr: %v", udpConn.LocalAddr())
+
+	go c.serveKCP(c.kcpL)
+	go c.serveSignaling(signaling)
+
+	return nil
+}
+
+func (c *Client) serveKCP(kcpL *kcp.Listener) {
+	for {
+		sess, err := kcpL.AcceptSession()
+		if err != nil {
+			if !c.isClosed() {
+				c.log.Warnf("stopped serving sudph: %v", err)
+			}
+
+			return
+		}
+
+		go func() {
+			if err := c.acceptSession(sess); err != nil {
+				c.log.Warnf("failed to accept incoming connection: %v", err)
+			}
+		}()
+	}
+}
+
+func (c *Client) acceptSession(sess *kcp.Session) error {
+	var lis *stcp.Listener
+
+	hs := stcp.ResponderHandshake(func(f2 stcp.Frame2) error {
+		c.mx.Lock()
+		defer c.mx.Unlock()
+
+		var ok bool
+		if lis, ok = c.lMap[f2.DstAddr.Port]; !ok {
+			return errors.New("not listening on given port")
+		}
+
+		return nil
+	})
+
+	conn, err := stcp.NewConn(sess, time.Now().Add(stcp.HandshakeTimeout), hs, nil)
+	if err != nil {
+		return err
+	}
+
+	return lis.Introduce(conn)
+}
+
+func (c *Client) serveSignaling(signaling net.Listener) {
+	for {
+		conn, err := signaling.Accept()
+		if err != nil {
+			if !c.isClosed() {
+				c.log.Warnf("stopped serving sudph signaling: %v", err)
+			}
+
+			return
+		}
+
+		go func() {
+			if err := c.answer(conn); err != nil {
+				c.log.Warnf("failed to answer hole punching of %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// answer answers the offer of a remote with the candidates of the client, and punches a hole towards the remote.
+func (c *Client) answer(conn net.Conn) error {
+	defer func() {
+		_ = conn.Close() // nolint:errcheck
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(signalingTimeout)); err != nil {
+		return err
+	}
+
+	var o offer
+	if err := json.NewDecoder(conn).Decode(&o); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), punchTimeout)
+	defer cancel()
+
+	candidates, cErr := c.candidates(ctx, c.conn)
+
+	a := answer{Candidates: candidates}
+	if cErr != nil {
+		a.Error = cErr.Error()
+	}
+
+	registered, unregister := c.conn.register(o.Token)
+	defer func() {
+		// Probes of the remote are answered until it gives up, even once its probes got through.
+		time.AfterFunc(punchTimeout, unregister)
+	}()
+
+	if err := json.NewEncoder(conn).Encode(a); err != nil {
+		return err
+	}
+
+	if cErr != nil {
+		return cErr
+	}
+
+	addr, err := c.conn.punch(ctx, o.Token, registered, o.Candidates)
+	if err != nil {
+		return err
+	}
+
+	c.log.Debugf("punched hole to %v", addr)
+
+	return nil
+}
+
+// Dial dials a new connection to specified remote public key and port.
+func (c *Client) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (*stcp.Conn, error) {
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	c.mx.Lock()
+	conn, kcpL := c.conn, c.kcpL
+	c.mx.Unlock()
+
+	if conn == nil {
+		return nil, ErrNotServing
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, punchTimeout)
+	defer cancel()
+
+	addr, err := c.punchTo(ctx, conn, rPK)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Debugf("punched hole to %s at %v", rPK, addr)
+
+	sess, err := kcpL.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	lPort, freePort, err := c.p.ReserveEphemeral(ctx)
+	if err != nil {
+		_ = sess.Close() // nolint:errcheck
+		return nil, err
+	}
+
+	hs := stcp.InitiatorHandshake(c.lSK, dmsg.Addr{PK: c.lPK, Port: lPort}, dmsg.Addr{PK: rPK, Port: rPort})
+
+	return stcp.NewConn(sess, time.Now().Add(stcp.HandshakeTimeout), hs, freePort)
+}
+
+// punchTo exchanges candidates with the remote rPK over signaling, and punches a hole towards it.
+func (c *Client) punchTo(ctx context.Context, conn *udpConn, rPK cipher.PubKey) (*net.UDPAddr, error) {
+	candidates, err := c.candidates(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newPunchToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sConn, err := c.dialSignaling(ctx, rPK)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = sConn.Close() // nolint:errcheck
+	}()
+
+	if err := sConn.SetDeadline(time.Now().Add(signalingTimeout)); err != nil {
+		return nil, err
+	}
+
+	// Probes of the remote may arrive as soon as it answers.
+	registered, unregister := conn.register(token)
+	defer unregister()
+
+	if err := json.NewEncoder(sConn).Encode(offer{Token: token, Candidates: candidates}); err != nil {
+		return nil, err
+	}
+
+	var a answer
+	if err := json.NewDecoder(sConn).Decode(&a); err != nil {
+		return nil, err
+	}
+
+	if a.Error != "" {
+		return nil, errors.New(a.Error)
+	}
+
+	return conn.punch(ctx, token, registered, a.Candidates)
+}
+
+// candidates returns the addresses at which the UDP socket of the client may be reached: its addresses on the
+// interfaces of the host (or the address it is bound to), and its reflexive address if STUN servers respond.
+func (c *Client) candidates(ctx context.Context, conn *udpConn) ([]string, error) {
+	lAddr := conn.LocalAddr().(*net.UDPAddr)
+	port := strconv.Itoa(lAddr.Port)
+
+	var candidates []string
+
+	if !lAddr.IP.IsUnspecified() {
+		candidates = append(candidates, lAddr.String())
+	} else {
+		ifAddrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ifAddr := range ifAddrs {
+			ipNet, ok := ifAddr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+
+			candidates = append(candidates, net.JoinHostPort(ipNet.IP.String(), port))
+		}
+	}
+
+	if len(c.stunServers) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, stunTimeout)
+		defer cancel()
+
+		addr, err := conn.reflexiveAddr(ctx, c.stunServers)
+		if err != nil {
+			c.log.Warnf("failed to obtain reflexive address from stun servers: %v", err)
+		} else if !contains(candidates, addr.String()) {
+			candidates = append(candidates, addr.String())
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.New("no candidates")
+	}
+
+	return candidates, nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Listen creates a new listener for sudph.
+// The created Listener cannot actually accept remote connections unless Serve is called beforehand.
+func (c *Client) Listen(lPort uint16) (*stcp.Listener, error) {
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	ok, freePort := c.p.Reserve(lPort)
+	if !ok {
+		return nil, errors.New("port is already occupied")
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	lAddr := dmsg.Addr{PK: c.lPK, Port: lPort}
+	lis := stcp.NewListener(lAddr, freePort)
+	c.lMap[lPort] = lis
+
+	return lis, nil
+}
+
+// Close closes the Client.
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.once.Do(func() {
+		close(c.done)
+
+		c.mx.Lock()
+		defer c.mx.Unlock()
+
+		if c.kcpL != nil {
+			_ = c.kcpL.Close() // nolint:errcheck
+		}
+
+		if c.signaling != nil {
+			_ = c.signaling.Close() // nolint:errcheck
+		}
+
+		for _, lis := range c.lMap {
+			_ = lis.Close() // nolint:errcheck
+		}
+	})
+
+	return nil
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Type returns the stream type.
+func (c *Client) Type() string {
+	return Type
+}
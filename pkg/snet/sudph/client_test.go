@@ -0,0 +1,105 @@
+package sudph
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/nettest"
+)
+
+func TestClient(t *testing.T) {
+	stunAddr, stopSTUN := serveSTUN(t)
+	defer stopSTUN()
+
+	// Signaling is done over TCP instead of dmsg.
+	signaling := make(map[cipher.PubKey]string)
+	dialSignaling := func(ctx context.Context, rPK cipher.PubKey) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", signaling[rPK])
+	}
+
+	newClient := func() (*Client, cipher.PubKey) {
+		pk, sk := cipher.GenerateKeyPair()
+		c := NewClient(pk, sk, dialSignaling, []string{stunAddr})
+
+		l, err := nettest.NewLocalListener("tcp")
+		require.NoError(t, err)
+
+		signaling[pk] = l.Addr().String()
+		require.NoError(t, c.Serve("127.0.0.1:0", l))
+
+		return c, pk
+	}
+
+	c1, _ := newClient()
+	c2, pk2 := newClient()
+
+	defer func() {
+		require.NoError(t, c1.Close())
+		require.NoError(t, c2.Close())
+	}()
+
+	candidates, err := c1.candidates(context.TODO(), c1.conn)
+	require.NoError(t, err)
+	// The reflexive address is the bound address, as there is no NAT on the way.
+	require.Equal(t, []string{c1.conn.LocalAddr().String()}, candidates)
+
+	lis, err := c2.Listen(10)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conn1, err := c1.Dial(ctx, pk2, 10)
+	require.NoError(t, err)
+
+	conn2, err := lis.Accept()
+	require.NoError(t, err)
+
+	require.Equal(t, conn1.LocalAddr(), conn2.RemoteAddr())
+	require.Equal(t, conn1.RemoteAddr(), conn2.LocalAddr())
+
+	_, err = conn1.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn2, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	require.NoError(t, conn1.Close())
+	require.NoError(t, conn2.Close())
+}
+
+// serveSTUN serves a STUN server on the loopback interface, and returns its address.
+func serveSTUN(t *testing.T) (addr string, stop func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		buf := make([]byte, 1500)
+
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var txID stunTxID
+			copy(txID[:], buf[8:n])
+
+			if _, err := conn.WriteTo(stunResponse(txID, addr.(*net.UDPAddr)), addr); err != nil {
+				return
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() {
+		require.NoError(t, conn.Close())
+	}
+}
@@ -0,0 +1,223 @@
+package sudph
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// punchMagic prefixes punch packets. KCP packets never start with it, as their fifth byte is a KCP command.
+	punchMagic     = "SUDPH\x00\x00\x00"
+	punchTokenSize = 16
+	punchSize      = len(punchMagic) + 1 + punchTokenSize
+	punchProbe     = byte(0) // sent to candidates of the remote
+	punchReply     = byte(1) // sent in response to probes
+)
+
+// punchToken identifies the hole punching of a connection.
+type punchToken [punchTokenSize]byte
+
+func newPunchToken() (punchToken, error) {
+	var token punchToken
+	_, err := rand.Read(token[:])
+
+	return token, err
+}
+
+func punchPacket(kind byte, token punchToken) []byte {
+	pkt := make([]byte, 0, punchSize)
+	pkt = append(pkt, punchMagic...)
+	pkt = append(pkt, kind)
+
+	return append(pkt, token[:]...)
+}
+
+// udpConn is the UDP socket of a client. It answers hole punching probes and delivers STUN responses,
+// and passes other packets to KCP, which reads the socket.
+type udpConn struct {
+	*net.UDPConn
+
+	mx      sync.Mutex
+	stun    map[stunTxID]chan *net.UDPAddr
+	punches map[punchToken]chan *net.UDPAddr
+}
+
+func newUDPConn(conn *net.UDPConn) *udpConn {
+	return &udpConn{
+		UDPConn: conn,
+		stun:    make(map[stunTxID]chan *net.UDPAddr),
+		punches: make(map[punchToken]chan *net.UDPAddr),
+	}
+}
+
+// ReadFrom implements net.PacketConn. It only returns packets which are neither STUN messages nor punch packets.
+func (c *udpConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.UDPConn.ReadFromUDP(b)
+		if err != nil {
+			return n, addr, err
+		}
+
+		switch pkt := b[:n]; {
+		case isSTUN(pkt):
+			c.handleSTUN(pkt)
+		case n == punchSize && bytes.HasPrefix(pkt, []byte(punchMagic)):
+			c.handlePunch(pkt, addr)
+		default:
+			return n, addr, nil
+		}
+	}
+}
+
+func (c *udpConn) handleSTUN(pkt []byte) {
+	txID, addr, err := parseSTUNResponse(pkt)
+	if err != nil {
+		return
+	}
+
+	c.mx.Lock()
+	ch, ok := c.stun[txID]
+	c.mx.Unlock()
+
+	if ok {
+		select {
+		case ch <- addr:
+		default:
+		}
+	}
+}
+
+func (c *udpConn) handlePunch(pkt []byte, addr *net.UDPAddr) {
+	var token punchToken
+	copy(token[:], pkt[len(punchMagic)+1:])
+
+	c.mx.Lock()
+	ch, ok := c.punches[token]
+	c.mx.Unlock()
+
+	// Probes of unknown tokens are not answered, so that the socket cannot be used to reflect traffic.
+	if !ok {
+		return
+	}
+
+	if pkt[len(punchMagic)] == punchProbe {
+		_, _ = c.WriteTo(punchPacket(punchReply, token), addr) // nolint:errcheck
+	}
+
+	select {
+	case ch <- addr:
+	default:
+	}
+}
+
+// reflexiveAddr returns the address of the socket as seen by STUN servers, which is the address mapped by NATs
+// on the way. The first server to respond wins.
+func (c *udpConn) reflexiveAddr(ctx context.Context, servers []string) (*net.UDPAddr, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("no stun servers")
+	}
+
+	ch := make(chan *net.UDPAddr, 1)
+
+	var txIDs []stunTxID
+
+	defer func() {
+		c.mx.Lock()
+		for _, txID := range txIDs {
+			delete(c.stun, txID)
+		}
+		c.mx.Unlock()
+	}()
+
+	var lastErr error
+
+	for _, server := range servers {
+		addr, err := net.ResolveUDPAddr("udp", server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var txID stunTxID
+		if _, err := rand.Read(txID[:]); err != nil {
+			return nil, err
+		}
+
+		c.mx.Lock()
+		c.stun[txID] = ch
+		c.mx.Unlock()
+
+		txIDs = append(txIDs, txID)
+
+		if _, err := c.WriteTo(stunRequest(txID), addr); err != nil {
+			lastErr = err
+		}
+	}
+
+	if len(txIDs) == 0 {
+		return nil, lastErr
+	}
+
+	select {
+	case addr := <-ch:
+		return addr, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// register registers token, so that probes with it are answered. Addresses of the remote are sent to the returned
+// channel until unregister is called.
+func (c *udpConn) register(token punchToken) (addrs <-chan *net.UDPAddr, unregister func()) {
+	ch := make(chan *net.UDPAddr, 1)
+
+	c.mx.Lock()
+	c.punches[token] = ch
+	c.mx.Unlock()
+
+	return ch, func() {
+		c.mx.Lock()
+		delete(c.punches, token)
+		c.mx.Unlock()
+	}
+}
+
+// punch sends probes with token to the candidate addresses of a remote, until a probe or reply is received from
+// the remote on registered. It returns the address the remote is reached at.
+func (c *udpConn) punch(ctx context.Context, token punchToken, registered <-chan *net.UDPAddr, candidates []string) (*net.UDPAddr, error) {
+	addrs := make([]*net.UDPAddr, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if addr, err := net.ResolveUDPAddr("udp", candidate); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.New("no valid candidates")
+	}
+
+	ticker := time.NewTicker(punchInterval)
+	defer ticker.Stop()
+
+	probe := punchPacket(punchProbe, token)
+
+	for {
+		for _, addr := range addrs {
+			_, _ = c.WriteTo(probe, addr) // nolint:errcheck
+		}
+
+		select {
+		case addr := <-registered:
+			return addr, nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sudph
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// STUN message constants (RFC 5389).
+const (
+	stunHeaderSize       = 20
+	stunMagicCookie      = 0x2112A442
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	attrMappedAddress    = 0x0001
+	attrXORMappedAddress = 0x0020
+	familyIPv4           = 0x01
+	familyIPv6           = 0x02
+)
+
+var (
+	errNotBindingSuccess = errors.New("stun: not a binding success response")
+	errNoMappedAddress   = errors.New("stun: response has no mapped address")
+	errMalformed         = errors.New("stun: malformed message")
+)
+
+// stunTxID is the transaction ID of a STUN request.
+type stunTxID [12]byte
+
+// stunRequest returns a binding request, to which STUN servers respond with the address they received it from.
+func stunRequest(txID stunTxID) []byte {
+	msg := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(msg[0:], stunBindingRequest)
+	binary.BigEndian.PutUint16(msg[2:], 0)
+	binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+	copy(msg[8:], txID[:])
+
+	return msg
+}
+
+// isSTUN reports whether pkt looks like a STUN message.
+func isSTUN(pkt []byte) bool {
+	return len(pkt) >= stunHeaderSize &&
+		pkt[0]&0xC0 == 0 &&
+		binary.BigEndian.Uint32(pkt[4:]) == stunMagicCookie
+}
+
+// parseSTUNResponse returns the transaction ID and mapped address of a binding success response.
+func parseSTUNResponse(pkt []byte) (stunTxID, *net.UDPAddr, error) {
+	var txID stunTxID
+
+	if !isSTUN(pkt) {
+		return txID, nil, errMalformed
+	}
+
+	copy(txID[:], pkt[8:stunHeaderSize])
+
+	if binary.BigEndian.Uint16(pkt[0:]) != stunBindingSuccess {
+		return txID, nil, errNotBindingSuccess
+	}
+
+	length := int(binary.BigEndian.Uint16(pkt[2:]))
+	if len(pkt) < stunHeaderSize+length {
+		return txID, nil, errMalformed
+	}
+
+	var mapped *net.UDPAddr
+
+	for attrs := pkt[stunHeaderSize : stunHeaderSize+length]; len(attrs) >= 4; {
+		typ := binary.BigEndian.Uint16(attrs[0:])
+		size := int(binary.BigEndian.Uint16(attrs[2:]))
+
+		if len(attrs) < 4+size {
+			return txID, nil, errMalformed
+		}
+
+		value := attrs[4 : 4+size]
+
+		switch typ {
+		case attrXORMappedAddress:
+			addr, err := parseAddress(value, pkt[4:stunHeaderSize])
+			if err != nil {
+				return txID, nil, err
+			}
+
+			// XOR-MAPPED-ADDRESS is preferred, as NATs may rewrite addresses in the plain attribute.
+			return txID, addr, nil
+		case attrMappedAddress:
+			addr, err := parseAddress(value, nil)
+			if err != nil {
+				return txID, nil, err
+			}
+
+			mapped = addr
+		}
+
+		// Attributes are padded to 4 bytes.
+		attrs = attrs[4+(size+3)&^3:]
+	}
+
+	if mapped == nil {
+		return txID, nil, errNoMappedAddress
+	}
+
+	return txID, mapped, nil
+}
+
+// parseAddress parses the value of an address attribute, which is XORed with key unless key is nil.
+// The key is the magic cookie followed by the transaction ID.
+func parseAddress(value, key []byte) (*net.UDPAddr, error) {
+	if len(value) < 4 {
+		return nil, errMalformed
+	}
+
+	var ip net.IP
+
+	switch value[1] {
+	case familyIPv4:
+		ip = make(net.IP, net.IPv4len)
+	case familyIPv6:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return nil, errMalformed
+	}
+
+	if len(value) < 4+len(ip) {
+		return nil, errMalformed
+	}
+
+	port := binary.BigEndian.Uint16(value[2:])
+	copy(ip, value[4:])
+
+	if key != nil {
+		port ^= stunMagicCookie >> 16
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
@@ -0,0 +1,43 @@
+package sudph
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stunResponse returns a binding success response to a request with txID, mapping it to addr.
+func stunResponse(txID stunTxID, addr *net.UDPAddr) []byte {
+	ip := addr.IP.To4()
+
+	attr := make([]byte, 12)
+	binary.BigEndian.PutUint16(attr[0:], attrXORMappedAddress)
+	binary.BigEndian.PutUint16(attr[2:], 8)
+	attr[5] = familyIPv4
+	binary.BigEndian.PutUint16(attr[6:], uint16(addr.Port)^stunMagicCookie>>16)
+	binary.BigEndian.PutUint32(attr[8:], binary.BigEndian.Uint32(ip)^stunMagicCookie)
+
+	msg := stunRequest(txID)
+	binary.BigEndian.PutUint16(msg[0:], stunBindingSuccess)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(attr)))
+
+	return append(msg, attr...)
+}
+
+func TestParseSTUNResponse(t *testing.T) {
+	txID := stunTxID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	want := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7).To4(), Port: 40123}
+
+	gotTxID, got, err := parseSTUNResponse(stunResponse(txID, want))
+	require.NoError(t, err)
+	require.Equal(t, txID, gotTxID)
+	require.Equal(t, want.String(), got.String())
+
+	_, _, err = parseSTUNResponse(stunRequest(txID))
+	require.Equal(t, errNotBindingSuccess, err)
+
+	_, _, err = parseSTUNResponse([]byte("not a stun message at all"))
+	require.Equal(t, errMalformed, err)
+}
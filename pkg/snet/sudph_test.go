@@ -0,0 +1,56 @@
+package snet_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/snet"
+	"github.com/skycoin/skywire/pkg/snet/snettest"
+)
+
+func TestNetwork_SUDPH(t *testing.T) {
+	keys := snettest.GenKeyPairs(2)
+	nEnv := snettest.NewEnv(t, keys, []string{snet.SUDPHType})
+	defer nEnv.Teardown()
+
+	require.Contains(t, nEnv.Nets[0].TransportNetworks(), snet.SUDPHType)
+
+	lis, err := nEnv.Nets[1].Listen(snet.SUDPHType, snet.TransportPort)
+	require.NoError(t, err)
+
+	// Offers are sent over dmsg, which takes a moment to connect.
+	var conn0 *snet.Conn
+
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(100 * time.Millisecond) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		conn0, err = nEnv.Nets[0].Dial(ctx, snet.SUDPHType, keys[1].PK, snet.TransportPort)
+		cancel()
+
+		if err == nil {
+			break
+		}
+	}
+
+	require.NoError(t, err)
+	require.Equal(t, keys[1].PK, conn0.RemotePK())
+	require.Equal(t, snet.SUDPHType, conn0.Network())
+
+	conn1, err := lis.AcceptConn()
+	require.NoError(t, err)
+	require.Equal(t, keys[0].PK, conn1.RemotePK())
+
+	_, err = conn0.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn1, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	require.NoError(t, conn0.Close())
+	require.NoError(t, conn1.Close())
+}
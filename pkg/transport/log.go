@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -18,25 +20,41 @@ import (
 // LogEntry represents a logging entry for a given Transport.
 // The entry is updated every time a packet is received or sent.
 type LogEntry struct {
-	RecvBytes uint64 `json:"recv"` // Total received bytes.
-	SentBytes uint64 `json:"sent"` // Total sent bytes.
+	RecvBytes    uint64 `json:"recv"`          // Total received bytes.
+	SentBytes    uint64 `json:"sent"`          // Total sent bytes.
+	CreatedAt    int64  `json:"created_at"`    // Unix time (in seconds) of when the entry was created.
+	LastActivity int64  `json:"last_activity"` // Unix time (in seconds) of the last read or write.
+}
+
+// NewLogEntry creates a new LogEntry, created at the current time.
+func NewLogEntry() *LogEntry {
+	return &LogEntry{CreatedAt: time.Now().Unix()}
 }
 
 // AddRecv records read.
 func (le *LogEntry) AddRecv(n uint64) {
 	atomic.AddUint64(&le.RecvBytes, n)
+	atomic.StoreInt64(&le.LastActivity, time.Now().Unix())
 }
 
 // AddSent records write.
 func (le *LogEntry) AddSent(n uint64) {
 	atomic.AddUint64(&le.SentBytes, n)
+	atomic.StoreInt64(&le.LastActivity, time.Now().Unix())
+}
+
+// TotalBytes returns the total of received and sent bytes.
+func (le *LogEntry) TotalBytes() uint64 {
+	return atomic.LoadUint64(&le.RecvBytes) + atomic.LoadUint64(&le.SentBytes)
 }
 
 // MarshalJSON implements json.Marshaller
 func (le *LogEntry) MarshalJSON() ([]byte, error) {
 	rb := strconv.FormatUint(atomic.LoadUint64(&le.RecvBytes), 10)
 	sb := strconv.FormatUint(atomic.LoadUint64(&le.SentBytes), 10)
-	return []byte(`{"recv":` + rb + `,"sent":` + sb + `}`), nil
+	ca := strconv.FormatInt(atomic.LoadInt64(&le.CreatedAt), 10)
+	la := strconv.FormatInt(atomic.LoadInt64(&le.LastActivity), 10)
+	return []byte(`{"recv":` + rb + `,"sent":` + sb + `,"created_at":` + ca + `,"last_activity":` + la + `}`), nil
 }
 
 // GobEncode implements gob.GobEncoder
@@ -49,6 +67,12 @@ func (le *LogEntry) GobEncode() ([]byte, error) {
 	if err := enc.Encode(le.SentBytes); err != nil {
 		return nil, err
 	}
+	if err := enc.Encode(atomic.LoadInt64(&le.CreatedAt)); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(atomic.LoadInt64(&le.LastActivity)); err != nil {
+		return nil, err
+	}
 	return b.Bytes(), nil
 }
 
@@ -66,6 +90,19 @@ func (le *LogEntry) GobDecode(b []byte) error {
 	}
 	atomic.StoreUint64(&le.RecvBytes, rb)
 	atomic.StoreUint64(&le.SentBytes, sb)
+	// Timestamps are absent in entries encoded by older versions.
+	var ca, la int64
+	if err := dec.Decode(&ca); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if err := dec.Decode(&la); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&le.CreatedAt, ca)
+	atomic.StoreInt64(&le.LastActivity, la)
 	return nil
 }
 
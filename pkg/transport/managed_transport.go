@@ -13,7 +13,6 @@ import (
 
 	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/dmsg/httputil"
-	"github.com/skycoin/dmsg/netutil"
 	"github.com/skycoin/skycoin/src/util/logging"
 
 	"github.com/skycoin/skywire/pkg/routing"
@@ -34,15 +33,6 @@ var (
 	ErrConnAlreadyExists = errors.New("underlying transport connection already exists")
 )
 
-// Constants associated with transport redial loop.
-// @evanlinjin: I see no need to make these configurable.
-const (
-	tpInitBO = time.Millisecond * 500
-	tpMaxBO  = time.Second * 10
-	tpTries  = 0
-	tpFactor = 1.3
-)
-
 // ManagedTransport manages a direct line of communication between two visor nodes.
 // There is a single underlying connection between two edges.
 // Initial dialing can be requested by either edge of the connection.
@@ -55,6 +45,8 @@ type ManagedTransport struct {
 	Entry      Entry
 	LogEntry   *LogEntry
 	logUpdates uint32
+	readErrs   uint64 // updated atomically
+	writeErrs  uint64 // updated atomically
 
 	dc DiscoveryClient
 	ls LogStore
@@ -63,6 +55,13 @@ type ManagedTransport struct {
 	isUpErr error // records whether the last status update was successful or not
 	isUpMux sync.Mutex
 
+	statusHook func(mt *ManagedTransport, isUp bool) // called when isUp changes, if set
+
+	reconnect     ReconnectConfig
+	reconnectHook func(mt *ManagedTransport, ev ReconnectEvent) // called when reconnection attempts fail, if set
+	redialCh      chan struct{}                                 // signaled when the underlying connection drops
+	gaveUp        bool                                          // whether reconnection was given up, until reconnected
+
 	redialCancel context.CancelFunc // for canceling redialling logic
 	redialMx     sync.Mutex
 
@@ -79,16 +78,18 @@ type ManagedTransport struct {
 // NewManagedTransport creates a new ManagedTransport.
 func NewManagedTransport(n *snet.Network, dc DiscoveryClient, ls LogStore, rPK cipher.PubKey, netName string) *ManagedTransport {
 	mt := &ManagedTransport{
-		log:      logging.MustGetLogger(fmt.Sprintf("tp:%s", rPK.String()[:6])),
-		rPK:      rPK,
-		netName:  netName,
-		n:        n,
-		dc:       dc,
-		ls:       ls,
-		Entry:    makeEntry(n.LocalPK(), rPK, netName),
-		LogEntry: new(LogEntry),
-		connCh:   make(chan struct{}, 1),
-		done:     make(chan struct{}),
+		log:       logging.MustGetLogger(fmt.Sprintf("tp:%s", rPK.String()[:6])),
+		rPK:       rPK,
+		netName:   netName,
+		n:         n,
+		dc:        dc,
+		ls:        ls,
+		Entry:     makeEntry(n.LocalPK(), rPK, netName),
+		LogEntry:  NewLogEntry(),
+		reconnect: DefaultReconnectConfig(),
+		redialCh:  make(chan struct{}, 1),
+		connCh:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
 	}
 	mt.wg.Add(2)
 	return mt
@@ -153,6 +154,13 @@ func (mt *ManagedTransport) Serve(readCh chan<- routing.Packet) {
 				mt.clearConn()
 				mt.connMx.Unlock()
 				log.WithError(err).Warn("Failed to read packet.")
+
+				// Reconnect straight away, rather than on the next check.
+				select {
+				case mt.redialCh <- struct{}{}:
+				default:
+				}
+
 				continue
 			}
 			select {
@@ -180,15 +188,11 @@ func (mt *ManagedTransport) Serve(readCh chan<- routing.Packet) {
 				continue
 			}
 
-			// Only least significant edge is responsible for redialing.
-			if !mt.isLeastSignificantEdge() {
-				continue
-			}
-
 			// If there has not been any activity, ensure underlying 'write' tp is still up.
-			if err := mt.redialLoop(ctx); err != nil {
-				mt.log.WithError(err).Debug("Stopped reconnecting underlying connection.")
-			}
+			mt.reconnectIfDown(ctx)
+
+		case <-mt.redialCh:
+			mt.reconnectIfDown(ctx)
 		}
 	}
 }
@@ -269,7 +273,7 @@ func (mt *ManagedTransport) Dial(ctx context.Context) error {
 }
 
 func (mt *ManagedTransport) dial(ctx context.Context) error {
-	tp, err := mt.n.Dial(ctx, mt.netName, mt.rPK, skyenv.DmsgTransportPort)
+	tp, err := mt.dialNetwork(ctx)
 	if err != nil {
 		return err
 	}
@@ -311,7 +315,28 @@ func (mt *ManagedTransport) redial(ctx context.Context) error {
 	return mt.dial(ctx)
 }
 
-// redialLoop calls redial in a loop with exponential back-off until success or transport closure.
+// reconnectIfDown re-establishes the underlying connection if it dropped, unless reconnection was given up.
+func (mt *ManagedTransport) reconnectIfDown(ctx context.Context) {
+	// Only least significant edge is responsible for redialing.
+	if !mt.isLeastSignificantEdge() {
+		return
+	}
+
+	mt.redialMx.Lock()
+	gaveUp := mt.gaveUp
+	mt.redialMx.Unlock()
+
+	if gaveUp {
+		return
+	}
+
+	if err := mt.redialLoop(ctx); err != nil {
+		mt.log.WithError(err).Debug("Stopped reconnecting underlying connection.")
+	}
+}
+
+// redialLoop calls redial in a loop with exponential back-off and jitter until success, transport closure, or
+// the configured number of attempts.
 func (mt *ManagedTransport) redialLoop(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -320,18 +345,83 @@ func (mt *ManagedTransport) redialLoop(ctx context.Context) error {
 	mt.redialCancel = cancel
 	mt.redialMx.Unlock()
 
-	retry := netutil.NewRetrier(mt.log, tpInitBO, tpMaxBO, tpTries, tpFactor).
-		WithErrWhitelist(ErrNotServing, context.Canceled)
+	for attempt := 1; ; attempt++ {
+		// Only redial when there is no underlying conn.
+		var err error
 
-	// Only redial when there is no underlying conn.
-	return retry.Do(ctx, func() (err error) {
 		mt.connMx.Lock()
 		if mt.conn == nil {
 			err = mt.redial(ctx)
 		}
 		mt.connMx.Unlock()
-		return err
-	})
+
+		if err == nil || err == ErrNotServing || ctx.Err() != nil {
+			return err
+		}
+
+		ev := ReconnectEvent{Attempt: attempt, Err: err}
+
+		if mt.reconnect.Retries > 0 && attempt >= mt.reconnect.Retries {
+			ev.GaveUp = true
+
+			mt.redialMx.Lock()
+			mt.gaveUp = true
+			mt.redialMx.Unlock()
+		} else {
+			ev.Backoff = mt.reconnect.Backoff(attempt)
+		}
+
+		mt.log.WithError(err).WithField("attempt", attempt).WithField("backoff", ev.Backoff).
+			Debug("Failed to reconnect underlying connection.")
+
+		if mt.reconnectHook != nil {
+			mt.reconnectHook(mt, ev)
+		}
+
+		if ev.GaveUp {
+			return ErrReconnectGaveUp
+		}
+
+		t := time.NewTimer(ev.Backoff)
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// dialNetwork dials the remote, and gives up when ctx is canceled. Dmsg dials don't, so a remote which is shutting
+// down would otherwise block the transport (and its closure) while reconnecting.
+func (mt *ManagedTransport) dialNetwork(ctx context.Context) (*snet.Conn, error) {
+	type dialResult struct {
+		conn *snet.Conn
+		err  error
+	}
+
+	ch := make(chan dialResult, 1)
+
+	go func() {
+		conn, err := mt.n.Dial(ctx, mt.netName, mt.rPK, skyenv.DmsgTransportPort)
+		ch <- dialResult{conn, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				if err := r.conn.Close(); err != nil {
+					mt.log.WithError(err).Warn("Failed to close connection dialed after cancellation.")
+				}
+			}
+		}()
+
+		return nil, ctx.Err()
+	}
 }
 
 func (mt *ManagedTransport) isLeastSignificantEdge() bool {
@@ -390,6 +480,7 @@ func (mt *ManagedTransport) setConn(newConn *snet.Conn) error {
 	if mt.redialCancel != nil {
 		mt.redialCancel()
 	}
+	mt.gaveUp = false
 	mt.redialMx.Unlock()
 
 	return nil
@@ -470,6 +561,11 @@ func (mt *ManagedTransport) updateStatus(isUp bool, tries int) (err error) {
 	mt.isUp = isUp
 	mt.isUpErr = err
 	mt.isUpMux.Unlock()
+
+	if mt.statusHook != nil {
+		mt.statusHook(mt, isUp)
+	}
+
 	return err
 }
 
@@ -491,6 +587,7 @@ func (mt *ManagedTransport) WritePacket(ctx context.Context, packet routing.Pack
 
 	if mt.conn == nil {
 		if err := mt.redial(ctx); err != nil {
+			atomic.AddUint64(&mt.writeErrs, 1)
 
 			// TODO(evanlinjin): Determine whether we need to call 'mt.wg.Wait()' here.
 			if err == ErrNotServing {
@@ -503,6 +600,7 @@ func (mt *ManagedTransport) WritePacket(ctx context.Context, packet routing.Pack
 
 	n, err := mt.conn.Write(packet)
 	if err != nil {
+		atomic.AddUint64(&mt.writeErrs, 1)
 		mt.clearConn()
 		return err
 	}
@@ -532,6 +630,7 @@ func (mt *ManagedTransport) readPacket() (packet routing.Packet, err error) {
 
 	h := make(routing.Packet, routing.PacketHeaderSize)
 	if _, err = io.ReadFull(conn, h); err != nil {
+		atomic.AddUint64(&mt.readErrs, 1)
 		log.WithError(err).Debugf("Failed to read packet header.")
 		return nil, err
 	}
@@ -539,6 +638,7 @@ func (mt *ManagedTransport) readPacket() (packet routing.Packet, err error) {
 
 	p := make([]byte, h.Size())
 	if _, err = io.ReadFull(conn, p); err != nil {
+		atomic.AddUint64(&mt.readErrs, 1)
 		log.WithError(err).Debugf("Failed to read packet payload.")
 		return nil, err
 	}
@@ -578,6 +678,19 @@ func (mt *ManagedTransport) logMod() bool {
 	return false
 }
 
+// Errors returns the number of failed reads and writes of packets over the transport.
+func (mt *ManagedTransport) Errors() (read, write uint64) {
+	return atomic.LoadUint64(&mt.readErrs), atomic.LoadUint64(&mt.writeErrs)
+}
+
+// IsUp returns whether the transport has an underlying connection, as last reported to transport discovery.
+func (mt *ManagedTransport) IsUp() bool {
+	mt.isUpMux.Lock()
+	defer mt.isUpMux.Unlock()
+
+	return mt.isUp
+}
+
 // Remote returns the remote public key.
 func (mt *ManagedTransport) Remote() cipher.PubKey { return mt.rPK }
 
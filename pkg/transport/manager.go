@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +20,9 @@ import (
 	"github.com/skycoin/skywire/pkg/snet/snettest"
 )
 
+// ErrDraining is returned for new transports once the manager is draining.
+var ErrDraining = errors.New("transport manager is draining, new transports are refused")
+
 // ManagerConfig configures a Manager.
 type ManagerConfig struct {
 	PubKey          cipher.PubKey
@@ -26,6 +30,9 @@ type ManagerConfig struct {
 	DefaultVisors   []cipher.PubKey // Visors to automatically connect to
 	DiscoveryClient DiscoveryClient
 	LogStore        LogStore
+	StatusHook      func(tp *ManagedTransport, isUp bool)         // If set, called when transports go up or down.
+	Reconnect       ReconnectConfig                               // How transports are re-established once they drop.
+	ReconnectHook   func(tp *ManagedTransport, ev ReconnectEvent) // If set, called when reconnection attempts fail.
 }
 
 // Manager manages Transports.
@@ -43,6 +50,11 @@ type Manager struct {
 	serveOnce sync.Once // ensure we only serve once.
 	closeOnce sync.Once // ensure we only close once.
 	done      chan struct{}
+	draining  int32         // set atomically by Drain
+	ready     chan struct{} // closed once serving
+
+	failureMx sync.Mutex
+	failure   error // set once the manager stops accepting transports unexpectedly
 }
 
 // NewManager creates a Manager with the provided configuration and transport factories.
@@ -60,6 +72,7 @@ func NewManager(n *snet.Network, config *ManagerConfig) (*Manager, error) {
 		n:      n,
 		readCh: make(chan routing.Packet, 20),
 		done:   make(chan struct{}),
+		ready:  make(chan struct{}),
 	}
 	return tm, nil
 }
@@ -108,6 +121,9 @@ func (tm *Manager) serve(ctx context.Context) {
 					if err := tm.acceptTransport(ctx, lis); err != nil {
 						tm.Logger.Warnf("Failed to accept connection: %s", err)
 						if strings.Contains(err.Error(), "closed") {
+							if !tm.isClosing() && ctx.Err() == nil {
+								tm.fail(fmt.Errorf("listener of network '%s' stopped: %w", lis.Network(), err))
+							}
 							return
 						}
 					}
@@ -118,6 +134,7 @@ func (tm *Manager) serve(ctx context.Context) {
 
 	tm.initTransports(ctx)
 	tm.Logger.Info("transport manager is serving.")
+	close(tm.ready)
 
 	// closing logic
 	<-tm.done
@@ -176,10 +193,18 @@ func (tm *Manager) acceptTransport(ctx context.Context, lis *snet.Listener) erro
 	tpID := tm.tpIDFromPK(conn.RemotePK(), conn.Network())
 
 	mTp, ok := tm.tps[tpID]
+	if !ok && tm.isDraining() {
+		if err := conn.Close(); err != nil {
+			tm.Logger.WithError(err).Warn("Failed to close refused connection.")
+		}
+
+		return ErrDraining
+	}
+
 	if !ok {
 		tm.Logger.Debugln("No TP found, creating new one")
 
-		mTp = NewManagedTransport(tm.n, tm.Conf.DiscoveryClient, tm.Conf.LogStore, conn.RemotePK(), lis.Network())
+		mTp = tm.newManagedTransport(conn.RemotePK(), lis.Network())
 
 		go func() {
 			mTp.Serve(tm.readCh)
@@ -269,7 +294,11 @@ func (tm *Manager) saveTransport(remote cipher.PubKey, netName string) (*Managed
 		return tp, nil
 	}
 
-	mTp := NewManagedTransport(tm.n, tm.Conf.DiscoveryClient, tm.Conf.LogStore, remote, netName)
+	if tm.isDraining() {
+		return nil, ErrDraining
+	}
+
+	mTp := tm.newManagedTransport(remote, netName)
 	go func() {
 		mTp.Serve(tm.readCh)
 		tm.mx.Lock()
@@ -282,6 +311,15 @@ func (tm *Manager) saveTransport(remote cipher.PubKey, netName string) (*Managed
 	return mTp, nil
 }
 
+func (tm *Manager) newManagedTransport(remote cipher.PubKey, netName string) *ManagedTransport {
+	mTp := NewManagedTransport(tm.n, tm.Conf.DiscoveryClient, tm.Conf.LogStore, remote, netName)
+	mTp.statusHook = tm.Conf.StatusHook
+	mTp.reconnect = tm.Conf.Reconnect
+	mTp.reconnectHook = tm.Conf.ReconnectHook
+
+	return mTp
+}
+
 // DeleteTransport deregisters the Transport of Transport ID in transport discovery and deletes it locally.
 func (tm *Manager) DeleteTransport(id uuid.UUID) {
 	tm.mx.Lock()
@@ -365,14 +403,15 @@ func (tm *Manager) close() {
 	}
 
 	tm.mx.Lock()
-	defer tm.mx.Unlock()
-
 	close(tm.done)
 
 	statuses := make([]*Status, 0, len(tm.tps))
 	for _, tr := range tm.tps {
 		tr.close()
 	}
+	// Connections accepted meanwhile wait for the lock, so it should not be held while waiting for the listeners.
+	tm.mx.Unlock()
+
 	if _, err := tm.Conf.DiscoveryClient.UpdateStatuses(context.Background(), statuses...); err != nil {
 		tm.Logger.Warnf("failed to update transport statuses: %v", err)
 	}
@@ -384,6 +423,40 @@ func (tm *Manager) close() {
 	close(tm.readCh)
 }
 
+// Drain makes the manager refuse new transports, both dialed and accepted.
+// Existing transports are kept, and may still reconnect.
+func (tm *Manager) Drain() {
+	atomic.StoreInt32(&tm.draining, 1)
+}
+
+// Ready returns a channel which is closed once the manager listens on all its networks,
+// and has initialized the transports registered in the discovery.
+func (tm *Manager) Ready() <-chan struct{} {
+	return tm.ready
+}
+
+// Failure returns the error which stopped the manager from accepting transports, or nil while it accepts them.
+func (tm *Manager) Failure() error {
+	tm.failureMx.Lock()
+	defer tm.failureMx.Unlock()
+
+	return tm.failure
+}
+
+func (tm *Manager) fail(err error) {
+	tm.failureMx.Lock()
+	defer tm.failureMx.Unlock()
+
+	if tm.failure == nil {
+		tm.failure = err
+		tm.Logger.WithError(err).Error("Stopped accepting transports.")
+	}
+}
+
+func (tm *Manager) isDraining() bool {
+	return atomic.LoadInt32(&tm.draining) == 1
+}
+
 func (tm *Manager) isClosing() bool {
 	select {
 	case <-tm.done:
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrReconnectGaveUp is returned when the underlying connection of a transport could not be re-established within
+// the configured number of attempts.
+var ErrReconnectGaveUp = errors.New("gave up reconnecting transport")
+
+// ReconnectConfig configures how the underlying connections of transports are re-established once they drop.
+// Only the edge with the least-significant public key reconnects. Zero values are replaced with defaults.
+type ReconnectConfig struct {
+	Retries     int           // Attempts before giving up, unlimited if 0.
+	InitBackoff time.Duration // Delay after the first failed attempt.
+	MaxBackoff  time.Duration // Maximum delay between attempts.
+	Factor      float64       // Multiplier of the delay after each failed attempt.
+	Jitter      float64       // Fraction of the delay which is randomly added or removed, from 0 to 1.
+}
+
+// DefaultReconnectConfig returns the default reconnection config, which retries forever.
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		InitBackoff: 500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		Factor:      1.3,
+		Jitter:      0.2,
+	}
+}
+
+// Validate checks the reconnection config for values which cannot be used.
+func (c ReconnectConfig) Validate() error {
+	switch {
+	case c.Retries < 0:
+		return errors.New("retries cannot be negative")
+	case c.InitBackoff < 0 || c.MaxBackoff < 0:
+		return errors.New("backoffs cannot be negative")
+	case c.Factor != 0 && c.Factor < 1:
+		return errors.New("factor cannot be lower than 1")
+	case c.Jitter < 0 || c.Jitter > 1:
+		return errors.New("jitter should be between 0 and 1")
+	}
+
+	return nil
+}
+
+func (c ReconnectConfig) withDefaults() ReconnectConfig {
+	d := DefaultReconnectConfig()
+
+	if c.InitBackoff <= 0 {
+		c.InitBackoff = d.InitBackoff
+	}
+
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = d.MaxBackoff
+	}
+
+	if c.MaxBackoff < c.InitBackoff {
+		c.MaxBackoff = c.InitBackoff
+	}
+
+	if c.Factor < 1 {
+		c.Factor = d.Factor
+	}
+
+	return c
+}
+
+// Backoff returns the delay after the failed attempt (from 1), with jitter.
+func (c ReconnectConfig) Backoff(attempt int) time.Duration {
+	c = c.withDefaults()
+
+	bo := float64(c.InitBackoff)
+	for i := 1; i < attempt && bo < float64(c.MaxBackoff); i++ {
+		bo *= c.Factor
+	}
+
+	if bo > float64(c.MaxBackoff) {
+		bo = float64(c.MaxBackoff)
+	}
+
+	// Jitter spreads the reconnections of transports which dropped at once, such as on a network blip.
+	bo += bo * c.Jitter * (2*rand.Float64() - 1) // nolint:gosec
+
+	return time.Duration(bo)
+}
+
+// ReconnectEvent is a failed attempt to re-establish the underlying connection of a transport.
+type ReconnectEvent struct {
+	Attempt int           // Number of the attempt, from 1.
+	Err     error         // Why the attempt failed.
+	Backoff time.Duration // Delay before the next attempt.
+	GaveUp  bool          // Whether there are no attempts left.
+}
@@ -0,0 +1,41 @@
+package transport_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+func TestReconnectConfig_Validate(t *testing.T) {
+	assert.NoError(t, transport.ReconnectConfig{}.Validate())
+	assert.NoError(t, transport.DefaultReconnectConfig().Validate())
+
+	for _, c := range []transport.ReconnectConfig{
+		{Retries: -1},
+		{InitBackoff: -time.Second},
+		{Factor: 0.5},
+		{Jitter: 1.5},
+	} {
+		assert.Error(t, c.Validate(), c)
+	}
+}
+
+func TestReconnectConfig_Backoff(t *testing.T) {
+	c := transport.ReconnectConfig{InitBackoff: time.Second, MaxBackoff: 10 * time.Second, Factor: 2}
+
+	assert.Equal(t, time.Second, c.Backoff(1))
+	assert.Equal(t, 2*time.Second, c.Backoff(2))
+	assert.Equal(t, 8*time.Second, c.Backoff(4))
+	assert.Equal(t, 10*time.Second, c.Backoff(5))
+	assert.Equal(t, 10*time.Second, c.Backoff(1000))
+
+	c.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		bo := c.Backoff(2)
+		assert.True(t, bo >= time.Second && bo <= 3*time.Second, bo)
+	}
+}
@@ -0,0 +1,182 @@
+// Package cfgutil decodes JSON configs strictly, reporting the path of invalid fields,
+// and migrates configs of older versions forward.
+package cfgutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidConfig is wrapped by the errors of configs which fail decoding, migration or validation.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// FieldError reports why a config field is invalid.
+type FieldError struct {
+	Path   string // Path of the field, such as "apps[1].port". Empty if the error is not about a single field.
+	Reason string
+}
+
+// Invalid returns a FieldError for the field at path.
+func Invalid(path, format string, v ...interface{}) error {
+	return &FieldError{Path: path, Reason: fmt.Sprintf(format, v...)}
+}
+
+// Error implements error.
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%v: %s", ErrInvalidConfig, e.Reason)
+	}
+
+	return fmt.Sprintf("%v: %s: %s", ErrInvalidConfig, e.Path, e.Reason)
+}
+
+// Unwrap returns ErrInvalidConfig.
+func (e *FieldError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// Decode decodes the JSON config in raw to v. Unknown fields are refused.
+// Errors are returned as FieldErrors.
+func Decode(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return Invalid("", "unexpected data after the config")
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var (
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+
+	const unknownField = "json: unknown field "
+
+	switch {
+	case errors.As(err, &typeErr):
+		return Invalid(fieldPath(typeErr.Field), "expected %s, got JSON %s", typeErr.Type, typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return Invalid("", "malformed JSON at offset %d: %v", syntaxErr.Offset, err)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Invalid("", "unexpected end of JSON")
+	case strings.HasPrefix(err.Error(), unknownField):
+		return Invalid(strings.Trim(strings.TrimPrefix(err.Error(), unknownField), `"`), "unknown field")
+	default:
+		return Invalid("", "%v", err)
+	}
+}
+
+// fieldPath formats the field path of encoding/json, such as "apps.1.port", as "apps[1].port".
+func fieldPath(field string) string {
+	var b strings.Builder
+
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+
+		if i > 0 {
+			b.WriteByte('.')
+		}
+
+		b.WriteString(part)
+	}
+
+	return b.String()
+}
+
+// Migration migrates the fields of a config at version From to version To.
+type Migration struct {
+	From    string
+	To      string
+	Migrate func(fields map[string]interface{}) error
+}
+
+// Migrate applies the migrations to the JSON config in raw, starting with the one from its version,
+// until the config is at the current version. The version is kept in the field versionKey.
+// It returns the migrated config and the version it was migrated from.
+// Configs at the current version are returned unchanged.
+func Migrate(raw []byte, versionKey, current string, migrations []Migration) ([]byte, string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var fields map[string]interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, "", decodeError(err)
+	}
+
+	if fields == nil {
+		return nil, "", Invalid("", "config is not a JSON object")
+	}
+
+	var from string
+	if v, ok := fields[versionKey]; ok && v != nil {
+		if from, ok = v.(string); !ok {
+			return nil, "", Invalid(versionKey, "expected string, got %T", v)
+		}
+	}
+
+	if from == current {
+		return raw, from, nil
+	}
+
+	for version, n := from, 0; version != current; n++ {
+		i := findMigration(migrations, version)
+		if i < 0 || n == len(migrations) {
+			return nil, from, Invalid(versionKey, "unsupported version %q (latest is %q)", from, current)
+		}
+
+		if err := migrations[i].Migrate(fields); err != nil {
+			return nil, from, fmt.Errorf("failed to migrate config from version %q to %q: %w",
+				version, migrations[i].To, err)
+		}
+
+		version = migrations[i].To
+		fields[versionKey] = version
+	}
+
+	out, err := json.MarshalIndent(fields, "", "\t")
+	if err != nil {
+		return nil, from, err
+	}
+
+	return out, from, nil
+}
+
+func findMigration(migrations []Migration, from string) int {
+	for i, m := range migrations {
+		if m.From == from {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Rename moves the value of field oldKey in fields to newKey, unless newKey is already set.
+func Rename(fields map[string]interface{}, oldKey, newKey string) {
+	v, ok := fields[oldKey]
+	if !ok {
+		return
+	}
+
+	delete(fields, oldKey)
+
+	if _, ok := fields[newKey]; !ok {
+		fields[newKey] = v
+	}
+}
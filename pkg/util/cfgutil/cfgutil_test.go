@@ -0,0 +1,101 @@
+package cfgutil
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Items   []struct {
+		Port uint16 `json:"port"`
+	} `json:"items"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		path string
+	}{
+		{"valid", `{"name":"a","items":[{"port":1}]}`, ""},
+		{"unknown field", `{"name":"a","nmae":"b"}`, "nmae"},
+		{"wrong type", `{"items":[{"port":1},{"port":"x"}]}`, "items[1].port"},
+		{"trailing data", `{"name":"a"} {}`, ""},
+		{"malformed", `{"name":`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c testConfig
+			err := Decode([]byte(tt.raw), &c)
+
+			if tt.name == "valid" {
+				require.NoError(t, err)
+				assert.Equal(t, "a", c.Name)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidConfig))
+
+			var fErr *FieldError
+			require.True(t, errors.As(err, &fErr))
+			assert.Equal(t, tt.path, fErr.Path)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	migrations := []Migration{
+		{From: "", To: "1", Migrate: func(f map[string]interface{}) error {
+			Rename(f, "title", "name")
+			return nil
+		}},
+		{From: "1", To: "2", Migrate: func(f map[string]interface{}) error {
+			f["items"] = []interface{}{}
+			return nil
+		}},
+	}
+
+	t.Run("from unversioned", func(t *testing.T) {
+		out, from, err := Migrate([]byte(`{"title":"a","big":12345678901234567890}`), "version", "2", migrations)
+		require.NoError(t, err)
+		assert.Equal(t, "", from)
+
+		var fields map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(out, &fields))
+		assert.Equal(t, `"2"`, string(fields["version"]))
+		assert.Equal(t, `"a"`, string(fields["name"]))
+		assert.Equal(t, `12345678901234567890`, string(fields["big"]), "numbers are kept exactly")
+		assert.NotContains(t, fields, "title")
+	})
+
+	t.Run("current", func(t *testing.T) {
+		raw := []byte(`{"version":"2","name":"a"}`)
+		out, from, err := Migrate(raw, "version", "2", migrations)
+		require.NoError(t, err)
+		assert.Equal(t, "2", from)
+		assert.Equal(t, raw, out)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, _, err := Migrate([]byte(`{"version":"3"}`), "version", "2", migrations)
+		var fErr *FieldError
+		require.True(t, errors.As(err, &fErr))
+		assert.Equal(t, "version", fErr.Path)
+	})
+
+	t.Run("failed migration", func(t *testing.T) {
+		failing := []Migration{{From: "", To: "1", Migrate: func(map[string]interface{}) error {
+			return errors.New("boom")
+		}}}
+		_, _, err := Migrate([]byte(`{}`), "version", "1", failing)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,216 @@
+// Package cron implements parsing and evaluation of cron-style schedule expressions.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears bounds the search of the next activation time, so that expressions
+// which never match (such as "0 0 30 2 *") do not loop forever.
+const maxSearchYears = 5
+
+// Errors associated with cron expressions.
+var (
+	ErrBadExpression = errors.New("cron expression should have 5 fields: minute, hour, day of month, month and day of week")
+	ErrNeverMatches  = errors.New("cron expression never matches")
+)
+
+// nolint: gochecknoglobals
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// nolint: gochecknoglobals
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+type field struct {
+	name     string
+	min, max int
+	names    []string // names of values, starting from min
+}
+
+// nolint: gochecknoglobals
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dayNames}, // both 0 and 7 are Sunday
+}
+
+// Expr is a parsed cron expression.
+type Expr struct {
+	minute, hour, dom, month, dow uint64 // bit sets of matching values
+
+	// As in Vixie cron, if both day of month and day of week are restricted,
+	// a day matches if either of them does.
+	domStar, dowStar bool
+}
+
+// Parse parses a standard 5-field cron expression ("minute hour day-of-month month day-of-week").
+// Fields support '*', lists ("1,5"), ranges ("1-5"), steps ("*/15", "0-30/10") and
+// three-letter month and day names. The descriptors @yearly, @annually, @monthly, @weekly,
+// @daily, @midnight and @hourly are also accepted.
+func Parse(s string) (*Expr, error) {
+	s = strings.TrimSpace(s)
+	if d, ok := descriptors[strings.ToLower(s)]; ok {
+		s = d
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) != len(fields) {
+		return nil, ErrBadExpression
+	}
+
+	sets := make([]uint64, len(fields))
+
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fields[i].name, err)
+		}
+
+		sets[i] = set
+	}
+
+	e := &Expr{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+
+	return e, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(s, ",") {
+		rng, step := item, 1
+
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", item)
+			}
+
+			rng, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+
+			var err error
+			if lo, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseValue(bounds[1], f); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+
+			if hi < lo {
+				return 0, fmt.Errorf("bad range %q", rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q should be within [%d, %d]", s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Matches returns whether the expression matches the minute of t.
+func (e *Expr) Matches(t time.Time) bool {
+	return has(e.minute, t.Minute()) && has(e.hour, t.Hour()) && e.matchesDay(t)
+}
+
+func (e *Expr) matchesDay(t time.Time) bool {
+	if !has(e.month, int(t.Month())) {
+		return false
+	}
+
+	dom, dow := has(e.dom, t.Day()), has(e.dow, int(t.Weekday()))
+
+	if !e.domStar && !e.dowStar {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// Next returns the first minute strictly after t matched by the expression, in the location of t.
+func (e *Expr) Next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(end) {
+		if !e.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(e.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(e.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, ErrNeverMatches
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,62 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 0-6 1,15 jan-jun mon-fri", "0 3 * * 7", "@daily", "@WEEKLY", "5-59/10 * * * *"} {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "@fortnightly"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+
+	_, err := Parse("* * * *")
+	assert.Equal(t, ErrBadExpression, err)
+}
+
+func TestExpr_Next(t *testing.T) {
+	// Friday.
+	from := time.Date(2020, time.May, 15, 10, 30, 45, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2020, time.May, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, time.May, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, time.May, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 4 * * sun", time.Date(2020, time.May, 17, 4, 0, 0, 0, time.UTC)},
+		{"0 4 * * 7", time.Date(2020, time.May, 17, 4, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month or day of week, if both are restricted: the 20th, or Mondays.
+		{"0 0 20 * mon", time.Date(2020, time.May, 18, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		e, err := Parse(tc.expr)
+		require.NoError(t, err, tc.expr)
+
+		next, err := e.Next(from)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.next, next, tc.expr)
+		assert.True(t, e.Matches(next), tc.expr)
+	}
+
+	e, err := Parse("0 0 30 2 *")
+	require.NoError(t, err)
+
+	_, err = e.Next(from)
+	assert.True(t, errors.Is(err, ErrNeverMatches))
+}
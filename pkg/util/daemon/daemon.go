@@ -0,0 +1,110 @@
+// Package daemon installs and controls services of the native service manager of the platform:
+// the service control manager on Windows and launchd on macOS. It also notifies systemd of the state
+// of services it runs.
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupported is returned on platforms without a supported service manager.
+	ErrUnsupported = errors.New("services are not supported on this platform")
+
+	// ErrNotInstalled is returned when controlling a service which is not installed.
+	ErrNotInstalled = errors.New("service is not installed")
+
+	// ErrInstalled is returned when installing a service which is already installed.
+	ErrInstalled = errors.New("service is already installed")
+)
+
+// Config describes a service to install.
+type Config struct {
+	Name        string   // Name of the service, as known to the service manager.
+	DisplayName string   // Name of the service, as shown to users.
+	Description string   // One line description of the service.
+	Executable  string   // Absolute path of the executable of the service.
+	Args        []string // Arguments passed to the executable.
+	LogFile     string   // Output of the service is appended to this file, where the service manager supports it.
+}
+
+// Status is the state of a service.
+type Status string
+
+// Service states.
+const (
+	StatusNotInstalled = Status("not installed")
+	StatusStopped      = Status("stopped")
+	StatusStarting     = Status("starting")
+	StatusRunning      = Status("running")
+	StatusStopping     = Status("stopping")
+)
+
+// String implements fmt.Stringer for Status.
+func (s Status) String() string {
+	return string(s)
+}
+
+// launchdPlist returns the launchd property list of the daemon described by c.
+// The daemon is started on boot and restarted if it exits unsuccessfully. Processes it spawns are not killed
+// when it exits, so that a visor restarting itself (as on updates) is not taken down with its parent.
+func launchdPlist(c Config) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+
+	writeKey := func(key string) {
+		fmt.Fprintf(&buf, "\t<key>%s</key>\n", key)
+	}
+
+	writeString := func(indent, s string) error {
+		buf.WriteString(indent + "<string>")
+		if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+			return err
+		}
+		buf.WriteString("</string>\n")
+
+		return nil
+	}
+
+	writeKey("Label")
+	if err := writeString("\t", c.Name); err != nil {
+		return nil, err
+	}
+
+	writeKey("ProgramArguments")
+	buf.WriteString("\t<array>\n")
+	for _, arg := range append([]string{c.Executable}, c.Args...) {
+		if err := writeString("\t\t", arg); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("\t</array>\n")
+
+	writeKey("RunAtLoad")
+	buf.WriteString("\t<true/>\n")
+
+	writeKey("KeepAlive")
+	buf.WriteString("\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+
+	writeKey("AbandonProcessGroup")
+	buf.WriteString("\t<true/>\n")
+
+	if c.LogFile != "" {
+		for _, key := range []string{"StandardOutPath", "StandardErrorPath"} {
+			writeKey(key)
+			if err := writeString("\t", c.LogFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf.WriteString("</dict>\n</plist>\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const launchDaemonsDir = "/Library/LaunchDaemons"
+
+func plistPath(name string) string {
+	return filepath.Join(launchDaemonsDir, name+".plist")
+}
+
+// Install installs the service described by c as a launchd daemon, started on boot.
+func Install(c Config) error {
+	path := plistPath(c.Name)
+	if _, err := os.Stat(path); err == nil {
+		return ErrInstalled
+	}
+
+	if c.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(c.LogFile), 0755); err != nil { // nolint:gosec
+			return err
+		}
+	}
+
+	plist, err := launchdPlist(c)
+	if err != nil {
+		return err
+	}
+
+	// launchd refuses property lists which are writable by others than root.
+	return ioutil.WriteFile(path, plist, 0644) // nolint:gosec
+}
+
+// Uninstall stops the service name, and removes it from launchd.
+func Uninstall(name string) error {
+	if err := Stop(name); err != nil {
+		return err
+	}
+
+	return os.Remove(plistPath(name))
+}
+
+// Start loads the service name into launchd, which starts it.
+func Start(name string) error {
+	return launchctl(name, "load", "-w")
+}
+
+// Stop unloads the service name from launchd, which stops it. It is not started on boot until started again.
+func Stop(name string) error {
+	status, err := QueryStatus(name)
+	if err != nil || status == StatusStopped {
+		return err
+	}
+
+	return launchctl(name, "unload", "-w")
+}
+
+var launchctlPID = regexp.MustCompile(`"PID" = \d+;`)
+
+// QueryStatus returns the status of the service name.
+func QueryStatus(name string) (Status, error) {
+	if _, err := os.Stat(plistPath(name)); os.IsNotExist(err) {
+		return StatusNotInstalled, nil
+	}
+
+	out, err := exec.Command("launchctl", "list", name).Output() // nolint:gosec
+	if err != nil {
+		// launchctl fails on daemons which are not loaded.
+		return StatusStopped, nil // nolint:nilerr
+	}
+
+	if launchctlPID.Match(out) {
+		return StatusRunning, nil
+	}
+
+	// Loaded daemons without a process are about to be (re)started.
+	return StatusStarting, nil
+}
+
+func launchctl(name string, args ...string) error {
+	path := plistPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+
+	out, err := exec.Command("launchctl", append(args, path)...).CombinedOutput() // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
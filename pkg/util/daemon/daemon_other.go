@@ -0,0 +1,29 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package daemon
+
+// Install returns ErrUnsupported: services are only supported on Windows and macOS.
+func Install(_ Config) error {
+	return ErrUnsupported
+}
+
+// Uninstall returns ErrUnsupported: services are only supported on Windows and macOS.
+func Uninstall(_ string) error {
+	return ErrUnsupported
+}
+
+// Start returns ErrUnsupported: services are only supported on Windows and macOS.
+func Start(_ string) error {
+	return ErrUnsupported
+}
+
+// Stop returns ErrUnsupported: services are only supported on Windows and macOS.
+func Stop(_ string) error {
+	return ErrUnsupported
+}
+
+// QueryStatus returns ErrUnsupported: services are only supported on Windows and macOS.
+func QueryStatus(_ string) (Status, error) {
+	return "", ErrUnsupported
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaunchdPlist(t *testing.T) {
+	plist, err := launchdPlist(Config{
+		Name:       "com.skycoin.skywire-visor",
+		Executable: "/usr/local/bin/skywire-visor",
+		Args:       []string{"/Users/a&b/skywire-config.json", "--service"},
+		LogFile:    "/Library/Logs/Skywire/skywire-visor.log",
+	})
+	require.NoError(t, err)
+
+	// Values are in document order: the label, the program arguments and the log file, twice.
+	var strs []string
+
+	dec := xml.NewDecoder(strings.NewReader(string(plist)))
+
+	for inString := false; ; {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			inString = tok.Name.Local == "string"
+		case xml.CharData:
+			if inString {
+				strs = append(strs, string(tok))
+			}
+		case xml.EndElement:
+			inString = false
+		}
+	}
+
+	assert.Equal(t, []string{
+		"com.skycoin.skywire-visor",
+		"/usr/local/bin/skywire-visor",
+		"/Users/a&b/skywire-config.json",
+		"--service",
+		"/Library/Logs/Skywire/skywire-visor.log",
+		"/Library/Logs/Skywire/skywire-visor.log",
+	}, strs)
+	assert.Contains(t, string(plist), "/Users/a&amp;b/skywire-config.json")
+	assert.Contains(t, string(plist), "<key>AbandonProcessGroup</key>\n\t<true/>")
+}
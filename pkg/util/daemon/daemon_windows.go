@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Install installs the service described by c in the service control manager, started on boot.
+func Install(c Config) error {
+	mgr, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_ALL_ACCESS)
+	if err != nil {
+		return err
+	}
+
+	defer windows.CloseServiceHandle(mgr) // nolint:errcheck
+
+	cmd := make([]string, 0, len(c.Args)+1)
+	for _, arg := range append([]string{c.Executable}, c.Args...) {
+		cmd = append(cmd, windows.EscapeArg(arg))
+	}
+
+	name, err := windows.UTF16PtrFromString(c.Name)
+	if err != nil {
+		return err
+	}
+
+	displayName, err := windows.UTF16PtrFromString(c.DisplayName)
+	if err != nil {
+		return err
+	}
+
+	pathName, err := windows.UTF16PtrFromString(strings.Join(cmd, " "))
+	if err != nil {
+		return err
+	}
+
+	s, err := windows.CreateService(mgr, name, displayName, windows.SERVICE_ALL_ACCESS,
+		windows.SERVICE_WIN32_OWN_PROCESS, windows.SERVICE_AUTO_START, windows.SERVICE_ERROR_NORMAL,
+		pathName, nil, nil, nil, nil, nil)
+	if errors.Is(err, windows.ERROR_SERVICE_EXISTS) {
+		return ErrInstalled
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer windows.CloseServiceHandle(s) // nolint:errcheck
+
+	if c.Description == "" {
+		return nil
+	}
+
+	description, err := windows.UTF16PtrFromString(c.Description)
+	if err != nil {
+		return err
+	}
+
+	info := windows.SERVICE_DESCRIPTION{Description: description}
+
+	return windows.ChangeServiceConfig2(s, windows.SERVICE_CONFIG_DESCRIPTION, (*byte)(unsafe.Pointer(&info)))
+}
+
+// Uninstall stops the service name, and removes it from the service control manager.
+func Uninstall(name string) error {
+	if err := Stop(name); err != nil {
+		return err
+	}
+
+	return withService(name, windows.DELETE, windows.DeleteService)
+}
+
+// Start starts the service name.
+func Start(name string) error {
+	err := withService(name, windows.SERVICE_START, func(s windows.Handle) error {
+		return windows.StartService(s, 0, nil)
+	})
+	if errors.Is(err, windows.ERROR_SERVICE_ALREADY_RUNNING) {
+		return nil
+	}
+
+	return err
+}
+
+// Stop asks the service name to stop. The service may still be stopping once Stop returns.
+func Stop(name string) error {
+	err := withService(name, windows.SERVICE_STOP, func(s windows.Handle) error {
+		var status windows.SERVICE_STATUS
+		return windows.ControlService(s, windows.SERVICE_CONTROL_STOP, &status)
+	})
+	if errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return nil
+	}
+
+	return err
+}
+
+// QueryStatus returns the status of the service name.
+func QueryStatus(name string) (Status, error) {
+	var status windows.SERVICE_STATUS
+
+	err := withService(name, windows.SERVICE_QUERY_STATUS, func(s windows.Handle) error {
+		return windows.QueryServiceStatus(s, &status)
+	})
+	if errors.Is(err, ErrNotInstalled) {
+		return StatusNotInstalled, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	switch status.CurrentState {
+	case windows.SERVICE_STOPPED:
+		return StatusStopped, nil
+	case windows.SERVICE_START_PENDING, windows.SERVICE_CONTINUE_PENDING:
+		return StatusStarting, nil
+	case windows.SERVICE_STOP_PENDING:
+		return StatusStopping, nil
+	default:
+		return StatusRunning, nil
+	}
+}
+
+// withService calls fn with the service name, opened with access.
+func withService(name string, access uint32, fn func(s windows.Handle) error) error {
+	mgr, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return err
+	}
+
+	defer windows.CloseServiceHandle(mgr) // nolint:errcheck
+
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	s, err := windows.OpenService(mgr, namePtr, access)
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return ErrNotInstalled
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer windows.CloseServiceHandle(s) // nolint:errcheck
+
+	return fn(s)
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States sent to systemd with Notify.
+const (
+	NotifyReady    = "READY=1"    // The service finished starting up.
+	NotifyWatchdog = "WATCHDOG=1" // The service is alive, and is to keep being pinged within WatchdogInterval.
+	NotifyStopping = "STOPPING=1" // The service is shutting down.
+)
+
+// Notify sends state to systemd, as sd_notify does, if the process was started by systemd with notifications
+// enabled (as by Type=notify). It reports whether the state was sent.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close() // nolint:errcheck
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// NotifyStatus returns the state which describes the status of the service to systemd, as shown by
+// 'systemctl status'.
+func NotifyStatus(status string) string {
+	return "STATUS=" + status
+}
+
+// WatchdogInterval returns the interval within which systemd expects NotifyWatchdog pings of the process,
+// or 0 if the watchdog is disabled (as without WatchdogSec) or watches another process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(usec, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid $WATCHDOG_USEC %q", usec)
+	}
+
+	return time.Duration(n) * time.Microsecond, nil
+}
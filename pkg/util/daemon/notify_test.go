@@ -0,0 +1,90 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	socket := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, conn.Close()) }()
+
+	require.NoError(t, os.Unsetenv("NOTIFY_SOCKET"))
+
+	sent, err := Notify(NotifyReady)
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	require.NoError(t, os.Setenv("NOTIFY_SOCKET", socket))
+	defer func() { require.NoError(t, os.Unsetenv("NOTIFY_SOCKET")) }()
+
+	sent, err = Notify(NotifyReady)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, NotifyReady, string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer func() {
+		require.NoError(t, os.Unsetenv("WATCHDOG_USEC"))
+		require.NoError(t, os.Unsetenv("WATCHDOG_PID"))
+	}()
+
+	tests := []struct {
+		name     string
+		usec     string
+		pid      string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", usec: "30000000", interval: 30 * time.Second},
+		{name: "this process", usec: "30000000", pid: strconv.Itoa(os.Getpid()), interval: 30 * time.Second},
+		{name: "other process", usec: "30000000", pid: "1"},
+		{name: "invalid", usec: "30s", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, os.Setenv("WATCHDOG_USEC", tc.usec))
+			require.NoError(t, os.Setenv("WATCHDOG_PID", tc.pid))
+
+			if tc.usec == "" {
+				require.NoError(t, os.Unsetenv("WATCHDOG_USEC"))
+			}
+
+			interval, err := WatchdogInterval()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.interval, interval)
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+// Serve reports to the service manager that the process runs the service name. On platforms other than Windows,
+// service managers stop services with signals: the returned channel is never closed, and stopped does nothing.
+func Serve(_ string) (stop <-chan struct{}, stopped func(), err error) {
+	return nil, func() {}, nil
+}
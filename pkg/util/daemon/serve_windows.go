@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// stopWaitHint is how long the service control manager is told to wait for the service to stop.
+const stopWaitHint = 30 * time.Second
+
+// nolint:gochecknoglobals
+var (
+	advapi32                          = windows.NewLazySystemDLL("advapi32.dll")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+
+	serviceMainCallback = syscall.NewCallback(serviceMain)
+	ctrlHandlerCallback = syscall.NewCallback(ctrlHandler)
+
+	// current is the service run by the process, as service callbacks cannot carry Go state.
+	current *service
+)
+
+// service is a service run by the process on behalf of the service control manager.
+type service struct {
+	name     *uint16
+	stop     chan struct{} // closed once the service control manager asks the service to stop
+	stopOnce sync.Once
+	stopped  chan struct{} // closed once the process has stopped the service
+	started  chan error    // receives the result of registering the service
+
+	mu     sync.Mutex
+	handle windows.Handle
+	status windows.SERVICE_STATUS
+}
+
+// Serve reports to the service control manager that the process runs the service name. The returned channel
+// is closed once the service control manager asks the service to stop, and stopped is to be called once it has.
+// Serve fails if the process was not started by the service control manager.
+func Serve(name string) (stop <-chan struct{}, stopped func(), err error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current = &service{
+		name:    namePtr,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		started: make(chan error, 1),
+	}
+
+	dispatched := make(chan error, 1)
+
+	go func() {
+		// The dispatcher runs the callbacks of the service on the thread which calls it, until the service stops.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		table := []windows.SERVICE_TABLE_ENTRY{
+			{ServiceName: namePtr, ServiceProc: serviceMainCallback},
+			{},
+		}
+
+		dispatched <- windows.StartServiceCtrlDispatcher(&table[0])
+	}()
+
+	select {
+	case err := <-current.started:
+		if err != nil {
+			return nil, nil, err
+		}
+	case err := <-dispatched:
+		return nil, nil, err
+	}
+
+	s := current
+	var once sync.Once
+
+	stopped = func() {
+		once.Do(func() {
+			close(s.stopped)
+			<-dispatched
+		})
+	}
+
+	return s.stop, stopped, nil
+}
+
+// serviceMain is called by the service control manager to run the service.
+func serviceMain(_, _ uintptr) uintptr {
+	s := current
+
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(s.name)), ctrlHandlerCallback, 0)
+	if handle == 0 {
+		s.started <- err
+		return 0
+	}
+
+	s.mu.Lock()
+	s.handle = windows.Handle(handle)
+	s.mu.Unlock()
+
+	if err := s.setStatus(windows.SERVICE_RUNNING, windows.SERVICE_ACCEPT_STOP|windows.SERVICE_ACCEPT_SHUTDOWN); err != nil {
+		s.started <- err
+		return 0
+	}
+
+	s.started <- nil
+	<-s.stopped
+
+	_ = s.setStatus(windows.SERVICE_STOPPED, 0) // nolint:errcheck
+
+	return 0
+}
+
+// ctrlHandler is called by the service control manager with controls of the service.
+func ctrlHandler(control, _, _, _ uintptr) uintptr {
+	s := current
+
+	switch control {
+	case windows.SERVICE_CONTROL_STOP, windows.SERVICE_CONTROL_SHUTDOWN:
+		_ = s.setStatus(windows.SERVICE_STOP_PENDING, 0) // nolint:errcheck
+		s.stopOnce.Do(func() { close(s.stop) })
+	case windows.SERVICE_CONTROL_INTERROGATE:
+		s.mu.Lock()
+		_ = windows.SetServiceStatus(s.handle, &s.status) // nolint:errcheck
+		s.mu.Unlock()
+	}
+
+	return windows.NO_ERROR
+}
+
+func (s *service) setStatus(state, accepts uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = windows.SERVICE_STATUS{
+		ServiceType:      windows.SERVICE_WIN32_OWN_PROCESS,
+		CurrentState:     state,
+		ControlsAccepted: accepts,
+	}
+
+	if state == windows.SERVICE_STOP_PENDING {
+		s.status.WaitHint = uint32(stopWaitHint / time.Millisecond)
+	}
+
+	return windows.SetServiceStatus(s.handle, &s.status)
+}
@@ -21,7 +21,7 @@ const (
 	// HomeLoc represents the default home folder location for a configuration file.
 	HomeLoc = ConfigLocationType("HOME")
 
-	// LocalLoc represents the default /usr/local location for a configuration file.
+	// LocalLoc represents the default system-wide location for a configuration file, such as /usr/local.
 	LocalLoc = ConfigLocationType("LOCAL")
 )
 
@@ -81,7 +81,7 @@ func VisorDefaults() ConfigPaths {
 	}
 
 	paths[HomeLoc] = filepath.Join(HomeDir(), ".skycoin/skywire/skywire-config.json")
-	paths[LocalLoc] = "/usr/local/skycoin/skywire/skywire-config.json"
+	paths[LocalLoc] = filepath.Join(VisorSystemPaths().Dir, "skywire-config.json")
 
 	return paths
 }
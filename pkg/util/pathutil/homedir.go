@@ -25,3 +25,18 @@ func HomeDir() string {
 func VisorDir(pk string) string {
 	return filepath.Join(HomeDir(), ".skycoin", "skywire", pk)
 }
+
+// RuntimeDir returns a per-user directory for runtime files, such as unix sockets.
+// It is $XDG_RUNTIME_DIR if set, and ~/.skycoin otherwise.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(HomeDir(), ".skycoin")
+}
+
+// HypervisorAdminSocket returns the default path of the hypervisor admin socket, in the per-user runtime dir.
+func HypervisorAdminSocket() string {
+	return filepath.Join(RuntimeDir(), "hypervisor-admin.sock")
+}
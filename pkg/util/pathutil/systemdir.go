@@ -0,0 +1,49 @@
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// SystemPaths are the default paths of a system-wide installation of skywire-visor, as run by a service.
+type SystemPaths struct {
+	Dir  string // Config, local data and transport logs.
+	Apps string // App binaries.
+	Logs string // Log files of the visor.
+}
+
+// VisorSystemPaths returns the platform-appropriate paths of a system-wide installation of skywire-visor.
+// On Windows, data is kept in %ProgramData% and app binaries in %ProgramFiles%. On macOS, logs are kept in
+// /Library/Logs. Data is otherwise kept in /usr/local/skycoin/skywire and logs in /var/log/skywire.
+func VisorSystemPaths() SystemPaths {
+	if runtime.GOOS == "windows" {
+		dir := filepath.Join(envOr("ProgramData", `C:\ProgramData`), "Skywire")
+
+		return SystemPaths{
+			Dir:  dir,
+			Apps: filepath.Join(envOr("ProgramFiles", `C:\Program Files`), "Skywire", "apps"),
+			Logs: filepath.Join(dir, "logs"),
+		}
+	}
+
+	paths := SystemPaths{
+		Dir:  "/usr/local/skycoin/skywire",
+		Apps: "/usr/local/skycoin/skywire/apps",
+		Logs: "/var/log/skywire",
+	}
+
+	if runtime.GOOS == "darwin" {
+		paths.Logs = "/Library/Logs/Skywire"
+	}
+
+	return paths
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return fallback
+}
@@ -0,0 +1,226 @@
+package rpcutil
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDSep separates the request ID from the service method of traced calls.
+const requestIDSep = "#"
+
+// TracedMethod appends a request ID to the service method, to be sent along with the call by a client
+// using NewClientCodec. The request ID is stripped before the call reaches the server.
+func TracedMethod(serviceMethod, requestID string) string {
+	if requestID == "" {
+		return serviceMethod
+	}
+
+	return serviceMethod + requestIDSep + requestID
+}
+
+// tracedRequest is the request header of traced calls.
+// Gob ignores fields missing on either side, so it is interchangeable with rpc.Request:
+// servers using the default codec ignore the request ID and the accepted compression,
+// and requests of clients using the default codec have none.
+type tracedRequest struct {
+	ServiceMethod string
+	Seq           uint64
+	RequestID     string
+	Accept        string // Compression of large responses accepted by the client.
+}
+
+// clientCodec is the gob codec of net/rpc, sending the request IDs of traced calls.
+type clientCodec struct {
+	rwc         io.ReadWriteCloser
+	dec         *gob.Decoder
+	enc         *gob.Encoder
+	encBuf      *bufio.Writer
+	compression string
+	encoding    string // compression of the body of the response being read
+}
+
+// NewClientCodec returns a gob rpc.ClientCodec, which sends the request IDs of calls made with TracedMethod.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return NewCompressedClientCodec(conn, CompressionNone)
+}
+
+// NewCompressedClientCodec returns a codec as NewClientCodec does, which accepts large responses compressed
+// with compression. Servers using NewServerCodec then compress them, others send them uncompressed.
+func NewCompressedClientCodec(conn io.ReadWriteCloser, compression string) rpc.ClientCodec {
+	encBuf := bufio.NewWriter(conn)
+
+	return &clientCodec{
+		rwc:         conn,
+		dec:         gob.NewDecoder(conn),
+		enc:         gob.NewEncoder(encBuf),
+		encBuf:      encBuf,
+		compression: compression,
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	hdr := tracedRequest{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Accept: c.compression}
+
+	if i := strings.Index(hdr.ServiceMethod, requestIDSep); i >= 0 {
+		hdr.ServiceMethod, hdr.RequestID = hdr.ServiceMethod[:i], hdr.ServiceMethod[i+len(requestIDSep):]
+	}
+
+	if err := c.enc.Encode(&hdr); err != nil {
+		return err
+	}
+
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+
+	return c.encBuf.Flush()
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	var hdr encodedResponse
+	if err := c.dec.Decode(&hdr); err != nil {
+		return err
+	}
+
+	r.ServiceMethod, r.Seq, r.Error = hdr.ServiceMethod, hdr.Seq, hdr.Error
+	c.encoding = hdr.Encoding
+
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	if c.encoding == CompressionNone {
+		return c.dec.Decode(body)
+	}
+
+	var data []byte
+	if err := c.dec.Decode(&data); err != nil {
+		return err
+	}
+
+	// The body is discarded.
+	if body == nil {
+		return nil
+	}
+
+	return decompressBody(c.encoding, data, body)
+}
+
+func (c *clientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+type tracedCall struct {
+	requestID string
+	method    string
+	start     time.Time
+}
+
+// serverCodec is the gob codec of net/rpc, logging the calls which carry request IDs.
+type serverCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	log    logrus.FieldLogger
+
+	mu          sync.Mutex
+	traced      map[uint64]tracedCall
+	compression string // accepted by the client
+}
+
+// NewServerCodec returns a gob rpc.ServerCodec, which logs the calls sent with request IDs,
+// so that they can be matched with the logs of the caller. Large responses are compressed
+// if the client accepts it, as clients using NewCompressedClientCodec do.
+func NewServerCodec(conn io.ReadWriteCloser, log logrus.FieldLogger) rpc.ServerCodec {
+	encBuf := bufio.NewWriter(conn)
+
+	return &serverCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+		log:    log,
+		traced: make(map[uint64]tracedCall),
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	var hdr tracedRequest
+	if err := c.dec.Decode(&hdr); err != nil {
+		return err
+	}
+
+	r.ServiceMethod, r.Seq = hdr.ServiceMethod, hdr.Seq
+
+	c.mu.Lock()
+	if hdr.RequestID != "" {
+		c.traced[hdr.Seq] = tracedCall{requestID: hdr.RequestID, method: hdr.ServiceMethod, start: time.Now()}
+	}
+
+	if CheckCompression(hdr.Accept) == nil {
+		c.compression = hdr.Accept
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	call, ok := c.traced[r.Seq]
+	delete(c.traced, r.Seq)
+	compression := c.compression
+	c.mu.Unlock()
+
+	if ok {
+		log := c.log.
+			WithField("request_id", call.requestID).
+			WithField("_method", call.method).
+			WithField("_elapsed", time.Since(call.start).String())
+		if r.Error != "" {
+			log = log.WithField("error", r.Error)
+		}
+		log.Info("Traced request processed.")
+	}
+
+	if compression != CompressionNone && r.Error == "" {
+		data, err := compressBody(compression, body)
+		if err != nil {
+			return err
+		}
+
+		if data != nil {
+			return c.writeResponse(encodedResponse{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Encoding: compression}, data)
+		}
+	}
+
+	return c.writeResponse(encodedResponse{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: r.Error}, body)
+}
+
+func (c *serverCodec) writeResponse(hdr encodedResponse, body interface{}) error {
+	if err := c.enc.Encode(&hdr); err != nil {
+		return err
+	}
+
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	return c.rwc.Close()
+}
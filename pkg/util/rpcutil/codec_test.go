@@ -0,0 +1,154 @@
+package rpcutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct{}
+
+func (echo) Echo(in *string, out *string) error {
+	*out = *in
+	return nil
+}
+
+func newEchoServer(t *testing.T) *rpc.Server {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("test", echo{}))
+
+	return srv
+}
+
+func TestCodec_Traced(t *testing.T) {
+	var logs bytes.Buffer
+
+	log := logrus.New()
+	log.SetOutput(&logs)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	srvConn, cliConn := net.Pipe()
+	go newEchoServer(t).ServeCodec(NewServerCodec(srvConn, log))
+
+	client := rpc.NewClientWithCodec(NewClientCodec(cliConn))
+	defer func() { require.NoError(t, client.Close()) }()
+
+	var out string
+	require.NoError(t, client.Call(TracedMethod("test.Echo", "req-1"), "hello", &out))
+	assert.Equal(t, "hello", out)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.NewDecoder(&logs).Decode(&entry))
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "test.Echo", entry["_method"])
+
+	// Calls without request IDs are not logged by the codec.
+	require.NoError(t, client.Call(TracedMethod("test.Echo", ""), "world", &out))
+	assert.Equal(t, "world", out)
+	assert.Zero(t, logs.Len())
+}
+
+func TestCodec_DefaultCodecs(t *testing.T) {
+	t.Run("traced client, default server", func(t *testing.T) {
+		srvConn, cliConn := net.Pipe()
+		go newEchoServer(t).ServeConn(srvConn)
+
+		client := rpc.NewClientWithCodec(NewClientCodec(cliConn))
+		defer func() { require.NoError(t, client.Close()) }()
+
+		var out string
+		require.NoError(t, client.Call(TracedMethod("test.Echo", "req-1"), "hello", &out))
+		assert.Equal(t, "hello", out)
+	})
+
+	t.Run("default client, traced server", func(t *testing.T) {
+		srvConn, cliConn := net.Pipe()
+		go newEchoServer(t).ServeCodec(NewServerCodec(srvConn, logrus.New()))
+
+		client := rpc.NewClient(cliConn)
+		defer func() { require.NoError(t, client.Close()) }()
+
+		var out string
+		require.NoError(t, client.Call("test.Echo", "hello", &out))
+		assert.Equal(t, "hello", out)
+	})
+}
+
+type lines struct{}
+
+func (lines) Lines(n *int, out *[]string) error {
+	for i := 0; i < *n; i++ {
+		*out = append(*out, "the same line, again and again")
+	}
+
+	return nil
+}
+
+// countingConn counts the bytes read from it.
+type countingConn struct {
+	net.Conn
+	n int
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.n += n
+
+	return n, err
+}
+
+func TestCodec_Compression(t *testing.T) {
+	read := func(t *testing.T, compression string, n int) int {
+		srv := rpc.NewServer()
+		require.NoError(t, srv.RegisterName("test", lines{}))
+
+		srvConn, cliConn := net.Pipe()
+		go srv.ServeCodec(NewServerCodec(srvConn, logrus.New()))
+
+		conn := &countingConn{Conn: cliConn}
+
+		client := rpc.NewClientWithCodec(NewCompressedClientCodec(conn, compression))
+		defer func() { require.NoError(t, client.Close()) }()
+
+		var out []string
+		require.NoError(t, client.Call("test.Lines", n, &out))
+		require.Len(t, out, n)
+
+		// Errors are not compressed.
+		assert.Error(t, client.Call("test.Unknown", n, &out))
+
+		return conn.n
+	}
+
+	uncompressed := read(t, CompressionNone, 1000)
+
+	for _, compression := range []string{CompressionGzip, CompressionSnappy} {
+		t.Run(compression, func(t *testing.T) {
+			assert.Less(t, read(t, compression, 1000), uncompressed/4)
+
+			// Small responses are sent uncompressed.
+			read(t, compression, 1)
+		})
+	}
+
+	t.Run("default server", func(t *testing.T) {
+		srv := rpc.NewServer()
+		require.NoError(t, srv.RegisterName("test", lines{}))
+
+		srvConn, cliConn := net.Pipe()
+		go srv.ServeConn(srvConn)
+
+		client := rpc.NewClientWithCodec(NewCompressedClientCodec(cliConn, CompressionGzip))
+		defer func() { require.NoError(t, client.Close()) }()
+
+		var out []string
+		require.NoError(t, client.Call("test.Lines", 1000, &out))
+		assert.Len(t, out, 1000)
+	})
+}
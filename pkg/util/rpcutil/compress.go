@@ -0,0 +1,114 @@
+package rpcutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// Compression algorithms of RPC responses.
+const (
+	CompressionNone   = ""
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+)
+
+// compressMinSize is the size of gob encoded responses below which they are sent uncompressed.
+const compressMinSize = 4 << 10
+
+// ErrUnknownCompression is returned for compression algorithms which are not supported.
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// CheckCompression returns ErrUnknownCompression if the compression algorithm is not supported.
+func CheckCompression(compression string) error {
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionSnappy:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownCompression, compression)
+	}
+}
+
+// encodedResponse is the response header of calls which accepted a compression.
+// As tracedRequest, it is interchangeable with rpc.Response: clients using the default codec
+// never accept a compression, and responses of servers using the default codec are never compressed.
+type encodedResponse struct {
+	ServiceMethod string
+	Seq           uint64
+	Error         string
+	Encoding      string // Compression of the body, which is then sent as compressed gob encoded bytes.
+}
+
+// compressBody returns the gob encoding of body compressed with compression,
+// or nil if the encoding is too small to be worth compressing.
+func compressBody(compression string, body interface{}) ([]byte, error) {
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(body); err != nil {
+		return nil, err
+	}
+
+	if raw.Len() < compressMinSize {
+		return nil, nil
+	}
+
+	switch compression {
+	case CompressionGzip:
+		var out bytes.Buffer
+
+		w := gzip.NewWriter(&out)
+		if _, err := w.Write(raw.Bytes()); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return out.Bytes(), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, raw.Bytes()), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCompression, compression)
+	}
+}
+
+// decompressBody decodes the compressed gob encoding of a body into body.
+func decompressBody(compression string, data []byte, body interface{}) error {
+	var raw io.Reader
+
+	switch compression {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+
+		defer r.Close() // nolint: errcheck
+
+		raw = r
+	case CompressionSnappy:
+		decoded, err := snappy.Decode(nil, data)
+		if err != nil {
+			return err
+		}
+
+		raw = bytes.NewReader(decoded)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownCompression, compression)
+	}
+
+	if err := gob.NewDecoder(raw).Decode(body); err != nil {
+		return err
+	}
+
+	// Drain the reader, so that gzip checks the integrity of the data.
+	_, err := io.Copy(ioutil.Discard, raw)
+
+	return err
+}
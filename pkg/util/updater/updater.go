@@ -51,6 +51,10 @@ var (
 	ErrMalformedChecksumFile = errors.New("malformed checksum file")
 	// ErrAlreadyStarted is returned when updating is already started.
 	ErrAlreadyStarted = errors.New("updating already started")
+	// ErrNoBackup is returned on rollback when no binaries were backed up by a previous update.
+	ErrNoBackup = errors.New("no backed up binaries to roll back to")
+	// ErrNotReleasedApp is returned by UpdateApp for apps which are not part of Skywire releases.
+	ErrNotReleasedApp = errors.New("app is not part of Skywire releases")
 )
 
 // Updater checks if a new version of skywire is available, downloads its binary files
@@ -102,7 +106,7 @@ func (u *Updater) Update() (updated bool, err error) {
 
 	if err := u.restartCurrentProcess(); err != nil {
 		currentVisorPath := filepath.Join(currentBasePath, visorBinary)
-		oldVisorPath := filepath.Join(downloadedBinariesPath, visorBinary+oldSuffix)
+		oldVisorPath := currentVisorPath + oldSuffix
 
 		u.restore(currentVisorPath, oldVisorPath)
 
@@ -121,6 +125,76 @@ func (u *Updater) Update() (updated bool, err error) {
 	return true, nil
 }
 
+// UpdateApp replaces the binary of a single app with the one of the latest release.
+// Neither the visor nor the app is restarted, so a running app keeps running the old binary.
+// The replaced binary is backed up, as by Update.
+func (u *Updater) UpdateApp(app string) (updated bool, err error) {
+	if !isReleasedApp(app) {
+		return false, ErrNotReleasedApp
+	}
+
+	if !atomic.CompareAndSwapInt32(&u.updating, 0, 1) {
+		return false, ErrAlreadyStarted
+	}
+	defer atomic.StoreInt32(&u.updating, 0)
+
+	latestVersion, err := u.UpdateAvailable()
+	if err != nil {
+		return false, fmt.Errorf("failed to get last Skywire version: %w", err)
+	}
+
+	if latestVersion == nil {
+		return false, nil
+	}
+
+	u.log.Infof("Update found, version: %q, updating %s only", latestVersion.String(), app)
+
+	downloadedBinariesPath, err := u.download(latestVersion.String())
+	if err != nil {
+		return false, err
+	}
+
+	defer u.removeFiles(downloadedBinariesPath)
+
+	if err := u.updateBinary(downloadedBinariesPath, u.appsPath, app); err != nil {
+		return false, fmt.Errorf("failed to update %s binary: %w", app, err)
+	}
+
+	return true, nil
+}
+
+// Rollback restores the binaries that were backed up by the last update and restarts the visor.
+// NOTE: Rollback may call os.Exit.
+func (u *Updater) Rollback() (err error) {
+	if !atomic.CompareAndSwapInt32(&u.updating, 0, 1) {
+		return ErrAlreadyStarted
+	}
+	defer atomic.StoreInt32(&u.updating, 0)
+
+	currentBasePath := filepath.Dir(u.restartCtx.CmdPath())
+	if _, err := os.Stat(filepath.Join(currentBasePath, visorBinary+oldSuffix)); os.IsNotExist(err) {
+		return ErrNoBackup
+	}
+
+	u.log.Infof("Rolling back to backed up binaries")
+
+	for _, app := range apps() {
+		u.rollbackBinary(u.appsPath, app)
+	}
+
+	u.rollbackBinary(currentBasePath, cliBinary)
+	u.rollbackBinary(currentBasePath, visorBinary)
+
+	if err := u.restartCurrentProcess(); err != nil {
+		return err
+	}
+
+	// Let RPC call complete and then exit.
+	go u.exitAfterDelay(exitDelay)
+
+	return nil
+}
+
 // UpdateAvailable checks if an update is available.
 // If it is, the method returns the last available version.
 // Otherwise, it returns nil.
@@ -173,7 +247,7 @@ func (u *Updater) updateBinary(downloadedBinariesPath, basePath, binary string)
 	}
 
 	currentBinaryPath := filepath.Join(basePath, binary)
-	oldBinaryPath := downloadedBinaryPath + oldSuffix
+	oldBinaryPath := currentBinaryPath + oldSuffix // kept to be able to roll back
 
 	if _, err := os.Stat(oldBinaryPath); err == nil {
 		if err := os.Remove(oldBinaryPath); err != nil {
@@ -207,6 +281,19 @@ func (u *Updater) restore(currentBinaryPath string, toBeRemoved string) {
 	}
 }
 
+// rollbackBinary replaces the binary with its backup, if there is one.
+func (u *Updater) rollbackBinary(basePath, binary string) {
+	currentBinaryPath := filepath.Join(basePath, binary)
+	oldBinaryPath := currentBinaryPath + oldSuffix
+
+	if _, err := os.Stat(oldBinaryPath); os.IsNotExist(err) {
+		return
+	}
+
+	u.restore(currentBinaryPath, oldBinaryPath)
+	u.log.Infof("Rolled back %s binary", binary)
+}
+
 func (u *Updater) download(version string) (string, error) {
 	checksumsURL := fileURL(version, checksumsFilename)
 	u.log.Infof("Checksums file URL: %q", checksumsURL)
@@ -469,6 +556,16 @@ func extractLatestVersion(buffer string) string {
 	return versionWithRest[:idx]
 }
 
+func isReleasedApp(app string) bool {
+	for _, a := range apps() {
+		if a == app {
+			return true
+		}
+	}
+
+	return false
+}
+
 func apps() []string {
 	return []string{
 		"skychat",
@@ -191,3 +191,18 @@ func Test_binaryFilename(t *testing.T) {
 		})
 	}
 }
+
+func Test_isReleasedApp(t *testing.T) {
+	assert.True(t, isReleasedApp("skysocks"))
+	assert.True(t, isReleasedApp("skysocks-client"))
+	assert.False(t, isReleasedApp("skywire-visor"))
+	assert.False(t, isReleasedApp("custom-app"))
+}
+
+func TestUpdater_UpdateApp(t *testing.T) {
+	u := New(nil, nil, "")
+
+	updated, err := u.UpdateApp("custom-app")
+	assert.Equal(t, ErrNotReleasedApp, err)
+	assert.False(t, updated)
+}
@@ -0,0 +1,73 @@
+// Package wol implements sending of Wake-on-LAN magic packets.
+package wol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DefaultAddr is the address magic packets are sent to by default: the limited broadcast address on the discard port.
+const DefaultAddr = "255.255.255.255:9"
+
+const (
+	syncStreamLen = 6
+	macRepeats    = 16
+)
+
+// ErrBadMAC occurs when the MAC address is not a 6-byte (EUI-48) address.
+var ErrBadMAC = errors.New("wake-on-lan requires a 6-byte MAC address")
+
+// MagicPacket returns the magic packet waking the machine of the given MAC address:
+// 6 bytes of 0xFF followed by 16 repetitions of the MAC address.
+func MagicPacket(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, ErrBadMAC
+	}
+
+	var b bytes.Buffer
+	b.Write(bytes.Repeat([]byte{0xff}, syncStreamLen))
+	b.Write(bytes.Repeat(mac, macRepeats))
+
+	return b.Bytes(), nil
+}
+
+// Send sends the magic packet for mac over UDP to addr (DefaultAddr if empty).
+func Send(mac, addr string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+
+	packet, err := MagicPacket(hw)
+	if err != nil {
+		return err
+	}
+
+	if addr == "" {
+		addr = DefaultAddr
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialUDP("udp4", nil, udpAddr)
+	if err != nil {
+		return err
+	}
+
+	_, wErr := conn.Write(packet)
+
+	if err := conn.Close(); err != nil && wErr == nil {
+		wErr = err
+	}
+
+	if wErr != nil {
+		return fmt.Errorf("failed to send magic packet to %s: %w", addr, wErr)
+	}
+
+	return nil
+}
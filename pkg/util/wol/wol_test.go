@@ -0,0 +1,54 @@
+package wol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, err := net.ParseMAC("00:11:22:aa:bb:cc")
+	require.NoError(t, err)
+
+	packet, err := MagicPacket(mac)
+	require.NoError(t, err)
+	require.Len(t, packet, 102)
+
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, 6), packet[:6])
+	for i := 6; i < len(packet); i += 6 {
+		assert.Equal(t, []byte(mac), packet[i:i+6])
+	}
+
+	long, err := net.ParseMAC("00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01")
+	require.NoError(t, err)
+
+	_, err = MagicPacket(long)
+	assert.Equal(t, ErrBadMAC, err)
+}
+
+func TestSend(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, conn.Close()) }()
+
+	require.NoError(t, Send("00-11-22-AA-BB-CC", conn.LocalAddr().String()))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	mac, err := net.ParseMAC("00:11:22:aa:bb:cc")
+	require.NoError(t, err)
+
+	expected, err := MagicPacket(mac)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf[:n])
+
+	assert.Error(t, Send("not-a-mac", conn.LocalAddr().String()))
+}
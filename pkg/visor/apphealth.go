@@ -0,0 +1,129 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/app/appserver"
+)
+
+// AppHealth is the health of a running app, as reported by its health checks.
+type AppHealth string
+
+// App health states.
+const (
+	AppHealthUnknown   AppHealth = ""          // The app didn't register a health check, or isn't running.
+	AppHealthHealthy   AppHealth = "healthy"   // The last health check of the app succeeded.
+	AppHealthUnhealthy AppHealth = "unhealthy" // The last health check of the app failed, the app is degraded.
+)
+
+const (
+	defaultAppHealthInterval = 10 * time.Second
+	defaultAppHealthFailures = 3
+)
+
+func (c AppConfig) healthInterval() time.Duration {
+	if c.HealthInterval <= 0 {
+		return defaultAppHealthInterval
+	}
+
+	return time.Duration(c.HealthInterval)
+}
+
+func (c AppConfig) healthFailures() int {
+	if c.HealthFailures <= 0 {
+		return defaultAppHealthFailures
+	}
+
+	return c.HealthFailures
+}
+
+// errHealthReportOverdue is returned when an app which reports its health itself has stopped doing so.
+var errHealthReportOverdue = errors.New("health report is overdue")
+
+// checkAppHealth checks the health of an app by its health check.
+// Apps which report their health themselves are expected to do so at least once per interval.
+func checkAppHealth(client *http.Client, check appserver.HealthCheck, interval time.Duration) error {
+	if check.URL == "" {
+		if time.Since(check.Reported) > 2*interval {
+			return errHealthReportOverdue
+		}
+
+		if check.Err != "" {
+			return errors.New(check.Err)
+		}
+
+		return nil
+	}
+
+	resp, err := client.Get(check.URL)
+	if err != nil {
+		return err
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("health endpoint responded with status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// probeAppHealth periodically checks the health of the app run identified by token, until done is closed.
+// Apps which fail as many consecutive health checks as configured are stopped, to be restarted by runApp.
+func (visor *Visor) probeAppHealth(config AppConfig, token *appRunToken, done <-chan struct{}) {
+	interval := config.healthInterval()
+	client := &http.Client{Timeout: interval}
+	log := visor.logger.WithField("app_name", config.App)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		check, ok := visor.procManager.HealthCheck(config.App)
+		if !ok {
+			continue
+		}
+
+		err := checkAppHealth(client, check, interval)
+		if err == nil {
+			failures = 0
+			visor.appRuns.setHealth(config.App, token, nil, failures)
+
+			continue
+		}
+
+		failures++
+		visor.appRuns.setHealth(config.App, token, err, failures)
+
+		if failures < config.healthFailures() {
+			log.WithError(err).Warn("App failed health check.")
+			continue
+		}
+
+		log.WithError(err).Warnf("App failed %d health checks in a row, restarting it.", failures)
+
+		if !visor.appRuns.failHealth(config.App, token) {
+			return
+		}
+
+		if err := visor.procManager.Stop(config.App); err != nil {
+			log.WithError(err).Warn("Failed to stop unhealthy app.")
+		}
+
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package visor
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/internal/testhelpers"
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+	"github.com/skycoin/skywire/pkg/app/appserver"
+)
+
+func TestCheckAppHealth(t *testing.T) {
+	healthy := true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Timeout: time.Second}
+
+	t.Run("url", func(t *testing.T) {
+		check := appserver.HealthCheck{URL: srv.URL}
+
+		healthy = true
+		assert.NoError(t, checkAppHealth(client, check, time.Second))
+
+		healthy = false
+		assert.Error(t, checkAppHealth(client, check, time.Second))
+	})
+
+	t.Run("report", func(t *testing.T) {
+		check := appserver.HealthCheck{Reported: time.Now()}
+		assert.NoError(t, checkAppHealth(client, check, time.Second))
+
+		check.Err = "database is down"
+		assert.EqualError(t, checkAppHealth(client, check, time.Second), check.Err)
+
+		check = appserver.HealthCheck{Reported: time.Now().Add(-time.Minute)}
+		assert.Equal(t, errHealthReportOverdue, checkAppHealth(client, check, time.Second))
+	})
+}
+
+func TestVisorProbeAppHealth(t *testing.T) {
+	localPath, err := ioutil.TempDir("", "visor-app-health")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(localPath))
+	}()
+
+	app := AppConfig{
+		App:            "unhealthy",
+		Port:           10,
+		RestartBackoff: Duration(time.Millisecond),
+		HealthInterval: Duration(time.Millisecond),
+		HealthFailures: 2,
+	}
+
+	stopped := make(chan struct{})
+
+	pm := &appserver.MockProcManager{}
+	pm.On("Start", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(appcommon.ProcID(10), testhelpers.NoErr)
+	pm.On("Wait", app.App).Return(errors.New("interrupted")).Run(func(mock.Arguments) { <-stopped }).Once()
+	pm.On("Wait", app.App).Return(testhelpers.NoErr).Once()
+	// Only the first run of the app is unhealthy.
+	pm.On("HealthCheck", app.App).Return(appserver.HealthCheck{Err: "database is down"}, func(string) bool {
+		select {
+		case <-stopped:
+			return false
+		default:
+			return true
+		}
+	})
+	pm.On("Stop", app.App).Return(testhelpers.NoErr).Run(func(mock.Arguments) { close(stopped) }).Once()
+	pm.On("Exists", app.App).Return(false)
+
+	visor := &Visor{
+		appsConf:    map[string]AppConfig{app.App: app},
+		logger:      logging.MustGetLogger("test"),
+		localPath:   localPath,
+		procManager: pm,
+		conf: &Config{
+			KeyPair:       NewKeyPair(),
+			AppServerAddr: appcommon.DefaultServerAddr,
+		},
+	}
+
+	require.NoError(t, os.MkdirAll(visor.dir(), 0700))
+
+	// The app is restarted although its restart policy is "never".
+	require.NoError(t, visor.runApp(app, nil))
+	pm.AssertNumberOfCalls(t, "Start", 2)
+	pm.AssertNumberOfCalls(t, "Stop", 1)
+
+	state := visor.appState(app)
+	assert.Equal(t, 1, state.Crashes)
+	assert.Equal(t, 1, state.Restarts)
+	assert.Equal(t, AppHealthUnknown, state.Health)
+}
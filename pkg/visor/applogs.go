@@ -0,0 +1,78 @@
+package visor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/app"
+)
+
+// appLogsRotateInterval is how often the log stores of apps are checked for rotation.
+const appLogsRotateInterval = time.Minute
+
+// appLogRotation returns the rotation of the log stores of apps, as set in the config.
+func (c *Config) appLogRotation() app.LogRotation {
+	conf := c.AppLogs
+	if conf == nil {
+		conf = DefaultAppLogsConfig()
+	}
+
+	return app.LogRotation{
+		MaxSize:     conf.MaxSize,
+		MaxAge:      time.Duration(conf.MaxAge),
+		MaxArchives: conf.MaxArchives,
+	}
+}
+
+// serveAppLogRotation periodically rotates the log stores of apps, until ctx is done.
+func (visor *Visor) serveAppLogRotation(ctx context.Context) {
+	t := time.NewTicker(appLogsRotateInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			visor.rotateAppLogs()
+		}
+	}
+}
+
+// rotateAppLogs rotates the log stores of apps which exceed the rotation limits.
+// Stores are only rotated if they exist, apps which never ran don't get one.
+func (visor *Visor) rotateAppLogs() {
+	r := visor.conf.appLogRotation()
+
+	apps := visor.appConfs()
+	names := make([]string, 0, len(apps))
+	for _, ac := range apps {
+		names = append(names, ac.App)
+	}
+
+	for _, name := range names {
+		log := visor.logger.WithField("app_name", name)
+
+		if _, err := os.Stat(filepath.Join(visor.dir(), name)); err != nil {
+			continue
+		}
+
+		ls, err := visor.appLogStore(name)
+		if err != nil {
+			log.WithError(err).Warn("Failed to open app log store.")
+			continue
+		}
+
+		rotated, err := ls.Rotate(r)
+		if err != nil {
+			log.WithError(err).Warn("Failed to rotate app logs.")
+			continue
+		}
+
+		if rotated {
+			log.Info("Rotated app logs.")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package visor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+func TestConfig_appLogRotation(t *testing.T) {
+	assert.Equal(t, app.LogRotation{MaxSize: 8 << 20, MaxAge: 7 * 24 * time.Hour, MaxArchives: 2}, (&Config{}).appLogRotation())
+
+	c := &Config{AppLogs: &AppLogsConfig{MaxAge: Duration(time.Hour)}}
+	assert.Equal(t, app.LogRotation{MaxAge: time.Hour}, c.appLogRotation())
+}
+
+func TestVisorRotateAppLogs(t *testing.T) {
+	visor := &Visor{
+		appsConf: map[string]AppConfig{"foo": {App: "foo"}, "bar": {App: "bar"}},
+		logger:   logging.MustGetLogger("test"),
+		conf: &Config{
+			KeyPair: NewKeyPair(),
+			AppLogs: &AppLogsConfig{MaxSize: 1, MaxArchives: 1},
+		},
+	}
+
+	require.NoError(t, pathutil.EnsureDir(visor.dir()))
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(visor.dir()))
+	}()
+
+	ls, err := visor.appLogStore("foo")
+	require.NoError(t, err)
+	require.NoError(t, ls.Store(time.Now(), "hello"))
+
+	visor.rotateAppLogs()
+
+	logs, err := ls.LogsAfter(0)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+
+	_, err = os.Stat(filepath.Join(visor.dir(), "foo.1.gz"))
+	assert.NoError(t, err)
+
+	// Apps which never ran don't get a log store.
+	_, err = os.Stat(filepath.Join(visor.dir(), "bar"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,341 @@
+package visor
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// App restart policies.
+const (
+	AppRestartNever     = "never"      // Apps are not restarted (default).
+	AppRestartOnFailure = "on-failure" // Apps are restarted when they exit with an error.
+	AppRestartAlways    = "always"     // Apps are restarted whenever they exit, unless they are stopped.
+)
+
+const (
+	defaultAppRestartBackoff = time.Second
+	maxAppRestartBackoff     = time.Minute
+)
+
+// AppExit describes how an app exited.
+type AppExit struct {
+	Time  time.Time `json:"time"`
+	Code  int       `json:"code"`            // Exit code, or -1 if the app was killed by a signal or failed to run.
+	Error string    `json:"error,omitempty"` // Error the app exited with, if any.
+}
+
+func newAppExit(err error) *AppExit {
+	exit := &AppExit{Time: time.Now()}
+
+	if err == nil {
+		return exit
+	}
+
+	exit.Code = -1
+	exit.Error = err.Error()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exit.Code = exitErr.ExitCode()
+	}
+
+	return exit
+}
+
+// restarts returns whether the app should be restarted after exiting, by its restart policy.
+func (c AppConfig) restarts(crashed bool) bool {
+	switch c.RestartPolicy {
+	case AppRestartAlways:
+		return true
+	case AppRestartOnFailure:
+		return crashed
+	default:
+		return false
+	}
+}
+
+// runApp runs the app, restarting it by its restart policy until it is stopped or the visor is closed.
+// Apps which fail their health checks are restarted regardless of their restart policy.
+// Restarts are delayed by an exponential backoff, which is reset once the app has run for a while.
+// If max_restarts is set, the app is given up on after as many consecutive restarts.
+func (visor *Visor) runApp(config AppConfig, startCh chan<- struct{}) error {
+	backoff := time.Duration(config.RestartBackoff)
+	if backoff <= 0 {
+		backoff = defaultAppRestartBackoff
+	}
+
+	initBackoff := backoff
+	restarts := 0
+
+	for {
+		run := visor.appRuns.starting(config.App)
+		startedAt := time.Now()
+
+		probeDone := make(chan struct{})
+		go visor.probeAppHealth(config, run, probeDone)
+
+		err := visor.SpawnApp(&config, startCh)
+		startCh = nil
+
+		close(probeDone)
+
+		crashed, stopped := visor.appRuns.exited(config.App, run, err)
+		if crashed {
+			visor.appCrashed(config.App, err, visor.appRuns.unhealthy(run))
+		}
+
+		if stopped || !(config.restarts(crashed) || visor.appRuns.unhealthy(run)) {
+			return err
+		}
+
+		log := visor.logger.WithError(err).WithField("app_name", config.App)
+
+		if time.Since(startedAt) >= maxAppRestartBackoff {
+			backoff, restarts = initBackoff, 0
+		}
+
+		if config.MaxRestarts > 0 && restarts >= config.MaxRestarts {
+			log.Warnf("App was restarted %d times in a row, not restarting it again.", restarts)
+			return err
+		}
+
+		log.Warnf("App exited, restarting it in %s.", backoff)
+
+		if !visor.appRuns.wait(config.App, backoff) || visor.procManager.Exists(config.App) {
+			return err
+		}
+
+		// The config may have changed in the meantime.
+		newConfig, ok := visor.appConf(config.App)
+		if !ok {
+			return err
+		}
+
+		config = newConfig
+
+		restarts++
+		visor.appRuns.restarted(config.App)
+
+		if backoff *= 2; backoff > maxAppRestartBackoff {
+			backoff = maxAppRestartBackoff
+		}
+	}
+}
+
+// appRunToken identifies a single run of an app.
+type appRunToken struct {
+	stopped   bool // whether the app was stopped deliberately
+	unhealthy bool // whether the app was stopped for failing its health checks
+}
+
+type appRun struct {
+	current  *appRunToken
+	pending  chan struct{} // closed to cancel a pending restart, nil if none is pending
+	crashes  int
+	restarts int
+	lastExit *AppExit
+
+	health         AppHealth
+	healthErr      string
+	healthFailures int
+}
+
+// appRuns tracks runs of apps, so that deliberately stopped apps are told apart from crashed ones.
+type appRuns struct {
+	mu     sync.Mutex
+	closed bool
+	runs   map[string]*appRun
+}
+
+func (ar *appRuns) get(name string) *appRun {
+	if ar.runs == nil {
+		ar.runs = make(map[string]*appRun)
+	}
+
+	run, ok := ar.runs[name]
+	if !ok {
+		run = new(appRun)
+		ar.runs[name] = run
+	}
+
+	return run
+}
+
+func (ar *appRuns) starting(name string) *appRunToken {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	token := new(appRunToken)
+
+	run := ar.get(name)
+	run.current = token
+	run.health, run.healthErr, run.healthFailures = AppHealthUnknown, "", 0
+
+	return token
+}
+
+// exited records the exit of the app run identified by token.
+// It returns whether the app crashed, and whether it was stopped deliberately or by closing the visor.
+func (ar *appRuns) exited(name string, token *appRunToken, err error) (crashed, stopped bool) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	run := ar.get(name)
+	run.lastExit = newAppExit(err)
+	run.health = AppHealthUnknown
+
+	stopped = token.stopped || ar.closed
+	crashed = (err != nil || token.unhealthy) && !stopped
+
+	if crashed {
+		run.crashes++
+	}
+
+	return crashed, stopped
+}
+
+// stop marks the current run of the app as stopped deliberately, and cancels a pending restart.
+// It returns whether a restart was pending.
+func (ar *appRuns) stop(name string) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	run, ok := ar.runs[name]
+	if !ok {
+		return false
+	}
+
+	if run.current != nil {
+		run.current.stopped = true
+	}
+
+	if run.pending == nil {
+		return false
+	}
+
+	close(run.pending)
+	run.pending = nil
+
+	return true
+}
+
+// wait waits for the backoff before restarting the app. It returns false if the restart was cancelled.
+func (ar *appRuns) wait(name string, backoff time.Duration) bool {
+	ar.mu.Lock()
+
+	if ar.closed {
+		ar.mu.Unlock()
+		return false
+	}
+
+	run := ar.get(name)
+	pending := make(chan struct{})
+	run.pending = pending
+
+	ar.mu.Unlock()
+
+	t := time.NewTimer(backoff)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-pending:
+		return false
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if run.pending != pending {
+		return false
+	}
+
+	run.pending = nil
+
+	return true
+}
+
+// setHealth records the result of a health check of the app run identified by token.
+func (ar *appRuns) setHealth(name string, token *appRunToken, err error, failures int) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	run := ar.get(name)
+	if run.current != token {
+		return
+	}
+
+	run.health, run.healthErr, run.healthFailures = AppHealthHealthy, "", failures
+
+	if err != nil {
+		run.health, run.healthErr = AppHealthUnhealthy, err.Error()
+	}
+}
+
+// failHealth marks the app run identified by token as stopped for failing its health checks.
+// It returns false if the run was already stopped deliberately.
+func (ar *appRuns) failHealth(name string, token *appRunToken) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if token.stopped || ar.closed || ar.get(name).current != token {
+		return false
+	}
+
+	token.unhealthy = true
+
+	return true
+}
+
+// unhealthy returns whether the app run identified by token was stopped for failing its health checks.
+func (ar *appRuns) unhealthy(token *appRunToken) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return token.unhealthy
+}
+
+func (ar *appRuns) restarted(name string) {
+	ar.mu.Lock()
+	ar.get(name).restarts++
+	ar.mu.Unlock()
+}
+
+// close cancels pending restarts, and prevents apps from being restarted.
+func (ar *appRuns) close() {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.closed = true
+
+	for _, run := range ar.runs {
+		if run.pending != nil {
+			close(run.pending)
+			run.pending = nil
+		}
+	}
+}
+
+// fill fills the crash and restart counts, the health and the last exit of the app in state.
+func (ar *appRuns) fill(state *AppState) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	run, ok := ar.runs[state.Name]
+	if !ok {
+		return
+	}
+
+	state.Crashes = run.crashes
+	state.Restarts = run.restarts
+	state.LastExit = run.lastExit
+	state.RestartPending = run.pending != nil
+
+	if state.Status == AppStatusRunning {
+		state.Health = run.health
+		state.HealthError = run.healthErr
+		state.HealthFailures = run.healthFailures
+	}
+}
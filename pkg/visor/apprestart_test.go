@@ -0,0 +1,240 @@
+package visor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/internal/testhelpers"
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+	"github.com/skycoin/skywire/pkg/app/appserver"
+)
+
+func TestAppConfig_restarts(t *testing.T) {
+	tests := []struct {
+		policy  string
+		crashed bool
+		want    bool
+	}{
+		{policy: "", crashed: true, want: false},
+		{policy: AppRestartNever, crashed: true, want: false},
+		{policy: AppRestartOnFailure, crashed: false, want: false},
+		{policy: AppRestartOnFailure, crashed: true, want: true},
+		{policy: AppRestartAlways, crashed: false, want: true},
+		{policy: AppRestartAlways, crashed: true, want: true},
+	}
+
+	for _, tc := range tests {
+		c := AppConfig{RestartPolicy: tc.policy}
+		assert.Equal(t, tc.want, c.restarts(tc.crashed), "policy %q, crashed %v", tc.policy, tc.crashed)
+	}
+}
+
+func TestNewAppExit(t *testing.T) {
+	exit := newAppExit(nil)
+	assert.Equal(t, 0, exit.Code)
+	assert.Empty(t, exit.Error)
+
+	exit = newAppExit(errors.New("failed"))
+	assert.Equal(t, -1, exit.Code)
+	assert.Equal(t, "failed", exit.Error)
+}
+
+func TestAppRuns(t *testing.T) {
+	t.Run("crash", func(t *testing.T) {
+		var ar appRuns
+
+		crashed, stopped := ar.exited("app", ar.starting("app"), errors.New("failed"))
+		assert.True(t, crashed)
+		assert.False(t, stopped)
+
+		crashed, stopped = ar.exited("app", ar.starting("app"), nil)
+		assert.False(t, crashed)
+		assert.False(t, stopped)
+
+		state := AppState{Name: "app"}
+		ar.fill(&state)
+		assert.Equal(t, 1, state.Crashes)
+		require.NotNil(t, state.LastExit)
+		assert.Empty(t, state.LastExit.Error)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var ar appRuns
+
+		assert.False(t, ar.stop("app"))
+		assert.Empty(t, ar.runs)
+
+		token := ar.starting("app")
+		assert.False(t, ar.stop("app"))
+
+		crashed, stopped := ar.exited("app", token, errors.New("killed"))
+		assert.False(t, crashed)
+		assert.True(t, stopped)
+	})
+
+	t.Run("stop cancels restart", func(t *testing.T) {
+		var ar appRuns
+
+		ar.exited("app", ar.starting("app"), errors.New("failed"))
+
+		done := make(chan bool)
+		go func() { done <- ar.wait("app", time.Hour) }()
+
+		require.Eventually(t, func() bool {
+			state := AppState{Name: "app"}
+			ar.fill(&state)
+			return state.RestartPending
+		}, time.Second, time.Millisecond)
+
+		assert.True(t, ar.stop("app"))
+		assert.False(t, <-done)
+	})
+
+	t.Run("close cancels restart", func(t *testing.T) {
+		var ar appRuns
+
+		done := make(chan bool)
+		go func() { done <- ar.wait("app", time.Hour) }()
+
+		require.Eventually(t, func() bool {
+			ar.mu.Lock()
+			defer ar.mu.Unlock()
+			return ar.runs["app"] != nil && ar.runs["app"].pending != nil
+		}, time.Second, time.Millisecond)
+
+		ar.close()
+		assert.False(t, <-done)
+		assert.False(t, ar.wait("app", time.Millisecond))
+
+		_, stopped := ar.exited("app", ar.starting("app"), errors.New("failed"))
+		assert.True(t, stopped)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		var ar appRuns
+		assert.True(t, ar.wait("app", time.Millisecond))
+	})
+}
+
+func TestVisorRunApp(t *testing.T) {
+	localPath, err := ioutil.TempDir("", "visor-run-app")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(localPath))
+	}()
+
+	newVisor := func(app AppConfig, pm appserver.ProcManager) *Visor {
+		visor := &Visor{
+			appsConf:    map[string]AppConfig{app.App: app},
+			logger:      logging.MustGetLogger("test"),
+			localPath:   localPath,
+			procManager: pm,
+			conf: &Config{
+				KeyPair:       NewKeyPair(),
+				AppServerAddr: appcommon.DefaultServerAddr,
+			},
+		}
+
+		require.NoError(t, os.MkdirAll(visor.dir(), 0700))
+
+		return visor
+	}
+
+	errCrash := errors.New("crashed")
+
+	t.Run("on-failure", func(t *testing.T) {
+		app := AppConfig{
+			App:            "restarter",
+			Port:           10,
+			RestartPolicy:  AppRestartOnFailure,
+			RestartBackoff: Duration(time.Millisecond),
+		}
+
+		pm := &appserver.MockProcManager{}
+		pm.On("Start", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(appcommon.ProcID(10), testhelpers.NoErr)
+		pm.On("Wait", app.App).Return(errCrash).Twice()
+		pm.On("Wait", app.App).Return(testhelpers.NoErr).Once()
+		pm.On("Exists", app.App).Return(false)
+
+		visor := newVisor(app, pm)
+		defer func() { require.NoError(t, os.RemoveAll(visor.dir())) }()
+
+		require.NoError(t, visor.runApp(app, nil))
+		pm.AssertNumberOfCalls(t, "Start", 3)
+
+		state := visor.appState(app)
+		assert.Equal(t, 2, state.Crashes)
+		assert.Equal(t, 2, state.Restarts)
+		require.NotNil(t, state.LastExit)
+		assert.Equal(t, 0, state.LastExit.Code)
+	})
+
+	t.Run("max restarts", func(t *testing.T) {
+		app := AppConfig{
+			App:            "restarter",
+			Port:           10,
+			RestartPolicy:  AppRestartAlways,
+			MaxRestarts:    2,
+			RestartBackoff: Duration(time.Millisecond),
+		}
+
+		pm := &appserver.MockProcManager{}
+		pm.On("Start", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(appcommon.ProcID(10), testhelpers.NoErr)
+		pm.On("Wait", app.App).Return(errCrash)
+		pm.On("Exists", app.App).Return(false)
+
+		visor := newVisor(app, pm)
+		defer func() { require.NoError(t, os.RemoveAll(visor.dir())) }()
+
+		require.Equal(t, errCrash, visor.runApp(app, nil))
+		pm.AssertNumberOfCalls(t, "Start", 3)
+
+		state := visor.appState(app)
+		assert.Equal(t, 3, state.Crashes)
+		assert.Equal(t, 2, state.Restarts)
+		require.NotNil(t, state.LastExit)
+		assert.Equal(t, -1, state.LastExit.Code)
+		assert.Equal(t, errCrash.Error(), state.LastExit.Error)
+	})
+
+	t.Run("stop during backoff", func(t *testing.T) {
+		app := AppConfig{
+			App:            "restarter",
+			Port:           10,
+			RestartPolicy:  AppRestartAlways,
+			RestartBackoff: Duration(time.Hour),
+		}
+
+		pm := &appserver.MockProcManager{}
+		pm.On("Start", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(appcommon.ProcID(10), testhelpers.NoErr)
+		pm.On("Wait", app.App).Return(errCrash)
+		pm.On("Exists", app.App).Return(false)
+
+		visor := newVisor(app, pm)
+		defer func() { require.NoError(t, os.RemoveAll(visor.dir())) }()
+
+		errCh := make(chan error)
+		go func() { errCh <- visor.runApp(app, nil) }()
+
+		require.Eventually(t, func() bool {
+			return visor.appState(app).RestartPending
+		}, time.Second, time.Millisecond)
+
+		require.NoError(t, visor.StopApp(app.App))
+		assert.Equal(t, errCrash, <-errCh)
+		pm.AssertNumberOfCalls(t, "Start", 1)
+		assert.Equal(t, ErrUnknownApp, visor.StopApp(app.App))
+	})
+}
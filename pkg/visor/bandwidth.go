@@ -0,0 +1,333 @@
+package visor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+const (
+	// DefaultBandwidthTestDuration is the duration of each direction of a bandwidth test, if not specified.
+	DefaultBandwidthTestDuration = 10 * time.Second
+	// MaxBandwidthTestDuration is the maximum duration of each direction of a bandwidth test.
+	MaxBandwidthTestDuration = time.Minute
+	// BandwidthTestOverhead bounds the time of a bandwidth test spent on other things than sending data,
+	// such as setting up transports.
+	BandwidthTestOverhead = 30 * time.Second
+
+	bandwidthChunkSize     = 32 * 1024
+	bandwidthExpectTimeout = time.Minute // how long an expected bandwidth test may take to start
+)
+
+// Errors associated with bandwidth tests.
+var (
+	ErrBandwidthTestDuration    = errors.New("bandwidth test duration is out of range")
+	ErrBandwidthTestUnavailable = errors.New("bandwidth tests are not available, no transport network is set up")
+)
+
+// BandwidthResult is the result of a bandwidth test of a visor with a remote visor.
+type BandwidthResult struct {
+	TransportType      string    `json:"transport_type"`
+	TransportID        uuid.UUID `json:"transport_id"`
+	TemporaryTransport bool      `json:"temporary_transport"` // Whether the transport was created for the test.
+	Duration           Duration  `json:"duration"`            // Duration of each direction.
+	UploadBytes        int64     `json:"upload_bytes"`
+	UploadMbps         float64   `json:"upload_mbps"` // From the visor to the remote.
+	DownloadBytes      int64     `json:"download_bytes"`
+	DownloadMbps       float64   `json:"download_mbps"` // From the remote to the visor.
+}
+
+// bandwidthTests keeps the remote visors bandwidth tests are expected from.
+type bandwidthTests struct {
+	mu       sync.Mutex
+	expected map[cipher.PubKey]time.Time
+}
+
+// expect allows a single bandwidth test from remote, until it expires.
+func (bt *bandwidthTests) expect(remote cipher.PubKey) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if bt.expected == nil {
+		bt.expected = make(map[cipher.PubKey]time.Time)
+	}
+
+	bt.expected[remote] = time.Now().Add(bandwidthExpectTimeout)
+}
+
+// take reports whether a bandwidth test from remote was expected, and stops expecting it.
+func (bt *bandwidthTests) take(remote cipher.PubKey) bool {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	expires, ok := bt.expected[remote]
+	delete(bt.expected, remote)
+
+	return ok && time.Now().Before(expires)
+}
+
+// BandwidthTestTimeout returns how long a bandwidth test of duration d may take.
+func BandwidthTestTimeout(d time.Duration) time.Duration {
+	if d == 0 {
+		d = DefaultBandwidthTestDuration
+	}
+
+	return 2*d + BandwidthTestOverhead
+}
+
+// ExpectBandwidthTest allows the remote visor to run a single bandwidth test with this visor.
+func (visor *Visor) ExpectBandwidthTest(remote cipher.PubKey) {
+	visor.bwTests.expect(remote)
+}
+
+// BandwidthTest measures the throughput to and from the remote visor, which should expect the test.
+// Data is sent over the network of a transport of tpType (dmsg by default), for d in each direction.
+// A transport to the remote is created for the test if none exists.
+func (visor *Visor) BandwidthTest(ctx context.Context, remote cipher.PubKey, tpType string, d time.Duration) (*BandwidthResult, error) {
+	if d == 0 {
+		d = DefaultBandwidthTestDuration
+	}
+
+	if d < 0 || d > MaxBandwidthTestDuration {
+		return nil, ErrBandwidthTestDuration
+	}
+
+	if visor.n == nil || visor.tm == nil {
+		return nil, ErrBandwidthTestUnavailable
+	}
+
+	if tpType == "" {
+		tpType = dmsg.Type
+	}
+
+	res := &BandwidthResult{TransportType: tpType, Duration: Duration(d), TemporaryTransport: true}
+
+	visor.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		if tp.Remote() == remote && tp.Type() == tpType {
+			res.TransportID, res.TemporaryTransport = tp.Entry.ID, false
+			return false
+		}
+
+		return true
+	})
+
+	if res.TemporaryTransport {
+		tp, err := visor.tm.SaveTransport(ctx, remote, tpType)
+		if err != nil {
+			return nil, err
+		}
+
+		res.TransportID = tp.Entry.ID
+		defer visor.tm.DeleteTransport(tp.Entry.ID)
+	}
+
+	conn, err := visor.n.Dial(ctx, tpType, remote, skyenv.BandwidthTestPort)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			visor.logger.WithError(err).Debug("Failed to close bandwidth test connection.")
+		}
+	}()
+
+	up, down, err := runBandwidthTest(conn, d)
+	if err != nil {
+		return nil, err
+	}
+
+	res.UploadBytes, res.UploadMbps = up.bytes, up.mbps()
+	res.DownloadBytes, res.DownloadMbps = down.bytes, down.mbps()
+
+	return res, nil
+}
+
+// serveBandwidthTests serves bandwidth tests expected from remote visors, on each transport network.
+func (visor *Visor) serveBandwidthTests(ctx context.Context) {
+	if visor.n == nil {
+		return
+	}
+
+	log := visor.Logger.PackageLogger("bandwidth")
+
+	for _, network := range visor.n.TransportNetworks() {
+		lis, err := visor.n.Listen(network, skyenv.BandwidthTestPort)
+		if err != nil {
+			log.WithError(err).WithField("network", network).Error("Failed to listen for bandwidth tests.")
+			continue
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			if err := lis.Close(); err != nil {
+				log.WithError(err).Warn("Failed to close bandwidth test listener.")
+			}
+		}()
+
+		go func(network string) {
+			for {
+				conn, err := lis.AcceptConn()
+				if err != nil {
+					log.WithError(err).WithField("network", network).Debug("Stopped serving bandwidth tests.")
+					return
+				}
+
+				log := log.WithField("remote_pk", conn.RemotePK()).WithField("network", network)
+
+				if !visor.bwTests.take(conn.RemotePK()) {
+					log.Warn("Refused bandwidth test which was not expected.")
+
+					if err := conn.Close(); err != nil {
+						log.WithError(err).Debug("Failed to close bandwidth test connection.")
+					}
+
+					continue
+				}
+
+				go serveBandwidthConn(log, conn)
+			}
+		}(network)
+	}
+}
+
+// bandwidthSample is an amount of data transferred over a duration.
+type bandwidthSample struct {
+	bytes   int64
+	elapsed time.Duration
+}
+
+func (s bandwidthSample) mbps() float64 {
+	if s.elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.bytes) * 8 / s.elapsed.Seconds() / 1e6
+}
+
+// runBandwidthTest runs the client side of a bandwidth test: the client sends data for d, then the server does.
+// The client sends d first, and the server replies to the client's data with the amount and time it received.
+func runBandwidthTest(conn net.Conn, d time.Duration) (up, down bandwidthSample, err error) {
+	if err := conn.SetDeadline(time.Now().Add(BandwidthTestTimeout(d))); err != nil {
+		return up, down, err
+	}
+
+	if err := binary.Write(conn, binary.BigEndian, int64(d)); err != nil {
+		return up, down, err
+	}
+
+	if err := sendBandwidthData(conn, d); err != nil {
+		return up, down, err
+	}
+
+	var received [2]int64
+	if err := binary.Read(conn, binary.BigEndian, &received); err != nil {
+		return up, down, err
+	}
+
+	up = bandwidthSample{bytes: received[0], elapsed: time.Duration(received[1])}
+
+	down, err = receiveBandwidthData(conn)
+
+	return up, down, err
+}
+
+func serveBandwidthConn(log logrus.FieldLogger, conn net.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.WithError(err).Debug("Failed to close bandwidth test connection.")
+		}
+	}()
+
+	var d int64
+	if err := binary.Read(conn, binary.BigEndian, &d); err != nil {
+		log.WithError(err).Warn("Failed to read bandwidth test request.")
+		return
+	}
+
+	if d <= 0 || time.Duration(d) > MaxBandwidthTestDuration {
+		log.WithField("duration", time.Duration(d)).Warn("Refused bandwidth test of invalid duration.")
+		return
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(BandwidthTestTimeout(time.Duration(d)))); err != nil {
+		return
+	}
+
+	up, err := receiveBandwidthData(conn)
+	if err != nil {
+		log.WithError(err).Warn("Failed to receive bandwidth test data.")
+		return
+	}
+
+	if err := binary.Write(conn, binary.BigEndian, [2]int64{up.bytes, int64(up.elapsed)}); err != nil {
+		log.WithError(err).Warn("Failed to send bandwidth test results.")
+		return
+	}
+
+	if err := sendBandwidthData(conn, time.Duration(d)); err != nil {
+		log.WithError(err).Warn("Failed to send bandwidth test data.")
+		return
+	}
+
+	log.WithField("upload_mbps", up.mbps()).Info("Served bandwidth test.")
+}
+
+// sendBandwidthData sends chunks of data prefixed with their length for d, followed by an empty chunk.
+func sendBandwidthData(w io.Writer, d time.Duration) error {
+	chunk := make([]byte, 4+bandwidthChunkSize)
+	binary.BigEndian.PutUint32(chunk, bandwidthChunkSize)
+
+	for end := time.Now().Add(d); time.Now().Before(end); {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return binary.Write(w, binary.BigEndian, uint32(0))
+}
+
+// receiveBandwidthData reads the chunks sent by sendBandwidthData, timing them from the first chunk.
+func receiveBandwidthData(r io.Reader) (bandwidthSample, error) {
+	var (
+		s     bandwidthSample
+		start time.Time
+		size  uint32
+	)
+
+	for {
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return s, err
+		}
+
+		if start.IsZero() {
+			start = time.Now()
+		}
+
+		if size == 0 {
+			s.elapsed = time.Since(start)
+			return s, nil
+		}
+
+		n, err := io.CopyN(ioutil.Discard, r, int64(size))
+		s.bytes += n
+
+		if err != nil {
+			return s, err
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package visor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthTests(t *testing.T) {
+	var bt bandwidthTests
+
+	pk := cipher.PubKey{1}
+	assert.False(t, bt.take(pk))
+
+	bt.expect(pk)
+	assert.True(t, bt.take(pk))
+	assert.False(t, bt.take(pk), "expected tests are allowed once")
+
+	bt.expect(pk)
+	bt.expected[pk] = time.Now().Add(-time.Second)
+	assert.False(t, bt.take(pk), "expected tests expire")
+}
+
+func TestRunBandwidthTest(t *testing.T) {
+	conn, serverConn := net.Pipe()
+	go serveBandwidthConn(logrus.New(), serverConn)
+
+	const d = 100 * time.Millisecond
+
+	up, down, err := runBandwidthTest(conn, d)
+	require.NoError(t, err)
+
+	for _, s := range []bandwidthSample{up, down} {
+		assert.Greater(t, s.bytes, int64(0))
+		assert.Zero(t, s.bytes%bandwidthChunkSize)
+		assert.InDelta(t, d, s.elapsed, float64(d))
+		assert.Greater(t, s.mbps(), 0.0)
+	}
+}
+
+func TestBandwidthSample(t *testing.T) {
+	assert.Equal(t, 8.0, bandwidthSample{bytes: 1e6, elapsed: time.Second}.mbps())
+	assert.Zero(t, bandwidthSample{bytes: 1e6}.mbps())
+}
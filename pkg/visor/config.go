@@ -7,7 +7,12 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +27,7 @@ import (
 	"github.com/skycoin/skywire/pkg/snet"
 	"github.com/skycoin/skywire/pkg/transport"
 	trClient "github.com/skycoin/skywire/pkg/transport-discovery/client"
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
 )
 
 const (
@@ -41,8 +47,15 @@ const (
 var (
 	// ErrNoConfigPath is returned on attempt to read/write config when visor contains no config path.
 	ErrNoConfigPath = errors.New("no config path")
+
+	// ErrInvalidConfig is returned when a config fails validation.
+	ErrInvalidConfig = cfgutil.ErrInvalidConfig
 )
 
+var envKeyRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`) // nolint: gochecknoglobals
+
+var settingKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`) // nolint: gochecknoglobals
+
 // Config defines configuration parameters for Visor.
 type Config struct {
 	Path    *string `json:"-"`
@@ -54,14 +67,23 @@ type Config struct {
 	Dmsg          *snet.DmsgConfig     `json:"dmsg"`
 	DmsgPty       *DmsgPtyConfig       `json:"dmsg_pty,omitempty"`
 	STCP          *snet.STCPConfig     `json:"stcp,omitempty"`
+	SUDPH         *snet.SUDPHConfig    `json:"sudph,omitempty"`
+	KCP           *snet.KCPConfig      `json:"kcp,omitempty"`
 	Transport     *TransportConfig     `json:"transport"`
 	Routing       *RoutingConfig       `json:"routing"`
 	UptimeTracker *UptimeTrackerConfig `json:"uptime_tracker,omitempty"`
+	Exec          *ExecConfig          `json:"exec,omitempty"`
+	Files         *FilesConfig         `json:"files,omitempty"`
+	AppInstall    *AppInstallConfig    `json:"app_install,omitempty"`
+	Metrics       *MetricsConfig       `json:"metrics,omitempty"`
+	AppLogs       *AppLogsConfig       `json:"app_logs,omitempty"`
+	Power         *PowerConfig         `json:"power,omitempty"`
 
 	Apps []AppConfig `json:"apps"`
 
 	TrustedVisors []cipher.PubKey    `json:"trusted_visors"`
 	Hypervisors   []HypervisorConfig `json:"hypervisors"`
+	HypervisorPKs []cipher.PubKey    `json:"hypervisor_pks"` // Hypervisors allowed to manage the visor, defaults to those in 'hypervisors'.
 
 	AppsPath  string `json:"apps_path"`
 	LocalPath string `json:"local_path"`
@@ -96,6 +118,306 @@ func (c *Config) flush() error {
 	return ioutil.WriteFile(*c.Path, bytes, filePerm)
 }
 
+// Validate checks the config for errors that would prevent a visor from starting with it.
+// Errors are returned as *cfgutil.FieldError, which wrap ErrInvalidConfig.
+func (c *Config) Validate() error {
+	invalid := cfgutil.Invalid
+
+	if c.Dmsg != nil && c.Dmsg.Discovery == "" {
+		return invalid("dmsg.discovery", "is not set")
+	}
+
+	if c.STCP != nil && c.STCP.LocalAddr != "" {
+		if _, _, err := net.SplitHostPort(c.STCP.LocalAddr); err != nil {
+			return invalid("stcp.local_address", "%v", err)
+		}
+	}
+
+	if c.SUDPH != nil && c.SUDPH.LocalAddr != "" {
+		if _, _, err := net.SplitHostPort(c.SUDPH.LocalAddr); err != nil {
+			return invalid("sudph.local_address", "%v", err)
+		}
+	}
+
+	if c.KCP != nil {
+		if c.KCP.LocalAddr != "" {
+			if _, _, err := net.SplitHostPort(c.KCP.LocalAddr); err != nil {
+				return invalid("kcp.local_address", "%v", err)
+			}
+		}
+
+		if err := c.KCP.Session().Validate(); err != nil {
+			return invalid("kcp", "%v", err)
+		}
+	}
+
+	if c.Transport != nil {
+		if c.Transport.Discovery == "" {
+			return invalid("transport.discovery", "is not set")
+		}
+
+		if ls := c.Transport.LogStore; ls != nil && ls.Type != LogStoreFile && ls.Type != LogStoreMemory {
+			return invalid("transport.log_store.type", "unknown type %q", ls.Type)
+		}
+
+		if rc := c.Transport.Reconnect; rc != nil {
+			if err := rc.Config().Validate(); err != nil {
+				return invalid("transport.reconnect", "%v", err)
+			}
+		}
+	}
+
+	if c.Routing != nil && c.Routing.RouteFinder == "" {
+		return invalid("routing.route_finder", "is not set")
+	}
+
+	if c.Interfaces != nil && c.Interfaces.LocalAPIAddress != "" {
+		if err := checkLocalAPIAddr(c.Interfaces.LocalAPIAddress); err != nil {
+			return invalid("interfaces.local_api", "%v", err)
+		}
+	}
+
+	if c.Metrics != nil {
+		if _, _, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+			return invalid("metrics.addr", "%v", err)
+		}
+	}
+
+	if c.LogLevel != "" {
+		if _, err := logging.LevelFromString(c.LogLevel); err != nil {
+			return invalid("log_level", "%v", err)
+		}
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return invalid("shutdown_timeout", "is negative")
+	}
+
+	if c.Exec != nil {
+		for i, pattern := range c.Exec.Allow {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return invalid(fmt.Sprintf("exec.allow[%d]", i), "pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	if c.Files != nil {
+		for i, path := range c.Files.Allow {
+			if !filepath.IsAbs(path) {
+				return invalid(fmt.Sprintf("files.allow[%d]", i), "path %q is not absolute", path)
+			}
+		}
+	}
+
+	if c.AppLogs != nil {
+		switch {
+		case c.AppLogs.MaxSize < 0:
+			return invalid("app_logs.max_size", "is negative")
+		case c.AppLogs.MaxAge < 0:
+			return invalid("app_logs.max_age", "is negative")
+		case c.AppLogs.MaxArchives < 0:
+			return invalid("app_logs.max_archives", "is negative")
+		}
+	}
+
+	names := make(map[string]struct{}, len(c.Apps))
+	ports := make(map[routing.Port]string, len(c.Apps))
+
+	for i, app := range c.Apps {
+		path := fmt.Sprintf("apps[%d]", i)
+
+		if app.App == "" {
+			return invalid(path+".app", "is not set")
+		}
+
+		if _, ok := names[app.App]; ok {
+			return invalid(path+".app", "app %s is defined more than once", app.App)
+		}
+
+		if app.Binary != "" && !appNameRegexp.MatchString(app.Binary) {
+			return invalid(path+".binary", "%v", ErrBadAppName)
+		}
+
+		if name, ok := reservedPorts[app.Port]; ok && name != app.binary() {
+			return invalid(path+".port", "app %s can't bind to reserved port %d", app.App, app.Port)
+		}
+
+		if name, ok := ports[app.Port]; ok {
+			return invalid(path+".port", "apps %s and %s use the same port %d", name, app.App, app.Port)
+		}
+
+		switch app.RestartPolicy {
+		case "", AppRestartNever, AppRestartOnFailure, AppRestartAlways:
+		default:
+			return invalid(path+".restart_policy", "unknown restart policy %q", app.RestartPolicy)
+		}
+
+		if app.MaxRestarts < 0 {
+			return invalid(path+".max_restarts", "is negative")
+		}
+
+		if app.RestartBackoff < 0 {
+			return invalid(path+".restart_backoff", "is negative")
+		}
+
+		if app.HealthInterval < 0 {
+			return invalid(path+".health_interval", "is negative")
+		}
+
+		if app.HealthFailures < 0 {
+			return invalid(path+".health_failures", "is negative")
+		}
+
+		if app.Limits != nil && !(app.Limits.CPU >= 0) {
+			return invalid(path+".limits.cpu", "is not a non-negative number")
+		}
+
+		if app.Sandbox != nil && app.Sandbox.Seccomp != "" &&
+			app.Sandbox.Seccomp != appcommon.SeccompDefault && !filepath.IsAbs(app.Sandbox.Seccomp) {
+			return invalid(path+".sandbox.seccomp", "is neither %q nor an absolute path", appcommon.SeccompDefault)
+		}
+
+		for k := range app.Env {
+			if !envKeyRegexp.MatchString(k) || appcommon.IsReservedEnv(k) {
+				return invalid(path+".env", "app %s can't set env %q", app.App, k)
+			}
+		}
+
+		for k := range app.Settings {
+			if !settingKeyRegexp.MatchString(k) {
+				return invalid(path+".settings", "app %s can't have setting %q", app.App, k)
+			}
+		}
+
+		names[app.App] = struct{}{}
+		ports[app.Port] = app.App
+	}
+
+	return nil
+}
+
+// redactedJSON returns the config encoded as JSON, with the secret key omitted.
+func (c *Config) redactedJSON() ([]byte, error) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	if c.KeyPair != nil {
+		if fields["key_pair"], err = json.Marshal(struct {
+			PubKey cipher.PubKey `json:"public_key"`
+		}{c.KeyPair.PubKey}); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(fields, "", "\t")
+}
+
+// replace replaces the contents of the config with those of a config received from a hypervisor,
+// and flushes it to disk. The key pair, config path and local-only settings are kept.
+// It returns false if n has local-only settings which differ from those kept.
+func (c *Config) replace(n *Config) (bool, error) {
+	applied := c.setRemote(n)
+
+	return applied, c.flush()
+}
+
+// set copies the fields of n, except for the key pair and path, to c.
+func (c *Config) set(n *Config) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.copyFields(n)
+}
+
+// setRemote is set for configs received from hypervisors, which keeps the local-only settings of c:
+// those granting access to the host of the visor (exec, files, power, app_install, apps_path) and choosing who may
+// manage it (hypervisors, hypervisor_pks). They are only applied by reloading the config file of the visor.
+// Apps are only changed as hypervisors can change them over RPC: see remoteApps.
+// It returns false if n has local-only settings which differ from those kept.
+func (c *Config) setRemote(n *Config) bool {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	exec, files, power, appInstall := c.Exec, c.Files, c.Power, c.AppInstall
+	hvs, hvPKs, appsPath := c.Hypervisors, c.HypervisorPKs, c.AppsPath
+	apps := remoteApps(c.Apps, n.Apps)
+
+	applied := reflect.DeepEqual(exec, n.Exec) && reflect.DeepEqual(files, n.Files) &&
+		reflect.DeepEqual(power, n.Power) && reflect.DeepEqual(appInstall, n.AppInstall) &&
+		reflect.DeepEqual(hvs, n.Hypervisors) && reflect.DeepEqual(hvPKs, n.HypervisorPKs) &&
+		appsPath == n.AppsPath && (len(apps) == 0 && len(n.Apps) == 0 || reflect.DeepEqual(apps, n.Apps))
+
+	c.copyFields(n)
+	c.Exec, c.Files, c.Power, c.AppInstall = exec, files, power, appInstall
+	c.Hypervisors, c.HypervisorPKs, c.AppsPath = hvs, hvPKs, appsPath
+	c.Apps = apps
+
+	return applied
+}
+
+// remoteApps returns the local apps, with the settings of the remote apps of the same names which hypervisors
+// may change over RPC. What an app runs and with which privileges (binary, env, limits and sandbox) is local-only,
+// and apps are neither added nor removed: apps are added by installing them.
+func remoteApps(local, remote []AppConfig) []AppConfig {
+	byName := make(map[string]AppConfig, len(remote))
+	for _, app := range remote {
+		byName[app.App] = app
+	}
+
+	apps := make([]AppConfig, 0, len(local))
+
+	for _, app := range local {
+		if r, ok := byName[app.App]; ok {
+			app.AutoStart, app.Port, app.Args, app.Settings = r.AutoStart, r.Port, r.Args, r.Settings
+			app.RestartPolicy, app.MaxRestarts, app.RestartBackoff = r.RestartPolicy, r.MaxRestarts, r.RestartBackoff
+			app.HealthInterval, app.HealthFailures = r.HealthInterval, r.HealthFailures
+		}
+
+		apps = append(apps, app)
+	}
+
+	return apps
+}
+
+func (c *Config) copyFields(n *Config) {
+	c.Version = n.Version
+	c.Dmsg = n.Dmsg
+	c.DmsgPty = n.DmsgPty
+	c.STCP = n.STCP
+	c.SUDPH = n.SUDPH
+	c.KCP = n.KCP
+	c.Transport = n.Transport
+	c.Routing = n.Routing
+	c.UptimeTracker = n.UptimeTracker
+	c.Exec = n.Exec
+	c.Files = n.Files
+	c.AppInstall = n.AppInstall
+	c.Metrics = n.Metrics
+	c.AppLogs = n.AppLogs
+	c.Power = n.Power
+	c.Apps = n.Apps
+	c.TrustedVisors = n.TrustedVisors
+	c.Hypervisors = n.Hypervisors
+	c.HypervisorPKs = n.HypervisorPKs
+	c.AppsPath = n.AppsPath
+	c.LocalPath = n.LocalPath
+	c.LogLevel = n.LogLevel
+	c.ShutdownTimeout = n.ShutdownTimeout
+	c.Interfaces = n.Interfaces
+	c.AppServerAddr = n.AppServerAddr
+	c.RestartCheckDelay = n.RestartCheckDelay
+}
+
 // Keys returns visor public and secret keys extracted from config.
 // If they are not found, new keys are generated.
 func (c *Config) Keys() *KeyPair {
@@ -140,9 +462,37 @@ func (c *Config) DmsgConfig() *snet.DmsgConfig {
 	return c.Dmsg
 }
 
+// HypervisorWhitelist returns the public keys of hypervisors allowed to manage the visor.
+// Unless 'hypervisor_pks' is set, these are the keys of the configured hypervisors.
+func (c *Config) HypervisorWhitelist() []cipher.PubKey {
+	if c.HypervisorPKs != nil {
+		return c.HypervisorPKs
+	}
+
+	pks := make([]cipher.PubKey, 0, len(c.Hypervisors))
+	for _, hv := range c.Hypervisors {
+		pks = append(pks, hv.PubKey)
+	}
+
+	return pks
+}
+
+// ReadOnlyHypervisors returns the public keys of hypervisors which only have read-only access.
+func (c *Config) ReadOnlyHypervisors() []cipher.PubKey {
+	var pks []cipher.PubKey
+	for _, hv := range c.Hypervisors {
+		if hv.ReadOnly {
+			pks = append(pks, hv.PubKey)
+		}
+	}
+
+	return pks
+}
+
 // DmsgPtyHost extracts DmsgPtyConfig and returns *dmsgpty.Host based on the config.
 // If DmsgPtyConfig is not found, DefaultDmsgPtyConfig() is used.
-func (c *Config) DmsgPtyHost(dmsgC *dmsg.Client) (*dmsgpty.Host, error) {
+// Hypervisors in hypervisorWL are allowed in addition to the keys of the configured auth file.
+func (c *Config) DmsgPtyHost(dmsgC *dmsg.Client, hypervisorWL dmsgpty.Whitelist) (*dmsgpty.Host, error) {
 	if c.DmsgPty == nil {
 		c.DmsgPty = DefaultDmsgPtyConfig()
 		if err := c.flush(); err != nil && c.log != nil {
@@ -160,14 +510,6 @@ func (c *Config) DmsgPtyHost(dmsgC *dmsg.Client) (*dmsgpty.Host, error) {
 		}
 	}
 
-	// Whitelist hypervisor PKs.
-	hypervisorWL := dmsgpty.NewMemoryWhitelist()
-	for _, hv := range c.Hypervisors {
-		if err := hypervisorWL.Add(hv.PubKey); err != nil {
-			return nil, fmt.Errorf("failed to add hypervisor PK to whitelist: %v", err)
-		}
-	}
-
 	host := dmsgpty.NewHost(dmsgC, dmsgpty.NewCombinedWhitelist(0, wl, hypervisorWL))
 	return host, nil
 }
@@ -222,6 +564,9 @@ func (c *Config) RoutingConfig() *RoutingConfig {
 
 // AppsConfig decodes AppsConfig from a local json config file.
 func (c *Config) AppsConfig() (map[string]AppConfig, error) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
 	apps := make(map[string]AppConfig)
 	for _, app := range c.Apps {
 		apps[app.App] = app
@@ -230,6 +575,26 @@ func (c *Config) AppsConfig() (map[string]AppConfig, error) {
 	return apps, nil
 }
 
+// updateApps replaces the apps of the config with those returned by change, which is given a copy of them.
+// The apps are validated first. The config is not flushed.
+func (c *Config) updateApps(change func(apps []AppConfig) ([]AppConfig, error)) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	apps, err := change(append([]AppConfig(nil), c.Apps...))
+	if err != nil {
+		return err
+	}
+
+	if err := (&Config{Apps: apps}).Validate(); err != nil {
+		return err
+	}
+
+	c.Apps = apps
+
+	return nil
+}
+
 // AppsDir returns absolute path for directory with application binaries.
 // Directory will be created if necessary.
 // If it is not set in config, DefaultAppsPath is used.
@@ -327,6 +692,14 @@ func DefaultSTCPConfig() (*snet.STCPConfig, error) {
 	return c, nil
 }
 
+// DefaultSUDPHConfig returns default SUDPH config, which listens on a random port.
+func DefaultSUDPHConfig() *snet.SUDPHConfig {
+	return &snet.SUDPHConfig{
+		LocalAddr:   ":0",
+		STUNServers: []string{skyenv.DefaultSTUNServer1, skyenv.DefaultSTUNServer2},
+	}
+}
+
 // DefaultDmsgConfig returns default Dmsg config.
 func DefaultDmsgConfig() *snet.DmsgConfig {
 	return &snet.DmsgConfig{
@@ -335,6 +708,62 @@ func DefaultDmsgConfig() *snet.DmsgConfig {
 	}
 }
 
+// ExecConfig restricts commands executed remotely via RPC.Exec.
+// If ExecConfig is not found, all commands are allowed.
+type ExecConfig struct {
+	Disabled bool     `json:"disabled"`        // Disables remote execution entirely.
+	Allow    []string `json:"allow,omitempty"` // If set, only commands matching one of these patterns are allowed.
+}
+
+// Allows returns ErrExecDisabled or ErrExecNotAllowed if the command should not be executed.
+func (c *ExecConfig) Allows(command string) error {
+	_, err := c.command(command)
+	return err
+}
+
+// command returns the args to execute the command with, or ErrExecDisabled or ErrExecNotAllowed if the command
+// should not be executed. Commands are split into args on white space.
+// Patterns (as in filepath.Match) are matched against the path of the binary, the base name of binaries found in
+// PATH and the whole command. The binary is executed from the path it was matched against.
+func (c *ExecConfig) command(command string) ([]string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, ErrExecNotAllowed
+	}
+
+	if c == nil {
+		return args, nil
+	}
+
+	if c.Disabled {
+		return nil, ErrExecDisabled
+	}
+
+	if c.Allow == nil {
+		return args, nil
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExecNotAllowed, err)
+	}
+
+	names := []string{path, strings.Join(args, " ")}
+	if !strings.ContainsRune(args[0], filepath.Separator) {
+		names = append(names, filepath.Base(path))
+	}
+
+	for _, pattern := range c.Allow {
+		for _, name := range names {
+			if ok, err := filepath.Match(pattern, name); err == nil && ok {
+				return append([]string{path}, args[1:]...), nil
+			}
+		}
+	}
+
+	return nil, ErrExecNotAllowed
+}
+
 // DmsgPtyConfig configures the dmsgpty-host.
 type DmsgPtyConfig struct {
 	Port     uint16 `json:"port"`
@@ -355,8 +784,41 @@ func DefaultDmsgPtyConfig() *DmsgPtyConfig {
 
 // TransportConfig defines a transport config.
 type TransportConfig struct {
-	Discovery string          `json:"discovery"`
-	LogStore  *LogStoreConfig `json:"log_store"`
+	Discovery string            `json:"discovery"`
+	LogStore  *LogStoreConfig   `json:"log_store"`
+	Policies  []TransportPolicy `json:"policies,omitempty"` // Disable matching transports during time windows.
+	Maintain  []TransportTarget `json:"maintain,omitempty"` // Numbers of transports to keep established per type.
+	Reconnect *ReconnectConfig  `json:"reconnect,omitempty"`
+}
+
+// ReconnectConfig configures how dropped transports are re-established. Zero values are replaced with defaults.
+type ReconnectConfig struct {
+	Retries     int      `json:"retries,omitempty"`      // Attempts before giving up (default 0, for unlimited).
+	InitBackoff Duration `json:"init_backoff,omitempty"` // Delay after the first failed attempt (default 500ms).
+	MaxBackoff  Duration `json:"max_backoff,omitempty"`  // Maximum delay between attempts (default 10s).
+	Factor      float64  `json:"factor,omitempty"`       // Multiplier of the delay after each failed attempt (default 1.3).
+	Jitter      float64  `json:"jitter,omitempty"`       // Fraction of the delay randomly added or removed (default 0.2).
+}
+
+// Config returns the reconnection config of the transport manager.
+func (c *ReconnectConfig) Config() transport.ReconnectConfig {
+	if c == nil {
+		return transport.DefaultReconnectConfig()
+	}
+
+	conf := transport.ReconnectConfig{
+		Retries:     c.Retries,
+		InitBackoff: time.Duration(c.InitBackoff),
+		MaxBackoff:  time.Duration(c.MaxBackoff),
+		Factor:      c.Factor,
+		Jitter:      c.Jitter,
+	}
+
+	if conf.Jitter == 0 {
+		conf.Jitter = transport.DefaultReconnectConfig().Jitter
+	}
+
+	return conf
 }
 
 // DefaultTransportConfig returns default transport config.
@@ -407,6 +869,14 @@ func DefaultRoutingConfig() *RoutingConfig {
 	}
 }
 
+// PowerConfig allows the host of the visor to be rebooted or shut down via RPC.
+// If PowerConfig is not found, power management is disabled.
+type PowerConfig struct {
+	Enabled         bool   `json:"enabled"`
+	RebootCommand   string `json:"reboot_command,omitempty"`   // Defaults to "shutdown -r now" ("shutdown /r /t 0" on Windows).
+	ShutdownCommand string `json:"shutdown_command,omitempty"` // Defaults to "shutdown -h now" ("shutdown /s /t 0" on Windows).
+}
+
 // UptimeTrackerConfig configures uptime tracker.
 type UptimeTrackerConfig struct {
 	Addr string `json:"addr"`
@@ -419,23 +889,122 @@ func DefaultUptimeTrackerConfig() *UptimeTrackerConfig {
 	}
 }
 
+// MetricsConfig configures the listener serving metrics of the visor in the Prometheus format.
+type MetricsConfig struct {
+	Addr string `json:"addr"` // Address to serve metrics on, such as "localhost:2121".
+}
+
+// AppLogsConfig configures the rotation of the log stores of apps.
+// If AppLogsConfig is not found, DefaultAppLogsConfig() is used.
+type AppLogsConfig struct {
+	MaxSize     int64    `json:"max_size"`     // Size of the stored logs of an app in bytes above which they are rotated (0 for no limit).
+	MaxAge      Duration `json:"max_age"`      // Age of the oldest stored log of an app above which the logs are rotated (0 for no limit).
+	MaxArchives int      `json:"max_archives"` // Number of gzipped archives of rotated logs kept per app.
+}
+
+// DefaultAppLogsConfig returns the default app logs config.
+func DefaultAppLogsConfig() *AppLogsConfig {
+	return &AppLogsConfig{
+		MaxSize:     8 << 20,
+		MaxAge:      Duration(7 * 24 * time.Hour),
+		MaxArchives: 2,
+	}
+}
+
 // HypervisorConfig represents hypervisor configuration.
 type HypervisorConfig struct {
-	PubKey cipher.PubKey `json:"public_key"`
-	Addr   string        `json:"address"`
+	PubKey          cipher.PubKey `json:"public_key"`
+	Addr            string        `json:"address"`
+	Standby         bool          `json:"standby,omitempty"`          // Only sends heartbeats to the hypervisor, without serving RPC.
+	EnrollmentToken string        `json:"enrollment_token,omitempty"` // Presented to a hypervisor with an accept-list when first connecting.
+	ReadOnly        bool          `json:"read_only,omitempty"`        // Only allows the hypervisor to read state, without pty access.
 }
 
 // AppConfig defines app startup parameters.
 type AppConfig struct {
-	App       string       `json:"app"`
-	AutoStart bool         `json:"auto_start"`
-	Port      routing.Port `json:"port"`
-	Args      []string     `json:"args,omitempty"`
+	App       string            `json:"app"`
+	Binary    string            `json:"binary,omitempty"` // Binary of the app in the apps path, if it is not named as the app.
+	AutoStart bool              `json:"auto_start"`
+	Port      routing.Port      `json:"port"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`      // Additional environment of the app.
+	Settings  map[string]string `json:"settings,omitempty"` // Settings of the app, passed to it as "-key=value" flags before its args.
+
+	RestartPolicy  string   `json:"restart_policy,omitempty"`  // Either "never" (default), "on-failure" or "always".
+	MaxRestarts    int      `json:"max_restarts,omitempty"`    // Consecutive restarts after which the app is given up on (0 is unlimited).
+	RestartBackoff Duration `json:"restart_backoff,omitempty"` // Delay of the first restart, doubled on each consecutive restart (default 1s).
+
+	HealthInterval Duration `json:"health_interval,omitempty"` // Interval of health checks, if the app registers one (default 10s).
+	HealthFailures int      `json:"health_failures,omitempty"` // Consecutive failed health checks after which the app is restarted (default 3).
+
+	Limits  *appcommon.Limits  `json:"limits,omitempty"`  // Resource limits of the app process.
+	Sandbox *appcommon.Sandbox `json:"sandbox,omitempty"` // Restrictions of the privileges of the app process.
+}
+
+// binary returns the name of the binary the app runs.
+// Several instances of a binary can be run by giving each app config a distinct app name and the same binary.
+func (c AppConfig) binary() string {
+	if c.Binary == "" {
+		return c.App
+	}
+
+	return c.Binary
+}
+
+// settingArgs returns the settings of the app as sorted "-key=value" flags.
+func (c AppConfig) settingArgs() []string {
+	var args []string
+	for k, v := range c.Settings {
+		args = append(args, "-"+k+"="+v)
+	}
+
+	sort.Strings(args)
+
+	return args
+}
+
+// withoutFlag returns args without the flag of given name, in any of the forms
+// "-name value", "-name=value", "--name value" and "--name=value".
+func withoutFlag(args []string, name string) []string {
+	var out []string
+
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(args[i], "-")
+		if arg == args[i] {
+			out = append(out, args[i])
+			continue
+		}
+
+		arg = strings.TrimPrefix(arg, "-")
+
+		switch {
+		case arg == name:
+			i++ // skip the value
+		case strings.HasPrefix(arg, name+"="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+
+	return out
+}
+
+// envList returns the env of the app as sorted "KEY=value" pairs, or nil if there is none.
+func (c AppConfig) envList() []string {
+	var env []string
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+
+	sort.Strings(env)
+
+	return env
 }
 
 // InterfaceConfig defines listening interfaces for skywire visor.
 type InterfaceConfig struct {
-	RPCAddress string `json:"rpc"` // RPC address and port for command-line interface (leave blank to disable RPC interface).
+	RPCAddress      string `json:"rpc"`                 // RPC address and port for command-line interface (leave blank to disable RPC interface).
+	LocalAPIAddress string `json:"local_api,omitempty"` // Loopback address and port to serve the local HTTP/JSON API on (leave blank to disable it).
 }
 
 // DefaultInterfaceConfig returns default server interface config.
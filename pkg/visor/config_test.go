@@ -2,9 +2,12 @@ package visor
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -13,7 +16,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire/internal/httpauth"
+	"github.com/skycoin/skywire/pkg/app/appcommon"
 	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/snet"
 )
 
 func TestTransportDiscovery(t *testing.T) {
@@ -112,3 +117,187 @@ func TestLocalDir(t *testing.T) {
 	_, err = os.Stat(dir)
 	assert.NoError(t, err)
 }
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Dmsg:      DefaultDmsgConfig(),
+			Transport: DefaultTransportConfig(),
+			Routing:   DefaultRoutingConfig(),
+			LogLevel:  DefaultLogLevel,
+			Apps: []AppConfig{
+				{App: "skychat", Port: 1},
+				{App: "foo", Port: 10, Env: map[string]string{"FOO_MODE": "fast"}},
+				{App: "foo-2", Binary: "foo", Port: 11},
+			},
+		}
+	}
+
+	require.NoError(t, valid().Validate())
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"no_dmsg_discovery", func(c *Config) { c.Dmsg.Discovery = "" }},
+		{"bad_stcp_addr", func(c *Config) { c.STCP = &snet.STCPConfig{LocalAddr: "localhost"} }},
+		{"bad_sudph_addr", func(c *Config) { c.SUDPH = &snet.SUDPHConfig{LocalAddr: "localhost"} }},
+		{"bad_kcp_addr", func(c *Config) { c.KCP = &snet.KCPConfig{LocalAddr: "localhost"} }},
+		{"bad_kcp_fec", func(c *Config) { c.KCP = &snet.KCPConfig{FEC: &snet.KCPFECConfig{ParityShards: 3}} }},
+		{"bad_log_store", func(c *Config) { c.Transport.LogStore.Type = "disk" }},
+		{"bad_reconnect_jitter", func(c *Config) { c.Transport.Reconnect = &ReconnectConfig{Jitter: 2} }},
+		{"bad_log_level", func(c *Config) { c.LogLevel = "loud" }},
+		{"duplicate_app", func(c *Config) { c.Apps[1].App = "skychat" }},
+		{"duplicate_port", func(c *Config) { c.Apps[1].Port = 1 }},
+		{"reserved_port", func(c *Config) { c.Apps[1].Port = 3 }},
+		{"bad_binary", func(c *Config) { c.Apps[2].Binary = "../foo" }},
+		{"bad_env_key", func(c *Config) { c.Apps[1].Env["FOO-MODE"] = "fast" }},
+		{"reserved_env_key", func(c *Config) { c.Apps[1].Env["APP_KEY"] = "key" }},
+		{"bad_setting_key", func(c *Config) { c.Apps[1].Settings = map[string]string{"-srv": "pk"} }},
+		{"negative_app_logs_size", func(c *Config) { c.AppLogs = &AppLogsConfig{MaxSize: -1} }},
+		{"bad_exec_pattern", func(c *Config) { c.Exec = &ExecConfig{Allow: []string{"["}} }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := valid()
+			tc.modify(c)
+			assert.True(t, errors.Is(c.Validate(), ErrInvalidConfig))
+		})
+	}
+}
+
+func TestAppConfig_binary(t *testing.T) {
+	assert.Equal(t, "foo", AppConfig{App: "foo"}.binary())
+	assert.Equal(t, "foo", AppConfig{App: "foo-2", Binary: "foo"}.binary())
+}
+
+func TestAppConfig_settingArgs(t *testing.T) {
+	app := AppConfig{Settings: map[string]string{"srv": "pk", "passcode": "a b"}}
+	assert.Equal(t, []string{"-passcode=a b", "-srv=pk"}, app.settingArgs())
+	assert.Nil(t, AppConfig{}.settingArgs())
+}
+
+func TestWithoutFlag(t *testing.T) {
+	args := []string{"-srv", "a", "-addr", ":1080", "--srv=b", "-srv=c", "-srvx", "d", "srv"}
+	assert.Equal(t, []string{"-addr", ":1080", "-srvx", "d", "srv"}, withoutFlag(args, "srv"))
+	assert.Nil(t, withoutFlag([]string{"-srv", "a"}, "srv"))
+}
+
+func TestAppConfig_envList(t *testing.T) {
+	app := AppConfig{Env: map[string]string{"B": "2", "A": "1=1"}}
+	assert.Equal(t, []string{"A=1=1", "B=2"}, app.envList())
+}
+
+func TestExecConfig_Allows(t *testing.T) {
+	var unset *ExecConfig
+	assert.NoError(t, unset.Allows("rm -rf /"))
+
+	disabled := &ExecConfig{Disabled: true, Allow: []string{"*"}}
+	assert.Equal(t, ErrExecDisabled, disabled.Allows("uptime"))
+
+	dir, err := ioutil.TempDir("", "exec")
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	// A binary named as an allowed one, outside of PATH.
+	fake := filepath.Join(dir, "uptime")
+	require.NoError(t, ioutil.WriteFile(fake, []byte("#!/bin/sh\n"), 0700)) // nolint: gosec
+
+	uptime, err := exec.LookPath("uptime")
+	if err != nil {
+		t.Skip("uptime is not installed")
+	}
+
+	df, err := exec.LookPath("df")
+	if err != nil {
+		t.Skip("df is not installed")
+	}
+
+	c := &ExecConfig{Allow: []string{"uptime", df, "echo hello *"}}
+
+	tests := []struct {
+		command string
+		args    []string
+		err     error
+	}{
+		{"uptime", []string{uptime}, nil},
+		{"uptime  -p", []string{uptime, "-p"}, nil},
+		{fake, nil, ErrExecNotAllowed},
+		{df + " -h", []string{df, "-h"}, nil},
+		{"df -h", []string{df, "-h"}, nil},
+		{"echo hello world", nil, nil},
+		{"echo bye world", nil, ErrExecNotAllowed},
+		{"", nil, ErrExecNotAllowed},
+	}
+
+	for _, tc := range tests {
+		args, err := c.command(tc.command)
+		assert.Equal(t, tc.err, err, tc.command)
+
+		if tc.args != nil {
+			assert.Equal(t, tc.args, args, tc.command)
+		}
+	}
+}
+
+func TestConfig_setRemote(t *testing.T) {
+	hvPK, _ := cipher.GenerateKeyPair()
+
+	c := &Config{
+		LogLevel:      "info",
+		Exec:          &ExecConfig{Allow: []string{"uptime"}},
+		Files:         &FilesConfig{Allow: []string{"/var/log"}},
+		Hypervisors:   []HypervisorConfig{{PubKey: hvPK, ReadOnly: true}},
+		HypervisorPKs: []cipher.PubKey{hvPK},
+		AppsPath:      "./apps",
+		Apps:          []AppConfig{{App: "foo", Port: 10, Sandbox: &appcommon.Sandbox{User: "skywire"}}},
+	}
+
+	same := &Config{
+		LogLevel:      "debug",
+		Exec:          &ExecConfig{Allow: []string{"uptime"}},
+		Files:         &FilesConfig{Allow: []string{"/var/log"}},
+		Hypervisors:   []HypervisorConfig{{PubKey: hvPK, ReadOnly: true}},
+		HypervisorPKs: []cipher.PubKey{hvPK},
+		AppsPath:      "./apps",
+		Apps: []AppConfig{{App: "foo", AutoStart: true, Port: 10, Args: []string{"-v"},
+			Sandbox: &appcommon.Sandbox{User: "skywire"}}},
+	}
+	assert.True(t, c.setRemote(same))
+	assert.Equal(t, "debug", c.LogLevel)
+	assert.Equal(t, same.Apps, c.Apps)
+
+	otherPK, _ := cipher.GenerateKeyPair()
+	remote := &Config{
+		LogLevel:      "warn",
+		Exec:          &ExecConfig{Allow: []string{"*"}},
+		Files:         &FilesConfig{Allow: []string{"/"}},
+		Power:         &PowerConfig{Enabled: true, RebootCommand: "rm -rf /"},
+		AppInstall:    &AppInstallConfig{},
+		Hypervisors:   []HypervisorConfig{{PubKey: hvPK}, {PubKey: otherPK}},
+		HypervisorPKs: []cipher.PubKey{otherPK},
+		AppsPath:      "/tmp",
+		Apps: []AppConfig{
+			{App: "foo", Port: 11, Env: map[string]string{"LD_PRELOAD": "/tmp/evil.so"}},
+			{App: "bar", Binary: "foo"},
+		},
+	}
+	assert.False(t, c.setRemote(remote))
+	assert.Nil(t, c.Power)
+	assert.Nil(t, c.AppInstall)
+	assert.Equal(t, []HypervisorConfig{{PubKey: hvPK, ReadOnly: true}}, c.Hypervisors)
+	assert.Equal(t, "./apps", c.AppsPath)
+	assert.Equal(t, []AppConfig{{App: "foo", Port: 11, Sandbox: &appcommon.Sandbox{User: "skywire"}}}, c.Apps)
+
+	assert.Equal(t, "warn", c.LogLevel)
+	assert.Equal(t, []string{"uptime"}, c.Exec.Allow)
+	assert.Equal(t, []string{"/var/log"}, c.Files.Allow)
+	assert.Equal(t, []cipher.PubKey{hvPK}, c.HypervisorPKs)
+
+	// Reloading the local config applies them.
+	c.set(remote)
+	assert.Equal(t, []string{"*"}, c.Exec.Allow)
+	assert.Equal(t, []cipher.PubKey{otherPK}, c.HypervisorPKs)
+}
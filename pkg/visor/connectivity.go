@@ -0,0 +1,355 @@
+package visor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/disc"
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+// Connectivity checks.
+const (
+	CheckDmsgDiscovery      = "dmsg_discovery"
+	CheckDmsgServers        = "dmsg_servers"
+	CheckTransportDiscovery = "transport_discovery"
+	CheckRouteFinder        = "route_finder"
+	CheckProbeTransport     = "probe_transport"
+	CheckEcho               = "echo"
+)
+
+const (
+	connectivityCheckTimeout = 5 * time.Second
+	echoPayloadLen           = 32
+)
+
+var errNoProbe = errors.New("no probe visor specified")
+
+// ConnectivityCheck is the result of a single connectivity check.
+type ConnectivityCheck struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ConnectivityReport is the result of a connectivity test.
+type ConnectivityReport struct {
+	Passed bool                `json:"passed"` // Whether no check failed.
+	Checks []ConnectivityCheck `json:"checks"`
+}
+
+func (cr *ConnectivityReport) add(c ConnectivityCheck) {
+	cr.Checks = append(cr.Checks, c)
+	cr.Passed = cr.Passed && (c.Passed || c.Skipped)
+}
+
+func runCheck(ctx context.Context, name string, check func(ctx context.Context) error) ConnectivityCheck {
+	ctx, cancel := context.WithTimeout(ctx, connectivityCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+
+	c := ConnectivityCheck{Name: name, Passed: err == nil, Duration: time.Since(start)}
+
+	switch {
+	case err == errNoProbe:
+		c.Passed, c.Skipped = false, true
+	case err != nil:
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+// ConnectivityTest runs a battery of checks of the visor's connectivity to the services it depends on.
+// If a probe visor is given, a transport to it is established (and removed again if it did not exist before),
+// and data is echoed by the probe's echo server over dmsg.
+func (visor *Visor) ConnectivityTest(probe cipher.PubKey, tpType string) *ConnectivityReport {
+	ctx := context.Background()
+	report := &ConnectivityReport{Passed: true}
+
+	services := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{CheckDmsgDiscovery, visor.checkDmsgDiscovery},
+		{CheckDmsgServers, visor.checkDmsgServers},
+		{CheckTransportDiscovery, visor.checkTransportDiscovery},
+		{CheckRouteFinder, visor.checkRouteFinder},
+	}
+
+	results := make([]ConnectivityCheck, len(services))
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(services))
+
+	for i, s := range services {
+		go func(i int, name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, name, check)
+		}(i, s.name, s.check)
+	}
+
+	wg.Wait()
+
+	for _, c := range results {
+		report.add(c)
+	}
+
+	report.add(runCheck(ctx, CheckProbeTransport, func(ctx context.Context) error {
+		return visor.checkProbeTransport(ctx, probe, tpType)
+	}))
+
+	report.add(runCheck(ctx, CheckEcho, func(ctx context.Context) error {
+		return visor.checkEcho(ctx, probe)
+	}))
+
+	return report
+}
+
+func (visor *Visor) checkDmsgDiscovery(ctx context.Context) error {
+	if visor.conf.Dmsg == nil {
+		return errors.New("dmsg is not configured")
+	}
+
+	servers, err := disc.NewHTTP(visor.conf.Dmsg.Discovery).AvailableServers(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(servers) == 0 {
+		return errors.New("no dmsg servers are available")
+	}
+
+	return nil
+}
+
+func (visor *Visor) checkDmsgServers(_ context.Context) error {
+	dmsgC := visor.dmsgClient()
+	if dmsgC == nil {
+		return errors.New("dmsg is not configured")
+	}
+
+	if len(dmsgC.ConnectedServers()) == 0 {
+		return errors.New("not connected to any dmsg server")
+	}
+
+	return nil
+}
+
+func (visor *Visor) checkTransportDiscovery(ctx context.Context) error {
+	_, err := visor.tm.Conf.DiscoveryClient.GetTransportsByEdge(ctx, visor.conf.Keys().PubKey)
+	return err
+}
+
+func (visor *Visor) checkRouteFinder(ctx context.Context) error {
+	addr := visor.conf.RoutingConfig().RouteFinder
+	if addr == "" {
+		return errors.New("route finder is not configured")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr, nil)
+	if err != nil {
+		return err
+	}
+
+	// Any response means the route finder is reachable.
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (visor *Visor) checkProbeTransport(ctx context.Context, probe cipher.PubKey, tpType string) error {
+	if probe.Null() {
+		return errNoProbe
+	}
+
+	if tpType == "" {
+		tpType = dmsg.Type
+	}
+
+	existed := false
+
+	visor.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		if tp.Remote() == probe && tp.Type() == tpType {
+			existed = true
+		}
+
+		return !existed
+	})
+
+	tp, err := visor.tm.SaveTransport(ctx, probe, tpType)
+	if err != nil {
+		return err
+	}
+
+	if !existed {
+		visor.tm.DeleteTransport(tp.Entry.ID)
+	}
+
+	return nil
+}
+
+func (visor *Visor) checkEcho(ctx context.Context, probe cipher.PubKey) error {
+	if probe.Null() {
+		return errNoProbe
+	}
+
+	dmsgC := visor.dmsgClient()
+	if dmsgC == nil {
+		return errors.New("dmsg is not configured")
+	}
+
+	_, err := NewDmsgEchoClient(dmsgC, probe).Ping(ctx)
+
+	return err
+}
+
+// EchoClient measures round trips to the echo server of a visor, over streams opened with dial.
+type EchoClient struct {
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// NewEchoClient creates a new EchoClient.
+func NewEchoClient(dial func(ctx context.Context) (net.Conn, error)) *EchoClient {
+	return &EchoClient{dial: dial}
+}
+
+// NewDmsgEchoClient creates a new EchoClient of the visor of pk, reached over dmsg.
+func NewDmsgEchoClient(dmsgC *dmsg.Client, pk cipher.PubKey) *EchoClient {
+	return NewEchoClient(func(ctx context.Context) (net.Conn, error) {
+		return dmsgC.Dial(ctx, dmsg.Addr{PK: pk, Port: skyenv.DmsgEchoPort})
+	})
+}
+
+// NewMockEchoClient creates an EchoClient which is echoed in process.
+func NewMockEchoClient() *EchoClient {
+	return NewEchoClient(func(context.Context) (net.Conn, error) {
+		conn, visorConn := net.Pipe()
+		go echo(logging.MustGetLogger("echo"), visorConn)
+
+		return conn, nil
+	})
+}
+
+// Ping sends random data to the echo server, and returns the time until it was echoed back.
+// The time to open the stream is not included.
+func (ec *EchoClient) Ping(ctx context.Context) (time.Duration, error) {
+	conn, err := ec.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		_ = conn.Close() // nolint: errcheck
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+
+	payload := make([]byte, echoPayloadLen)
+	if _, err := rand.Read(payload); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+
+	if _, err := conn.Write(payload); err != nil {
+		return 0, err
+	}
+
+	echoed := make([]byte, echoPayloadLen)
+	if _, err := io.ReadFull(conn, echoed); err != nil {
+		return 0, err
+	}
+
+	rtt := time.Since(start)
+
+	if !bytes.Equal(payload, echoed) {
+		return 0, fmt.Errorf("echoed data differs from sent data")
+	}
+
+	return rtt, nil
+}
+
+// dmsgClient returns the dmsg client of the visor, or nil if dmsg is not set up.
+func (visor *Visor) dmsgClient() *dmsg.Client {
+	if visor.n == nil {
+		return nil
+	}
+
+	return visor.n.Dmsg()
+}
+
+// serveEcho serves an echo server over dmsg, so other visors may use this visor as a connectivity test probe.
+func (visor *Visor) serveEcho(ctx context.Context) {
+	dmsgC := visor.dmsgClient()
+	if dmsgC == nil {
+		return
+	}
+
+	log := visor.Logger.PackageLogger("echo")
+
+	lis, err := dmsgC.Listen(skyenv.DmsgEchoPort)
+	if err != nil {
+		log.WithError(err).Error("Failed to listen for echo connections.")
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := lis.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close echo listener.")
+		}
+	}()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.WithError(err).Debug("Stopped serving echo.")
+			return
+		}
+
+		go echo(log, conn)
+	}
+}
+
+func echo(log *logging.Logger, conn net.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.WithError(err).Debug("Failed to close echo connection.")
+		}
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(connectivityCheckTimeout)); err != nil {
+		return
+	}
+
+	if _, err := io.CopyN(conn, conn, echoPayloadLen); err != nil {
+		log.WithError(err).Debug("Echo failed.")
+	}
+}
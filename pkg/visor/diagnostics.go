@@ -0,0 +1,179 @@
+package visor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+)
+
+const redactedValue = "<redacted>"
+
+// Args whose values are secrets, redacted from diagnostics.
+var secretArgs = map[string]bool{"-passcode": true} // nolint: gochecknoglobals
+
+// DiagnosticsIn is input for Diagnostics.
+type DiagnosticsIn struct {
+	LogsSince time.Time // App logs logged since then are included.
+}
+
+// Diagnostics gathers a gzipped tar archive for troubleshooting the visor. It contains the config
+// (with secrets redacted), health, summary, transports, routing rules, route groups, goroutine dumps
+// and recent app logs. Failures to gather any of them are listed in 'errors.txt'.
+func (r *RPC) Diagnostics(in *DiagnosticsIn, out *[]byte) (err error) {
+	defer rpcutil.LogCall(r.log, "Diagnostics", in)(nil, &err)
+
+	files := make(map[string][]byte)
+	var errs []string
+
+	addJSON := func(name string, v interface{}, err error) {
+		if err == nil {
+			files[name], err = json.MarshalIndent(v, "", "\t")
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	var (
+		health    HealthInfo
+		summary   Summary
+		tps       []*TransportSummary
+		rules     []routing.Rule
+		rGroups   []RouteGroupInfo
+		noArgs    = &struct{}{}
+		allTpsArg = &TransportsIn{ShowLogs: true}
+	)
+
+	addJSON("health.json", &health, r.Health(noArgs, &health))
+	addJSON("summary.json", &summary, r.Summary(noArgs, &summary))
+	addJSON("transports.json", tps, r.Transports(allTpsArg, &tps))
+	addJSON("routing_rules.json", rules, r.RoutingRules(noArgs, &rules))
+	addJSON("route_groups.json", rGroups, r.RouteGroups(noArgs, &rGroups))
+
+	if files["config.json"], err = r.visor.diagnosticsConfig(); err != nil {
+		errs = append(errs, fmt.Sprintf("config.json: %v", err))
+	}
+
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		errs = append(errs, fmt.Sprintf("goroutines.txt: %v", err))
+	}
+
+	files["goroutines.txt"] = goroutines.Bytes()
+
+	for _, app := range r.visor.Apps() {
+		var lines []string
+		if err := r.LogsSince(&AppLogsRequest{TimeStamp: in.LogsSince, AppName: app.Name}, &lines); err != nil {
+			errs = append(errs, fmt.Sprintf("logs/%s.log: %v", app.Name, err))
+			continue
+		}
+
+		files["logs/"+app.Name+".log"] = []byte(strings.Join(lines, ""))
+	}
+
+	if len(errs) > 0 {
+		files["errors.txt"] = []byte(strings.Join(errs, "\n") + "\n")
+	}
+
+	var buf bytes.Buffer
+	if err := writeTarGz(&buf, files); err != nil {
+		return err
+	}
+
+	*out = buf.Bytes()
+
+	return nil
+}
+
+// diagnosticsConfig returns the config encoded as JSON, with the secret key, app env values and secret app args redacted.
+func (visor *Visor) diagnosticsConfig() ([]byte, error) {
+	raw, err := visor.conf.redactedJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	var apps []AppConfig
+	if err := json.Unmarshal(fields["apps"], &apps); err != nil {
+		return nil, err
+	}
+
+	for i := range apps {
+		apps[i].Args = redactArgs(apps[i].Args)
+
+		for k := range apps[i].Env {
+			apps[i].Env[k] = redactedValue
+		}
+	}
+
+	if fields["apps"], err = json.Marshal(apps); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(fields, "", "\t")
+}
+
+// redactArgs returns a copy of args, with the values of secret args redacted.
+func redactArgs(args []string) []string {
+	redacted := append([]string(nil), args...)
+
+	for i := 0; i+1 < len(redacted); i++ {
+		if secretArgs[redacted[i]] {
+			redacted[i+1] = redactedValue
+		}
+	}
+
+	return redacted
+}
+
+// writeTarGz writes files as a gzipped tar archive, in order of their names.
+func writeTarGz(w io.Writer, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
@@ -0,0 +1,35 @@
+package visor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisor_diagnosticsConfig(t *testing.T) {
+	c := &Config{
+		KeyPair: NewKeyPair(),
+		Apps: []AppConfig{
+			{App: "skysocks", Port: 3, Args: []string{"-passcode", "hunter2", "-v"}},
+			{App: "chat", Port: 1, Env: map[string]string{"TOKEN": "secret"}},
+		},
+	}
+
+	raw, err := (&Visor{conf: c}).diagnosticsConfig()
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(raw), c.KeyPair.SecKey.Hex())
+	assert.NotContains(t, string(raw), "hunter2")
+	assert.NotContains(t, string(raw), "secret")
+
+	var redacted Config
+	require.NoError(t, json.Unmarshal(raw, &redacted))
+	assert.Equal(t, []string{"-passcode", redactedValue, "-v"}, redacted.Apps[0].Args)
+	assert.Equal(t, map[string]string{"TOKEN": redactedValue}, redacted.Apps[1].Env)
+
+	// The config itself is untouched.
+	assert.Equal(t, "hunter2", c.Apps[0].Args[1])
+	assert.Equal(t, "secret", c.Apps[1].Env["TOKEN"])
+}
@@ -0,0 +1,107 @@
+package visor
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDrainTimeout is how long a draining visor waits for its routes to become idle, if not specified.
+	DefaultDrainTimeout = 10 * time.Minute
+
+	drainCheckInterval = time.Second
+)
+
+// DrainStatus is the status of a draining visor.
+type DrainStatus struct {
+	Deadline time.Time `json:"deadline"` // The visor shuts down at the latest then.
+	Routes   int       `json:"routes"`   // Routing rules which are still in use.
+}
+
+// drainState tracks draining the visor. The zero value is ready to use.
+type drainState struct {
+	mu       sync.Mutex
+	deadline time.Time     // zero unless draining
+	done     chan struct{} // closed once drained
+}
+
+func (d *drainState) doneCh() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.done == nil {
+		d.done = make(chan struct{})
+	}
+
+	return d.done
+}
+
+// Drain makes the visor refuse new transports and routes, and shuts it down once its routes are idle,
+// or once timeout (DefaultDrainTimeout if not positive) passes. Routes are idle when all their routing rules
+// have been removed or timed out for lack of traffic. Draining a visor which is already draining only returns
+// its status.
+func (visor *Visor) Drain(timeout time.Duration) *DrainStatus {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+
+	done := visor.drain.doneCh()
+
+	visor.drain.mu.Lock()
+	defer visor.drain.mu.Unlock()
+
+	if !visor.drain.deadline.IsZero() {
+		return &DrainStatus{Deadline: visor.drain.deadline, Routes: len(visor.router.Rules())}
+	}
+
+	visor.drain.deadline = time.Now().Add(timeout)
+
+	visor.logger.WithField("timeout", timeout).Info("Draining visor: new transports and routes are refused.")
+	visor.tm.Drain()
+	visor.router.Drain()
+
+	go visor.awaitDrain(visor.drain.deadline, done)
+
+	return &DrainStatus{Deadline: visor.drain.deadline, Routes: len(visor.router.Rules())}
+}
+
+// awaitDrain closes done once the routes of the visor are idle, or the deadline passes.
+func (visor *Visor) awaitDrain(deadline time.Time, done chan struct{}) {
+	defer close(done)
+	defer visor.uptime.end(ShutdownDrain)
+
+	t := time.NewTicker(drainCheckInterval)
+	defer t.Stop()
+
+	for {
+		routes := len(visor.router.Rules())
+		if routes == 0 {
+			visor.logger.Info("Visor drained, shutting down.")
+			return
+		}
+
+		if time.Now().After(deadline) {
+			visor.logger.WithField("routes", routes).Warn("Visor drain timed out, shutting down.")
+			return
+		}
+
+		<-t.C
+	}
+}
+
+// Drained returns a channel which is closed once the visor is drained, and is to be shut down.
+func (visor *Visor) Drained() <-chan struct{} {
+	return visor.drain.doneCh()
+}
+
+// drainStatus returns the status of draining the visor, or nil if it is not draining.
+func (visor *Visor) drainStatus() *DrainStatus {
+	visor.drain.mu.Lock()
+	defer visor.drain.mu.Unlock()
+
+	if visor.drain.deadline.IsZero() {
+		return nil
+	}
+
+	return &DrainStatus{Deadline: visor.drain.deadline, Routes: len(visor.router.Rules())}
+}
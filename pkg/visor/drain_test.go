@@ -0,0 +1,46 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/router"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+func TestVisorDrain(t *testing.T) {
+	r := &router.MockRouter{}
+	r.On("Drain").Return().Once()
+	r.On("Rules").Return([]routing.Rule{nil}).Twice()
+	r.On("Rules").Return([]routing.Rule{})
+
+	visor := &Visor{
+		router: r,
+		tm:     &transport.Manager{},
+		logger: logging.MustGetLogger("test"),
+	}
+
+	assert.Nil(t, visor.drainStatus())
+
+	status := visor.Drain(time.Minute)
+	require.NotNil(t, status)
+
+	select {
+	case <-visor.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatal("visor was not drained")
+	}
+
+	// Draining again only returns the status.
+	again := visor.Drain(time.Hour)
+	assert.Equal(t, status.Deadline, again.Deadline)
+	assert.Equal(t, 0, again.Routes)
+	assert.Equal(t, again, visor.drainStatus())
+
+	r.AssertExpectations(t)
+}
@@ -0,0 +1,140 @@
+package visor
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+)
+
+// EnvPrefix prefixes the names of environment variables which override visor config fields.
+const EnvPrefix = "SW"
+
+// envAliases are alternative names of config environment variables.
+var envAliases = map[string]string{ // nolint: gochecknoglobals
+	"SW_STCP_LOCAL_ADDR": "SW_STCP_LOCAL_ADDRESS",
+}
+
+// ApplyEnv overrides config fields with the environment variables in environ (as returned by os.Environ).
+// The variable of a field is named after its JSON path, such as SW_DMSG_DISCOVERY for 'dmsg.discovery'.
+// Values are decoded as JSON, or taken as strings if they are not valid JSON of the field type.
+// Sections which are not set are created when one of their fields is overridden.
+// It returns the names of the applied variables. The config should be validated afterwards.
+func (c *Config) ApplyEnv(environ []string) ([]string, error) {
+	env := make(map[string]string)
+
+	for _, kv := range environ {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv, EnvPrefix+"_") {
+			continue
+		}
+
+		k, v := kv[:i], kv[i+1:]
+		if alias, ok := envAliases[k]; ok {
+			k = alias
+		}
+
+		env[k] = v
+	}
+
+	if len(env) == 0 {
+		return nil, nil
+	}
+
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	var applied []string
+	if err := applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, env, &applied); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(applied)
+
+	return applied, nil
+}
+
+func applyEnv(v reflect.Value, prefix string, env map[string]string, applied *[]string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		if val, ok := env[key]; ok {
+			if err := decodeEnv(fv, val); err != nil {
+				return cfgutil.Invalid(key, "%v", err)
+			}
+
+			*applied = append(*applied, key)
+
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() != reflect.Struct || !hasEnvPrefix(env, key+"_") {
+			continue
+		}
+
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				fv.Set(reflect.New(ft))
+			}
+
+			fv = fv.Elem()
+		}
+
+		if err := applyEnv(fv, key, env, applied); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeEnv decodes val as JSON to fv, or as a JSON string if that fails.
+func decodeEnv(fv reflect.Value, val string) error {
+	ptr := reflect.New(fv.Type())
+
+	if err := json.Unmarshal([]byte(val), ptr.Interface()); err != nil {
+		quoted, qErr := json.Marshal(val)
+		if qErr != nil {
+			return qErr
+		}
+
+		ptr = reflect.New(fv.Type())
+		if json.Unmarshal(quoted, ptr.Interface()) != nil {
+			return err
+		}
+	}
+
+	fv.Set(ptr.Elem())
+
+	return nil
+}
+
+func hasEnvPrefix(env map[string]string, prefix string) bool {
+	for k := range env {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package visor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+)
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	pk := NewKeyPair().PubKey
+
+	conf := &Config{
+		Dmsg:      DefaultDmsgConfig(),
+		Transport: DefaultTransportConfig(),
+		LogLevel:  "info",
+	}
+
+	applied, err := conf.ApplyEnv([]string{
+		"PATH=/usr/bin",
+		"SW_CONFIG=./skywire-config.json",
+		"SW_DMSG_DISCOVERY=http://dmsg.example.com",
+		"SW_DMSG_SESSIONS_COUNT=3",
+		"SW_STCP_LOCAL_ADDR=0.0.0.0:7777",
+		"SW_TRANSPORT_LOG_STORE_TYPE=memory",
+		"SW_LOG_LEVEL=debug",
+		"SW_SHUTDOWN_TIMEOUT=30s",
+		"SW_TRUSTED_VISORS=[\"" + pk.Hex() + "\"]",
+		"SW_APPS=[{\"app\":\"skychat\",\"auto_start\":true,\"port\":1}]",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"SW_APPS",
+		"SW_DMSG_DISCOVERY",
+		"SW_DMSG_SESSIONS_COUNT",
+		"SW_LOG_LEVEL",
+		"SW_SHUTDOWN_TIMEOUT",
+		"SW_STCP_LOCAL_ADDRESS",
+		"SW_TRANSPORT_LOG_STORE_TYPE",
+		"SW_TRUSTED_VISORS",
+	}, applied)
+
+	assert.Equal(t, "http://dmsg.example.com", conf.Dmsg.Discovery)
+	assert.Equal(t, 3, conf.Dmsg.SessionsCount)
+	require.NotNil(t, conf.STCP, "unset sections are created")
+	assert.Equal(t, "0.0.0.0:7777", conf.STCP.LocalAddr)
+	assert.Equal(t, LogStoreType(LogStoreMemory), conf.Transport.LogStore.Type)
+	assert.Equal(t, "debug", conf.LogLevel)
+	assert.Equal(t, Duration(30*time.Second), conf.ShutdownTimeout)
+	assert.Equal(t, pk, conf.TrustedVisors[0])
+	assert.Equal(t, "skychat", conf.Apps[0].App)
+	assert.NoError(t, conf.Validate())
+
+	_, err = conf.ApplyEnv([]string{"SW_DMSG_SESSIONS_COUNT=many"})
+
+	var fErr *cfgutil.FieldError
+	require.True(t, errors.As(err, &fErr))
+	assert.Equal(t, "SW_DMSG_SESSIONS_COUNT", fErr.Path)
+}
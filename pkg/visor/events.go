@@ -0,0 +1,270 @@
+package visor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+// Kinds of visor events.
+const (
+	EventTransportUp   = "transport_up"   // A transport was established, data is a TransportEvent.
+	EventTransportDown = "transport_down" // A transport went down or was removed, data is a TransportEvent.
+
+	// EventTransportReconnecting is published when an attempt to re-establish a dropped transport failed, and
+	// EventTransportReconnectFailed when there are no attempts left. Data is a TransportReconnectEvent.
+	EventTransportReconnecting    = "transport_reconnecting"
+	EventTransportReconnectFailed = "transport_reconnect_failed"
+
+	EventRouteAdded    = "route_added"    // A routing rule was saved, data is a routing.RuleSummary.
+	EventAppCrashed    = "app_crashed"    // An app exited unexpectedly, data is an AppCrashEvent.
+	EventUpdateApplied = "update_applied" // The visor or an app was updated, data is an UpdateEvent.
+)
+
+// EventKinds are all kinds of visor events.
+var EventKinds = []string{ // nolint: gochecknoglobals
+	EventTransportUp,
+	EventTransportDown,
+	EventTransportReconnecting,
+	EventTransportReconnectFailed,
+	EventRouteAdded,
+	EventAppCrashed,
+	EventUpdateApplied,
+}
+
+const (
+	// MaxEventsTimeout is the maximum time Events waits for events.
+	MaxEventsTimeout = time.Minute
+
+	defaultEventsTimeout = 30 * time.Second // For the local API, if the timeout is not given.
+
+	eventHistoryLen    = 256              // Number of the most recent events kept for subscribers.
+	eventsTimeoutSlack = 10 * time.Second // For the replies of Events calls which waited for events.
+)
+
+// Event is an occurrence in the visor, published on its event bus.
+type Event struct {
+	Seq  uint64          `json:"seq"` // Sequence number of the event, starting from 1 on every visor start.
+	Kind string          `json:"kind"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// TransportEvent is the data of transport events.
+type TransportEvent struct {
+	ID     uuid.UUID     `json:"id"`
+	Remote cipher.PubKey `json:"remote_pk"`
+	Type   string        `json:"type"`
+}
+
+// TransportReconnectEvent is the data of transport reconnection events.
+type TransportReconnectEvent struct {
+	TransportEvent
+	Attempt int      `json:"attempt"`
+	Error   string   `json:"error"`
+	Backoff Duration `json:"backoff,omitempty"` // Delay before the next attempt.
+}
+
+// AppCrashEvent is the data of app crash events.
+type AppCrashEvent struct {
+	App       string `json:"app"`
+	Error     string `json:"error,omitempty"`
+	Unhealthy bool   `json:"unhealthy"` // Whether the app was stopped for failing its health checks.
+}
+
+// UpdateEvent is the data of update events.
+type UpdateEvent struct {
+	App string `json:"app,omitempty"` // Empty if the visor itself was updated.
+}
+
+// EventsOut is output of Events.
+type EventsOut struct {
+	Events []Event `json:"events"`
+	Cursor uint64  `json:"cursor"` // To be passed to the next call, to get the events after these.
+	Missed uint64  `json:"missed"` // Number of events after the given cursor which are no longer kept.
+}
+
+// eventBus keeps the most recent events of the visor, and wakes up the subscribers waiting for them.
+// The zero value is ready to use.
+type eventBus struct {
+	mu      sync.Mutex
+	seq     uint64
+	events  []Event       // oldest first
+	changed chan struct{} // closed when events are published
+}
+
+// publish records a new event of kind, with data encoded as JSON.
+func (b *eventBus) publish(kind string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	b.events = append(b.events, Event{Seq: b.seq, Kind: kind, Time: time.Now().UTC(), Data: raw})
+
+	if n := len(b.events) - eventHistoryLen; n > 0 {
+		b.events = append([]Event(nil), b.events[n:]...)
+	}
+
+	if b.changed != nil {
+		close(b.changed)
+		b.changed = nil
+	}
+
+	return nil
+}
+
+// since returns the events after the cursor of the given kinds (of all kinds if none are given),
+// and a channel closed once further events are published.
+// Cursors beyond the last event were given before the visor restarted, and are treated as zero.
+func (b *eventBus) since(cursor uint64, kinds []string) (*EventsOut, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cursor > b.seq {
+		cursor = 0
+	}
+
+	out := &EventsOut{Events: []Event{}, Cursor: b.seq}
+
+	oldest := b.seq + 1
+	if len(b.events) > 0 {
+		oldest = b.events[0].Seq
+	}
+
+	if oldest > cursor+1 {
+		out.Missed = oldest - cursor - 1
+	}
+
+	for _, e := range b.events {
+		if e.Seq > cursor && hasEventKind(kinds, e.Kind) {
+			out.Events = append(out.Events, e)
+		}
+	}
+
+	if b.changed == nil {
+		b.changed = make(chan struct{})
+	}
+
+	return out, b.changed
+}
+
+// wait returns the events after the cursor of the given kinds, waiting up to timeout for some to be published.
+func (b *eventBus) wait(cursor uint64, kinds []string, timeout time.Duration) *EventsOut {
+	out, changed := b.since(cursor, kinds)
+	if len(out.Events) > 0 || timeout <= 0 {
+		return out
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for len(out.Events) == 0 {
+		select {
+		case <-timer.C:
+			return out
+		case <-changed:
+		}
+
+		out, changed = b.since(out.Cursor, kinds)
+	}
+
+	return out
+}
+
+func hasEventKind(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkEventKinds returns ErrMalformedRequest for unknown event kinds.
+func checkEventKinds(kinds []string) error {
+	for _, k := range kinds {
+		if !hasEventKind(EventKinds, k) {
+			return fmt.Errorf("%w: unknown event kind %q", ErrMalformedRequest, k)
+		}
+	}
+
+	return nil
+}
+
+// publish publishes an event of kind on the event bus of the visor.
+func (visor *Visor) publish(kind string, data interface{}) {
+	if err := visor.events.publish(kind, data); err != nil {
+		visor.logger.WithError(err).WithField("kind", kind).Error("Failed to publish event.")
+	}
+}
+
+// Events returns the events after the cursor of the given kinds (of all kinds if none are given).
+// If there are none, it waits up to timeout (capped to MaxEventsTimeout) for some to be published.
+func (visor *Visor) Events(cursor uint64, kinds []string, timeout time.Duration) (*EventsOut, error) {
+	if err := checkEventKinds(kinds); err != nil {
+		return nil, err
+	}
+
+	if timeout > MaxEventsTimeout {
+		timeout = MaxEventsTimeout
+	}
+
+	return visor.events.wait(cursor, kinds, timeout), nil
+}
+
+// transportStatusChanged publishes transport events, it is the status hook of the transport manager.
+func (visor *Visor) transportStatusChanged(tp *transport.ManagedTransport, isUp bool) {
+	kind := EventTransportDown
+	if isUp {
+		kind = EventTransportUp
+	}
+
+	visor.publish(kind, TransportEvent{ID: tp.Entry.ID, Remote: tp.Remote(), Type: tp.Type()})
+}
+
+// transportReconnectFailed publishes transport reconnection events, it is the reconnect hook of the transport manager.
+func (visor *Visor) transportReconnectFailed(tp *transport.ManagedTransport, ev transport.ReconnectEvent) {
+	kind := EventTransportReconnecting
+	if ev.GaveUp {
+		kind = EventTransportReconnectFailed
+	}
+
+	visor.publish(kind, TransportReconnectEvent{
+		TransportEvent: TransportEvent{ID: tp.Entry.ID, Remote: tp.Remote(), Type: tp.Type()},
+		Attempt:        ev.Attempt,
+		Error:          ev.Err.Error(),
+		Backoff:        Duration(ev.Backoff),
+	})
+}
+
+// ruleSaved publishes route events, it is the rule hook of the router.
+func (visor *Visor) ruleSaved(rule routing.Rule) {
+	visor.publish(EventRouteAdded, rule.Summary())
+}
+
+// appCrashed publishes an app crash event.
+func (visor *Visor) appCrashed(appName string, err error, unhealthy bool) {
+	e := AppCrashEvent{App: appName, Unhealthy: unhealthy}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	visor.publish(EventAppCrashed, e)
+}
@@ -0,0 +1,87 @@
+package visor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	var b eventBus
+
+	out, _ := b.since(0, nil)
+	assert.Empty(t, out.Events)
+	assert.Equal(t, uint64(0), out.Cursor)
+
+	require.NoError(t, b.publish(EventAppCrashed, AppCrashEvent{App: "skychat", Error: "exit status 1"}))
+	require.NoError(t, b.publish(EventUpdateApplied, UpdateEvent{}))
+
+	out, _ = b.since(0, nil)
+	require.Len(t, out.Events, 2)
+	assert.Equal(t, uint64(2), out.Cursor)
+	assert.Equal(t, EventAppCrashed, out.Events[0].Kind)
+	assert.Equal(t, uint64(1), out.Events[0].Seq)
+
+	var e AppCrashEvent
+	require.NoError(t, json.Unmarshal(out.Events[0].Data, &e))
+	assert.Equal(t, AppCrashEvent{App: "skychat", Error: "exit status 1"}, e)
+
+	t.Run("kinds", func(t *testing.T) {
+		out, _ := b.since(0, []string{EventUpdateApplied})
+		require.Len(t, out.Events, 1)
+		assert.Equal(t, uint64(2), out.Events[0].Seq)
+		assert.Equal(t, uint64(2), out.Cursor)
+	})
+
+	t.Run("cursor_after_restart", func(t *testing.T) {
+		out, _ := b.since(10, nil)
+		assert.Len(t, out.Events, 2)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		start := time.Now()
+		out := b.wait(2, nil, 50*time.Millisecond)
+		assert.Empty(t, out.Events)
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+		time.AfterFunc(10*time.Millisecond, func() {
+			assert.NoError(t, b.publish(EventRouteAdded, struct{}{}))
+		})
+
+		out = b.wait(2, []string{EventRouteAdded}, time.Second)
+		require.Len(t, out.Events, 1)
+		assert.Equal(t, uint64(3), out.Cursor)
+	})
+
+	t.Run("missed", func(t *testing.T) {
+		for i := 0; i < eventHistoryLen; i++ {
+			require.NoError(t, b.publish(EventTransportUp, TransportEvent{}))
+		}
+
+		out, _ := b.since(1, nil)
+		assert.Len(t, out.Events, eventHistoryLen)
+		assert.Equal(t, uint64(2), out.Missed)
+
+		out, _ = b.since(out.Cursor, nil)
+		assert.Empty(t, out.Events)
+		assert.Equal(t, uint64(0), out.Missed)
+	})
+}
+
+func TestVisorEvents(t *testing.T) {
+	visor := &Visor{}
+
+	_, err := visor.Events(0, []string{"unknown"}, 0)
+	assert.True(t, errors.Is(err, ErrMalformedRequest))
+
+	visor.publish(EventTransportDown, TransportEvent{Type: "dmsg"})
+
+	out, err := visor.Events(0, []string{EventTransportUp, EventTransportDown}, 0)
+	require.NoError(t, err)
+	require.Len(t, out.Events, 1)
+	assert.Equal(t, EventTransportDown, out.Events[0].Kind)
+}
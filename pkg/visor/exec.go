@@ -0,0 +1,332 @@
+package visor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+const (
+	execReadTimeout = time.Second      // How long ExecRead waits for output.
+	execIdleTimeout = time.Minute      // Sessions which are not read from for this long are killed.
+	execReadMax     = 64 * 1024        // Maximum bytes of each stream returned by a single ExecRead.
+	execBufferMax   = 4 * execReadMax  // Output of each stream buffered before the command is blocked on writing it.
+	execExitUnknown = -1               // Exit code reported when the command could not be waited for.
+	execSessionsMax = 16               // Maximum number of concurrent exec sessions.
+	execStopTimeout = 10 * time.Second // How long ExecStop waits for the command to exit.
+)
+
+var (
+	// ErrUnknownExecSession is returned for exec session IDs which do not exist (or have ended).
+	ErrUnknownExecSession = errors.New("unknown exec session")
+
+	// ErrTooManyExecSessions is returned by ExecStart when the maximum number of sessions are running.
+	ErrTooManyExecSessions = errors.New("too many exec sessions")
+)
+
+// ExecOutput is output of a streamed command, as returned by ExecRead.
+type ExecOutput struct {
+	Stdout   []byte `json:"stdout,omitempty"`
+	Stderr   []byte `json:"stderr,omitempty"`
+	Exited   bool   `json:"exited"`              // Whether the command has exited and all output has been read.
+	ExitCode int    `json:"exit_code,omitempty"` // Set once Exited is true.
+}
+
+// execSession is a command started with ExecStart.
+type execSession struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	notify chan struct{}
+	done   chan struct{}
+	idle   *time.Timer
+
+	mu       sync.Mutex
+	drained  *sync.Cond // signaled when output is read, or discarded
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	discard  bool // set once the session is stopped, as the output won't be read
+	exitCode int
+}
+
+// execWriter appends command output to a buffer of the session, and notifies readers.
+// Writes are blocked while the buffer holds execBufferMax bytes, until output is read or the session is stopped,
+// which blocks the command once the pipe of the stream is full.
+type execWriter struct {
+	s   *execSession
+	buf *bytes.Buffer
+}
+
+func (w execWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+
+	for w.buf.Len() >= execBufferMax && !w.s.discard {
+		w.s.drained.Wait()
+	}
+
+	if !w.s.discard {
+		w.buf.Write(p) // nolint: errcheck
+	}
+
+	w.s.mu.Unlock()
+
+	select {
+	case w.s.notify <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+func startExecSession(args []string, onIdle func()) (*execSession, error) {
+	cmd := exec.Command(args[0], args[1:]...) // nolint: gosec
+
+	s := &execSession{
+		cmd:    cmd,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	s.drained = sync.NewCond(&s.mu)
+
+	cmd.Stdout = execWriter{s: s, buf: &s.stdout}
+	cmd.Stderr = execWriter{s: s, buf: &s.stderr}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	s.stdin = stdin
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	s.idle = time.AfterFunc(execIdleTimeout, onIdle)
+
+	go func() {
+		err := cmd.Wait()
+
+		code := 0
+		if err != nil {
+			code = execExitUnknown
+
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+		}
+
+		s.mu.Lock()
+		s.exitCode = code
+		s.mu.Unlock()
+
+		close(s.done)
+	}()
+
+	return s, nil
+}
+
+// read waits for output (up to timeout) and returns it.
+func (s *execSession) read(timeout time.Duration) ExecOutput {
+	s.idle.Reset(execIdleTimeout)
+
+	select {
+	case <-s.notify:
+	case <-s.done:
+	case <-time.After(timeout):
+	}
+
+	var exited bool
+	select {
+	case <-s.done:
+		exited = true
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := ExecOutput{
+		Stdout: nextBytes(&s.stdout, execReadMax),
+		Stderr: nextBytes(&s.stderr, execReadMax),
+	}
+
+	s.drained.Broadcast()
+
+	if exited && s.stdout.Len() == 0 && s.stderr.Len() == 0 {
+		out.Exited = true
+		out.ExitCode = s.exitCode
+	}
+
+	return out
+}
+
+func nextBytes(buf *bytes.Buffer, n int) []byte {
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	return append([]byte(nil), buf.Next(n)...)
+}
+
+// stop kills the command and waits for it to exit.
+func (s *execSession) stop() error {
+	s.idle.Stop()
+
+	// Output is discarded from now on, so that the command isn't blocked on writing it.
+	s.mu.Lock()
+	s.discard = true
+	s.mu.Unlock()
+	s.drained.Broadcast()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	if err := s.cmd.Process.Kill(); err != nil {
+		return err
+	}
+
+	select {
+	case <-s.done:
+	case <-time.After(execStopTimeout):
+	}
+
+	return nil
+}
+
+// execConfig returns a copy of the exec config, which may be replaced by reloading the config.
+func (c *Config) execConfig() *ExecConfig {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.Exec == nil {
+		return nil
+	}
+
+	exec := *c.Exec
+
+	return &exec
+}
+
+// execSessions holds the running exec sessions of a visor. The zero value is ready to use.
+type execSessions struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*execSession
+}
+
+func (es *execSessions) start(args []string, log *logging.Logger) (uuid.UUID, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.sessions == nil {
+		es.sessions = make(map[uuid.UUID]*execSession)
+	}
+
+	if len(es.sessions) >= execSessionsMax {
+		return uuid.UUID{}, ErrTooManyExecSessions
+	}
+
+	id := uuid.New()
+
+	s, err := startExecSession(args, func() {
+		if err := es.stop(id); err != nil {
+			log.WithError(err).WithField("session_id", id).Warn("Failed to stop idle exec session.")
+		}
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	es.sessions[id] = s
+
+	return id, nil
+}
+
+func (es *execSessions) get(id uuid.UUID) (*execSession, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	s, ok := es.sessions[id]
+	if !ok {
+		return nil, ErrUnknownExecSession
+	}
+
+	return s, nil
+}
+
+// read reads output of the session. The session is removed once it has exited and all output was read.
+func (es *execSessions) read(id uuid.UUID) (*ExecOutput, error) {
+	s, err := es.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	out := s.read(execReadTimeout)
+
+	if out.Exited {
+		es.mu.Lock()
+		delete(es.sessions, id)
+		es.mu.Unlock()
+
+		s.idle.Stop()
+	}
+
+	return &out, nil
+}
+
+func (es *execSessions) write(id uuid.UUID, data []byte, closeStdin bool) error {
+	s, err := es.get(id)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		if _, err := s.stdin.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if closeStdin {
+		return s.stdin.Close()
+	}
+
+	return nil
+}
+
+func (es *execSessions) stop(id uuid.UUID) error {
+	es.mu.Lock()
+	s, ok := es.sessions[id]
+	delete(es.sessions, id)
+	es.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownExecSession
+	}
+
+	return s.stop()
+}
+
+func (es *execSessions) stopAll(log *logging.Logger) {
+	es.mu.Lock()
+	ids := make([]uuid.UUID, 0, len(es.sessions))
+	for id := range es.sessions {
+		ids = append(ids, id)
+	}
+	es.mu.Unlock()
+
+	for _, id := range ids {
+		if err := es.stop(id); err != nil {
+			log.WithError(err).WithField("session_id", id).Warn("Failed to stop exec session.")
+		}
+	}
+}
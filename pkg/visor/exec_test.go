@@ -0,0 +1,106 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readUntilExit reads an exec session until the command exits, and returns all output.
+func readUntilExit(t *testing.T, es *execSessions, id uuid.UUID) (stdout string, code int) {
+	deadline := time.Now().Add(10 * time.Second)
+
+	for time.Now().Before(deadline) {
+		out, err := es.read(id)
+		require.NoError(t, err)
+
+		stdout += string(out.Stdout)
+
+		if out.Exited {
+			return stdout, out.ExitCode
+		}
+	}
+
+	t.Fatal("command did not exit")
+
+	return "", 0
+}
+
+func TestExecSessions(t *testing.T) {
+	log := logging.MustGetLogger("exec_test")
+
+	var es execSessions
+
+	t.Run("output_and_exit_code", func(t *testing.T) {
+		id, err := es.start([]string{"echo", "hello"}, log)
+		require.NoError(t, err)
+
+		stdout, code := readUntilExit(t, &es, id)
+		assert.Equal(t, "hello\n", stdout)
+		assert.Equal(t, 0, code)
+
+		_, err = es.read(id)
+		assert.Equal(t, ErrUnknownExecSession, err)
+
+		id, err = es.start([]string{"false"}, log)
+		require.NoError(t, err)
+
+		_, code = readUntilExit(t, &es, id)
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		id, err := es.start([]string{"cat"}, log)
+		require.NoError(t, err)
+
+		require.NoError(t, es.write(id, []byte("ping\n"), false))
+		require.NoError(t, es.write(id, []byte("pong\n"), true))
+
+		stdout, code := readUntilExit(t, &es, id)
+		assert.Equal(t, "ping\npong\n", stdout)
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		id, err := es.start([]string{"sleep", "30"}, log)
+		require.NoError(t, err)
+
+		require.NoError(t, es.stop(id))
+		assert.Equal(t, ErrUnknownExecSession, es.stop(id))
+	})
+
+	t.Run("bounded_output", func(t *testing.T) {
+		id, err := es.start([]string{"yes"}, log)
+		require.NoError(t, err)
+
+		s, err := es.get(id)
+		require.NoError(t, err)
+
+		// The command is blocked once the buffer is full, until output is read.
+		time.Sleep(200 * time.Millisecond)
+
+		s.mu.Lock()
+		buffered := s.stdout.Len()
+		s.mu.Unlock()
+
+		assert.True(t, buffered >= execBufferMax, buffered)
+		assert.True(t, buffered < 2*execBufferMax, buffered)
+
+		out, err := es.read(id)
+		require.NoError(t, err)
+		assert.Len(t, out.Stdout, execReadMax)
+
+		start := time.Now()
+		require.NoError(t, es.stop(id))
+		assert.True(t, time.Since(start) < execStopTimeout)
+	})
+
+	t.Run("unknown_command", func(t *testing.T) {
+		_, err := es.start([]string{"no-such-command-for-exec-test"}, log)
+		assert.Error(t, err)
+	})
+}
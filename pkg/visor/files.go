@@ -0,0 +1,471 @@
+package visor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/skyenv"
+)
+
+// Errors associated with file transfers.
+var (
+	ErrFilesDisabled  = errors.New("file transfers are disabled")
+	ErrPathNotAllowed = errors.New("path is not allowed for file transfers")
+	ErrFileNotFound   = errors.New("file not found")
+	ErrFileTooLarge   = errors.New("file exceeds the maximum size of uploads")
+	ErrNotRegularFile = errors.New("path is not a regular file")
+)
+
+// Operations of file transfers.
+const (
+	FileGet = "get"
+	FilePut = "put"
+)
+
+// Uploaded files are created with this mode, unless another one is requested.
+const defaultFileMode = os.FileMode(0644)
+
+// FilesConfig allows hypervisors to transfer files to and from the visor, over a dedicated dmsg stream.
+// If FilesConfig is not found, file transfers are disabled.
+type FilesConfig struct {
+	Allow   []string `json:"allow"`              // Absolute paths of files and directories which may be read or written.
+	MaxSize int64    `json:"max_size,omitempty"` // Maximum size of uploaded files in bytes, if set.
+}
+
+// Allows returns the path with symlinks resolved, or an error if it may not be transferred.
+// Paths are allowed if they are, or are in, one of the allowed paths once symlinks are resolved.
+func (c *FilesConfig) Allows(path string) (string, error) {
+	if c == nil || len(c.Allow) == 0 {
+		return "", ErrFilesDisabled
+	}
+
+	if !filepath.IsAbs(path) {
+		return "", ErrPathNotAllowed
+	}
+
+	resolved, err := resolvePath(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return "", ErrFileNotFound
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, allowed := range c.Allow {
+		dir, err := resolvePath(filepath.Clean(allowed))
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+
+	return "", ErrPathNotAllowed
+}
+
+// resolvePath resolves symlinks of path, or of its directory if path does not exist yet.
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if !os.IsNotExist(err) {
+		return resolved, err
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
+// FileRequest starts a file transfer over a dmsg stream, as a line of JSON.
+// Put requests are followed by Size bytes of content, once accepted by the visor.
+type FileRequest struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Size int64       `json:"size,omitempty"`
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// FileResponse answers a FileRequest, as a line of JSON.
+// Get requests are answered once, followed by Size bytes of content.
+// Put requests are answered once to accept them, and once the content is written.
+type FileResponse struct {
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+func writeJSONLine(w io.Writer, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(raw, '\n'))
+
+	return err
+}
+
+func readJSONLine(r *bufio.Reader, v interface{}) error {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(line, v)
+}
+
+// filesConfig returns a copy of the files config, which may be replaced by reloading the config.
+func (c *Config) filesConfig() *FilesConfig {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.Files == nil {
+		return nil
+	}
+
+	files := *c.Files
+
+	return &files
+}
+
+// serveFiles serves file transfers over dmsg to whitelisted hypervisors, if enabled in the config.
+func (visor *Visor) serveFiles(ctx context.Context) {
+	dmsgC := visor.dmsgClient()
+	if dmsgC == nil || visor.conf.filesConfig() == nil {
+		return
+	}
+
+	log := visor.Logger.PackageLogger("files")
+
+	lis, err := dmsgC.Listen(skyenv.DmsgFilesPort)
+	if err != nil {
+		log.WithError(err).Error("Failed to listen for file transfers.")
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := lis.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close file transfer listener.")
+		}
+	}()
+
+	for {
+		conn, err := lis.AcceptStream()
+		if err != nil {
+			log.WithError(err).Debug("Stopped serving file transfers.")
+			return
+		}
+
+		pk := conn.RawRemoteAddr().PK
+		if !visor.hvWhitelist.Allowed(pk) {
+			log.WithField("remote_pk", pk).Warn("Refused file transfer of hypervisor which is not whitelisted.")
+
+			if err := conn.Close(); err != nil {
+				log.WithError(err).Debug("Failed to close file transfer stream.")
+			}
+
+			continue
+		}
+
+		go serveFileConn(log.WithField("hypervisor_pk", pk), visor.conf.filesConfig(), conn, visor.hvWhitelist.ReadOnly(pk))
+	}
+}
+
+func serveFileConn(log logrus.FieldLogger, conf *FilesConfig, conn net.Conn, readOnly bool) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.WithError(err).Debug("Failed to close file transfer stream.")
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+
+	var req FileRequest
+	if err := readJSONLine(r, &req); err != nil {
+		log.WithError(err).Warn("Malformed file transfer request.")
+		return
+	}
+
+	log = log.WithField("op", req.Op).WithField("path", req.Path)
+
+	path, err := conf.Allows(req.Path)
+	if err == nil {
+		switch req.Op {
+		case FileGet:
+			err = sendFile(log, conn, path)
+		case FilePut:
+			if readOnly {
+				err = ErrReadOnlyHypervisor
+				break
+			}
+
+			err = receiveFile(log, r, conn, path, req, conf.MaxSize)
+		default:
+			err = fmt.Errorf("unknown file operation %q", req.Op)
+		}
+	}
+
+	if err != nil {
+		log.WithError(err).Warn("File transfer failed.")
+
+		if err := writeJSONLine(conn, FileResponse{Error: err.Error()}); err != nil {
+			log.WithError(err).Debug("Failed to write file transfer response.")
+		}
+
+		return
+	}
+
+	log.Info("Transferred file.")
+}
+
+// sendFile writes the file of path to w. Errors are only returned if no content was written.
+func sendFile(log logrus.FieldLogger, w io.Writer, path string) error {
+	f, err := os.Open(path) // nolint: gosec
+	if os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.WithError(err).Debug("Failed to close transferred file.")
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return ErrNotRegularFile
+	}
+
+	if err := writeJSONLine(w, FileResponse{Size: info.Size()}); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(w, f, info.Size()); err != nil {
+		log.WithError(err).Warn("Failed to send file.")
+	}
+
+	return nil
+}
+
+// receiveFile replaces the file of path with the content of the request read from r.
+// The file is written to a temporary file first, so it is not left incomplete.
+func receiveFile(log logrus.FieldLogger, r io.Reader, w io.Writer, path string, req FileRequest, maxSize int64) error {
+	if req.Size < 0 {
+		return fmt.Errorf("invalid file size %d", req.Size)
+	}
+
+	if maxSize > 0 && req.Size > maxSize {
+		return ErrFileTooLarge
+	}
+
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return ErrNotRegularFile
+	}
+
+	mode := req.Mode.Perm()
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Warn("Failed to remove temporary file.")
+		}
+	}()
+
+	if err := writeJSONLine(w, FileResponse{}); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(tmp, r, req.Size); err != nil {
+		_ = tmp.Close() // nolint: errcheck
+		return err
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close() // nolint: errcheck
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return writeJSONLine(w, FileResponse{Size: req.Size})
+}
+
+// FilesClient transfers files to and from a visor, over streams opened with dial.
+type FilesClient struct {
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// NewFilesClient creates a new FilesClient.
+func NewFilesClient(dial func(ctx context.Context) (net.Conn, error)) *FilesClient {
+	return &FilesClient{dial: dial}
+}
+
+// NewDmsgFilesClient creates a new FilesClient of the visor of pk, reached over dmsg.
+func NewDmsgFilesClient(dmsgC *dmsg.Client, pk cipher.PubKey) *FilesClient {
+	return NewFilesClient(func(ctx context.Context) (net.Conn, error) {
+		stream, err := dmsgC.DialStream(ctx, dmsg.Addr{PK: pk, Port: skyenv.DmsgFilesPort})
+		if err != nil {
+			return nil, err
+		}
+
+		return stream, nil
+	})
+}
+
+// Download opens the file of path on the visor, returning its content and size.
+// The content should be closed once read.
+func (fc *FilesClient) Download(ctx context.Context, path string) (io.ReadCloser, int64, error) {
+	conn, err := fc.open(ctx, FileRequest{Op: FileGet, Path: path})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := conn.response()
+	if err != nil {
+		_ = conn.Close() // nolint: errcheck
+		return nil, 0, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(conn.r, resp.Size), conn}, resp.Size, nil
+}
+
+// Upload creates or replaces the file of path on the visor with size bytes read from r.
+// The file is created with mode, or 0644 if it is zero.
+func (fc *FilesClient) Upload(ctx context.Context, path string, mode os.FileMode, size int64, r io.Reader) error {
+	conn, err := fc.open(ctx, FileRequest{Op: FilePut, Path: path, Size: size, Mode: mode})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = conn.Close() // nolint: errcheck
+	}()
+
+	if _, err := conn.response(); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(conn, r, size); err != nil {
+		return err
+	}
+
+	_, err = conn.response()
+
+	return err
+}
+
+func (fc *FilesClient) open(ctx context.Context, req FileRequest) (*fileConn, error) {
+	conn, err := fc.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fConn := &fileConn{Conn: conn, r: bufio.NewReader(conn), done: make(chan struct{})}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close() // nolint: errcheck
+		case <-fConn.done:
+		}
+	}()
+
+	if err := writeJSONLine(conn, req); err != nil {
+		_ = fConn.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	return fConn, nil
+}
+
+// fileConn is a file transfer stream, which is closed once the context of the transfer is done.
+type fileConn struct {
+	net.Conn
+	r    *bufio.Reader
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *fileConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+func (c *fileConn) response() (*FileResponse, error) {
+	var resp FileResponse
+	if err := readJSONLine(c.r, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fileError(resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// fileError recovers errors of file transfers reported by the visor.
+func fileError(msg string) error {
+	for _, err := range []error{ErrFilesDisabled, ErrPathNotAllowed, ErrFileNotFound, ErrFileTooLarge, ErrNotRegularFile, ErrReadOnlyHypervisor} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+
+	return errors.New(msg)
+}
+
+// NewMockFilesClient creates a FilesClient which serves transfers allowed by conf in process.
+func NewMockFilesClient(conf *FilesConfig) *FilesClient {
+	return NewFilesClient(func(context.Context) (net.Conn, error) {
+		conn, visorConn := net.Pipe()
+		go serveFileConn(logrus.New(), conf, visorConn, false)
+
+		return conn, nil
+	})
+}
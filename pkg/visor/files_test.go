@@ -0,0 +1,148 @@
+package visor
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesConfig_Allows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	// Resolve the temporary directory itself, which may be behind a symlink.
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	allowed := filepath.Join(dir, "allowed")
+	require.NoError(t, os.Mkdir(allowed, 0700))
+	require.NoError(t, os.Symlink(dir, filepath.Join(allowed, "escape")))
+
+	var unset *FilesConfig
+
+	_, err = unset.Allows(filepath.Join(allowed, "file"))
+	assert.Equal(t, ErrFilesDisabled, err)
+
+	conf := &FilesConfig{Allow: []string{allowed}}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+		err  error
+	}{
+		{"new_file", filepath.Join(allowed, "file"), filepath.Join(allowed, "file"), nil},
+		{"dir", allowed, allowed, nil},
+		{"relative", "allowed/file", "", ErrPathNotAllowed},
+		{"outside", filepath.Join(dir, "file"), "", ErrPathNotAllowed},
+		{"dot_dot", filepath.Join(allowed, "..", "file"), "", ErrPathNotAllowed},
+		{"prefix", allowed + "2", "", ErrPathNotAllowed},
+		{"symlink", filepath.Join(allowed, "escape", "file"), "", ErrPathNotAllowed},
+		{"missing_dir", filepath.Join(allowed, "missing", "file"), "", ErrFileNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := conf.Allows(tc.path)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.want, path)
+		})
+	}
+}
+
+func TestFilesClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	fc := NewMockFilesClient(&FilesConfig{Allow: []string{dir}, MaxSize: 16})
+	ctx := context.Background()
+	path := filepath.Join(dir, "key")
+
+	content := []byte("secret")
+	require.NoError(t, fc.Upload(ctx, path, 0600, int64(len(content)), bytes.NewReader(content)))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	r, size, err := fc.Download(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size)
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, content, got)
+
+	err = fc.Upload(ctx, path, 0, 17, bytes.NewReader(make([]byte, 17)))
+	assert.Equal(t, ErrFileTooLarge, err)
+
+	_, _, err = fc.Download(ctx, filepath.Join(dir, "missing"))
+	assert.Equal(t, ErrFileNotFound, err)
+
+	_, _, err = fc.Download(ctx, "/etc/passwd")
+	assert.Equal(t, ErrPathNotAllowed, err)
+
+	_, _, err = fc.Download(ctx, dir)
+	assert.Equal(t, ErrNotRegularFile, err)
+
+	// Files are only replaced once fully received.
+	got, err = ioutil.ReadFile(path) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestFilesClient_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "key")
+	content := []byte("secret")
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+
+	conf := &FilesConfig{Allow: []string{dir}}
+	fc := NewFilesClient(func(context.Context) (net.Conn, error) {
+		conn, visorConn := net.Pipe()
+		go serveFileConn(logrus.New(), conf, visorConn, true)
+
+		return conn, nil
+	})
+	ctx := context.Background()
+
+	r, _, err := fc.Download(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	err = fc.Upload(ctx, path, 0, 3, bytes.NewReader([]byte("new")))
+	assert.Equal(t, ErrReadOnlyHypervisor, err)
+
+	got, err := ioutil.ReadFile(path) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
@@ -0,0 +1,201 @@
+package visor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+const appBinaryPerm = 0755
+
+var (
+	// ErrAppInstallDisabled is returned by InstallApp if installing apps is disabled in the config.
+	ErrAppInstallDisabled = errors.New("app install is disabled")
+
+	// ErrBadAppName is returned by InstallApp for app names which can't be used as a file name.
+	ErrBadAppName = errors.New("app name should be 1 to 64 alphanumeric chars, '.', '_' or '-'")
+
+	// ErrAppChecksumMismatch is returned by InstallApp if the binary does not match its checksum.
+	ErrAppChecksumMismatch = errors.New("app binary does not match its checksum")
+
+	// ErrAppNotSigned is returned by InstallApp if the binary is not signed by one of the trusted signers.
+	ErrAppNotSigned = errors.New("app binary is not signed by a trusted signer")
+
+	// ErrNoAppSigners is returned by InstallApp if there are no trusted signers, and unsigned apps are not allowed.
+	ErrNoAppSigners = errors.New("no trusted signers are set to verify app binaries with")
+)
+
+var appNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,63}$`) // nolint: gochecknoglobals
+
+// AppInstallConfig restricts apps installed remotely via RPC.InstallApp.
+// Binaries must be signed by one of the trusted signers, unless unsigned binaries are explicitly allowed.
+// If AppInstallConfig is not found, apps can't be installed.
+type AppInstallConfig struct {
+	Disabled bool            `json:"disabled"`          // Disables installing apps entirely.
+	Signers  []cipher.PubKey `json:"signers,omitempty"` // Binaries must be signed by one of these keys.
+
+	// AllowUnsigned allows installing binaries without signers. They are then only checked against the checksum sent
+	// along with them, which proves nothing about where they come from: any hypervisor managing the visor can run
+	// binaries of its choice on it.
+	AllowUnsigned bool `json:"allow_unsigned,omitempty"`
+}
+
+// AppInstall is an app binary, together with the config the app is registered with.
+type AppInstall struct {
+	App       string
+	Binary    []byte
+	SHA256    string     // Hex encoded SHA-256 checksum of the binary.
+	Sig       cipher.Sig // Signature of the checksum, required if the visor has trusted signers.
+	Port      routing.Port
+	Args      []string
+	AutoStart bool
+	Start     bool // Start the app once installed. Running apps are always restarted.
+}
+
+// String describes the install without the binary, so that it can be logged.
+func (in AppInstall) String() string {
+	return fmt.Sprintf("{App:%s Size:%d SHA256:%s Port:%d Args:%v AutoStart:%v Start:%v}",
+		in.App, len(in.Binary), in.SHA256, in.Port, in.Args, in.AutoStart, in.Start)
+}
+
+// Verify returns an error if the app should not be installed.
+// The binary is checked against its checksum, and the signature of the checksum against the trusted signers.
+// Without signers, binaries are refused unless unsigned binaries are allowed.
+func (c *AppInstallConfig) Verify(in *AppInstall) error {
+	if c != nil && c.Disabled {
+		return ErrAppInstallDisabled
+	}
+
+	if !appNameRegexp.MatchString(in.App) {
+		return ErrBadAppName
+	}
+
+	sum := sha256.Sum256(in.Binary)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), in.SHA256) {
+		return ErrAppChecksumMismatch
+	}
+
+	if c == nil || len(c.Signers) == 0 {
+		if c != nil && c.AllowUnsigned {
+			return nil
+		}
+
+		return ErrNoAppSigners
+	}
+
+	for _, pk := range c.Signers {
+		if cipher.VerifyPubKeySignedPayload(pk, in.Sig, sum[:]) == nil {
+			return nil
+		}
+	}
+
+	return ErrAppNotSigned
+}
+
+// InstallApp writes an app binary to the apps dir and registers the app in the config, replacing any app of the name.
+// Installs are checked against the 'app_install' config first.
+func (visor *Visor) InstallApp(in AppInstall) error {
+	if err := visor.conf.appInstallConfig().Verify(&in); err != nil {
+		visor.logger.WithError(err).Warnf("Refused to install app %s", in.App)
+		return err
+	}
+
+	visor.installMu.Lock()
+	defer visor.installMu.Unlock()
+
+	appConf := AppConfig{App: in.App, AutoStart: in.AutoStart, Port: in.Port, Args: in.Args}
+
+	withApp := func(apps []AppConfig) ([]AppConfig, error) {
+		installed := make([]AppConfig, 0, len(apps)+1)
+		for _, app := range apps {
+			if app.App != in.App {
+				installed = append(installed, app)
+			}
+		}
+
+		return append(installed, appConf), nil
+	}
+
+	// The apps are checked before the binary is replaced, and changed once it is.
+	apps, _ := withApp(visor.conf.apps()) // nolint: errcheck
+	if err := (&Config{Apps: apps}).Validate(); err != nil {
+		return err
+	}
+
+	running := visor.procManager.Exists(in.App)
+	if running {
+		if err := visor.StopApp(in.App); err != nil {
+			return fmt.Errorf("stop app %v: %w", in.App, err)
+		}
+	}
+
+	visor.logger.Infof("Installing app %s (%d bytes)", in.App, len(in.Binary))
+
+	if err := writeAppBinary(filepath.Join(visor.appsPath, in.App), in.Binary); err != nil {
+		return err
+	}
+
+	if err := visor.conf.updateApps(withApp); err != nil {
+		return err
+	}
+
+	visor.setAppConf(in.App, appConf)
+
+	if err := visor.conf.flush(); err != nil {
+		return err
+	}
+
+	if in.Start || running {
+		return visor.StartApp(in.App)
+	}
+
+	return nil
+}
+
+// appInstallConfig returns a copy of the app install config, which may be replaced by reloading the config.
+func (c *Config) appInstallConfig() *AppInstallConfig {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.AppInstall == nil {
+		return nil
+	}
+
+	appInstall := *c.AppInstall
+
+	return &appInstall
+}
+
+// apps returns a copy of the apps of the config.
+func (c *Config) apps() []AppConfig {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	return append([]AppConfig(nil), c.Apps...)
+}
+
+// writeAppBinary writes an executable to a temp file first, so that the binary at path is replaced atomically.
+func writeAppBinary(path string, data []byte) error {
+	tmp := path + ".install"
+
+	if err := ioutil.WriteFile(tmp, data, appBinaryPerm); err != nil { // nolint: gosec
+		return err
+	}
+
+	// WriteFile does not change the mode of an existing file.
+	if err := os.Chmod(tmp, appBinaryPerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
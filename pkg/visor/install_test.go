@@ -0,0 +1,83 @@
+package visor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppInstallConfig_Verify(t *testing.T) {
+	bin := []byte("#!/bin/sh\necho hello\n")
+	sum := sha256.Sum256(bin)
+
+	signer, signerSK := cipher.GenerateKeyPair()
+	other, otherSK := cipher.GenerateKeyPair()
+
+	sig, err := cipher.SignPayload(sum[:], signerSK)
+	require.NoError(t, err)
+
+	otherSig, err := cipher.SignPayload(sum[:], otherSK)
+	require.NoError(t, err)
+
+	install := func(name, checksum string, sig cipher.Sig) *AppInstall {
+		return &AppInstall{App: name, Binary: bin, SHA256: checksum, Sig: sig}
+	}
+
+	checksum := hex.EncodeToString(sum[:])
+	signed := &AppInstallConfig{Signers: []cipher.PubKey{signer}}
+
+	cases := []struct {
+		name string
+		conf *AppInstallConfig
+		in   *AppInstall
+		err  error
+	}{
+		{"no_config", nil, install("hello", checksum, cipher.Sig{}), ErrNoAppSigners},
+		{"no_signers", &AppInstallConfig{}, install("hello", checksum, cipher.Sig{}), ErrNoAppSigners},
+		{"allow_unsigned", &AppInstallConfig{AllowUnsigned: true}, install("hello", checksum, cipher.Sig{}), nil},
+		{"disabled", &AppInstallConfig{Disabled: true}, install("hello", checksum, cipher.Sig{}), ErrAppInstallDisabled},
+		{"bad_name", nil, install("../hello", checksum, cipher.Sig{}), ErrBadAppName},
+		{"dot_name", nil, install("..", checksum, cipher.Sig{}), ErrBadAppName},
+		{"bad_checksum", nil, install("hello", "00", cipher.Sig{}), ErrAppChecksumMismatch},
+		{"signed", signed, install("hello", checksum, sig), nil},
+		{"not_signed", signed, install("hello", checksum, cipher.Sig{}), ErrAppNotSigned},
+		{"untrusted_signer", signed, install("hello", checksum, otherSig), ErrAppNotSigned},
+		{"second_signer", &AppInstallConfig{Signers: []cipher.PubKey{signer, other}}, install("hello", checksum, otherSig), nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, tc.conf.Verify(tc.in))
+		})
+	}
+}
+
+func TestWriteAppBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "app_install")
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	path := filepath.Join(dir, "hello")
+	require.NoError(t, ioutil.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, writeAppBinary(path, []byte("new")))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(appBinaryPerm), info.Mode().Perm())
+
+	_, err = os.Stat(path + ".install")
+	assert.True(t, os.IsNotExist(err))
+}
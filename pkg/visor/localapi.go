@@ -0,0 +1,347 @@
+package visor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/httputil"
+
+	"github.com/skycoin/skywire/pkg/app/appserver"
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+// Errors related to the local API.
+var (
+	ErrLocalAPIAddr     = errors.New("local api must listen on a loopback address")
+	ErrLocalAPIHost     = errors.New("local api only serves requests to loopback hosts")
+	ErrMalformedRequest = errors.New("malformed request")
+)
+
+// checkLocalAPIAddr returns ErrLocalAPIAddr unless addr is on a loopback interface,
+// as the local API is served without authentication.
+func checkLocalAPIAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	if !isLoopbackHost(host) {
+		return ErrLocalAPIAddr
+	}
+
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// localAPI serves the read-only routes of the RPC gateway of the visor as HTTP/JSON, with the routes of the visor
+// on the hypervisor. Changes are left to the hypervisor and the CLI, as the API is served without authentication.
+type localAPI struct {
+	rpc *RPC
+}
+
+func newLocalAPI(v *Visor) *localAPI {
+	return &localAPI{rpc: &RPC{visor: v, log: v.Logger.PackageLogger("visor_rpc:local_api")}}
+}
+
+func (api *localAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Requests to other hosts come from web pages which rebound their domains to the loopback address.
+	host := req.Host
+	if h, _, err := net.SplitHostPort(req.Host); err == nil {
+		host = h
+	}
+
+	if !isLoopbackHost(host) {
+		httputil.WriteJSON(w, req, http.StatusForbidden, ErrLocalAPIHost)
+		return
+	}
+
+	r := chi.NewRouter()
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/", api.getSummary)
+		r.Get("/health", api.getHealth)
+		r.Get("/uptime", api.getUptime)
+		r.Get("/uptime/stats", api.getUptimeStats)
+		r.Get("/resources", api.getResources)
+		r.Get("/events", api.getEvents)
+		r.Get("/apps", api.getApps)
+		r.Get("/apps/{app}", api.getApp)
+		r.Get("/apps/{app}/logs", api.getAppLogs)
+		r.Get("/apps/{app}/connections", api.getAppConnections)
+		r.Get("/apps/{app}/usage", api.getAppUsage)
+		r.Get("/apps/{app}/settings", api.getAppSettings)
+		r.Get("/transport-types", api.getTransportTypes)
+		r.Get("/transports", api.getTransports)
+		r.Get("/transports/{tid}", api.getTransport)
+		r.Get("/routes", api.getRoutes)
+		r.Get("/routes/{rid}", api.getRoute)
+		r.Get("/routegroups", api.getRouteGroups)
+	})
+
+	r.ServeHTTP(w, req)
+}
+
+// respond writes out as JSON, or err with its status if it is not nil.
+func respond(w http.ResponseWriter, r *http.Request, out interface{}, err error) {
+	if err != nil {
+		httputil.WriteJSON(w, r, localAPIStatus(err), err)
+		return
+	}
+
+	httputil.WriteJSON(w, r, http.StatusOK, out)
+}
+
+func localAPIStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownApp), errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrMalformedRequest), errors.Is(err, ErrInvalidConfig):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAppNotRunning):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (api *localAPI) getSummary(w http.ResponseWriter, r *http.Request) {
+	var out Summary
+	respond(w, r, &out, api.rpc.Summary(nil, &out))
+}
+
+func (api *localAPI) getHealth(w http.ResponseWriter, r *http.Request) {
+	var out HealthInfo
+	respond(w, r, &out, api.rpc.Health(nil, &out))
+}
+
+func (api *localAPI) getUptime(w http.ResponseWriter, r *http.Request) {
+	var out float64
+	respond(w, r, &out, api.rpc.Uptime(nil, &out))
+}
+
+func (api *localAPI) getUptimeStats(w http.ResponseWriter, r *http.Request) {
+	var out UptimeStats
+	respond(w, r, &out, api.rpc.UptimeStats(nil, &out))
+}
+
+func (api *localAPI) getResources(w http.ResponseWriter, r *http.Request) {
+	var out Resources
+	respond(w, r, &out, api.rpc.Resources(nil, &out))
+}
+
+func (api *localAPI) getEvents(w http.ResponseWriter, r *http.Request) {
+	// Events of all kinds are returned, unless 'kind' is set. Without events, the response is delayed until
+	// there are some, or the 'timeout' query (30s by default) expires.
+	q := r.URL.Query()
+	in := EventsIn{Kinds: q["kind"], Timeout: defaultEventsTimeout}
+
+	if c := q.Get("cursor"); c != "" {
+		var err error
+		if in.Cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+			respond(w, r, nil, fmt.Errorf("%w: %v", ErrMalformedRequest, err))
+			return
+		}
+	}
+
+	if t := q.Get("timeout"); t != "" {
+		var err error
+		if in.Timeout, err = time.ParseDuration(t); err != nil || in.Timeout < 0 {
+			respond(w, r, nil, ErrMalformedRequest)
+			return
+		}
+	}
+
+	var out EventsOut
+	respond(w, r, &out, api.rpc.Events(&in, &out))
+}
+
+func (api *localAPI) getApps(w http.ResponseWriter, r *http.Request) {
+	var out []*AppState
+	respond(w, r, &out, api.rpc.Apps(nil, &out))
+}
+
+func (api *localAPI) getApp(w http.ResponseWriter, r *http.Request) {
+	app, ok := api.rpc.visor.App(chi.URLParam(r, "app"))
+	if !ok {
+		respond(w, r, nil, ErrUnknownApp)
+		return
+	}
+
+	respond(w, r, app, nil)
+}
+
+func (api *localAPI) getAppLogs(w http.ResponseWriter, r *http.Request) {
+	// Logs since the unix epoch are returned, unless 'since' is a valid RFC 3339 time.
+	since, err := time.Parse(time.RFC3339Nano, strings.Replace(r.URL.Query().Get("since"), " ", "+", 1))
+	if err != nil {
+		since = time.Unix(0, 0)
+	}
+
+	in := AppLogsRequest{TimeStamp: since, AppName: chi.URLParam(r, "app")}
+
+	// Logs of all levels are returned, unless 'level' is set. 'limit' caps the number of logs.
+	in.Filter.Level = r.URL.Query().Get("level")
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if in.Filter.Limit, err = strconv.Atoi(limit); err != nil {
+			respond(w, r, nil, fmt.Errorf("%w: %v", ErrMalformedRequest, err))
+			return
+		}
+	}
+
+	if err := in.Filter.Validate(); err != nil {
+		respond(w, r, nil, fmt.Errorf("%w: %v", ErrMalformedRequest, err))
+		return
+	}
+
+	var out []string
+	respond(w, r, &out, api.rpc.LogsSince(&in, &out))
+}
+
+func (api *localAPI) getAppConnections(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "app")
+
+	var out []appserver.ConnSummary
+	respond(w, r, &out, api.rpc.AppConnections(&name, &out))
+}
+
+func (api *localAPI) getAppUsage(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "app")
+
+	var out appserver.Usage
+	respond(w, r, &out, api.rpc.AppUsage(&name, &out))
+}
+
+func (api *localAPI) getAppSettings(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "app")
+
+	var out map[string]string
+	respond(w, r, &out, api.rpc.GetAppSettings(&name, &out))
+}
+
+func (api *localAPI) getTransportTypes(w http.ResponseWriter, r *http.Request) {
+	var out []string
+	respond(w, r, &out, api.rpc.TransportTypes(nil, &out))
+}
+
+func (api *localAPI) getTransports(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	in := TransportsIn{FilterTypes: q["type"], ShowLogs: q.Get("logs") != "false"}
+
+	for _, s := range q["pk"] {
+		var pk cipher.PubKey
+		if err := pk.Set(s); err != nil {
+			respond(w, r, nil, fmt.Errorf("%w: %v", ErrMalformedRequest, err))
+			return
+		}
+
+		in.FilterPubKeys = append(in.FilterPubKeys, pk)
+	}
+
+	var out []*TransportSummary
+	respond(w, r, &out, api.rpc.Transports(&in, &out))
+}
+
+func transportIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	tid, err := uuid.Parse(chi.URLParam(r, "tid"))
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+
+	return tid, nil
+}
+
+func (api *localAPI) getTransport(w http.ResponseWriter, r *http.Request) {
+	tid, err := transportIDFromRequest(r)
+	if err != nil {
+		respond(w, r, nil, err)
+		return
+	}
+
+	var out TransportSummary
+	respond(w, r, &out, api.rpc.Transport(&tid, &out))
+}
+
+// localRuleResp is a routing rule, as returned by the hypervisor.
+type localRuleResp struct {
+	Key     routing.RouteID      `json:"key"`
+	Rule    string               `json:"rule"`
+	Summary *routing.RuleSummary `json:"rule_summary"`
+}
+
+func newLocalRuleResp(rule routing.Rule) localRuleResp {
+	return localRuleResp{Key: rule.KeyRouteID(), Rule: hex.EncodeToString(rule), Summary: rule.Summary()}
+}
+
+func routeIDFromRequest(r *http.Request) (routing.RouteID, error) {
+	rid, err := strconv.ParseUint(chi.URLParam(r, "rid"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+
+	return routing.RouteID(rid), nil
+}
+
+func (api *localAPI) getRoutes(w http.ResponseWriter, r *http.Request) {
+	var rules []routing.Rule
+	if err := api.rpc.RoutingRules(nil, &rules); err != nil {
+		respond(w, r, nil, err)
+		return
+	}
+
+	out := make([]localRuleResp, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, newLocalRuleResp(rule))
+	}
+
+	respond(w, r, &out, nil)
+}
+
+func (api *localAPI) getRoute(w http.ResponseWriter, r *http.Request) {
+	rid, err := routeIDFromRequest(r)
+	if err != nil {
+		respond(w, r, nil, err)
+		return
+	}
+
+	var rule routing.Rule
+	if err := api.rpc.RoutingRule(&rid, &rule); err != nil || rule == nil {
+		respond(w, r, nil, ErrNotFound)
+		return
+	}
+
+	respond(w, r, newLocalRuleResp(rule), nil)
+}
+
+func (api *localAPI) getRouteGroups(w http.ResponseWriter, r *http.Request) {
+	var out []RouteGroupInfo
+	respond(w, r, &out, api.rpc.RouteGroups(nil, &out))
+}
+
+// serveLocalAPI serves the local API on l until it is closed.
+func (visor *Visor) serveLocalAPI(l net.Listener) {
+	visor.logger.Info("Serving local API on ", l.Addr())
+
+	err := http.Serve(l, newLocalAPI(visor))
+	if err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		visor.logger.WithError(err).Error("Serve local API stopped.")
+	}
+}
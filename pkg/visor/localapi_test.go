@@ -0,0 +1,93 @@
+package visor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app/appserver"
+)
+
+func TestCheckLocalAPIAddr(t *testing.T) {
+	for _, addr := range []string{"localhost:3436", "127.0.0.1:3436", "[::1]:3436"} {
+		assert.NoError(t, checkLocalAPIAddr(addr), addr)
+	}
+
+	for _, addr := range []string{":3436", "0.0.0.0:3436", "192.168.1.10:3436", "example.com:3436", "localhost"} {
+		assert.Error(t, checkLocalAPIAddr(addr), addr)
+	}
+}
+
+func TestLocalAPI(t *testing.T) {
+	pm := &appserver.MockProcManager{}
+	pm.On("Exists", "foo").Return(true)
+
+	v := &Visor{
+		Logger:      logging.NewMasterLogger(),
+		appsConf:    map[string]AppConfig{"foo": {App: "foo", AutoStart: true, Port: 10}},
+		procManager: pm,
+	}
+
+	srv := httptest.NewServer(newLocalAPI(v))
+	defer srv.Close()
+
+	get := func(path string) *http.Response {
+		resp, err := http.Get(srv.URL + path) // nolint: gosec, noctx
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("apps", func(t *testing.T) {
+		resp := get("/api/v1/apps")
+		defer func() { require.NoError(t, resp.Body.Close()) }()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var apps []*AppState
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&apps))
+		require.Len(t, apps, 1)
+		assert.Equal(t, "foo", apps[0].Name)
+		assert.Equal(t, AppStatusRunning, apps[0].Status)
+	})
+
+	t.Run("unknown app", func(t *testing.T) {
+		resp := get("/api/v1/apps/bar")
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+"/api/v1/apps/foo", strings.NewReader(`{"status":0}`))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("rebound host", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/apps", nil)
+		require.NoError(t, err)
+
+		req.Host = "attacker.example.com"
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("malformed transport id", func(t *testing.T) {
+		resp := get("/api/v1/transports/not-a-uuid")
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
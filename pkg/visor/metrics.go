@@ -0,0 +1,228 @@
+package visor
+
+import (
+	"net/http"
+	"net/rpc"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+var (
+	routerPacketsReceivedDesc = prometheus.NewDesc("skywire_visor_router_packets_received_total",
+		"Packets received by the router from transports.", []string{"type"}, nil)
+	routerPacketsForwardedDesc = prometheus.NewDesc("skywire_visor_router_packets_forwarded_total",
+		"Packets forwarded by the router to the next hop.", nil, nil)
+	routerPacketsDroppedDesc = prometheus.NewDesc("skywire_visor_router_packets_dropped_total",
+		"Packets received by the router which failed to be handled.", nil, nil)
+	routerRulesDesc = prometheus.NewDesc("skywire_visor_router_rules",
+		"Rules in the routing table.", nil, nil)
+
+	transportLabels       = []string{"id", "remote", "type"}
+	transportSentDesc     = prometheus.NewDesc("skywire_visor_transport_sent_bytes_total", "Bytes sent over the transport.", transportLabels, nil)
+	transportRecvDesc     = prometheus.NewDesc("skywire_visor_transport_received_bytes_total", "Bytes received over the transport.", transportLabels, nil)
+	transportReadErrsDesc = prometheus.NewDesc("skywire_visor_transport_read_errors_total", "Failed reads of the transport.", transportLabels, nil)
+	transportWriteErrDesc = prometheus.NewDesc("skywire_visor_transport_write_errors_total", "Failed writes of the transport.", transportLabels, nil)
+
+	dmsgSessionsDesc = prometheus.NewDesc("skywire_visor_dmsg_sessions",
+		"Sessions with dmsg servers.", nil, nil)
+	dmsgSessionsWantedDesc = prometheus.NewDesc("skywire_visor_dmsg_sessions_wanted",
+		"Sessions with dmsg servers the visor tries to keep.", nil, nil)
+
+	appRunningDesc = prometheus.NewDesc("skywire_visor_app_running",
+		"Whether the app is running.", []string{"app"}, nil)
+	appRestartsDesc = prometheus.NewDesc("skywire_visor_app_restarts_total",
+		"Times the app was started again after its first start.", []string{"app"}, nil)
+)
+
+// visorMetrics exports metrics of a visor in the Prometheus format.
+// Most metrics are read from the visor when scraped, RPC calls are recorded as they are served.
+type visorMetrics struct {
+	visor    *Visor
+	registry *prometheus.Registry
+
+	rpcDurations *prometheus.HistogramVec
+	rpcErrors    *prometheus.CounterVec
+
+	mu     sync.Mutex
+	starts map[string]uint64 // times each app was started
+}
+
+func newVisorMetrics(v *Visor) *visorMetrics {
+	m := &visorMetrics{
+		visor:    v,
+		registry: prometheus.NewRegistry(),
+		rpcDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "skywire_visor_rpc_call_duration_seconds",
+			Help: "Durations of RPC calls served by the visor.",
+		}, []string{"method"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "skywire_visor_rpc_call_errors_total",
+			Help: "RPC calls served by the visor which returned an error.",
+		}, []string{"method"}),
+		starts: make(map[string]uint64),
+	}
+
+	m.registry.MustRegister(m, m.rpcDurations, m.rpcErrors)
+
+	return m
+}
+
+// handler serves the metrics to scrapers.
+func (m *visorMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// appStarted records a start of the app. It is a no-op if metrics are disabled.
+func (m *visorMetrics) appStarted(name string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.starts[name]++
+	m.mu.Unlock()
+}
+
+// Describe implements prometheus.Collector.
+func (m *visorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		routerPacketsReceivedDesc, routerPacketsForwardedDesc, routerPacketsDroppedDesc, routerRulesDesc,
+		transportSentDesc, transportRecvDesc, transportReadErrsDesc, transportWriteErrDesc,
+		dmsgSessionsDesc, dmsgSessionsWantedDesc,
+		appRunningDesc, appRestartsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *visorMetrics) Collect(ch chan<- prometheus.Metric) {
+	v := m.visor
+
+	if v.router != nil {
+		stats := v.router.PacketStats()
+
+		for typ, n := range map[string]uint64{
+			"data":       stats.DataReceived,
+			"close":      stats.CloseReceived,
+			"keep_alive": stats.KeepAliveReceived,
+		} {
+			ch <- prometheus.MustNewConstMetric(routerPacketsReceivedDesc, prometheus.CounterValue, float64(n), typ)
+		}
+
+		ch <- prometheus.MustNewConstMetric(routerPacketsForwardedDesc, prometheus.CounterValue, float64(stats.Forwarded))
+		ch <- prometheus.MustNewConstMetric(routerPacketsDroppedDesc, prometheus.CounterValue, float64(stats.Dropped))
+		ch <- prometheus.MustNewConstMetric(routerRulesDesc, prometheus.GaugeValue, float64(len(v.router.Rules())))
+	}
+
+	if v.tm != nil {
+		v.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+			labels := []string{tp.Entry.ID.String(), tp.Remote().Hex(), tp.Type()}
+			readErrs, writeErrs := tp.Errors()
+
+			ch <- prometheus.MustNewConstMetric(transportSentDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&tp.LogEntry.SentBytes)), labels...)
+			ch <- prometheus.MustNewConstMetric(transportRecvDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&tp.LogEntry.RecvBytes)), labels...)
+			ch <- prometheus.MustNewConstMetric(transportReadErrsDesc, prometheus.CounterValue, float64(readErrs), labels...)
+			ch <- prometheus.MustNewConstMetric(transportWriteErrDesc, prometheus.CounterValue, float64(writeErrs), labels...)
+
+			return true
+		})
+	}
+
+	if v.n != nil && v.n.Dmsg() != nil {
+		ch <- prometheus.MustNewConstMetric(dmsgSessionsDesc, prometheus.GaugeValue, float64(v.n.Dmsg().SessionCount()))
+	}
+
+	if v.conf != nil && v.conf.Dmsg != nil {
+		ch <- prometheus.MustNewConstMetric(dmsgSessionsWantedDesc, prometheus.GaugeValue, float64(v.conf.Dmsg.SessionsCount))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ac := range v.appConfs() {
+		name := ac.App
+		running := 0.0
+		if v.procManager != nil && v.procManager.Exists(name) {
+			running = 1
+		}
+
+		var restarts uint64
+		if n := m.starts[name]; n > 1 {
+			restarts = n - 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(appRunningDesc, prometheus.GaugeValue, running, name)
+		ch <- prometheus.MustNewConstMetric(appRestartsDesc, prometheus.CounterValue, float64(restarts), name)
+	}
+}
+
+// rpcServer returns rpcS, recording the calls it serves if metrics are enabled.
+func (m *visorMetrics) rpcServer(rpcS RPCServer) RPCServer {
+	if m == nil {
+		return rpcS
+	}
+
+	return &meteredRPCServer{RPCServer: rpcS, m: m}
+}
+
+type meteredRPCServer struct {
+	RPCServer
+	m *visorMetrics
+}
+
+func (s *meteredRPCServer) ServeCodec(codec rpc.ServerCodec) {
+	s.RPCServer.ServeCodec(&meteredCodec{ServerCodec: codec, m: s.m, calls: make(map[uint64]meteredCall)})
+}
+
+type meteredCall struct {
+	method string
+	start  time.Time
+}
+
+// meteredCodec records the durations of calls, from reading their headers until writing their responses.
+type meteredCodec struct {
+	rpc.ServerCodec
+	m *visorMetrics
+
+	mu    sync.Mutex
+	calls map[uint64]meteredCall
+}
+
+func (c *meteredCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.calls[r.Seq] = meteredCall{method: r.ServiceMethod, start: time.Now()}
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *meteredCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mu.Lock()
+	call, ok := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mu.Unlock()
+
+	// Calls rejected by net/rpc itself (such as of unknown methods) are not recorded,
+	// so that clients can't add labels at will.
+	if ok && !strings.HasPrefix(r.Error, "rpc: ") {
+		c.m.rpcDurations.WithLabelValues(call.method).Observe(time.Since(call.start).Seconds())
+
+		if r.Error != "" {
+			c.m.rpcErrors.WithLabelValues(call.method).Inc()
+		}
+	}
+
+	return c.ServerCodec.WriteResponse(r, body)
+}
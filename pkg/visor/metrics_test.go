@@ -0,0 +1,91 @@
+package visor
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app/appserver"
+	"github.com/skycoin/skywire/pkg/router"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+)
+
+func scrapeMetrics(t *testing.T, m *visorMetrics) string {
+	w := httptest.NewRecorder()
+	m.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestVisorMetrics(t *testing.T) {
+	r := &router.MockRouter{}
+	r.On("PacketStats").Return(router.PacketStats{DataReceived: 7, Forwarded: 5, Dropped: 2})
+	r.On("Rules").Return([]routing.Rule{nil, nil, nil})
+
+	pm := &appserver.MockProcManager{}
+	pm.On("Exists", "foo").Return(true)
+
+	v := &Visor{
+		router:      r,
+		appsConf:    map[string]AppConfig{"foo": {App: "foo"}},
+		procManager: pm,
+	}
+	v.metrics = newVisorMetrics(v)
+
+	for i := 0; i < 3; i++ {
+		v.metrics.appStarted("foo")
+	}
+
+	out := scrapeMetrics(t, v.metrics)
+
+	assert.Contains(t, out, `skywire_visor_router_packets_received_total{type="data"} 7`)
+	assert.Contains(t, out, `skywire_visor_router_packets_received_total{type="close"} 0`)
+	assert.Contains(t, out, "skywire_visor_router_packets_forwarded_total 5")
+	assert.Contains(t, out, "skywire_visor_router_packets_dropped_total 2")
+	assert.Contains(t, out, "skywire_visor_router_rules 3")
+	assert.Contains(t, out, `skywire_visor_app_running{app="foo"} 1`)
+	assert.Contains(t, out, `skywire_visor_app_restarts_total{app="foo"} 2`)
+}
+
+type metricsTestService struct{}
+
+func (metricsTestService) Ok(_ *struct{}, _ *struct{}) error   { return nil }
+func (metricsTestService) Fail(_ *struct{}, _ *struct{}) error { return errors.New("failed") }
+
+func TestVisorMetrics_RPC(t *testing.T) {
+	m := newVisorMetrics(&Visor{})
+
+	rpcS := rpc.NewServer()
+	require.NoError(t, rpcS.RegisterName("Test", metricsTestService{}))
+
+	connS, connC := net.Pipe()
+	go m.rpcServer(rpcS).ServeCodec(rpcutil.NewServerCodec(connS, logrus.New()))
+
+	c := rpc.NewClientWithCodec(rpcutil.NewClientCodec(connC))
+	defer func() { assert.NoError(t, c.Close()) }()
+
+	require.NoError(t, c.Call("Test.Ok", &struct{}{}, &struct{}{}))
+	require.Error(t, c.Call("Test.Fail", &struct{}{}, &struct{}{}))
+	require.Error(t, c.Call("Test.Unknown", &struct{}{}, &struct{}{}))
+
+	out := scrapeMetrics(t, m)
+
+	assert.Contains(t, out, `skywire_visor_rpc_call_duration_seconds_count{method="Test.Ok"} 1`)
+	assert.Contains(t, out, `skywire_visor_rpc_call_duration_seconds_count{method="Test.Fail"} 1`)
+	assert.Contains(t, out, `skywire_visor_rpc_call_errors_total{method="Test.Fail"} 1`)
+	assert.NotContains(t, out, `skywire_visor_rpc_call_errors_total{method="Test.Ok"}`)
+	assert.NotContains(t, out, "Test.Unknown")
+}
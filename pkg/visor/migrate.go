@@ -0,0 +1,100 @@
+package visor
+
+import (
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+)
+
+// ConfigVersion is the version of the visor config schema.
+// Configs of older versions are migrated to it by ParseAndMigrate.
+const ConfigVersion = "1.0"
+
+// configMigrations migrate visor configs from older versions, in order.
+var configMigrations = []cfgutil.Migration{ // nolint: gochecknoglobals
+	{From: "", To: "1.0", Migrate: migrateLegacyConfig},
+}
+
+// ParseAndMigrate decodes a JSON encoded visor config, migrating it from older versions, filling defaults and
+// validating it. Unknown fields are refused. It also returns the version the config was at.
+// Errors about the config are returned as *cfgutil.FieldError, which wrap ErrInvalidConfig.
+func ParseAndMigrate(raw []byte) (*Config, string, error) {
+	raw, from, err := cfgutil.Migrate(raw, "version", ConfigVersion, configMigrations)
+	if err != nil {
+		return nil, from, err
+	}
+
+	conf := new(Config)
+	if err := cfgutil.Decode(raw, conf); err != nil {
+		return nil, from, err
+	}
+
+	conf.fillDefaults()
+
+	if err := conf.Validate(); err != nil {
+		return nil, from, err
+	}
+
+	return conf, from, nil
+}
+
+// fillDefaults fills the unset fields which the visor would otherwise fill when it starts.
+func (c *Config) fillDefaults() {
+	c.Version = ConfigVersion
+
+	if c.Dmsg == nil {
+		c.Dmsg = DefaultDmsgConfig()
+	}
+
+	if c.Transport == nil {
+		c.Transport = DefaultTransportConfig()
+	} else if c.Transport.LogStore == nil {
+		c.Transport.LogStore = DefaultLogStoreConfig()
+	}
+
+	if c.Routing == nil {
+		c.Routing = DefaultRoutingConfig()
+	}
+
+	if c.AppsPath == "" {
+		c.AppsPath = DefaultAppsPath
+	}
+
+	if c.LocalPath == "" {
+		c.LocalPath = DefaultLocalPath
+	}
+
+	if c.LogLevel == "" {
+		c.LogLevel = DefaultLogLevel
+	}
+
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = DefaultTimeout
+	}
+
+	if c.AppServerAddr == "" {
+		c.AppServerAddr = appcommon.DefaultServerAddr
+	}
+}
+
+// migrateLegacyConfig migrates unversioned configs, which used the 'node', 'messaging' and 'trusted_nodes' fields.
+func migrateLegacyConfig(fields map[string]interface{}) error {
+	if node, ok := fields["node"].(map[string]interface{}); ok {
+		delete(fields, "node")
+
+		if _, ok := fields["key_pair"]; !ok {
+			fields["key_pair"] = map[string]interface{}{
+				"public_key": node["static_public_key"],
+				"secret_key": node["static_secret_key"],
+			}
+		}
+	}
+
+	if messaging, ok := fields["messaging"].(map[string]interface{}); ok {
+		cfgutil.Rename(messaging, "server_count", "sessions_count")
+	}
+
+	cfgutil.Rename(fields, "messaging", "dmsg")
+	cfgutil.Rename(fields, "trusted_nodes", "trusted_visors")
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package visor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/util/cfgutil"
+)
+
+func TestParseAndMigrate(t *testing.T) {
+	keys := NewKeyPair()
+
+	t.Run("legacy", func(t *testing.T) {
+		raw := `{
+			"node": {"static_public_key": "` + keys.PubKey.Hex() + `", "static_secret_key": "` + keys.SecKey.Hex() + `"},
+			"messaging": {"discovery": "http://dmsg.discovery", "server_count": 2},
+			"trusted_nodes": [],
+			"apps": [{"app": "skychat", "auto_start": true, "port": 1}]
+		}`
+
+		conf, version, err := ParseAndMigrate([]byte(raw))
+		require.NoError(t, err)
+		assert.Equal(t, "", version)
+		assert.Equal(t, ConfigVersion, conf.Version)
+		assert.Equal(t, keys, conf.KeyPair)
+		assert.Equal(t, "http://dmsg.discovery", conf.Dmsg.Discovery)
+		assert.Equal(t, 2, conf.Dmsg.SessionsCount)
+		assert.NotNil(t, conf.TrustedVisors)
+
+		// Defaults are filled.
+		assert.Equal(t, DefaultTransportConfig(), conf.Transport)
+		assert.Equal(t, DefaultLogLevel, conf.LogLevel)
+		assert.Equal(t, DefaultAppsPath, conf.AppsPath)
+	})
+
+	tests := []struct {
+		name string
+		raw  string
+		path string
+	}{
+		{"unknown field", `{"version": "1.0", "log_levle": "info"}`, "log_levle"},
+		{"wrong type", `{"version": "1.0", "apps": [{"app": "a", "port": "x"}]}`, "apps[0].port"},
+		{"invalid value", `{"version": "1.0", "apps": [{"app": "a", "port": 10}, {"app": "b", "port": 10}]}`, "apps[1].port"},
+		{"unsupported version", `{"version": "9.0"}`, "version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseAndMigrate([]byte(tt.raw))
+			assert.True(t, errors.Is(err, ErrInvalidConfig))
+
+			var fErr *cfgutil.FieldError
+			require.True(t, errors.As(err, &fErr))
+			assert.Equal(t, tt.path, fErr.Path)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrPowerDisabled is returned by Reboot and Shutdown if power management is not enabled in the config.
+var ErrPowerDisabled = errors.New("power management is disabled")
+
+// powerDelay lets the response to a power call reach the caller before the host goes down.
+const powerDelay = time.Second
+
+// Reboot reboots the host of the visor, if enabled in the 'power' config.
+// The reboot command runs shortly after Reboot returns, and its failure is only logged.
+func (visor *Visor) Reboot() error {
+	return visor.power("reboot", (*PowerConfig).rebootCommand)
+}
+
+// Shutdown powers off the host of the visor, if enabled in the 'power' config.
+// The shutdown command runs shortly after Shutdown returns, and its failure is only logged.
+func (visor *Visor) Shutdown() error {
+	return visor.power("shutdown", (*PowerConfig).shutdownCommand)
+}
+
+func (visor *Visor) power(action string, commandOf func(*PowerConfig) string) error {
+	conf := visor.conf.powerConfig()
+	if !conf.enabled() {
+		visor.logger.WithError(ErrPowerDisabled).Warnf("Refused to %s the host", action)
+		return ErrPowerDisabled
+	}
+
+	command := commandOf(conf)
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return fmt.Errorf("no command to %s the host", action)
+	}
+
+	visor.logger.Warnf("Running %q to %s the host", command, action)
+	ended := visor.uptime.end(ShutdownPower)
+
+	go func() {
+		time.Sleep(powerDelay)
+
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput() // nolint: gosec
+		if err != nil {
+			if ended {
+				visor.uptime.resume()
+			}
+
+			visor.logger.WithError(err).Errorf("Failed to %s the host: %s", action, out)
+		}
+	}()
+
+	return nil
+}
+
+// powerConfig returns a copy of the power config, which may be replaced by reloading the config.
+func (c *Config) powerConfig() *PowerConfig {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.Power == nil {
+		return nil
+	}
+
+	power := *c.Power
+
+	return &power
+}
+
+func (c *PowerConfig) enabled() bool {
+	return c != nil && c.Enabled
+}
+
+func (c *PowerConfig) rebootCommand() string {
+	if c != nil && c.RebootCommand != "" {
+		return c.RebootCommand
+	}
+
+	if runtime.GOOS == "windows" {
+		return "shutdown /r /t 0"
+	}
+
+	return "shutdown -r now"
+}
+
+func (c *PowerConfig) shutdownCommand() string {
+	if c != nil && c.ShutdownCommand != "" {
+		return c.ShutdownCommand
+	}
+
+	if runtime.GOOS == "windows" {
+		return "shutdown /s /t 0"
+	}
+
+	return "shutdown -h now"
+}
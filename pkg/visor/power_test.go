@@ -0,0 +1,57 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisorPower(t *testing.T) {
+	visor := &Visor{
+		conf:   &Config{},
+		logger: logging.MustGetLogger("test"),
+	}
+
+	assert.Equal(t, ErrPowerDisabled, visor.Reboot())
+	assert.Equal(t, ErrPowerDisabled, visor.Shutdown())
+
+	visor.conf.Power = &PowerConfig{}
+	assert.Equal(t, ErrPowerDisabled, visor.Reboot())
+
+	if runtime.GOOS == "windows" {
+		t.Skip("reboot command is a shell utility")
+	}
+
+	dir, err := ioutil.TempDir("", "power")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	marker := filepath.Join(dir, "rebooted")
+	visor.conf.Power = &PowerConfig{Enabled: true, RebootCommand: "touch " + marker}
+
+	require.NoError(t, visor.Reboot())
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(marker)
+		return err == nil
+	}, 5*powerDelay, powerDelay/10)
+}
+
+func TestPowerConfig_Commands(t *testing.T) {
+	var c *PowerConfig
+	assert.NotEmpty(t, c.rebootCommand())
+	assert.NotEmpty(t, c.shutdownCommand())
+
+	c = &PowerConfig{RebootCommand: "reboot", ShutdownCommand: "poweroff"}
+	assert.Equal(t, "reboot", c.rebootCommand())
+	assert.Equal(t, "poweroff", c.shutdownCommand())
+}
@@ -0,0 +1,87 @@
+package visor
+
+import (
+	"errors"
+	"net/rpc"
+	"strings"
+)
+
+// ErrReadOnlyHypervisor is returned to hypervisors with read-only access, when they call methods
+// which change the visor.
+var ErrReadOnlyHypervisor = errors.New("hypervisor only has read-only access to this visor")
+
+// readOnlyMethods are the RPC methods available to hypervisors with read-only access.
+// Methods which are not listed are refused, so that new methods are not exposed by mistake.
+var readOnlyMethods = map[string]bool{ // nolint: gochecknoglobals
+	"KeepAlive":              true,
+	"Health":                 true,
+	"Uptime":                 true,
+	"UptimeStats":            true,
+	"EnrollmentToken":        true,
+	"Summary":                true,
+	"Resources":              true,
+	"Diagnostics":            true,
+	"LogsSince":              true,
+	"LogsAfter":              true,
+	"Events":                 true,
+	"Apps":                   true,
+	"AppConnections":         true,
+	"AppUsage":               true,
+	"GetAppSettings":         true,
+	"TransportTypes":         true,
+	"TransportTypeInfos":     true,
+	"Transports":             true,
+	"Transport":              true,
+	"TransportPolicies":      true,
+	"TransportMaintenance":   true,
+	"DiscoverTransportsByPK": true,
+	"DiscoverTransportByID":  true,
+	"RoutingRules":           true,
+	"RoutingRule":            true,
+	"RouteGroups":            true,
+	"Config":                 true,
+	"HypervisorPKs":          true,
+	"UpdateAvailable":        true,
+}
+
+// readOnlyRPCServer serves RPC calls of hypervisors with read-only access.
+type readOnlyRPCServer struct {
+	RPCServer
+}
+
+func (s readOnlyRPCServer) ServeCodec(codec rpc.ServerCodec) {
+	s.RPCServer.ServeCodec(&readOnlyCodec{ServerCodec: codec})
+}
+
+// readOnlyCodec refuses calls of methods which are not in readOnlyMethods, before they reach the RPC gateway.
+// net/rpc replies with the error of reading the request body, so refused calls fail with ErrReadOnlyHypervisor.
+// Requests are read one at a time by rpc.Server, so no locking is needed.
+type readOnlyCodec struct {
+	rpc.ServerCodec
+	refuse bool // whether the request whose body is to be read is refused
+}
+
+func (c *readOnlyCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+
+	c.refuse = !readOnlyMethods[strings.TrimPrefix(r.ServiceMethod, RPCPrefix+".")]
+
+	return nil
+}
+
+func (c *readOnlyCodec) ReadRequestBody(body interface{}) error {
+	if !c.refuse || body == nil {
+		return c.ServerCodec.ReadRequestBody(body)
+	}
+
+	c.refuse = false
+
+	// Discard the arguments.
+	if err := c.ServerCodec.ReadRequestBody(nil); err != nil {
+		return err
+	}
+
+	return ErrReadOnlyHypervisor
+}
@@ -0,0 +1,200 @@
+package visor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
+	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/snet/stcp"
+	"github.com/skycoin/skywire/pkg/transport"
+	trClient "github.com/skycoin/skywire/pkg/transport-discovery/client"
+)
+
+// ReloadConfig re-reads the config file of the visor and applies the changes which don't need a restart:
+// the log level, transport discovery and route finder URLs, STCP and KCP public key tables and app settings.
+// Apps which are newly set to auto start are started. Existing transports are kept.
+// Environment variable overrides are applied again, as with ApplyEnv.
+// Other changes are saved in the visor config, but only take effect after the visor restarts.
+func (visor *Visor) ReloadConfig() error {
+	if visor.conf.Path == nil {
+		return ErrNoConfigPath
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Clean(*visor.conf.Path))
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	conf, _, err := ParseAndMigrate(raw)
+	if err != nil {
+		return err
+	}
+
+	overridden, err := conf.ApplyEnv(os.Environ())
+	if err != nil {
+		return err
+	}
+
+	if len(overridden) > 0 {
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// The transport discovery client is created first, as it connects to the discovery.
+	var tpDiscC transport.DiscoveryClient
+	if visor.tpDisc != nil && conf.Transport != nil && conf.Transport.Discovery != visor.tpDisc.URL() {
+		keys := visor.conf.Keys()
+		if tpDiscC, err = trClient.NewHTTP(conf.Transport.Discovery, keys.PubKey, keys.SecKey); err != nil {
+			return fmt.Errorf("invalid transport discovery config: %w", err)
+		}
+	}
+
+	visor.logger.Infof("Reloading config from %s", *visor.conf.Path)
+
+	visor.conf.set(conf)
+
+	if lvl, err := logging.LevelFromString(conf.LogLevel); err == nil {
+		visor.Logger.SetLevel(lvl)
+	}
+
+	if tpDiscC != nil {
+		visor.tpDisc.set(conf.Transport.Discovery, tpDiscC)
+	}
+
+	if visor.routeFinder != nil && conf.Routing != nil {
+		visor.routeFinder.setURL(conf.Routing.RouteFinder, time.Duration(conf.Routing.RouteFinderTimeout))
+	}
+
+	if visor.n != nil && visor.n.STcp() != nil && conf.STCP != nil {
+		visor.n.STcp().SetTable(stcp.NewTable(conf.STCP.PubKeyTable))
+	}
+
+	if visor.n != nil && visor.n.KCP() != nil && conf.KCP != nil {
+		visor.n.KCP().SetTable(stcp.NewTable(conf.KCP.PubKeyTable))
+	}
+
+	return visor.reloadApps()
+}
+
+// reloadApps replaces the app configs with those of the visor config,
+// and starts apps which were not auto started before and are not running.
+func (visor *Visor) reloadApps() error {
+	appsConf, err := visor.conf.AppsConfig()
+	if err != nil {
+		return err
+	}
+
+	prev := visor.setAppsConf(appsConf)
+
+	for _, ac := range appsConf {
+		if !ac.AutoStart || prev[ac.App].AutoStart || visor.procManager.Exists(ac.App) {
+			continue
+		}
+
+		go func(a AppConfig) {
+			if err := visor.runApp(a, nil); err != nil {
+				visor.logger.
+					WithError(err).
+					WithField("app_name", a.App).
+					Warn("App stopped.")
+			}
+		}(ac)
+	}
+
+	return nil
+}
+
+// tpDiscovery is a transport discovery client which can be pointed to another URL when the config is reloaded.
+type tpDiscovery struct {
+	mu  sync.RWMutex
+	url string
+	c   transport.DiscoveryClient
+}
+
+func newTpDiscovery(url string, c transport.DiscoveryClient) *tpDiscovery {
+	return &tpDiscovery{url: url, c: c}
+}
+
+// URL returns the URL of the transport discovery.
+func (d *tpDiscovery) URL() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.url
+}
+
+func (d *tpDiscovery) set(url string, c transport.DiscoveryClient) {
+	d.mu.Lock()
+	d.url, d.c = url, c
+	d.mu.Unlock()
+}
+
+func (d *tpDiscovery) client() transport.DiscoveryClient {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.c
+}
+
+func (d *tpDiscovery) RegisterTransports(ctx context.Context, entries ...*transport.SignedEntry) error {
+	return d.client().RegisterTransports(ctx, entries...)
+}
+
+func (d *tpDiscovery) GetTransportByID(ctx context.Context, id uuid.UUID) (*transport.EntryWithStatus, error) {
+	return d.client().GetTransportByID(ctx, id)
+}
+
+func (d *tpDiscovery) GetTransportsByEdge(ctx context.Context, pk cipher.PubKey) ([]*transport.EntryWithStatus, error) {
+	return d.client().GetTransportsByEdge(ctx, pk)
+}
+
+func (d *tpDiscovery) DeleteTransport(ctx context.Context, id uuid.UUID) error {
+	return d.client().DeleteTransport(ctx, id)
+}
+
+func (d *tpDiscovery) UpdateStatuses(ctx context.Context, statuses ...*transport.Status) ([]*transport.EntryWithStatus, error) {
+	return d.client().UpdateStatuses(ctx, statuses...)
+}
+
+// routeFinder is a route finder client which can be pointed to another URL when the config is reloaded.
+type routeFinder struct {
+	mu      sync.RWMutex
+	url     string
+	timeout time.Duration
+	c       rfclient.Client
+}
+
+func newRouteFinder(url string, timeout time.Duration) *routeFinder {
+	return &routeFinder{url: url, timeout: timeout, c: rfclient.NewHTTP(url, timeout)}
+}
+
+func (rf *routeFinder) setURL(url string, timeout time.Duration) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if url == rf.url && timeout == rf.timeout {
+		return
+	}
+
+	rf.url, rf.timeout, rf.c = url, timeout, rfclient.NewHTTP(url, timeout)
+}
+
+func (rf *routeFinder) FindRoutes(ctx context.Context, rts []routing.PathEdges,
+	opts *rfclient.RouteOptions) (map[routing.PathEdges][]routing.Path, error) {
+	rf.mu.RLock()
+	c := rf.c
+	rf.mu.RUnlock()
+
+	return c.FindRoutes(ctx, rts, opts)
+}
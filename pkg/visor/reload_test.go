@@ -0,0 +1,106 @@
+package visor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/app/appserver"
+)
+
+func TestVisor_ReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	path := filepath.Join(dir, "config.json")
+
+	writeConf := func(c *Config) {
+		raw, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, raw, 0600))
+	}
+
+	keys := NewKeyPair()
+	conf := &Config{
+		Path:      &path,
+		KeyPair:   keys,
+		Transport: DefaultTransportConfig(),
+		Routing:   DefaultRoutingConfig(),
+		LogLevel:  "info",
+		Apps:      []AppConfig{{App: "foo", AutoStart: true, Port: 10}},
+	}
+
+	pm := &appserver.MockProcManager{}
+	pm.On("Exists", "foo").Return(true)
+
+	logger := logging.NewMasterLogger()
+	v := &Visor{
+		conf:        conf,
+		Logger:      logger,
+		logger:      logger.PackageLogger("test"),
+		appsConf:    map[string]AppConfig{"foo": conf.Apps[0]},
+		procManager: pm,
+		tpDisc:      newTpDiscovery(conf.Transport.Discovery, nil),
+		routeFinder: newRouteFinder(conf.Routing.RouteFinder, time.Second),
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+		assert.True(t, errors.Is(v.ReloadConfig(), ErrInvalidConfig))
+	})
+
+	tpd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"next_nonce": 1}`)) // nolint: errcheck
+	}))
+	defer tpd.Close()
+
+	t.Run("unreachable transport discovery", func(t *testing.T) {
+		writeConf(&Config{
+			Transport: &TransportConfig{Discovery: "http://127.0.0.1:1"},
+			Routing:   DefaultRoutingConfig(),
+			LogLevel:  "debug",
+		})
+
+		require.Error(t, v.ReloadConfig())
+		assert.Equal(t, "info", v.conf.LogLevel, "config is not applied")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		writeConf(&Config{
+			KeyPair:   NewKeyPair(),
+			Transport: &TransportConfig{Discovery: tpd.URL},
+			Routing:   &RoutingConfig{RouteFinder: "http://rf.example.com", RouteFinderTimeout: Duration(time.Second)},
+			LogLevel:  "debug",
+			Apps:      []AppConfig{{App: "foo", AutoStart: true, Port: 10, Args: []string{"-a"}}},
+		})
+
+		require.NoError(t, v.ReloadConfig())
+
+		assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+		assert.Equal(t, tpd.URL, v.tpDisc.URL())
+		assert.NotNil(t, v.tpDisc.client())
+		assert.Equal(t, "http://rf.example.com", v.routeFinder.url)
+		assert.Equal(t, []string{"-a"}, v.appsConf["foo"].Args)
+		assert.Equal(t, keys, v.conf.KeyPair, "key pair is kept")
+	})
+
+	t.Run("no config path", func(t *testing.T) {
+		v := &Visor{conf: &Config{}}
+		assert.Equal(t, ErrNoConfigPath, v.ReloadConfig())
+	})
+}
@@ -0,0 +1,177 @@
+package visor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+)
+
+// ErrResourcesUnsupported is returned when resources of the host can't be read on its platform.
+var ErrResourcesUnsupported = errors.New("resource reporting is not supported on this platform")
+
+// Resources is a snapshot of the resources of the host of a visor.
+type Resources struct {
+	Time time.Time `json:"time"`
+	CPUs int       `json:"cpus"`
+
+	// Load averages over 1, 5 and 15 minutes.
+	Load1  float64 `json:"load_1"`
+	Load5  float64 `json:"load_5"`
+	Load15 float64 `json:"load_15"`
+
+	// Memory in bytes.
+	MemTotal     uint64 `json:"mem_total"`
+	MemAvailable uint64 `json:"mem_available"`
+	SwapTotal    uint64 `json:"swap_total"`
+	SwapFree     uint64 `json:"swap_free"`
+
+	Disks []DiskUsage   `json:"disks"`
+	NICs  []NICCounters `json:"nics"`
+}
+
+// DiskUsage is the usage of the filesystem of a directory used by the visor, in bytes.
+type DiskUsage struct {
+	Path      string `json:"path"`
+	Total     uint64 `json:"total"`
+	Free      uint64 `json:"free"`
+	Available uint64 `json:"available"` // Free bytes available to the visor's user.
+}
+
+// NICCounters are the counters of a network interface since the host booted.
+type NICCounters struct {
+	Name        string `json:"name"`
+	RecvBytes   uint64 `json:"recv_bytes"`
+	RecvPackets uint64 `json:"recv_packets"`
+	RecvErrors  uint64 `json:"recv_errors"`
+	RecvDropped uint64 `json:"recv_dropped"`
+	SentBytes   uint64 `json:"sent_bytes"`
+	SentPackets uint64 `json:"sent_packets"`
+	SentErrors  uint64 `json:"sent_errors"`
+	SentDropped uint64 `json:"sent_dropped"`
+}
+
+// Resources returns a snapshot of the resources of the host.
+func (r *RPC) Resources(_ *struct{}, out *Resources) (err error) {
+	defer rpcutil.LogCall(r.log, "Resources", nil)(out, &err)
+
+	res, err := r.visor.resources()
+	if err != nil {
+		return err
+	}
+
+	*out = *res
+
+	return nil
+}
+
+// resources reads the resources of the host, with the usage of the filesystems of the local and apps directories.
+func (visor *Visor) resources() (*Resources, error) {
+	var paths []string
+	for _, path := range []string{visor.localPath, visor.appsPath} {
+		if path != "" && (len(paths) == 0 || paths[0] != path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return hostResources(paths)
+}
+
+// parseLoadAvg parses load averages in the format of /proc/loadavg.
+func parseLoadAvg(r io.Reader, res *Resources) error {
+	var load [3]float64
+	if _, err := fmt.Fscan(r, &load[0], &load[1], &load[2]); err != nil {
+		return fmt.Errorf("failed to parse load averages: %w", err)
+	}
+
+	res.Load1, res.Load5, res.Load15 = load[0], load[1], load[2]
+
+	return nil
+}
+
+// parseMemInfo parses memory usage in the format of /proc/meminfo.
+func parseMemInfo(r io.Reader, res *Resources) error {
+	fields := map[string]*uint64{
+		"MemTotal":     &res.MemTotal,
+		"MemAvailable": &res.MemAvailable,
+		"SwapTotal":    &res.SwapTotal,
+		"SwapFree":     &res.SwapFree,
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Lines are like "MemTotal:       16314208 kB".
+		parts := strings.Fields(s.Text())
+		if len(parts) < 2 {
+			continue
+		}
+
+		field, ok := fields[strings.TrimSuffix(parts[0], ":")]
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", parts[0], err)
+		}
+
+		if len(parts) > 2 && parts[2] == "kB" {
+			v *= 1024
+		}
+
+		*field = v
+	}
+
+	return s.Err()
+}
+
+// parseNetDev parses counters of network interfaces in the format of /proc/net/dev.
+func parseNetDev(r io.Reader) ([]NICCounters, error) {
+	var nics []NICCounters
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Lines are like "  eth0: 1234 10 0 0 0 0 0 0 5678 20 0 0 0 0 0 0"; the two header lines have no counters.
+		i := strings.IndexByte(s.Text(), ':')
+		if i < 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(s.Text()[:i])
+		fields := strings.Fields(s.Text()[i+1:])
+
+		if len(fields) < 12 {
+			continue
+		}
+
+		var counters [12]uint64
+		for j := range counters {
+			v, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse counters of %s: %w", name, err)
+			}
+
+			counters[j] = v
+		}
+
+		nics = append(nics, NICCounters{
+			Name:        name,
+			RecvBytes:   counters[0],
+			RecvPackets: counters[1],
+			RecvErrors:  counters[2],
+			RecvDropped: counters[3],
+			SentBytes:   counters[8],
+			SentPackets: counters[9],
+			SentErrors:  counters[10],
+			SentDropped: counters[11],
+		})
+	}
+
+	return nics, s.Err()
+}
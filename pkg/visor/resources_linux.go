@@ -0,0 +1,62 @@
+package visor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// hostResources reads the resources of the host from procfs, with the usage of the filesystems of paths.
+func hostResources(paths []string) (*Resources, error) {
+	res := &Resources{Time: time.Now(), CPUs: runtime.NumCPU()}
+
+	parseFile := func(name string, parse func(r io.Reader) error) error {
+		f, err := os.Open(name) // nolint: gosec
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = f.Close() // nolint: errcheck
+		}()
+
+		return parse(f)
+	}
+
+	if err := parseFile("/proc/loadavg", func(r io.Reader) error { return parseLoadAvg(r, res) }); err != nil {
+		return nil, err
+	}
+
+	if err := parseFile("/proc/meminfo", func(r io.Reader) error { return parseMemInfo(r, res) }); err != nil {
+		return nil, err
+	}
+
+	err := parseFile("/proc/net/dev", func(r io.Reader) (err error) {
+		res.NICs, err = parseNetDev(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return nil, fmt.Errorf("failed to stat filesystem of %s: %w", path, err)
+		}
+
+		bsize := uint64(st.Bsize) // nolint: unconvert
+
+		res.Disks = append(res.Disks, DiskUsage{
+			Path:      path,
+			Total:     st.Blocks * bsize,
+			Free:      st.Bfree * bsize,
+			Available: st.Bavail * bsize,
+		})
+	}
+
+	return res, nil
+}
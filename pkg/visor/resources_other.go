@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package visor
+
+// hostResources reads the resources of the host, which is only supported on Linux.
+func hostResources(_ []string) (*Resources, error) {
+	return nil, ErrResourcesUnsupported
+}
@@ -0,0 +1,52 @@
+package visor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResources(t *testing.T) {
+	var res Resources
+
+	require.NoError(t, parseLoadAvg(strings.NewReader("0.52 0.58 0.59 2/1040 12345\n"), &res))
+	assert.Equal(t, 0.52, res.Load1)
+	assert.Equal(t, 0.58, res.Load5)
+	assert.Equal(t, 0.59, res.Load15)
+
+	assert.Error(t, parseLoadAvg(strings.NewReader(""), &res))
+
+	meminfo := `MemTotal:        2048 kB
+MemFree:          512 kB
+MemAvailable:    1024 kB
+SwapTotal:          0 kB
+SwapFree:           0 kB
+HugePages_Total:    0
+`
+	require.NoError(t, parseMemInfo(strings.NewReader(meminfo), &res))
+	assert.Equal(t, uint64(2048*1024), res.MemTotal)
+	assert.Equal(t, uint64(1024*1024), res.MemAvailable)
+	assert.Equal(t, uint64(0), res.SwapTotal)
+
+	netdev := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 5000000    4000    1    2    0     0          0         0  3000000    3500    3    4    0     0       0          0
+`
+	nics, err := parseNetDev(strings.NewReader(netdev))
+	require.NoError(t, err)
+	require.Len(t, nics, 2)
+	assert.Equal(t, NICCounters{
+		Name:        "eth0",
+		RecvBytes:   5000000,
+		RecvPackets: 4000,
+		RecvErrors:  1,
+		RecvDropped: 2,
+		SentBytes:   3000000,
+		SentPackets: 3500,
+		SentErrors:  3,
+		SentDropped: 4,
+	}, nics[1])
+}
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"net/rpc"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,11 +14,14 @@ import (
 	"github.com/skycoin/dmsg/cipher"
 
 	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/snet"
 	"github.com/skycoin/skywire/pkg/transport"
 	"github.com/skycoin/skywire/pkg/util/buildinfo"
 	"github.com/skycoin/skywire/pkg/util/rpcutil"
 	"github.com/skycoin/skywire/pkg/util/updater"
+	"github.com/skycoin/skywire/pkg/util/wol"
 )
 
 const (
@@ -36,19 +38,24 @@ var (
 
 	// ErrMalformedRestartContext is returned when restart context is malformed.
 	ErrMalformedRestartContext = errors.New("restart context is malformed")
+
+	// ErrRPCTimeout is returned by RPC clients when a call is not replied to in time.
+	ErrRPCTimeout = errors.New("rpc call timed out")
 )
 
 // RPC defines RPC methods for Visor.
 type RPC struct {
-	visor *Visor
-	log   logrus.FieldLogger
+	visor           *Visor
+	log             logrus.FieldLogger
+	enrollmentToken string // presented to the remote hypervisor
 }
 
-func newRPCServer(v *Visor, remoteName string) (*rpc.Server, error) {
+func newRPCServer(v *Visor, remoteName, enrollmentToken string) (*rpc.Server, error) {
 	rpcS := rpc.NewServer()
 	rpcG := &RPC{
-		visor: v,
-		log:   v.Logger.PackageLogger("visor_rpc:" + remoteName),
+		visor:           v,
+		log:             v.Logger.PackageLogger("visor_rpc:" + remoteName),
+		enrollmentToken: enrollmentToken,
 	}
 
 	if err := rpcS.RegisterName(RPCPrefix, rpcG); err != nil {
@@ -62,6 +69,18 @@ func newRPCServer(v *Visor, remoteName string) (*rpc.Server, error) {
 	<<< NODE HEALTH >>>
 */
 
+// KeepAliveIn is input for KeepAlive.
+type KeepAliveIn struct {
+	Timeout time.Duration // The connection is closed if no keepalive is received for this long.
+}
+
+// KeepAlive is called periodically by hypervisors to check that the connection is alive.
+// Visors close connections which stop receiving keepalives, and redial the hypervisor.
+// Calls are not logged, as they are frequent.
+func (r *RPC) KeepAlive(_ *KeepAliveIn, _ *struct{}) error {
+	return nil
+}
+
 // HealthInfo carries information about visor's external services health represented as http status codes
 type HealthInfo struct {
 	TransportDiscovery int `json:"transport_discovery"`
@@ -104,6 +123,22 @@ func (r *RPC) Uptime(_ *struct{}, out *float64) (err error) {
 	return nil
 }
 
+// UptimeStats returns the uptime of the visor across its restarts, its boot count and the reason of its last shutdown.
+func (r *RPC) UptimeStats(_ *struct{}, out *UptimeStats) (err error) {
+	defer rpcutil.LogCall(r.log, "UptimeStats", nil)(out, &err)
+
+	*out = *r.visor.UptimeStats()
+	return nil
+}
+
+// EnrollmentToken returns the enrollment token configured for the calling hypervisor, if any.
+func (r *RPC) EnrollmentToken(_ *struct{}, out *string) (err error) {
+	defer rpcutil.LogCall(r.log, "EnrollmentToken", nil)(nil, &err)
+
+	*out = r.enrollmentToken
+	return nil
+}
+
 /*
 	<<< APP LOGS >>>
 */
@@ -114,18 +149,20 @@ type AppLogsRequest struct {
 	TimeStamp time.Time `json:"time_stamp"`
 	// AppName should match the app name in visor config
 	AppName string `json:"app_name"`
+	// Filter restricts the level and number of the logs
+	Filter app.LogFilter `json:"filter"`
 }
 
-// LogsSince returns all logs from an specific app since the timestamp
+// LogsSince returns the logs from an specific app since the timestamp which pass the filter
 func (r *RPC) LogsSince(in *AppLogsRequest, out *[]string) (err error) {
 	defer rpcutil.LogCall(r.log, "LogsSince", in)(out, &err)
 
-	ls, err := app.NewLogStore(filepath.Join(r.visor.dir(), in.AppName), in.AppName, "bbolt")
+	ls, err := r.visor.appLogStore(in.AppName)
 	if err != nil {
 		return err
 	}
 
-	res, err := ls.LogsSince(in.TimeStamp)
+	res, err := ls.LogsSince(in.TimeStamp, in.Filter)
 	if err != nil {
 		return err
 	}
@@ -134,6 +171,43 @@ func (r *RPC) LogsSince(in *AppLogsRequest, out *[]string) (err error) {
 	return nil
 }
 
+// AppLogsAfterIn is input for LogsAfter.
+type AppLogsAfterIn struct {
+	AppName string `json:"app_name"`
+	Seq     uint64 `json:"seq"` // Sequence number of the last log already obtained, 0 for all logs.
+}
+
+// LogsAfter returns the logs of an app with sequence numbers greater than the given one.
+func (r *RPC) LogsAfter(in *AppLogsAfterIn, out *[]app.LogEntry) (err error) {
+	defer rpcutil.LogCall(r.log, "LogsAfter", in)(nil, &err)
+
+	ls, err := r.visor.appLogStore(in.AppName)
+	if err != nil {
+		return err
+	}
+
+	*out, err = ls.LogsAfter(in.Seq)
+	return err
+}
+
+// EventsIn is input for Events.
+type EventsIn struct {
+	Cursor  uint64        // Cursor returned by the last call, 0 for all the events kept.
+	Kinds   []string      // Kinds of the events to return, all kinds if empty.
+	Timeout time.Duration // How long to wait for events if there are none, capped to MaxEventsTimeout.
+}
+
+// Events returns the events of the visor after the cursor, waiting for some if there are none.
+func (r *RPC) Events(in *EventsIn, out *EventsOut) (err error) {
+	// Not logged, as it is called repeatedly by subscribers.
+	o, err := r.visor.Events(in.Cursor, in.Kinds, in.Timeout)
+	if o != nil {
+		*out = *o
+	}
+
+	return err
+}
+
 /*
 	<<< NODE SUMMARY >>>
 */
@@ -144,6 +218,7 @@ type TransportSummary struct {
 	Local   cipher.PubKey       `json:"local_pk"`
 	Remote  cipher.PubKey       `json:"remote_pk"`
 	Type    string              `json:"type"`
+	Public  bool                `json:"public"`
 	Log     *transport.LogEntry `json:"log,omitempty"`
 	IsSetup bool                `json:"is_setup"`
 }
@@ -155,6 +230,7 @@ func newTransportSummary(tm *transport.Manager, tp *transport.ManagedTransport,
 		Local:   tm.Local(),
 		Remote:  tp.Remote(),
 		Type:    tp.Type(),
+		Public:  tp.Entry.Public,
 		IsSetup: isSetup,
 	}
 	if includeLogs {
@@ -171,6 +247,9 @@ type Summary struct {
 	Apps            []*AppState         `json:"apps"`
 	Transports      []*TransportSummary `json:"transports"`
 	RoutesCount     int                 `json:"routes_count"`
+	Resources       *Resources          `json:"resources,omitempty"` // Unset if resources of the host can't be read.
+	Drain           *DrainStatus        `json:"drain,omitempty"`     // Set while the visor is draining.
+	Uptime          *UptimeStats        `json:"uptime,omitempty"`    // Unset for visors which don't track it.
 }
 
 // Summary provides a summary of the AppNode.
@@ -190,7 +269,15 @@ func (r *RPC) Summary(_ *struct{}, out *Summary) (err error) {
 		Apps:            r.visor.Apps(),
 		Transports:      summaries,
 		RoutesCount:     r.visor.router.RoutesCount(),
+		Drain:           r.visor.drainStatus(),
+		Uptime:          r.visor.UptimeStats(),
+	}
+
+	if out.Resources, err = r.visor.resources(); err != nil {
+		r.log.WithError(err).Debug("Failed to read resources of the host.")
+		err = nil
 	}
+
 	return nil
 }
 
@@ -206,6 +293,28 @@ func (r *RPC) Apps(_ *struct{}, reply *[]*AppState) (err error) {
 	return nil
 }
 
+// AppConnections returns the live connections of App with provided name.
+func (r *RPC) AppConnections(name *string, out *[]appserver.ConnSummary) (err error) {
+	defer rpcutil.LogCall(r.log, "AppConnections", name)(out, &err)
+
+	*out, err = r.visor.AppConnections(*name)
+	return err
+}
+
+// AppUsage returns the resource usage of the running App with provided name.
+func (r *RPC) AppUsage(name *string, out *appserver.Usage) (err error) {
+	defer rpcutil.LogCall(r.log, "AppUsage", name)(out, &err)
+
+	usage, err := r.visor.AppUsage(*name)
+	if err != nil {
+		return err
+	}
+
+	*out = *usage
+
+	return nil
+}
+
 // StartApp start App with provided name.
 func (r *RPC) StartApp(name *string, _ *struct{}) (err error) {
 	defer rpcutil.LogCall(r.log, "StartApp", name)(nil, &err)
@@ -233,18 +342,52 @@ func (r *RPC) SetAutoStart(in *SetAutoStartIn, _ *struct{}) (err error) {
 	return r.visor.setAutoStart(in.AppName, in.AutoStart)
 }
 
-// SetSocksPassword sets password for skysocks.
-func (r *RPC) SetSocksPassword(in *string, _ *struct{}) (err error) {
-	defer rpcutil.LogCall(r.log, "SetSocksPassword", in)(nil, &err)
+// SetAppArgsIn is input for SetAppArgs.
+type SetAppArgsIn struct {
+	AppName string
+	Args    []string
+}
+
+// SetAppArgs sets the launch arguments of an app, restarting it if it is running.
+func (r *RPC) SetAppArgs(in *SetAppArgsIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetAppArgs", in)(nil, &err)
 
-	return r.visor.setSocksPassword(*in)
+	return r.visor.setAppArgs(in.AppName, in.Args)
 }
 
-// SetSocksClientPK sets PK for skysocks-client.
-func (r *RPC) SetSocksClientPK(in *cipher.PubKey, _ *struct{}) (err error) {
-	defer rpcutil.LogCall(r.log, "SetSocksClientPK", in)(nil, &err)
+// SetAppEnvIn is input for SetAppEnv.
+type SetAppEnvIn struct {
+	AppName string
+	Env     map[string]string
+}
+
+// SetAppEnv sets the additional environment of an app, restarting it if it is running.
+func (r *RPC) SetAppEnv(in *SetAppEnvIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetAppEnv", in)(nil, &err)
 
-	return r.visor.setSocksClientPK(*in)
+	return r.visor.setAppEnv(in.AppName, in.Env)
+}
+
+// SetAppSettingIn is input for SetAppSetting.
+type SetAppSettingIn struct {
+	AppName string
+	Key     string
+	Value   string
+}
+
+// SetAppSetting sets a setting of an app, restarting it if it is running.
+func (r *RPC) SetAppSetting(in *SetAppSettingIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetAppSetting", in)(nil, &err)
+
+	return r.visor.setAppSetting(in.AppName, in.Key, in.Value)
+}
+
+// GetAppSettings returns the settings of an app.
+func (r *RPC) GetAppSettings(in *string, out *map[string]string) (err error) {
+	defer rpcutil.LogCall(r.log, "GetAppSettings", in)(out, &err)
+
+	*out, err = r.visor.appSettings(*in)
+	return err
 }
 
 /*
@@ -259,6 +402,31 @@ func (r *RPC) TransportTypes(_ *struct{}, out *[]string) (err error) {
 	return nil
 }
 
+// TransportTypeInfo is a transport type supported by the visor, along with its capabilities.
+type TransportTypeInfo struct {
+	Type string `json:"type"`
+	snet.Capabilities
+	Known bool `json:"known"` // Whether capabilities are registered for the type.
+}
+
+// TransportTypeInfos lists all transport types supported by the Visor, along with their capabilities.
+func (r *RPC) TransportTypeInfos(_ *struct{}, out *[]TransportTypeInfo) (err error) {
+	defer rpcutil.LogCall(r.log, "TransportTypeInfos", nil)(out, &err)
+
+	*out = makeTransportTypeInfos(r.visor.tm.Networks())
+	return nil
+}
+
+func makeTransportTypeInfos(types []string) []TransportTypeInfo {
+	infos := make([]TransportTypeInfo, 0, len(types))
+	for _, t := range types {
+		c, ok := snet.NetworkCapabilities(t)
+		infos = append(infos, TransportTypeInfo{Type: t, Capabilities: c, Known: ok})
+	}
+
+	return infos
+}
+
 // TransportsIn is input for Transports.
 type TransportsIn struct {
 	FilterTypes   []string
@@ -333,6 +501,10 @@ func (r *RPC) AddTransport(in *AddTransportIn, out *TransportSummary) (err error
 		defer cancel()
 	}
 
+	if name, ok := r.visor.tpPolicies.activePolicy(in.RemotePK, in.TpType, time.Now()); ok {
+		return fmt.Errorf("%w: %q", ErrTransportDisabledByPolicy, name)
+	}
+
 	tp, err := r.visor.tm.SaveTransport(ctx, in.RemotePK, in.TpType)
 	if err != nil {
 		return err
@@ -350,6 +522,38 @@ func (r *RPC) RemoveTransport(tid *uuid.UUID, _ *struct{}) (err error) {
 	return nil
 }
 
+// TransportPolicies returns the transport policies of the visor, and their state.
+func (r *RPC) TransportPolicies(_ *struct{}, out *TransportPolicyStatus) (err error) {
+	defer rpcutil.LogCall(r.log, "TransportPolicies", nil)(out, &err)
+
+	*out = *r.visor.TransportPolicies()
+
+	return nil
+}
+
+// SetTransportPolicies replaces the transport policies of the visor.
+func (r *RPC) SetTransportPolicies(in *[]TransportPolicy, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetTransportPolicies", in)(nil, &err)
+
+	return r.visor.SetTransportPolicies(*in)
+}
+
+// TransportMaintenance returns the transport targets of the visor, and their state.
+func (r *RPC) TransportMaintenance(_ *struct{}, out *TransportMaintenanceStatus) (err error) {
+	defer rpcutil.LogCall(r.log, "TransportMaintenance", nil)(out, &err)
+
+	*out = *r.visor.TransportMaintenance()
+
+	return nil
+}
+
+// SetTransportTargets replaces the transport targets of the visor.
+func (r *RPC) SetTransportTargets(in *[]TransportTarget, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetTransportTargets", in)(nil, &err)
+
+	return r.visor.SetTransportTargets(*in)
+}
+
 /*
 	<<< AVAILABLE TRANSPORTS >>>
 */
@@ -425,6 +629,49 @@ func (r *RPC) RemoveRoutingRule(key *routing.RouteID, _ *struct{}) (err error) {
 	return nil
 }
 
+// RemoveRoutingRulesIn is input for RemoveRoutingRules.
+type RemoveRoutingRulesIn struct {
+	Keys    []routing.RouteID
+	Expired bool // Whether to also remove rules of which keep-alive timeout is exceeded.
+}
+
+// RemoveRoutingRules removes the RoutingRules of given RouteID keys, and returns the keys of removed rules.
+func (r *RPC) RemoveRoutingRules(in *RemoveRoutingRulesIn, out *[]routing.RouteID) (err error) {
+	defer rpcutil.LogCall(r.log, "RemoveRoutingRules", in)(out, &err)
+
+	removed := existingRuleKeys(r.visor.router.Rules(), in.Keys)
+	r.visor.router.DelRules(removed)
+
+	if in.Expired {
+		for _, rule := range r.visor.router.RemoveExpiredRules() {
+			removed = append(removed, rule.KeyRouteID())
+		}
+	}
+
+	*out = removed
+
+	return nil
+}
+
+// existingRuleKeys returns the keys which are keys of rules.
+func existingRuleKeys(rules []routing.Rule, keys []routing.RouteID) []routing.RouteID {
+	exists := make(map[routing.RouteID]bool, len(rules))
+	for _, rule := range rules {
+		exists[rule.KeyRouteID()] = true
+	}
+
+	out := make([]routing.RouteID, 0, len(keys))
+
+	for _, key := range keys {
+		if exists[key] {
+			out = append(out, key)
+			exists[key] = false // ignore duplicates
+		}
+	}
+
+	return out
+}
+
 /*
 	<<< ROUTEGROUPS MANAGEMENT >>>
 	>>> TODO(evanlinjin): Implement.
@@ -488,14 +735,139 @@ func (r *RPC) Restart(_ *struct{}, _ *struct{}) (err error) {
 		return ErrMalformedRestartContext
 	}
 
-	return r.visor.restartCtx.Start()
+	// The new visor is started before this one exits.
+	ended := r.visor.uptime.end(ShutdownRestart)
+
+	if err := r.visor.restartCtx.Start(); err != nil {
+		if ended {
+			r.visor.uptime.resume()
+		}
+
+		return err
+	}
+
+	return nil
 }
 
-// Exec executes a given command in cmd and writes its output to out.
-func (r *RPC) Exec(cmd *string, out *[]byte) (err error) {
-	defer rpcutil.LogCall(r.log, "Exec", cmd)(out, &err)
+// DrainIn is input for Drain.
+type DrainIn struct {
+	Timeout time.Duration // How long to wait for routes to become idle, DefaultDrainTimeout if zero.
+}
+
+// Drain makes the visor refuse new transports and routes, and shut down once its routes are idle.
+func (r *RPC) Drain(in *DrainIn, out *DrainStatus) (err error) {
+	defer rpcutil.LogCall(r.log, "Drain", in)(out, &err)
 
-	*out, err = r.visor.Exec(*cmd)
+	*out = *r.visor.Drain(in.Timeout)
+	return nil
+}
+
+// Reboot reboots the host of the visor, if enabled in the config.
+func (r *RPC) Reboot(_ *struct{}, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "Reboot", nil)(nil, &err)
+
+	return r.visor.Reboot()
+}
+
+// Shutdown powers off the host of the visor, if enabled in the config.
+func (r *RPC) Shutdown(_ *struct{}, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "Shutdown", nil)(nil, &err)
+
+	return r.visor.Shutdown()
+}
+
+// Config returns the visor config encoded as JSON, without the secret key.
+func (r *RPC) Config(_ *struct{}, out *[]byte) (err error) {
+	defer rpcutil.LogCall(r.log, "Config", nil)(out, &err)
+
+	*out, err = r.visor.Config()
+	return err
+}
+
+// SetConfigIn is input for SetConfig.
+type SetConfigIn struct {
+	Config  []byte // JSON encoded visor config.
+	Restart bool   // Restart the visor to apply the config.
+}
+
+// SetConfig validates and saves the visor config, restarting the visor if requested.
+func (r *RPC) SetConfig(in *SetConfigIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetConfig", in)(nil, &err)
+
+	if err := r.visor.SetConfig(in.Config); err != nil {
+		return err
+	}
+
+	if in.Restart {
+		return r.Restart(&struct{}{}, &struct{}{})
+	}
+
+	return nil
+}
+
+// ReloadConfig re-reads the visor config file and applies the changes which don't need a restart.
+func (r *RPC) ReloadConfig(_ *struct{}, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "ReloadConfig", nil)(nil, &err)
+
+	return r.visor.ReloadConfig()
+}
+
+// InstallApp installs an app binary and registers the app in the config.
+func (r *RPC) InstallApp(in *AppInstall, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "InstallApp", in)(nil, &err)
+
+	return r.visor.InstallApp(*in)
+}
+
+// ExecStart starts a given command in cmd, of which output is streamed with ExecRead.
+func (r *RPC) ExecStart(cmd *string, out *uuid.UUID) (err error) {
+	defer rpcutil.LogCall(r.log, "ExecStart", cmd)(out, &err)
+
+	*out, err = r.visor.ExecStart(*cmd)
+	return err
+}
+
+// ExecRead waits for output of a command started with ExecStart.
+func (r *RPC) ExecRead(id *uuid.UUID, out *ExecOutput) (err error) {
+	// Not logged, as it is called repeatedly for the lifetime of a command.
+	o, err := r.visor.ExecRead(*id)
+	if o != nil {
+		*out = *o
+	}
+
+	return err
+}
+
+// ExecWriteIn is input for ExecWrite.
+type ExecWriteIn struct {
+	ID         uuid.UUID
+	Data       []byte
+	CloseStdin bool
+}
+
+// ExecWrite writes to stdin of a command started with ExecStart.
+func (r *RPC) ExecWrite(in *ExecWriteIn, _ *struct{}) (err error) {
+	return r.visor.ExecWrite(in.ID, in.Data, in.CloseStdin)
+}
+
+// ExecStop kills a command started with ExecStart.
+func (r *RPC) ExecStop(id *uuid.UUID, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "ExecStop", id)(nil, &err)
+
+	return r.visor.ExecStop(*id)
+}
+
+// ExecIn is input for Exec.
+type ExecIn struct {
+	Command string
+	Timeout time.Duration // The command is killed after this long, unless zero.
+}
+
+// Exec executes a given command and writes its output to out.
+func (r *RPC) Exec(in *ExecIn, out *[]byte) (err error) {
+	defer rpcutil.LogCall(r.log, "Exec", in)(out, &err)
+
+	*out, err = r.visor.Exec(in.Command, in.Timeout)
 	return err
 }
 
@@ -507,6 +879,102 @@ func (r *RPC) Update(_ *struct{}, updated *bool) (err error) {
 	return
 }
 
+// UpdateApp updates a single app, restarting it if it is running.
+func (r *RPC) UpdateApp(appName *string, updated *bool) (err error) {
+	defer rpcutil.LogCall(r.log, "UpdateApp", appName)(updated, &err)
+
+	*updated, err = r.visor.UpdateApp(*appName)
+	return
+}
+
+// Rollback rolls back the last visor update.
+func (r *RPC) Rollback(_ *struct{}, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "Rollback", nil)(nil, &err)
+
+	return r.visor.Rollback()
+}
+
+// ConnectivityTestIn is input for ConnectivityTest.
+type ConnectivityTestIn struct {
+	Probe         cipher.PubKey
+	TransportType string
+}
+
+// ConnectivityTest runs a connectivity test of the visor.
+func (r *RPC) ConnectivityTest(in *ConnectivityTestIn, out *ConnectivityReport) (err error) {
+	defer rpcutil.LogCall(r.log, "ConnectivityTest", in)(out, &err)
+
+	*out = *r.visor.ConnectivityTest(in.Probe, in.TransportType)
+
+	return nil
+}
+
+// BandwidthTestIn is input for BandwidthTest.
+type BandwidthTestIn struct {
+	Remote        cipher.PubKey
+	TransportType string
+	Duration      time.Duration
+}
+
+// BandwidthTest measures the throughput between the visor and a remote visor, which should expect the test.
+func (r *RPC) BandwidthTest(in *BandwidthTestIn, out *BandwidthResult) (err error) {
+	defer rpcutil.LogCall(r.log, "BandwidthTest", in)(out, &err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), BandwidthTestTimeout(in.Duration))
+	defer cancel()
+
+	res, err := r.visor.BandwidthTest(ctx, in.Remote, in.TransportType, in.Duration)
+	if res != nil {
+		*out = *res
+	}
+
+	return err
+}
+
+// ExpectBandwidthTest allows a remote visor to run a single bandwidth test with the visor.
+func (r *RPC) ExpectBandwidthTest(remote *cipher.PubKey, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "ExpectBandwidthTest", remote)(nil, &err)
+
+	r.visor.ExpectBandwidthTest(*remote)
+
+	return nil
+}
+
+// HypervisorPKs returns the public keys of hypervisors allowed to manage the visor.
+func (r *RPC) HypervisorPKs(_ *struct{}, out *[]cipher.PubKey) (err error) {
+	defer rpcutil.LogCall(r.log, "HypervisorPKs", nil)(out, &err)
+
+	*out = r.visor.HypervisorPKs()
+
+	return nil
+}
+
+// UpdateHypervisorPKsIn is input for UpdateHypervisorPKs.
+type UpdateHypervisorPKsIn struct {
+	Add    []cipher.PubKey
+	Remove []cipher.PubKey
+}
+
+// UpdateHypervisorPKs adds and removes public keys of hypervisors allowed to manage the visor.
+func (r *RPC) UpdateHypervisorPKs(in *UpdateHypervisorPKsIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "UpdateHypervisorPKs", in)(nil, &err)
+
+	return r.visor.UpdateHypervisorPKs(in.Add, in.Remove)
+}
+
+// WakeOnLANIn is input for WakeOnLAN.
+type WakeOnLANIn struct {
+	MAC  string
+	Addr string
+}
+
+// WakeOnLAN sends a Wake-on-LAN magic packet from the visor, waking a machine on the visor's LAN.
+func (r *RPC) WakeOnLAN(in *WakeOnLANIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "WakeOnLAN", in)(nil, &err)
+
+	return wol.Send(in.MAC, in.Addr)
+}
+
 // UpdateAvailable checks if visor update is available.
 func (r *RPC) UpdateAvailable(_ *struct{}, version *updater.Version) (err error) {
 	defer rpcutil.LogCall(r.log, "UpdateAvailable", nil)(version, &err)
@@ -1,25 +1,34 @@
 package visor
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/rpc"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/skycoin/dmsg"
 	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/skycoin/src/util/logging"
 
 	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/app/appcommon"
+	"github.com/skycoin/skywire/pkg/app/appnet"
+	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/router"
 	"github.com/skycoin/skywire/pkg/routing"
 	"github.com/skycoin/skywire/pkg/snet/snettest"
 	"github.com/skycoin/skywire/pkg/transport"
 	"github.com/skycoin/skywire/pkg/util/buildinfo"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
 	"github.com/skycoin/skywire/pkg/util/updater"
 )
 
@@ -29,26 +38,87 @@ var (
 	ErrAlreadyServing = errors.New("already serving")
 )
 
+// rpcErrors are the errors which are recognized again on the client side, as net/rpc only transfers error messages.
+var rpcErrors = []error{ // nolint: gochecknoglobals
+	ErrInvalidConfig,
+	ErrMalformedRequest,
+	ErrAppInstallDisabled,
+	ErrAppNotSigned,
+	ErrNoAppSigners,
+	ErrBadAppName,
+	ErrAppChecksumMismatch,
+}
+
+// RPCError is an error returned by the RPC server, which originates from one of the recognized visor errors.
+// It wraps that error, so that it can be checked with errors.Is.
+type RPCError struct {
+	Msg string
+	Err error
+}
+
+// Error implements error.
+func (e *RPCError) Error() string {
+	return e.Msg
+}
+
+// Unwrap returns the recognized visor error.
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// recognizeRPCError turns recognized errors returned by the RPC server into *RPCError.
+// They are recognized by message, which is either the error itself or the error wrapped with "%w: ...".
+func recognizeRPCError(err error) error {
+	se, ok := err.(rpc.ServerError)
+	if !ok {
+		return err
+	}
+
+	msg := string(se)
+	for _, e := range rpcErrors {
+		if msg == e.Error() || strings.HasPrefix(msg, e.Error()+": ") {
+			return &RPCError{Msg: msg, Err: e}
+		}
+	}
+
+	return err
+}
+
 // RPCClient represents a RPC Client implementation.
 type RPCClient interface {
 	Summary() (*Summary, error)
+	Resources() (*Resources, error)
+	KeepAlive(interval, timeout time.Duration) error
 
 	Health() (*HealthInfo, error)
 	Uptime() (float64, error)
+	UptimeStats() (*UptimeStats, error)
+	EnrollmentToken() (string, error)
+	Events(cursor uint64, kinds []string, timeout time.Duration) (*EventsOut, error)
 
 	Apps() ([]*AppState, error)
 	StartApp(appName string) error
 	StopApp(appName string) error
+	AppConnections(appName string) ([]appserver.ConnSummary, error)
+	AppUsage(appName string) (*appserver.Usage, error)
 	SetAutoStart(appName string, autostart bool) error
-	SetSocksPassword(password string) error
-	SetSocksClientPK(pk cipher.PubKey) error
-	LogsSince(timestamp time.Time, appName string) ([]string, error)
+	SetAppArgs(appName string, args []string) error
+	SetAppEnv(appName string, env map[string]string) error
+	SetAppSetting(appName, key, value string) error
+	GetAppSettings(appName string) (map[string]string, error)
+	LogsSince(timestamp time.Time, appName string, filter app.LogFilter) ([]string, error)
+	LogsAfter(appName string, seq uint64) ([]app.LogEntry, error)
 
 	TransportTypes() ([]string, error)
+	TransportTypeInfos() ([]TransportTypeInfo, error)
 	Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error)
 	Transport(tid uuid.UUID) (*TransportSummary, error)
 	AddTransport(remote cipher.PubKey, tpType string, public bool, timeout time.Duration) (*TransportSummary, error)
 	RemoveTransport(tid uuid.UUID) error
+	TransportPolicies() (*TransportPolicyStatus, error)
+	SetTransportPolicies(policies []TransportPolicy) error
+	TransportMaintenance() (*TransportMaintenanceStatus, error)
+	SetTransportTargets(targets []TransportTarget) error
 
 	DiscoverTransportsByPK(pk cipher.PubKey) ([]*transport.EntryWithStatus, error)
 	DiscoverTransportByID(id uuid.UUID) (*transport.EntryWithStatus, error)
@@ -57,20 +127,45 @@ type RPCClient interface {
 	RoutingRule(key routing.RouteID) (routing.Rule, error)
 	SaveRoutingRule(rule routing.Rule) error
 	RemoveRoutingRule(key routing.RouteID) error
+	RemoveRoutingRules(keys []routing.RouteID, expired bool) ([]routing.RouteID, error)
 
 	RouteGroups() ([]RouteGroupInfo, error)
 
-	Restart() error
-	Exec(command string) ([]byte, error)
-	Update() (bool, error)
+	Restart(timeout time.Duration) error
+	Drain(timeout time.Duration) (*DrainStatus, error)
+	Reboot() error
+	Shutdown() error
+	Config() ([]byte, error)
+	Diagnostics(logsSince time.Time) ([]byte, error)
+	SetConfig(config []byte, restart bool) error
+	ReloadConfig() error
+	InstallApp(in AppInstall) error
+	Exec(command string, timeout time.Duration) ([]byte, error)
+	ExecStart(command string) (uuid.UUID, error)
+	ExecRead(id uuid.UUID) (*ExecOutput, error)
+	ExecWrite(id uuid.UUID, data []byte, closeStdin bool) error
+	ExecStop(id uuid.UUID) error
+	Update(timeout time.Duration) (bool, error)
+	UpdateApp(appName string) (bool, error)
+	Rollback() error
 	UpdateAvailable() (*updater.Version, error)
+
+	ConnectivityTest(probe cipher.PubKey, tpType string) (*ConnectivityReport, error)
+	BandwidthTest(remote cipher.PubKey, tpType string, d time.Duration) (*BandwidthResult, error)
+	ExpectBandwidthTest(remote cipher.PubKey) error
+
+	HypervisorPKs() ([]cipher.PubKey, error)
+	UpdateHypervisorPKs(add, remove []cipher.PubKey) error
+
+	WakeOnLAN(mac, addr string) error
 }
 
 // RPCClient provides methods to call an RPC Server.
 // It implements RPCClient
 type rpcClient struct {
-	client *rpc.Client
-	prefix string
+	client    *rpc.Client
+	prefix    string
+	requestID string // sent along with calls, see rpcutil.TracedMethod
 }
 
 // NewRPCClient creates a new RPCClient.
@@ -78,9 +173,39 @@ func NewRPCClient(rc *rpc.Client, prefix string) RPCClient {
 	return &rpcClient{client: rc, prefix: prefix}
 }
 
+// WithRequestID returns a copy of the client, which sends the request ID along with calls.
+// The request ID is only received by servers using rpcutil.NewServerCodec.
+func (rc *rpcClient) WithRequestID(requestID string) RPCClient {
+	c := *rc
+	c.requestID = requestID
+
+	return &c
+}
+
 // Call calls the internal rpc.Client with the serviceMethod arg prefixed.
 func (rc *rpcClient) Call(method string, args, reply interface{}) error {
-	return rc.client.Call(rc.prefix+"."+method, args, reply)
+	err := rc.client.Call(rpcutil.TracedMethod(rc.prefix+"."+method, rc.requestID), args, reply)
+	return recognizeRPCError(err)
+}
+
+// CallTimeout is Call, failing with ErrRPCTimeout if there is no reply within timeout.
+// A timeout of zero waits indefinitely.
+func (rc *rpcClient) CallTimeout(method string, args, reply interface{}, timeout time.Duration) error {
+	if timeout <= 0 {
+		return rc.Call(method, args, reply)
+	}
+
+	call := rc.client.Go(rpcutil.TracedMethod(rc.prefix+"."+method, rc.requestID), args, reply, make(chan *rpc.Call, 1))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-call.Done:
+		return recognizeRPCError(call.Error)
+	case <-timer.C:
+		return ErrRPCTimeout
+	}
 }
 
 // Summary calls Summary.
@@ -90,6 +215,19 @@ func (rc *rpcClient) Summary() (*Summary, error) {
 	return out, err
 }
 
+// Resources calls Resources.
+func (rc *rpcClient) Resources() (*Resources, error) {
+	out := new(Resources)
+	err := rc.Call("Resources", &struct{}{}, out)
+	return out, err
+}
+
+// KeepAlive calls KeepAlive, waiting for the reply for up to interval.
+// The visor closes the connection if it receives no keepalive for timeout.
+func (rc *rpcClient) KeepAlive(interval, timeout time.Duration) error {
+	return rc.CallTimeout("KeepAlive", &KeepAliveIn{Timeout: timeout}, &struct{}{}, interval)
+}
+
 // Health calls Health
 func (rc *rpcClient) Health() (*HealthInfo, error) {
 	hi := &HealthInfo{}
@@ -104,6 +242,27 @@ func (rc *rpcClient) Uptime() (float64, error) {
 	return out, err
 }
 
+// UptimeStats calls UptimeStats.
+func (rc *rpcClient) UptimeStats() (*UptimeStats, error) {
+	out := new(UptimeStats)
+	err := rc.Call("UptimeStats", &struct{}{}, out)
+	return out, err
+}
+
+// EnrollmentToken calls EnrollmentToken.
+func (rc *rpcClient) EnrollmentToken() (string, error) {
+	var out string
+	err := rc.Call("EnrollmentToken", &struct{}{}, &out)
+	return out, err
+}
+
+// Events calls Events, waiting up to timeout for events if there are none.
+func (rc *rpcClient) Events(cursor uint64, kinds []string, timeout time.Duration) (*EventsOut, error) {
+	out := new(EventsOut)
+	err := rc.CallTimeout("Events", &EventsIn{Cursor: cursor, Kinds: kinds, Timeout: timeout}, out, timeout+eventsTimeoutSlack)
+	return out, err
+}
+
 // Apps calls Apps.
 func (rc *rpcClient) Apps() ([]*AppState, error) {
 	states := make([]*AppState, 0)
@@ -121,6 +280,20 @@ func (rc *rpcClient) StopApp(appName string) error {
 	return rc.Call("StopApp", &appName, &struct{}{})
 }
 
+// AppConnections calls AppConnections.
+func (rc *rpcClient) AppConnections(appName string) ([]appserver.ConnSummary, error) {
+	var conns []appserver.ConnSummary
+	err := rc.Call("AppConnections", &appName, &conns)
+	return conns, err
+}
+
+// AppUsage calls AppUsage.
+func (rc *rpcClient) AppUsage(appName string) (*appserver.Usage, error) {
+	out := new(appserver.Usage)
+	err := rc.Call("AppUsage", &appName, out)
+	return out, err
+}
+
 // SetAutoStart calls SetAutoStart.
 func (rc *rpcClient) SetAutoStart(appName string, autostart bool) error {
 	return rc.Call("SetAutoStart", &SetAutoStartIn{
@@ -129,23 +302,36 @@ func (rc *rpcClient) SetAutoStart(appName string, autostart bool) error {
 	}, &struct{}{})
 }
 
-// SetSocksPassword calls SetSocksPassword.
-func (rc *rpcClient) SetSocksPassword(password string) error {
-	return rc.Call("SetSocksPassword", &password, &struct{}{})
+// SetAppArgs calls SetAppArgs.
+func (rc *rpcClient) SetAppArgs(appName string, args []string) error {
+	return rc.Call("SetAppArgs", &SetAppArgsIn{AppName: appName, Args: args}, &struct{}{})
+}
+
+// SetAppEnv calls SetAppEnv.
+func (rc *rpcClient) SetAppEnv(appName string, env map[string]string) error {
+	return rc.Call("SetAppEnv", &SetAppEnvIn{AppName: appName, Env: env}, &struct{}{})
+}
+
+// SetAppSetting calls SetAppSetting.
+func (rc *rpcClient) SetAppSetting(appName, key, value string) error {
+	return rc.Call("SetAppSetting", &SetAppSettingIn{AppName: appName, Key: key, Value: value}, &struct{}{})
 }
 
-// SetSocksClientPK calls SetSocksClientPK.
-func (rc *rpcClient) SetSocksClientPK(pk cipher.PubKey) error {
-	return rc.Call("SetSocksClientPK", &pk, &struct{}{})
+// GetAppSettings calls GetAppSettings.
+func (rc *rpcClient) GetAppSettings(appName string) (map[string]string, error) {
+	var settings map[string]string
+	err := rc.Call("GetAppSettings", &appName, &settings)
+	return settings, err
 }
 
 // LogsSince calls LogsSince
-func (rc *rpcClient) LogsSince(timestamp time.Time, appName string) ([]string, error) {
+func (rc *rpcClient) LogsSince(timestamp time.Time, appName string, filter app.LogFilter) ([]string, error) {
 	res := make([]string, 0)
 
 	err := rc.Call("LogsSince", &AppLogsRequest{
 		TimeStamp: timestamp,
 		AppName:   appName,
+		Filter:    filter,
 	}, &res)
 	if err != nil {
 		return nil, err
@@ -154,6 +340,28 @@ func (rc *rpcClient) LogsSince(timestamp time.Time, appName string) ([]string, e
 	return res, nil
 }
 
+// LogsAfter calls LogsAfter
+func (rc *rpcClient) LogsAfter(appName string, seq uint64) ([]app.LogEntry, error) {
+	res := make([]app.LogEntry, 0)
+
+	err := rc.Call("LogsAfter", &AppLogsAfterIn{
+		AppName: appName,
+		Seq:     seq,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// TransportTypeInfos calls TransportTypeInfos.
+func (rc *rpcClient) TransportTypeInfos() ([]TransportTypeInfo, error) {
+	var infos []TransportTypeInfo
+	err := rc.Call("TransportTypeInfos", &struct{}{}, &infos)
+	return infos, err
+}
+
 // TransportTypes calls TransportTypes.
 func (rc *rpcClient) TransportTypes() ([]string, error) {
 	var types []string
@@ -196,6 +404,30 @@ func (rc *rpcClient) RemoveTransport(tid uuid.UUID) error {
 	return rc.Call("RemoveTransport", &tid, &struct{}{})
 }
 
+// TransportPolicies calls TransportPolicies.
+func (rc *rpcClient) TransportPolicies() (*TransportPolicyStatus, error) {
+	out := new(TransportPolicyStatus)
+	err := rc.Call("TransportPolicies", &struct{}{}, out)
+	return out, err
+}
+
+// SetTransportPolicies calls SetTransportPolicies.
+func (rc *rpcClient) SetTransportPolicies(policies []TransportPolicy) error {
+	return rc.Call("SetTransportPolicies", &policies, &struct{}{})
+}
+
+// TransportMaintenance calls TransportMaintenance.
+func (rc *rpcClient) TransportMaintenance() (*TransportMaintenanceStatus, error) {
+	out := new(TransportMaintenanceStatus)
+	err := rc.Call("TransportMaintenance", &struct{}{}, out)
+	return out, err
+}
+
+// SetTransportTargets calls SetTransportTargets.
+func (rc *rpcClient) SetTransportTargets(targets []TransportTarget) error {
+	return rc.Call("SetTransportTargets", &targets, &struct{}{})
+}
+
 func (rc *rpcClient) DiscoverTransportsByPK(pk cipher.PubKey) ([]*transport.EntryWithStatus, error) {
 	entries := make([]*transport.EntryWithStatus, 0)
 	err := rc.Call("DiscoverTransportsByPK", &pk, &entries)
@@ -232,6 +464,13 @@ func (rc *rpcClient) RemoveRoutingRule(key routing.RouteID) error {
 	return rc.Call("RemoveRoutingRule", &key, &struct{}{})
 }
 
+// RemoveRoutingRules calls RemoveRoutingRules.
+func (rc *rpcClient) RemoveRoutingRules(keys []routing.RouteID, expired bool) ([]routing.RouteID, error) {
+	removed := make([]routing.RouteID, 0)
+	err := rc.Call("RemoveRoutingRules", &RemoveRoutingRulesIn{Keys: keys, Expired: expired}, &removed)
+	return removed, err
+}
+
 // RouteGroups calls RouteGroups.
 func (rc *rpcClient) RouteGroups() ([]RouteGroupInfo, error) {
 	var routegroups []RouteGroupInfo
@@ -240,24 +479,154 @@ func (rc *rpcClient) RouteGroups() ([]RouteGroupInfo, error) {
 }
 
 // Restart calls Restart.
-func (rc *rpcClient) Restart() error {
-	return rc.Call("Restart", &struct{}{}, &struct{}{})
+func (rc *rpcClient) Restart(timeout time.Duration) error {
+	return rc.CallTimeout("Restart", &struct{}{}, &struct{}{}, timeout)
+}
+
+// Drain calls Drain.
+func (rc *rpcClient) Drain(timeout time.Duration) (*DrainStatus, error) {
+	out := new(DrainStatus)
+	err := rc.Call("Drain", &DrainIn{Timeout: timeout}, out)
+	return out, err
+}
+
+// Reboot calls Reboot.
+func (rc *rpcClient) Reboot() error {
+	return rc.Call("Reboot", &struct{}{}, &struct{}{})
+}
+
+// Shutdown calls Shutdown.
+func (rc *rpcClient) Shutdown() error {
+	return rc.Call("Shutdown", &struct{}{}, &struct{}{})
+}
+
+// Config calls Config.
+func (rc *rpcClient) Config() ([]byte, error) {
+	output := make([]byte, 0)
+	err := rc.Call("Config", &struct{}{}, &output)
+	return output, err
+}
+
+// Diagnostics calls Diagnostics.
+func (rc *rpcClient) Diagnostics(logsSince time.Time) ([]byte, error) {
+	output := make([]byte, 0)
+	err := rc.Call("Diagnostics", &DiagnosticsIn{LogsSince: logsSince}, &output)
+	return output, err
+}
+
+// SetConfig calls SetConfig.
+func (rc *rpcClient) SetConfig(config []byte, restart bool) error {
+	return rc.Call("SetConfig", &SetConfigIn{
+		Config:  config,
+		Restart: restart,
+	}, &struct{}{})
+}
+
+// ReloadConfig calls ReloadConfig.
+func (rc *rpcClient) ReloadConfig() error {
+	return rc.Call("ReloadConfig", &struct{}{}, &struct{}{})
+}
+
+// InstallApp calls InstallApp.
+func (rc *rpcClient) InstallApp(in AppInstall) error {
+	return rc.Call("InstallApp", &in, &struct{}{})
 }
 
 // Exec calls Exec.
-func (rc *rpcClient) Exec(command string) ([]byte, error) {
+func (rc *rpcClient) Exec(command string, timeout time.Duration) ([]byte, error) {
 	output := make([]byte, 0)
-	err := rc.Call("Exec", &command, &output)
+	err := rc.CallTimeout("Exec", &ExecIn{Command: command, Timeout: timeout}, &output, timeout)
 	return output, err
 }
 
+// ExecStart calls ExecStart.
+func (rc *rpcClient) ExecStart(command string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := rc.Call("ExecStart", &command, &id)
+	return id, err
+}
+
+// ExecRead calls ExecRead.
+func (rc *rpcClient) ExecRead(id uuid.UUID) (*ExecOutput, error) {
+	out := new(ExecOutput)
+	err := rc.Call("ExecRead", &id, out)
+	return out, err
+}
+
+// ExecWrite calls ExecWrite.
+func (rc *rpcClient) ExecWrite(id uuid.UUID, data []byte, closeStdin bool) error {
+	return rc.Call("ExecWrite", &ExecWriteIn{ID: id, Data: data, CloseStdin: closeStdin}, &struct{}{})
+}
+
+// ExecStop calls ExecStop.
+func (rc *rpcClient) ExecStop(id uuid.UUID) error {
+	return rc.Call("ExecStop", &id, &struct{}{})
+}
+
 // Update calls Update.
-func (rc *rpcClient) Update() (bool, error) {
+func (rc *rpcClient) Update(timeout time.Duration) (bool, error) {
 	var updated bool
-	err := rc.Call("Update", &struct{}{}, &updated)
+	err := rc.CallTimeout("Update", &struct{}{}, &updated, timeout)
 	return updated, err
 }
 
+// UpdateApp calls UpdateApp.
+func (rc *rpcClient) UpdateApp(appName string) (bool, error) {
+	var updated bool
+	err := rc.Call("UpdateApp", &appName, &updated)
+	return updated, err
+}
+
+// Rollback calls Rollback.
+func (rc *rpcClient) Rollback() error {
+	return rc.Call("Rollback", &struct{}{}, &struct{}{})
+}
+
+// ConnectivityTest calls ConnectivityTest.
+func (rc *rpcClient) ConnectivityTest(probe cipher.PubKey, tpType string) (*ConnectivityReport, error) {
+	out := new(ConnectivityReport)
+	err := rc.Call("ConnectivityTest", &ConnectivityTestIn{
+		Probe:         probe,
+		TransportType: tpType,
+	}, out)
+
+	return out, err
+}
+
+// BandwidthTest calls BandwidthTest.
+func (rc *rpcClient) BandwidthTest(remote cipher.PubKey, tpType string, d time.Duration) (*BandwidthResult, error) {
+	out := new(BandwidthResult)
+	err := rc.CallTimeout("BandwidthTest", &BandwidthTestIn{
+		Remote:        remote,
+		TransportType: tpType,
+		Duration:      d,
+	}, out, BandwidthTestTimeout(d))
+
+	return out, err
+}
+
+// ExpectBandwidthTest calls ExpectBandwidthTest.
+func (rc *rpcClient) ExpectBandwidthTest(remote cipher.PubKey) error {
+	return rc.Call("ExpectBandwidthTest", &remote, &struct{}{})
+}
+
+// HypervisorPKs calls HypervisorPKs.
+func (rc *rpcClient) HypervisorPKs() ([]cipher.PubKey, error) {
+	var out []cipher.PubKey
+	err := rc.Call("HypervisorPKs", &struct{}{}, &out)
+	return out, err
+}
+
+// UpdateHypervisorPKs calls UpdateHypervisorPKs.
+func (rc *rpcClient) UpdateHypervisorPKs(add, remove []cipher.PubKey) error {
+	return rc.Call("UpdateHypervisorPKs", &UpdateHypervisorPKsIn{Add: add, Remove: remove}, &struct{}{})
+}
+
+// WakeOnLAN calls WakeOnLAN.
+func (rc *rpcClient) WakeOnLAN(mac, addr string) error {
+	return rc.Call("WakeOnLAN", &WakeOnLANIn{MAC: mac, Addr: addr}, &struct{}{})
+}
+
 // UpdateAvailable calls UpdateAvailable.
 func (rc *rpcClient) UpdateAvailable() (*updater.Version, error) {
 	var version, empty updater.Version
@@ -275,11 +644,17 @@ func (rc *rpcClient) UpdateAvailable() (*updater.Version, error) {
 
 // MockRPCClient mocks RPCClient.
 type mockRPCClient struct {
-	startedAt time.Time
-	s         *Summary
-	tpTypes   []string
-	rt        routing.Table
-	appls     app.LogStore
+	startedAt  time.Time
+	s          *Summary
+	tpTypes    []string
+	rt         routing.Table
+	appls      app.LogStore
+	conf       []byte
+	hvPKs      []cipher.PubKey
+	execs      map[uuid.UUID]string
+	tpPolicies []TransportPolicy
+	tpTargets  []TransportTarget
+	events     eventBus
 	sync.RWMutex
 }
 
@@ -300,7 +675,8 @@ func NewMockRPCClient(r *rand.Rand, maxTps int, maxRules int) (cipher.PubKey, RP
 			Local:  localPK,
 			Remote: remotePK,
 			Type:   types[r.Int()%len(types)],
-			Log:    new(transport.LogEntry),
+			Public: r.Intn(2) == 0,
+			Log:    mockLogEntry(r),
 		}
 		log.Infof("tp[%2d]: %v", i, tps[i])
 	}
@@ -351,6 +727,22 @@ func NewMockRPCClient(r *rand.Rand, maxTps int, maxRules int) (cipher.PubKey, RP
 
 	log.Printf("rtCount: %d", rt.Count())
 
+	conf, err := (&Config{
+		Version:   "1.0",
+		KeyPair:   &KeyPair{PubKey: localPK},
+		Dmsg:      DefaultDmsgConfig(),
+		Transport: DefaultTransportConfig(),
+		Routing:   DefaultRoutingConfig(),
+		Apps: []AppConfig{
+			{App: "foo.v1.0", Port: 10},
+			{App: "bar.v2.0", Port: 20},
+		},
+		LogLevel: DefaultLogLevel,
+	}).redactedJSON()
+	if err != nil {
+		return cipher.PubKey{}, nil, err
+	}
+
 	client := &mockRPCClient{
 		s: &Summary{
 			PubKey:          localPK,
@@ -365,12 +757,26 @@ func NewMockRPCClient(r *rand.Rand, maxTps int, maxRules int) (cipher.PubKey, RP
 		},
 		tpTypes:   types,
 		rt:        rt,
+		conf:      conf,
 		startedAt: time.Now(),
 	}
 
 	return localPK, client, nil
 }
 
+func mockLogEntry(r *rand.Rand) *transport.LogEntry {
+	const maxAge = 24 * 60 * 60 // seconds
+
+	createdAt := time.Now().Unix() - r.Int63n(maxAge)
+
+	return &transport.LogEntry{
+		RecvBytes:    uint64(r.Intn(1 << 20)),
+		SentBytes:    uint64(r.Intn(1 << 20)),
+		CreatedAt:    createdAt,
+		LastActivity: createdAt + r.Int63n(time.Now().Unix()-createdAt+1),
+	}
+}
+
 func (mc *mockRPCClient) do(write bool, f func() error) error {
 	if write {
 		mc.Lock()
@@ -394,11 +800,32 @@ func (mc *mockRPCClient) Summary() (*Summary, error) {
 			out.Transports = append(out.Transports, &(*tp))
 		}
 		out.RoutesCount = mc.s.RoutesCount
+		out.Uptime, _ = mc.UptimeStats() // nolint: errcheck
 		return nil
 	})
 	return &out, err
 }
 
+// Resources implements RPCClient.
+func (mc *mockRPCClient) Resources() (*Resources, error) {
+	return &Resources{
+		Time:         time.Now(),
+		CPUs:         4,
+		Load1:        0.5,
+		Load5:        0.4,
+		Load15:       0.3,
+		MemTotal:     4 << 30,
+		MemAvailable: 3 << 30,
+		Disks:        []DiskUsage{{Path: "/", Total: 32 << 30, Free: 16 << 30, Available: 15 << 30}},
+		NICs:         []NICCounters{{Name: "eth0", RecvBytes: 1 << 20, RecvPackets: 1000, SentBytes: 2 << 20, SentPackets: 2000}},
+	}, nil
+}
+
+// KeepAlive implements RPCClient.
+func (mc *mockRPCClient) KeepAlive(_, _ time.Duration) error {
+	return nil
+}
+
 // Health implements RPCClient
 func (mc *mockRPCClient) Health() (*HealthInfo, error) {
 	hi := &HealthInfo{
@@ -415,6 +842,32 @@ func (mc *mockRPCClient) Uptime() (float64, error) {
 	return time.Since(mc.startedAt).Seconds(), nil
 }
 
+// UptimeStats implements RPCClient. The mock visor is on its first run.
+func (mc *mockRPCClient) UptimeStats() (*UptimeStats, error) {
+	uptime := time.Since(mc.startedAt).Seconds()
+
+	return &UptimeStats{
+		StartedAt:   mc.startedAt,
+		Uptime:      uptime,
+		TotalUptime: uptime,
+		Boots:       1,
+	}, nil
+}
+
+// EnrollmentToken implements RPCClient.
+func (mc *mockRPCClient) EnrollmentToken() (string, error) {
+	return "", nil
+}
+
+// Events implements RPCClient. Events are published by AddTransport, RemoveTransport and SaveRoutingRule.
+func (mc *mockRPCClient) Events(cursor uint64, kinds []string, timeout time.Duration) (*EventsOut, error) {
+	if err := checkEventKinds(kinds); err != nil {
+		return nil, err
+	}
+
+	return mc.events.wait(cursor, kinds, timeout), nil
+}
+
 // Apps implements RPCClient.
 func (mc *mockRPCClient) Apps() ([]*AppState, error) {
 	var apps []*AppState
@@ -437,6 +890,55 @@ func (*mockRPCClient) StopApp(string) error {
 	return nil
 }
 
+// AppConnections implements RPCClient. Running apps have a single mock connection.
+func (mc *mockRPCClient) AppConnections(appName string) ([]appserver.ConnSummary, error) {
+	var conns []appserver.ConnSummary
+	err := mc.do(false, func() error {
+		for _, a := range mc.s.Apps {
+			if a.Name != appName {
+				continue
+			}
+
+			if a.Status == AppStatusRunning {
+				remote, _ := cipher.GenerateKeyPair()
+				conns = append(conns, appserver.ConnSummary{
+					Local:   appnet.Addr{Net: appnet.TypeSkynet, PubKey: mc.s.PubKey, Port: a.Port},
+					Remote:  appnet.Addr{Net: appnet.TypeSkynet, PubKey: remote, Port: routing.Port(1024)},
+					Started: time.Now().Add(-time.Minute),
+				})
+			}
+
+			return nil
+		}
+
+		return ErrNotFound
+	})
+	return conns, err
+}
+
+// AppUsage implements RPCClient.
+func (mc *mockRPCClient) AppUsage(appName string) (*appserver.Usage, error) {
+	var usage *appserver.Usage
+	err := mc.do(false, func() error {
+		for _, a := range mc.s.Apps {
+			if a.Name != appName {
+				continue
+			}
+
+			if a.Status != AppStatusRunning {
+				return ErrAppNotRunning
+			}
+
+			usage = &appserver.Usage{CPUTime: time.Minute, Memory: 16 << 20, Files: 12}
+
+			return nil
+		}
+
+		return ErrNotFound
+	})
+	return usage, err
+}
+
 // SetAutoStart implements RPCClient.
 func (mc *mockRPCClient) SetAutoStart(appName string, autostart bool) error {
 	return mc.do(true, func() error {
@@ -450,39 +952,85 @@ func (mc *mockRPCClient) SetAutoStart(appName string, autostart bool) error {
 	})
 }
 
-// SetSocksPassword implements RPCClient.
-func (mc *mockRPCClient) SetSocksPassword(string) error {
+// SetAppArgs implements RPCClient.
+func (mc *mockRPCClient) SetAppArgs(appName string, args []string) error {
 	return mc.do(true, func() error {
-		const socksName = "skysocks"
-
-		for i := range mc.s.Apps {
-			if mc.s.Apps[i].Name == socksName {
+		for _, a := range mc.s.Apps {
+			if a.Name == appName {
+				a.Args = args
 				return nil
 			}
 		}
+		return fmt.Errorf("app of name '%s' does not exist", appName)
+	})
+}
+
+// SetAppEnv implements RPCClient.
+func (mc *mockRPCClient) SetAppEnv(appName string, env map[string]string) error {
+	return mc.do(true, func() error {
+		for k := range env {
+			if !envKeyRegexp.MatchString(k) || appcommon.IsReservedEnv(k) {
+				return fmt.Errorf("%w: app %s can't set env %q", ErrInvalidConfig, appName, k)
+			}
+		}
 
-		return fmt.Errorf("app of name '%s' does not exist", socksName)
+		for _, a := range mc.s.Apps {
+			if a.Name == appName {
+				a.Env = env
+				return nil
+			}
+		}
+		return fmt.Errorf("app of name '%s' does not exist", appName)
 	})
 }
 
-// SetSocksClientPK implements RPCClient.
-func (mc *mockRPCClient) SetSocksClientPK(cipher.PubKey) error {
+// SetAppSetting implements RPCClient.
+func (mc *mockRPCClient) SetAppSetting(appName, key, value string) error {
 	return mc.do(true, func() error {
-		const socksName = "skysocks-client"
+		if !settingKeyRegexp.MatchString(key) {
+			return fmt.Errorf("%w: app %s can't have setting %q", ErrInvalidConfig, appName, key)
+		}
 
-		for i := range mc.s.Apps {
-			if mc.s.Apps[i].Name == socksName {
+		for _, a := range mc.s.Apps {
+			if a.Name == appName {
+				if a.Settings == nil {
+					a.Settings = make(map[string]string)
+				}
+
+				a.Settings[key] = value
 				return nil
 			}
 		}
+		return fmt.Errorf("app of name '%s' does not exist", appName)
+	})
+}
 
-		return fmt.Errorf("app of name '%s' does not exist", socksName)
+// GetAppSettings implements RPCClient.
+func (mc *mockRPCClient) GetAppSettings(appName string) (map[string]string, error) {
+	var settings map[string]string
+	err := mc.do(false, func() error {
+		for _, a := range mc.s.Apps {
+			if a.Name == appName {
+				settings = make(map[string]string, len(a.Settings))
+				for k, v := range a.Settings {
+					settings[k] = v
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("app of name '%s' does not exist", appName)
 	})
+	return settings, err
 }
 
 // LogsSince implements RPCClient. Manually set (*mockRPPClient).appls before calling this function
-func (mc *mockRPCClient) LogsSince(timestamp time.Time, _ string) ([]string, error) {
-	return mc.appls.LogsSince(timestamp)
+func (mc *mockRPCClient) LogsSince(timestamp time.Time, _ string, filter app.LogFilter) ([]string, error) {
+	return mc.appls.LogsSince(timestamp, filter)
+}
+
+// LogsAfter implements RPCClient. Manually set (*mockRPPClient).appls before calling this function
+func (mc *mockRPCClient) LogsAfter(_ string, seq uint64) ([]app.LogEntry, error) {
+	return mc.appls.LogsAfter(seq)
 }
 
 // TransportTypes implements RPCClient.
@@ -490,6 +1038,11 @@ func (mc *mockRPCClient) TransportTypes() ([]string, error) {
 	return mc.tpTypes, nil
 }
 
+// TransportTypeInfos implements RPCClient.
+func (mc *mockRPCClient) TransportTypeInfos() ([]TransportTypeInfo, error) {
+	return makeTransportTypeInfos(mc.tpTypes), nil
+}
+
 // Transports implements RPCClient.
 func (mc *mockRPCClient) Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error) {
 	var summaries []*TransportSummary
@@ -542,17 +1095,18 @@ func (mc *mockRPCClient) Transport(tid uuid.UUID) (*TransportSummary, error) {
 }
 
 // AddTransport implements RPCClient.
-func (mc *mockRPCClient) AddTransport(remote cipher.PubKey, tpType string, _ bool, _ time.Duration) (*TransportSummary, error) {
+func (mc *mockRPCClient) AddTransport(remote cipher.PubKey, tpType string, public bool, _ time.Duration) (*TransportSummary, error) {
 	summary := &TransportSummary{
 		ID:     transport.MakeTransportID(mc.s.PubKey, remote, tpType),
 		Local:  mc.s.PubKey,
 		Remote: remote,
 		Type:   tpType,
-		Log:    new(transport.LogEntry),
+		Public: public,
+		Log:    transport.NewLogEntry(),
 	}
 	return summary, mc.do(true, func() error {
 		mc.s.Transports = append(mc.s.Transports, summary)
-		return nil
+		return mc.events.publish(EventTransportUp, TransportEvent{ID: summary.ID, Remote: remote, Type: tpType})
 	})
 }
 
@@ -562,13 +1116,84 @@ func (mc *mockRPCClient) RemoveTransport(tid uuid.UUID) error {
 		for i, tp := range mc.s.Transports {
 			if tp.ID == tid {
 				mc.s.Transports = append(mc.s.Transports[:i], mc.s.Transports[i+1:]...)
-				return nil
+				return mc.events.publish(EventTransportDown, TransportEvent{ID: tid, Remote: tp.Remote, Type: tp.Type})
 			}
 		}
 		return fmt.Errorf("transport of id '%s' is not found", tid)
 	})
 }
 
+// TransportPolicies implements RPCClient.
+func (mc *mockRPCClient) TransportPolicies() (*TransportPolicyStatus, error) {
+	mc.RLock()
+	defer mc.RUnlock()
+
+	s := &TransportPolicyStatus{
+		Policies:  append([]TransportPolicy{}, mc.tpPolicies...),
+		Active:    make([]string, 0),
+		Suspended: make([]SuspendedTransport, 0),
+	}
+
+	for _, p := range mc.tpPolicies {
+		if p.Active(time.Now()) {
+			s.Active = append(s.Active, p.Name)
+		}
+	}
+
+	return s, nil
+}
+
+// SetTransportPolicies implements RPCClient.
+func (mc *mockRPCClient) SetTransportPolicies(policies []TransportPolicy) error {
+	if err := ValidateTransportPolicies(policies); err != nil {
+		return err
+	}
+
+	mc.Lock()
+	mc.tpPolicies = policies
+	mc.Unlock()
+
+	return nil
+}
+
+// TransportMaintenance implements RPCClient.
+func (mc *mockRPCClient) TransportMaintenance() (*TransportMaintenanceStatus, error) {
+	mc.RLock()
+	defer mc.RUnlock()
+
+	s := &TransportMaintenanceStatus{
+		Targets:     make([]TransportTargetStatus, 0, len(mc.tpTargets)),
+		Unreachable: make([]UnreachableVisor, 0),
+	}
+
+	for _, target := range mc.tpTargets {
+		ts := TransportTargetStatus{TransportTarget: target, Established: make([]cipher.PubKey, 0)}
+
+		for _, tp := range mc.s.Transports {
+			if tp.Type == target.Type {
+				ts.Established = append(ts.Established, tp.Remote)
+			}
+		}
+
+		s.Targets = append(s.Targets, ts)
+	}
+
+	return s, nil
+}
+
+// SetTransportTargets implements RPCClient.
+func (mc *mockRPCClient) SetTransportTargets(targets []TransportTarget) error {
+	if err := ValidateTransportTargets(targets); err != nil {
+		return err
+	}
+
+	mc.Lock()
+	mc.tpTargets = targets
+	mc.Unlock()
+
+	return nil
+}
+
 func (mc *mockRPCClient) DiscoverTransportsByPK(cipher.PubKey) ([]*transport.EntryWithStatus, error) {
 	return nil, ErrNotImplemented
 }
@@ -589,7 +1214,11 @@ func (mc *mockRPCClient) RoutingRule(key routing.RouteID) (routing.Rule, error)
 
 // SaveRoutingRule implements RPCClient.
 func (mc *mockRPCClient) SaveRoutingRule(rule routing.Rule) error {
-	return mc.rt.SaveRule(rule)
+	if err := mc.rt.SaveRule(rule); err != nil {
+		return err
+	}
+
+	return mc.events.publish(EventRouteAdded, rule.Summary())
 }
 
 // RemoveRoutingRule implements RPCClient.
@@ -598,6 +1227,20 @@ func (mc *mockRPCClient) RemoveRoutingRule(key routing.RouteID) error {
 	return nil
 }
 
+// RemoveRoutingRules implements RPCClient.
+func (mc *mockRPCClient) RemoveRoutingRules(keys []routing.RouteID, expired bool) ([]routing.RouteID, error) {
+	removed := existingRuleKeys(mc.rt.AllRules(), keys)
+	mc.rt.DelRules(removed)
+
+	if expired {
+		for _, rule := range mc.rt.CollectGarbage() {
+			removed = append(removed, rule.KeyRouteID())
+		}
+	}
+
+	return removed, nil
+}
+
 // RouteGroups implements RPCClient.
 func (mc *mockRPCClient) RouteGroups() ([]RouteGroupInfo, error) {
 	var routeGroups []RouteGroupInfo
@@ -623,21 +1266,303 @@ func (mc *mockRPCClient) RouteGroups() ([]RouteGroupInfo, error) {
 }
 
 // Restart implements RPCClient.
-func (mc *mockRPCClient) Restart() error {
+func (mc *mockRPCClient) Restart(time.Duration) error {
+	return nil
+}
+
+// Drain implements RPCClient. The mock visor reports draining in its summary, but never shuts down.
+func (mc *mockRPCClient) Drain(timeout time.Duration) (*DrainStatus, error) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+
+	var out DrainStatus
+	err := mc.do(true, func() error {
+		if mc.s.Drain == nil {
+			mc.s.Drain = &DrainStatus{Deadline: time.Now().Add(timeout), Routes: mc.rt.Count()}
+		}
+
+		out = *mc.s.Drain
+		return nil
+	})
+
+	return &out, err
+}
+
+// Reboot implements RPCClient.
+func (mc *mockRPCClient) Reboot() error {
+	return nil
+}
+
+// Shutdown implements RPCClient.
+func (mc *mockRPCClient) Shutdown() error {
+	return nil
+}
+
+// Config implements RPCClient.
+func (mc *mockRPCClient) Config() ([]byte, error) {
+	var out []byte
+	err := mc.do(false, func() error {
+		out = append(out, mc.conf...)
+		return nil
+	})
+	return out, err
+}
+
+// Diagnostics implements RPCClient.
+func (mc *mockRPCClient) Diagnostics(logsSince time.Time) ([]byte, error) {
+	files := make(map[string][]byte)
+
+	err := mc.do(false, func() error {
+		summary, err := json.MarshalIndent(mc.s, "", "\t")
+		if err != nil {
+			return err
+		}
+
+		files["config.json"] = append([]byte(nil), mc.conf...)
+		files["summary.json"] = summary
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// App logs are only included when the log store was set, see LogsSince.
+	if mc.appls != nil {
+		logs, err := mc.appls.LogsSince(logsSince, app.LogFilter{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, app := range mc.s.Apps {
+			files["logs/"+app.Name+".log"] = []byte(strings.Join(logs, ""))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeTarGz(&buf, files); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SetConfig implements RPCClient.
+func (mc *mockRPCClient) SetConfig(config []byte, _ bool) error {
+	return mc.do(true, func() error {
+		var conf Config
+		if err := json.Unmarshal(config, &conf); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+
+		mc.conf = append([]byte(nil), config...)
+		return nil
+	})
+}
+
+// ReloadConfig implements RPCClient.
+func (mc *mockRPCClient) ReloadConfig() error {
 	return nil
 }
 
+// InstallApp implements RPCClient.
+func (mc *mockRPCClient) InstallApp(in AppInstall) error {
+	if err := (&AppInstallConfig{AllowUnsigned: true}).Verify(&in); err != nil {
+		return err
+	}
+
+	return mc.do(true, func() error {
+		for _, a := range mc.s.Apps {
+			if a.Name == in.App {
+				a.AutoStart, a.Port = in.AutoStart, in.Port
+				return nil
+			}
+		}
+
+		status := AppStatusStopped
+		if in.Start {
+			status = AppStatusRunning
+		}
+
+		mc.s.Apps = append(mc.s.Apps, &AppState{Name: in.App, AutoStart: in.AutoStart, Port: in.Port, Status: status})
+
+		return nil
+	})
+}
+
 // Exec implements RPCClient.
-func (mc *mockRPCClient) Exec(string) ([]byte, error) {
+func (mc *mockRPCClient) Exec(string, time.Duration) ([]byte, error) {
 	return []byte("mock"), nil
 }
 
+// ExecStart implements RPCClient.
+func (mc *mockRPCClient) ExecStart(command string) (uuid.UUID, error) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if mc.execs == nil {
+		mc.execs = make(map[uuid.UUID]string)
+	}
+
+	id := uuid.New()
+	mc.execs[id] = command
+
+	return id, nil
+}
+
+// ExecRead implements RPCClient. Commands output "mock" and exit immediately.
+func (mc *mockRPCClient) ExecRead(id uuid.UUID) (*ExecOutput, error) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if _, ok := mc.execs[id]; !ok {
+		return nil, ErrUnknownExecSession
+	}
+
+	delete(mc.execs, id)
+
+	return &ExecOutput{Stdout: []byte("mock"), Exited: true}, nil
+}
+
+// ExecWrite implements RPCClient.
+func (mc *mockRPCClient) ExecWrite(id uuid.UUID, _ []byte, _ bool) error {
+	mc.RLock()
+	defer mc.RUnlock()
+
+	if _, ok := mc.execs[id]; !ok {
+		return ErrUnknownExecSession
+	}
+
+	return nil
+}
+
+// ExecStop implements RPCClient.
+func (mc *mockRPCClient) ExecStop(id uuid.UUID) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if _, ok := mc.execs[id]; !ok {
+		return ErrUnknownExecSession
+	}
+
+	delete(mc.execs, id)
+
+	return nil
+}
+
 // Update implements RPCClient.
-func (mc *mockRPCClient) Update() (bool, error) {
+func (mc *mockRPCClient) Update(time.Duration) (bool, error) {
 	return false, nil
 }
 
+// UpdateApp implements RPCClient.
+func (mc *mockRPCClient) UpdateApp(appName string) (bool, error) {
+	err := mc.do(false, func() error {
+		for _, a := range mc.s.Apps {
+			if a.Name == appName {
+				return nil
+			}
+		}
+
+		return ErrUnknownApp
+	})
+
+	return false, err
+}
+
+// Rollback implements RPCClient.
+func (mc *mockRPCClient) Rollback() error {
+	return nil
+}
+
+// ConnectivityTest implements RPCClient.
+func (mc *mockRPCClient) ConnectivityTest(probe cipher.PubKey, _ string) (*ConnectivityReport, error) {
+	report := &ConnectivityReport{Passed: true}
+
+	for _, name := range []string{CheckDmsgDiscovery, CheckDmsgServers, CheckTransportDiscovery, CheckRouteFinder} {
+		report.add(ConnectivityCheck{Name: name, Passed: true})
+	}
+
+	for _, name := range []string{CheckProbeTransport, CheckEcho} {
+		report.add(ConnectivityCheck{Name: name, Passed: !probe.Null(), Skipped: probe.Null()})
+	}
+
+	return report, nil
+}
+
+// BandwidthTest implements RPCClient.
+func (mc *mockRPCClient) BandwidthTest(_ cipher.PubKey, tpType string, d time.Duration) (*BandwidthResult, error) {
+	if d == 0 {
+		d = DefaultBandwidthTestDuration
+	}
+
+	if d < 0 || d > MaxBandwidthTestDuration {
+		return nil, ErrBandwidthTestDuration
+	}
+
+	if tpType == "" {
+		tpType = dmsg.Type
+	}
+
+	return &BandwidthResult{
+		TransportType:      tpType,
+		TransportID:        uuid.New(),
+		TemporaryTransport: true,
+		Duration:           Duration(d),
+		UploadBytes:        int64(d.Seconds() * 12.5e6),
+		UploadMbps:         100,
+		DownloadBytes:      int64(d.Seconds() * 6.25e6),
+		DownloadMbps:       50,
+	}, nil
+}
+
+// ExpectBandwidthTest implements RPCClient.
+func (mc *mockRPCClient) ExpectBandwidthTest(cipher.PubKey) error {
+	return nil
+}
+
 // UpdateAvailable implements RPCClient.
 func (mc *mockRPCClient) UpdateAvailable() (*updater.Version, error) {
 	return nil, nil
 }
+
+// HypervisorPKs implements RPCClient.
+func (mc *mockRPCClient) HypervisorPKs() ([]cipher.PubKey, error) {
+	mc.RLock()
+	defer mc.RUnlock()
+
+	return append([]cipher.PubKey(nil), mc.hvPKs...), nil
+}
+
+// UpdateHypervisorPKs implements RPCClient.
+func (mc *mockRPCClient) UpdateHypervisorPKs(add, remove []cipher.PubKey) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	pks := make(map[cipher.PubKey]struct{}, len(mc.hvPKs)+len(add))
+	for _, pk := range append(mc.hvPKs, add...) {
+		pks[pk] = struct{}{}
+	}
+
+	for _, pk := range remove {
+		delete(pks, pk)
+	}
+
+	mc.hvPKs = mc.hvPKs[:0]
+	for pk := range pks {
+		mc.hvPKs = append(mc.hvPKs, pk)
+	}
+
+	return nil
+}
+
+// WakeOnLAN implements RPCClient.
+func (mc *mockRPCClient) WakeOnLAN(mac, _ string) error {
+	_, err := net.ParseMAC(mac)
+	return err
+}
@@ -3,12 +3,22 @@ package visor
 import (
 	"context"
 	"net/rpc"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/dmsg/netutil"
 
 	"github.com/skycoin/skywire/pkg/snet"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
+)
+
+const (
+	whitelistCheckInterval = 5 * time.Second
+	redialInitBackoff      = time.Second
+	redialMaxBackoff       = time.Minute
 )
 
 func isDone(ctx context.Context) bool {
@@ -20,9 +30,36 @@ func isDone(ctx context.Context) bool {
 	}
 }
 
+// RPCServer serves RPC calls read by server codecs, as *rpc.Server does.
+type RPCServer interface {
+	ServeCodec(codec rpc.ServerCodec)
+}
+
 // ServeRPCClient repetitively dials to a remote dmsg address and serves a RPC server to that address.
-func ServeRPCClient(ctx context.Context, log logrus.FieldLogger, n *snet.Network, rpcS *rpc.Server, rAddr dmsg.Addr, errCh chan<- error) {
+// The RPC server is only served while allowed (if non-nil) returns true for the remote public key.
+// Connections are redialed when the hypervisor stops sending keepalives (see RPC.KeepAlive).
+func ServeRPCClient(ctx context.Context, log logrus.FieldLogger, n *snet.Network, rpcS RPCServer, rAddr dmsg.Addr, errCh chan<- error, allowed func(cipher.PubKey) bool) {
+	isAllowed := func() bool {
+		return allowed == nil || allowed(rAddr.PK)
+	}
+
+	backoff := redialInitBackoff
+
 	for {
+		if !isAllowed() {
+			log.Warn("Hypervisor is not whitelisted, waiting before retrying...")
+
+			select {
+			case <-ctx.Done():
+				if errCh != nil {
+					errCh <- ctx.Err()
+				}
+				return
+			case <-time.After(whitelistCheckInterval):
+				continue
+			}
+		}
+
 		var conn *snet.Conn
 		err := netutil.NewDefaultRetrier(log).Do(ctx, func() (rErr error) {
 			log.Info("Dialing...")
@@ -43,15 +80,94 @@ func ServeRPCClient(ctx context.Context, log logrus.FieldLogger, n *snet.Network
 		}
 
 		log.Info("Serving RPC client...")
+		connectedAt := time.Now()
+		codec := newKeepAliveCodec(rpcutil.NewServerCodec(conn, log))
 		connCtx, cancel := context.WithCancel(ctx)
 		go func() {
-			rpcS.ServeConn(conn)
+			rpcS.ServeCodec(codec)
 			cancel()
 		}()
+		go func() {
+			ticker := time.NewTicker(whitelistCheckInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-connCtx.Done():
+					return
+				case <-ticker.C:
+					if !isAllowed() {
+						log.Warn("Hypervisor was removed from the whitelist, closing connection.")
+						cancel()
+						return
+					}
+					if codec.expired(time.Now()) {
+						log.Warn("Missed keepalives of hypervisor, closing connection.")
+						cancel()
+						return
+					}
+				}
+			}
+		}()
 		<-connCtx.Done()
+		cancel()
 
 		log.WithError(conn.Close()).
 			WithField("context_done", isDone(ctx)).
 			Debug("Conn closed. Redialing...")
+
+		// Connections which are closed right away, such as by a hypervisor rejecting the visor,
+		// are redialed with increasing delays.
+		if time.Since(connectedAt) > redialMaxBackoff {
+			backoff = redialInitBackoff
+			continue
+		}
+
+		log.WithField("backoff", backoff).Debug("Waiting before redialing...")
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > redialMaxBackoff {
+			backoff = redialMaxBackoff
+		}
+	}
+}
+
+// keepAliveCodec records the keepalive calls of the hypervisor, so that dead connections are detected.
+type keepAliveCodec struct {
+	rpc.ServerCodec
+
+	mu      sync.Mutex
+	last    time.Time     // time of the last keepalive
+	timeout time.Duration // as announced by the hypervisor, zero until the first keepalive
+}
+
+func newKeepAliveCodec(codec rpc.ServerCodec) *keepAliveCodec {
+	return &keepAliveCodec{ServerCodec: codec}
+}
+
+func (c *keepAliveCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+
+	if in, ok := body.(*KeepAliveIn); ok {
+		c.mu.Lock()
+		c.last, c.timeout = time.Now(), in.Timeout
+		c.mu.Unlock()
 	}
+
+	return nil
+}
+
+// expired returns true if the hypervisor sent keepalives, but not within the timeout it announced.
+// Connections of hypervisors which don't send keepalives never expire.
+func (c *keepAliveCodec) expired(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.timeout > 0 && now.Sub(c.last) > c.timeout
 }
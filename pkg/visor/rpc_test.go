@@ -1,9 +1,13 @@
 package visor
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/rpc"
 	"os"
 	"path/filepath"
 	"testing"
@@ -22,6 +26,7 @@ import (
 	"github.com/skycoin/skywire/pkg/router"
 	"github.com/skycoin/skywire/pkg/routing"
 	"github.com/skycoin/skywire/pkg/util/pathutil"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
 )
 
 func TestHealth(t *testing.T) {
@@ -416,3 +421,130 @@ These tests have been commented out for the following reasons:
 //	// TODO: Test add/remove transports
 //
 //}
+
+func TestHypervisorPKs(t *testing.T) {
+	f, err := ioutil.TempFile("", "visor-config")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer func() { require.NoError(t, os.Remove(f.Name())) }()
+
+	hv1, _ := cipher.GenerateKeyPair()
+	hv2, _ := cipher.GenerateKeyPair()
+
+	path := f.Name()
+	conf := &Config{
+		Path:        &path,
+		log:         logging.MustGetLogger("test"),
+		Hypervisors: []HypervisorConfig{{PubKey: hv1}},
+	}
+
+	wl, err := newHypervisorWhitelist(conf.HypervisorWhitelist(), conf.ReadOnlyHypervisors())
+	require.NoError(t, err)
+
+	rpc := &RPC{visor: &Visor{conf: conf, hvWhitelist: wl}, log: logrus.New()}
+
+	var pks []cipher.PubKey
+	require.NoError(t, rpc.HypervisorPKs(nil, &pks))
+	assert.Equal(t, []cipher.PubKey{hv1}, pks)
+
+	in := &UpdateHypervisorPKsIn{Add: []cipher.PubKey{hv2}, Remove: []cipher.PubKey{hv1}}
+	require.NoError(t, rpc.UpdateHypervisorPKs(in, nil))
+	require.NoError(t, rpc.HypervisorPKs(nil, &pks))
+	assert.Equal(t, []cipher.PubKey{hv2}, pks)
+	assert.False(t, wl.Allowed(hv1))
+	assert.True(t, wl.Allowed(hv2))
+
+	var saved Config
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &saved))
+	assert.Equal(t, []cipher.PubKey{hv2}, saved.HypervisorWhitelist())
+
+	// Removing all keys keeps every hypervisor out, rather than falling back to 'hypervisors'.
+	require.NoError(t, rpc.UpdateHypervisorPKs(&UpdateHypervisorPKsIn{Remove: []cipher.PubKey{hv2}}, nil))
+	raw, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	saved = Config{}
+	require.NoError(t, json.Unmarshal(raw, &saved))
+	saved.Hypervisors = conf.Hypervisors
+	assert.Empty(t, saved.HypervisorWhitelist())
+}
+
+func TestKeepAliveCodec(t *testing.T) {
+	rpcS := rpc.NewServer()
+	require.NoError(t, rpcS.RegisterName(RPCPrefix, &RPC{visor: &Visor{}, log: logrus.New()}))
+
+	connS, connC := net.Pipe()
+	codec := newKeepAliveCodec(rpcutil.NewServerCodec(connS, logrus.New()))
+
+	go rpcS.ServeCodec(codec)
+
+	rpcC := NewRPCClient(rpc.NewClientWithCodec(rpcutil.NewClientCodec(connC)), RPCPrefix)
+
+	// Connections don't expire before the first keepalive.
+	assert.False(t, codec.expired(time.Now().Add(time.Hour)))
+
+	require.NoError(t, rpcC.KeepAlive(time.Second, time.Minute))
+	assert.False(t, codec.expired(time.Now()))
+	assert.True(t, codec.expired(time.Now().Add(2*time.Minute)))
+
+	require.NoError(t, connC.Close())
+}
+
+func TestReadOnlyHypervisor(t *testing.T) {
+	hv1, _ := cipher.GenerateKeyPair()
+	hv2, _ := cipher.GenerateKeyPair()
+
+	conf := &Config{Hypervisors: []HypervisorConfig{{PubKey: hv1}, {PubKey: hv2, ReadOnly: true}}}
+
+	wl, err := newHypervisorWhitelist(conf.HypervisorWhitelist(), conf.ReadOnlyHypervisors())
+	require.NoError(t, err)
+	assert.True(t, wl.Allowed(hv2))
+	assert.False(t, wl.ReadOnly(hv1))
+	assert.True(t, wl.ReadOnly(hv2))
+
+	// Read-only hypervisors have no pty access.
+	ok, err := wl.pty.Get(hv1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = wl.pty.Get(hv2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	rpcS := rpc.NewServer()
+	require.NoError(t, rpcS.RegisterName(RPCPrefix, &RPC{visor: &Visor{startedAt: time.Now()}, log: logrus.New()}))
+
+	connS, connC := net.Pipe()
+	go readOnlyRPCServer{RPCServer: rpcS}.ServeCodec(rpcutil.NewServerCodec(connS, logrus.New()))
+
+	rpcC := NewRPCClient(rpc.NewClientWithCodec(rpcutil.NewClientCodec(connC)), RPCPrefix)
+
+	_, err = rpcC.Uptime()
+	assert.NoError(t, err)
+
+	err = rpcC.StartApp("skychat")
+	require.Error(t, err)
+	assert.Equal(t, ErrReadOnlyHypervisor.Error(), err.Error())
+
+	// The connection is still served after refusing a call.
+	_, err = rpcC.Uptime()
+	assert.NoError(t, err)
+
+	require.NoError(t, connC.Close())
+}
+
+func TestRecognizeRPCError(t *testing.T) {
+	err := recognizeRPCError(rpc.ServerError(ErrInvalidConfig.Error() + ": apps.0.port: is taken"))
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	assert.Equal(t, "invalid config: apps.0.port: is taken", err.Error())
+
+	err = recognizeRPCError(rpc.ServerError(ErrAppNotSigned.Error()))
+	assert.True(t, errors.Is(err, ErrAppNotSigned))
+
+	err = recognizeRPCError(rpc.ServerError(ErrInvalidConfig.Error() + "uration"))
+	assert.False(t, errors.Is(err, ErrInvalidConfig))
+	assert.Equal(t, rpc.ServerError("invalid configuration"), err)
+
+	assert.Equal(t, rpc.ErrShutdown, recognizeRPCError(rpc.ErrShutdown))
+	assert.Nil(t, recognizeRPCError(nil))
+}
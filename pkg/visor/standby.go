@@ -0,0 +1,77 @@
+package visor
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/netutil"
+
+	"github.com/skycoin/skywire/pkg/snet"
+	"github.com/skycoin/skywire/pkg/util/buildinfo"
+)
+
+// StandbyHeartbeatInterval is how often standby visors send heartbeats.
+const StandbyHeartbeatInterval = 30 * time.Second
+
+// Heartbeat is sent periodically by standby visors, which register with a hypervisor without serving RPC.
+type Heartbeat struct {
+	BuildInfo *buildinfo.Info `json:"build_info"`
+	StartedAt time.Time       `json:"started_at"`
+	Uptime    float64         `json:"uptime"` // Seconds.
+}
+
+func (visor *Visor) heartbeat() Heartbeat {
+	return Heartbeat{
+		BuildInfo: buildinfo.Get(),
+		StartedAt: visor.startedAt,
+		Uptime:    time.Since(visor.startedAt).Seconds(),
+	}
+}
+
+// ServeHeartbeats repetitively dials to a remote dmsg address and sends heartbeats to it, as JSON objects.
+// It is used instead of ServeRPCClient by standby visors.
+func ServeHeartbeats(ctx context.Context, log logrus.FieldLogger, n *snet.Network, rAddr dmsg.Addr, errCh chan<- error, heartbeat func() Heartbeat) {
+	for {
+		var conn *snet.Conn
+		err := netutil.NewDefaultRetrier(log).Do(ctx, func() (rErr error) {
+			log.Info("Dialing...")
+			conn, rErr = n.Dial(ctx, snet.DmsgType, rAddr.PK, rAddr.Port)
+			return rErr
+		})
+		if err != nil {
+			if errCh != nil {
+				errCh <- err
+			}
+			log.WithError(err).Info("Stopped sending heartbeats.")
+			return
+		}
+
+		log.Info("Sending heartbeats...")
+		err = sendHeartbeats(ctx, json.NewEncoder(conn), heartbeat)
+
+		log.WithError(conn.Close()).
+			WithField("context_done", isDone(ctx)).
+			Debugf("Conn closed (%v). Redialing...", err)
+	}
+}
+
+// sendHeartbeats encodes heartbeats until ctx is done or encoding fails.
+func sendHeartbeats(ctx context.Context, enc *json.Encoder, heartbeat func() Heartbeat) error {
+	ticker := time.NewTicker(StandbyHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := enc.Encode(heartbeat()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package visor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// failure records the error which stopped a subsystem. The zero value is ready to use.
+type failure struct {
+	mu  sync.Mutex
+	err error
+}
+
+// set records err, unless a failure was recorded before.
+func (f *failure) set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+func (f *failure) get() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+// Ready returns a channel which is closed once the subsystems of the visor are up. The router is started last
+// by Start, and is up once its transport manager listens on all its networks and has initialized its transports.
+func (visor *Visor) Ready() <-chan struct{} {
+	return visor.tm.Ready()
+}
+
+// Degraded returns the failure of the first subsystem of the visor which stopped serving, among the router,
+// the transport manager and the app server, or nil while they all serve. A degraded visor is to be restarted.
+func (visor *Visor) Degraded() error {
+	if err := visor.router.Failure(); err != nil {
+		return fmt.Errorf("router: %w", err)
+	}
+
+	if err := visor.tm.Failure(); err != nil {
+		return fmt.Errorf("transport manager: %w", err)
+	}
+
+	if err := visor.appServerFailure.get(); err != nil {
+		return fmt.Errorf("app server: %w", err)
+	}
+
+	return nil
+}
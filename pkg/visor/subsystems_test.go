@@ -0,0 +1,35 @@
+package visor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/router"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+func TestVisorDegraded(t *testing.T) {
+	r := &router.MockRouter{}
+	r.On("Failure").Return(nil)
+
+	visor := &Visor{
+		router: r,
+		tm:     &transport.Manager{},
+	}
+
+	require.NoError(t, visor.Degraded())
+
+	// Only the first failure of a subsystem is reported.
+	visor.appServerFailure.set(errors.New("listen failed"))
+	visor.appServerFailure.set(errors.New("other"))
+	assert.EqualError(t, visor.Degraded(), "app server: listen failed")
+
+	r = &router.MockRouter{}
+	r.On("Failure").Return(errors.New("stopped reading packets"))
+	visor.router = r
+
+	assert.EqualError(t, visor.Degraded(), "router: stopped reading packets")
+}
@@ -0,0 +1,418 @@
+package visor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+// Constants associated with transport maintenance.
+const (
+	// Transport targets are checked this often, so dropped transports are replaced within this delay.
+	tpMaintainInterval = 30 * time.Second
+
+	// Transports established for targets which stay down longer are replaced.
+	tpMaintainDownTimeout = 2 * time.Minute
+
+	// Visors which could not be reached are not tried again before this delay.
+	tpMaintainBackoff = 10 * time.Minute
+
+	tpMaintainDialTimeout = 30 * time.Second
+)
+
+// TransportTarget is a number of transports of a type which the visor keeps established to public visors.
+// Visors are taken from the candidates, then discovered in transport discovery, as the visors which have
+// public transports with the remotes of the visor (or with the candidates).
+type TransportTarget struct {
+	Type       string          `json:"type"`
+	Count      int             `json:"count"`
+	Candidates []cipher.PubKey `json:"candidates,omitempty"` // Visors tried before discovered ones.
+}
+
+// Validate checks the transport target.
+func (t TransportTarget) Validate() error {
+	if t.Type == "" {
+		return errors.New("transport target has no type")
+	}
+
+	if t.Count <= 0 {
+		return fmt.Errorf("transport target of %q should have a positive count", t.Type)
+	}
+
+	return nil
+}
+
+// ValidateTransportTargets checks the transport targets, which should have unique types.
+func ValidateTransportTargets(targets []TransportTarget) error {
+	types := make(map[string]bool, len(targets))
+
+	for _, t := range targets {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+
+		if types[t.Type] {
+			return fmt.Errorf("transport target of %q is defined more than once", t.Type)
+		}
+
+		types[t.Type] = true
+	}
+
+	return nil
+}
+
+// TransportTargetStatus is the state of a transport target.
+type TransportTargetStatus struct {
+	TransportTarget
+	Established []cipher.PubKey `json:"established"` // Remotes of the transports of the type which are up.
+}
+
+// UnreachableVisor is a visor to which a transport could not be established for a target, or went down.
+type UnreachableVisor struct {
+	Remote cipher.PubKey `json:"remote_pk"`
+	Type   string        `json:"type"`
+	Error  string        `json:"error"`
+	Until  time.Time     `json:"until"` // When the visor may be tried again.
+}
+
+// TransportMaintenanceStatus is the state of the transport targets of a visor.
+type TransportMaintenanceStatus struct {
+	Targets     []TransportTargetStatus `json:"targets"`
+	Unreachable []UnreachableVisor      `json:"unreachable"`
+}
+
+// tpMaintainer keeps the transports of the transport targets of a visor established.
+type tpMaintainer struct {
+	tm       *transport.Manager
+	policies *tpPolicies // transports disabled by policies are not established, if set
+	log      logrus.FieldLogger
+
+	maintainMu sync.Mutex // serializes maintain
+
+	mu          sync.Mutex
+	targets     []TransportTarget
+	maintained  map[tpKey]time.Time // transports established for targets, and since when they are down
+	unreachable map[tpKey]UnreachableVisor
+}
+
+func newTpMaintainer(tm *transport.Manager, policies *tpPolicies, log logrus.FieldLogger, targets []TransportTarget) *tpMaintainer {
+	return &tpMaintainer{
+		tm:          tm,
+		policies:    policies,
+		log:         log,
+		targets:     targets,
+		maintained:  make(map[tpKey]time.Time),
+		unreachable: make(map[tpKey]UnreachableVisor),
+	}
+}
+
+func (m *tpMaintainer) serve(ctx context.Context) {
+	ticker := time.NewTicker(tpMaintainInterval)
+	defer ticker.Stop()
+
+	for {
+		m.maintain(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maintain establishes transports for the targets which are short of transports at t, replacing those which
+// have been down for too long.
+func (m *tpMaintainer) maintain(ctx context.Context, t time.Time) {
+	m.maintainMu.Lock()
+	defer m.maintainMu.Unlock()
+
+	m.mu.Lock()
+	targets := m.targets
+
+	for k, u := range m.unreachable {
+		if !t.Before(u.Until) {
+			delete(m.unreachable, k)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+
+		m.maintainTarget(ctx, target, t)
+	}
+}
+
+func (m *tpMaintainer) maintainTarget(ctx context.Context, target TransportTarget, t time.Time) {
+	var remotes []cipher.PubKey
+
+	count := 0
+	existing := make(map[tpKey]bool)
+
+	m.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		remotes = append(remotes, tp.Remote())
+
+		if tp.Type() != target.Type {
+			return true
+		}
+
+		k := tpKey{tp.Remote(), tp.Type()}
+		existing[k] = true
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		downSince, maintained := m.maintained[k]
+
+		switch {
+		case tp.IsUp():
+			count++
+
+			if maintained {
+				m.maintained[k] = time.Time{}
+			}
+		case !maintained:
+			// Transports established by operators are left alone, but don't count.
+		case downSince.IsZero():
+			count++
+			m.maintained[k] = t
+		case t.Sub(downSince) < tpMaintainDownTimeout:
+			// The remote may still come back.
+			count++
+		}
+
+		return true
+	})
+
+	m.mu.Lock()
+	var expired []tpKey
+	for k, downSince := range m.maintained {
+		switch {
+		case k.tpType != target.Type:
+		case !existing[k]:
+			// Removed by an operator, or closed by the remote.
+			delete(m.maintained, k)
+		case !downSince.IsZero() && t.Sub(downSince) >= tpMaintainDownTimeout:
+			expired = append(expired, k)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, k := range expired {
+		m.log.WithField("remote_pk", k.remote).WithField("type", k.tpType).
+			Info("Replacing transport of transport target which is down.")
+		m.drop(k, errors.New("transport went down"), t)
+	}
+
+	if count >= target.Count {
+		return
+	}
+
+	for _, remote := range m.candidates(ctx, target, remotes) {
+		if count >= target.Count || ctx.Err() != nil {
+			return
+		}
+
+		if m.establish(ctx, remote, target.Type, t) {
+			count++
+		}
+	}
+
+	if count < target.Count {
+		m.log.WithField("type", target.Type).WithField("established", count).WithField("target", target.Count).
+			Warn("Not enough visors to meet transport target.")
+	}
+}
+
+// establish establishes a transport of the type to the remote visor, and returns whether it is up.
+func (m *tpMaintainer) establish(ctx context.Context, remote cipher.PubKey, tpType string, t time.Time) bool {
+	k := tpKey{remote, tpType}
+
+	ctx, cancel := context.WithTimeout(ctx, tpMaintainDialTimeout)
+	defer cancel()
+
+	tp, err := m.tm.SaveTransport(ctx, remote, tpType)
+	if err == nil && !tp.IsUp() {
+		err = errors.New("transport is not up")
+		m.tm.DeleteTransport(tp.Entry.ID)
+	}
+
+	if err != nil {
+		m.log.WithError(err).WithField("remote_pk", remote).WithField("type", tpType).
+			Debug("Failed to establish transport for transport target.")
+
+		m.mu.Lock()
+		m.unreachable[k] = UnreachableVisor{Remote: remote, Type: tpType, Error: err.Error(), Until: t.Add(tpMaintainBackoff).UTC()}
+		m.mu.Unlock()
+
+		return false
+	}
+
+	m.log.WithField("remote_pk", remote).WithField("type", tpType).
+		Info("Established transport for transport target.")
+
+	m.mu.Lock()
+	m.maintained[k] = time.Time{}
+	m.mu.Unlock()
+
+	return true
+}
+
+// drop deletes the maintained transport of k, of which the remote is not tried again for a while.
+func (m *tpMaintainer) drop(k tpKey, reason error, t time.Time) {
+	m.tm.DeleteTransport(transport.MakeTransportID(m.tm.Local(), k.remote, k.tpType))
+
+	m.mu.Lock()
+	delete(m.maintained, k)
+	m.unreachable[k] = UnreachableVisor{Remote: k.remote, Type: k.tpType, Error: reason.Error(), Until: t.Add(tpMaintainBackoff).UTC()}
+	m.mu.Unlock()
+}
+
+// candidates returns the visors to establish transports of the target to, excluding the remotes of existing
+// transports, unreachable visors and those disabled by transport policies.
+func (m *tpMaintainer) candidates(ctx context.Context, target TransportTarget, remotes []cipher.PubKey) []cipher.PubKey {
+	local := m.tm.Local()
+	seen := map[cipher.PubKey]bool{local: true}
+
+	m.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		if tp.Type() == target.Type {
+			seen[tp.Remote()] = true
+		}
+
+		return true
+	})
+
+	var candidates []cipher.PubKey
+
+	add := func(pk cipher.PubKey) {
+		if seen[pk] {
+			return
+		}
+
+		seen[pk] = true
+
+		m.mu.Lock()
+		_, unreachable := m.unreachable[tpKey{pk, target.Type}]
+		m.mu.Unlock()
+
+		if unreachable {
+			return
+		}
+
+		if m.policies != nil {
+			if _, disabled := m.policies.activePolicy(pk, target.Type, time.Now()); disabled {
+				return
+			}
+		}
+
+		candidates = append(candidates, pk)
+	}
+
+	for _, pk := range target.Candidates {
+		add(pk)
+	}
+
+	// Visors with public transports of the type come first, as they are known to support it.
+	var others []cipher.PubKey
+
+	for _, seed := range append(append([]cipher.PubKey{local}, target.Candidates...), remotes...) {
+		entries, err := m.tm.Conf.DiscoveryClient.GetTransportsByEdge(ctx, seed)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			if !e.IsUp || !e.Entry.Public {
+				continue
+			}
+
+			if e.Entry.Type == target.Type {
+				add(e.Entry.RemoteEdge(seed))
+			} else {
+				others = append(others, e.Entry.RemoteEdge(seed))
+			}
+		}
+	}
+
+	for _, pk := range others {
+		add(pk)
+	}
+
+	return candidates
+}
+
+func (m *tpMaintainer) set(targets []TransportTarget) {
+	m.mu.Lock()
+	m.targets = targets
+	m.mu.Unlock()
+}
+
+func (m *tpMaintainer) status() *TransportMaintenanceStatus {
+	m.mu.Lock()
+	s := &TransportMaintenanceStatus{
+		Targets:     make([]TransportTargetStatus, 0, len(m.targets)),
+		Unreachable: make([]UnreachableVisor, 0, len(m.unreachable)),
+	}
+
+	for _, target := range m.targets {
+		s.Targets = append(s.Targets, TransportTargetStatus{TransportTarget: target, Established: make([]cipher.PubKey, 0)})
+	}
+
+	for _, u := range m.unreachable {
+		s.Unreachable = append(s.Unreachable, u)
+	}
+	m.mu.Unlock()
+
+	m.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		for i := range s.Targets {
+			if s.Targets[i].Type == tp.Type() && tp.IsUp() {
+				s.Targets[i].Established = append(s.Targets[i].Established, tp.Remote())
+			}
+		}
+
+		return true
+	})
+
+	sort.Slice(s.Unreachable, func(i, j int) bool {
+		return s.Unreachable[i].Until.Before(s.Unreachable[j].Until)
+	})
+
+	return s
+}
+
+// TransportMaintenance returns the transport targets of the visor, and their state.
+func (visor *Visor) TransportMaintenance() *TransportMaintenanceStatus {
+	return visor.tpMaintainer.status()
+}
+
+// SetTransportTargets replaces the transport targets of the visor, saves them in the config and maintains them.
+func (visor *Visor) SetTransportTargets(targets []TransportTarget) error {
+	if err := ValidateTransportTargets(targets); err != nil {
+		return err
+	}
+
+	visor.tpMaintainer.set(targets)
+
+	visor.conf.flushMu.Lock()
+	visor.conf.Transport.Maintain = targets
+	visor.conf.flushMu.Unlock()
+
+	if err := visor.conf.flush(); err != nil {
+		return err
+	}
+
+	go visor.tpMaintainer.maintain(context.Background(), time.Now())
+
+	return nil
+}
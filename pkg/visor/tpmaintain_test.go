@@ -0,0 +1,89 @@
+package visor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg"
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/pkg/snet/snettest"
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+func TestValidateTransportTargets(t *testing.T) {
+	valid := TransportTarget{Type: "stcp", Count: 3}
+	assert.NoError(t, ValidateTransportTargets([]TransportTarget{valid, {Type: "dmsg", Count: 2}}))
+
+	for name, targets := range map[string][]TransportTarget{
+		"no type":   {{Count: 3}},
+		"no count":  {{Type: "stcp"}},
+		"negative":  {{Type: "stcp", Count: -1}},
+		"duplicate": {valid, valid},
+	} {
+		assert.Error(t, ValidateTransportTargets(targets), name)
+	}
+}
+
+func TestTpMaintainer_Maintain(t *testing.T) {
+	tpDisc := transport.NewDiscoveryMock()
+
+	keys := snettest.GenKeyPairs(4)
+	nEnv := snettest.NewEnv(t, keys, []string{dmsg.Type})
+	defer nEnv.Teardown()
+
+	tms := make([]*transport.Manager, len(keys))
+
+	for i, pair := range keys {
+		tm, err := transport.NewManager(nEnv.Nets[i], &transport.ManagerConfig{
+			PubKey:          pair.PK,
+			SecKey:          pair.SK,
+			DiscoveryClient: tpDisc,
+			LogStore:        transport.InMemoryTransportLogStore(),
+		})
+		require.NoError(t, err)
+
+		go tm.Serve(context.TODO())
+		tms[i] = tm
+	}
+
+	defer func() {
+		for _, tm := range tms {
+			require.NoError(t, tm.Close())
+		}
+	}()
+
+	// Visor 2 is public, and is discovered from its transport with visor 1.
+	_, err := tms[2].SaveTransport(context.TODO(), keys[1].PK, dmsg.Type)
+	require.NoError(t, err)
+
+	// Visor 3 is disabled by a transport policy.
+	always := TransportPolicy{Name: "always", Remotes: []cipher.PubKey{keys[3].PK}, Windows: []TimeWindow{
+		{Start: "00:00", End: "12:00"},
+		{Start: "12:00", End: "00:00"},
+	}}
+	_, err = tms[3].SaveTransport(context.TODO(), keys[1].PK, dmsg.Type)
+	require.NoError(t, err)
+
+	policies := newTpPolicies(tms[0], logging.MustGetLogger("test"), []TransportPolicy{always})
+	target := TransportTarget{Type: dmsg.Type, Count: 3, Candidates: []cipher.PubKey{keys[1].PK}}
+	m := newTpMaintainer(tms[0], policies, logging.MustGetLogger("test"), []TransportTarget{target})
+
+	m.maintain(context.TODO(), time.Now())
+
+	s := m.status()
+	require.Len(t, s.Targets, 1)
+	assert.Equal(t, target, s.Targets[0].TransportTarget)
+	assert.ElementsMatch(t, []cipher.PubKey{keys[1].PK, keys[2].PK}, s.Targets[0].Established)
+
+	// Transports removed by operators are replaced.
+	tms[0].DeleteTransport(transport.MakeTransportID(keys[0].PK, keys[2].PK, dmsg.Type))
+	require.Len(t, m.status().Targets[0].Established, 1)
+
+	m.maintain(context.TODO(), time.Now())
+	assert.ElementsMatch(t, []cipher.PubKey{keys[1].PK, keys[2].PK}, m.status().Targets[0].Established)
+}
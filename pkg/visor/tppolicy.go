@@ -0,0 +1,383 @@
+package visor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skycoin/dmsg/cipher"
+
+	"github.com/skycoin/skywire/pkg/transport"
+)
+
+// Transport policies are evaluated this often, so windows take effect within this delay.
+const tpPolicyCheckInterval = time.Minute
+
+// Errors associated with transport policies.
+var (
+	ErrBadTimeWindow             = errors.New("time window is invalid")
+	ErrTransportDisabledByPolicy = errors.New("transport is disabled by a transport policy")
+)
+
+var weekdays = map[string]time.Weekday{ // nolint: gochecknoglobals
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeWindow is a daily time range, optionally limited to some days of the week.
+// A window ending before it starts spans midnight, and belongs to the day it starts on.
+type TimeWindow struct {
+	Days     []string `json:"days,omitempty"`     // Three letter days of the week ("mon", "tue", ...), defaults to every day.
+	Start    string   `json:"start"`              // Inclusive, as "15:04".
+	End      string   `json:"end"`                // Exclusive, as "15:04".
+	Location string   `json:"location,omitempty"` // IANA time zone, defaults to the visor's local time.
+}
+
+// Validate checks the time window.
+func (tw TimeWindow) Validate() error {
+	_, err := tw.parse()
+	return err
+}
+
+type parsedWindow struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration
+	loc        *time.Location
+}
+
+func (tw TimeWindow) parse() (parsedWindow, error) {
+	pw := parsedWindow{days: make(map[time.Weekday]bool), loc: time.Local}
+
+	for _, d := range tw.Days {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return pw, fmt.Errorf("%w: unknown day %q", ErrBadTimeWindow, d)
+		}
+
+		pw.days[wd] = true
+	}
+
+	var err error
+
+	if pw.start, err = parseTimeOfDay(tw.Start); err != nil {
+		return pw, err
+	}
+
+	if pw.end, err = parseTimeOfDay(tw.End); err != nil {
+		return pw, err
+	}
+
+	if pw.start == pw.end {
+		return pw, fmt.Errorf("%w: start and end are equal", ErrBadTimeWindow)
+	}
+
+	if tw.Location != "" {
+		if pw.loc, err = time.LoadLocation(tw.Location); err != nil {
+			return pw, fmt.Errorf("%w: %v", ErrBadTimeWindow, err)
+		}
+	}
+
+	return pw, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: time of day %q should be formatted as HH:MM", ErrBadTimeWindow, s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (pw parsedWindow) contains(t time.Time) bool {
+	t = t.In(pw.loc)
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	if pw.start < pw.end {
+		return pw.onDay(day) && sinceMidnight >= pw.start && sinceMidnight < pw.end
+	}
+
+	// The window spans midnight: it is either in its first part today, or its second part after yesterday's start.
+	return (pw.onDay(day) && sinceMidnight >= pw.start) ||
+		(pw.onDay((day+6)%7) && sinceMidnight < pw.end)
+}
+
+func (pw parsedWindow) onDay(day time.Weekday) bool {
+	return len(pw.days) == 0 || pw.days[day]
+}
+
+// TransportPolicy disables matching transports during its time windows,
+// such as expensive cellular transports during peak billing hours.
+// Transports torn down by a policy are established again once none of its windows are active.
+type TransportPolicy struct {
+	Name    string          `json:"name"`
+	Types   []string        `json:"types,omitempty"`      // Transport types the policy applies to, defaults to all.
+	Remotes []cipher.PubKey `json:"remote_pks,omitempty"` // Remote visors the policy applies to, defaults to all.
+	Windows []TimeWindow    `json:"windows"`              // When matching transports are disabled.
+}
+
+// Validate checks the transport policy.
+func (p TransportPolicy) Validate() error {
+	if p.Name == "" {
+		return errors.New("transport policy has no name")
+	}
+
+	if len(p.Windows) == 0 {
+		return fmt.Errorf("transport policy %q has no time windows", p.Name)
+	}
+
+	for _, w := range p.Windows {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("transport policy %q: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTransportPolicies checks the transport policies, which should have unique names.
+func ValidateTransportPolicies(policies []TransportPolicy) error {
+	names := make(map[string]bool, len(policies))
+
+	for _, p := range policies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		if names[p.Name] {
+			return fmt.Errorf("transport policy %q is defined more than once", p.Name)
+		}
+
+		names[p.Name] = true
+	}
+
+	return nil
+}
+
+// Active returns whether any of the policy's windows contain t.
+func (p TransportPolicy) Active(t time.Time) bool {
+	for _, w := range p.Windows {
+		if pw, err := w.parse(); err == nil && pw.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches returns whether the policy applies to transports of the type to the remote visor.
+func (p TransportPolicy) Matches(remote cipher.PubKey, tpType string) bool {
+	return matchesAny(len(p.Types), func(i int) bool { return p.Types[i] == tpType }) &&
+		matchesAny(len(p.Remotes), func(i int) bool { return p.Remotes[i] == remote })
+}
+
+func matchesAny(n int, match func(i int) bool) bool {
+	if n == 0 {
+		return true
+	}
+
+	for i := 0; i < n; i++ {
+		if match(i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SuspendedTransport is a transport torn down by a transport policy, to be established again when it is inactive.
+type SuspendedTransport struct {
+	Remote cipher.PubKey `json:"remote_pk"`
+	Type   string        `json:"type"`
+	Policy string        `json:"policy"`
+	Since  time.Time     `json:"since"`
+}
+
+// TransportPolicyStatus is the state of transport policies of a visor.
+type TransportPolicyStatus struct {
+	Policies  []TransportPolicy    `json:"policies"`
+	Active    []string             `json:"active"` // Names of the currently active policies.
+	Suspended []SuspendedTransport `json:"suspended"`
+}
+
+type tpKey struct {
+	remote cipher.PubKey
+	tpType string
+}
+
+// tpPolicies enforces transport policies on the transports of a visor.
+type tpPolicies struct {
+	tm  *transport.Manager
+	log logrus.FieldLogger
+
+	mu        sync.Mutex
+	policies  []TransportPolicy
+	suspended map[tpKey]SuspendedTransport
+}
+
+func newTpPolicies(tm *transport.Manager, log logrus.FieldLogger, policies []TransportPolicy) *tpPolicies {
+	return &tpPolicies{
+		tm:        tm,
+		log:       log,
+		policies:  policies,
+		suspended: make(map[tpKey]SuspendedTransport),
+	}
+}
+
+// activePolicy returns the name of the first policy disabling transports of the type to the remote visor at t, if any.
+func (p *tpPolicies) activePolicy(remote cipher.PubKey, tpType string, t time.Time) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return activePolicy(p.policies, remote, tpType, t)
+}
+
+func activePolicy(policies []TransportPolicy, remote cipher.PubKey, tpType string, t time.Time) (string, bool) {
+	for _, policy := range policies {
+		if policy.Matches(remote, tpType) && policy.Active(t) {
+			return policy.Name, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *tpPolicies) serve(ctx context.Context) {
+	ticker := time.NewTicker(tpPolicyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.enforce(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enforce tears down transports disabled by policies at t, and establishes suspended transports no longer disabled.
+func (p *tpPolicies) enforce(ctx context.Context, t time.Time) {
+	p.mu.Lock()
+	policies := p.policies
+	p.mu.Unlock()
+
+	var disabled []*transport.ManagedTransport
+
+	p.tm.WalkTransports(func(tp *transport.ManagedTransport) bool {
+		if name, ok := activePolicy(policies, tp.Remote(), tp.Type(), t); ok {
+			disabled = append(disabled, tp)
+
+			p.mu.Lock()
+			p.suspended[tpKey{tp.Remote(), tp.Type()}] = SuspendedTransport{
+				Remote: tp.Remote(),
+				Type:   tp.Type(),
+				Policy: name,
+				Since:  t.UTC(),
+			}
+			p.mu.Unlock()
+		}
+
+		return true
+	})
+
+	for _, tp := range disabled {
+		p.log.WithField("remote_pk", tp.Remote()).WithField("type", tp.Type()).
+			Info("Tearing down transport disabled by transport policy.")
+		p.tm.DeleteTransport(tp.Entry.ID)
+	}
+
+	p.mu.Lock()
+	var resumed []tpKey
+	for k := range p.suspended {
+		if _, ok := activePolicy(policies, k.remote, k.tpType, t); !ok {
+			resumed = append(resumed, k)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, k := range resumed {
+		if _, err := p.tm.SaveTransport(ctx, k.remote, k.tpType); err != nil {
+			// Retried on the next check.
+			p.log.WithError(err).WithField("remote_pk", k.remote).WithField("type", k.tpType).
+				Warn("Failed to establish transport suspended by transport policy.")
+			continue
+		}
+
+		p.mu.Lock()
+		delete(p.suspended, k)
+		p.mu.Unlock()
+	}
+}
+
+func (p *tpPolicies) set(policies []TransportPolicy) {
+	p.mu.Lock()
+	p.policies = policies
+	p.mu.Unlock()
+}
+
+func (p *tpPolicies) status(t time.Time) *TransportPolicyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := &TransportPolicyStatus{
+		Policies:  append([]TransportPolicy{}, p.policies...),
+		Active:    make([]string, 0),
+		Suspended: make([]SuspendedTransport, 0, len(p.suspended)),
+	}
+
+	for _, policy := range p.policies {
+		if policy.Active(t) {
+			s.Active = append(s.Active, policy.Name)
+		}
+	}
+
+	for _, st := range p.suspended {
+		s.Suspended = append(s.Suspended, st)
+	}
+
+	sort.Slice(s.Suspended, func(i, j int) bool {
+		return s.Suspended[i].Since.Before(s.Suspended[j].Since)
+	})
+
+	return s
+}
+
+// TransportPolicies returns the transport policies of the visor, and their state.
+func (visor *Visor) TransportPolicies() *TransportPolicyStatus {
+	return visor.tpPolicies.status(time.Now())
+}
+
+// SetTransportPolicies replaces the transport policies of the visor, saves them in the config and enforces them.
+func (visor *Visor) SetTransportPolicies(policies []TransportPolicy) error {
+	if err := ValidateTransportPolicies(policies); err != nil {
+		return err
+	}
+
+	visor.tpPolicies.set(policies)
+
+	visor.conf.flushMu.Lock()
+	visor.conf.Transport.Policies = policies
+	visor.conf.flushMu.Unlock()
+
+	if err := visor.conf.flush(); err != nil {
+		return err
+	}
+
+	go visor.tpPolicies.enforce(context.Background(), time.Now())
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeWindow_Contains(t *testing.T) {
+	// 2020-06-01 is a Monday.
+	at := func(day int, hhmm string) time.Time {
+		tod, err := time.Parse("15:04", hhmm)
+		require.NoError(t, err)
+
+		return time.Date(2020, 6, day, tod.Hour(), tod.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		window TimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside", TimeWindow{Start: "08:00", End: "18:00"}, at(1, "12:00"), true},
+		{"start is inclusive", TimeWindow{Start: "08:00", End: "18:00"}, at(1, "08:00"), true},
+		{"end is exclusive", TimeWindow{Start: "08:00", End: "18:00"}, at(1, "18:00"), false},
+		{"other day", TimeWindow{Days: []string{"tue"}, Start: "08:00", End: "18:00"}, at(1, "12:00"), false},
+		{"listed day", TimeWindow{Days: []string{"Mon"}, Start: "08:00", End: "18:00"}, at(1, "12:00"), true},
+		{"spans midnight, evening", TimeWindow{Start: "22:00", End: "06:00"}, at(1, "23:00"), true},
+		{"spans midnight, morning", TimeWindow{Start: "22:00", End: "06:00"}, at(2, "05:00"), true},
+		{"spans midnight, outside", TimeWindow{Start: "22:00", End: "06:00"}, at(2, "07:00"), false},
+		{"spans midnight, from the listed day", TimeWindow{Days: []string{"mon"}, Start: "22:00", End: "06:00"}, at(2, "05:00"), true},
+		{"spans midnight, into the listed day", TimeWindow{Days: []string{"tue"}, Start: "22:00", End: "06:00"}, at(2, "05:00"), false},
+		{"location", TimeWindow{Start: "08:00", End: "09:00", Location: "Asia/Tokyo"}, at(1, "23:30"), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pw, err := tc.window.parse()
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, pw.contains(tc.t))
+		})
+	}
+}
+
+func TestValidateTransportPolicies(t *testing.T) {
+	valid := TransportPolicy{Name: "peak", Windows: []TimeWindow{{Start: "08:00", End: "18:00"}}}
+	assert.NoError(t, ValidateTransportPolicies([]TransportPolicy{valid}))
+
+	for name, policies := range map[string][]TransportPolicy{
+		"no name":    {{Windows: valid.Windows}},
+		"no windows": {{Name: "peak"}},
+		"bad time":   {{Name: "peak", Windows: []TimeWindow{{Start: "8am", End: "18:00"}}}},
+		"empty":      {{Name: "peak", Windows: []TimeWindow{{Start: "08:00", End: "08:00"}}}},
+		"bad day":    {{Name: "peak", Windows: []TimeWindow{{Days: []string{"someday"}, Start: "08:00", End: "18:00"}}}},
+		"bad zone":   {{Name: "peak", Windows: []TimeWindow{{Start: "08:00", End: "18:00", Location: "Nowhere/City"}}}},
+		"duplicate":  {valid, valid},
+	} {
+		assert.Error(t, ValidateTransportPolicies(policies), name)
+	}
+}
+
+func TestTransportPolicy_Matches(t *testing.T) {
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+
+	all := TransportPolicy{Name: "all"}
+	assert.True(t, all.Matches(pk1, "stcp"))
+
+	stcp := TransportPolicy{Name: "stcp", Types: []string{"stcp"}, Remotes: []cipher.PubKey{pk1}}
+	assert.True(t, stcp.Matches(pk1, "stcp"))
+	assert.False(t, stcp.Matches(pk1, "dmsg"))
+	assert.False(t, stcp.Matches(pk2, "stcp"))
+}
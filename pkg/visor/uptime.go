@@ -0,0 +1,203 @@
+package visor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+)
+
+// Reasons of visor shutdowns, as reported in UptimeStats.
+const (
+	ShutdownCrash   = "crash"   // The visor exited without recording a reason, as on a panic, a kill or a power loss.
+	ShutdownStop    = "stop"    // The visor was closed, as on a signal.
+	ShutdownRestart = "restart" // The visor was restarted by an operator.
+	ShutdownUpdate  = "update"  // The visor restarted to apply an update or a rollback.
+	ShutdownDrain   = "drain"   // The visor shut down once drained.
+	ShutdownPower   = "power"   // The host of the visor was rebooted or powered off via RPC.
+)
+
+const (
+	uptimeFileName     = "uptime.json"
+	uptimeSaveInterval = time.Minute
+)
+
+// UptimeStats are the uptime of the visor across its restarts.
+type UptimeStats struct {
+	StartedAt      time.Time `json:"started_at"`
+	Uptime         float64   `json:"uptime"`                     // Seconds since the visor started.
+	TotalUptime    float64   `json:"total_uptime"`               // Seconds the visor has run, over all its runs.
+	Boots          uint64    `json:"boots"`                      // Times the visor has started, this run included.
+	LastShutdown   string    `json:"last_shutdown,omitempty"`    // Reason of the shutdown before this run, unset on the first run.
+	LastShutdownAt time.Time `json:"last_shutdown_at,omitempty"` // Last time the previous run was known to be running.
+}
+
+// uptimeRecord is the uptime of the visor, as persisted in its directory.
+type uptimeRecord struct {
+	TotalUptime    Duration   `json:"total_uptime"` // Of the runs before the current one.
+	Boots          uint64     `json:"boots"`
+	LastShutdown   string     `json:"last_shutdown,omitempty"`
+	LastShutdownAt time.Time  `json:"last_shutdown_at"`
+	Run            *uptimeRun `json:"run,omitempty"`
+}
+
+// uptimeRun is the current run of the visor. A run left in the record by a visor which did not set its
+// ending is accounted as a crash by the next run.
+type uptimeRun struct {
+	StartedAt time.Time `json:"started_at"`
+	SeenAt    time.Time `json:"seen_at"`          // Last time the run was saved.
+	Ending    string    `json:"ending,omitempty"` // Reason of the shutdown, set right before it.
+}
+
+// uptimeTracker persists the uptime of the visor, every uptimeSaveInterval and on shutdowns.
+// The zero value reports the current run only, until started.
+type uptimeTracker struct {
+	mu    sync.Mutex
+	path  string
+	rec   uptimeRecord
+	ended bool
+	done  chan struct{}
+	log   *logging.Logger
+}
+
+// start accounts the previous run in the record at path, and starts recording the run started at now.
+func (u *uptimeTracker) start(path string, now time.Time, log *logging.Logger) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.path = path
+	u.log = log
+	u.done = make(chan struct{})
+
+	if raw, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(raw, &u.rec); err != nil {
+			log.WithError(err).Warnf("Failed to read uptime record %s, starting over.", path)
+			u.rec = uptimeRecord{}
+		}
+	} else if !os.IsNotExist(err) {
+		log.WithError(err).Warnf("Failed to read uptime record %s, starting over.", path)
+	}
+
+	if prev := u.rec.Run; prev != nil {
+		u.rec.TotalUptime += Duration(prev.SeenAt.Sub(prev.StartedAt))
+		u.rec.LastShutdown = prev.Ending
+		u.rec.LastShutdownAt = prev.SeenAt
+
+		if u.rec.LastShutdown == "" {
+			u.rec.LastShutdown = ShutdownCrash
+		}
+
+		log.WithField("reason", u.rec.LastShutdown).Infof("Visor was last running at %s.", prev.SeenAt.Format(time.RFC3339))
+	}
+
+	u.rec.Boots++
+	u.rec.Run = &uptimeRun{StartedAt: now, SeenAt: now}
+	u.save()
+
+	go u.saveLoop(u.done)
+}
+
+func (u *uptimeTracker) saveLoop(done <-chan struct{}) {
+	t := time.NewTicker(uptimeSaveInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-t.C:
+			u.mu.Lock()
+			if !u.ended {
+				u.rec.Run.SeenAt = now
+				u.save()
+			}
+			u.mu.Unlock()
+		}
+	}
+}
+
+// save writes the record. It is to be called with mu locked.
+func (u *uptimeTracker) save() {
+	raw, err := json.Marshal(u.rec)
+	if err == nil {
+		err = pathutil.AtomicWriteFile(u.path, raw)
+	}
+
+	if err != nil {
+		u.log.WithError(err).Warnf("Failed to save uptime record %s.", u.path)
+	}
+}
+
+// end records that the visor is about to shut down for reason, and stops saving the run so that a visor
+// which replaces this one may take over the record. Only the first reason is recorded, and end reports
+// whether it was this one.
+func (u *uptimeTracker) end(reason string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.path == "" || u.ended {
+		return false
+	}
+
+	u.ended = true
+	u.rec.Run.SeenAt = time.Now()
+	u.rec.Run.Ending = reason
+	u.save()
+
+	return true
+}
+
+// resume carries on recording the run, after a shutdown for which end returned true did not happen.
+func (u *uptimeTracker) resume() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.path == "" || !u.ended {
+		return
+	}
+
+	u.ended = false
+	u.rec.Run.SeenAt = time.Now()
+	u.rec.Run.Ending = ""
+	u.save()
+}
+
+// close records a ShutdownStop, unless another reason was recorded, and stops saving the run.
+func (u *uptimeTracker) close() {
+	u.end(ShutdownStop)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.done != nil {
+		close(u.done)
+		u.done = nil
+	}
+}
+
+// stats returns the uptime stats of the run started at startedAt.
+func (u *uptimeTracker) stats(startedAt time.Time) *UptimeStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	uptime := time.Since(startedAt)
+
+	return &UptimeStats{
+		StartedAt:      startedAt,
+		Uptime:         uptime.Seconds(),
+		TotalUptime:    (time.Duration(u.rec.TotalUptime) + uptime).Seconds(),
+		Boots:          u.rec.Boots,
+		LastShutdown:   u.rec.LastShutdown,
+		LastShutdownAt: u.rec.LastShutdownAt,
+	}
+}
+
+// UptimeStats returns the uptime of the visor across its restarts.
+func (visor *Visor) UptimeStats() *UptimeStats {
+	return visor.uptime.stats(visor.startedAt)
+}
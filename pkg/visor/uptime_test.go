@@ -0,0 +1,75 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUptimeTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uptime")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	path := filepath.Join(dir, uptimeFileName)
+	log := logging.MustGetLogger("test")
+
+	run := func(startedAt time.Time) *uptimeTracker {
+		var u uptimeTracker
+		u.start(path, startedAt, log)
+
+		return &u
+	}
+
+	u := run(time.Now().Add(-time.Hour))
+	stats := u.stats(time.Now().Add(-time.Hour))
+	assert.Equal(t, uint64(1), stats.Boots)
+	assert.Empty(t, stats.LastShutdown)
+
+	require.True(t, u.end(ShutdownRestart))
+	assert.False(t, u.end(ShutdownStop))
+	u.close()
+
+	startedAt := time.Now()
+	u = run(startedAt)
+	stats = u.stats(startedAt)
+	assert.Equal(t, uint64(2), stats.Boots)
+	assert.Equal(t, ShutdownRestart, stats.LastShutdown)
+	assert.InDelta(t, time.Hour.Seconds(), stats.TotalUptime, 5)
+
+	t.Run("crash", func(t *testing.T) {
+		require.True(t, u.end(ShutdownUpdate))
+		u.resume()
+
+		// The visor exits without recording a reason.
+		crashed := u
+		defer crashed.close()
+
+		u := run(time.Now())
+		defer u.close()
+
+		stats := u.stats(time.Now())
+		assert.Equal(t, uint64(3), stats.Boots)
+		assert.Equal(t, ShutdownCrash, stats.LastShutdown)
+	})
+
+	t.Run("corrupt_record", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+
+		u := run(time.Now())
+		defer u.close()
+
+		stats := u.stats(time.Now())
+		assert.Equal(t, uint64(1), stats.Boots)
+		assert.Empty(t, stats.LastShutdown)
+	})
+}
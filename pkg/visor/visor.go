@@ -4,9 +4,11 @@ package visor
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,22 +19,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/skycoin/dmsg"
 	"github.com/skycoin/dmsg/cipher"
 	"github.com/skycoin/dmsg/dmsgpty"
 	"github.com/skycoin/skycoin/src/util/logging"
 
+	"github.com/skycoin/skywire/pkg/app"
 	"github.com/skycoin/skywire/pkg/app/appcommon"
 	"github.com/skycoin/skywire/pkg/app/appnet"
 	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/restart"
-	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
 	"github.com/skycoin/skywire/pkg/router"
 	"github.com/skycoin/skywire/pkg/routing"
 	"github.com/skycoin/skywire/pkg/skyenv"
 	"github.com/skycoin/skywire/pkg/snet"
 	"github.com/skycoin/skywire/pkg/transport"
 	"github.com/skycoin/skywire/pkg/util/pathutil"
+	"github.com/skycoin/skywire/pkg/util/rpcutil"
 	"github.com/skycoin/skywire/pkg/util/updater"
 )
 
@@ -50,6 +54,15 @@ const (
 var (
 	// ErrUnknownApp represents lookup error for App related calls.
 	ErrUnknownApp = errors.New("unknown app")
+
+	// ErrAppNotRunning is returned when the app is not running.
+	ErrAppNotRunning = errors.New("app is not running")
+
+	// ErrExecDisabled is returned by Exec if remote execution is disabled in the config.
+	ErrExecDisabled = errors.New("exec is disabled")
+
+	// ErrExecNotAllowed is returned by Exec if the command does not match the configured allowlist.
+	ErrExecNotAllowed = errors.New("command is not allowed")
 )
 
 const (
@@ -62,39 +75,72 @@ var reservedPorts = map[routing.Port]string{0: "router", 1: "skychat", 3: "skyso
 
 // AppState defines state parameters for a registered App.
 type AppState struct {
-	Name      string       `json:"name"`
-	AutoStart bool         `json:"autostart"`
-	Port      routing.Port `json:"port"`
-	Status    AppStatus    `json:"status"`
+	Name      string            `json:"name"`
+	Binary    string            `json:"binary"`
+	AutoStart bool              `json:"autostart"`
+	Port      routing.Port      `json:"port"`
+	Status    AppStatus         `json:"status"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+
+	RestartPolicy  string   `json:"restart_policy,omitempty"`
+	Crashes        int      `json:"crashes"`                   // Number of times the app exited with an error since the visor started.
+	Restarts       int      `json:"restarts"`                  // Number of times the app was restarted by its restart policy.
+	RestartPending bool     `json:"restart_pending,omitempty"` // Whether the app is waiting to be restarted.
+	LastExit       *AppExit `json:"last_exit,omitempty"`
+
+	Health         AppHealth `json:"health,omitempty"`          // Health of the app, if it is running and registered a health check.
+	HealthError    string    `json:"health_error,omitempty"`    // Error of the last failed health check.
+	HealthFailures int       `json:"health_failures,omitempty"` // Number of consecutive failed health checks.
 }
 
 // Visor provides messaging runtime for Apps by setting up all
 // necessary connections and performing messaging gateway functions.
 type Visor struct {
-	conf   *Config
-	router router.Router
-	n      *snet.Network
-	tm     *transport.Manager
-	pty    *dmsgpty.Host
+	conf         *Config
+	router       router.Router
+	n            *snet.Network
+	tm           *transport.Manager
+	tpPolicies   *tpPolicies   // transport policies enforced on tm
+	tpMaintainer *tpMaintainer // transport targets maintained on tm
+	tpDisc       *tpDiscovery  // transport discovery client of tm, replaced on config reload
+	routeFinder  *routeFinder  // route finder client of router, replaced on config reload
+	pty          *dmsgpty.Host
 
 	Logger *logging.MasterLogger
 	logger *logging.Logger
 
-	appsPath  string
-	localPath string
-	appsConf  map[string]AppConfig
+	appsPath   string
+	localPath  string
+	appsConf   map[string]AppConfig
+	appsConfMu sync.RWMutex
 
 	startedAt  time.Time
 	restartCtx *restart.Context
 	updater    *updater.Updater
 
-	pidMu sync.Mutex
+	pidMu     sync.Mutex
+	installMu sync.Mutex // serializes InstallApp
 
-	cliLis net.Listener
-	hvErrs map[cipher.PubKey]chan error // errors returned when the associated hypervisor ServeRPCClient returns
+	cliLis      net.Listener
+	metricsLis  net.Listener
+	localAPILis net.Listener
+	metrics     *visorMetrics                      // nil if the metrics listener is disabled
+	hvErrs      map[cipher.PubKey]chan error       // errors returned when the associated hypervisor ServeRPCClient returns
+	hvConfs     map[cipher.PubKey]HypervisorConfig // configs of the hypervisors served
+	hvWhitelist *hypervisorWhitelist               // hypervisors allowed to connect over RPC and dmsgpty
 
 	procManager  appserver.ProcManager
 	appRPCServer *appserver.Server
+	execs        execSessions // commands started with ExecStart
+	appRuns      appRuns      // runs of apps, restarted by their restart policies
+	bwTests      bandwidthTests
+	events       eventBus      // events of transports, routes, apps and updates
+	drain        drainState    // set once the visor is draining
+	uptime       uptimeTracker // uptime across restarts, persisted in the visor directory
+
+	appServerFailure failure // set if the app server stops serving
 
 	// cancel is to be called when visor.Close is triggered.
 	cancel context.CancelFunc
@@ -131,13 +177,19 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 		SecKey: sk,
 		Dmsg:   cfg.DmsgConfig(),
 		STCP:   cfg.STCP,
+		SUDPH:  cfg.SUDPH,
+		KCP:    cfg.KCP,
 	})
 	if err := visor.n.Init(ctx); err != nil {
 		return nil, fmt.Errorf("failed to init network: %v", err)
 	}
 
+	if visor.hvWhitelist, err = newHypervisorWhitelist(cfg.HypervisorWhitelist(), cfg.ReadOnlyHypervisors()); err != nil {
+		return nil, fmt.Errorf("failed to whitelist hypervisors: %v", err)
+	}
+
 	if cfg.DmsgPty != nil {
-		pty, err := cfg.DmsgPtyHost(visor.n.Dmsg())
+		pty, err := cfg.DmsgPtyHost(visor.n.Dmsg(), visor.hvWhitelist.pty)
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup pty: %v", err)
 		}
@@ -151,6 +203,8 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 		return nil, fmt.Errorf("invalid transport discovery config: %s", err)
 	}
 
+	visor.tpDisc = newTpDiscovery(cfg.Transport.Discovery, trDiscovery)
+
 	logStore, err := cfg.TransportLogStore()
 	if err != nil {
 		return nil, fmt.Errorf("invalid TransportLogStore: %s", err)
@@ -160,8 +214,11 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 		PubKey:          pk,
 		SecKey:          sk,
 		DefaultVisors:   cfg.TrustedVisors,
-		DiscoveryClient: trDiscovery,
+		DiscoveryClient: visor.tpDisc,
 		LogStore:        logStore,
+		StatusHook:      visor.transportStatusChanged,
+		Reconnect:       cfg.Transport.Reconnect.Config(),
+		ReconnectHook:   visor.transportReconnectFailed,
 	}
 
 	visor.tm, err = transport.NewManager(visor.n, tmConfig)
@@ -169,13 +226,29 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 		return nil, fmt.Errorf("transport manager: %s", err)
 	}
 
+	if err := ValidateTransportPolicies(cfg.Transport.Policies); err != nil {
+		return nil, fmt.Errorf("invalid transport policies: %w", err)
+	}
+
+	visor.tpPolicies = newTpPolicies(visor.tm, visor.Logger.PackageLogger("transport_policies"), cfg.Transport.Policies)
+
+	if err := ValidateTransportTargets(cfg.Transport.Maintain); err != nil {
+		return nil, fmt.Errorf("invalid transport targets: %w", err)
+	}
+
+	visor.tpMaintainer = newTpMaintainer(visor.tm, visor.tpPolicies,
+		visor.Logger.PackageLogger("transport_maintenance"), cfg.Transport.Maintain)
+
+	visor.routeFinder = newRouteFinder(cfg.RoutingConfig().RouteFinder, time.Duration(cfg.RoutingConfig().RouteFinderTimeout))
+
 	rConfig := &router.Config{
 		Logger:           visor.Logger.PackageLogger("router"),
 		PubKey:           pk,
 		SecKey:           sk,
 		TransportManager: visor.tm,
-		RouteFinder:      rfclient.NewHTTP(cfg.RoutingConfig().RouteFinder, time.Duration(cfg.RoutingConfig().RouteFinderTimeout)),
+		RouteFinder:      visor.routeFinder,
 		SetupNodes:       cfg.RoutingConfig().SetupNodes,
+		RuleHook:         visor.ruleSaved,
 	}
 
 	r, err := router.New(visor.n, rConfig)
@@ -212,9 +285,34 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 		visor.cliLis = l
 	}
 
+	if cfg.Interfaces != nil && cfg.Interfaces.LocalAPIAddress != "" {
+		if err := checkLocalAPIAddr(cfg.Interfaces.LocalAPIAddress); err != nil {
+			return nil, fmt.Errorf("invalid local API address: %w", err)
+		}
+
+		l, err := net.Listen("tcp", cfg.Interfaces.LocalAPIAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup local API listener: %s", err)
+		}
+
+		visor.localAPILis = l
+	}
+
+	if cfg.Metrics != nil {
+		l, err := net.Listen("tcp", cfg.Metrics.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup metrics listener: %s", err)
+		}
+
+		visor.metricsLis = l
+		visor.metrics = newVisorMetrics(visor)
+	}
+
 	visor.hvErrs = make(map[cipher.PubKey]chan error, len(cfg.Hypervisors))
+	visor.hvConfs = make(map[cipher.PubKey]HypervisorConfig, len(cfg.Hypervisors))
 	for _, hv := range cfg.Hypervisors {
 		visor.hvErrs[hv.PubKey] = make(chan error, 1)
+		visor.hvConfs[hv.PubKey] = hv
 	}
 
 	visor.appRPCServer = appserver.New(logging.MustGetLogger("app_rpc_server"), visor.conf.AppServerAddr)
@@ -222,6 +320,7 @@ func NewVisor(cfg *Config, logger *logging.MasterLogger, restartCtx *restart.Con
 	go func() {
 		if err := visor.appRPCServer.ListenAndServe(); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
 			visor.logger.WithError(err).Error("Serve app_rpc stopped.")
+			visor.appServerFailure.set(err)
 		}
 	}()
 
@@ -249,6 +348,8 @@ func (visor *Visor) Start() error {
 		return err
 	}
 
+	visor.uptime.start(filepath.Join(visor.dir(), uptimeFileName), visor.startedAt, visor.logger)
+
 	if err := visor.startApps(); err != nil {
 		return err
 	}
@@ -259,6 +360,27 @@ func (visor *Visor) Start() error {
 
 	visor.startRPC(ctx)
 
+	if visor.metricsLis != nil {
+		go visor.serveMetrics()
+	}
+
+	if visor.localAPILis != nil {
+		go visor.serveLocalAPI(visor.localAPILis)
+	}
+
+	go visor.serveEcho(ctx)
+	go visor.serveFiles(ctx)
+	go visor.serveBandwidthTests(ctx)
+	go visor.serveAppLogRotation(ctx)
+
+	if visor.tpPolicies != nil {
+		go visor.tpPolicies.serve(ctx)
+	}
+
+	if visor.tpMaintainer != nil {
+		go visor.tpMaintainer.serve(ctx)
+	}
+
 	visor.logger.Info("Starting packet router")
 
 	if err := visor.router.Serve(ctx); err != nil {
@@ -273,13 +395,13 @@ func (visor *Visor) startApps() error {
 		return err
 	}
 
-	for _, ac := range visor.appsConf {
+	for _, ac := range visor.appConfs() {
 		if !ac.AutoStart {
 			continue
 		}
 
 		go func(a AppConfig) {
-			if err := visor.SpawnApp(&a, nil); err != nil {
+			if err := visor.runApp(a, nil); err != nil {
 				visor.logger.
 					WithError(err).
 					WithField("app_name", a.App).
@@ -356,13 +478,13 @@ func (visor *Visor) startRPC(ctx context.Context) {
 	if visor.cliLis != nil {
 		visor.logger.Info("Starting RPC interface on ", visor.cliLis.Addr())
 
-		srv, err := newRPCServer(visor, "CLI")
+		srv, err := newRPCServer(visor, "CLI", "")
 		if err != nil {
 			visor.logger.WithError(err).Errorf("Failed to start RPC server")
 			return
 		}
 
-		go srv.Accept(visor.cliLis)
+		go serveRPCListener(visor.cliLis, visor.metrics.rpcServer(srv), visor.Logger.PackageLogger("visor_rpc:CLI"))
 	}
 
 	if visor.hvErrs != nil {
@@ -370,22 +492,69 @@ func (visor *Visor) startRPC(ctx context.Context) {
 			log := visor.Logger.PackageLogger("hypervisor_client").
 				WithField("hypervisor_pk", hvPK)
 
+			hvConf := visor.hvConfs[hvPK]
+
+			if hvConf.Standby {
+				addr := dmsg.Addr{PK: hvPK, Port: skyenv.DmsgStandbyPort}
+				go ServeHeartbeats(ctx, log, visor.n, addr, hvErrs, visor.heartbeat)
+
+				continue
+			}
+
 			addr := dmsg.Addr{PK: hvPK, Port: skyenv.DmsgHypervisorPort}
-			rpcS, err := newRPCServer(visor, addr.PK.String()[:shortHashLen])
+			rpcS, err := newRPCServer(visor, addr.PK.String()[:shortHashLen], hvConf.EnrollmentToken)
 			if err != nil {
 				visor.logger.WithError(err).Errorf("Failed to start RPC server")
 				return
 			}
 
-			go ServeRPCClient(ctx, log, visor.n, rpcS, addr, hvErrs)
+			var srv RPCServer = rpcS
+			if hvConf.ReadOnly {
+				srv = readOnlyRPCServer{RPCServer: rpcS}
+			}
+
+			go ServeRPCClient(ctx, log, visor.n, visor.metrics.rpcServer(srv), addr, hvErrs, visor.hvWhitelist.Allowed)
 		}
 	}
 }
 
+func (visor *Visor) serveMetrics() {
+	visor.logger.Info("Serving metrics on ", visor.metricsLis.Addr())
+
+	err := http.Serve(visor.metricsLis, visor.metrics.handler())
+	if err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		visor.logger.WithError(err).Error("Serve metrics stopped.")
+	}
+}
+
+// serveRPCListener serves rpcS to connections accepted by l, until l is closed.
+func serveRPCListener(l net.Listener, rpcS RPCServer, log *logging.Logger) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.WithError(err).Debug("Stopped accepting RPC connections.")
+			return
+		}
+
+		go rpcS.ServeCodec(rpcutil.NewServerCodec(conn, log))
+	}
+}
+
 func (visor *Visor) dir() string {
 	return pathutil.VisorDir(visor.conf.Keys().PubKey.String())
 }
 
+// appLogStore returns the log store of the app of given name.
+// Every app has a store of its own, in which logs are kept under the name of its binary.
+func (visor *Visor) appLogStore(appName string) (app.LogStore, error) {
+	binary := appName
+	if conf, ok := visor.appConf(appName); ok {
+		binary = conf.binary()
+	}
+
+	return app.NewLogStore(filepath.Join(visor.dir(), appName), binary, "bbolt")
+}
+
 func (visor *Visor) pidFile() (*os.File, error) {
 	f, err := os.OpenFile(filepath.Join(visor.dir(), "apps-pid.txt"), os.O_RDWR|os.O_CREATE, 0600)
 	if err != nil {
@@ -459,6 +628,8 @@ func (visor *Visor) Close() (err error) {
 		visor.cancel()
 	}
 
+	visor.uptime.close()
+
 	if visor.cliLis != nil {
 		if err = visor.cliLis.Close(); err != nil {
 			visor.logger.WithError(err).Error("failed to close CLI listener")
@@ -466,6 +637,18 @@ func (visor *Visor) Close() (err error) {
 			visor.logger.Info("CLI listener closed successfully")
 		}
 	}
+	if visor.metricsLis != nil {
+		if err = visor.metricsLis.Close(); err != nil {
+			visor.logger.WithError(err).Error("failed to close metrics listener")
+		}
+	}
+
+	if visor.localAPILis != nil {
+		if err = visor.localAPILis.Close(); err != nil {
+			visor.logger.WithError(err).Error("failed to close local API listener")
+		}
+	}
+
 	if visor.hvErrs != nil {
 		for hvPK, hvErr := range visor.hvErrs {
 			visor.logger.
@@ -475,7 +658,9 @@ func (visor *Visor) Close() (err error) {
 		}
 	}
 
+	visor.appRuns.close()
 	visor.procManager.StopAll()
+	visor.execs.stopAll(visor.logger)
 
 	if err = visor.router.Close(); err != nil {
 		visor.logger.WithError(err).Error("Failed to stop router.")
@@ -492,15 +677,33 @@ func (visor *Visor) Close() (err error) {
 
 // App returns a single app state of given name.
 func (visor *Visor) App(name string) (*AppState, bool) {
-	app, ok := visor.appsConf[name]
+	app, ok := visor.appConf(name)
 	if !ok {
 		return nil, false
 	}
-	state := &AppState{app.App, app.AutoStart, app.Port, AppStatusStopped}
+	return visor.appState(app), true
+}
+
+func (visor *Visor) appState(app AppConfig) *AppState {
+	state := &AppState{
+		Name:          app.App,
+		Binary:        app.binary(),
+		AutoStart:     app.AutoStart,
+		Port:          app.Port,
+		Status:        AppStatusStopped,
+		Args:          app.Args,
+		Env:           app.Env,
+		Settings:      app.Settings,
+		RestartPolicy: app.RestartPolicy,
+	}
+
 	if visor.procManager.Exists(app.App) {
 		state.Status = AppStatusRunning
 	}
-	return state, true
+
+	visor.appRuns.fill(state)
+
+	return state
 }
 
 // Apps returns list of AppStates for all registered apps.
@@ -508,27 +711,57 @@ func (visor *Visor) Apps() []*AppState {
 	// TODO: move app states to the app module
 	res := make([]*AppState, 0)
 
-	for _, app := range visor.appsConf {
-		state := &AppState{app.App, app.AutoStart, app.Port, AppStatusStopped}
+	for _, app := range visor.appConfs() {
+		res = append(res, visor.appState(app))
+	}
 
-		if visor.procManager.Exists(app.App) {
-			state.Status = AppStatusRunning
+	return res
+}
+
+// AppConnections returns the live skywire connections of an app.
+// Returns no connections if the app is not running.
+func (visor *Visor) AppConnections(appName string) ([]appserver.ConnSummary, error) {
+	for _, app := range visor.appConfs() {
+		if app.App != appName {
+			continue
 		}
 
-		res = append(res, state)
+		if !visor.procManager.Exists(appName) {
+			return nil, nil
+		}
+
+		return visor.procManager.Connections(appName)
 	}
 
-	return res
+	return nil, ErrUnknownApp
+}
+
+// AppUsage returns the resource usage of a running app.
+func (visor *Visor) AppUsage(appName string) (*appserver.Usage, error) {
+	if _, ok := visor.appConf(appName); !ok {
+		return nil, ErrUnknownApp
+	}
+
+	if !visor.procManager.Exists(appName) {
+		return nil, ErrAppNotRunning
+	}
+
+	usage, err := visor.procManager.Usage(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
 }
 
 // StartApp starts registered App.
 func (visor *Visor) StartApp(appName string) error {
-	for _, app := range visor.appsConf {
+	for _, app := range visor.appConfs() {
 		if app.App == appName {
 			startCh := make(chan struct{})
 
 			go func(app AppConfig) {
-				if err := visor.SpawnApp(&app, startCh); err != nil {
+				if err := visor.runApp(app, startCh); err != nil {
 					visor.logger.
 						WithError(err).
 						WithField("app_name", appName).
@@ -551,16 +784,26 @@ func (visor *Visor) SpawnApp(config *AppConfig, startCh chan<- struct{}) (err er
 		WithField("args", config.Args).
 		Info("Spawning app.")
 
-	if app, ok := reservedPorts[config.Port]; ok && app != config.App {
+	if app, ok := reservedPorts[config.Port]; ok && app != config.binary() {
 		return fmt.Errorf("can't bind to reserved port %d", config.Port)
 	}
 
 	appCfg := appcommon.Config{
 		Name:       config.App,
+		Binary:     config.Binary,
 		ServerAddr: visor.conf.AppServerAddr,
 		VisorPK:    visor.conf.Keys().PubKey.Hex(),
 		BinaryDir:  visor.appsPath,
 		WorkDir:    filepath.Join(visor.localPath, config.App),
+		Env:        config.envList(),
+	}
+
+	if config.Limits != nil {
+		appCfg.Limits = *config.Limits
+	}
+
+	if config.Sandbox != nil {
+		appCfg.Sandbox = *config.Sandbox
 	}
 
 	if _, err := ensureDir(appCfg.WorkDir); err != nil {
@@ -582,13 +825,16 @@ func (visor *Visor) SpawnApp(config *AppConfig, startCh chan<- struct{}) (err er
 	}()
 
 	appLogger := logging.MustGetLogger(fmt.Sprintf("app_%s", config.App))
-	appArgs := append([]string{filepath.Join(visor.dir(), config.App)}, config.Args...)
+	appArgs := append([]string{filepath.Join(visor.dir(), config.App)}, config.settingArgs()...)
+	appArgs = append(appArgs, config.Args...)
 
 	pid, err := visor.procManager.Start(appLogger, appCfg, appArgs, logger, errLogger)
 	if err != nil {
 		return fmt.Errorf("error running app %s: %v", config.App, err)
 	}
 
+	visor.metrics.appStarted(config.App)
+
 	if startCh != nil {
 		startCh <- struct{}{}
 	}
@@ -628,7 +874,14 @@ func (visor *Visor) persistPID(name string, pid appcommon.ProcID) error {
 
 // StopApp stops running App.
 func (visor *Visor) StopApp(appName string) error {
+	restartPending := visor.appRuns.stop(appName)
+
 	if !visor.procManager.Exists(appName) {
+		if restartPending {
+			visor.logger.Infof("Cancelled restart of app %s", appName)
+			return nil
+		}
+
 		return ErrUnknownApp
 	}
 
@@ -658,25 +911,117 @@ func (visor *Visor) RestartApp(name string) error {
 }
 
 // Exec executes a shell command. It returns combined stdout and stderr output and an error.
-func (visor *Visor) Exec(command string) ([]byte, error) {
-	args := strings.Split(command, " ")
-	cmd := exec.Command(args[0], args[1:]...) // nolint: gosec
+// Commands are checked against the 'exec' config first, and killed after timeout unless it is zero.
+func (visor *Visor) Exec(command string, timeout time.Duration) ([]byte, error) {
+	args, err := visor.conf.execConfig().command(command)
+	if err != nil {
+		visor.logger.WithError(err).Warnf("Refused to execute %q", command)
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // nolint: gosec
 	return cmd.CombinedOutput()
 }
 
+// ExecStart starts a shell command, of which output is streamed with ExecRead.
+// Commands are checked against the 'exec' config first.
+func (visor *Visor) ExecStart(command string) (uuid.UUID, error) {
+	args, err := visor.conf.execConfig().command(command)
+	if err != nil {
+		visor.logger.WithError(err).Warnf("Refused to execute %q", command)
+		return uuid.UUID{}, err
+	}
+
+	return visor.execs.start(args, visor.logger)
+}
+
+// ExecRead waits for output of a command started with ExecStart.
+func (visor *Visor) ExecRead(id uuid.UUID) (*ExecOutput, error) {
+	return visor.execs.read(id)
+}
+
+// ExecWrite writes to stdin of a command started with ExecStart, and optionally closes stdin.
+func (visor *Visor) ExecWrite(id uuid.UUID, data []byte, closeStdin bool) error {
+	return visor.execs.write(id, data, closeStdin)
+}
+
+// ExecStop kills a command started with ExecStart.
+func (visor *Visor) ExecStop(id uuid.UUID) error {
+	return visor.execs.stop(id)
+}
+
 // Update updates visor.
 // It checks if visor update is available.
 // If it is, the method downloads a new visor versions, starts it and kills the current process.
 func (visor *Visor) Update() (bool, error) {
+	// The updated visor is started before this one exits.
+	ended := visor.uptime.end(ShutdownUpdate)
+
 	updated, err := visor.updater.Update()
+	if ended && !updated {
+		visor.uptime.resume()
+	}
+
 	if err != nil {
 		visor.logger.Errorf("Failed to update visor: %v", err)
 		return false, err
 	}
 
+	if updated {
+		visor.publish(EventUpdateApplied, UpdateEvent{})
+	}
+
 	return updated, nil
 }
 
+// UpdateApp updates a single app to the latest release, restarting it if it is running.
+// The visor and other apps are left as they are.
+func (visor *Visor) UpdateApp(appName string) (bool, error) {
+	if _, ok := visor.appConf(appName); !ok {
+		return false, ErrUnknownApp
+	}
+
+	updated, err := visor.updater.UpdateApp(appName)
+	if err != nil {
+		visor.logger.Errorf("Failed to update app %s: %v", appName, err)
+		return false, err
+	}
+
+	if updated {
+		visor.publish(EventUpdateApplied, UpdateEvent{App: appName})
+	}
+
+	if updated && visor.procManager.Exists(appName) {
+		return true, visor.RestartApp(appName)
+	}
+
+	return updated, nil
+}
+
+// Rollback restores the visor binaries replaced by the last update and restarts the visor.
+func (visor *Visor) Rollback() error {
+	ended := visor.uptime.end(ShutdownUpdate)
+
+	if err := visor.updater.Rollback(); err != nil {
+		if ended {
+			visor.uptime.resume()
+		}
+
+		visor.logger.Errorf("Failed to roll back visor: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 // UpdateAvailable checks if visor update is available.
 func (visor *Visor) UpdateAvailable() (*updater.Version, error) {
 	version, err := visor.updater.UpdateAvailable()
@@ -688,119 +1033,211 @@ func (visor *Visor) UpdateAvailable() (*updater.Version, error) {
 	return version, nil
 }
 
+// Config returns the visor config encoded as JSON. The secret key is omitted.
+func (visor *Visor) Config() ([]byte, error) {
+	return visor.conf.redactedJSON()
+}
+
+// SetConfig validates a JSON encoded config and saves it as the visor config.
+// The visor key pair and the local-only settings (see Config.setRemote) can't be changed this way and are kept.
+// Most changes only take effect after the visor restarts.
+func (visor *Visor) SetConfig(raw []byte) error {
+	var conf Config
+	if err := json.Unmarshal(raw, &conf); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	visor.logger.Info("Saving new visor config")
+
+	applied, err := visor.conf.replace(&conf)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		visor.logger.Warn("Kept the local-only settings of the visor config, they are only applied by reloading the config file")
+	}
+
+	appsConf, err := visor.conf.AppsConfig()
+	if err != nil {
+		return err
+	}
+
+	visor.setAppsConf(appsConf)
+
+	return nil
+}
+
+// appConf returns the config of the app of given name.
+func (visor *Visor) appConf(appName string) (AppConfig, bool) {
+	visor.appsConfMu.RLock()
+	defer visor.appsConfMu.RUnlock()
+
+	ac, ok := visor.appsConf[appName]
+
+	return ac, ok
+}
+
+// appConfs returns the configs of all apps.
+func (visor *Visor) appConfs() []AppConfig {
+	visor.appsConfMu.RLock()
+	defer visor.appsConfMu.RUnlock()
+
+	apps := make([]AppConfig, 0, len(visor.appsConf))
+	for _, ac := range visor.appsConf {
+		apps = append(apps, ac)
+	}
+
+	return apps
+}
+
+// setAppConf sets the config of the app of given name.
+func (visor *Visor) setAppConf(appName string, ac AppConfig) {
+	visor.appsConfMu.Lock()
+	visor.appsConf[appName] = ac
+	visor.appsConfMu.Unlock()
+}
+
+// setAppsConf replaces the configs of all apps, returning the previous ones.
+func (visor *Visor) setAppsConf(appsConf map[string]AppConfig) map[string]AppConfig {
+	visor.appsConfMu.Lock()
+	defer visor.appsConfMu.Unlock()
+
+	prev := visor.appsConf
+	visor.appsConf = appsConf
+
+	return prev
+}
+
 func (visor *Visor) setAutoStart(appName string, autoStart bool) error {
-	appConf, ok := visor.appsConf[appName]
+	appConf, ok := visor.appConf(appName)
 	if !ok {
 		return ErrUnknownApp
 	}
 
 	appConf.AutoStart = autoStart
-	visor.appsConf[appName] = appConf
+	visor.setAppConf(appName, appConf)
 
 	visor.logger.Infof("Saving auto start = %v for app %v to config", autoStart, appName)
 
 	return visor.updateAppAutoStart(appName, autoStart)
 }
 
-func (visor *Visor) setSocksPassword(password string) error {
-	visor.logger.Infof("Changing skysocks password to %q", password)
+func (visor *Visor) setAppArgs(appName string, args []string) error {
+	visor.logger.Infof("Changing args of app %v to %q", appName, args)
 
-	const (
-		socksName       = "skysocks"
-		passcodeArgName = "-passcode"
-	)
+	return visor.updateApp(appName, func(app *AppConfig) {
+		app.Args = args
+	})
+}
 
-	if err := visor.updateAppArg(socksName, passcodeArgName, password); err != nil {
-		return err
-	}
+func (visor *Visor) setAppEnv(appName string, env map[string]string) error {
+	visor.logger.Infof("Changing env of app %v", appName)
 
-	if visor.procManager.Exists(socksName) {
-		visor.logger.Infof("Updated %v password, restarting it", socksName)
-		return visor.RestartApp(socksName)
+	return visor.updateApp(appName, func(app *AppConfig) {
+		app.Env = env
+	})
+}
+
+// appSettings returns the settings of the app of given name.
+func (visor *Visor) appSettings(appName string) (map[string]string, error) {
+	app, ok := visor.appConf(appName)
+	if !ok {
+		return nil, ErrUnknownApp
 	}
 
-	visor.logger.Infof("Updated %v password", socksName)
+	settings := make(map[string]string, len(app.Settings))
+	for k, v := range app.Settings {
+		settings[k] = v
+	}
 
-	return nil
+	return settings, nil
 }
 
-func (visor *Visor) setSocksClientPK(pk cipher.PubKey) error {
-	visor.logger.Infof("Changing skysocks-client PK to %q", pk)
-
-	const (
-		socksClientName = "skysocks-client"
-		pkArgName       = "-srv"
-	)
+// setAppSetting sets a setting of the app, restarting the app if it is running.
+// The flag of the setting is removed from the args of the app, so that it doesn't override the setting.
+func (visor *Visor) setAppSetting(appName, key, value string) error {
+	visor.logger.Infof("Changing setting %v of app %v", key, appName)
 
-	if err := visor.updateAppArg(socksClientName, pkArgName, pk.String()); err != nil {
-		return err
-	}
-
-	if visor.procManager.Exists(socksClientName) {
-		visor.logger.Infof("Updated %v PK, restarting it", socksClientName)
-		return visor.RestartApp(socksClientName)
-	}
+	return visor.updateApp(appName, func(app *AppConfig) {
+		settings := make(map[string]string, len(app.Settings)+1)
+		for k, v := range app.Settings {
+			settings[k] = v
+		}
 
-	visor.logger.Infof("Updated %v PK", socksClientName)
+		settings[key] = value
 
-	return nil
+		app.Settings = settings
+		app.Args = withoutFlag(app.Args, key)
+	})
 }
 
-func (visor *Visor) updateAppAutoStart(appName string, autoStart bool) error {
-	changed := false
+// updateApp applies the change to the config of the app and saves it, restarting the app if it is running.
+func (visor *Visor) updateApp(appName string, change func(app *AppConfig)) error {
+	var appConf AppConfig
 
-	for i := range visor.conf.Apps {
-		if visor.conf.Apps[i].App == appName {
-			visor.conf.Apps[i].AutoStart = autoStart
-			if v, ok := visor.appsConf[appName]; ok {
-				v.AutoStart = autoStart
-				visor.appsConf[appName] = v
-			}
+	err := visor.conf.updateApps(func(apps []AppConfig) ([]AppConfig, error) {
+		i := 0
+		for i < len(apps) && apps[i].App != appName {
+			i++
+		}
 
-			changed = true
-			break
+		if i == len(apps) {
+			return nil, ErrUnknownApp
 		}
-	}
 
-	if !changed {
-		return nil
+		change(&apps[i])
+		appConf = apps[i]
+
+		return apps, nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return visor.conf.flush()
-}
+	visor.setAppConf(appName, appConf)
+
+	if err := visor.conf.flush(); err != nil {
+		return err
+	}
 
-func (visor *Visor) updateAppArg(appName, argName, value string) error {
-	configChanged := true
+	if visor.procManager.Exists(appName) {
+		visor.logger.Infof("Updated %v, restarting it", appName)
+		return visor.RestartApp(appName)
+	}
 
-	for i := range visor.conf.Apps {
-		argChanged := false
-		if visor.conf.Apps[i].App == appName {
-			configChanged = true
+	return nil
+}
 
-			for j := range visor.conf.Apps[i].Args {
-				if visor.conf.Apps[i].Args[j] == argName && j+1 < len(visor.conf.Apps[i].Args) {
-					visor.conf.Apps[i].Args[j+1] = value
-					argChanged = true
-					break
-				}
-			}
+func (visor *Visor) updateAppAutoStart(appName string, autoStart bool) error {
+	changed := false
 
-			if !argChanged {
-				visor.conf.Apps[i].Args = append(visor.conf.Apps[i].Args, argName, value)
-			}
+	err := visor.conf.updateApps(func(apps []AppConfig) ([]AppConfig, error) {
+		for i := range apps {
+			if apps[i].App == appName {
+				apps[i].AutoStart = autoStart
+				changed = true
 
-			if v, ok := visor.appsConf[appName]; ok {
-				v.Args = visor.conf.Apps[i].Args
-				visor.appsConf[appName] = v
+				break
 			}
 		}
+
+		return apps, nil
+	})
+	if err != nil || !changed {
+		return err
 	}
 
-	if configChanged {
-		return visor.conf.flush()
+	if v, ok := visor.appConf(appName); ok {
+		v.AutoStart = autoStart
+		visor.setAppConf(appName, v)
 	}
 
-	return nil
+	return visor.conf.flush()
 }
 
 // UnlinkSocketFiles removes unix socketFiles from file system
@@ -140,7 +140,7 @@ func TestVisorStartClose(t *testing.T) {
 
 	var netConf snet.Config
 
-	network := snet.NewRaw(netConf, dmsgC, nil)
+	network := snet.NewRaw(netConf, dmsgC, nil, nil, nil)
 	tmConf := &transport.ManagerConfig{
 		PubKey:          cipher.PubKey{},
 		DiscoveryClient: transport.NewDiscoveryMock(),
@@ -321,3 +321,30 @@ func TestVisorSpawnAppValidations(t *testing.T) {
 		assert.Equal(t, wantErr, err.Error())
 	})
 }
+
+func TestVisorAppUsage(t *testing.T) {
+	usage := appserver.Usage{CPUTime: time.Second, Memory: 1 << 20, Files: 8, Limits: appcommon.Limits{Files: 64}}
+
+	pm := &appserver.MockProcManager{}
+	pm.On("Exists", "running").Return(true)
+	pm.On("Exists", "stopped").Return(false)
+	pm.On("Usage", "running").Return(usage, testhelpers.NoErr)
+
+	visor := &Visor{
+		appsConf: map[string]AppConfig{
+			"running": {App: "running"},
+			"stopped": {App: "stopped"},
+		},
+		procManager: pm,
+	}
+
+	got, err := visor.AppUsage("running")
+	require.NoError(t, err)
+	require.Equal(t, usage, *got)
+
+	_, err = visor.AppUsage("stopped")
+	require.Equal(t, ErrAppNotRunning, err)
+
+	_, err = visor.AppUsage("unknown")
+	require.Equal(t, ErrUnknownApp, err)
+}
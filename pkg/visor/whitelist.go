@@ -0,0 +1,107 @@
+package visor
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/skycoin/dmsg/cipher"
+	"github.com/skycoin/dmsg/dmsgpty"
+)
+
+// hypervisorWhitelist holds public keys of hypervisors which are allowed to manage the visor.
+type hypervisorWhitelist struct {
+	pks      map[cipher.PubKey]struct{}
+	readOnly map[cipher.PubKey]struct{} // hypervisors with read-only access
+	pty      dmsgpty.Whitelist          // kept in sync with pks (except read-only ones) for the dmsgpty host
+	mu       sync.RWMutex
+}
+
+func newHypervisorWhitelist(pks, readOnly []cipher.PubKey) (*hypervisorWhitelist, error) {
+	wl := &hypervisorWhitelist{
+		pks:      make(map[cipher.PubKey]struct{}, len(pks)),
+		readOnly: make(map[cipher.PubKey]struct{}, len(readOnly)),
+		pty:      dmsgpty.NewMemoryWhitelist(),
+	}
+
+	for _, pk := range readOnly {
+		wl.readOnly[pk] = struct{}{}
+	}
+
+	return wl, wl.update(pks, nil)
+}
+
+// Allowed returns whether the hypervisor of pk is whitelisted.
+func (wl *hypervisorWhitelist) Allowed(pk cipher.PubKey) bool {
+	wl.mu.RLock()
+	_, ok := wl.pks[pk]
+	wl.mu.RUnlock()
+
+	return ok
+}
+
+// ReadOnly returns whether the hypervisor of pk only has read-only access.
+func (wl *hypervisorWhitelist) ReadOnly(pk cipher.PubKey) bool {
+	wl.mu.RLock()
+	_, ok := wl.readOnly[pk]
+	wl.mu.RUnlock()
+
+	return ok
+}
+
+// PubKeys returns the whitelisted keys in sorted order.
+func (wl *hypervisorWhitelist) PubKeys() []cipher.PubKey {
+	wl.mu.RLock()
+	pks := make([]cipher.PubKey, 0, len(wl.pks))
+	for pk := range wl.pks {
+		pks = append(pks, pk)
+	}
+	wl.mu.RUnlock()
+
+	sort.Slice(pks, func(i, j int) bool { return pks[i].Hex() < pks[j].Hex() })
+
+	return pks
+}
+
+func (wl *hypervisorWhitelist) update(add, remove []cipher.PubKey) error {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	for _, pk := range add {
+		if _, ok := wl.readOnly[pk]; !ok {
+			if err := wl.pty.Add(pk); err != nil {
+				return err
+			}
+		}
+
+		wl.pks[pk] = struct{}{}
+	}
+
+	for _, pk := range remove {
+		if err := wl.pty.Remove(pk); err != nil {
+			return err
+		}
+
+		delete(wl.pks, pk)
+	}
+
+	return nil
+}
+
+// HypervisorPKs returns the public keys of hypervisors which are allowed to manage the visor.
+func (visor *Visor) HypervisorPKs() []cipher.PubKey {
+	return visor.hvWhitelist.PubKeys()
+}
+
+// UpdateHypervisorPKs adds and removes keys of the hypervisor whitelist, and saves it in the config.
+// Connections of removed hypervisors are closed.
+func (visor *Visor) UpdateHypervisorPKs(add, remove []cipher.PubKey) error {
+	if err := visor.hvWhitelist.update(add, remove); err != nil {
+		return err
+	}
+
+	visor.conf.flushMu.Lock()
+	visor.conf.HypervisorPKs = visor.hvWhitelist.PubKeys()
+	visor.conf.flushMu.Unlock()
+
+	return visor.conf.flush()
+}